@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+)
+
+// ANSI colors of the log lines.
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// newLogger creates the CLI's logger on out, colored when out is a
+// terminal that understands ANSI colors, unless -no-color is passed or
+// NO_COLOR is set.
+func (c *jobConfig) newLogger(out *os.File) *log.Logger {
+	w := io.Writer(out)
+	if c.useColor(out) {
+		w = &colorWriter{w: out}
+	}
+	return log.New(w, "", log.LstdFlags)
+}
+
+// useColor reports whether the log on out is colored (see newLogger).
+func (c *jobConfig) useColor(out *os.File) bool {
+	return !*c.noColor && os.Getenv("NO_COLOR") == "" && isTerminal(out) && enableANSI(out)
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorWriter colors each log line it is given by what it reports: errors
+// red, warnings yellow, successes green. Other lines pass unchanged. The
+// logger writes a whole line per call.
+type colorWriter struct {
+	w io.Writer
+}
+
+func (cw *colorWriter) Write(p []byte) (int, error) {
+	color := lineColor(p)
+	if color == "" {
+		return cw.w.Write(p)
+	}
+	line := bytes.TrimSuffix(p, []byte("\n"))
+	var buf bytes.Buffer
+	buf.Grow(len(p) + len(color) + len(colorReset))
+	buf.WriteString(color)
+	buf.Write(line)
+	buf.WriteString(colorReset)
+	if len(line) < len(p) {
+		buf.WriteByte('\n')
+	}
+	if _, err := cw.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// lineColor picks the color of a log line, or "" to leave it alone.
+func lineColor(line []byte) string {
+	switch {
+	case bytes.Contains(line, []byte("ERROR")), bytes.Contains(line, []byte("Job failed")):
+		return colorRed
+	case bytes.Contains(line, []byte("WARNING")):
+		return colorYellow
+	case bytes.Contains(line, []byte("successfully")), bytes.Contains(line, []byte("Success: Got")):
+		return colorGreen
+	}
+	return ""
+}
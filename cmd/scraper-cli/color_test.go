@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestColorWriter(t *testing.T) {
+	tests := []struct {
+		line, want string
+	}{
+		{"[JOB 1] ERROR: download failed\n", colorRed + "[JOB 1] ERROR: download failed" + colorReset + "\n"},
+		{"Job failed: 1\n", colorRed + "Job failed: 1" + colorReset + "\n"},
+		{"[JOB 1] WARNING: slow\n", colorYellow + "[JOB 1] WARNING: slow" + colorReset + "\n"},
+		{"[JOB 1] Job completed successfully!\n", colorGreen + "[JOB 1] Job completed successfully!" + colorReset + "\n"},
+		{"[JOB 1] Success: Got video URL\n", colorGreen + "[JOB 1] Success: Got video URL" + colorReset + "\n"},
+		{"[JOB 1] ERROR: no newline", colorRed + "[JOB 1] ERROR: no newline" + colorReset},
+		{"[JOB 1] Downloading video stream...\n", "[JOB 1] Downloading video stream...\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		n, err := (&colorWriter{w: &buf}).Write([]byte(tt.line))
+		if err != nil || n != len(tt.line) {
+			t.Errorf("Write(%q) = %d, %v", tt.line, n, err)
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("Write(%q) wrote %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+// A log written to a file or pipe has no color codes.
+func TestLoggerNotColoredOutsideTerminal(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	c := parseJobFlags(t)
+	if c.useColor(out) {
+		t.Error("useColor of a file = true")
+	}
+	c.newLogger(out).Printf("[JOB %s] ERROR: %s", "1", "download failed")
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "ERROR: download failed") || strings.Contains(string(data), "\x1b[") {
+		t.Errorf("logged %q, want the line without ANSI codes", data)
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// NO_COLOR and -no-color turn off coloring for a terminal. /dev/null, a
+// character device, passes for one.
+func TestUseColorTerminal(t *testing.T) {
+	tty, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer tty.Close()
+
+	tests := []struct {
+		name    string
+		args    []string
+		noColor string
+		want    bool
+	}{
+		{"terminal", nil, "", true},
+		{"NO_COLOR", nil, "1", false},
+		{"-no-color", []string{"-no-color"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			if got := parseJobFlags(t, tt.args...).useColor(tty); got != tt.want {
+				t.Errorf("useColor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,747 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/adapters/apify"
+	"scrapeanddown/internal/adapters/channelstate"
+	"scrapeanddown/internal/adapters/checkpoint"
+	"scrapeanddown/internal/adapters/contentindex"
+	"scrapeanddown/internal/adapters/downloader"
+	"scrapeanddown/internal/adapters/localstorage"
+	"scrapeanddown/internal/adapters/multistorage"
+	"scrapeanddown/internal/adapters/oembed"
+	"scrapeanddown/internal/adapters/rapidapi"
+	"scrapeanddown/internal/adapters/s3storage"
+	"scrapeanddown/internal/adapters/shortlink"
+	"scrapeanddown/internal/adapters/ytdlp"
+	"scrapeanddown/internal/core/ports"
+	"scrapeanddown/internal/service"
+)
+
+// jobConfig holds the flags shared by every command that runs jobs.
+type jobConfig struct {
+	dataDir          *string
+	storageBackend   *string
+	writePolicy      *string
+	mirrorDirs       *string
+	mirrorBestEffort *bool
+	s3Bucket         *string
+	s3Prefix         *string
+	s3Region         *string
+	s3Endpoint       *string
+	s3KMSKey         *string
+	s3Partitions     *string
+	readableDirs     *bool
+	externalIDDirs   *bool
+	noMetadata       *bool
+	metadataFields   *string
+	noRawMetadata    *bool
+	noCache          *bool
+	scrapeCacheTTL   *time.Duration
+	metadataSource   *string
+	oembedFallback   *bool
+	resolver         *string
+	apifyConcurrency *int
+	apifyInterval    *time.Duration
+	apifyProxy       *string
+	proxyCountry     *string
+	apifyPollMin     *time.Duration
+	scrapeLimit      *int
+	downloadLimit    *int
+	startRamp        *time.Duration
+	jobTimeout       *time.Duration
+	scrapeTimeout    *time.Duration
+	downloadTimeout  *time.Duration
+	breakerThreshold *int
+	breakerCooldown  *time.Duration
+	apifyPollMax     *time.Duration
+	apifyPollFactor  *float64
+	readyTimeout     *time.Duration
+	withComments     *bool
+	maxComments      *int
+	tempDir          *string
+	qualities        *string
+	separateStreams  *bool
+	maxDuration      *time.Duration
+	minDuration      *time.Duration
+	minViews         *int64
+	maxSize          *string
+	checkFreeSpace   *bool
+	freeSpaceMargin  *string
+	ytdlpRetries     *int
+	ytdlpPath        *string
+	maxHeight        *int
+	maxFPS           *int
+	cookiesFile      *string
+	cookiesBrowser   *string
+	dedupContent     *bool
+	hashes           *string
+	retries          *int
+	resolveRetries   *int
+	writeManifest    *bool
+	tlsMinVersion    *string
+	pinCerts         *string
+	dialTimeout      *time.Duration
+	tlsTimeout       *time.Duration
+	headerTimeout    *time.Duration
+	readIdleTimeout  *time.Duration
+	anyContentType   *bool
+	nativeHLS        *bool
+	hlsConcurrency   *int
+	gracePeriod      *time.Duration
+	storyboards      *bool
+	transcript       *bool
+	tiktokMusic      *bool
+	allowDuplicates  *bool
+	savePage         *bool
+	openGraph        *bool
+	checkAvailable   *bool
+	skipList         *string
+	resultsFile      *string
+	summaryFile      *string
+	quiet            *bool
+	noColor          *bool
+	verbose          *bool
+	verboseShort     *bool
+	debug            *bool
+	logElapsed       *bool
+	maxJobs          *int
+
+	outputTemplate      *string
+	outputTemplateShort *string
+
+	outputs []*os.File // -results and -summary, opened by build
+}
+
+// registerJobFlags defines the job flags on fs.
+func registerJobFlags(fs *flag.FlagSet) *jobConfig {
+	return &jobConfig{
+		dataDir:          fs.String("data-dir", "./data", "Base directory for storing job data"),
+		readableDirs:     fs.Bool("readable-dirs", false, "Name job directories <platform>-<timestamp>-<short id> instead of the bare job ID"),
+		externalIDDirs:   fs.Bool("external-id-dirs", false, "Name job directories after their external ID, when one is given"),
+		storageBackend:   fs.String("storage", "local", "Storage backend for job artifacts: local, cas (local, videos stored once by content hash) or s3 (see -s3-bucket)"),
+		writePolicy:      fs.String("write-policy", "overwrite", "What saving over an existing video or metadata file does: overwrite, skip-existing or error-if-exists"),
+		mirrorDirs:       fs.String("mirror-dir", "", "Comma-separated extra data directories every job is also written to, with the same -storage backend"),
+		mirrorBestEffort: fs.Bool("mirror-best-effort", false, "Log -mirror-dir write failures instead of failing the job"),
+		s3Bucket:         fs.String("s3-bucket", "", "S3 bucket for -storage s3; credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN"),
+		s3Prefix:         fs.String("s3-prefix", "", "Key prefix for everything -storage s3 writes (e.g. scrapes/)"),
+		s3Region:         fs.String("s3-region", firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"), "Region of -s3-bucket (default: $AWS_REGION)"),
+		s3Endpoint:       fs.String("s3-endpoint", "", "URL of an S3-compatible service (e.g. MinIO) to use instead of AWS"),
+		s3KMSKey:         fs.String("s3-sse-kms-key", "", "Encrypt uploaded objects with SSE-KMS under this KMS key ID or ARN"),
+		s3Partitions:     fs.String("s3-date-partitions", "", "Partition job keys by start date: daily (jobs/2006/01/02/<id>/), monthly or hive (jobs/year=2006/month=01/day=02/<id>/)"),
+		noMetadata:       fs.Bool("no-metadata", false, "Skip the metadata scrape for yt-dlp platforms (e.g. YouTube)"),
+		metadataFields:   fs.String("metadata-fields", "", "Comma-separated JSON paths to save as metadata.json (e.g. title,channelName,viewCount)"),
+		noRawMetadata:    fs.Bool("no-raw-metadata", false, "Don't save the full metadata_raw.json"),
+		noCache:          fs.Bool("no-cache", false, "Always scrape metadata, bypassing the scrape cache"),
+		scrapeCacheTTL:   fs.Duration("scrape-cache-ttl", time.Hour, "How long cached scrape results are reused (0 = forever)"),
+		metadataSource:   fs.String("metadata-source", "apify", "Metadata source: apify or oembed (free, title/author only)"),
+		oembedFallback:   fs.Bool("oembed-fallback", true, "With -metadata-source apify, scrape platforms without an Apify actor (e.g. Pinterest) via oEmbed"),
+		resolver:         fs.String("resolver", "ytdlp", "YouTube download URL resolver: ytdlp or rapidapi (needs RAPIDAPI_KEY)"),
+		apifyConcurrency: fs.Int("apify-concurrency", 0, "Maximum concurrent Apify actor runs (0 = unlimited)"),
+		apifyInterval:    fs.Duration("apify-interval", 0, "Minimum spacing between Apify run starts (e.g. 500ms)"),
+		apifyProxy:       fs.String("apify-proxy", "", "Run Apify actors through Apify Proxy: \"auto\" or comma-separated proxy groups (e.g. RESIDENTIAL)"),
+		proxyCountry:     fs.String("apify-proxy-country", "", "Apify Proxy exit country code (e.g. US, with -apify-proxy)"),
+		apifyPollMin:     fs.Duration("apify-poll-min", time.Second, "Initial Apify run status polling interval"),
+		apifyPollMax:     fs.Duration("apify-poll-max", 15*time.Second, "Maximum Apify run status polling interval"),
+		apifyPollFactor:  fs.Float64("apify-poll-factor", 1.5, "Factor the Apify polling interval grows by while the run status is unchanged"),
+		readyTimeout:     fs.Duration("apify-ready-timeout", 0, "Abort Apify runs still READY (actor building or cold-starting) after this long (0 = wait indefinitely)"),
+		breakerThreshold: fs.Int("apify-breaker-threshold", 0, "Consecutive Apify failures before failing fast for -apify-breaker-cooldown (0 = never)"),
+		breakerCooldown:  fs.Duration("apify-breaker-cooldown", time.Minute, "How long Apify calls fail fast once the breaker opens"),
+		scrapeLimit:      fs.Int("scrape-concurrency", 0, "Maximum jobs scraping metadata at once (0 = one per worker)"),
+		downloadLimit:    fs.Int("download-concurrency", 0, "Maximum jobs downloading video at once (0 = one per worker)"),
+		startRamp:        fs.Duration("ramp", 0, "Delay each batch worker's first job by a random time up to this (e.g. 2s), spreading the initial burst"),
+		jobTimeout:       fs.Duration("job-timeout", 0, "Fail a job attempt that runs longer than this (0 = no limit)"),
+		scrapeTimeout:    fs.Duration("scrape-timeout", 0, "Fail a metadata scrape that runs longer than this (0 = no limit)"),
+		downloadTimeout:  fs.Duration("download-timeout", 0, "Fail a video download that runs longer than this (0 = no limit)"),
+		withComments:     fs.Bool("comments", false, "Scrape top comments and save them to comments.json"),
+		maxComments:      fs.Int("max-comments", 100, "Maximum number of comments to scrape (with -comments)"),
+		tempDir:          fs.String("temp-dir", "", "Root directory for per-job scratch files (default: system temp dir)"),
+		qualities:        fs.String("qualities", "", "Comma-separated renditions to download via yt-dlp (e.g. 1080p,360p)"),
+		separateStreams:  fs.Bool("separate-streams", false, "Download the best video-only and audio-only streams unmerged, as video_only.mp4 and audio_only.m4a"),
+		maxDuration:      fs.Duration("max-duration", 0, "Skip videos longer than this (e.g. 10m); 0 = no limit"),
+		minDuration:      fs.Duration("min-duration", 0, "Skip videos shorter than this (e.g. 30s); 0 = no limit"),
+		minViews:         fs.Int64("min-views", 0, "Skip videos with fewer views than this; 0 = no limit"),
+		maxSize:          fs.String("max-size", "", "Skip videos larger than this (e.g. 500MB); empty = no limit"),
+		checkFreeSpace:   fs.Bool("check-free-space", false, "Fail downloads that won't fit in -data-dir with -free-space-margin to spare"),
+		freeSpaceMargin:  fs.String("free-space-margin", "500MB", "Free space to keep in -data-dir beyond the video's estimated size, with -check-free-space (0 = only the estimate)"),
+		maxHeight:        fs.Int("max-height", 0, "Download the best format no taller than this, e.g. 1080 (0 = no cap)"),
+		maxFPS:           fs.Int("max-fps", 0, "Download the best format at no more than this frame rate, e.g. 30 (0 = no cap)"),
+		ytdlpRetries:     fs.Int("ytdlp-retries", 2, "Times to retry transient yt-dlp failures"),
+		ytdlpPath:        fs.String("ytdlp-path", "", "Path to the yt-dlp binary (default: ./yt-dlp if present, else yt-dlp on PATH)"),
+		cookiesFile:      fs.String("cookies", "", "Netscape-format cookies file for yt-dlp"),
+		cookiesBrowser:   fs.String("cookies-from-browser", "", "Let yt-dlp read cookies from a browser: BROWSER[+KEYRING][:PROFILE] (e.g. chrome)"),
+		dedupContent:     fs.Bool("dedup-content", false, "Replace videos identical to an earlier job's with a reference"),
+		hashes:           fs.String("hashes", "", "Comma-separated extra video digests to record, computed during the download: md5, sha1, sha256, sha512"),
+		retries:          fs.Int("retries", 0, "Times to retry the whole job on retryable failures"),
+		resolveRetries:   fs.Int("resolve-retries", 2, "Times to re-resolve an expired download URL before failing"),
+		writeManifest:    fs.Bool("manifest", false, "Write manifest.json listing all job artifacts"),
+		tlsMinVersion:    fs.String("tls-min-version", "", "Minimum TLS version for video downloads: 1.2 or 1.3"),
+		pinCerts:         fs.String("pin-cert", "", "Comma-separated SHA-256 fingerprints of accepted download server certificates"),
+		dialTimeout:      fs.Duration("dial-timeout", downloader.DefaultDialTimeout, "Timeout for connecting to the video server"),
+		tlsTimeout:       fs.Duration("tls-handshake-timeout", downloader.DefaultTLSHandshakeTimeout, "Timeout for the video server's TLS handshake"),
+		headerTimeout:    fs.Duration("response-header-timeout", downloader.DefaultResponseHeaderTimeout, "Timeout for the video server's response headers"),
+		readIdleTimeout:  fs.Duration("read-idle-timeout", downloader.DefaultReadIdleTimeout, "Fail a download when no data arrives for this long (0 = never)"),
+		anyContentType:   fs.Bool("allow-any-content-type", false, "Save downloads the server labels as HTML, JSON or XML instead of rejecting them as error pages"),
+		nativeHLS:        fs.Bool("native-hls", false, "Download HLS (.m3u8) video URLs natively, joining the segments into one file (downloads can't be resumed)"),
+		hlsConcurrency:   fs.Int("hls-concurrency", downloader.DefaultSegmentConcurrency, "HLS segments fetched at once (with -native-hls)"),
+		storyboards:      fs.Bool("storyboards", false, "Download storyboard sprite sheets (scrubbing previews) to storyboards/"),
+		transcript:       fs.Bool("transcript", false, "Save the subtitles (or automatic captions) and a plain-text transcript.txt"),
+		tiktokMusic:      fs.Bool("tiktok-music", false, "Also download a TikTok post's background music track as music.mp3"),
+		resultsFile:      fs.String("results", "", "Append a JSON line per finished batch job to this file (watch and sync)"),
+		summaryFile:      fs.String("summary", "", "Append a JSON line with each batch's summary to this file (watch and sync)"),
+		savePage:         fs.Bool("save-page", false, "Save the video page's raw HTML as page.html"),
+		openGraph:        fs.Bool("opengraph", false, "Fill gaps in the normalized metadata from the video page's OpenGraph tags"),
+		checkAvailable:   fs.Bool("preflight", false, "Check each video exists (yt-dlp or oEmbed) before scraping it, failing dead URLs fast"),
+		skipList:         fs.String("skip-list", "", "File of video IDs or URLs never to download, one per line (# comments, trailing * for prefixes)"),
+		allowDuplicates:  fs.Bool("allow-duplicates", false, "Run duplicate URLs in a batch separately instead of once"),
+		quiet:            fs.Bool("quiet", false, "Only log errors; the job summary is still printed"),
+		noColor:          fs.Bool("no-color", false, "Don't color the log (also set by NO_COLOR; never colored unless a terminal)"),
+		verbose:          fs.Bool("verbose", false, "Also log step timings and download URL resolution"),
+		verboseShort:     fs.Bool("v", false, "Shorthand for -verbose"),
+		debug:            fs.Bool("debug", false, "Like -verbose, plus every yt-dlp invocation and Apify request"),
+		logElapsed:       fs.Bool("log-elapsed", false, "Prefix each job log line with the time since the job started, e.g. [+3.2s]"),
+		maxJobs:          fs.Int("max-jobs", 0, "Keep at most this many jobs in -data-dir, removing the oldest finished ones (0 = no limit)"),
+		gracePeriod:      fs.Duration("grace-period", 5*time.Minute, "On interrupt, how long to let in-flight jobs finish before cancelling (0 = cancel immediately)"),
+
+		outputTemplate:      fs.String("output-template", "", "Name the video after its metadata, yt-dlp style (e.g. \"%(uploader)s/%(title)s.%(ext)s\")"),
+		outputTemplateShort: fs.String("o", "", "Shorthand for -output-template"),
+	}
+}
+
+// attempts returns the total number of tries per job.
+func (c *jobConfig) attempts() int {
+	return *c.retries + 1
+}
+
+// logLevel returns the log level selected by -quiet, -verbose/-v and -debug.
+func (c *jobConfig) logLevel() (service.LogLevel, error) {
+	verbose := *c.verbose || *c.verboseShort
+	switch {
+	case *c.quiet && (verbose || *c.debug):
+		return 0, fmt.Errorf("-quiet can't be combined with -verbose or -debug")
+	case *c.quiet:
+		return service.LogQuiet, nil
+	case *c.debug:
+		return service.LogDebug, nil
+	case verbose:
+		return service.LogVerbose, nil
+	}
+	return service.LogNormal, nil
+}
+
+// parseOutputTemplate parses -output-template (or -o), if given.
+func (c *jobConfig) parseOutputTemplate() (*service.OutputTemplate, error) {
+	tmpl := *c.outputTemplate
+	if *c.outputTemplateShort != "" {
+		if tmpl != "" {
+			return nil, fmt.Errorf("-o and -output-template can't both be given")
+		}
+		tmpl = *c.outputTemplateShort
+	}
+	if tmpl == "" {
+		return nil, nil
+	}
+	parsed, err := service.ParseOutputTemplate(tmpl, localstorage.SanitizeFilename)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -output-template: %w", err)
+	}
+	return parsed, nil
+}
+
+// debugLog returns a logf for the adapters' debug output.
+func debugLog(logger *log.Logger) func(format string, args ...interface{}) {
+	return func(format string, args ...interface{}) {
+		logger.Printf("DEBUG: "+format, args...)
+	}
+}
+
+// ytdlpOptions returns the yt-dlp options selected by the flags.
+func (c *jobConfig) ytdlpOptions(logger *log.Logger) ([]ytdlp.Option, error) {
+	opts := []ytdlp.Option{ytdlp.WithRetries(*c.ytdlpRetries)}
+	if *c.debug {
+		opts = append(opts, ytdlp.WithDebugLog(debugLog(logger)))
+	}
+	if *c.maxHeight != 0 {
+		opts = append(opts, ytdlp.WithMaxHeight(*c.maxHeight))
+	}
+	if *c.maxFPS != 0 {
+		opts = append(opts, ytdlp.WithMaxFPS(*c.maxFPS))
+	}
+	if *c.cookiesFile != "" && *c.cookiesBrowser != "" {
+		return nil, fmt.Errorf("-cookies and -cookies-from-browser are mutually exclusive")
+	}
+	if *c.cookiesFile != "" {
+		opts = append(opts, ytdlp.WithCookiesFile(*c.cookiesFile))
+	}
+	if *c.cookiesBrowser != "" {
+		if err := ytdlp.ValidateBrowser(*c.cookiesBrowser); err != nil {
+			return nil, fmt.Errorf("invalid -cookies-from-browser: %w", err)
+		}
+		opts = append(opts, ytdlp.WithCookiesFromBrowser(*c.cookiesBrowser))
+	}
+	return opts, nil
+}
+
+// newYtDlp creates the yt-dlp adapter selected by the flags. A missing
+// -ytdlp-path is an error; a missing default binary is only a warning, as
+// jobs that don't resolve through yt-dlp still work.
+func (c *jobConfig) newYtDlp(logger *log.Logger) (*ytdlp.YtDlpDownloader, error) {
+	opts, err := c.ytdlpOptions(logger)
+	if err != nil {
+		return nil, err
+	}
+	if *c.ytdlpPath != "" {
+		d := ytdlp.NewYtDlpDownloaderWithPath(*c.ytdlpPath, opts...)
+		if err := d.CheckBinary(); err != nil {
+			return nil, fmt.Errorf("invalid -ytdlp-path: %w", err)
+		}
+		return d, nil
+	}
+	d := ytdlp.NewYtDlpDownloader(opts...)
+	if err := d.CheckBinary(); err != nil {
+		logger.Printf("WARNING: %s", describeError(err))
+	}
+	return d, nil
+}
+
+// build wires the adapters and orchestrator from the flags.
+func (c *jobConfig) build(logger *log.Logger) (*service.Orchestrator, ports.Storage, error) {
+	logLevel, err := c.logLevel()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.validateStorage(); err != nil {
+		return nil, nil, err
+	}
+	maxSizeBytes, err := parseSize(*c.maxSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid -max-size: %w", err)
+	}
+	freeSpaceMargin, err := parseSize(*c.freeSpaceMargin)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid -free-space-margin: %w", err)
+	}
+	if *c.separateStreams && *c.qualities != "" {
+		return nil, nil, fmt.Errorf("-separate-streams and -qualities are mutually exclusive")
+	}
+	if *c.maxHeight != 0 {
+		if err := ytdlp.ValidateMaxHeight(*c.maxHeight); err != nil {
+			return nil, nil, fmt.Errorf("invalid -max-height: %w", err)
+		}
+	}
+	if *c.maxFPS < 0 {
+		return nil, nil, fmt.Errorf("invalid -max-fps: must not be negative")
+	}
+	outputTemplate, err := c.parseOutputTemplate()
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes, err := service.ParseHashAlgorithms(splitList(*c.hashes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid -hashes: %w", err)
+	}
+	var skipList *service.SkipList
+	if *c.skipList != "" {
+		if skipList, err = service.LoadSkipList(*c.skipList); err != nil {
+			return nil, nil, fmt.Errorf("invalid -skip-list: %w", err)
+		}
+	}
+
+	// Initialize adapters
+	var scraper ports.Scraper
+	switch *c.metadataSource {
+	case "apify":
+		scraperOpts := []apify.Option{apify.WithPolling(apify.PollConfig{
+			Min:    *c.apifyPollMin,
+			Max:    *c.apifyPollMax,
+			Factor: *c.apifyPollFactor,
+		})}
+		if *c.readyTimeout > 0 {
+			scraperOpts = append(scraperOpts, apify.WithReadyTimeout(*c.readyTimeout))
+		}
+		if !*c.quiet {
+			scraperOpts = append(scraperOpts, apify.WithStatusLog(logger.Printf))
+		}
+		if *c.apifyConcurrency > 0 || *c.apifyInterval > 0 {
+			scraperOpts = append(scraperOpts, apify.WithLimiter(apify.NewLimiter(*c.apifyConcurrency, *c.apifyInterval)))
+		}
+		if *c.breakerThreshold > 0 {
+			scraperOpts = append(scraperOpts, apify.WithCircuitBreaker(apify.NewCircuitBreaker(*c.breakerThreshold, *c.breakerCooldown)))
+		}
+		if *c.maxHeight != 0 {
+			scraperOpts = append(scraperOpts, apify.WithMaxHeight(*c.maxHeight))
+		}
+		if *c.maxFPS != 0 {
+			scraperOpts = append(scraperOpts, apify.WithMaxFPS(*c.maxFPS))
+		}
+		if *c.debug {
+			scraperOpts = append(scraperOpts, apify.WithDebugLog(debugLog(logger)))
+		}
+		if *c.oembedFallback {
+			scraperOpts = append(scraperOpts, apify.WithFallback(oembed.NewOEmbedScraper()))
+		}
+		if *c.withComments {
+			scraperOpts = append(scraperOpts, apify.WithComments(*c.maxComments))
+		}
+		if *c.apifyProxy != "" {
+			proxy := apify.ProxyConfig{UseApifyProxy: true, Country: *c.proxyCountry}
+			if *c.apifyProxy != "auto" {
+				proxy.Groups = splitList(*c.apifyProxy)
+			}
+			scraperOpts = append(scraperOpts, apify.WithProxy(proxy))
+		}
+		apifyScraper, err := apify.NewApifyScraper(scraperOpts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize scraper: %w", err)
+		}
+		scraper = apifyScraper
+	case "oembed":
+		scraper = oembed.NewOEmbedScraper()
+	default:
+		return nil, nil, fmt.Errorf("unknown metadata source: %s", *c.metadataSource)
+	}
+	if !*c.noCache {
+		// The caps pick the video URL Apify returns, and -comments what it scrapes
+		settings := []string{
+			fmt.Sprintf("max-height=%d", *c.maxHeight),
+			fmt.Sprintf("max-fps=%d", *c.maxFPS),
+		}
+		if *c.withComments {
+			settings = append(settings, fmt.Sprintf("comments=%d", *c.maxComments))
+		}
+		scraper = service.NewCachingScraper(scraper, filepath.Join(*c.dataDir, "scrape_cache"), *c.scrapeCacheTTL, settings...)
+	}
+
+	var resolver ports.URLResolver
+	switch *c.resolver {
+	case "ytdlp":
+		ytdlpResolver, err := c.newYtDlp(logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		resolver = ytdlpResolver
+	case "rapidapi":
+		rapidResolver, err := rapidapi.NewRapidAPIResolver()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize resolver: %w", err)
+		}
+		resolver = rapidResolver
+	default:
+		return nil, nil, fmt.Errorf("unknown resolver: %s", *c.resolver)
+	}
+
+	dlOpts := []downloader.Option{
+		downloader.WithDialTimeout(*c.dialTimeout),
+		downloader.WithTLSHandshakeTimeout(*c.tlsTimeout),
+		downloader.WithResponseHeaderTimeout(*c.headerTimeout),
+		downloader.WithReadIdleTimeout(*c.readIdleTimeout),
+	}
+	if *c.tlsMinVersion != "" {
+		version, err := downloader.ParseTLSVersion(*c.tlsMinVersion)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid -tls-min-version: %w", err)
+		}
+		dlOpts = append(dlOpts, downloader.WithMinTLSVersion(version))
+	}
+	if pins := splitList(*c.pinCerts); len(pins) > 0 {
+		dlOpts = append(dlOpts, downloader.WithPinnedCertificates(pins...))
+	}
+	if *c.anyContentType {
+		dlOpts = append(dlOpts, downloader.WithAnyContentType())
+	}
+	var dl ports.Downloader
+	if *c.nativeHLS {
+		dl = downloader.NewHLSDownloader(append(dlOpts, downloader.WithSegmentConcurrency(*c.hlsConcurrency))...)
+	} else {
+		dl = downloader.NewHTTPDownloader(dlOpts...)
+	}
+	writePolicy, err := localstorage.ParseWritePolicy(*c.writePolicy)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid -write-policy: %w", err)
+	}
+	storage, err := c.newStorage(*c.dataDir, writePolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+	if dirs := splitList(*c.mirrorDirs); len(dirs) > 0 {
+		var mirrors []ports.Storage
+		for _, dir := range dirs {
+			mirror, err := c.newStorage(dir, writePolicy)
+			if err != nil {
+				return nil, nil, err
+			}
+			mirrors = append(mirrors, mirror)
+		}
+		var opts []multistorage.Option
+		if *c.mirrorBestEffort {
+			opts = append(opts, multistorage.WithBestEffort(func(err error) {
+				logger.Printf("WARNING: %v", err)
+			}))
+		}
+		storage = multistorage.NewMultiStorage(storage, mirrors, opts...)
+	}
+	if *c.maxJobs < 0 {
+		return nil, nil, fmt.Errorf("invalid -max-jobs: must not be negative")
+	}
+	if _, ok := storage.(jobPruner); *c.maxJobs > 0 && !ok {
+		if *c.mirrorDirs != "" {
+			return nil, nil, fmt.Errorf("-max-jobs can't be combined with -mirror-dir")
+		}
+		return nil, nil, fmt.Errorf("-storage %s does not support -max-jobs", *c.storageBackend)
+	}
+
+	var contentIndex ports.ContentIndex
+	if *c.dedupContent {
+		contentIndex = contentindex.NewJSONIndex(filepath.Join(*c.dataDir, "content_index.json"))
+	}
+
+	channelState := channelstate.NewJSONState(filepath.Join(*c.dataDir, "channel_state.json"))
+
+	var results io.Writer
+	if *c.resultsFile != "" {
+		f, err := os.OpenFile(*c.resultsFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open -results file: %w", err)
+		}
+		c.outputs = append(c.outputs, f)
+		results = f
+	}
+	var summary io.Writer
+	if *c.summaryFile != "" {
+		f, err := os.OpenFile(*c.summaryFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open -summary file: %w", err)
+		}
+		c.outputs = append(c.outputs, f)
+		summary = f
+	}
+
+	// Create orchestrator
+	orchestrator := service.NewOrchestrator(scraper, dl, storage, resolver, logger, service.Options{
+		WriteManifest:          *c.writeManifest,
+		MaxResolveRetries:      *c.resolveRetries,
+		TempDir:                *c.tempDir,
+		SkipMetadata:           *c.noMetadata,
+		Qualities:              splitList(*c.qualities),
+		SeparateStreams:        *c.separateStreams,
+		MaxDuration:            *c.maxDuration,
+		MinDuration:            *c.minDuration,
+		MinViews:               *c.minViews,
+		MaxSizeBytes:           maxSizeBytes,
+		CheckFreeSpace:         *c.checkFreeSpace,
+		FreeSpaceMargin:        freeSpaceMargin,
+		ContentIndex:           contentIndex,
+		Hashes:                 hashes,
+		ChannelState:           channelState,
+		Checkpoint:             checkpoint.NewJSONCheckpoint(filepath.Join(*c.dataDir, "batch_checkpoint.json")),
+		LinkExpander:           shortlink.NewResolver(),
+		MetadataFields:         splitList(*c.metadataFields),
+		SkipRawMetadata:        *c.noRawMetadata,
+		Storyboards:            *c.storyboards,
+		Transcript:             *c.transcript,
+		TikTokMusic:            *c.tiktokMusic,
+		AllowDuplicateURLs:     *c.allowDuplicates,
+		SavePageHTML:           *c.savePage,
+		OpenGraph:              *c.openGraph,
+		CheckAvailability:      *c.checkAvailable,
+		SkipList:               skipList,
+		AvailabilityChecker:    oembed.NewOEmbedScraper(),
+		Results:                results,
+		Summary:                summary,
+		MaxConcurrentScrapes:   *c.scrapeLimit,
+		MaxConcurrentDownloads: *c.downloadLimit,
+		StartRamp:              *c.startRamp,
+		JobTimeout:             *c.jobTimeout,
+		ScrapeTimeout:          *c.scrapeTimeout,
+		DownloadTimeout:        *c.downloadTimeout,
+		LogLevel:               logLevel,
+		LogElapsed:             *c.logElapsed,
+		OutputTemplate:         outputTemplate,
+	})
+	return orchestrator, storage, nil
+}
+
+// closeOutputs closes the files opened by build, reporting a failed final
+// write (e.g. a full disk) that would otherwise go unnoticed.
+func (c *jobConfig) closeOutputs() error {
+	var errs []error
+	for _, f := range c.outputs {
+		if err := f.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write %s: %w", f.Name(), err))
+		}
+	}
+	c.outputs = nil
+	return errors.Join(errs...)
+}
+
+// newStorage constructs the storage backend selected with -storage, in
+// dataDir for the local backends.
+func (c *jobConfig) newStorage(dataDir string, writePolicy localstorage.WritePolicy) (ports.Storage, error) {
+	opts := []localstorage.Option{localstorage.WithWritePolicy(writePolicy)}
+	if *c.readableDirs {
+		opts = append(opts, localstorage.WithReadableDirs())
+	}
+	if *c.externalIDDirs {
+		opts = append(opts, localstorage.WithExternalIDDirs())
+	}
+
+	switch backend := *c.storageBackend; backend {
+	case "", "local":
+		return localstorage.NewLocalStorage(dataDir, opts...), nil
+	case "cas":
+		return localstorage.NewCASStorage(localstorage.NewLocalStorage(dataDir, opts...)), nil
+	case "s3":
+		return c.newS3Storage()
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// validateStorage checks -storage and the flags that depend on it, so an
+// unsupported setup fails before anything is built.
+func (c *jobConfig) validateStorage() error {
+	switch backend := *c.storageBackend; backend {
+	case "", "local", "cas":
+		if *c.s3Bucket != "" || *c.s3Prefix != "" || *c.s3Endpoint != "" || *c.s3KMSKey != "" || *c.s3Partitions != "" {
+			return fmt.Errorf("the -s3-* flags need -storage s3")
+		}
+		return nil
+	case "s3":
+		if *c.mirrorDirs != "" {
+			return fmt.Errorf("-storage s3 can't be combined with -mirror-dir")
+		}
+		if *c.readableDirs || *c.externalIDDirs {
+			return fmt.Errorf("-storage s3 can't be combined with -readable-dirs or -external-id-dirs")
+		}
+		if *c.writePolicy != "overwrite" {
+			return fmt.Errorf("-storage s3 can't be combined with -write-policy %s", *c.writePolicy)
+		}
+		return nil
+	case "gcs", "webdav":
+		return fmt.Errorf("-storage %s is not supported: use local, cas or s3", backend)
+	default:
+		return fmt.Errorf("invalid -storage %q: expected local, cas or s3", backend)
+	}
+}
+
+// s3Partitions maps -s3-date-partitions values to partition layouts.
+var s3Partitions = map[string]string{
+	"daily":   s3storage.DailyPartitions,
+	"monthly": s3storage.MonthlyPartitions,
+	"hive":    s3storage.HivePartitions,
+}
+
+// newS3Storage constructs the -storage s3 backend from the -s3-* flags and
+// the AWS credential variables.
+func (c *jobConfig) newS3Storage() (*s3storage.S3Storage, error) {
+	creds := s3storage.Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if *c.s3Bucket == "" {
+		return nil, fmt.Errorf("-storage s3 needs -s3-bucket")
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("-storage s3 needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	opts := []s3storage.Option{s3storage.WithPrefix(*c.s3Prefix)}
+	region := *c.s3Region
+	if *c.s3Endpoint != "" {
+		endpoint, err := url.Parse(*c.s3Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -s3-endpoint: %w", err)
+		}
+		opts = append(opts, s3storage.WithEndpoint(endpoint))
+		// S3-compatible services mostly ignore the region, but it is signed
+		if region == "" {
+			region = "us-east-1"
+		}
+	}
+	if region == "" {
+		return nil, fmt.Errorf("-storage s3 needs -s3-region or AWS_REGION")
+	}
+	if *c.s3KMSKey != "" {
+		opts = append(opts, s3storage.WithSSEKMS(*c.s3KMSKey))
+	}
+	if *c.s3Partitions != "" {
+		layout, ok := s3Partitions[*c.s3Partitions]
+		if !ok {
+			return nil, fmt.Errorf("invalid -s3-date-partitions %q: expected daily, monthly or hive", *c.s3Partitions)
+		}
+		opts = append(opts, s3storage.WithDatePartitions(layout))
+	}
+	return s3storage.NewS3Storage(*c.s3Bucket, region, creds, opts...)
+}
+
+// firstEnv returns the first of the environment variables that is set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseSize parses sizes like "500MB", "1.5GB" or a plain byte count
+// (decimal units). An empty string yields 0.
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return 0, nil
+	}
+	multiplier := 1.0
+	for _, unit := range []struct {
+		suffix string
+		mult   float64
+	}{{"TB", 1e12}, {"GB", 1e9}, {"MB", 1e6}, {"KB", 1e3}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * multiplier), nil
+}
+
+// formatBytes renders a byte count using decimal units (e.g. "124 MB").
+func formatBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	i := 0
+	for n >= 1000 && i < len(units)-1 {
+		n /= 1000
+		i++
+	}
+	if i == 0 || n >= 100 {
+		return fmt.Sprintf("%.0f %s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[i])
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"scrapeanddown/internal/adapters/localstorage"
+	"scrapeanddown/internal/adapters/s3storage"
+)
+
+// parseJobFlags parses args as job flags.
+func parseJobFlags(t *testing.T, args ...string) *jobConfig {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	c := registerJobFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestValidateStorage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"default", nil, ""},
+		{"local", []string{"-storage", "local"}, ""},
+		{"cas", []string{"-storage", "cas", "-mirror-dir", "/mnt/nas", "-readable-dirs"}, ""},
+		{"s3", []string{"-storage", "s3", "-s3-bucket", "videos", "-s3-date-partitions", "daily"}, ""},
+		{"gcs", []string{"-storage", "gcs"}, "-storage gcs is not supported"},
+		{"webdav", []string{"-storage", "webdav"}, "-storage webdav is not supported"},
+		{"unknown", []string{"-storage", "ftp"}, `invalid -storage "ftp"`},
+		{"s3 flags without s3", []string{"-s3-bucket", "videos"}, "need -storage s3"},
+		{"s3 with mirror-dir", []string{"-storage", "s3", "-mirror-dir", "/mnt/nas"}, "can't be combined with -mirror-dir"},
+		{"s3 with readable-dirs", []string{"-storage", "s3", "-readable-dirs"}, "can't be combined with -readable-dirs"},
+		{"s3 with external-id-dirs", []string{"-storage", "s3", "-external-id-dirs"}, "can't be combined with -readable-dirs or -external-id-dirs"},
+		{"s3 with write-policy", []string{"-storage", "s3", "-write-policy", "skip-existing"}, "can't be combined with -write-policy skip-existing"},
+		{"s3 with default write-policy", []string{"-storage", "s3", "-write-policy", "overwrite"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseJobFlags(t, tt.args...).validateStorage()
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("validateStorage: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("validateStorage err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestBuildRejectsUnsupportedStorage(t *testing.T) {
+	c := parseJobFlags(t, "-storage", "gcs", "-data-dir", t.TempDir())
+	if _, _, err := c.build(log.New(io.Discard, "", 0)); err == nil || !strings.Contains(err.Error(), "-storage gcs is not supported") {
+		t.Errorf("build err = %v, want the unsupported backend error", err)
+	}
+}
+
+func TestNewStorage(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "*localstorage.LocalStorage"},
+		{[]string{"-storage", "local", "-readable-dirs"}, "*localstorage.LocalStorage"},
+		{[]string{"-storage", "cas"}, "*localstorage.CASStorage"},
+		{[]string{"-storage", "s3", "-s3-bucket", "videos", "-s3-region", "eu-west-1"}, "*s3storage.S3Storage"},
+	}
+	for _, tt := range tests {
+		storage, err := parseJobFlags(t, tt.args...).newStorage(t.TempDir(), localstorage.Overwrite)
+		if err != nil {
+			t.Errorf("newStorage(%v): %v", tt.args, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", storage); got != tt.want {
+			t.Errorf("newStorage(%v) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestLocalStorageDataDir(t *testing.T) {
+	dataDir := t.TempDir()
+	storage, err := parseJobFlags(t).newStorage(dataDir, localstorage.Overwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := storage.GetJobPath("job1"), filepath.Join(dataDir, "jobs", "job1"); got != want {
+		t.Errorf("GetJobPath = %s, want %s", got, want)
+	}
+}
+
+func TestNewS3Storage(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		noCreds bool
+		err     string
+	}{
+		{"bucket", []string{"-s3-bucket", "videos", "-s3-region", "eu-west-1"}, false, ""},
+		{"prefix", []string{"-s3-bucket", "videos", "-s3-region", "eu-west-1", "-s3-prefix", "scrapes"}, false, ""},
+		{"endpoint without region", []string{"-s3-bucket", "videos", "-s3-region", "", "-s3-endpoint", "http://localhost:9000"}, false, ""},
+		{"sse-kms and partitions", []string{"-s3-bucket", "videos", "-s3-region", "eu-west-1", "-s3-sse-kms-key", "key", "-s3-date-partitions", "daily"}, false, ""},
+		{"no bucket", []string{"-s3-region", "eu-west-1"}, false, "needs -s3-bucket"},
+		{"no credentials", []string{"-s3-bucket", "videos", "-s3-region", "eu-west-1"}, true, "needs AWS_ACCESS_KEY_ID"},
+		{"no region", []string{"-s3-bucket", "videos", "-s3-region", ""}, false, "needs -s3-region"},
+		{"bad partitions", []string{"-s3-bucket", "videos", "-s3-region", "eu-west-1", "-s3-date-partitions", "weekly"}, false, "invalid -s3-date-partitions"},
+		{"bad endpoint", []string{"-s3-bucket", "videos", "-s3-region", "eu-west-1", "-s3-endpoint", "minio:9000"}, false, "invalid S3 endpoint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.noCreds {
+				t.Setenv("AWS_ACCESS_KEY_ID", "")
+			} else {
+				t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+			}
+			t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+			c := parseJobFlags(t, append([]string{"-storage", "s3"}, tt.args...)...)
+
+			storage, err := c.newStorage(t.TempDir(), localstorage.Overwrite)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("newStorage err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := storage.(*s3storage.S3Storage); !ok {
+				t.Fatalf("newStorage = %T, want *s3storage.S3Storage", storage)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    float64
+		want string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1500, "1.5 KB"},
+		{14_900_000, "14.9 MB"},
+		{124_000_000, "124 MB"},
+		{2_500_000_000_000_000, "2500 TB"},
+	}
+	for _, tt := range tests {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%v) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestYtdlpOptions(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{nil, ""},
+		{[]string{"-cookies", "cookies.txt"}, ""},
+		{[]string{"-cookies-from-browser", "chrome:Default"}, ""},
+		{[]string{"-cookies", "cookies.txt", "-cookies-from-browser", "chrome"}, "mutually exclusive"},
+		{[]string{"-cookies-from-browser", "lynx"}, "invalid -cookies-from-browser"},
+	}
+	for _, tt := range tests {
+		_, err := parseJobFlags(t, tt.args...).ytdlpOptions(log.New(io.Discard, "", 0))
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("ytdlpOptions(%v): %v", tt.args, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ytdlpOptions(%v) err = %v, want %q", tt.args, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/adapters/localstorage"
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/service"
+)
+
+// exportColumns are the fields of an export row, in CSV column order.
+var exportColumns = []string{
+	"job_id", "external_id", "url", "platform", "created_at", "status", "metadata",
+	"title", "author", "description", "duration_seconds", "view_count", "like_count",
+	"published_at", "thumbnail_url", "tags",
+}
+
+// exportRow is one job of an export. Metadata is "normalized" when read
+// from metadata_normalized.json, "raw" when normalized from
+// metadata_raw.json, and "missing" (with blank fields) otherwise.
+type exportRow struct {
+	JobID           string   `json:"job_id"`
+	ExternalID      string   `json:"external_id,omitempty"`
+	URL             string   `json:"url"`
+	Platform        string   `json:"platform"`
+	CreatedAt       string   `json:"created_at"`
+	Status          string   `json:"status"`
+	Metadata        string   `json:"metadata"`
+	Title           string   `json:"title,omitempty"`
+	Author          string   `json:"author,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	DurationSeconds float64  `json:"duration_seconds,omitempty"`
+	ViewCount       int64    `json:"view_count,omitempty"`
+	LikeCount       int64    `json:"like_count,omitempty"`
+	PublishedAt     string   `json:"published_at,omitempty"`
+	ThumbnailURL    string   `json:"thumbnail_url,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+}
+
+// runExport implements "scraper-cli export": it writes one row per stored
+// job, with its normalized metadata, as CSV or NDJSON.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "csv", "Export format: csv or ndjson")
+	out := fs.String("out", "", "File to write the export to (default: stdout)")
+	dataDir := fs.String("data-dir", "./data", "Base directory of the job data to export")
+	fs.Parse(args)
+
+	if *format != "csv" && *format != "ndjson" {
+		fmt.Println("Usage: scraper-cli export [-format csv|ndjson] [-out <file>] [-data-dir <path>]")
+		os.Exit(1)
+	}
+
+	n, err := exportJobs(*dataDir, *format, *out)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if *out != "" {
+		log.Printf("Exported %d jobs to %s", n, *out)
+	}
+}
+
+// exportJobs writes the jobs under dataDir in format to the file out, or
+// to stdout if out is "", returning how many it exported.
+func exportJobs(dataDir, format, out string) (n int, err error) {
+	jobs, err := localstorage.NewLocalStorage(dataDir).ListJobs(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	rows := make([]exportRow, len(jobs))
+	for i, job := range jobs {
+		rows[i] = newExportRow(job)
+	}
+
+	w := io.Writer(os.Stdout)
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create %s: %w", out, err)
+		}
+		// A failed close may lose the end of the export
+		defer func() {
+			if closeErr := f.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("failed to write export: %w", closeErr)
+			}
+		}()
+		w = f
+	}
+	if format == "csv" {
+		err = writeExportCSV(w, rows)
+	} else {
+		err = writeExportNDJSON(w, rows)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write export: %w", err)
+	}
+	return len(rows), nil
+}
+
+// newExportRow reads a stored job's outcome marker and metadata.
+func newExportRow(stored localstorage.StoredJob) exportRow {
+	job := stored.Job
+	row := exportRow{
+		JobID:      job.ID,
+		ExternalID: job.ExternalID,
+		URL:        job.URL,
+		Platform:   job.Platform,
+		CreatedAt:  job.CreatedAt.UTC().Format(time.RFC3339),
+		Status:     jobStatus(stored.Dir),
+		Metadata:   "missing",
+	}
+
+	var meta *domain.VideoMetadata
+	if data, err := os.ReadFile(filepath.Join(stored.Dir, "metadata_normalized.json")); err == nil {
+		if err := json.Unmarshal(data, &meta); err == nil {
+			row.Metadata = "normalized"
+		}
+	}
+	if row.Metadata == "missing" {
+		if data, err := os.ReadFile(filepath.Join(stored.Dir, "metadata_raw.json")); err == nil {
+			if meta, err = service.NormalizeMetadata(data); err == nil {
+				row.Metadata = "raw"
+			}
+		}
+	}
+	if row.Metadata == "missing" || meta == nil {
+		return row
+	}
+
+	row.Title = meta.Title
+	row.Author = meta.Author
+	row.Description = meta.Description
+	row.DurationSeconds = meta.DurationSeconds
+	row.ViewCount = meta.ViewCount
+	row.LikeCount = meta.LikeCount
+	row.PublishedAt = meta.PublishedAt
+	row.ThumbnailURL = meta.ThumbnailURL
+	row.Tags = meta.Tags
+	return row
+}
+
+// jobStatus reads a job's outcome from its marker: success, failed or
+// skipped, or incomplete if it has none (still running, or interrupted).
+func jobStatus(dir string) string {
+	for _, marker := range []struct{ name, status string }{
+		{"_SUCCESS", "success"},
+		{"_FAILED", "failed"},
+		{"_SKIPPED", "skipped"},
+	} {
+		if _, err := os.Stat(filepath.Join(dir, marker.name)); err == nil {
+			return marker.status
+		}
+	}
+	return "incomplete"
+}
+
+// writeExportCSV writes rows under an exportColumns header. Tags are
+// joined with ";" and zero numbers are left blank.
+func writeExportCSV(w io.Writer, rows []exportRow) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(exportColumns); err != nil {
+		return err
+	}
+	number := func(n float64) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	for _, r := range rows {
+		record := []string{
+			r.JobID, r.ExternalID, r.URL, r.Platform, r.CreatedAt, r.Status, r.Metadata,
+			r.Title, r.Author, r.Description, number(r.DurationSeconds), number(float64(r.ViewCount)), number(float64(r.LikeCount)),
+			r.PublishedAt, r.ThumbnailURL, strings.Join(r.Tags, ";"),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeExportNDJSON writes rows as one JSON object per line.
+func writeExportNDJSON(w io.Writer, rows []exportRow) error {
+	enc := json.NewEncoder(w)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportJobsWritesFile(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "jobs.csv")
+	n, err := exportJobs(t.TempDir(), "csv", out)
+	if err != nil {
+		t.Fatalf("exportJobs: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("exported %d jobs from an empty data dir", n)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != strings.Join(exportColumns, ",") {
+		t.Errorf("export = %q, want just the header", got)
+	}
+}
+
+func TestExportJobsReportsCreateError(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "missing", "jobs.csv")
+	if _, err := exportJobs(t.TempDir(), "csv", out); err == nil {
+		t.Fatal("exportJobs succeeded writing into a missing directory")
+	}
+}
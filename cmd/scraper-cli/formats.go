@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"scrapeanddown/internal/adapters/ytdlp"
+)
+
+// printFormats lists the formats yt-dlp offers for url as a table.
+func printFormats(ctx context.Context, url string, d *ytdlp.YtDlpDownloader) error {
+	formats, err := d.ListFormats(ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to list formats: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEXT\tRESOLUTION\tFPS\tVCODEC\tACODEC\tSIZE\tNOTE")
+	for _, f := range formats {
+		fps, size := "", ""
+		if f.FPS > 0 {
+			fps = fmt.Sprintf("%g", f.FPS)
+		}
+		if f.Filesize > 0 {
+			size = formatBytes(float64(f.Filesize))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			f.FormatID, f.Ext, f.Resolution, fps,
+			orNone(f.VideoCodec), orNone(f.AudioCodec), size, f.Note)
+	}
+	return tw.Flush()
+}
+
+func orNone(codec string) string {
+	if codec == "" {
+		return "-"
+	}
+	return codec
+}
@@ -2,18 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
-	"scrapeanddown/internal/adapters/apify"
-	"scrapeanddown/internal/adapters/downloader"
-	"scrapeanddown/internal/adapters/localstorage"
 	"scrapeanddown/internal/adapters/ytdlp"
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
 	"scrapeanddown/internal/service"
 )
 
@@ -24,66 +25,289 @@ func main() {
 		log.Println("No .env file found")
 	}
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "watch":
+			runWatch(os.Args[2:])
+			return
+		case "sync":
+			runSync(os.Args[2:])
+			return
+		case "export":
+			runExport(os.Args[2:])
+			return
+		}
+	}
+	runSingle()
+}
+
+// jobArchiver is implemented by storage backends that support -archive.
+type jobArchiver interface {
+	ArchiveJob(ctx context.Context, jobID string, gzipped bool) (string, error)
+	RemoveJob(ctx context.Context, jobID string) error
+}
+
+// runSingle scrapes and downloads a single URL (or resumes a job).
+func runSingle() {
 	// Parse flags
 	url := flag.String("url", "", "YouTube or TikTok video URL to scrape")
-	dataDir := flag.String("data-dir", "./data", "Base directory for storing job data")
+	resumeID := flag.String("resume", "", "Resume an interrupted download for the given job ID")
+	externalID := flag.String("external-id", "", "Your own ID for the job, recorded in input.json")
+	videoURL := flag.String("video-url", "", "Download this already resolved media URL as is, skipping the scrape and yt-dlp")
+	archive := flag.String("archive", "", "Bundle the finished job directory: tar or tar.gz")
+	archiveRemove := flag.Bool("archive-remove", false, "Remove the job directory after archiving (with -archive)")
+	toStdout := flag.Bool("stdout", false, "Stream the video to stdout instead of saving it; logs go to stderr")
+	listFormats := flag.Bool("list-formats", false, "List the formats available for -url via yt-dlp without downloading")
+	fromStdin := flag.Bool("stdin", false, "Run a job per JSON line read from stdin and write a JSON line per result to stdout; logs go to stderr")
+	workers := flag.Int("workers", 1, "Number of -stdin jobs to run concurrently")
+	batch := flag.String("batch", "", "Checkpoint -stdin jobs under this name, so running the same input again skips the jobs already completed")
+	force := flag.Bool("force", false, "With -batch, re-run jobs an interrupted or failed earlier run already completed")
+	cfg := registerJobFlags(flag.CommandLine)
 	flag.Parse()
 
-	if *url == "" {
+	if *fromStdin {
+		if *url != "" || *videoURL != "" || *resumeID != "" || *toStdout || *archive != "" || *listFormats {
+			log.Fatal("-stdin can't be combined with -url, -video-url, -resume, -stdout, -archive or -list-formats")
+		}
+		if *force && *batch == "" {
+			log.Fatal("-force requires -batch")
+		}
+		runStdin(cfg, *workers, *batch, *force)
+		return
+	}
+	if *batch != "" || *force {
+		log.Fatal("-batch and -force require -stdin")
+	}
+
+	if *url == "" && *videoURL == "" && *resumeID == "" {
 		fmt.Println("Usage: scraper-cli -url <video-url> [-data-dir <path>]")
+		fmt.Println("       scraper-cli -video-url <media-url> [-url <page-url>] [-data-dir <path>]")
+		fmt.Println("       scraper-cli -resume <job-id> [-data-dir <path>]")
+		fmt.Println("       scraper-cli -stdin [-workers <n>] [-batch <name> [-force]] [-data-dir <path>] < jobs.jsonl")
+		fmt.Println("       scraper-cli watch -in <dir> [-data-dir <path>]")
+		fmt.Println("       scraper-cli sync -channel <channel-url> [-data-dir <path>]")
+		fmt.Println("       scraper-cli export [-format csv|ndjson] [-out <file>] [-data-dir <path>]")
 		fmt.Println("\nExample:")
 		fmt.Println("  scraper-cli -url https://www.youtube.com/watch?v=dQw4w9WgXcQ")
 		fmt.Println("  scraper-cli -url https://www.tiktok.com/@user/video/1234567890")
 		os.Exit(1)
 	}
 
-	// Setup logger
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	if *listFormats {
+		if *url == "" {
+			log.Fatal("-list-formats requires -url")
+		}
+		d, err := cfg.newYtDlp(log.Default())
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		ctx, cancel := signalContext(log.Default(), 0)
+		defer cancel()
+		if err := printFormats(ctx, *url, d); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
+
+	if *toStdout && (*resumeID != "" || *archive != "") {
+		log.Fatal("-stdout can't be combined with -resume or -archive")
+	}
 
-	logger.Println("=== Video Scraper CLI ===")
-	logger.Printf("URL: %s", *url)
-	logger.Printf("Data Directory: %s", *dataDir)
+	if *videoURL != "" {
+		if *toStdout || *resumeID != "" || *listFormats {
+			log.Fatal("-video-url can't be combined with -stdout, -resume or -list-formats")
+		}
+		if err := service.ValidateVideoURL(*videoURL); err != nil {
+			log.Fatalf("Invalid -video-url: %v", err)
+		}
+	}
 
-	// Initialize adapters
-	scraper, err := apify.NewApifyScraper()
+	if *archive != "" && *archive != "tar" && *archive != "tar.gz" {
+		log.Fatalf("Invalid -archive %q: expected tar or tar.gz", *archive)
+	}
+
+	// Setup logger; with -stdout, stdout carries the video only
+	out := os.Stdout
+	if *toStdout {
+		out = os.Stderr
+	}
+	logger := cfg.newLogger(out)
+
+	if !*cfg.quiet {
+		logger.Println("=== Video Scraper CLI ===")
+		if *url != "" {
+			logger.Printf("URL: %s", *url)
+		}
+		if *videoURL != "" {
+			logger.Printf("Video URL: %s", service.RedactURL(*videoURL))
+		}
+		logger.Printf("Data Directory: %s", *cfg.dataDir)
+	}
+
+	orchestrator, storage, err := cfg.build(logger)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+	if _, ok := storage.(jobArchiver); *archive != "" && !ok {
+		if *cfg.mirrorDirs != "" {
+			logger.Fatalf("-archive can't be combined with -mirror-dir")
+		}
+		logger.Fatalf("-storage %s does not support -archive", *cfg.storageBackend)
+	}
+
+	ctx, cancel := signalContext(logger, *cfg.gracePeriod)
+	defer cancel()
+	if *externalID != "" {
+		ctx = service.WithExternalID(ctx, *externalID)
+	}
+	if *videoURL != "" {
+		ctx = service.WithDirectVideoURL(ctx, *videoURL)
+	}
+
+	// Run the job
+	var result *domain.JobResult
+	switch {
+	case *toStdout:
+		result, err = orchestrator.StreamJob(ctx, *url, os.Stdout)
+	case *resumeID != "":
+		result, err = orchestrator.ResumeJob(ctx, *resumeID)
+	default:
+		result, err = orchestrator.RunJobWithRetry(ctx, *url, cfg.attempts())
+	}
+	cfg.enforceMaxJobs(ctx, storage, logger)
+	if closeErr := cfg.closeOutputs(); closeErr != nil {
+		logger.Printf("ERROR: %v", closeErr)
+		os.Exit(1)
+	}
 	if err != nil {
-		logger.Fatalf("Failed to initialize scraper: %v", err)
+		logger.Printf("Job failed: %s", describeError(err))
+		os.Exit(exitCode(err))
 	}
 
-	ytDlpClient := ytdlp.NewYtDlpDownloader()
+	if *archive != "" {
+		// Checked after build
+		archiver := storage.(jobArchiver)
+		archivePath, err := archiver.ArchiveJob(ctx, result.Job.ID, *archive == "tar.gz")
+		if err != nil {
+			logger.Printf("Archive failed: %v", err)
+			os.Exit(1)
+		}
+		logger.Printf("Archived job to %s", archivePath)
+		if *archiveRemove {
+			if err := archiver.RemoveJob(ctx, result.Job.ID); err != nil {
+				logger.Printf("WARNING: %v", err)
+			}
+		}
+	}
 
-	dl := downloader.NewHTTPDownloader()
-	storage := localstorage.NewLocalStorage(*dataDir)
+	// Print summary
+	fmt.Fprintln(out, "\n=== Job Summary ===")
+	fmt.Fprintf(out, "Job ID:       %s\n", result.Job.ID)
+	if result.Job.ExternalID != "" {
+		fmt.Fprintf(out, "External ID:  %s\n", result.Job.ExternalID)
+	}
+	fmt.Fprintf(out, "Platform:     %s\n", result.Job.Platform)
+	fmt.Fprintf(out, "Success:      %t\n", result.Success)
+	if result.Skipped {
+		fmt.Fprintf(out, "Skipped:      %s\n", result.SkipReason)
+	}
+	if result.MetadataFallback {
+		fmt.Fprintf(out, "Metadata:     %s (oEmbed fallback, basic fields only)\n", result.MetadataPath)
+	} else {
+		fmt.Fprintf(out, "Metadata:     %s\n", result.MetadataPath)
+	}
+	fmt.Fprintf(out, "Video:        %s\n", result.VideoPath)
+	if result.AudioPath != "" {
+		fmt.Fprintf(out, "Audio:        %s\n", result.AudioPath)
+	}
+	if result.DuplicateOf != "" {
+		fmt.Fprintf(out, "Duplicate Of: %s\n", result.DuplicateOf)
+	}
+	for _, r := range result.Renditions {
+		fmt.Fprintf(out, "  %-11s %s\n", r.Quality+":", r.Path)
+	}
+	if result.DownloadBytes > 0 {
+		fmt.Fprintf(out, "Download:     Downloaded %s in %.1fs (%s/s)\n",
+			formatBytes(float64(result.DownloadBytes)),
+			result.DownloadDuration.Seconds(),
+			formatBytes(result.AvgThroughputBytesPerSec))
+	}
+	if result.ApifyComputeUnits > 0 || result.ApifyCostUSD > 0 {
+		fmt.Fprintf(out, "Apify Usage:  %s\n", service.FormatApifyUsage(result.ApifyComputeUnits, result.ApifyCostUSD))
+	}
+	fmt.Fprintf(out, "Completed At: %s\n", result.CompletedAt.Format("2006-01-02 15:04:05 UTC"))
+}
 
-	// Create orchestrator
-	orchestrator := service.NewOrchestrator(scraper, dl, storage, ytDlpClient, logger)
+// unavailableExitCodes lets scripts tell apart why a video couldn't be
+// fetched; other failures exit with 1 (and usage errors with 2).
+var unavailableExitCodes = map[ports.UnavailableReason]int{
+	ports.ReasonRemoved:       3,
+	ports.ReasonPrivate:       4,
+	ports.ReasonMembersOnly:   5,
+	ports.ReasonAgeRestricted: 6,
+	ports.ReasonGeoBlocked:    7,
+}
 
+// describeError renders err, adding a hint for failures the user can fix
+// with a flag.
+func describeError(err error) string {
+	var notFound *ytdlp.BinaryNotFoundError
+	if errors.As(err, &notFound) {
+		return err.Error() + "; install it or set -ytdlp-path"
+	}
+	var unavailable *ports.UnavailableError
+	if errors.As(err, &unavailable) && unavailable.NeedsCookies() {
+		return err.Error() + "; run again with -cookies or -cookies-from-browser"
+	}
+	return err.Error()
+}
+
+// exitCode returns the process exit code for a failed job: 1 unless
+// unavailableExitCodes maps its reason.
+func exitCode(err error) int {
+	if reason, ok := ports.UnavailableReasonOf(err); ok {
+		if code, ok := unavailableExitCodes[reason]; ok {
+			return code
+		}
+	}
+	return 1
+}
+
+// signalContext returns a context for running jobs with two-phase shutdown:
+// the first SIGINT/SIGTERM drains (in-flight work finishes, nothing new
+// starts), and a second signal or the end of gracePeriod cancels. A zero
+// gracePeriod cancels on the first signal.
+func signalContext(logger *log.Logger, gracePeriod time.Duration) (context.Context, context.CancelFunc) {
 	// Setup context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	drain := make(chan struct{})
 
 	// Handle graceful shutdown
-	sigChan := make(chan os.Signal, 1)
+	sigChan := make(chan os.Signal, 2)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
-		<-sigChan
-		logger.Println("\nReceived interrupt signal, cancelling...")
+		select {
+		case <-sigChan:
+		case <-ctx.Done():
+			return
+		}
+		if gracePeriod <= 0 {
+			logger.Println("\nReceived interrupt signal, cancelling...")
+			cancel()
+			return
+		}
+
+		logger.Printf("\nReceived interrupt signal, finishing in-flight work (up to %s); interrupt again to cancel...", gracePeriod)
+		close(drain)
+		select {
+		case <-sigChan:
+			logger.Println("\nReceived second interrupt signal, cancelling...")
+		case <-time.After(gracePeriod):
+			logger.Println("Grace period expired, cancelling...")
+		case <-ctx.Done():
+			return
+		}
 		cancel()
 	}()
-
-	// Run the job
-	result, err := orchestrator.RunJob(ctx, *url)
-	if err != nil {
-		logger.Printf("Job failed: %v", err)
-		os.Exit(1)
-	}
-
-	// Print summary
-	fmt.Println("\n=== Job Summary ===")
-	fmt.Printf("Job ID:       %s\n", result.Job.ID)
-	fmt.Printf("Platform:     %s\n", result.Job.Platform)
-	fmt.Printf("Success:      %t\n", result.Success)
-	fmt.Printf("Metadata:     %s\n", result.MetadataPath)
-	fmt.Printf("Video:        %s\n", result.VideoPath)
-	fmt.Printf("Completed At: %s\n", result.CompletedAt.Format("2006-01-02 15:04:05 UTC"))
+	return service.WithDrain(ctx, drain), cancel
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"scrapeanddown/internal/adapters/ytdlp"
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestDescribeError(t *testing.T) {
+	err := fmt.Errorf("resolve failed: %w", &ytdlp.BinaryNotFoundError{Path: "yt-dlp"})
+	if got := describeError(err); !strings.HasSuffix(got, "; install it or set -ytdlp-path") {
+		t.Errorf("describeError(%v) = %q, want the -ytdlp-path hint", err, got)
+	}
+	other := fmt.Errorf("download failed")
+	if got := describeError(other); got != other.Error() {
+		t.Errorf("describeError(%v) = %q, want it unchanged", other, got)
+	}
+}
+
+func TestDescribeErrorCookiesHint(t *testing.T) {
+	tests := []struct {
+		reason ports.UnavailableReason
+		hint   bool
+	}{
+		{ports.ReasonAgeRestricted, true},
+		{ports.ReasonMembersOnly, true},
+		{ports.ReasonPrivate, false},
+		{ports.ReasonRemoved, false},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("resolve failed: %w", &ports.UnavailableError{Reason: tt.reason})
+		got := describeError(err)
+		if hint := strings.HasSuffix(got, "; run again with -cookies or -cookies-from-browser"); hint != tt.hint {
+			t.Errorf("describeError(%s) = %q, want the cookies hint %v", tt.reason, got, tt.hint)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"generic", fmt.Errorf("download failed"), 1},
+		{"plain unavailable", fmt.Errorf("oembed: %w", ports.ErrVideoUnavailable), 3},
+		{"removed", &ports.UnavailableError{Reason: ports.ReasonRemoved}, 3},
+		{"private", fmt.Errorf("x: %w", &ports.UnavailableError{Reason: ports.ReasonPrivate}), 4},
+		{"members only", &ports.UnavailableError{Reason: ports.ReasonMembersOnly}, 5},
+		{"age restricted", &ports.UnavailableError{Reason: ports.ReasonAgeRestricted}, 6},
+		{"geo blocked", &ports.UnavailableError{Reason: ports.ReasonGeoBlocked}, 7},
+		{"unmapped reason", &ports.UnavailableError{Reason: "quota"}, 1},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("%s: exitCode(%v) = %d, want %d", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
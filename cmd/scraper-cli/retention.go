@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// maxJobsInterval is how often long-running modes enforce -max-jobs.
+const maxJobsInterval = time.Minute
+
+// jobPruner is implemented by storage backends that support -max-jobs.
+type jobPruner interface {
+	EnforceMaxJobs(ctx context.Context, max int) ([]string, error)
+}
+
+// enforceMaxJobs removes the oldest jobs beyond -max-jobs, if set.
+func (c *jobConfig) enforceMaxJobs(ctx context.Context, storage ports.Storage, logger *log.Logger) {
+	pruner, ok := storage.(jobPruner)
+	if *c.maxJobs == 0 || !ok || ctx.Err() != nil {
+		return
+	}
+	removed, err := pruner.EnforceMaxJobs(ctx, *c.maxJobs)
+	if len(removed) > 0 && !*c.quiet {
+		logger.Printf("Removed %d jobs over -max-jobs %d: %v", len(removed), *c.maxJobs, removed)
+	}
+	if err != nil {
+		logger.Printf("WARNING: failed to enforce -max-jobs: %v", err)
+	}
+}
+
+// enforceMaxJobsEvery enforces -max-jobs every maxJobsInterval until ctx
+// is done, for modes that run until interrupted.
+func (c *jobConfig) enforceMaxJobsEvery(ctx context.Context, storage ports.Storage, logger *log.Logger) {
+	if *c.maxJobs == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(maxJobsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.enforceMaxJobs(ctx, storage, logger)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
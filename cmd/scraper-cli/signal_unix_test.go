@@ -0,0 +1,88 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for the signal goroutine to log to.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// interrupt sends SIGINT to the test process, which signalContext catches,
+// and waits for want to be logged.
+func interrupt(t *testing.T, logs *syncBuffer, want string) {
+	t.Helper()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(logs.String(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%q not logged after SIGINT; logs:\n%s", want, logs)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSignalContext(t *testing.T) {
+	t.Run("second signal cancels", func(t *testing.T) {
+		logs := &syncBuffer{}
+		ctx, cancel := signalContext(log.New(logs, "", 0), time.Hour)
+		defer cancel()
+
+		interrupt(t, logs, "finishing in-flight work")
+		if ctx.Err() != nil {
+			t.Fatal("first signal cancelled instead of draining")
+		}
+		interrupt(t, logs, "second interrupt signal")
+		<-ctx.Done()
+	})
+	t.Run("grace period expires", func(t *testing.T) {
+		logs := &syncBuffer{}
+		ctx, cancel := signalContext(log.New(logs, "", 0), 20*time.Millisecond)
+		defer cancel()
+
+		interrupt(t, logs, "finishing in-flight work")
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("not cancelled after the grace period")
+		}
+		if !strings.Contains(logs.String(), "Grace period expired") {
+			t.Errorf("logs = %q, want the grace period to have expired", logs)
+		}
+	})
+	t.Run("no grace period", func(t *testing.T) {
+		logs := &syncBuffer{}
+		ctx, cancel := signalContext(log.New(logs, "", 0), 0)
+		defer cancel()
+
+		interrupt(t, logs, "cancelling")
+		<-ctx.Done()
+		if strings.Contains(logs.String(), "in-flight") {
+			t.Errorf("logs = %q, want no drain", logs)
+		}
+	})
+}
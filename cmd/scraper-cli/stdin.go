@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+)
+
+// runStdin implements "scraper-cli -stdin": it runs a job per JSON line on
+// stdin and streams a JSON line per result to stdout. With a batch name,
+// jobs an earlier run of the batch completed are skipped, unless force.
+func runStdin(cfg *jobConfig, workers int, batch string, force bool) {
+	// stdout carries the results only
+	logger := cfg.newLogger(os.Stderr)
+
+	if !*cfg.quiet {
+		logger.Println("=== Video Scraper CLI (stdin) ===")
+		logger.Printf("Data Directory: %s", *cfg.dataDir)
+	}
+
+	orchestrator, storage, err := cfg.build(logger)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	ctx, cancel := signalContext(logger, *cfg.gracePeriod)
+	defer cancel()
+	cfg.enforceMaxJobsEvery(ctx, storage, logger)
+
+	var failed int
+	if batch == "" {
+		failed, err = orchestrator.RunJobStream(ctx, os.Stdin, os.Stdout, workers, cfg.attempts())
+	} else {
+		if force {
+			if err := orchestrator.ClearBatch(ctx, batch); err != nil {
+				logger.Printf("WARNING: failed to clear checkpoint for %s: %v", batch, err)
+			}
+		}
+		failed, err = orchestrator.ResumeJobStream(ctx, batch, os.Stdin, os.Stdout, workers, cfg.attempts())
+	}
+	cfg.enforceMaxJobs(ctx, storage, logger)
+	if closeErr := cfg.closeOutputs(); closeErr != nil {
+		logger.Printf("ERROR: %v", closeErr)
+		os.Exit(1)
+	}
+	if err != nil {
+		logger.Printf("ERROR: %v", err)
+		os.Exit(1)
+	}
+	if failed > 0 {
+		logger.Printf("%d jobs failed", failed)
+		os.Exit(1)
+	}
+}
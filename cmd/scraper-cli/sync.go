@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"scrapeanddown/internal/core/ports"
+	"scrapeanddown/internal/service"
+)
+
+// runSync implements "scraper-cli sync": it downloads the videos of a
+// channel that earlier syncs haven't.
+func runSync(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	channelURL := fs.String("channel", "", "Channel or playlist URL to sync")
+	workers := fs.Int("workers", 1, "Number of jobs to run concurrently")
+	cfg := registerJobFlags(fs)
+	fs.Parse(args)
+
+	if *channelURL == "" {
+		fmt.Println("Usage: scraper-cli sync -channel <channel-url> [-workers <n>] [-data-dir <path>]")
+		os.Exit(1)
+	}
+
+	logger := cfg.newLogger(os.Stdout)
+
+	if !*cfg.quiet {
+		logger.Println("=== Video Scraper CLI (sync) ===")
+		logger.Printf("Channel: %s", *channelURL)
+		logger.Printf("Data Directory: %s", *cfg.dataDir)
+	}
+
+	orchestrator, storage, err := cfg.build(logger)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	ctx, cancel := signalContext(logger, *cfg.gracePeriod)
+	defer cancel()
+
+	results, err := orchestrator.SyncChannel(ctx, *channelURL, *workers, cfg.attempts())
+	cfg.enforceMaxJobs(ctx, storage, logger)
+	if closeErr := cfg.closeOutputs(); closeErr != nil {
+		logger.Printf("ERROR: %v", closeErr)
+		os.Exit(1)
+	}
+	if err != nil {
+		logger.Printf("Sync failed: %v", err)
+		os.Exit(1)
+	}
+
+	failed, skipped := 0, 0
+	unavailable := make(map[ports.UnavailableReason]int)
+	for _, r := range results {
+		if r.Err == nil && r.Result != nil && r.Result.Skipped {
+			logger.Printf("SKIPPED %s: %s", r.URL, r.Result.SkipReason)
+			skipped++
+		}
+		if r.Err != nil {
+			logger.Printf("FAILED %s: %s", r.URL, describeError(r.Err))
+			failed++
+			if reason, ok := ports.UnavailableReasonOf(r.Err); ok {
+				unavailable[reason]++
+			}
+		}
+	}
+	fmt.Println("\n=== Sync Summary ===")
+	fmt.Printf("New videos:   %d\n", len(results))
+	fmt.Printf("Downloaded:   %d\n", len(results)-failed-skipped)
+	fmt.Printf("Skipped:      %d\n", skipped)
+	fmt.Printf("Failed:       %d\n", failed)
+	if units, cost := service.ApifyUsage(results); units > 0 || cost > 0 {
+		fmt.Printf("Apify Usage:  %s\n", service.FormatApifyUsage(units, cost))
+	}
+	for _, reason := range []ports.UnavailableReason{
+		ports.ReasonRemoved, ports.ReasonPrivate, ports.ReasonMembersOnly, ports.ReasonAgeRestricted, ports.ReasonGeoBlocked,
+	} {
+		if n := unavailable[reason]; n > 0 {
+			fmt.Printf("  %-14s %d\n", string(reason)+":", n)
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"scrapeanddown/internal/service"
+)
+
+// runWatch implements "scraper-cli watch": it processes URL files dropped
+// into a directory until interrupted.
+func runWatch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	inDir := fs.String("in", "", "Directory to watch for .txt/.json URL files")
+	workers := fs.Int("workers", 1, "Number of jobs to run concurrently")
+	interval := fs.Duration("poll-interval", 2*time.Second, "How often to scan the input directory")
+	force := fs.Bool("force", false, "Re-run URLs an interrupted or failed earlier run of the same file already completed")
+	cfg := registerJobFlags(fs)
+	fs.Parse(args)
+
+	if *inDir == "" {
+		fmt.Println("Usage: scraper-cli watch -in <dir> [-workers <n>] [-data-dir <path>]")
+		os.Exit(1)
+	}
+
+	logger := cfg.newLogger(os.Stdout)
+
+	if !*cfg.quiet {
+		logger.Println("=== Video Scraper CLI (watch) ===")
+		logger.Printf("Input Directory: %s", *inDir)
+		logger.Printf("Data Directory: %s", *cfg.dataDir)
+	}
+
+	orchestrator, storage, err := cfg.build(logger)
+	if err != nil {
+		logger.Fatalf("%v", err)
+	}
+
+	ctx, cancel := signalContext(logger, *cfg.gracePeriod)
+	defer cancel()
+	cfg.enforceMaxJobsEvery(ctx, storage, logger)
+
+	watcher := service.NewWatcher(orchestrator, *inDir, service.WatchOptions{
+		Interval:    *interval,
+		Workers:     *workers,
+		MaxAttempts: cfg.attempts(),
+		Force:       *force,
+	})
+	err = watcher.Run(ctx)
+	if closeErr := cfg.closeOutputs(); closeErr != nil {
+		logger.Printf("ERROR: %v", closeErr)
+		os.Exit(1)
+	}
+	if err != nil {
+		logger.Fatalf("Watch failed: %v", err)
+	}
+}
@@ -5,3 +5,5 @@ go 1.25.5
 require github.com/google/uuid v1.6.0
 
 require github.com/joho/godotenv v1.5.1
+
+require golang.org/x/text v0.33.0
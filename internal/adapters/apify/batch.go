@@ -0,0 +1,176 @@
+package apify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"strings"
+	"sync"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// maxBatchURLs caps the URLs one batched run scrapes, so a long batch is
+// split into runs that each finish well within the actor's timeout.
+const maxBatchURLs = 50
+
+// ScrapeBatch scrapes the TikTok URLs among urls in as few actor runs as
+// possible (the TikTok actor takes a list of postURLs), and splits each
+// run's dataset back into one result per URL. A run's usage is shared
+// evenly among the results it produced.
+//
+// URLs of other platforms, URLs the dataset has no item for, and URLs of a
+// failed run get nil results, to be scraped on their own. If runs failed,
+// the results of the others are returned along with their errors.
+func (s *ApifyScraper) ScrapeBatch(ctx context.Context, urls []string) ([]*ports.ScrapeResult, error) {
+	results := make([]*ports.ScrapeResult, len(urls))
+
+	var batch []int
+	for i, u := range urls {
+		if detectPlatform(u) == "tiktok" {
+			batch = append(batch, i)
+		}
+	}
+	// A lone URL costs the same as a normal scrape, which reports removed
+	// videos properly
+	if len(batch) < 2 {
+		return results, nil
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for start := 0; start < len(batch); start += maxBatchURLs {
+		chunk := batch[start:min(start+maxBatchURLs, len(batch))]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chunkURLs := make([]string, len(chunk))
+			for j, i := range chunk {
+				chunkURLs[j] = urls[i]
+			}
+			chunkResults, err := s.scrapeChunk(ctx, chunkURLs)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("batch of %d URLs: %w", len(chunk), err))
+				return
+			}
+			for j, i := range chunk {
+				results[i] = chunkResults[j]
+			}
+		}()
+	}
+	wg.Wait()
+	return results, errors.Join(errs...)
+}
+
+// scrapeChunk scrapes TikTok urls in a single run, guarded by the breaker.
+func (s *ApifyScraper) scrapeChunk(ctx context.Context, urls []string) ([]*ports.ScrapeResult, error) {
+	if s.breaker != nil {
+		if err := s.breaker.allow(); err != nil {
+			return nil, err
+		}
+	}
+	input := s.buildInput(urls[0], "tiktok")
+	input["postURLs"] = urls
+	// The single-URL input asks for one item; a batch needs one per URL
+	input["resultsPerPage"] = len(urls)
+	run, rawData, err := s.runActor(ctx, tiktokActorID, input)
+	if s.breaker != nil {
+		s.breaker.record(ctx, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := splitDataset(rawData, urls)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split results: %w", err)
+	}
+	found := 0
+	for _, item := range items {
+		if item != nil {
+			found++
+		}
+	}
+	s.debugf("Apify: batched dataset %s has items for %d of %d URLs", run.DefaultDatasetID, found, len(urls))
+
+	results := make([]*ports.ScrapeResult, len(urls))
+	for i, item := range items {
+		if item == nil {
+			continue
+		}
+		results[i] = s.newResult(item, "tiktok")
+		results[i].ComputeUnits = run.Stats.ComputeUnits / float64(found)
+		results[i].CostUSD = run.UsageTotalUSD / float64(found)
+		results[i].RunID, results[i].DatasetID = run.ID, run.DefaultDatasetID
+	}
+	return results, nil
+}
+
+// splitDataset matches a batched run's dataset items to urls, returning
+// each URL's item as a one-item dataset (what a single-URL run returns), or
+// nil if no item matches. An item matches a URL it was submitted as, or one
+// naming the same post ID.
+func splitDataset(rawData []byte, urls []string) ([][]byte, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(rawData, &items); err != nil {
+		return nil, err
+	}
+
+	bySubmitted := make(map[string]json.RawMessage)
+	byID := make(map[string]json.RawMessage)
+	for _, item := range items {
+		var keys struct {
+			ID           string `json:"id"`
+			SubmittedURL string `json:"submittedVideoUrl"`
+			WebVideoURL  string `json:"webVideoUrl"`
+		}
+		if err := json.Unmarshal(item, &keys); err != nil {
+			continue
+		}
+		if keys.SubmittedURL != "" {
+			bySubmitted[strings.TrimSpace(keys.SubmittedURL)] = item
+		}
+		id := keys.ID
+		if id == "" {
+			id = tiktokPostID(keys.WebVideoURL)
+		}
+		if id != "" {
+			byID[id] = item
+		}
+	}
+
+	split := make([][]byte, len(urls))
+	for i, u := range urls {
+		item, ok := bySubmitted[strings.TrimSpace(u)]
+		if !ok {
+			if id := tiktokPostID(u); id != "" {
+				item, ok = byID[id]
+			}
+		}
+		if ok {
+			split[i], _ = json.Marshal([]json.RawMessage{item})
+		}
+	}
+	return split, nil
+}
+
+// tiktokPostID returns the post ID of a tiktok.com/@user/video/<id> (or
+// /photo/<id>) URL, or "" for other URLs such as short links.
+func tiktokPostID(rawURL string) string {
+	u, err := neturl.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] == "video" || segments[i] == "photo" {
+			return segments[i+1]
+		}
+	}
+	return ""
+}
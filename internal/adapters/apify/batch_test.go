@@ -0,0 +1,49 @@
+package apify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestScrapeBatchAsksForAnItemPerURL(t *testing.T) {
+	var input map[string]interface{}
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/runs"):
+			body, _ := io.ReadAll(req.Body)
+			if err := json.Unmarshal(body, &input); err != nil {
+				t.Errorf("run input: %v", err)
+			}
+			return jsonResponse(http.StatusCreated, `{"data":{"id":"run1"}}`), nil
+		case strings.HasSuffix(req.URL.Path, "/actor-runs/run1"):
+			return jsonResponse(http.StatusOK, `{"data":{"id":"run1","status":"SUCCEEDED","defaultDatasetId":"ds1"}}`), nil
+		case strings.HasSuffix(req.URL.Path, "/datasets/ds1/items"):
+			return jsonResponse(http.StatusOK, `[{"id":"1"},{"id":"2"},{"id":"3"}]`), nil
+		}
+		return jsonResponse(http.StatusNotFound, `{}`), nil
+	})}
+	s := NewApifyScraperWithClient("token", client)
+	s.after = instantAfter
+
+	urls := []string{
+		"https://www.tiktok.com/@user/video/1",
+		"https://www.tiktok.com/@user/video/2",
+		"https://www.tiktok.com/@user/video/3",
+	}
+	results, err := s.ScrapeBatch(context.Background(), urls)
+	if err != nil {
+		t.Fatalf("ScrapeBatch: %v", err)
+	}
+	if got := input["resultsPerPage"]; got != float64(len(urls)) {
+		t.Errorf("resultsPerPage = %v, want %d", got, len(urls))
+	}
+	for i, r := range results {
+		if r == nil {
+			t.Errorf("no result for %s", urls[i])
+		}
+	}
+}
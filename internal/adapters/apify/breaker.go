@@ -0,0 +1,97 @@
+package apify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// breakerState is the state of a CircuitBreaker.
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // Requests flow normally
+	breakerOpen                         // Requests fail fast with ports.ErrCircuitOpen
+	breakerHalfOpen                     // One trial request decides whether to close
+)
+
+// CircuitBreaker stops calls to Apify once it keeps failing (suspended
+// account, broken actor) so a batch fails fast instead of stalling. After
+// threshold consecutive failures it opens for cooldown; then a single trial
+// request is let through, closing the breaker on success and reopening it on
+// failure. Like Limiter, one breaker can be shared by several scrapers.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	trial    bool // A half-open trial request is in flight
+}
+
+// NewCircuitBreaker creates a breaker that opens after threshold consecutive
+// failures and stays open for cooldown.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &CircuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// WithCircuitBreaker guards runs with b.
+func WithCircuitBreaker(b *CircuitBreaker) Option {
+	return func(s *ApifyScraper) {
+		s.breaker = b
+	}
+}
+
+// allow reports whether a request may proceed, moving an open breaker to
+// half-open once the cooldown has passed.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		remaining := b.cooldown - b.now().Sub(b.openedAt)
+		if remaining > 0 {
+			return fmt.Errorf("%w: apify (retrying in %s)", ports.ErrCircuitOpen, remaining.Round(time.Second))
+		}
+		b.state = breakerHalfOpen
+		b.trial = true
+		return nil
+	case breakerHalfOpen:
+		if b.trial {
+			return fmt.Errorf("%w: apify (testing recovery)", ports.ErrCircuitOpen)
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of an allowed request. A
+// missing video still means Apify answered; a cancelled request says
+// nothing either way.
+func (b *CircuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	switch {
+	case err == nil || errors.Is(err, ports.ErrVideoUnavailable):
+		b.state = breakerClosed
+		b.failures = 0
+	case ctx.Err() != nil:
+	default:
+		b.failures++
+		if b.state == breakerHalfOpen || b.failures >= b.threshold {
+			b.state = breakerOpen
+			b.openedAt = b.now()
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package apify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+var errUpstream = errors.New("actor run failed")
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	ctx := context.Background()
+	clock := time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC)
+	b := NewCircuitBreaker(2, time.Minute)
+	b.now = func() time.Time { return clock }
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		outcome   error // Recorded if the request is allowed
+		wantAllow bool
+		wantState breakerState
+	}{
+		{"first failure", 0, errUpstream, true, breakerClosed},
+		{"threshold reached", 0, errUpstream, true, breakerOpen},
+		{"open fails fast", 30 * time.Second, nil, false, breakerOpen},
+		{"trial after cooldown fails", 30 * time.Second, errUpstream, true, breakerOpen},
+		{"reopened", 59 * time.Second, nil, false, breakerOpen},
+		{"trial after cooldown succeeds", time.Second, nil, true, breakerClosed},
+		{"closed again", 0, errUpstream, true, breakerClosed},
+		{"missing video counts as an answer", 0, ports.ErrVideoUnavailable, true, breakerClosed},
+		{"failures counted from the answer", 0, errUpstream, true, breakerClosed},
+	}
+	for _, step := range steps {
+		clock = clock.Add(step.advance)
+		err := b.allow()
+		if allowed := err == nil; allowed != step.wantAllow {
+			t.Fatalf("%s: allow = %v, want allowed %v", step.name, err, step.wantAllow)
+		}
+		if err != nil && !errors.Is(err, ports.ErrCircuitOpen) {
+			t.Errorf("%s: allow err = %v, want ErrCircuitOpen", step.name, err)
+		}
+		if err == nil {
+			b.record(ctx, step.outcome)
+		}
+		if b.state != step.wantState {
+			t.Errorf("%s: state = %d, want %d", step.name, b.state, step.wantState)
+		}
+	}
+}
+
+// A half-open breaker lets one trial through at a time, and a cancelled
+// trial neither closes nor reopens it.
+func TestCircuitBreakerHalfOpenTrial(t *testing.T) {
+	clock := time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC)
+	b := NewCircuitBreaker(1, time.Minute)
+	b.now = func() time.Time { return clock }
+	b.record(context.Background(), errUpstream)
+
+	clock = clock.Add(time.Minute)
+	if err := b.allow(); err != nil {
+		t.Fatalf("trial after cooldown: %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ports.ErrCircuitOpen) {
+		t.Errorf("second request during the trial = %v, want ErrCircuitOpen", err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.record(canceled, context.Canceled)
+	if b.state != breakerHalfOpen {
+		t.Errorf("state after a cancelled trial = %d, want half-open", b.state)
+	}
+	if err := b.allow(); err != nil {
+		t.Errorf("next trial after a cancelled one: %v", err)
+	}
+}
+
+// An open breaker fails scrapes without contacting Apify.
+func TestScrapeWithOpenBreaker(t *testing.T) {
+	api := &fakeAPI{start: reply(http.StatusBadRequest, `{"error":{"type":"actor-is-broken"}}`)}
+	s := newServerScraper(t, api, WithCircuitBreaker(NewCircuitBreaker(1, time.Hour)))
+
+	if _, err := s.Scrape(context.Background(), tiktokURL); err == nil || errors.Is(err, ports.ErrCircuitOpen) {
+		t.Fatalf("first Scrape err = %v, want the API error", err)
+	}
+	sent := len(api.requests)
+	if _, err := s.Scrape(context.Background(), tiktokURL); !errors.Is(err, ports.ErrCircuitOpen) {
+		t.Errorf("Scrape with the breaker open err = %v, want ErrCircuitOpen", err)
+	}
+	if len(api.requests) != sent {
+		t.Errorf("open breaker sent %v, want no requests", api.requests[sent:])
+	}
+}
@@ -4,76 +4,308 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"scrapeanddown/internal/core/ports"
+	"scrapeanddown/internal/retry"
 )
 
+// DefaultBaseURL is the public Apify API.
+const DefaultBaseURL = "https://api.apify.com/v2"
+
 const (
-	apifyBaseURL = "https://api.apify.com/v2"
 	// Actor IDs for different platforms (using internal Apify IDs)
 	youtubeMetadataActorID = "h7sDV53CddomktSi5"        // streamers/youtube-scraper
 	youtubeDownloadActorID = "apify~youtube-downloader" // Unused (replaced by fallback strategy)
 	tiktokActorID          = "GdWCkxBtKWOsKjdch"        // clockworks~tiktok-scraper
+
+	defaultMaxComments = 100
+
+	// Retries for 429 responses when starting a run
+	maxRateLimitAttempts    = 5
+	initialRateLimitBackoff = 2 * time.Second
 )
 
 // ApifyScraper implements ports.Scraper using Apify REST API.
 type ApifyScraper struct {
-	apiToken string
-	client   *http.Client
+	apiToken     string
+	baseURL      string
+	client       *http.Client
+	withComments bool
+	maxComments  int
+	limiter      *Limiter
+	breaker      *CircuitBreaker
+	webhook      *webhookConfig
+	proxy        *ProxyConfig
+	polling      PollConfig
+	readyTimeout time.Duration
+	maxHeight    int
+	maxFPS       int
+	fallback     ports.Scraper
+	statusf      func(format string, args ...interface{})
+	debugf       func(format string, args ...interface{})
+
+	// after is time.After; tests substitute a fake clock.
+	after func(time.Duration) <-chan time.Time
+}
+
+// Option configures an ApifyScraper.
+type Option func(*ApifyScraper)
+
+// WithStatusLog logs every run status transition (READY, RUNNING, ...)
+// through logf, so a slow cold start can be told apart from a hang.
+func WithStatusLog(logf func(format string, args ...interface{})) Option {
+	return func(s *ApifyScraper) {
+		s.statusf = logf
+	}
+}
+
+// WithDebugLog logs actor runs, status changes and dataset fetches through
+// logf. The API token is never logged.
+func WithDebugLog(logf func(format string, args ...interface{})) Option {
+	return func(s *ApifyScraper) {
+		s.debugf = logf
+	}
+}
+
+// WithFallback scrapes URLs of platforms without an actor (e.g. Pinterest)
+// with fallback, such as an oembed.OEmbedScraper, instead of failing with
+// ports.ErrUnsupportedPlatform. Its results are marked FromFallback.
+func WithFallback(fallback ports.Scraper) Option {
+	return func(s *ApifyScraper) {
+		s.fallback = fallback
+	}
+}
+
+// WithComments enables comment scraping, capped at maxComments per video.
+// A maxComments of 0 uses defaultMaxComments.
+func WithComments(maxComments int) Option {
+	return func(s *ApifyScraper) {
+		s.withComments = true
+		s.maxComments = maxComments
+	}
+}
+
+// WithLimiter bounds concurrent runs and spaces run starts using l, which may
+// be shared across scrapers.
+func WithLimiter(l *Limiter) Option {
+	return func(s *ApifyScraper) {
+		s.limiter = l
+	}
+}
+
+// WithWebhook makes runs notify receiver (reachable by Apify at publicURL)
+// when they finish, instead of relying on frequent status polling.
+// Intended for server mode; the CLI keeps polling.
+func WithWebhook(receiver *WebhookReceiver, publicURL string) Option {
+	return func(s *ApifyScraper) {
+		s.webhook = &webhookConfig{receiver: receiver, url: publicURL}
+	}
+}
+
+// ProxyConfig is the actor input's proxyConfiguration: Apify Proxy with
+// optional proxy groups (e.g. "RESIDENTIAL") and an exit country code
+// (e.g. "US"), which often fixes empty results for geo-blocked content.
+type ProxyConfig struct {
+	UseApifyProxy bool     `json:"useApifyProxy"`
+	Groups        []string `json:"apifyProxyGroups,omitempty"`
+	Country       string   `json:"apifyProxyCountry,omitempty"`
+}
+
+// WithProxy passes proxy as the actor's proxyConfiguration.
+func WithProxy(proxy ProxyConfig) Option {
+	return func(s *ApifyScraper) {
+		s.proxy = &proxy
+	}
+}
+
+// WithMaxHeight makes the scraped video URL the best rendition in the
+// item's "formats" array no taller than maxHeight (see selectFormat). Items
+// without per-format heights keep the usual URL.
+func WithMaxHeight(maxHeight int) Option {
+	return func(s *ApifyScraper) {
+		s.maxHeight = maxHeight
+	}
+}
+
+// WithMaxFPS makes the scraped video URL the best rendition in the item's
+// "formats" array at no more than maxFPS frames per second, combined with
+// WithMaxHeight if set. Entries without a frame rate qualify.
+func WithMaxFPS(maxFPS int) Option {
+	return func(s *ApifyScraper) {
+		s.maxFPS = maxFPS
+	}
 }
 
 // NewApifyScraper creates a new ApifyScraper.
-// Reads the API token from APIFY_API_TOKEN environment variable.
-func NewApifyScraper() (*ApifyScraper, error) {
+// Reads the API token from APIFY_API_TOKEN environment variable, and the
+// API base URL from APIFY_BASE_URL if set.
+func NewApifyScraper(opts ...Option) (*ApifyScraper, error) {
 	token := os.Getenv("APIFY_API_TOKEN")
 	if token == "" {
 		return nil, fmt.Errorf("APIFY_API_TOKEN environment variable not set")
 	}
-	return &ApifyScraper{
+	return NewApifyScraperWithConfig(Config{Token: token, BaseURL: os.Getenv("APIFY_BASE_URL")}, opts...)
+}
+
+// Config is the API connection of NewApifyScraperWithConfig.
+type Config struct {
+	Token string
+	// BaseURL is the API root including its version, e.g. an Apify
+	// Enterprise domain's "https://apify.example.com/v2" (default:
+	// DefaultBaseURL)
+	BaseURL string
+	Client  *http.Client // Default: a client with a 5 minute timeout
+}
+
+// NewApifyScraperWithConfig creates a new ApifyScraper for the given API
+// connection instead of the environment's.
+func NewApifyScraperWithConfig(cfg Config, opts ...Option) (*ApifyScraper, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("apify token not set")
+	}
+	baseURL := DefaultBaseURL
+	if cfg.BaseURL != "" {
+		u, err := neturl.Parse(cfg.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid apify base url %q: expected an absolute http(s) url", cfg.BaseURL)
+		}
+		baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	s := NewApifyScraperWithClient(cfg.Token, client, opts...)
+	s.baseURL = baseURL
+	return s, nil
+}
+
+// NewApifyScraperWithClient creates a new ApifyScraper using the given token
+// and HTTP client instead of the environment and internal defaults.
+func NewApifyScraperWithClient(token string, client *http.Client, opts ...Option) *ApifyScraper {
+	s := &ApifyScraper{
 		apiToken: token,
-		client: &http.Client{
-			Timeout: 5 * time.Minute,
-		},
-	}, nil
+		baseURL:  DefaultBaseURL,
+		client:   client,
+		polling:  defaultPolling,
+		after:    time.After,
+		statusf:  func(string, ...interface{}) {},
+		debugf:   func(string, ...interface{}) {},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Scrape fetches metadata for the given video URL using Apify.
 func (s *ApifyScraper) Scrape(ctx context.Context, videoPageURL string) (*ports.ScrapeResult, error) {
 	platform := detectPlatform(videoPageURL)
+	actorID := s.getActorID(platform)
+	if actorID == "" && s.fallback != nil {
+		s.debugf("Apify: no actor for %s, using the fallback scraper", videoPageURL)
+		result, err := s.fallback.Scrape(ctx, videoPageURL)
+		if err != nil {
+			return nil, err
+		}
+		result.FromFallback = true
+		return result, nil
+	}
 	if platform == "" {
-		return nil, fmt.Errorf("unsupported platform for URL: %s", videoPageURL)
+		return nil, fmt.Errorf("%w for URL: %s", ports.ErrUnsupportedPlatform, videoPageURL)
 	}
-
-	actorID := s.getActorID(platform)
 	if actorID == "" {
 		return nil, fmt.Errorf("no actor configured for platform: %s", platform)
 	}
 
+	if s.breaker == nil {
+		return s.scrape(ctx, videoPageURL, platform, actorID)
+	}
+	if err := s.breaker.allow(); err != nil {
+		return nil, err
+	}
+	result, err := s.scrape(ctx, videoPageURL, platform, actorID)
+	s.breaker.record(ctx, err)
+	return result, err
+}
+
+// scrape runs the platform's actor and reads its results.
+func (s *ApifyScraper) scrape(ctx context.Context, videoPageURL, platform, actorID string) (*ports.ScrapeResult, error) {
+	run, rawData, err := s.runActor(ctx, actorID, s.buildInput(videoPageURL, platform))
+	if err != nil {
+		return nil, err
+	}
+
+	// A succeeded run with no items means the video is gone (deleted/removed)
+	if isEmptyDataset(rawData) {
+		return nil, &ports.UnavailableError{Reason: ports.ReasonRemoved}
+	}
+
+	result := s.newResult(rawData, platform)
+	result.ComputeUnits = run.Stats.ComputeUnits
+	result.CostUSD = run.UsageTotalUSD
+	result.RunID, result.DatasetID = run.ID, run.DefaultDatasetID
+	return result, nil
+}
+
+// runActor runs the actor with input and returns the finished run and its
+// dataset items.
+func (s *ApifyScraper) runActor(ctx context.Context, actorID string, input map[string]interface{}) (*runStatus, []byte, error) {
+	// Hold a run slot until the results are fetched
+	if s.limiter != nil {
+		if err := s.limiter.Acquire(ctx); err != nil {
+			return nil, nil, err
+		}
+		defer s.limiter.Release()
+	}
+
 	// Start the actor run
-	runID, err := s.startActorRun(ctx, actorID, videoPageURL, platform)
+	runID, err := s.startActorRun(ctx, actorID, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start actor run: %w", err)
+		return nil, nil, fmt.Errorf("failed to start actor run: %w", err)
 	}
 
 	// Wait for completion and get results
-	rawData, err := s.waitAndGetResults(ctx, runID)
+	run, err := s.waitForRun(ctx, runID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get results: %w", err)
+	}
+	if run.ID == "" {
+		run.ID = runID
+	}
+	rawData, err := s.getDatasetItems(ctx, run.DefaultDatasetID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get results: %w", err)
+		return nil, nil, fmt.Errorf("failed to get results: %w", err)
 	}
+	return run, rawData, nil
+}
 
+// newResult builds the ScrapeResult for a dataset, without the run's usage.
+func (s *ApifyScraper) newResult(rawData []byte, platform string) *ports.ScrapeResult {
 	// Extract video URL if possible (optional for YouTube since we use RapidAPI)
 	videoURL, _ := s.extractVideoURL(rawData, platform)
 
-	return &ports.ScrapeResult{
+	result := &ports.ScrapeResult{
 		RawMetadata: rawData,
 		VideoURL:    videoURL,
-	}, nil
+	}
+	if s.withComments {
+		result.Comments = extractComments(rawData)
+	}
+	result.DurationSeconds, result.EstimatedBytes = extractSizeHints(rawData)
+	if platform == "tiktok" {
+		result.Music = extractMusic(rawData)
+	}
+	return result
 }
 
 // scrapeYouTubeDualActor is removed as we now handle downloads via RapidAPI/yt-dlp in Orchestrator
@@ -89,28 +321,68 @@ func (s *ApifyScraper) getActorID(platform string) string {
 	}
 }
 
-func (s *ApifyScraper) startActorRun(ctx context.Context, actorID, videoURL, platform string) (string, error) {
-	url := fmt.Sprintf("%s/acts/%s/runs?token=%s", apifyBaseURL, actorID, s.apiToken)
+// newRequest creates an Apify API request authenticated by the token. The
+// token goes in the Authorization header rather than the URL, so it can't
+// leak through the *url.Error of a failed request into the logs.
+func (s *ApifyScraper) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.apiToken)
+	return req, nil
+}
+
+func (s *ApifyScraper) startActorRun(ctx context.Context, actorID string, input map[string]interface{}) (string, error) {
+	url := fmt.Sprintf("%s/acts/%s/runs", s.baseURL, actorID)
+	if s.webhook != nil {
+		url += "?webhooks=" + neturl.QueryEscape(s.webhook.webhooksParam())
+	}
 
-	// Build input based on platform
-	input := s.buildInput(videoURL, platform)
 	body, _ := json.Marshal(input)
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
-	if err != nil {
-		return "", err
+	s.debugf("Apify: starting actor %s with input %s", actorID, body)
+
+	// Starting a run isn't idempotent: a 5xx or dropped connection may still
+	// have started one, so only rate limiting (429, honoring Retry-After) is retried
+	policy := retry.Policy{
+		MaxAttempts: maxRateLimitAttempts,
+		Initial:     initialRateLimitBackoff,
+		Jitter:      retry.DefaultPolicy.Jitter,
+		Retryable: func(err error) bool {
+			var statusErr *retry.StatusError
+			return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests
+		},
+		OnRetry: func(attempt int, err error, wait time.Duration) {
+			s.debugf("Apify: rate limited, retrying in %s", wait)
+		},
 	}
-	req.Header.Set("Content-Type", "application/json")
+	var runID string
+	err := retry.Do(ctx, policy, func() error {
+		req, err := s.newRequest(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.client.Do(req)
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		runID, err = parseRunResponse(resp)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
+	s.debugf("Apify: started run %s", runID)
+	return runID, nil
+}
 
+// parseRunResponse extracts the run ID from a "start actor run" response.
+func parseRunResponse(resp *http.Response) (string, error) {
 	if resp.StatusCode != http.StatusCreated {
-		respBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to start actor: status %d, body: %s", resp.StatusCode, string(respBody))
+		return "", fmt.Errorf("failed to start actor: %w", retry.NewStatusError(resp))
 	}
 
 	var result struct {
@@ -126,72 +398,183 @@ func (s *ApifyScraper) startActorRun(ctx context.Context, actorID, videoURL, pla
 }
 
 func (s *ApifyScraper) buildInput(videoURL, platform string) map[string]interface{} {
+	var input map[string]interface{}
 	switch platform {
 	case "youtube":
-		return map[string]interface{}{
+		input = map[string]interface{}{
 			"startUrls":  []map[string]string{{"url": videoURL}},
 			"maxResults": 1,
 		}
+		if s.withComments {
+			input["maxComments"] = s.commentsLimit()
+		}
 	case "tiktok":
-		return map[string]interface{}{
+		input = map[string]interface{}{
 			"postURLs":       []string{videoURL},
 			"resultsPerPage": 1,
 		}
+		if s.withComments {
+			input["commentsPerPost"] = s.commentsLimit()
+		}
 	default:
-		return map[string]interface{}{"url": videoURL}
+		input = map[string]interface{}{"url": videoURL}
+	}
+	if s.proxy != nil {
+		input["proxyConfiguration"] = s.proxy
+	}
+	return input
+}
+
+// commentsLimit returns the comment cap passed to the actor.
+func (s *ApifyScraper) commentsLimit() int {
+	if s.maxComments > 0 {
+		return s.maxComments
 	}
+	return defaultMaxComments
 }
 
-func (s *ApifyScraper) waitAndGetResults(ctx context.Context, runID string) ([]byte, error) {
-	// Poll for run completion
-	statusURL := fmt.Sprintf("%s/actor-runs/%s?token=%s", apifyBaseURL, runID, s.apiToken)
+// waitForRun waits for the run to succeed and returns its final status.
+func (s *ApifyScraper) waitForRun(ctx context.Context, runID string) (*runStatus, error) {
+	// Poll for run completion; with a webhook, polling is only a slow fallback
+	statusURL := fmt.Sprintf("%s/actor-runs/%s", s.baseURL, runID)
+	interval := s.polling.Min
+	adaptive := true
+	var notify <-chan runStatus
+	if s.webhook != nil {
+		var unsubscribe func()
+		notify, unsubscribe = s.webhook.receiver.subscribe(runID)
+		defer unsubscribe()
+		interval = webhookFallbackPollInterval
+		adaptive = false
+	}
+
+	// The run must leave READY within readyTimeout; nil once it has
+	started := time.Now()
+	var noProgress <-chan time.Time
+	if s.readyTimeout > 0 {
+		noProgress = s.after(s.readyTimeout)
+	}
 
+	lastStatus := ""
 	for {
+		var status runStatus
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
-		case <-time.After(3 * time.Second):
-		}
-
-		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, statusURL, nil)
-		resp, err := s.client.Do(req)
-		if err != nil {
-			return nil, err
+		case <-noProgress:
+			s.statusf("Apify: run %s didn't leave READY within %s, aborting", runID, s.readyTimeout)
+			s.abortRun(ctx, runID)
+			return nil, fmt.Errorf("%w: actor run %s didn't leave READY within %s", ports.ErrRunStalled, runID, s.readyTimeout)
+		case status = <-notify:
+		case <-s.after(interval):
+			polled, err := s.getRunStatus(ctx, statusURL)
+			if err != nil {
+				return nil, err
+			}
+			status = *polled
 		}
 
-		var status struct {
-			Data struct {
-				Status           string `json:"status"`
-				DefaultDatasetID string `json:"defaultDatasetId"`
-			} `json:"data"`
+		if status.Status != lastStatus {
+			s.statusf("Apify: run %s is %s (after %s)", runID, status.Status, time.Since(started).Round(time.Second))
 		}
-		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
-			resp.Body.Close()
-			return nil, err
+		if status.Status != "READY" {
+			noProgress = nil
 		}
-		resp.Body.Close()
-
-		switch status.Data.Status {
+		switch status.Status {
 		case "SUCCEEDED":
-			return s.getDatasetItems(ctx, status.Data.DefaultDatasetID)
+			return &status, nil
 		case "FAILED", "ABORTED", "TIMED-OUT":
-			return nil, fmt.Errorf("actor run failed with status: %s", status.Data.Status)
+			return nil, fmt.Errorf("actor run failed with status: %s", status.Status)
 		}
 		// Still running, continue polling
+		if adaptive {
+			if status.Status != lastStatus {
+				interval = s.polling.Min
+			} else {
+				interval = s.polling.next(interval)
+			}
+		}
+		lastStatus = status.Status
 	}
 }
 
-func (s *ApifyScraper) getDatasetItems(ctx context.Context, datasetID string) ([]byte, error) {
-	url := fmt.Sprintf("%s/datasets/%s/items?token=%s", apifyBaseURL, datasetID, s.apiToken)
+func (s *ApifyScraper) getRunStatus(ctx context.Context, statusURL string) (*runStatus, error) {
+	var status struct {
+		Data runStatus `json:"data"`
+	}
+	err := retry.Do(ctx, s.readPolicy("run status"), func() error {
+		req, err := s.newRequest(ctx, http.MethodGet, statusURL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return retry.NewStatusError(resp)
+		}
+		return json.NewDecoder(resp.Body).Decode(&status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &status.Data, nil
+}
 
-	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// readPolicy is the retry policy for Apify reads (run status, dataset
+// items), which are safe to repeat.
+func (s *ApifyScraper) readPolicy(what string) retry.Policy {
+	policy := retry.DefaultPolicy
+	policy.OnRetry = func(attempt int, err error, wait time.Duration) {
+		s.debugf("Apify: fetching %s failed (%v), retrying in %s", what, err, wait)
+	}
+	return policy
+}
+
+// abortRun asks Apify to abort a run we've given up on, so it isn't left to
+// start and bill later. Failures are only logged.
+func (s *ApifyScraper) abortRun(ctx context.Context, runID string) {
+	url := fmt.Sprintf("%s/actor-runs/%s/abort", s.baseURL, runID)
+	req, err := s.newRequest(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		s.debugf("Apify: failed to abort run %s: %v", runID, err)
+		return
+	}
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return nil, err
+		s.debugf("Apify: failed to abort run %s: %v", runID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s.debugf("Apify: failed to abort run %s: status %d", runID, resp.StatusCode)
 	}
-	defer resp.Body.Close()
+}
+
+func (s *ApifyScraper) getDatasetItems(ctx context.Context, datasetID string) ([]byte, error) {
+	url := fmt.Sprintf("%s/datasets/%s/items", s.baseURL, datasetID)
+	s.debugf("Apify: fetching dataset %s", datasetID)
 
-	return io.ReadAll(resp.Body)
+	var items []byte
+	err := retry.Do(ctx, s.readPolicy("dataset "+datasetID), func() error {
+		req, err := s.newRequest(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return retry.NewStatusError(resp)
+		}
+		items, err = io.ReadAll(resp.Body)
+		return err
+	})
+	return items, err
 }
 
 func (s *ApifyScraper) extractVideoURL(rawData []byte, platform string) (string, error) {
@@ -206,6 +589,13 @@ func (s *ApifyScraper) extractVideoURL(rawData []byte, platform string) (string,
 
 	item := items[0]
 
+	formats, _ := item["formats"].([]interface{})
+	if s.maxHeight > 0 || s.maxFPS > 0 {
+		if url := selectFormat(formats, s.maxHeight, s.maxFPS); url != "" {
+			return url, nil
+		}
+	}
+
 	// For other platforms, try common video URL field names
 	fieldNames := []string{"videoUrl", "video_url", "downloadUrl", "download_url", "videoPlayUrl"}
 	for _, field := range fieldNames {
@@ -215,20 +605,115 @@ func (s *ApifyScraper) extractVideoURL(rawData []byte, platform string) (string,
 	}
 
 	// Try extracting from 'formats' array (typical for some YouTube scrapers)
-	if formats, ok := item["formats"].([]interface{}); ok && len(formats) > 0 {
-		if format, ok := formats[len(formats)-1].(map[string]interface{}); ok {
-			if url, ok := format["url"].(string); ok && url != "" {
-				return url, nil
+	if url := selectFormat(formats, 0, 0); url != "" {
+		return url, nil
+	}
+
+	return "", fmt.Errorf("could not find video URL in response")
+}
+
+// extractSizeHints parses the video duration and, when available, the file
+// size from the first dataset item. YouTube items carry "duration" as
+// "HH:MM:SS"; TikTok items carry videoMeta.duration in seconds.
+func extractSizeHints(rawData []byte) (durationSeconds float64, estimatedBytes int64) {
+	var items []map[string]interface{}
+	if err := json.Unmarshal(rawData, &items); err != nil || len(items) == 0 {
+		return 0, 0
+	}
+	item := items[0]
+
+	durationSeconds = parseDuration(item["duration"])
+	if meta, ok := item["videoMeta"].(map[string]interface{}); ok {
+		if durationSeconds == 0 {
+			durationSeconds = parseDuration(meta["duration"])
+		}
+		if size, ok := meta["size"].(float64); ok {
+			estimatedBytes = int64(size)
+		}
+	}
+	for _, field := range []string{"filesize", "fileSize", "filesize_approx"} {
+		if size, ok := item[field].(float64); ok && estimatedBytes == 0 {
+			estimatedBytes = int64(size)
+		}
+	}
+	return durationSeconds, estimatedBytes
+}
+
+// parseDuration accepts seconds as a number or numeric string, or "[HH:]MM:SS".
+func parseDuration(v interface{}) float64 {
+	switch d := v.(type) {
+	case float64:
+		return d
+	case string:
+		if secs, err := strconv.ParseFloat(d, 64); err == nil {
+			return secs
+		}
+		var total float64
+		for _, part := range strings.Split(d, ":") {
+			n, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return 0
 			}
+			total = total*60 + n
 		}
+		return total
 	}
+	return 0
+}
 
-	return "", fmt.Errorf("could not find video URL in response")
+// extractComments returns the raw comments array from the first dataset item.
+// Returns nil when the actor didn't include comments.
+func extractComments(rawData []byte) []byte {
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(rawData, &items); err != nil || len(items) == 0 {
+		return nil
+	}
+	for _, field := range []string{"comments", "topComments", "commentsList"} {
+		if raw, ok := items[0][field]; ok && len(raw) > 0 && string(raw) != "null" {
+			return raw
+		}
+	}
+	return nil
+}
+
+// extractMusic returns the TikTok item's musicMeta track, or nil if the
+// item has none. The URL is empty when the actor didn't provide playUrl.
+func extractMusic(rawData []byte) *ports.MusicTrack {
+	var items []struct {
+		MusicMeta *struct {
+			PlayURL       string `json:"playUrl"`
+			MusicName     string `json:"musicName"`
+			MusicAuthor   string `json:"musicAuthor"`
+			MusicAlbum    string `json:"musicAlbum"`
+			MusicOriginal bool   `json:"musicOriginal"`
+		} `json:"musicMeta"`
+	}
+	if err := json.Unmarshal(rawData, &items); err != nil || len(items) == 0 || items[0].MusicMeta == nil {
+		return nil
+	}
+	meta := items[0].MusicMeta
+	return &ports.MusicTrack{
+		URL:      meta.PlayURL,
+		Title:    meta.MusicName,
+		Author:   meta.MusicAuthor,
+		Album:    meta.MusicAlbum,
+		Original: meta.MusicOriginal,
+	}
+}
+
+// isEmptyDataset reports whether the dataset response contains zero items.
+func isEmptyDataset(rawData []byte) bool {
+	var items []json.RawMessage
+	if err := json.Unmarshal(rawData, &items); err != nil {
+		return false
+	}
+	return len(items) == 0
 }
 
 func detectPlatform(url string) string {
 	lowerURL := strings.ToLower(url)
-	if strings.Contains(lowerURL, "youtube.com") || strings.Contains(lowerURL, "youtu.be") {
+	if strings.Contains(lowerURL, "youtube.com") || strings.Contains(lowerURL, "youtu.be") ||
+		strings.Contains(lowerURL, "youtube-nocookie.com") {
 		return "youtube"
 	}
 	if strings.Contains(lowerURL, "tiktok.com") {
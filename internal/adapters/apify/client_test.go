@@ -0,0 +1,149 @@
+package apify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roundTripFunc lets a function serve as an http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func jsonResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// instantAfter is a fake clock whose timers fire immediately.
+func instantAfter(time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	ch <- time.Now()
+	return ch
+}
+
+func TestNewApifyScraperWithClientUsesInjectedClient(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		paths = append(paths, req.Method+" "+req.URL.Path)
+		mu.Unlock()
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/runs"):
+			return jsonResponse(http.StatusCreated, `{"data":{"id":"run1"}}`), nil
+		case strings.HasSuffix(req.URL.Path, "/actor-runs/run1"):
+			return jsonResponse(http.StatusOK, `{"data":{"id":"run1","status":"SUCCEEDED","defaultDatasetId":"ds1"}}`), nil
+		case strings.HasSuffix(req.URL.Path, "/datasets/ds1/items"):
+			return jsonResponse(http.StatusOK, `[{"id":"abc","title":"t"}]`), nil
+		}
+		return jsonResponse(http.StatusNotFound, `{}`), nil
+	})}
+
+	s := NewApifyScraperWithClient("token", client)
+	s.after = instantAfter
+	result, err := s.Scrape(context.Background(), "https://www.youtube.com/watch?v=abc")
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if result.RunID != "run1" || result.DatasetID != "ds1" {
+		t.Errorf("run/dataset = %q/%q, want run1/ds1", result.RunID, result.DatasetID)
+	}
+
+	want := []string{
+		"POST /v2/acts/" + youtubeMetadataActorID + "/runs",
+		"GET /v2/actor-runs/run1",
+		"GET /v2/datasets/ds1/items",
+	}
+	if strings.Join(paths, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests through the injected client:\n%s\nwant:\n%s", strings.Join(paths, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTokenStaysOutOfURLs(t *testing.T) {
+	const token = "apify_api_secret"
+	var auth []string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if strings.Contains(req.URL.String(), token) {
+			t.Errorf("token in request URL %s", req.URL)
+		}
+		auth = append(auth, req.Header.Get("Authorization"))
+		return nil, errors.New("connection reset")
+	})}
+
+	var logged []string
+	s := NewApifyScraperWithClient(token, client, WithDebugLog(func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}))
+	s.abortRun(context.Background(), "run1")
+
+	if len(auth) != 1 || auth[0] != "Bearer "+token {
+		t.Errorf("Authorization = %q, want the bearer token", auth)
+	}
+	if len(logged) == 0 {
+		t.Fatal("failed abort not logged")
+	}
+	for _, line := range logged {
+		if strings.Contains(line, token) {
+			t.Errorf("token leaked into the log: %s", line)
+		}
+	}
+}
+
+func TestExtractSizeHints(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         string
+		wantDuration float64
+		wantBytes    int64
+	}{
+		{"youtube", `[{"title":"t","duration":"01:02:03"}]`, 3723, 0},
+		{"youtube minutes", `[{"duration":"4:05"}]`, 245, 0},
+		{"tiktok", `[{"videoMeta":{"duration":15,"size":2500000}}]`, 15, 2500000},
+		{"seconds string", `[{"duration":"12.5"}]`, 12.5, 0},
+		{"top-level duration wins", `[{"duration":30,"videoMeta":{"duration":15}}]`, 30, 0},
+		{"filesize", `[{"duration":10,"filesize":1000}]`, 10, 1000},
+		{"fileSize", `[{"fileSize":2000}]`, 0, 2000},
+		{"filesize_approx", `[{"filesize_approx":3000}]`, 0, 3000},
+		{"videoMeta size wins", `[{"videoMeta":{"size":500},"filesize":1000}]`, 0, 500},
+		{"bad duration", `[{"duration":"live"}]`, 0, 0},
+		{"none", `[{"id":"1"}]`, 0, 0},
+		{"empty dataset", `[]`, 0, 0},
+		{"not json", `oops`, 0, 0},
+	}
+	for _, tt := range tests {
+		duration, bytes := extractSizeHints([]byte(tt.data))
+		if duration != tt.wantDuration || bytes != tt.wantBytes {
+			t.Errorf("%s: extractSizeHints = %v, %d; want %v, %d", tt.name, duration, bytes, tt.wantDuration, tt.wantBytes)
+		}
+	}
+}
+
+func TestIsEmptyDataset(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{`[]`, true},
+		{` [ ] `, true},
+		{`[{}]`, false},
+		{`[{"id":"1"}]`, false},
+		{`{"error":"not a list"}`, false},
+		{`not json`, false},
+	}
+	for _, tt := range tests {
+		if got := isEmptyDataset([]byte(tt.raw)); got != tt.want {
+			t.Errorf("isEmptyDataset(%s) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
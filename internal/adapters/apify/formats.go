@@ -0,0 +1,88 @@
+package apify
+
+import "strings"
+
+// selectFormat returns the URL of the best entry of an item's "formats"
+// array within the caps (0 = no cap), or "" if none qualifies. Like
+// yt-dlp's "b[height<=H][fps<=?F]", an entry must list its height to pass a
+// height cap, while one without a frame rate passes an fps cap.
+//
+// Audio-only entries are skipped. Progressive entries (video with audio)
+// beat video-only ones, then taller beats shorter, then higher frame rates,
+// then MP4 over other containers. Among equal entries the later one wins,
+// as actors list formats from worst to best.
+func selectFormat(formats []interface{}, maxHeight, maxFPS int) string {
+	var best map[string]interface{}
+	for _, entry := range formats {
+		format, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if url, _ := format["url"].(string); url == "" || isAudioOnly(format) {
+			continue
+		}
+		height, fps := formatNumber(format, "height"), formatNumber(format, "fps")
+		if maxHeight > 0 && (height <= 0 || height > float64(maxHeight)) {
+			continue
+		}
+		if maxFPS > 0 && fps > float64(maxFPS) {
+			continue
+		}
+		if best == nil || !betterFormat(best, format) {
+			best = format
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	url, _ := best["url"].(string)
+	return url
+}
+
+// betterFormat reports whether a ranks strictly above b (see selectFormat).
+func betterFormat(a, b map[string]interface{}) bool {
+	if pa, pb := hasAudio(a), hasAudio(b); pa != pb {
+		return pa
+	}
+	if ha, hb := formatNumber(a, "height"), formatNumber(b, "height"); ha != hb {
+		return ha > hb
+	}
+	if fa, fb := formatNumber(a, "fps"), formatNumber(b, "fps"); fa != fb {
+		return fa > fb
+	}
+	return isMP4(a) && !isMP4(b)
+}
+
+// formatNumber returns a numeric field of a format entry, or 0.
+func formatNumber(format map[string]interface{}, field string) float64 {
+	n, _ := format[field].(float64)
+	return n
+}
+
+// hasAudio reports whether an entry may carry audio: unless its acodec is
+// "none" or hasAudio is false, it's taken to.
+func hasAudio(format map[string]interface{}) bool {
+	if acodec, _ := format["acodec"].(string); acodec == "none" {
+		return false
+	}
+	if audio, ok := format["hasAudio"].(bool); ok {
+		return audio
+	}
+	return true
+}
+
+// isAudioOnly reports whether an entry has no video track.
+func isAudioOnly(format map[string]interface{}) bool {
+	if vcodec, _ := format["vcodec"].(string); vcodec == "none" {
+		return true
+	}
+	mimeType, _ := format["mimeType"].(string)
+	return strings.HasPrefix(mimeType, "audio/")
+}
+
+// isMP4 reports whether an entry is in an MP4 container.
+func isMP4(format map[string]interface{}) bool {
+	ext, _ := format["ext"].(string)
+	mimeType, _ := format["mimeType"].(string)
+	return ext == "mp4" || strings.Contains(mimeType, "mp4")
+}
@@ -0,0 +1,101 @@
+package apify
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// formatsJSON lists formats from worst to best, as actors do.
+const formatsJSON = `[
+	{"url": "https://cdn/audio.m4a", "vcodec": "none", "acodec": "mp4a"},
+	{"url": "https://cdn/360-30.mp4", "height": 360, "fps": 30, "ext": "mp4"},
+	{"url": "https://cdn/720-30.webm", "height": 720, "fps": 30, "ext": "webm"},
+	{"url": "https://cdn/720-30.mp4", "height": 720, "fps": 30, "ext": "mp4"},
+	{"url": "https://cdn/720-60.webm", "height": 720, "fps": 60, "ext": "webm"},
+	{"url": "https://cdn/1080-30.mp4", "height": 1080, "fps": 30, "ext": "mp4"},
+	{"url": "https://cdn/1080-60.mp4", "height": 1080, "fps": 60, "ext": "mp4"},
+	{"url": "https://cdn/2160-60-video-only.mp4", "height": 2160, "fps": 60, "ext": "mp4", "acodec": "none"},
+	{"url": "", "height": 4320, "fps": 60}
+]`
+
+func TestSelectFormat(t *testing.T) {
+	var formats []interface{}
+	if err := json.Unmarshal([]byte(formatsJSON), &formats); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name              string
+		formats           []interface{}
+		maxHeight, maxFPS int
+		want              string
+	}{
+		{"no caps prefers progressive", formats, 0, 0, "https://cdn/1080-60.mp4"},
+		{"height cap", formats, 720, 0, "https://cdn/720-60.webm"},
+		{"fps cap", formats, 0, 30, "https://cdn/1080-30.mp4"},
+		{"both caps prefer mp4", formats, 720, 30, "https://cdn/720-30.mp4"},
+		{"between heights", formats, 1000, 60, "https://cdn/720-60.webm"},
+		{"nothing qualifies", formats, 240, 0, ""},
+		{"no formats", nil, 720, 30, ""},
+		{
+			"height cap needs a height, fps cap doesn't need a rate",
+			[]interface{}{
+				map[string]interface{}{"url": "https://cdn/unknown.mp4"},
+				map[string]interface{}{"url": "https://cdn/480.mp4", "height": 480.0},
+			},
+			720, 30, "https://cdn/480.mp4",
+		},
+		{
+			"equal entries keep the later",
+			[]interface{}{
+				map[string]interface{}{"url": "https://cdn/first.mp4"},
+				map[string]interface{}{"url": "https://cdn/last.mp4"},
+				"not a format",
+			},
+			0, 0, "https://cdn/last.mp4",
+		},
+		{
+			"video-only when nothing progressive fits",
+			[]interface{}{
+				map[string]interface{}{"url": "https://cdn/480-video.mp4", "height": 480.0, "hasAudio": false},
+				map[string]interface{}{"url": "https://cdn/1080.mp4", "height": 1080.0},
+				map[string]interface{}{"url": "https://cdn/audio.webm", "mimeType": "audio/webm"},
+			},
+			720, 0, "https://cdn/480-video.mp4",
+		},
+	}
+	for _, tt := range tests {
+		if got := selectFormat(tt.formats, tt.maxHeight, tt.maxFPS); got != tt.want {
+			t.Errorf("%s: selectFormat(%d, %d) = %q, want %q", tt.name, tt.maxHeight, tt.maxFPS, got, tt.want)
+		}
+	}
+}
+
+// Caps pick from the formats over the item's video URL; without caps, or
+// when no format fits, the video URL wins.
+func TestExtractVideoURLFormats(t *testing.T) {
+	withURL := `[{"videoUrl": "https://cdn/video.mp4", "formats": ` + formatsJSON + `}]`
+	formatsOnly := `[{"formats": ` + formatsJSON + `}]`
+	tests := []struct {
+		name              string
+		raw               string
+		maxHeight, maxFPS int
+		want              string
+	}{
+		{"no caps", withURL, 0, 0, "https://cdn/video.mp4"},
+		{"capped", withURL, 720, 30, "https://cdn/720-30.mp4"},
+		{"fps cap alone", withURL, 0, 30, "https://cdn/1080-30.mp4"},
+		{"no format fits", withURL, 240, 0, "https://cdn/video.mp4"},
+		{"formats only", formatsOnly, 0, 0, "https://cdn/1080-60.mp4"},
+	}
+	for _, tt := range tests {
+		s := &ApifyScraper{maxHeight: tt.maxHeight, maxFPS: tt.maxFPS}
+		got, err := s.extractVideoURL([]byte(tt.raw), "youtube")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: video URL = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
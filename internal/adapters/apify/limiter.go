@@ -0,0 +1,74 @@
+package apify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"scrapeanddown/internal/retry"
+)
+
+// Limiter bounds how many actor runs are in flight at once and spaces out
+// run starts. A single Limiter can be shared by several ApifyScraper
+// instances so they respect the same account limits.
+type Limiter struct {
+	slots    chan struct{}
+	interval time.Duration
+
+	mu        sync.Mutex
+	nextStart time.Time
+}
+
+// NewLimiter creates a Limiter allowing maxConcurrent runs in flight, with
+// consecutive starts at least interval apart. maxConcurrent <= 0 means no
+// concurrency cap; interval <= 0 disables spacing.
+func NewLimiter(maxConcurrent int, interval time.Duration) *Limiter {
+	l := &Limiter{interval: interval}
+	if maxConcurrent > 0 {
+		l.slots = make(chan struct{}, maxConcurrent)
+	}
+	return l
+}
+
+// Acquire blocks until a run slot is free and the start interval has passed.
+// The caller must call Release when the run has finished.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if err := l.waitForStart(ctx); err != nil {
+		l.Release()
+		return err
+	}
+	return nil
+}
+
+// Release frees the slot taken by Acquire.
+func (l *Limiter) Release() {
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// waitForStart reserves the next start time and sleeps until it arrives.
+func (l *Limiter) waitForStart(ctx context.Context) error {
+	if l.interval <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	start := l.nextStart
+	if start.Before(now) {
+		start = now
+	}
+	l.nextStart = start.Add(l.interval)
+	l.mu.Unlock()
+
+	return retry.Sleep(ctx, start.Sub(now))
+}
@@ -0,0 +1,140 @@
+package apify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Several scrapers sharing a limit of 2 never have more than 2 runs in
+// flight, from the start request until the dataset is fetched.
+func TestLimiterBoundsConcurrentRuns(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	api := &fakeAPI{
+		start: func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			reply(http.StatusCreated, `{"data":{"id":"run1"}}`)(w, r)
+		},
+		status: reply(http.StatusOK, `{"data":{"id":"run1","status":"SUCCEEDED","defaultDatasetId":"ds1"}}`),
+		dataset: func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			reply(http.StatusOK, `[{"id":"1","videoUrl":"https://cdn/v.mp4"}]`)(w, r)
+		},
+	}
+	limiter := NewLimiter(2, 0)
+	scrapers := []*ApifyScraper{newServerScraper(t, api, WithLimiter(limiter)), newServerScraper(t, api, WithLimiter(limiter))}
+
+	var wg sync.WaitGroup
+	for i := range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := scrapers[i%2].Scrape(context.Background(), tiktokURL); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxInFlight != 2 {
+		t.Errorf("%d runs were in flight at once, want 2", maxInFlight)
+	}
+}
+
+func TestLimiterSpacesStarts(t *testing.T) {
+	l := NewLimiter(0, 30*time.Millisecond)
+	start := time.Now()
+	for range 3 {
+		if err := l.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		l.Release()
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("3 starts took %s, want them at least 30ms apart", elapsed)
+	}
+}
+
+func TestLimiterAcquireCanceled(t *testing.T) {
+	l := NewLimiter(1, 0)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire with every slot taken = %v, want the context's error", err)
+	}
+
+	// A start canceled while waiting for its interval gives its slot back
+	l = NewLimiter(1, time.Hour)
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	l.Release()
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire before the interval passed = %v, want the context's error", err)
+	}
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		t.Error("canceled Acquire kept its slot")
+	}
+}
+
+// A rate-limited start is retried after the wait Retry-After asks for; other
+// failures aren't, as they may have started a run.
+func TestStartActorRunRateLimited(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantStarts int
+		wantErr    bool
+	}{
+		{"rate limited", http.StatusTooManyRequests, 2, false},
+		{"server error", http.StatusInternalServerError, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var starts []time.Time
+			api := &fakeAPI{
+				start: func(w http.ResponseWriter, r *http.Request) {
+					mu.Lock()
+					starts = append(starts, time.Now())
+					first := len(starts) == 1
+					mu.Unlock()
+					if first {
+						w.Header().Set("Retry-After", "1")
+						reply(tt.status, `{"error":{"type":"rate-limit-exceeded"}}`)(w, r)
+						return
+					}
+					reply(http.StatusCreated, `{"data":{"id":"run1"}}`)(w, r)
+				},
+				status:  reply(http.StatusOK, `{"data":{"id":"run1","status":"SUCCEEDED","defaultDatasetId":"ds1"}}`),
+				dataset: reply(http.StatusOK, `[{"id":"1","videoUrl":"https://cdn/v.mp4"}]`),
+			}
+			_, err := newServerScraper(t, api).Scrape(context.Background(), tiktokURL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scrape err = %v, want an error %v", err, tt.wantErr)
+			}
+			if len(starts) != tt.wantStarts {
+				t.Fatalf("%d start requests, want %d", len(starts), tt.wantStarts)
+			}
+			if len(starts) == 2 && starts[1].Sub(starts[0]) < time.Second {
+				t.Errorf("retried after %s, want the 1s Retry-After", starts[1].Sub(starts[0]))
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package apify
+
+import "time"
+
+// PollConfig controls how often a run's status is polled. The interval
+// starts at Min and is multiplied by Factor after each poll that finds the
+// status unchanged, up to Max; a status change (e.g. READY to RUNNING)
+// resets it to Min. Zero fields take the defaults.
+type PollConfig struct {
+	Min    time.Duration
+	Max    time.Duration
+	Factor float64
+}
+
+// defaultPolling suits both quick video scrapes and long profile scrapes.
+var defaultPolling = PollConfig{Min: time.Second, Max: 15 * time.Second, Factor: 1.5}
+
+// WithPolling sets the status polling strategy.
+func WithPolling(p PollConfig) Option {
+	return func(s *ApifyScraper) {
+		s.polling = p.withDefaults()
+	}
+}
+
+// WithReadyTimeout fails runs that are still READY (queued, or the actor
+// still building or cold-starting) after timeout with ports.ErrRunStalled,
+// aborting them. A timeout of 0 waits indefinitely.
+func WithReadyTimeout(timeout time.Duration) Option {
+	return func(s *ApifyScraper) {
+		s.readyTimeout = timeout
+	}
+}
+
+// withDefaults fills zero fields from defaultPolling and keeps Max >= Min.
+func (p PollConfig) withDefaults() PollConfig {
+	if p.Min <= 0 {
+		p.Min = defaultPolling.Min
+	}
+	if p.Max <= 0 {
+		p.Max = defaultPolling.Max
+	}
+	if p.Max < p.Min {
+		p.Max = p.Min
+	}
+	if p.Factor < 1 {
+		p.Factor = defaultPolling.Factor
+	}
+	return p
+}
+
+// next returns the interval following current.
+func (p PollConfig) next(current time.Duration) time.Duration {
+	next := time.Duration(float64(current) * p.Factor)
+	if next > p.Max {
+		return p.Max
+	}
+	return next
+}
@@ -0,0 +1,136 @@
+package apify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestPollConfigWithDefaults(t *testing.T) {
+	tests := []struct {
+		in, want PollConfig
+	}{
+		{PollConfig{}, defaultPolling},
+		{PollConfig{Min: 2 * time.Second}, PollConfig{Min: 2 * time.Second, Max: 15 * time.Second, Factor: 1.5}},
+		{PollConfig{Min: 30 * time.Second, Max: 10 * time.Second, Factor: 2}, PollConfig{Min: 30 * time.Second, Max: 30 * time.Second, Factor: 2}},
+		{PollConfig{Min: time.Second, Max: 5 * time.Second, Factor: 0.5}, PollConfig{Min: time.Second, Max: 5 * time.Second, Factor: 1.5}},
+	}
+	for _, tt := range tests {
+		if got := tt.in.withDefaults(); got != tt.want {
+			t.Errorf("%+v.withDefaults() = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+// The interval grows while the status stays the same, up to Max, and drops
+// back to Min when it changes.
+func TestPollingIntervals(t *testing.T) {
+	api := &fakeAPI{
+		start: reply(http.StatusCreated, `{"data":{"id":"run1"}}`),
+		status: replies(
+			`{"data":{"id":"run1","status":"READY"}}`,
+			`{"data":{"id":"run1","status":"READY"}}`,
+			`{"data":{"id":"run1","status":"RUNNING"}}`,
+			`{"data":{"id":"run1","status":"RUNNING"}}`,
+			`{"data":{"id":"run1","status":"RUNNING"}}`,
+			`{"data":{"id":"run1","status":"RUNNING"}}`,
+			`{"data":{"id":"run1","status":"SUCCEEDED","defaultDatasetId":"ds1"}}`,
+		),
+		dataset: reply(http.StatusOK, `[{"id":"1","videoUrl":"https://cdn/v.mp4"}]`),
+	}
+	s := newServerScraper(t, api, WithPolling(PollConfig{Min: time.Second, Max: 4 * time.Second, Factor: 2}))
+	var waits []time.Duration
+	s.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		return instantAfter(d)
+	}
+
+	if _, err := s.Scrape(context.Background(), tiktokURL); err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{1 * time.Second, 1 * time.Second, 2 * time.Second, 1 * time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}
+	if !slices.Equal(waits, want) {
+		t.Errorf("waited %v, want %v", waits, want)
+	}
+}
+
+// stallingAfter is an after function whose polls fire at once and whose
+// ready timeout fires once more than polls polls have been waited for.
+func stallingAfter(readyTimeout time.Duration, polls int) func(time.Duration) <-chan time.Time {
+	stall := make(chan time.Time)
+	waited := 0
+	return func(d time.Duration) <-chan time.Time {
+		if d == readyTimeout {
+			return stall
+		}
+		if waited++; waited == polls+1 {
+			close(stall)
+		}
+		return instantAfter(d)
+	}
+}
+
+func TestReadyTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		statuses    []string
+		wantStalled bool
+		wantLog     []string
+	}{
+		{
+			"stuck in READY",
+			[]string{"READY"},
+			true,
+			[]string{"run run1 is READY", "run run1 didn't leave READY within 1h0m0s, aborting"},
+		},
+		{
+			"cold start, then runs",
+			[]string{"READY", "READY", "RUNNING", "RUNNING", "RUNNING", "RUNNING", "SUCCEEDED"},
+			false,
+			[]string{"run run1 is READY", "run run1 is RUNNING", "run run1 is SUCCEEDED"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies []string
+			for _, status := range tt.statuses {
+				bodies = append(bodies, `{"data":{"id":"run1","status":"`+status+`","defaultDatasetId":"ds1"}}`)
+			}
+			api := &fakeAPI{
+				start:   reply(http.StatusCreated, `{"data":{"id":"run1"}}`),
+				status:  replies(bodies...),
+				dataset: reply(http.StatusOK, `[{"id":"1","videoUrl":"https://cdn/v.mp4"}]`),
+			}
+			var logged []string
+			logf := func(format string, args ...interface{}) { logged = append(logged, fmt.Sprintf(format, args...)) }
+			s := newServerScraper(t, api, WithReadyTimeout(time.Hour), WithStatusLog(logf))
+			s.after = stallingAfter(time.Hour, 3)
+
+			_, err := s.Scrape(context.Background(), tiktokURL)
+			if stalled := errors.Is(err, ports.ErrRunStalled); stalled != tt.wantStalled {
+				t.Fatalf("Scrape err = %v, want ErrRunStalled %v", err, tt.wantStalled)
+			}
+			if !tt.wantStalled && err != nil {
+				t.Fatal(err)
+			}
+			if aborted := slices.Contains(api.requests, "POST /custom/v2/actor-runs/run1/abort"); aborted != tt.wantStalled {
+				t.Errorf("aborted the run %v, want %v: %v", aborted, tt.wantStalled, api.requests)
+			}
+			if len(logged) != len(tt.wantLog) {
+				t.Fatalf("logged %q, want %q", logged, tt.wantLog)
+			}
+			for i, want := range tt.wantLog {
+				if !strings.Contains(logged[i], want) {
+					t.Errorf("log line %d = %q, want %q", i, logged[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,420 @@
+package apify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+	"scrapeanddown/internal/retry"
+)
+
+const tiktokURL = "https://www.tiktok.com/@user/video/1"
+
+// fakeAPI is an httptest server playing the Apify API under /custom/v2, so
+// requests that ignore the configured base URL miss it. Each handler field
+// answers one endpoint; nil ones answer 404.
+type fakeAPI struct {
+	start   http.HandlerFunc
+	status  http.HandlerFunc
+	dataset http.HandlerFunc
+
+	mu       sync.Mutex
+	requests []string
+	input    map[string]interface{}
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, `{"error":{"type":"token-not-provided"}}`, http.StatusUnauthorized)
+		return
+	}
+
+	var handler http.HandlerFunc
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/custom/v2/acts/"+tiktokActorID+"/runs":
+		f.mu.Lock()
+		json.NewDecoder(r.Body).Decode(&f.input)
+		f.mu.Unlock()
+		handler = f.start
+	case r.Method == http.MethodGet && r.URL.Path == "/custom/v2/actor-runs/run1":
+		handler = f.status
+	case r.Method == http.MethodGet && r.URL.Path == "/custom/v2/datasets/ds1/items":
+		handler = f.dataset
+	}
+	if handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+	handler(w, r)
+}
+
+// reply answers every request with status and body.
+func reply(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+// replies answers successive requests with bodies in turn, repeating the
+// last one.
+func replies(bodies ...string) http.HandlerFunc {
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		body := bodies[0]
+		if len(bodies) > 1 {
+			bodies = bodies[1:]
+		}
+		mu.Unlock()
+		reply(http.StatusOK, body)(w, r)
+	}
+}
+
+func newServerScraper(t *testing.T, api *fakeAPI, opts ...Option) *ApifyScraper {
+	t.Helper()
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	s, err := NewApifyScraperWithConfig(Config{Token: "token", BaseURL: srv.URL + "/custom/v2/"}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.after = instantAfter
+	return s
+}
+
+func TestScrapeAgainstServer(t *testing.T) {
+	api := &fakeAPI{
+		start: reply(http.StatusCreated, `{"data":{"id":"run1"}}`),
+		status: replies(
+			`{"data":{"id":"run1","status":"READY"}}`,
+			`{"data":{"id":"run1","status":"RUNNING"}}`,
+			`{"data":{"id":"run1","status":"SUCCEEDED","defaultDatasetId":"ds1","stats":{"computeUnits":0.5},"usageTotalUsd":0.2}}`,
+		),
+		dataset: reply(http.StatusOK, `[{"id":"1","videoMeta":{"duration":12,"size":3400},"videoUrl":"https://cdn/v.mp4"}]`),
+	}
+	s := newServerScraper(t, api)
+
+	result, err := s.Scrape(context.Background(), tiktokURL)
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if result.VideoURL != "https://cdn/v.mp4" || result.RunID != "run1" || result.DatasetID != "ds1" {
+		t.Errorf("video/run/dataset = %q/%q/%q, want https://cdn/v.mp4/run1/ds1", result.VideoURL, result.RunID, result.DatasetID)
+	}
+	if result.ComputeUnits != 0.5 || result.CostUSD != 0.2 {
+		t.Errorf("usage = %v CU, $%v, want 0.5 CU, $0.2", result.ComputeUnits, result.CostUSD)
+	}
+	if result.DurationSeconds != 12 || result.EstimatedBytes != 3400 {
+		t.Errorf("size hints = %vs, %d bytes, want 12s, 3400 bytes", result.DurationSeconds, result.EstimatedBytes)
+	}
+	if urls, _ := api.input["postURLs"].([]interface{}); len(urls) != 1 || urls[0] != tiktokURL {
+		t.Errorf("run input postURLs = %v, want [%s]", api.input["postURLs"], tiktokURL)
+	}
+
+	want := []string{
+		"POST /custom/v2/acts/" + tiktokActorID + "/runs",
+		"GET /custom/v2/actor-runs/run1",
+		"GET /custom/v2/actor-runs/run1",
+		"GET /custom/v2/actor-runs/run1",
+		"GET /custom/v2/datasets/ds1/items",
+	}
+	if got := strings.Join(api.requests, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("requests:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+}
+
+func TestScrapeServerErrors(t *testing.T) {
+	succeeded := reply(http.StatusOK, `{"data":{"id":"run1","status":"SUCCEEDED","defaultDatasetId":"ds1"}}`)
+	tests := []struct {
+		name     string
+		api      *fakeAPI
+		check    func(error) bool
+		requests int
+	}{
+		{
+			name:     "start rejected",
+			api:      &fakeAPI{start: reply(http.StatusForbidden, `{"error":{"type":"insufficient-permissions"}}`)},
+			check:    func(err error) bool { return strings.Contains(err.Error(), "403") },
+			requests: 1,
+		},
+		{
+			name: "start not retried on server error",
+			api:  &fakeAPI{start: reply(http.StatusInternalServerError, `{}`)},
+			check: func(err error) bool {
+				return strings.Contains(err.Error(), "failed to start actor run") && strings.Contains(err.Error(), "500")
+			},
+			requests: 1,
+		},
+		{
+			name: "run failed",
+			api: &fakeAPI{
+				start:  reply(http.StatusCreated, `{"data":{"id":"run1"}}`),
+				status: reply(http.StatusOK, `{"data":{"id":"run1","status":"FAILED"}}`),
+			},
+			check:    func(err error) bool { return strings.Contains(err.Error(), "status: FAILED") },
+			requests: 2,
+		},
+		{
+			name: "status not found",
+			api: &fakeAPI{
+				start: reply(http.StatusCreated, `{"data":{"id":"run1"}}`),
+			},
+			check:    func(err error) bool { return strings.Contains(err.Error(), "404") },
+			requests: 2,
+		},
+		{
+			name: "dataset not found",
+			api: &fakeAPI{
+				start:  reply(http.StatusCreated, `{"data":{"id":"run1"}}`),
+				status: succeeded,
+			},
+			check:    func(err error) bool { return strings.Contains(err.Error(), "404") },
+			requests: 3,
+		},
+		{
+			name: "empty dataset",
+			api: &fakeAPI{
+				start:   reply(http.StatusCreated, `{"data":{"id":"run1"}}`),
+				status:  succeeded,
+				dataset: reply(http.StatusOK, `[]`),
+			},
+			check: func(err error) bool {
+				reason, ok := ports.UnavailableReasonOf(err)
+				return ok && reason == ports.ReasonRemoved
+			},
+			requests: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newServerScraper(t, tt.api)
+			_, err := s.Scrape(context.Background(), tiktokURL)
+			if err == nil || !tt.check(err) {
+				t.Errorf("Scrape err = %v", err)
+			}
+			if len(tt.api.requests) != tt.requests {
+				t.Errorf("%d requests, want %d: %q", len(tt.api.requests), tt.requests, tt.api.requests)
+			}
+		})
+	}
+}
+
+func TestScrapeServerRejectsWrongToken(t *testing.T) {
+	api := &fakeAPI{start: reply(http.StatusCreated, `{"data":{"id":"run1"}}`)}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	s, err := NewApifyScraperWithConfig(Config{Token: "other", BaseURL: srv.URL + "/custom/v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.after = instantAfter
+
+	_, err = s.Scrape(context.Background(), tiktokURL)
+	var statusErr *retry.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Scrape err = %v, want the 401", err)
+	}
+}
+
+func TestNewApifyScraperWithConfigRejectsBadBaseURL(t *testing.T) {
+	for _, baseURL := range []string{"api.apify.com/v2", "ftp://api.apify.com/v2", "https://"} {
+		if _, err := NewApifyScraperWithConfig(Config{Token: "token", BaseURL: baseURL}); err == nil {
+			t.Errorf("base url %q accepted", baseURL)
+		}
+	}
+	if _, err := NewApifyScraperWithConfig(Config{BaseURL: DefaultBaseURL}); err == nil {
+		t.Error("missing token accepted")
+	}
+}
+
+// tiktokComments is a dataset item with the actor's comments array.
+const tiktokComments = `[{"id":"1","videoUrl":"https://cdn/v.mp4","comments":[{"text":"first","diggCount":3},{"text":"second","diggCount":1}]}]`
+
+func TestScrapeWithComments(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantInput interface{} // commentsPerPost
+		want      string
+	}{
+		{"off", nil, nil, ""},
+		{"default cap", []Option{WithComments(0)}, float64(defaultMaxComments), `[{"text":"first","diggCount":3},{"text":"second","diggCount":1}]`},
+		{"cap", []Option{WithComments(20)}, float64(20), `[{"text":"first","diggCount":3},{"text":"second","diggCount":1}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeAPI{
+				start:   reply(http.StatusCreated, `{"data":{"id":"run1"}}`),
+				status:  reply(http.StatusOK, `{"data":{"id":"run1","status":"SUCCEEDED","defaultDatasetId":"ds1"}}`),
+				dataset: reply(http.StatusOK, tiktokComments),
+			}
+			result, err := newServerScraper(t, api, tt.opts...).Scrape(context.Background(), tiktokURL)
+			if err != nil {
+				t.Fatalf("Scrape: %v", err)
+			}
+			if got := api.input["commentsPerPost"]; got != tt.wantInput {
+				t.Errorf("run input commentsPerPost = %v, want %v", got, tt.wantInput)
+			}
+			if string(result.Comments) != tt.want {
+				t.Errorf("Comments = %s, want %s", result.Comments, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildInputComments(t *testing.T) {
+	s := NewApifyScraperWithClient("token", http.DefaultClient, WithComments(5))
+	if got := s.buildInput("https://www.youtube.com/watch?v=abc", "youtube")["maxComments"]; got != 5 {
+		t.Errorf("YouTube maxComments = %v, want 5", got)
+	}
+	if got := s.buildInput(tiktokURL, "tiktok")["commentsPerPost"]; got != 5 {
+		t.Errorf("TikTok commentsPerPost = %v, want 5", got)
+	}
+	off := NewApifyScraperWithClient("token", http.DefaultClient)
+	for _, platform := range []string{"youtube", "tiktok"} {
+		input := off.buildInput(tiktokURL, platform)
+		if _, ok := input["maxComments"]; ok {
+			t.Errorf("%s input asks for comments by default: %v", platform, input)
+		}
+		if _, ok := input["commentsPerPost"]; ok {
+			t.Errorf("%s input asks for comments by default: %v", platform, input)
+		}
+	}
+}
+
+func TestExtractComments(t *testing.T) {
+	tests := []struct {
+		name, data, want string
+	}{
+		{"comments", `[{"comments":[{"text":"a"}]}]`, `[{"text":"a"}]`},
+		{"topComments", `[{"topComments":[{"text":"a"}]}]`, `[{"text":"a"}]`},
+		{"commentsList", `[{"commentsList":[]}]`, `[]`},
+		{"null", `[{"comments":null}]`, ""},
+		{"none", `[{"id":"1"}]`, ""},
+		{"empty dataset", `[]`, ""},
+		{"not json", `oops`, ""},
+	}
+	for _, tt := range tests {
+		if got := extractComments([]byte(tt.data)); string(got) != tt.want {
+			t.Errorf("%s: extractComments = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildInputProxy(t *testing.T) {
+	tests := []struct {
+		name  string
+		proxy ProxyConfig
+		want  string
+	}{
+		{"auto", ProxyConfig{UseApifyProxy: true}, `{"useApifyProxy":true}`},
+		{"groups and country", ProxyConfig{UseApifyProxy: true, Groups: []string{"RESIDENTIAL"}, Country: "US"},
+			`{"useApifyProxy":true,"apifyProxyGroups":["RESIDENTIAL"],"apifyProxyCountry":"US"}`},
+		{"disabled", ProxyConfig{}, `{"useApifyProxy":false}`},
+	}
+	for _, tt := range tests {
+		s := NewApifyScraperWithClient("token", http.DefaultClient, WithProxy(tt.proxy))
+		for _, platform := range []string{"youtube", "tiktok"} {
+			data, err := json.Marshal(s.buildInput(tiktokURL, platform))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var input struct {
+				ProxyConfiguration json.RawMessage `json:"proxyConfiguration"`
+			}
+			if err := json.Unmarshal(data, &input); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(input.ProxyConfiguration); got != tt.want {
+				t.Errorf("%s: %s proxyConfiguration = %s, want %s", tt.name, platform, got, tt.want)
+			}
+		}
+	}
+
+	off := NewApifyScraperWithClient("token", http.DefaultClient)
+	for _, platform := range []string{"youtube", "tiktok"} {
+		if input := off.buildInput(tiktokURL, platform); input["proxyConfiguration"] != nil {
+			t.Errorf("%s input has a proxyConfiguration by default: %v", platform, input)
+		}
+	}
+}
+
+// fallbackScraper returns result or err, recording the URLs it scraped.
+type fallbackScraper struct {
+	result *ports.ScrapeResult
+	err    error
+	calls  []string
+}
+
+func (f *fallbackScraper) Scrape(ctx context.Context, videoPageURL string) (*ports.ScrapeResult, error) {
+	f.calls = append(f.calls, videoPageURL)
+	if f.err != nil {
+		return nil, f.err
+	}
+	copied := *f.result
+	return &copied, nil
+}
+
+func TestScrapeFallback(t *testing.T) {
+	const pinterest = "https://www.pinterest.com/pin/1/"
+	api := &fakeAPI{
+		start:   reply(http.StatusCreated, `{"data":{"id":"run1"}}`),
+		status:  reply(http.StatusOK, `{"data":{"id":"run1","status":"SUCCEEDED","defaultDatasetId":"ds1"}}`),
+		dataset: reply(http.StatusOK, `[{"id":"1","videoUrl":"https://cdn/v.mp4"}]`),
+	}
+	fallback := &fallbackScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`{"title":"A pin","author_name":"user"}`)}}
+	s := newServerScraper(t, api, WithFallback(fallback))
+
+	result, err := s.Scrape(context.Background(), pinterest)
+	if err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if !result.FromFallback || string(result.RawMetadata) != `{"title":"A pin","author_name":"user"}` {
+		t.Errorf("result = %+v, want the fallback's metadata marked FromFallback", result)
+	}
+	if len(api.requests) != 0 {
+		t.Errorf("a platform without an actor sent %v to Apify", api.requests)
+	}
+
+	// Platforms with an actor still use Apify
+	result, err = s.Scrape(context.Background(), tiktokURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FromFallback || len(fallback.calls) != 1 {
+		t.Errorf("TikTok scrape used the fallback: %+v, fallback calls %v", result, fallback.calls)
+	}
+
+	fallback.err = ports.ErrVideoUnavailable
+	if _, err := s.Scrape(context.Background(), pinterest); !errors.Is(err, ports.ErrVideoUnavailable) {
+		t.Errorf("failed fallback err = %v, want its error", err)
+	}
+	if _, err := newServerScraper(t, api).Scrape(context.Background(), pinterest); !errors.Is(err, ports.ErrUnsupportedPlatform) {
+		t.Errorf("Scrape without a fallback err = %v, want ErrUnsupportedPlatform", err)
+	}
+}
+
+// A run that succeeds with no items fails with ErrVideoUnavailable.
+func TestScrapeEmptyDataset(t *testing.T) {
+	api := &fakeAPI{
+		start:   reply(http.StatusCreated, `{"data":{"id":"run1"}}`),
+		status:  reply(http.StatusOK, `{"data":{"id":"run1","status":"SUCCEEDED","defaultDatasetId":"ds1"}}`),
+		dataset: reply(http.StatusOK, `[]`),
+	}
+	s := newServerScraper(t, api)
+	result, err := s.Scrape(context.Background(), tiktokURL)
+	if !errors.Is(err, ports.ErrVideoUnavailable) {
+		t.Fatalf("Scrape = %v, %v; want ErrVideoUnavailable", result, err)
+	}
+}
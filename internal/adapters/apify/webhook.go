@@ -0,0 +1,170 @@
+package apify
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// webhookFallbackPollInterval is how often a run is still polled while
+// waiting for its webhook, in case the callback is lost.
+const webhookFallbackPollInterval = 30 * time.Second
+
+// arrivedTTL is how long a callback nobody is waiting for is kept, e.g. for
+// a run whose scraper gave up before it finished.
+const arrivedTTL = 10 * time.Minute
+
+// runStatus is the part of an Apify run object we act on.
+type runStatus struct {
+	ID               string `json:"id"`
+	Status           string `json:"status"`
+	DefaultDatasetID string `json:"defaultDatasetId"`
+
+	// Usage so far; final once the run has finished
+	Stats struct {
+		ComputeUnits float64 `json:"computeUnits"`
+	} `json:"stats"`
+	UsageTotalUSD float64 `json:"usageTotalUsd"`
+}
+
+// WebhookReceiver is an http.Handler receiving Apify "run finished" webhooks
+// and handing them to the scrapers waiting on those runs. Mount it on the
+// server's public address and pass it to the scraper WithWebhook.
+type WebhookReceiver struct {
+	secret string
+
+	mu      sync.Mutex
+	waiters map[string]chan runStatus
+	arrived map[string]arrivedStatus // Callbacks that beat their waiter
+}
+
+// arrivedStatus is a callback's status and when it arrived.
+type arrivedStatus struct {
+	status runStatus
+	at     time.Time
+}
+
+// NewWebhookReceiver creates a WebhookReceiver. Callbacks must carry secret
+// as the "secret" query parameter; it is added to the registered URL. An
+// empty secret is replaced by a random one, so the endpoint never accepts
+// unauthenticated callbacks.
+func NewWebhookReceiver(secret string) *WebhookReceiver {
+	if secret == "" {
+		secret = rand.Text()
+	}
+	return &WebhookReceiver{
+		secret:  secret,
+		waiters: make(map[string]chan runStatus),
+		arrived: make(map[string]arrivedStatus),
+	}
+}
+
+// ServeHTTP handles an Apify webhook callback with the default payload.
+func (wr *WebhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if wr.secret == "" || subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("secret")), []byte(wr.secret)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var payload struct {
+		EventData struct {
+			ActorRunID string `json:"actorRunId"`
+		} `json:"eventData"`
+		Resource struct {
+			ID string `json:"id"`
+			runStatus
+		} `json:"resource"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	runID := payload.Resource.ID
+	if runID == "" {
+		runID = payload.EventData.ActorRunID
+	}
+	if runID == "" {
+		http.Error(w, "missing run id", http.StatusBadRequest)
+		return
+	}
+
+	wr.deliver(runID, payload.Resource.runStatus)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// subscribe returns a channel receiving the run's webhook status, and a
+// function to call when no longer waiting.
+func (wr *WebhookReceiver) subscribe(runID string) (<-chan runStatus, func()) {
+	ch := make(chan runStatus, 1)
+
+	wr.mu.Lock()
+	if arrived, ok := wr.arrived[runID]; ok {
+		delete(wr.arrived, runID)
+		ch <- arrived.status
+	} else {
+		wr.waiters[runID] = ch
+	}
+	wr.mu.Unlock()
+
+	return ch, func() {
+		wr.mu.Lock()
+		delete(wr.waiters, runID)
+		wr.mu.Unlock()
+	}
+}
+
+func (wr *WebhookReceiver) deliver(runID string, status runStatus) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	if ch, ok := wr.waiters[runID]; ok {
+		delete(wr.waiters, runID)
+		ch <- status
+		return
+	}
+	now := time.Now()
+	for id, arrived := range wr.arrived {
+		if now.Sub(arrived.at) > arrivedTTL {
+			delete(wr.arrived, id)
+		}
+	}
+	wr.arrived[runID] = arrivedStatus{status: status, at: now}
+}
+
+// webhookConfig is the receiver plus the public URL Apify should call.
+type webhookConfig struct {
+	receiver *WebhookReceiver
+	url      string
+}
+
+// webhooksParam encodes the ad-hoc webhook definition for the "webhooks"
+// query parameter of the run-actor endpoint.
+func (c *webhookConfig) webhooksParam() string {
+	requestURL := c.url
+	if u, err := url.Parse(c.url); err == nil {
+		q := u.Query()
+		q.Set("secret", c.receiver.secret)
+		u.RawQuery = q.Encode()
+		requestURL = u.String()
+	}
+
+	defs, _ := json.Marshal([]map[string]interface{}{{
+		"eventTypes": []string{
+			"ACTOR.RUN.SUCCEEDED",
+			"ACTOR.RUN.FAILED",
+			"ACTOR.RUN.ABORTED",
+			"ACTOR.RUN.TIMED_OUT",
+		},
+		"requestUrl": requestURL,
+	}})
+	return base64.StdEncoding.EncodeToString(defs)
+}
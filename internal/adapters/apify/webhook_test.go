@@ -0,0 +1,232 @@
+package apify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// callback is an Apify webhook request for runID with the given status.
+func callback(secret, runID, status string) *http.Request {
+	body := `{"eventData":{"actorRunId":"` + runID + `"},"resource":{"id":"` + runID + `","status":"` + status + `","defaultDatasetId":"ds1"}}`
+	return httptest.NewRequest(http.MethodPost, "/apify/webhook?secret="+url.QueryEscape(secret), strings.NewReader(body))
+}
+
+func serve(wr *WebhookReceiver, req *http.Request) int {
+	rec := httptest.NewRecorder()
+	wr.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestWebhookReceiverRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *http.Request
+		want int
+	}{
+		{"callback", callback("s3cret", "run1", "SUCCEEDED"), http.StatusNoContent},
+		{"bad secret", callback("guess", "run1", "SUCCEEDED"), http.StatusForbidden},
+		{"empty secret", callback("", "run1", "SUCCEEDED"), http.StatusForbidden},
+		{"no secret", httptest.NewRequest(http.MethodPost, "/apify/webhook", strings.NewReader(`{}`)), http.StatusForbidden},
+		{"get", httptest.NewRequest(http.MethodGet, "/apify/webhook?secret=s3cret", nil), http.StatusMethodNotAllowed},
+		{"invalid payload", httptest.NewRequest(http.MethodPost, "/apify/webhook?secret=s3cret", strings.NewReader(`{`)), http.StatusBadRequest},
+		{"missing run id", httptest.NewRequest(http.MethodPost, "/apify/webhook?secret=s3cret", strings.NewReader(`{"resource":{}}`)), http.StatusBadRequest},
+		{
+			"run id from event data",
+			httptest.NewRequest(http.MethodPost, "/apify/webhook?secret=s3cret", strings.NewReader(`{"eventData":{"actorRunId":"run2"},"resource":{"status":"FAILED"}}`)),
+			http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(NewWebhookReceiver("s3cret"), tt.req); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// Without a configured secret, the receiver makes one up rather than
+// accepting anyone.
+func TestWebhookReceiverEmptySecret(t *testing.T) {
+	wr := NewWebhookReceiver("")
+	if wr.secret == "" {
+		t.Fatal("no secret generated")
+	}
+	if other := NewWebhookReceiver(""); other.secret == wr.secret {
+		t.Error("generated secrets repeat")
+	}
+	for _, secret := range []string{"", "guess"} {
+		if got := serve(wr, callback(secret, "run1", "SUCCEEDED")); got != http.StatusForbidden {
+			t.Errorf("secret %q: status %d, want 403", secret, got)
+		}
+	}
+	if got := serve(wr, callback(wr.secret, "run1", "SUCCEEDED")); got != http.StatusNoContent {
+		t.Errorf("generated secret: status %d, want 204", got)
+	}
+	if got := serve(&WebhookReceiver{}, callback("", "run1", "SUCCEEDED")); got != http.StatusForbidden {
+		t.Errorf("zero receiver: status %d, want 403", got)
+	}
+
+	// The generated secret is the one registered with Apify
+	config := &webhookConfig{receiver: wr, url: "https://example.com/apify/webhook"}
+	defs, err := base64.StdEncoding.DecodeString(config.webhooksParam())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed []struct {
+		RequestURL string `json:"requestUrl"`
+	}
+	if err := json.Unmarshal(defs, &parsed); err != nil || len(parsed) != 1 {
+		t.Fatalf("webhooks param %s: %v", defs, err)
+	}
+	u, err := url.Parse(parsed[0].RequestURL)
+	if err != nil || u.Query().Get("secret") != wr.secret {
+		t.Errorf("registered url %q doesn't carry the secret", parsed[0].RequestURL)
+	}
+}
+
+func receive(t *testing.T, ch <-chan runStatus) runStatus {
+	t.Helper()
+	select {
+	case status := <-ch:
+		return status
+	case <-time.After(time.Second):
+		t.Fatal("no status delivered")
+		return runStatus{}
+	}
+}
+
+func TestWebhookReceiverDelivery(t *testing.T) {
+	t.Run("after the wait", func(t *testing.T) {
+		wr := NewWebhookReceiver("s3cret")
+		ch, unsubscribe := wr.subscribe("run1")
+		defer unsubscribe()
+		serve(wr, callback("s3cret", "run1", "SUCCEEDED"))
+		if status := receive(t, ch); status.Status != "SUCCEEDED" || status.DefaultDatasetID != "ds1" {
+			t.Errorf("status = %+v, want SUCCEEDED with ds1", status)
+		}
+	})
+	t.Run("before the wait", func(t *testing.T) {
+		wr := NewWebhookReceiver("s3cret")
+		serve(wr, callback("s3cret", "run1", "FAILED"))
+		ch, unsubscribe := wr.subscribe("run1")
+		defer unsubscribe()
+		if status := receive(t, ch); status.Status != "FAILED" {
+			t.Errorf("status = %+v, want FAILED", status)
+		}
+		if len(wr.arrived) != 0 {
+			t.Errorf("arrived = %v, want the callback taken", wr.arrived)
+		}
+	})
+	t.Run("unknown run", func(t *testing.T) {
+		wr := NewWebhookReceiver("s3cret")
+		ch, unsubscribe := wr.subscribe("run1")
+		defer unsubscribe()
+		if got := serve(wr, callback("s3cret", "other", "SUCCEEDED")); got != http.StatusNoContent {
+			t.Errorf("status %d, want 204", got)
+		}
+		select {
+		case status := <-ch:
+			t.Errorf("run1 got another run's status %+v", status)
+		default:
+		}
+		if _, ok := wr.arrived["other"]; !ok {
+			t.Error("unknown run's callback not kept for a later waiter")
+		}
+	})
+	t.Run("duplicate callback", func(t *testing.T) {
+		wr := NewWebhookReceiver("s3cret")
+		ch, unsubscribe := wr.subscribe("run1")
+		for i := 0; i < 3; i++ {
+			if got := serve(wr, callback("s3cret", "run1", "SUCCEEDED")); got != http.StatusNoContent {
+				t.Fatalf("callback %d: status %d, want 204", i+1, got)
+			}
+		}
+		receive(t, ch)
+		select {
+		case status := <-ch:
+			t.Errorf("status delivered twice: %+v", status)
+		default:
+		}
+		unsubscribe()
+		if len(wr.waiters) != 0 {
+			t.Errorf("waiters = %v after unsubscribing", wr.waiters)
+		}
+	})
+	t.Run("stale callbacks expire", func(t *testing.T) {
+		wr := NewWebhookReceiver("s3cret")
+		wr.arrived["old"] = arrivedStatus{at: time.Now().Add(-2 * arrivedTTL)}
+		serve(wr, callback("s3cret", "run1", "SUCCEEDED"))
+		if _, ok := wr.arrived["old"]; ok {
+			t.Error("stale callback kept")
+		}
+	})
+}
+
+// neverAfter is a fake clock whose timers never fire, so only a webhook
+// can finish a run.
+func neverAfter(time.Duration) <-chan time.Time {
+	return nil
+}
+
+func TestScrapeWithWebhook(t *testing.T) {
+	for _, early := range []bool{true, false} {
+		name := "callback after the wait"
+		if early {
+			name = "callback before the wait"
+		}
+		t.Run(name, func(t *testing.T) {
+			wr := NewWebhookReceiver("s3cret")
+			api := &fakeAPI{
+				dataset: reply(http.StatusOK, `[{"id":"1","videoUrl":"https://cdn/v.mp4"}]`),
+			}
+			api.start = func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("webhooks") == "" {
+					t.Error("run started without a webhook")
+				}
+				if early {
+					serve(wr, callback("s3cret", "run1", "SUCCEEDED"))
+				} else {
+					go func() {
+						for {
+							wr.mu.Lock()
+							_, waiting := wr.waiters["run1"]
+							wr.mu.Unlock()
+							if waiting {
+								serve(wr, callback("s3cret", "run1", "SUCCEEDED"))
+								return
+							}
+							time.Sleep(time.Millisecond)
+						}
+					}()
+				}
+				reply(http.StatusCreated, `{"data":{"id":"run1"}}`)(w, r)
+			}
+			s := newServerScraper(t, api)
+			WithWebhook(wr, "https://example.com/apify/webhook")(s)
+			s.after = neverAfter
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			result, err := s.Scrape(ctx, tiktokURL)
+			if err != nil {
+				t.Fatalf("Scrape: %v", err)
+			}
+			if result.VideoURL != "https://cdn/v.mp4" || result.DatasetID != "ds1" {
+				t.Errorf("video/dataset = %q/%q, want https://cdn/v.mp4/ds1", result.VideoURL, result.DatasetID)
+			}
+			for _, req := range api.requests {
+				if strings.Contains(req, "/actor-runs/") {
+					t.Errorf("polled %s despite the webhook", req)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package channelstate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// channelEntry is the persisted state of one channel.
+type channelEntry struct {
+	URL      string    `json:"url"`
+	Title    string    `json:"title,omitempty"`
+	Seen     []string  `json:"seen"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// JSONState implements ports.ChannelState as a JSON file keyed by channel ID,
+// so a renamed channel keeps its history.
+type JSONState struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewJSONState creates a JSONState persisted at path.
+func NewJSONState(path string) *JSONState {
+	return &JSONState{path: path}
+}
+
+// SeenVideos returns the video IDs recorded for channelID.
+func (s *JSONState) SeenVideos(ctx context.Context, channelID string) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channels, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, id := range channels[channelID].Seen {
+		seen[id] = true
+	}
+	return seen, nil
+}
+
+// MarkSeen adds videoIDs to the channel's state and updates its URL and title.
+func (s *JSONState) MarkSeen(ctx context.Context, listing ports.ChannelListing, channelURL string, videoIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channels, err := s.load()
+	if err != nil {
+		return err
+	}
+	entry := channels[listing.ChannelID]
+	seen := make(map[string]bool, len(entry.Seen)+len(videoIDs))
+	for _, id := range append(entry.Seen, videoIDs...) {
+		seen[id] = true
+	}
+	entry.Seen = entry.Seen[:0]
+	for id := range seen {
+		entry.Seen = append(entry.Seen, id)
+	}
+	sort.Strings(entry.Seen)
+	entry.URL = channelURL
+	if listing.Title != "" {
+		entry.Title = listing.Title
+	}
+	entry.SyncedAt = time.Now().UTC()
+	channels[listing.ChannelID] = entry
+	return s.save(channels)
+}
+
+func (s *JSONState) load() (map[string]channelEntry, error) {
+	channels := make(map[string]channelEntry)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return channels, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read channel state: %w", err)
+	}
+	if err := json.Unmarshal(data, &channels); err != nil {
+		return nil, fmt.Errorf("failed to parse channel state %s: %w", s.path, err)
+	}
+	return channels, nil
+}
+
+func (s *JSONState) save(channels map[string]channelEntry) error {
+	data, err := json.MarshalIndent(channels, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create channel state directory: %w", err)
+	}
+	// Write-then-rename so a crash never leaves a torn state file
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write channel state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to write channel state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package channelstate
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// readChannels reads the state file at path.
+func readChannels(t *testing.T, path string) map[string]channelEntry {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var channels map[string]channelEntry
+	if err := json.Unmarshal(data, &channels); err != nil {
+		t.Fatal(err)
+	}
+	return channels
+}
+
+func TestJSONState(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state", "channel_state.json")
+	s := NewJSONState(path)
+
+	seen, err := s.SeenVideos(ctx, "UC1")
+	if err != nil || len(seen) != 0 {
+		t.Fatalf("SeenVideos without a file = %v, %v; want nothing", seen, err)
+	}
+	listing := ports.ChannelListing{ChannelID: "UC1", Title: "Channel"}
+	if err := s.MarkSeen(ctx, listing, "https://www.youtube.com/@channel", []string{"bbb", "aaa"}); err != nil {
+		t.Fatal(err)
+	}
+	// The channel was renamed and its handle changed; its ID wasn't
+	renamed := ports.ChannelListing{ChannelID: "UC1", Title: "New Name"}
+	if err := s.MarkSeen(ctx, renamed, "https://www.youtube.com/@newname", []string{"ccc", "aaa"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MarkSeen(ctx, ports.ChannelListing{ChannelID: "UC2"}, "https://www.youtube.com/@other", []string{"zzz"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new instance, as in the next sync, reads what was recorded
+	reread := NewJSONState(path)
+	tests := []struct {
+		channelID string
+		want      []string
+	}{
+		{"UC1", []string{"aaa", "bbb", "ccc"}},
+		{"UC2", []string{"zzz"}},
+		{"UC3", nil},
+	}
+	for _, tt := range tests {
+		seen, err := reread.SeenVideos(ctx, tt.channelID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for id := range seen {
+			got = append(got, id)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("SeenVideos(%s) = %v, want %v", tt.channelID, got, tt.want)
+		}
+	}
+
+	entry := readChannels(t, path)["UC1"]
+	if entry.URL != "https://www.youtube.com/@newname" || entry.Title != "New Name" || entry.SyncedAt.IsZero() {
+		t.Errorf("UC1 = %+v, want the latest URL and title", entry)
+	}
+	if !slices.Equal(entry.Seen, []string{"aaa", "bbb", "ccc"}) {
+		t.Errorf("UC1 seen = %v, want them sorted without duplicates", entry.Seen)
+	}
+
+	// A listing without a title keeps the known one
+	if err := reread.MarkSeen(ctx, ports.ChannelListing{ChannelID: "UC1"}, "https://www.youtube.com/@newname", nil); err != nil {
+		t.Fatal(err)
+	}
+	if title := readChannels(t, path)["UC1"].Title; title != "New Name" {
+		t.Errorf("title = %q after a listing without one", title)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestJSONStateCorrupt(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "channel_state.json")
+	if err := os.WriteFile(path, []byte(`{"UC1": {"seen": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewJSONState(path)
+	if _, err := s.SeenVideos(ctx, "UC1"); err == nil {
+		t.Error("SeenVideos of a corrupt file succeeded")
+	}
+	// Overwriting it would forget every channel's history
+	if err := s.MarkSeen(ctx, ports.ChannelListing{ChannelID: "UC1"}, "https://www.youtube.com/@channel", []string{"aaa"}); err == nil {
+		t.Error("MarkSeen on a corrupt file succeeded")
+	}
+}
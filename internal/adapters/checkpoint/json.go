@@ -0,0 +1,130 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// entry is one completed batch entry.
+type entry struct {
+	JobID       string    `json:"job_id"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// errCorrupt is a checkpoint file that can't be parsed.
+var errCorrupt = errors.New("corrupt batch checkpoint")
+
+// JSONCheckpoint implements ports.BatchCheckpoint as a JSON file keyed by
+// batch ID, then entry key.
+type JSONCheckpoint struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewJSONCheckpoint creates a JSONCheckpoint persisted at path.
+func NewJSONCheckpoint(path string) *JSONCheckpoint {
+	return &JSONCheckpoint{path: path}
+}
+
+// Completed returns the job IDs recorded for batchID, keyed by entry. A
+// corrupt checkpoint file is an error; the next update sets it aside.
+func (c *JSONCheckpoint) Completed(ctx context.Context, batchID string) (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	batches, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	completed := make(map[string]string, len(batches[batchID]))
+	for key, e := range batches[batchID] {
+		completed[key] = e.JobID
+	}
+	return completed, nil
+}
+
+// MarkCompleted records that the entry key of batchID finished as jobID.
+func (c *JSONCheckpoint) MarkCompleted(ctx context.Context, batchID, key, jobID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	batches, err := c.loadForUpdate()
+	if err != nil {
+		return err
+	}
+	if batches[batchID] == nil {
+		batches[batchID] = make(map[string]entry)
+	}
+	batches[batchID][key] = entry{JobID: jobID, CompletedAt: time.Now().UTC()}
+	return c.save(batches)
+}
+
+// Clear removes batchID from the checkpoint file.
+func (c *JSONCheckpoint) Clear(ctx context.Context, batchID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	batches, err := c.loadForUpdate()
+	if err != nil {
+		return err
+	}
+	if _, ok := batches[batchID]; !ok {
+		return nil
+	}
+	delete(batches, batchID)
+	return c.save(batches)
+}
+
+func (c *JSONCheckpoint) load() (map[string]map[string]entry, error) {
+	batches := make(map[string]map[string]entry)
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return batches, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &batches); err != nil {
+		return nil, fmt.Errorf("%w %s: %v", errCorrupt, c.path, err)
+	}
+	return batches, nil
+}
+
+// loadForUpdate is load for a change to the file. A corrupt file is moved
+// to <path>.corrupt, for inspection, and replaced by an empty checkpoint,
+// so one bad write doesn't stop every later batch from checkpointing.
+func (c *JSONCheckpoint) loadForUpdate() (map[string]map[string]entry, error) {
+	batches, err := c.load()
+	if !errors.Is(err, errCorrupt) {
+		return batches, err
+	}
+	if err := os.Rename(c.path, c.path+".corrupt"); err != nil {
+		return nil, fmt.Errorf("failed to set aside corrupt batch checkpoint: %w", err)
+	}
+	return make(map[string]map[string]entry), nil
+}
+
+func (c *JSONCheckpoint) save(batches map[string]map[string]entry) error {
+	data, err := json.MarshalIndent(batches, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create batch checkpoint directory: %w", err)
+	}
+	// Write-then-rename so a crash never leaves a torn checkpoint file
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write batch checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to write batch checkpoint: %w", err)
+	}
+	return nil
+}
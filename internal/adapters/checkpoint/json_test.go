@@ -0,0 +1,129 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestJSONCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state", "batch_checkpoint.json")
+	c := NewJSONCheckpoint(path)
+
+	completed, err := c.Completed(ctx, "urls.txt")
+	if err != nil || len(completed) != 0 {
+		t.Fatalf("Completed without a file = %v, %v, want nothing", completed, err)
+	}
+	for _, mark := range []struct{ batch, key, jobID string }{
+		{"urls.txt", "https://www.youtube.com/watch?v=a", "job-a"},
+		{"urls.txt", "https://www.youtube.com/watch?v=b 720p", "job-b"},
+		{"other.txt", "https://www.youtube.com/watch?v=a", "job-c"},
+		{"urls.txt", "https://www.youtube.com/watch?v=a", "job-a2"},
+	} {
+		if err := c.MarkCompleted(ctx, mark.batch, mark.key, mark.jobID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A new instance, as in the next run, reads what was recorded
+	reread := NewJSONCheckpoint(path)
+	tests := []struct {
+		batch string
+		want  map[string]string
+	}{
+		{"urls.txt", map[string]string{
+			"https://www.youtube.com/watch?v=a":      "job-a2",
+			"https://www.youtube.com/watch?v=b 720p": "job-b",
+		}},
+		{"other.txt", map[string]string{"https://www.youtube.com/watch?v=a": "job-c"}},
+		{"unknown.txt", map[string]string{}},
+	}
+	for _, tt := range tests {
+		got, err := reread.Completed(ctx, tt.batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("Completed(%s) = %v, want %v", tt.batch, got, tt.want)
+		}
+	}
+
+	if err := reread.Clear(ctx, "urls.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reread.Clear(ctx, "never-run.txt"); err != nil {
+		t.Errorf("Clear of an unknown batch: %v", err)
+	}
+	if got, _ := c.Completed(ctx, "urls.txt"); len(got) != 0 {
+		t.Errorf("Completed after Clear = %v, want nothing", got)
+	}
+	if got, _ := c.Completed(ctx, "other.txt"); len(got) != 1 {
+		t.Errorf("Clear removed other batches: Completed(other.txt) = %v", got)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestJSONCheckpointCorrupt(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "batch_checkpoint.json")
+	const torn = `{"urls.txt": {"https://www.youtube.com/watch?v=a": {"job_id": "job-a"`
+	if err := os.WriteFile(path, []byte(torn), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewJSONCheckpoint(path)
+
+	if _, err := c.Completed(ctx, "urls.txt"); err == nil {
+		t.Fatal("Completed of a corrupt file succeeded")
+	}
+	// The next update starts over, keeping the corrupt file for inspection
+	if err := c.MarkCompleted(ctx, "urls.txt", "https://www.youtube.com/watch?v=b", "job-b"); err != nil {
+		t.Fatalf("MarkCompleted on a corrupt file: %v", err)
+	}
+	got, err := c.Completed(ctx, "urls.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got["https://www.youtube.com/watch?v=b"] != "job-b" {
+		t.Errorf("Completed after recovery = %v, want only the new entry", got)
+	}
+	if kept, err := os.ReadFile(path + ".corrupt"); err != nil || string(kept) != torn {
+		t.Errorf("corrupt file kept as %q, %v", kept, err)
+	}
+
+	// Clear recovers the same way
+	if err := os.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Clear(ctx, "urls.txt"); err != nil {
+		t.Errorf("Clear on a corrupt file: %v", err)
+	}
+}
+
+func TestJSONCheckpointConcurrent(t *testing.T) {
+	ctx := context.Background()
+	c := NewJSONCheckpoint(filepath.Join(t.TempDir(), "batch_checkpoint.json"))
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.MarkCompleted(ctx, "urls.txt", fmt.Sprintf("url-%d", i), fmt.Sprintf("job-%d", i)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	got, err := c.Completed(ctx, "urls.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 20 {
+		t.Errorf("Completed has %d entries, want 20", len(got))
+	}
+}
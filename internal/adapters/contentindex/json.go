@@ -0,0 +1,89 @@
+package contentindex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// JSONIndex implements ports.ContentIndex as a JSON file mapping content
+// hashes to the first job that stored them.
+type JSONIndex struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewJSONIndex creates a JSONIndex persisted at path.
+func NewJSONIndex(path string) *JSONIndex {
+	return &JSONIndex{path: path}
+}
+
+// LookupOrAdd returns the existing reference for hash, or records ref as the
+// owner of hash and returns (nil, nil).
+func (i *JSONIndex) LookupOrAdd(ctx context.Context, hash string, ref ports.ContentRef) (*ports.ContentRef, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	entries, err := i.load()
+	if err != nil {
+		return nil, err
+	}
+	if existing, ok := entries[hash]; ok {
+		return &existing, nil
+	}
+
+	entries[hash] = ref
+	return nil, i.save(entries)
+}
+
+// Set records ref as the owner of hash, replacing any existing reference.
+func (i *JSONIndex) Set(ctx context.Context, hash string, ref ports.ContentRef) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	entries, err := i.load()
+	if err != nil {
+		return err
+	}
+	entries[hash] = ref
+	return i.save(entries)
+}
+
+func (i *JSONIndex) load() (map[string]ports.ContentRef, error) {
+	entries := make(map[string]ports.ContentRef)
+	data, err := os.ReadFile(i.path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read content index: %w", err)
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse content index %s: %w", i.path, err)
+	}
+	return entries, nil
+}
+
+func (i *JSONIndex) save(entries map[string]ports.ContentRef) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(i.path), 0755); err != nil {
+		return fmt.Errorf("failed to create content index directory: %w", err)
+	}
+	// Write-then-rename so readers never see a torn index
+	tmp := i.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write content index: %w", err)
+	}
+	if err := os.Rename(tmp, i.path); err != nil {
+		return fmt.Errorf("failed to write content index: %w", err)
+	}
+	return nil
+}
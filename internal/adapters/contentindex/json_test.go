@@ -0,0 +1,52 @@
+package contentindex
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestJSONIndexLookupOrAdd(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "content_index.json")
+	first := ports.ContentRef{JobID: "job1", Filename: "video.mp4"}
+
+	if existing, err := NewJSONIndex(path).LookupOrAdd(ctx, "abc", first); err != nil || existing != nil {
+		t.Fatalf("LookupOrAdd on a missing index = %v, %v", existing, err)
+	}
+	// A new instance, as in the next run, finds the first reference
+	index := NewJSONIndex(path)
+	existing, err := index.LookupOrAdd(ctx, "abc", ports.ContentRef{JobID: "job2", Filename: "video.mp4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if existing == nil || *existing != first {
+		t.Errorf("LookupOrAdd of a known hash = %v, want %v", existing, first)
+	}
+	if existing, err := index.LookupOrAdd(ctx, "def", first); err != nil || existing != nil {
+		t.Errorf("LookupOrAdd of another hash = %v, %v; want it added", existing, err)
+	}
+}
+
+func TestJSONIndexSetReplaces(t *testing.T) {
+	ctx := context.Background()
+	index := NewJSONIndex(filepath.Join(t.TempDir(), "content_index.json"))
+	first := ports.ContentRef{JobID: "job1", Filename: "video.mp4"}
+	second := ports.ContentRef{JobID: "job2", Filename: "video.webm"}
+
+	if existing, err := index.LookupOrAdd(ctx, "abc", first); err != nil || existing != nil {
+		t.Fatalf("LookupOrAdd on an empty index = %v, %v", existing, err)
+	}
+	if err := index.Set(ctx, "abc", second); err != nil {
+		t.Fatal(err)
+	}
+	existing, err := index.LookupOrAdd(ctx, "abc", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if existing == nil || *existing != second {
+		t.Errorf("LookupOrAdd after Set = %v, want %v", existing, second)
+	}
+}
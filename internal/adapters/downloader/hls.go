@@ -0,0 +1,485 @@
+package downloader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"scrapeanddown/internal/adapters/tempdir"
+	"scrapeanddown/internal/retry"
+)
+
+// DefaultSegmentConcurrency is how many HLS segments NewHLSDownloader fetches
+// at once.
+const DefaultSegmentConcurrency = 4
+
+// maxPlaylistSize bounds a playlist read; real ones are a few hundred KB.
+const maxPlaylistSize = 4 << 20
+
+// segmentPolicy retries a segment whose response or body fails. Getting a
+// response is already retried by each request (see DownloadFrom); this
+// also covers a body cut off mid-segment.
+var segmentPolicy = retry.Policy{
+	MaxAttempts: 3,
+	Initial:     time.Second,
+	Max:         10 * time.Second,
+	Multiplier:  2,
+	Jitter:      0.2,
+}
+
+// WithSegmentConcurrency sets how many segments an HLSDownloader fetches at
+// once (and so buffers on disk ahead of the stream). Too many get a CDN to
+// rate-limit the download; too few make it slow. Ignored by
+// NewHTTPDownloader.
+func WithSegmentConcurrency(n int) Option {
+	return func(s *settings) {
+		s.segmentConcurrency = n
+	}
+}
+
+// HLSDownloader implements ports.Downloader for HLS (.m3u8) URLs without
+// yt-dlp or ffmpeg: it picks the highest-bandwidth variant of a master
+// playlist, fetches the media playlist's segments with bounded concurrency,
+// and streams them concatenated as one file (MPEG-TS, or fragmented MP4
+// after its EXT-X-MAP init segment). URLs that aren't playlists are passed
+// through as downloaded.
+//
+// Segments complete in any order: each is buffered to a temp file named by
+// its index (in the job's scratch directory, see tempdir.WithDir) and
+// retried on its own if it fails, and the stream is reassembled in playlist
+// order. Byte-range segments (EXT-X-BYTERANGE) are fetched with Range
+// requests. Discontinuities (EXT-X-DISCONTINUITY) are joined as they are,
+// which MPEG-TS players resync at; an EXT-X-MAP repeated after one must
+// name the same init segment.
+//
+// Encrypted segments are not supported, nor are alternative renditions
+// (EXT-X-MEDIA): the variant must carry its own audio. A live playlist
+// yields the segments it lists when fetched.
+type HLSDownloader struct {
+	http        *HTTPDownloader
+	concurrency int
+}
+
+// NewHLSDownloader creates an HLSDownloader whose playlist and segment
+// requests behave like NewHTTPDownloader's with the same options.
+func NewHLSDownloader(opts ...Option) *HLSDownloader {
+	s := settings{segmentConcurrency: DefaultSegmentConcurrency}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	concurrency := s.segmentConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &HLSDownloader{http: NewHTTPDownloader(opts...), concurrency: concurrency}
+}
+
+// Download fetches the playlist at videoURL and returns its segments
+// concatenated.
+func (d *HLSDownloader) Download(ctx context.Context, videoURL string) (io.ReadCloser, error) {
+	body, playlist, err := d.fetchPlaylist(ctx, videoURL)
+	if err != nil || body != nil {
+		return body, err
+	}
+
+	if len(playlist.variants) > 0 {
+		variant, err := playlist.bestVariant()
+		if err != nil {
+			return nil, err
+		}
+		_, playlist, err = d.fetchPlaylist(ctx, variant)
+		if err != nil {
+			return nil, err
+		}
+		if playlist == nil || len(playlist.variants) > 0 {
+			return nil, fmt.Errorf("hls: variant %s is not a media playlist", variant)
+		}
+	}
+	if len(playlist.segments) == 0 {
+		return nil, fmt.Errorf("hls: media playlist has no segments")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(d.copySegments(ctx, pw, playlist.segments))
+	}()
+	return &segmentReader{PipeReader: pr, cancel: cancel}, nil
+}
+
+// fetchPlaylist downloads and parses a playlist. If the response isn't one,
+// it returns the still-open body instead.
+func (d *HLSDownloader) fetchPlaylist(ctx context.Context, playlistURL string) (io.ReadCloser, *hlsPlaylist, error) {
+	body, err := d.http.Download(ctx, playlistURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	buffered := bufio.NewReader(body)
+	if head, _ := buffered.Peek(len("#EXTM3U")); string(head) != "#EXTM3U" {
+		return struct {
+			io.Reader
+			io.Closer
+		}{buffered, body}, nil, nil
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(buffered, maxPlaylistSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("hls: failed to read playlist: %w", err)
+	}
+	if len(data) > maxPlaylistSize {
+		return nil, nil, fmt.Errorf("hls: playlist larger than %d bytes", maxPlaylistSize)
+	}
+	base, err := url.Parse(playlistURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("hls: invalid playlist url: %w", err)
+	}
+	playlist, err := parsePlaylist(data, base)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, playlist, nil
+}
+
+// copySegments writes the segments to w in order. Up to d.concurrency
+// segments are in flight or buffered ahead of the writer at any time.
+func (d *HLSDownloader) copySegments(ctx context.Context, w io.Writer, segments []hlsSegment) error {
+	scratch, _ := tempdir.DirFromContext(ctx)
+	dir, err := os.MkdirTemp(scratch, "hls-")
+	if err != nil {
+		return fmt.Errorf("hls: failed to create segment directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Fetches still running when a segment fails are stopped, and waited
+	// for before their files are removed
+	var fetches sync.WaitGroup
+	defer fetches.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]chan error, len(segments))
+	for i := range results {
+		results[i] = make(chan error, 1)
+	}
+
+	slots := make(chan struct{}, d.concurrency)
+	fetches.Add(1)
+	go func() {
+		defer fetches.Done()
+		for i, segment := range segments {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			fetches.Add(1)
+			go func(i int, segment hlsSegment) {
+				defer fetches.Done()
+				err := d.fetchSegment(ctx, segment, segmentPath(dir, i))
+				if err != nil {
+					err = fmt.Errorf("hls: segment %d of %d: %w", i+1, len(segments), err)
+				}
+				results[i] <- err
+			}(i, segment)
+		}
+	}()
+
+	for i := range segments {
+		select {
+		case err = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if err := appendSegment(w, segmentPath(dir, i)); err != nil {
+			return err
+		}
+		<-slots
+	}
+	return nil
+}
+
+// segmentPath is where segment i is buffered.
+func segmentPath(dir string, i int) string {
+	return filepath.Join(dir, fmt.Sprintf("segment_%06d", i))
+}
+
+// fetchSegment downloads one segment to path, starting it over with
+// segmentPolicy if its response or body fails.
+func (d *HLSDownloader) fetchSegment(ctx context.Context, segment hlsSegment, path string) error {
+	return retry.Do(ctx, segmentPolicy, func() error {
+		body, err := d.openSegment(ctx, segment)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("failed to buffer segment: %w", err)
+		}
+		n, err := io.Copy(file, body)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil && segment.length > 0 && n != segment.length {
+			err = fmt.Errorf("got %d of %d bytes: %w", n, segment.length, io.ErrUnexpectedEOF)
+		}
+		return err
+	})
+}
+
+// openSegment requests a segment, or just its byte range.
+func (d *HLSDownloader) openSegment(ctx context.Context, segment hlsSegment) (io.ReadCloser, error) {
+	if segment.length == 0 {
+		return d.http.Download(ctx, segment.uri)
+	}
+	resp, err := d.http.download(ctx, segment.uri, segment.offset, segment.length, "")
+	if err != nil {
+		return nil, err
+	}
+	// A server ignoring the range sends the whole resource
+	if resp.Offset != segment.offset {
+		if _, err := io.CopyN(io.Discard, resp.Body, segment.offset); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to skip to byte %d: %w", segment.offset, err)
+		}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(resp.Body, segment.length), resp.Body}, nil
+}
+
+// appendSegment copies a buffered segment to w and removes it.
+func appendSegment(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("hls: failed to read buffered segment: %w", err)
+	}
+	_, err = io.Copy(w, file)
+	file.Close()
+	os.Remove(path)
+	return err
+}
+
+// segmentReader is the concatenated stream; closing it stops the fetches.
+type segmentReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (r *segmentReader) Close() error {
+	r.cancel()
+	return r.PipeReader.Close()
+}
+
+// hlsPlaylist is a parsed master playlist (variants) or media playlist
+// (segments, the EXT-X-MAP init segment first), with absolute URIs.
+type hlsPlaylist struct {
+	variants []hlsVariant
+	segments []hlsSegment
+}
+
+// hlsSegment is a segment's resource, or the length bytes of it at offset
+// when length isn't 0 (EXT-X-BYTERANGE).
+type hlsSegment struct {
+	uri    string
+	offset int64
+	length int64
+}
+
+type hlsVariant struct {
+	uri       string
+	bandwidth int64
+}
+
+// bestVariant returns the URI of the highest-bandwidth variant.
+func (p *hlsPlaylist) bestVariant() (string, error) {
+	if len(p.variants) == 0 {
+		return "", fmt.Errorf("hls: master playlist has no variants")
+	}
+	best := p.variants[0]
+	for _, v := range p.variants[1:] {
+		if v.bandwidth > best.bandwidth {
+			best = v
+		}
+	}
+	return best.uri, nil
+}
+
+// parsePlaylist parses an M3U8 master or media playlist, resolving its URIs
+// against base.
+func parsePlaylist(data []byte, base *url.URL) (*hlsPlaylist, error) {
+	resolve := func(uri string) (string, error) {
+		ref, err := url.Parse(uri)
+		if err != nil {
+			return "", fmt.Errorf("hls: invalid uri %q: %w", uri, err)
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+
+	playlist := &hlsPlaylist{}
+	var pendingVariant *hlsVariant
+	var pendingRange *hlsSegment // From EXT-X-BYTERANGE, for the next segment
+	var initSegment *hlsSegment
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxPlaylistSize)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if first {
+			if line != "#EXTM3U" {
+				return nil, fmt.Errorf("hls: missing #EXTM3U header")
+			}
+			first = false
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "#") {
+			uri, err := resolve(line)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case pendingVariant != nil:
+				pendingVariant.uri = uri
+				playlist.variants = append(playlist.variants, *pendingVariant)
+				pendingVariant = nil
+			case pendingRange != nil:
+				segment := *pendingRange
+				segment.uri = uri
+				// Without an offset, the range follows the previous segment's
+				if segment.offset < 0 {
+					n := len(playlist.segments)
+					if n == 0 || playlist.segments[n-1].uri != uri || playlist.segments[n-1].length == 0 {
+						return nil, fmt.Errorf("hls: byte range without an offset must follow a range of %s", uri)
+					}
+					segment.offset = playlist.segments[n-1].offset + playlist.segments[n-1].length
+				}
+				playlist.segments = append(playlist.segments, segment)
+				pendingRange = nil
+			default:
+				playlist.segments = append(playlist.segments, hlsSegment{uri: uri})
+			}
+			continue
+		}
+
+		tag, value, _ := strings.Cut(line, ":")
+		switch tag {
+		case "#EXT-X-STREAM-INF":
+			attrs := parseAttributes(value)
+			bandwidth, _ := strconv.ParseInt(attrs["BANDWIDTH"], 10, 64)
+			pendingVariant = &hlsVariant{bandwidth: bandwidth}
+		case "#EXT-X-KEY":
+			if method := parseAttributes(value)["METHOD"]; method != "" && method != "NONE" {
+				return nil, fmt.Errorf("hls: %s-encrypted segments are not supported", method)
+			}
+		case "#EXT-X-BYTERANGE":
+			segment, err := parseByteRange(value, -1)
+			if err != nil {
+				return nil, err
+			}
+			pendingRange = &segment
+		case "#EXT-X-MAP":
+			attrs := parseAttributes(value)
+			if attrs["URI"] == "" {
+				return nil, errors.New("hls: EXT-X-MAP without a uri")
+			}
+			var segment hlsSegment
+			if byteRange, ok := attrs["BYTERANGE"]; ok {
+				var err error
+				if segment, err = parseByteRange(byteRange, 0); err != nil {
+					return nil, err
+				}
+			}
+			uri, err := resolve(attrs["URI"])
+			if err != nil {
+				return nil, err
+			}
+			segment.uri = uri
+			// Playlists may repeat the map after each discontinuity
+			if initSegment != nil && *initSegment == segment {
+				continue
+			}
+			if len(playlist.segments) > 0 {
+				return nil, errors.New("hls: a new EXT-X-MAP after the first segment is not supported")
+			}
+			initSegment = &segment
+			playlist.segments = append(playlist.segments, segment)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("hls: failed to parse playlist: %w", err)
+	}
+	if first {
+		return nil, fmt.Errorf("hls: missing #EXTM3U header")
+	}
+	if len(playlist.variants) > 0 && len(playlist.segments) > 0 {
+		return nil, fmt.Errorf("hls: playlist mixes variants and segments")
+	}
+	if pendingVariant != nil {
+		return nil, fmt.Errorf("hls: EXT-X-STREAM-INF without a uri")
+	}
+	return playlist, nil
+}
+
+// parseByteRange parses an EXT-X-BYTERANGE value, <length>[@<offset>]. A
+// missing offset is returned as defaultOffset.
+func parseByteRange(value string, defaultOffset int64) (hlsSegment, error) {
+	lengthPart, offsetPart, hasOffset := strings.Cut(strings.TrimSpace(value), "@")
+	length, err := strconv.ParseInt(lengthPart, 10, 64)
+	if err != nil || length <= 0 {
+		return hlsSegment{}, fmt.Errorf("hls: invalid byte range %q", value)
+	}
+	offset := defaultOffset
+	if hasOffset {
+		if offset, err = strconv.ParseInt(offsetPart, 10, 64); err != nil || offset < 0 {
+			return hlsSegment{}, fmt.Errorf("hls: invalid byte range %q", value)
+		}
+	}
+	return hlsSegment{offset: offset, length: length}, nil
+}
+
+// parseAttributes parses an attribute list (BANDWIDTH=1280000,CODECS="a,b").
+// Quoted values are unquoted; commas inside them don't split.
+func parseAttributes(list string) map[string]string {
+	attrs := make(map[string]string)
+	for list != "" {
+		name, rest, ok := strings.Cut(list, "=")
+		if !ok {
+			break
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+			rest = strings.TrimPrefix(rest, ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		attrs[strings.TrimSpace(name)] = value
+		list = rest
+	}
+	return attrs
+}
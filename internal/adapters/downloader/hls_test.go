@@ -0,0 +1,527 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/adapters/tempdir"
+	"scrapeanddown/internal/retry"
+)
+
+// hlsServer serves playlists (.m3u8) and segments from memory, honouring
+// Range requests, and records the paths requested.
+type hlsServer struct {
+	*httptest.Server
+	files map[string]string
+	// hook, if set, runs before a request is served; it may answer the
+	// request itself by returning true.
+	hook func(w http.ResponseWriter, r *http.Request) bool
+
+	mu        sync.Mutex
+	requested []string
+}
+
+func newHLSServer(t *testing.T, files map[string]string) *hlsServer {
+	s := &hlsServer{files: files}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requested = append(s.requested, r.URL.Path)
+		s.mu.Unlock()
+		if s.hook != nil && s.hook(w, r) {
+			return
+		}
+		data, ok := s.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, ".m3u8") {
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		} else {
+			w.Header().Set("Content-Type", "video/mp2t")
+		}
+		http.ServeContent(w, r, r.URL.Path, time.Time{}, strings.NewReader(data))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// paths returns the paths requested so far.
+func (s *hlsServer) paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requested...)
+}
+
+// downloadAll downloads videoURL with d and reads the whole stream.
+func downloadAll(t *testing.T, d *HLSDownloader, videoURL string) (string, error) {
+	t.Helper()
+	body, err := d.Download(context.Background(), videoURL)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	return string(data), err
+}
+
+// A master playlist downloads its highest-bandwidth variant, whose
+// segments are resolved against the media playlist's URL.
+func TestHLSDownloadMasterPlaylist(t *testing.T) {
+	srv := newHLSServer(t, map[string]string{
+		"/live/master.m3u8": "#EXTM3U\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=800000,RESOLUTION=640x360,CODECS=\"avc1.4d401e,mp4a.40.2\"\n" +
+			"low/index.m3u8\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=5000000,RESOLUTION=1920x1080\n" +
+			"high/index.m3u8\n" +
+			"#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720\n" +
+			"mid/index.m3u8\n",
+		"/live/low/index.m3u8":  "#EXTM3U\n#EXTINF:4,\nlow0.ts\n",
+		"/live/mid/index.m3u8":  "#EXTM3U\n#EXTINF:4,\nmid0.ts\n",
+		"/live/high/index.m3u8": "", // Set below, once the server's URL is known
+		"/live/high/seg0.ts":    "segment 0|",
+		"/segments/seg1.ts":     "segment 1|",
+		"/other/seg2.ts":        "segment 2",
+		"/live/low/low0.ts":     "low",
+		"/live/mid/mid0.ts":     "mid",
+	})
+	srv.files["/live/high/index.m3u8"] = "#EXTM3U\n" +
+		"#EXT-X-TARGETDURATION:4\n" +
+		"#EXTINF:4.0,\n" +
+		"seg0.ts\n" +
+		"#EXTINF:4.0,\n" +
+		"/segments/seg1.ts\n" +
+		"#EXTINF:4.0,\n" +
+		srv.URL + "/other/seg2.ts\n" +
+		"#EXT-X-ENDLIST\n"
+
+	got, err := downloadAll(t, NewHLSDownloader(), srv.URL+"/live/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "segment 0|segment 1|segment 2"; got != want {
+		t.Errorf("downloaded %q, want %q", got, want)
+	}
+	for _, path := range srv.paths() {
+		if strings.Contains(path, "/low/") || strings.Contains(path, "/mid/") {
+			t.Errorf("requested %s of a lower-bandwidth variant", path)
+		}
+	}
+}
+
+// A media playlist is downloaded as is, after its init segment.
+func TestHLSDownloadMediaPlaylist(t *testing.T) {
+	srv := newHLSServer(t, map[string]string{
+		"/v/index.m3u8": "#EXTM3U\n" +
+			"#EXT-X-MAP:URI=\"init.mp4\"\n" +
+			"#EXTINF:2,\nfrag0.m4s\n" +
+			"#EXTINF:2,\nfrag1.m4s\n",
+		"/v/init.mp4":  "ftyp moov|",
+		"/v/frag0.m4s": "moof mdat 0|",
+		"/v/frag1.m4s": "moof mdat 1",
+	})
+	got, err := downloadAll(t, NewHLSDownloader(), srv.URL+"/v/index.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ftyp moov|moof mdat 0|moof mdat 1"; got != want {
+		t.Errorf("downloaded %q, want %q", got, want)
+	}
+}
+
+// A URL that isn't a playlist is passed through.
+func TestHLSDownloadNotAPlaylist(t *testing.T) {
+	srv := newHLSServer(t, map[string]string{"/v.mp4": "plain video bytes"})
+	got, err := downloadAll(t, NewHLSDownloader(), srv.URL+"/v.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "plain video bytes" {
+		t.Errorf("downloaded %q, want the file as is", got)
+	}
+}
+
+func TestHLSDownloadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{"no segments", map[string]string{"/index.m3u8": "#EXTM3U\n#EXT-X-ENDLIST\n"}, "has no segments"},
+		{"variant is a master playlist", map[string]string{
+			"/index.m3u8":  "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nnested.m3u8\n",
+			"/nested.m3u8": "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nindex.m3u8\n",
+		}, "is not a media playlist"},
+		{"variant is not a playlist", map[string]string{
+			"/index.m3u8": "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nv.mp4\n",
+			"/v.mp4":      "video",
+		}, "is not a media playlist"},
+		{"encrypted", map[string]string{
+			"/index.m3u8": "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"key\"\n#EXTINF:4,\nseg0.ts\n",
+		}, "AES-128-encrypted segments are not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newHLSServer(t, tt.files)
+			_, err := downloadAll(t, NewHLSDownloader(), srv.URL+"/index.m3u8")
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Download err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParsePlaylist(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/v/index.m3u8")
+	tests := []struct {
+		name     string
+		playlist string
+		variants []hlsVariant
+		segments []hlsSegment
+		err      string
+	}{
+		{
+			name:     "master",
+			playlist: "#EXTM3U\n#EXT-X-STREAM-INF:CODECS=\"avc1,mp4a\",BANDWIDTH=1280000\n720/index.m3u8\n\n#EXT-X-STREAM-INF:BANDWIDTH=640000\nhttps://other.example.com/360.m3u8\n",
+			variants: []hlsVariant{
+				{uri: "https://cdn.example.com/v/720/index.m3u8", bandwidth: 1280000},
+				{uri: "https://other.example.com/360.m3u8", bandwidth: 640000},
+			},
+		},
+		{
+			name:     "media",
+			playlist: "#EXTM3U\r\n#EXTINF:4,\r\nseg0.ts\r\n#EXTINF:4,\r\n../seg1.ts?token=a\r\n",
+			segments: []hlsSegment{
+				{uri: "https://cdn.example.com/v/seg0.ts"},
+				{uri: "https://cdn.example.com/seg1.ts?token=a"},
+			},
+		},
+		{
+			name:     "unencrypted key",
+			playlist: "#EXTM3U\n#EXT-X-KEY:METHOD=NONE\n#EXTINF:4,\nseg0.ts\n",
+			segments: []hlsSegment{{uri: "https://cdn.example.com/v/seg0.ts"}},
+		},
+		{name: "empty", playlist: "", err: "missing #EXTM3U"},
+		{name: "no header", playlist: "#EXTINF:4,\nseg0.ts\n", err: "missing #EXTM3U"},
+		{name: "mixed", playlist: "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\na.m3u8\n#EXTINF:4,\nseg0.ts\n", err: "mixes variants and segments"},
+		{name: "variant without uri", playlist: "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\n", err: "EXT-X-STREAM-INF without a uri"},
+		{name: "encrypted", playlist: "#EXTM3U\n#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"k\"\n#EXTINF:4,\nseg0.ts\n", err: "SAMPLE-AES-encrypted"},
+		{name: "bad uri", playlist: "#EXTM3U\n#EXTINF:4,\n%zz.ts\n", err: "invalid uri"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			playlist, err := parsePlaylist([]byte(tt.playlist), base)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parsePlaylist err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(playlist.variants, tt.variants) {
+				t.Errorf("variants = %+v, want %+v", playlist.variants, tt.variants)
+			}
+			if !slices.Equal(playlist.segments, tt.segments) {
+				t.Errorf("segments = %+v, want %+v", playlist.segments, tt.segments)
+			}
+		})
+	}
+}
+
+func TestBestVariant(t *testing.T) {
+	p := &hlsPlaylist{variants: []hlsVariant{{"a", 100}, {"b", 300}, {"c", 200}, {"d", 300}}}
+	if got, err := p.bestVariant(); err != nil || got != "b" {
+		t.Errorf("bestVariant = %q, %v; want the first of the highest", got, err)
+	}
+	if _, err := (&hlsPlaylist{}).bestVariant(); err == nil {
+		t.Error("bestVariant of no variants succeeded")
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	got := parseAttributes(`BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=1280x720,URI="init.mp4"`)
+	want := map[string]string{"BANDWIDTH": "1280000", "CODECS": "avc1.4d401f,mp4a.40.2", "RESOLUTION": "1280x720", "URI": "init.mp4"}
+	if len(got) != len(want) {
+		t.Errorf("parseAttributes = %v, want %v", got, want)
+	}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %q, want %q", name, got[name], value)
+		}
+	}
+	if got := parseAttributes(`URI="unterminated`); got["URI"] != "unterminated" {
+		t.Errorf("unterminated quote: URI = %q", got["URI"])
+	}
+}
+
+// Closing the stream early stops the download without leaking it.
+func TestHLSDownloadClose(t *testing.T) {
+	srv := newHLSServer(t, map[string]string{
+		"/index.m3u8": "#EXTM3U\n#EXTINF:4,\nseg0.ts\n#EXTINF:4,\nseg1.ts\n",
+		"/seg0.ts":    "segment 0",
+		"/seg1.ts":    "segment 1",
+	})
+	body, err := NewHLSDownloader().Download(context.Background(), srv.URL+"/index.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 3)
+	if _, err := io.ReadFull(body, buf); err != nil || !bytes.Equal(buf, []byte("seg")) {
+		t.Fatalf("read %q, %v", buf, err)
+	}
+	if err := body.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if _, err := body.Read(buf); err == nil {
+		t.Error("Read after Close succeeded")
+	}
+}
+
+// shortSegmentPolicy retries segments without waiting, for the test.
+func shortSegmentPolicy(t *testing.T) {
+	saved := segmentPolicy
+	segmentPolicy = retry.Policy{MaxAttempts: 3, Initial: time.Millisecond}
+	t.Cleanup(func() { segmentPolicy = saved })
+}
+
+// Byte-range segments of one file, including an init segment and ranges
+// without an offset, are reassembled in playlist order at any concurrency,
+// also from a server that ignores Range requests.
+func TestHLSByteRanges(t *testing.T) {
+	const playlist = "#EXTM3U\n" +
+		"#EXT-X-MAP:URI=\"all.ts\",BYTERANGE=\"2@18\"\n" +
+		"#EXTINF:2,\n#EXT-X-BYTERANGE:5@0\nall.ts\n" +
+		"#EXTINF:2,\n#EXT-X-BYTERANGE:5\nall.ts\n" +
+		"#EXT-X-DISCONTINUITY\n" +
+		"#EXT-X-MAP:URI=\"all.ts\",BYTERANGE=\"2@18\"\n" +
+		"#EXTINF:2,\n#EXT-X-BYTERANGE:4@12\nall.ts\n" +
+		"#EXTINF:2,\nwhole.ts\n"
+	const want = "ij" + "01234" + "56789" + "cdef" + "whole"
+	for _, ignoreRange := range []bool{false, true} {
+		for _, concurrency := range []int{1, 2, 3, 8} {
+			srv := newHLSServer(t, map[string]string{
+				"/index.m3u8": playlist,
+				"/all.ts":     "0123456789abcdefghij",
+				"/whole.ts":   "whole",
+			})
+			if ignoreRange {
+				srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+					r.Header.Del("Range")
+					return false
+				}
+			}
+			got, err := downloadAll(t, NewHLSDownloader(WithSegmentConcurrency(concurrency)), srv.URL+"/index.m3u8")
+			if err != nil {
+				t.Errorf("concurrency %d, ignoring ranges %v: %v", concurrency, ignoreRange, err)
+				continue
+			}
+			if got != want {
+				t.Errorf("concurrency %d, ignoring ranges %v: downloaded %q, want %q", concurrency, ignoreRange, got, want)
+			}
+		}
+	}
+}
+
+func TestParseByteRanges(t *testing.T) {
+	base, _ := url.Parse("https://cdn.example.com/index.m3u8")
+	tests := []struct {
+		name     string
+		playlist string
+		segments []hlsSegment
+		err      string
+	}{
+		{
+			name:     "offsets",
+			playlist: "#EXTM3U\n#EXT-X-BYTERANGE:10@0\na.ts\n#EXT-X-BYTERANGE:20\na.ts\n#EXT-X-BYTERANGE:5@100\nb.ts\n",
+			segments: []hlsSegment{
+				{uri: "https://cdn.example.com/a.ts", offset: 0, length: 10},
+				{uri: "https://cdn.example.com/a.ts", offset: 10, length: 20},
+				{uri: "https://cdn.example.com/b.ts", offset: 100, length: 5},
+			},
+		},
+		{
+			name:     "map repeated after a discontinuity",
+			playlist: "#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:2,\n0.m4s\n#EXT-X-DISCONTINUITY\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:2,\n1.m4s\n",
+			segments: []hlsSegment{
+				{uri: "https://cdn.example.com/init.mp4"},
+				{uri: "https://cdn.example.com/0.m4s"},
+				{uri: "https://cdn.example.com/1.m4s"},
+			},
+		},
+		{name: "first range without offset", playlist: "#EXTM3U\n#EXT-X-BYTERANGE:10\na.ts\n", err: "without an offset"},
+		{name: "range without offset of another file", playlist: "#EXTM3U\n#EXT-X-BYTERANGE:10@0\na.ts\n#EXT-X-BYTERANGE:10\nb.ts\n", err: "without an offset"},
+		{name: "zero length", playlist: "#EXTM3U\n#EXT-X-BYTERANGE:0@0\na.ts\n", err: "invalid byte range"},
+		{name: "negative offset", playlist: "#EXTM3U\n#EXT-X-BYTERANGE:10@-1\na.ts\n", err: "invalid byte range"},
+		{name: "bad map range", playlist: "#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\",BYTERANGE=\"x\"\n", err: "invalid byte range"},
+		{name: "map without uri", playlist: "#EXTM3U\n#EXT-X-MAP:BYTERANGE=\"10@0\"\n", err: "EXT-X-MAP without a uri"},
+		{name: "new map", playlist: "#EXTM3U\n#EXT-X-MAP:URI=\"a.mp4\"\n#EXTINF:2,\n0.m4s\n#EXT-X-MAP:URI=\"b.mp4\"\n#EXTINF:2,\n1.m4s\n", err: "new EXT-X-MAP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			playlist, err := parsePlaylist([]byte(tt.playlist), base)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parsePlaylist err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(playlist.segments, tt.segments) {
+				t.Errorf("segments = %+v, want %+v", playlist.segments, tt.segments)
+			}
+		})
+	}
+}
+
+// Segments finishing out of order are still written in playlist order.
+func TestHLSOutOfOrderCompletion(t *testing.T) {
+	srv := newHLSServer(t, map[string]string{
+		"/index.m3u8": "#EXTM3U\n#EXTINF:4,\nseg0.ts\n#EXTINF:4,\nseg1.ts\n#EXTINF:4,\nseg2.ts\n",
+		"/seg0.ts":    "segment 0|",
+		"/seg1.ts":    "segment 1|",
+		"/seg2.ts":    "segment 2",
+	})
+	// The first two segments are held until the last one is served
+	lastServed := make(chan struct{})
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		switch r.URL.Path {
+		case "/seg0.ts", "/seg1.ts":
+			select {
+			case <-lastServed:
+			case <-time.After(5 * time.Second):
+				t.Errorf("%s: the last segment wasn't fetched alongside it", r.URL.Path)
+			}
+		case "/seg2.ts":
+			w.Write([]byte(srv.files[r.URL.Path]))
+			close(lastServed)
+			return true
+		}
+		return false
+	}
+
+	got, err := downloadAll(t, NewHLSDownloader(WithSegmentConcurrency(3)), srv.URL+"/index.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "segment 0|segment 1|segment 2"; got != want {
+		t.Errorf("downloaded %q, want %q", got, want)
+	}
+}
+
+// No more segments than WithSegmentConcurrency are fetched at once.
+func TestHLSSegmentConcurrency(t *testing.T) {
+	files := map[string]string{"/index.m3u8": "#EXTM3U\n"}
+	var want strings.Builder
+	for i := range 8 {
+		name := fmt.Sprintf("seg%d.ts", i)
+		files["/index.m3u8"] += "#EXTINF:4,\n" + name + "\n"
+		files["/"+name] = name
+		want.WriteString(name)
+	}
+	for _, concurrency := range []int{1, 2, 5} {
+		srv := newHLSServer(t, files)
+		var mu sync.Mutex
+		inFlight, most := 0, 0
+		srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+			if strings.HasSuffix(r.URL.Path, ".m3u8") {
+				return false
+			}
+			mu.Lock()
+			inFlight++
+			most = max(most, inFlight)
+			mu.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			w.Write([]byte(srv.files[r.URL.Path]))
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			return true
+		}
+
+		got, err := downloadAll(t, NewHLSDownloader(WithSegmentConcurrency(concurrency)), srv.URL+"/index.m3u8")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want.String() {
+			t.Errorf("concurrency %d: downloaded %q, want %q", concurrency, got, want.String())
+		}
+		if most > concurrency {
+			t.Errorf("concurrency %d: fetched %d segments at once", concurrency, most)
+		}
+	}
+}
+
+// A segment whose body is cut off is fetched again on its own.
+func TestHLSSegmentRetried(t *testing.T) {
+	shortSegmentPolicy(t)
+	srv := newHLSServer(t, map[string]string{
+		"/index.m3u8": "#EXTM3U\n#EXTINF:4,\nseg0.ts\n#EXTINF:4,\nseg1.ts\n",
+		"/seg0.ts":    "segment 0|",
+		"/seg1.ts":    "segment 1",
+	})
+	var once sync.Once
+	srv.hook = func(w http.ResponseWriter, r *http.Request) bool {
+		cut := false
+		if r.URL.Path == "/seg1.ts" {
+			once.Do(func() { cut = true })
+		}
+		if cut {
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte("segm"))
+		}
+		return cut
+	}
+
+	got, err := downloadAll(t, NewHLSDownloader(), srv.URL+"/index.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "segment 0|segment 1"; got != want {
+		t.Errorf("downloaded %q, want %q", got, want)
+	}
+	if n := strings.Count(strings.Join(srv.paths(), " "), "/seg0.ts"); n != 1 {
+		t.Errorf("the intact segment was fetched %d times", n)
+	}
+}
+
+// A segment that keeps failing fails the stream after the segments before
+// it, and its buffered segments are removed.
+func TestHLSFailingSegment(t *testing.T) {
+	shortSegmentPolicy(t)
+	srv := newHLSServer(t, map[string]string{
+		"/index.m3u8": "#EXTM3U\n#EXTINF:4,\nseg0.ts\n#EXTINF:4,\nmissing.ts\n#EXTINF:4,\nseg2.ts\n",
+		"/seg0.ts":    "segment 0|",
+		"/seg2.ts":    "segment 2",
+	})
+	scratch := t.TempDir()
+	body, err := NewHLSDownloader().Download(tempdir.WithDir(context.Background(), scratch), srv.URL+"/index.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err == nil || !strings.Contains(err.Error(), "segment 2 of 3") || !strings.Contains(err.Error(), "404") {
+		t.Errorf("read err = %v, want segment 2's 404", err)
+	}
+	if string(data) != "segment 0|" {
+		t.Errorf("read %q before the failure, want the first segment", data)
+	}
+	if entries, _ := os.ReadDir(scratch); len(entries) != 0 {
+		t.Errorf("left %d entries in the scratch directory", len(entries))
+	}
+}
@@ -4,40 +4,170 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strings"
 	"time"
+
+	"scrapeanddown/internal/core/ports"
+	"scrapeanddown/internal/retry"
 )
 
 // HTTPDownloader implements ports.Downloader using standard HTTP.
 type HTTPDownloader struct {
-	client *http.Client
+	client          *http.Client
+	readIdleTimeout time.Duration // 0 = no limit
+	anyContentType  bool
 }
 
-// NewHTTPDownloader creates a new HTTPDownloader.
-func NewHTTPDownloader() *HTTPDownloader {
-	return &HTTPDownloader{
-		client: &http.Client{
-			Timeout: 30 * time.Minute, // Videos can be large
-		},
+// NewHTTPDownloader creates a new HTTPDownloader. Without options it uses
+// standard TLS verification and the Default timeouts: connecting, the TLS
+// handshake and the response headers are bounded, and the body may take
+// as long as it needs while data keeps arriving.
+func NewHTTPDownloader(opts ...Option) *HTTPDownloader {
+	s := settings{
+		dialTimeout:           DefaultDialTimeout,
+		tlsHandshakeTimeout:   DefaultTLSHandshakeTimeout,
+		responseHeaderTimeout: DefaultResponseHeaderTimeout,
+		readIdleTimeout:       DefaultReadIdleTimeout,
+	}
+	for _, opt := range opts {
+		opt(&s)
 	}
+
+	d := NewHTTPDownloaderWithClient(&http.Client{Transport: s.transport()})
+	d.readIdleTimeout = s.readIdleTimeout
+	d.anyContentType = s.anyContentType
+	return d
+}
+
+// NewHTTPDownloaderWithClient creates a new HTTPDownloader using the given
+// client, whose own timeouts apply.
+func NewHTTPDownloaderWithClient(client *http.Client) *HTTPDownloader {
+	return &HTTPDownloader{client: client}
 }
 
 // Download fetches the video from the given URL.
 func (d *HTTPDownloader) Download(ctx context.Context, videoURL string) (io.ReadCloser, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, videoURL, nil)
+	resp, err := d.DownloadFrom(ctx, videoURL, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// DownloadFrom fetches the video starting at offset using a Range request.
+// The If-Range validator makes the server send the full file instead (Offset
+// 0) when it has changed since the partial download began. Transient
+// failures to get a response (see retry.IsRetryable) are retried with
+// retry.DefaultPolicy; a body that fails mid-stream is not. A response whose
+// Content-Type is a document rather than media fails with ports.ErrNotVideo
+// before its body is read, unless WithAnyContentType or
+// ports.WithAnyContentType allows it.
+func (d *HTTPDownloader) DownloadFrom(ctx context.Context, videoURL string, offset int64, ifRange string) (*ports.DownloadResponse, error) {
+	return d.download(ctx, videoURL, offset, 0, ifRange)
+}
+
+// download is DownloadFrom for the length bytes at offset, or everything
+// from offset if length is 0. A server ignoring the range sends the full
+// file (Offset 0).
+func (d *HTTPDownloader) download(ctx context.Context, videoURL string, offset, length int64, ifRange string) (*ports.DownloadResponse, error) {
+	// Cancelled by the body's idle timeout, or when the body is closed
+	reqCtx, cancel := context.WithCancel(ctx)
+	streaming := false
+	defer func() {
+		if !streaming {
+			cancel()
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, videoURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	for key, value := range ports.RequestHeadersFrom(ctx) {
+		req.Header.Set(key, value)
+	}
+	ranged := offset > 0 || length > 0
+	switch {
+	case length > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	if ranged && ifRange != "" {
+		req.Header.Set("If-Range", ifRange)
+	}
 
-	resp, err := d.client.Do(req)
+	var resp *http.Response
+	err = retry.Do(ctx, retry.DefaultPolicy, func() error {
+		var err error
+		resp, err = d.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to download video: %w", err)
+		}
+		if retry.ShouldRetryStatus(resp.StatusCode) {
+			defer resp.Body.Close()
+			return retry.NewStatusError(resp)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to download video: %w", err)
+		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusGone {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: unexpected status code: %d", ports.ErrURLExpired, resp.StatusCode)
+	}
+
+	// The partial file no longer fits the remote one; start over
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0 && length == 0 {
+		resp.Body.Close()
+		return d.DownloadFrom(ctx, videoURL, 0, "")
+	}
+
+	start := int64(0)
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && ranged:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			resp.Body.Close()
+			return nil, fmt.Errorf("unexpected content range: %q", resp.Header.Get("Content-Range"))
+		}
+		start = offset
+	case resp.StatusCode != http.StatusOK:
 		resp.Body.Close()
 		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	return resp.Body, nil
+	contentType := resp.Header.Get("Content-Type")
+	if !d.anyContentType && !ports.AnyContentTypeFrom(ctx) && isDocumentType(contentType) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: server sent %s", ports.ErrNotVideo, contentType)
+	}
+
+	streaming = true
+	return &ports.DownloadResponse{
+		Body:         newIdleReader(resp.Body, cancel, d.readIdleTimeout),
+		Offset:       start,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		ContentType:  contentType,
+	}, nil
+}
+
+// isDocumentType reports whether a Content-Type names a web page or a
+// structured (JSON, XML) document, which a media URL only serves in place
+// of the media, e.g. an error page. Missing, generic (octet-stream) and
+// playlist types are not documents.
+func isDocumentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "application/json", "text/json", "application/xml", "text/xml":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json")
 }
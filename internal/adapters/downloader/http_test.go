@@ -0,0 +1,137 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// roundTripFunc lets a function serve as an http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestNewHTTPDownloaderWithClientUsesInjectedClient(t *testing.T) {
+	var requested string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = req.URL.String()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"video/mp4"}},
+			Body:       io.NopCloser(strings.NewReader("video bytes")),
+		}, nil
+	})}
+
+	d := NewHTTPDownloaderWithClient(client)
+	body, err := d.Download(context.Background(), "https://cdn.example.com/v.mp4")
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+
+	if requested != "https://cdn.example.com/v.mp4" {
+		t.Errorf("injected client requested %q", requested)
+	}
+	if string(data) != "video bytes" {
+		t.Errorf("body = %q, want %q", data, "video bytes")
+	}
+}
+
+func TestDownloadSendsRequestHeaders(t *testing.T) {
+	var got http.Header
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		got = req.Header
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"video/mp4"}},
+			Body:       io.NopCloser(strings.NewReader("video bytes")),
+		}, nil
+	})}
+
+	headers := map[string]string{"User-Agent": "Mozilla/5.0 (yt-dlp)", "Referer": "https://www.bilibili.com/"}
+	ctx := ports.WithRequestHeaders(context.Background(), headers)
+	body, err := NewHTTPDownloaderWithClient(client).Download(ctx, "https://cdn.example.com/v.mp4")
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	body.Close()
+	for name, value := range headers {
+		if got.Get(name) != value {
+			t.Errorf("%s = %q, want %q", name, got.Get(name), value)
+		}
+	}
+}
+
+// A page or document served in place of the video fails before its body is
+// read, unless allowed.
+func TestDownloadRejectsDocuments(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.URL.Query().Get("type"); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		} else {
+			w.Header()["Content-Type"] = nil // Don't sniff one
+		}
+		w.Write([]byte("body"))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		contentType string
+		wantErr     bool
+	}{
+		{"text/html; charset=utf-8", true},
+		{"application/xhtml+xml", true},
+		{"application/json", true},
+		{"application/problem+json", true},
+		{"text/xml", true},
+		{"application/xml", true},
+		{"video/mp4", false},
+		{"video/webm", false},
+		{"application/octet-stream", false},
+		{"application/vnd.apple.mpegurl", false},
+		{"", false},
+		{"not a media type", false},
+	}
+	for _, tt := range tests {
+		u := srv.URL + "/v?type=" + url.QueryEscape(tt.contentType)
+		body, err := NewHTTPDownloader().Download(context.Background(), u)
+		if tt.wantErr {
+			if !errors.Is(err, ports.ErrNotVideo) {
+				t.Errorf("%q: err = %v, want ErrNotVideo", tt.contentType, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.contentType, err)
+			continue
+		}
+		data, _ := io.ReadAll(body)
+		body.Close()
+		if string(data) != "body" {
+			t.Errorf("%q: body = %q", tt.contentType, data)
+		}
+	}
+
+	html := srv.URL + "/v?type=text/html"
+	if body, err := NewHTTPDownloader(WithAnyContentType()).Download(context.Background(), html); err != nil {
+		t.Errorf("WithAnyContentType: %v", err)
+	} else {
+		body.Close()
+	}
+	if body, err := NewHTTPDownloader().Download(ports.WithAnyContentType(context.Background()), html); err != nil {
+		t.Errorf("ports.WithAnyContentType: %v", err)
+	} else {
+		body.Close()
+	}
+}
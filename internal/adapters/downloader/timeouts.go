@@ -0,0 +1,137 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// Default timeouts of NewHTTPDownloader. None bounds the whole transfer, so
+// a big download may take as long as it needs while data keeps arriving.
+const (
+	DefaultDialTimeout           = 10 * time.Second
+	DefaultTLSHandshakeTimeout   = 10 * time.Second
+	DefaultResponseHeaderTimeout = 30 * time.Second
+	DefaultReadIdleTimeout       = time.Minute
+)
+
+// Option configures an HTTPDownloader created by NewHTTPDownloader.
+type Option func(*settings)
+
+type settings struct {
+	tlsSettings
+	dialTimeout           time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+	readIdleTimeout       time.Duration
+	anyContentType        bool
+	segmentConcurrency    int // HLSDownloader only
+}
+
+// WithDialTimeout bounds establishing the TCP connection, so dead hosts
+// fail fast.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(s *settings) {
+		s.dialTimeout = timeout
+	}
+}
+
+// WithTLSHandshakeTimeout bounds the TLS handshake.
+func WithTLSHandshakeTimeout(timeout time.Duration) Option {
+	return func(s *settings) {
+		s.tlsHandshakeTimeout = timeout
+	}
+}
+
+// WithResponseHeaderTimeout bounds the wait for the response headers once
+// the request is sent.
+func WithResponseHeaderTimeout(timeout time.Duration) Option {
+	return func(s *settings) {
+		s.responseHeaderTimeout = timeout
+	}
+}
+
+// WithReadIdleTimeout fails a download with ports.ErrDownloadStalled when a
+// read of the body waits timeout for data (0 disables the check). Only time
+// spent inside Read counts, so a slow consumer isn't mistaken for a stall.
+func WithReadIdleTimeout(timeout time.Duration) Option {
+	return func(s *settings) {
+		s.readIdleTimeout = timeout
+	}
+}
+
+// WithAnyContentType turns off the check that rejects documents (HTML,
+// JSON, XML) with ports.ErrNotVideo, for servers that label media wrongly.
+func WithAnyContentType() Option {
+	return func(s *settings) {
+		s.anyContentType = true
+	}
+}
+
+// transport builds the HTTP transport for the settings.
+func (s *settings) transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   s.dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = s.tlsHandshakeTimeout
+	transport.ResponseHeaderTimeout = s.responseHeaderTimeout
+	if cfg := s.tlsConfig(); cfg != nil {
+		transport.TLSClientConfig = cfg
+	}
+	return transport
+}
+
+// idleReader wraps a response body, cancelling the request when a Read
+// blocks for timeout. The timer runs only while a Read is in progress, so
+// time the consumer spends between reads (e.g. blocked on a slow disk or a
+// stalled pipe) never counts. Closing it releases the request's context.
+type idleReader struct {
+	body    io.ReadCloser
+	cancel  context.CancelFunc
+	timeout time.Duration
+	timer   *time.Timer // nil without a timeout
+	stalled atomic.Bool
+}
+
+func newIdleReader(body io.ReadCloser, cancel context.CancelFunc, timeout time.Duration) *idleReader {
+	r := &idleReader{body: body, cancel: cancel, timeout: timeout}
+	if timeout > 0 {
+		r.timer = time.AfterFunc(timeout, func() {
+			r.stalled.Store(true)
+			cancel()
+		})
+		// Armed by each Read
+		r.timer.Stop()
+	}
+	return r
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	if r.timer != nil {
+		r.timer.Reset(r.timeout)
+	}
+	n, err := r.body.Read(p)
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	if err != nil && r.stalled.Load() {
+		err = fmt.Errorf("%w: no data received for %s", ports.ErrDownloadStalled, r.timeout)
+	}
+	return n, err
+}
+
+func (r *idleReader) Close() error {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.cancel()
+	return r.body.Close()
+}
@@ -0,0 +1,106 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestIdleReaderIgnoresSlowConsumer(t *testing.T) {
+	cancelled := false
+	r := newIdleReader(io.NopCloser(strings.NewReader("abcdef")), func() { cancelled = true }, 20*time.Millisecond)
+	defer r.Close()
+
+	buf := make([]byte, 3)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	// The consumer is busy for longer than the timeout between reads
+	time.Sleep(60 * time.Millisecond)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatalf("read after a slow consumer: %v", err)
+	}
+	if cancelled {
+		t.Fatal("request cancelled although no Read was waiting")
+	}
+}
+
+func TestIdleReaderFailsBlockedRead(t *testing.T) {
+	pr, pw := io.Pipe()
+	// Cancelling the request unblocks the body, as it does for a response
+	cancel := func() { pw.CloseWithError(errors.New("request cancelled")) }
+	r := newIdleReader(pr, cancel, 20*time.Millisecond)
+	defer r.Close()
+
+	_, err := r.Read(make([]byte, 8))
+	if !errors.Is(err, ports.ErrDownloadStalled) {
+		t.Fatalf("err = %v, want ErrDownloadStalled", err)
+	}
+}
+
+// trickleServer sends chunks of the body at interval, then, if stall is
+// set, keeps the connection open without sending more.
+func trickleServer(t *testing.T, chunks []string, interval time.Duration, stall bool) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		for _, chunk := range chunks {
+			w.Write([]byte(chunk))
+			w.(http.Flusher).Flush()
+			time.Sleep(interval)
+		}
+		if stall {
+			<-r.Context().Done()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDownloadStalls(t *testing.T) {
+	srv := trickleServer(t, []string{"first bytes"}, 0, true)
+	d := NewHTTPDownloader(WithReadIdleTimeout(50 * time.Millisecond))
+
+	body, err := d.Download(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	start := time.Now()
+	got, err := io.ReadAll(body)
+	if !errors.Is(err, ports.ErrDownloadStalled) {
+		t.Fatalf("read err = %v, want ErrDownloadStalled", err)
+	}
+	if string(got) != "first bytes" {
+		t.Errorf("read %q before the stall, want the bytes sent", got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("stall detected after %s", elapsed)
+	}
+}
+
+func TestDownloadSlowButProgressing(t *testing.T) {
+	// The whole transfer takes longer than the idle timeout, each gap less
+	chunks := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	srv := trickleServer(t, chunks, 30*time.Millisecond, false)
+	d := NewHTTPDownloader(WithReadIdleTimeout(150 * time.Millisecond))
+
+	body, err := d.Download(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("slow download failed: %v", err)
+	}
+	if string(got) != strings.Join(chunks, "") {
+		t.Errorf("read %q", got)
+	}
+}
@@ -0,0 +1,80 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+type tlsSettings struct {
+	minVersion uint16
+	pins       map[string]bool
+}
+
+// WithMinTLSVersion rejects servers that can't negotiate at least version
+// (e.g. tls.VersionTLS13).
+func WithMinTLSVersion(version uint16) Option {
+	return func(s *settings) {
+		s.minVersion = version
+	}
+}
+
+// WithPinnedCertificates only accepts servers whose leaf certificate has one
+// of the given SHA-256 fingerprints (hex, colons optional). Standard chain
+// verification still applies; a mismatch fails with ports.ErrCertMismatch.
+func WithPinnedCertificates(fingerprints ...string) Option {
+	return func(s *settings) {
+		if s.pins == nil {
+			s.pins = make(map[string]bool)
+		}
+		for _, fp := range fingerprints {
+			s.pins[normalizeFingerprint(fp)] = true
+		}
+	}
+}
+
+// ParseTLSVersion maps "1.2" or "1.3" to the crypto/tls constant. Older
+// versions are rejected, since raising the minimum is the point.
+func ParseTLSVersion(value string) (uint16, error) {
+	switch value {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q: expected 1.2 or 1.3", value)
+}
+
+// tlsConfig builds the transport's TLS config, or nil to keep the defaults.
+func (s *tlsSettings) tlsConfig() *tls.Config {
+	if s.minVersion == 0 && len(s.pins) == 0 {
+		return nil
+	}
+	cfg := &tls.Config{MinVersion: s.minVersion}
+	if len(s.pins) > 0 {
+		cfg.VerifyConnection = s.verifyPin
+	}
+	return cfg
+}
+
+// verifyPin runs after standard verification and checks the leaf certificate.
+func (s *tlsSettings) verifyPin(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return ports.ErrCertMismatch
+	}
+	sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+	if !s.pins[fingerprint] {
+		return fmt.Errorf("%w (got sha256 %s)", ports.ErrCertMismatch, fingerprint)
+	}
+	return nil
+}
+
+// normalizeFingerprint lowercases a fingerprint and strips colons.
+func normalizeFingerprint(fp string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fp), ":", ""))
+}
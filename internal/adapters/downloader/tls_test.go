@@ -0,0 +1,27 @@
+package downloader
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    uint16
+		wantErr bool
+	}{
+		{value: "1.2", want: tls.VersionTLS12},
+		{value: "1.3", want: tls.VersionTLS13},
+		{value: "1.0", wantErr: true},
+		{value: "1.1", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "tls1.3", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseTLSVersion(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseTLSVersion(%q) = %d, %v; want %d, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
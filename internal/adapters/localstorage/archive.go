@@ -0,0 +1,107 @@
+package localstorage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ArchiveJob bundles the job directory into <dir>.tar (or .tar.gz when
+// gzipped is set) next to the job directory and returns the archive path.
+func (s *LocalStorage) ArchiveJob(ctx context.Context, jobID string, gzipped bool) (string, error) {
+	jobDir := s.GetJobPath(jobID)
+	archivePath := jobDir + ".tar"
+	if gzipped {
+		archivePath += ".gz"
+	}
+
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive %s: %w", archivePath, err)
+	}
+
+	if err := writeTar(ctx, file, jobDir, filepath.Base(jobDir), gzipped); err != nil {
+		file.Close()
+		os.Remove(archivePath)
+		return "", fmt.Errorf("failed to archive job %s: %w", jobID, err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(archivePath)
+		return "", fmt.Errorf("failed to write archive %s: %w", archivePath, err)
+	}
+	return archivePath, nil
+}
+
+// RemoveJob deletes the job directory and everything in it.
+func (s *LocalStorage) RemoveJob(ctx context.Context, jobID string) error {
+	path := s.GetJobPath(jobID)
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove job directory %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeTar streams jobDir into w as a tar archive rooted at prefix/.
+func writeTar(ctx context.Context, w io.Writer, jobDir, prefix string, gzipped bool) error {
+	var gz *gzip.Writer
+	if gzipped {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(jobDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// The lock file is process bookkeeping, not an artifact
+		if info.Name() == lockFileName {
+			return nil
+		}
+
+		rel, err := filepath.Rel(jobDir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(filepath.Join(prefix, rel))
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
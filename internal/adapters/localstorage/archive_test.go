@@ -0,0 +1,119 @@
+package localstorage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readTar returns the entries of the archive at path, mapping file names to
+// their content and directory names to "".
+func readTar(t *testing.T, path string, gzipped bool) map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("archive is not gzipped: %v", err)
+		}
+		r = gz
+	}
+	entries := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[header.Name] = string(data)
+	}
+}
+
+func TestArchiveJob(t *testing.T) {
+	for _, gzipped := range []bool{false, true} {
+		ctx := context.Background()
+		s := NewLocalStorage(t.TempDir())
+		if err := s.InitJob(ctx, "job1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveMetadata(ctx, "job1", []byte(`{"title":"t"}`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveVideo(ctx, "job1", strings.NewReader("video"), "video.mp4"); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(s.GetJobPath("job1"), "thumbs"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(s.GetJobPath("job1"), "thumbs", "0.jpg"), []byte("jpeg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		path, err := s.ArchiveJob(ctx, "job1", gzipped)
+		if err != nil {
+			t.Fatalf("ArchiveJob(gzip %v): %v", gzipped, err)
+		}
+		wantPath := s.GetJobPath("job1") + ".tar"
+		if gzipped {
+			wantPath += ".gz"
+		}
+		if path != wantPath {
+			t.Errorf("archive path = %s, want %s", path, wantPath)
+		}
+		// The lock file is left out
+		want := map[string]string{
+			"job1/":                  "",
+			"job1/metadata_raw.json": `{"title":"t"}`,
+			"job1/video.mp4":         "video",
+			"job1/thumbs/":           "",
+			"job1/thumbs/0.jpg":      "jpeg",
+		}
+		if got := readTar(t, path, gzipped); !reflect.DeepEqual(got, want) {
+			t.Errorf("archive (gzip %v) = %v, want %v", gzipped, got, want)
+		}
+
+		if err := s.RemoveJob(ctx, "job1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(s.GetJobPath("job1")); !os.IsNotExist(err) {
+			t.Errorf("job directory left after RemoveJob: %v", err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("RemoveJob removed the archive: %v", err)
+		}
+	}
+}
+
+func TestArchiveJobCanceled(t *testing.T) {
+	s := NewLocalStorage(t.TempDir())
+	if err := s.InitJob(context.Background(), "job1"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.ArchiveJob(ctx, "job1", false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ArchiveJob err = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(s.GetJobPath("job1") + ".tar"); !os.IsNotExist(err) {
+		t.Errorf("partial archive left behind: %v", err)
+	}
+}
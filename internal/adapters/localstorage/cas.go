@@ -0,0 +1,162 @@
+package localstorage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CASStorage is a LocalStorage that keeps videos in a content-addressable
+// store, objects/<first 2 hex>/<rest of sha256>/video.<ext> under the base
+// directory, and links them into job directories. Identical videos are
+// stored once however many jobs download them. Job directories get hard
+// links, so they look and archive like plain files; if the filesystem
+// doesn't support those, symlinks are used instead. Objects are never
+// removed, even when every job linking them is.
+type CASStorage struct {
+	*LocalStorage
+}
+
+// NewCASStorage wraps local with a content-addressable video store.
+func NewCASStorage(local *LocalStorage) *CASStorage {
+	return &CASStorage{LocalStorage: local}
+}
+
+// SaveVideo saves the video like LocalStorage, hashing it as it streams,
+// then moves it into the store and links it back.
+func (s *CASStorage) SaveVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error {
+	if filename == "" {
+		filename = "video.mp4"
+	}
+	if write, err := s.ShouldWrite(ctx, jobID, filename); !write {
+		if err != nil {
+			return fmt.Errorf("failed to save %s: %w", filename, err)
+		}
+		return nil
+	}
+	path := filepath.Join(s.GetJobPath(jobID), filename)
+	// Never write through a link into a stored object
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace video file %s: %w", path, err)
+	}
+
+	hash := sha256.New()
+	if err := s.LocalStorage.SaveVideo(ctx, jobID, io.TeeReader(reader, hash), filename); err != nil {
+		return err
+	}
+	return s.intern(path, hex.EncodeToString(hash.Sum(nil)))
+}
+
+// AppendVideo appends to a partial video like LocalStorage, then stores the
+// completed file.
+func (s *CASStorage) AppendVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error {
+	path := filepath.Join(s.GetJobPath(jobID), filename)
+	if err := s.unshare(path); err != nil {
+		return err
+	}
+	if err := s.LocalStorage.AppendVideo(ctx, jobID, reader, filename); err != nil {
+		return err
+	}
+	sum, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+	return s.intern(path, sum)
+}
+
+// objectPath returns where content with the given hash is stored.
+func (s *CASStorage) objectPath(sum, filename string) string {
+	return filepath.Join(s.BaseDir, "objects", sum[:2], sum[2:], "video"+filepath.Ext(filename))
+}
+
+// intern moves the file at path into the store, unless an identical object
+// is already there, and replaces it with a link to the object.
+func (s *CASStorage) intern(path, sum string) error {
+	object := s.objectPath(sum, path)
+	if err := os.MkdirAll(filepath.Dir(object), 0755); err != nil {
+		return fmt.Errorf("failed to create object directory: %w", err)
+	}
+	if _, err := os.Stat(object); err == nil {
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove duplicate video %s: %w", path, err)
+		}
+	} else if err := os.Rename(path, object); err != nil {
+		return fmt.Errorf("failed to store video object: %w", err)
+	}
+
+	if err := os.Link(object, path); err == nil {
+		return nil
+	}
+	absObject, err := filepath.Abs(object)
+	if err != nil {
+		return fmt.Errorf("failed to link video object: %w", err)
+	}
+	if err := os.Symlink(absObject, path); err != nil {
+		return fmt.Errorf("failed to link video object: %w", err)
+	}
+	return nil
+}
+
+// unshare replaces path with a private copy if it is linked to a stored
+// object, so that writing to it can't change the object.
+func (s *CASStorage) unshare(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to stat video file %s: %w", path, err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		sum, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		object, err := os.Stat(s.objectPath(sum, path))
+		if err != nil || !os.SameFile(info, object) {
+			return nil // Not linked to the store
+		}
+	}
+
+	tmp := path + ".tmp"
+	if err := copyFile(path, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to unshare video file %s: %w", path, err)
+	}
+	return nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open video file %s: %w", path, err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("failed to hash video file %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open video file %s: %w", src, err)
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create video file %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy video file: %w", err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,110 @@
+package localstorage
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// objects returns the contents of every stored object, keyed by path
+// relative to the store.
+func objects(t *testing.T, s *CASStorage) map[string]string {
+	t.Helper()
+	found := map[string]string{}
+	root := filepath.Join(s.BaseDir, "objects")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		found[rel] = string(data)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func saveCASVideo(t *testing.T, s *CASStorage, jobID, data string) string {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.InitJob(ctx, jobID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveVideo(ctx, jobID, strings.NewReader(data), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(s.GetJobPath(jobID), "video.mp4")
+}
+
+// Identical videos are stored once and linked from every job.
+func TestCASStorageSavesIdenticalVideosOnce(t *testing.T) {
+	s := NewCASStorage(NewLocalStorage(t.TempDir()))
+	first := saveCASVideo(t, s, "job1", "same video")
+	second := saveCASVideo(t, s, "job2", "same video")
+	saveCASVideo(t, s, "job3", "other video")
+
+	stored := objects(t, s)
+	if len(stored) != 2 {
+		t.Fatalf("objects = %v, want one per distinct video", stored)
+	}
+	var contents []string
+	for name, data := range stored {
+		if !strings.HasSuffix(name, "video.mp4") {
+			t.Errorf("object %s doesn't keep the video's extension", name)
+		}
+		contents = append(contents, data)
+	}
+	sort.Strings(contents)
+	if contents[0] != "other video" || contents[1] != "same video" {
+		t.Errorf("object contents = %q", contents)
+	}
+
+	for _, path := range []string{first, second} {
+		if data, err := os.ReadFile(path); err != nil || string(data) != "same video" {
+			t.Errorf("%s = %q, %v; want the video", path, data, err)
+		}
+	}
+	a, errA := os.Stat(first)
+	b, errB := os.Stat(second)
+	if errA != nil || errB != nil || !os.SameFile(a, b) {
+		t.Errorf("job videos aren't links to one object: %v, %v", errA, errB)
+	}
+}
+
+// Replacing or appending to a job's video never changes a stored object.
+func TestCASStorageObjectsAreImmutable(t *testing.T) {
+	ctx := context.Background()
+	s := NewCASStorage(NewLocalStorage(t.TempDir()))
+	saveCASVideo(t, s, "job1", "vid")
+	shared := saveCASVideo(t, s, "job2", "vid")
+
+	if err := s.AppendVideo(ctx, "job2", strings.NewReader("eo"), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(shared); string(data) != "video" {
+		t.Errorf("appended video = %q, want %q", data, "video")
+	}
+	if err := s.SaveVideo(ctx, "job1", strings.NewReader("replaced"), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"replaced", "vid", "video"}
+	var got []string
+	for _, data := range objects(t, s) {
+		got = append(got, data)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("object contents = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,31 @@
+package localstorage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// diskFreeSpace returns the bytes available to this process on the file
+// system holding path; tests may replace it.
+var diskFreeSpace = freeSpace
+
+// FreeSpace returns the bytes available on the file system holding the base
+// directory. If that doesn't exist yet, its nearest existing parent is used.
+func (s *LocalStorage) FreeSpace(ctx context.Context) (int64, error) {
+	dir, err := filepath.Abs(s.BaseDir)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return diskFreeSpace(dir)
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package localstorage
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func freeSpace(path string) (int64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, fmt.Errorf("failed to query free space of %s: %w", path, err)
+	}
+	// Bavail excludes blocks reserved for root
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
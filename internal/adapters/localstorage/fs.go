@@ -6,24 +6,79 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
+
+	"scrapeanddown/internal/core/ports"
 )
 
+// downloadStateFile records in-progress download state for resuming.
+const downloadStateFile = "download.state.json"
+
 // LocalStorage implements ports.Storage for the local filesystem.
 type LocalStorage struct {
 	BaseDir string
+
+	readableDirs   bool
+	externalIDDirs bool
+	writePolicy    WritePolicy
+
+	mu      sync.Mutex
+	locks   map[string]*os.File
+	saved   map[string]map[string]bool // Held job ID -> files saved since InitJob
+	dirs    map[string]string          // job ID -> readable directory
+	scanned map[string]bool            // Named directories whose job ID is in dirs
 }
 
 // NewLocalStorage creates a new LocalStorage instance.
-func NewLocalStorage(baseDir string) *LocalStorage {
-	return &LocalStorage{BaseDir: baseDir}
+func NewLocalStorage(baseDir string, opts ...Option) *LocalStorage {
+	s := &LocalStorage{BaseDir: baseDir}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// InitJob creates the job directory.
+// InitJob creates the job directory and locks it for this process.
+// Returns ports.ErrJobLocked if another process is working the same job.
 func (s *LocalStorage) InitJob(ctx context.Context, jobID string) error {
-	path := s.GetJobPath(jobID)
+	path, err := s.newJobDir(ctx, jobID)
+	if err != nil {
+		return err
+	}
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return fmt.Errorf("failed to create job directory %s: %w", path, err)
 	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, held := s.locks[jobID]; held {
+		return fmt.Errorf("%w: %s", ports.ErrJobLocked, jobID)
+	}
+	lock, err := acquireLock(path)
+	if err != nil {
+		return err
+	}
+	if s.locks == nil {
+		s.locks = make(map[string]*os.File)
+	}
+	s.locks[jobID] = lock
+	delete(s.saved, jobID)
+	return nil
+}
+
+// ReleaseJob releases the job lock taken by InitJob.
+func (s *LocalStorage) ReleaseJob(ctx context.Context, jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lock, held := s.locks[jobID]
+	if !held {
+		return nil
+	}
+	delete(s.locks, jobID)
+	delete(s.saved, jobID)
+	if err := lock.Close(); err != nil {
+		return fmt.Errorf("failed to release job lock: %w", err)
+	}
 	return nil
 }
 
@@ -36,27 +91,86 @@ func (s *LocalStorage) SaveInput(ctx context.Context, jobID string, data []byte)
 	return nil
 }
 
-// SaveMetadata saves the raw API response.
+// SaveMetadata saves the raw API response, subject to the write policy.
 func (s *LocalStorage) SaveMetadata(ctx context.Context, jobID string, data []byte) error {
-	path := filepath.Join(s.GetJobPath(jobID), "metadata_raw.json")
+	return s.saveMetadataFile(ctx, jobID, "metadata_raw.json", data)
+}
+
+// SaveProjectedMetadata saves the selected metadata fields, subject to the
+// write policy.
+func (s *LocalStorage) SaveProjectedMetadata(ctx context.Context, jobID string, data []byte) error {
+	return s.saveMetadataFile(ctx, jobID, "metadata.json", data)
+}
+
+// SaveNormalizedMetadata saves the normalized metadata, subject to the
+// write policy.
+func (s *LocalStorage) SaveNormalizedMetadata(ctx context.Context, jobID string, data []byte) error {
+	return s.saveMetadataFile(ctx, jobID, "metadata_normalized.json", data)
+}
+
+// SaveScrapeMeta saves the IDs of the scrape run, subject to the write
+// policy.
+func (s *LocalStorage) SaveScrapeMeta(ctx context.Context, jobID string, data []byte) error {
+	return s.saveMetadataFile(ctx, jobID, "scrape_meta.json", data)
+}
+
+func (s *LocalStorage) saveMetadataFile(ctx context.Context, jobID string, name string, data []byte) error {
+	if write, err := s.ShouldWrite(ctx, jobID, name); !write {
+		if err != nil {
+			return fmt.Errorf("failed to save %s: %w", name, err)
+		}
+		return nil
+	}
+	path := filepath.Join(s.GetJobPath(jobID), name)
+	s.markSaved(jobID, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save %s: %w", name, err)
+	}
+	return nil
+}
+
+// SavePage saves the video page HTML.
+func (s *LocalStorage) SavePage(ctx context.Context, jobID string, data []byte) error {
+	path := filepath.Join(s.GetJobPath(jobID), "page.html")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save page.html: %w", err)
+	}
+	return nil
+}
+
+// SaveComments saves the scraped comments.
+func (s *LocalStorage) SaveComments(ctx context.Context, jobID string, data []byte) error {
+	path := filepath.Join(s.GetJobPath(jobID), "comments.json")
 	if err := os.WriteFile(path, data, 0644); err != nil {
-		return fmt.Errorf("failed to save metadata_raw.json: %w", err)
+		return fmt.Errorf("failed to save comments.json: %w", err)
 	}
 	return nil
 }
 
-// SaveVideo saves the video file.
+// SaveVideo saves the video file, subject to the write policy. A file kept
+// under SkipExisting leaves reader unread.
 func (s *LocalStorage) SaveVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error {
 	if filename == "" {
 		filename = "video.mp4"
 	}
+	if write, err := s.ShouldWrite(ctx, jobID, filename); !write {
+		if err != nil {
+			return fmt.Errorf("failed to save %s: %w", filename, err)
+		}
+		return nil
+	}
 	path := filepath.Join(s.GetJobPath(jobID), filename)
+	// Templated names may nest the video in directories
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create video directory: %w", err)
+	}
 
 	file, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("failed to create video file %s: %w", path, err)
 	}
 	defer file.Close()
+	s.markSaved(jobID, filename)
 
 	if _, err := io.Copy(file, reader); err != nil {
 		return fmt.Errorf("failed to write video file: %w", err)
@@ -64,7 +178,166 @@ func (s *LocalStorage) SaveVideo(ctx context.Context, jobID string, reader io.Re
 	return nil
 }
 
-// GetJobPath returns the path for a job directory.
+// AppendVideo appends to an existing (partial) video file.
+func (s *LocalStorage) AppendVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error {
+	path := filepath.Join(s.GetJobPath(jobID), filename)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create video directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open video file %s: %w", path, err)
+	}
+	defer file.Close()
+	s.markSaved(jobID, filename)
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return fmt.Errorf("failed to write video file: %w", err)
+	}
+	return nil
+}
+
+// OpenVideo opens a saved video file. The returned *os.File is seekable.
+func (s *LocalStorage) OpenVideo(ctx context.Context, jobID string, filename string) (io.ReadCloser, int64, error) {
+	path := filepath.Join(s.GetJobPath(jobID), filename)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open video file %s: %w", path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to stat video file %s: %w", path, err)
+	}
+	if info.IsDir() {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to open video file %s: is a directory: %w", path, os.ErrNotExist)
+	}
+	return file, info.Size(), nil
+}
+
+// SaveDownloadState saves the in-progress download state.
+func (s *LocalStorage) SaveDownloadState(ctx context.Context, jobID string, data []byte) error {
+	path := filepath.Join(s.GetJobPath(jobID), downloadStateFile)
+	// Write-then-rename so a crash never leaves a torn state file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save %s: %w", downloadStateFile, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to save %s: %w", downloadStateFile, err)
+	}
+	return nil
+}
+
+// LoadDownloadState reads the in-progress download state.
+func (s *LocalStorage) LoadDownloadState(ctx context.Context, jobID string) ([]byte, error) {
+	path := filepath.Join(s.GetJobPath(jobID), downloadStateFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", downloadStateFile, err)
+	}
+	return data, nil
+}
+
+// RemoveDownloadState deletes the download state file, if any.
+func (s *LocalStorage) RemoveDownloadState(ctx context.Context, jobID string) error {
+	path := filepath.Join(s.GetJobPath(jobID), downloadStateFile)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", downloadStateFile, err)
+	}
+	return nil
+}
+
+// SaveStoryboard saves a storyboard image under the job's storyboards/ directory.
+func (s *LocalStorage) SaveStoryboard(ctx context.Context, jobID string, name string, data []byte) error {
+	dir := filepath.Join(s.GetJobPath(jobID), "storyboards")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create storyboards directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return fmt.Errorf("failed to save storyboard %s: %w", name, err)
+	}
+	return nil
+}
+
+// SaveReference saves the duplicate-content reference.
+func (s *LocalStorage) SaveReference(ctx context.Context, jobID string, data []byte) error {
+	path := filepath.Join(s.GetJobPath(jobID), "duplicate_of.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save duplicate_of.json: %w", err)
+	}
+	return nil
+}
+
+// RemoveArtifact deletes a job artifact.
+func (s *LocalStorage) RemoveArtifact(ctx context.Context, jobID string, filename string) error {
+	path := filepath.Join(s.GetJobPath(jobID), filename)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteMarker creates an empty marker file, via a rename so that it appears
+// all at once.
+func (s *LocalStorage) WriteMarker(ctx context.Context, jobID string, name string) error {
+	path := filepath.Join(s.GetJobPath(jobID), name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, nil, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// SaveManifest saves the job manifest.
+func (s *LocalStorage) SaveManifest(ctx context.Context, jobID string, data []byte) error {
+	path := filepath.Join(s.GetJobPath(jobID), "manifest.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save manifest.json: %w", err)
+	}
+	return nil
+}
+
+// Exists reports whether a job artifact is present.
+func (s *LocalStorage) Exists(ctx context.Context, jobID string, filename string) (bool, error) {
+	path := filepath.Join(s.GetJobPath(jobID), filename)
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to check artifact %s: %w", path, err)
+}
+
+// StatArtifact returns size and modification time of a job artifact.
+func (s *LocalStorage) StatArtifact(ctx context.Context, jobID string, filename string) (*ports.ArtifactInfo, error) {
+	path := filepath.Join(s.GetJobPath(jobID), filename)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat artifact %s: %w", path, err)
+	}
+	return &ports.ArtifactInfo{
+		Name:    filename,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UTC(),
+	}, nil
+}
+
+// GetJobPath returns the path for a job directory. With WithReadableDirs it
+// resolves the job's readable directory, falling back to the bare-ID path.
 func (s *LocalStorage) GetJobPath(jobID string) string {
+	if s.namedDirs() {
+		if path, ok := s.findJobDir(jobID); ok {
+			return path
+		}
+	}
 	return filepath.Join(s.BaseDir, "jobs", jobID)
 }
@@ -0,0 +1,103 @@
+package localstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenVideo(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocalStorage(t.TempDir())
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	const data = "not really a video"
+	if err := s.SaveVideo(ctx, "job1", strings.NewReader(data), "video.webm"); err != nil {
+		t.Fatal(err)
+	}
+
+	video, size, err := s.OpenVideo(ctx, "job1", "video.webm")
+	if err != nil {
+		t.Fatalf("OpenVideo: %v", err)
+	}
+	defer video.Close()
+	got, err := io.ReadAll(video)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != data || size != int64(len(data)) {
+		t.Errorf("OpenVideo = %q (size %d), want %q (size %d)", got, size, data, len(data))
+	}
+	if _, ok := video.(io.ReadSeeker); !ok {
+		t.Error("OpenVideo's reader isn't seekable")
+	}
+}
+
+func TestOpenVideoMissing(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocalStorage(t.TempDir())
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"video.mp4", "."} {
+		if _, _, err := s.OpenVideo(ctx, "job1", name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("OpenVideo(%q) err = %v, want os.ErrNotExist", name, err)
+		}
+	}
+}
+
+func TestStatArtifact(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocalStorage(t.TempDir())
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	const data = "not really a video"
+	if err := s.SaveVideo(ctx, "job1", strings.NewReader(data), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := s.StatArtifact(ctx, "job1", "video.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "video.mp4" || info.Size != int64(len(data)) || info.ModTime.IsZero() || info.ModTime.Location() != time.UTC {
+		t.Errorf("StatArtifact = %+v, want video.mp4 of %d bytes, modified in UTC", info, len(data))
+	}
+	if _, err := s.StatArtifact(ctx, "job1", "thumbnail.jpg"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatArtifact of a missing file err = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestExists(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocalStorage(t.TempDir())
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveVideo(ctx, "job1", strings.NewReader("video"), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		jobID, filename string
+		want            bool
+		wantErr         bool
+	}{
+		{"job1", "video.mp4", true, false},
+		{"job1", "thumbnail.jpg", false, false},
+		{"job2", "video.mp4", false, false},
+		{"job1", "video.mp4/child", false, true},
+	}
+	for _, tt := range tests {
+		got, err := s.Exists(ctx, tt.jobID, tt.filename)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Exists(%s, %s) = %v, %v; want %v, error %v", tt.jobID, tt.filename, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
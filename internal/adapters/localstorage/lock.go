@@ -0,0 +1,27 @@
+package localstorage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockFileName is the per-job lock file inside the job directory.
+const lockFileName = ".lock"
+
+// acquireLock takes an exclusive OS-level lock on the job's lock file.
+// The lock is tied to the open file handle, so the OS releases it when a
+// process crashes; a leftover .lock file from a dead process is simply
+// re-locked and never counts as held.
+func acquireLock(jobDir string) (*os.File, error) {
+	path := filepath.Join(jobDir, lockFileName)
+	f, err := openLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the owner for humans inspecting a busy job
+	_ = f.Truncate(0)
+	_, _ = f.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0)
+	return f, nil
+}
@@ -0,0 +1,102 @@
+package localstorage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// Each LocalStorage opens its own lock file, as a second process would.
+func TestJobLock(t *testing.T) {
+	ctx := context.Background()
+	base := t.TempDir()
+	first, second := NewLocalStorage(base), NewLocalStorage(base)
+
+	if err := first.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.InitJob(ctx, "job1"); !errors.Is(err, ports.ErrJobLocked) {
+		t.Errorf("second InitJob in the same process = %v, want ErrJobLocked", err)
+	}
+	if err := second.InitJob(ctx, "job1"); !errors.Is(err, ports.ErrJobLocked) {
+		t.Errorf("InitJob of a locked job = %v, want ErrJobLocked", err)
+	}
+	if err := second.InitJob(ctx, "job2"); err != nil {
+		t.Errorf("InitJob of another job: %v", err)
+	}
+
+	if err := first.ReleaseJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.ReleaseJob(ctx, "job1"); err != nil {
+		t.Errorf("ReleaseJob of a released job: %v", err)
+	}
+	if err := second.InitJob(ctx, "job1"); err != nil {
+		t.Errorf("InitJob after ReleaseJob: %v", err)
+	}
+	owner, err := os.ReadFile(filepath.Join(second.GetJobPath("job1"), lockFileName))
+	if err != nil || strings.TrimSpace(string(owner)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("lock file = %q, %v; want this process's PID", owner, err)
+	}
+}
+
+// A lock file left behind by a crashed process holds no lock.
+func TestJobLockStale(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocalStorage(t.TempDir())
+	dir := s.GetJobPath("job1")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, lockFileName), []byte("99999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatalf("InitJob over a stale lock file: %v", err)
+	}
+	owner, _ := os.ReadFile(filepath.Join(dir, lockFileName))
+	if strings.TrimSpace(string(owner)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("lock file = %q, want this process's PID", owner)
+	}
+}
+
+func TestJobLockConcurrent(t *testing.T) {
+	ctx := context.Background()
+	base := t.TempDir()
+	var (
+		wg              sync.WaitGroup
+		mu              sync.Mutex
+		acquired, fails int
+	)
+	// Kept in scope so no lock file is closed by the GC
+	stores := make([]*LocalStorage, 10)
+	for i := range stores {
+		stores[i] = NewLocalStorage(base)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := stores[i].InitJob(ctx, "job1")
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				acquired++
+			case errors.Is(err, ports.ErrJobLocked):
+				fails++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if acquired != 1 || fails != 9 {
+		t.Errorf("%d InitJob calls took the lock and %d failed, want 1 and 9", acquired, fails)
+	}
+}
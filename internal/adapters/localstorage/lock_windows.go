@@ -0,0 +1,34 @@
+//go:build windows
+
+package localstorage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// errorSharingViolation is ERROR_SHARING_VIOLATION, returned when another
+// handle already holds the file open without sharing.
+const errorSharingViolation syscall.Errno = 32
+
+func openLockFile(path string) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	// Share mode 0 makes the handle exclusive until it is closed (or the process dies)
+	h, err := syscall.CreateFile(p,
+		syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		0, nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err != nil {
+		if errors.Is(err, errorSharingViolation) {
+			return nil, fmt.Errorf("%w: %s", ports.ErrJobLocked, path)
+		}
+		return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
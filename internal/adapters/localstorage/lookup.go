@@ -0,0 +1,77 @@
+package localstorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// StoredJob is a job found in the data directory.
+type StoredJob struct {
+	Job domain.Job
+	Dir string // The job directory
+}
+
+// ListJobs returns the stored jobs whose input.json is readable, oldest
+// first.
+func (s *LocalStorage) ListJobs(ctx context.Context) ([]StoredJob, error) {
+	entries, err := os.ReadDir(filepath.Join(s.BaseDir, "jobs"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	var jobs []StoredJob
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(s.BaseDir, "jobs", entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, "input.json"))
+		if err != nil {
+			continue
+		}
+		var job domain.Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			continue
+		}
+		jobs = append(jobs, StoredJob{Job: job, Dir: dir})
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].Job.CreatedAt.Before(jobs[j].Job.CreatedAt)
+	})
+	return jobs, nil
+}
+
+// GetJobByExternalID returns the job whose input.json records externalID.
+// If several jobs share it (e.g. retried attempts), the most recently created
+// one is returned. Returns ports.ErrJobNotFound if there is none.
+func (s *LocalStorage) GetJobByExternalID(ctx context.Context, externalID string) (*domain.Job, error) {
+	if externalID == "" {
+		return nil, fmt.Errorf("%w: empty external ID", ports.ErrJobNotFound)
+	}
+	jobs, err := s.ListJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *domain.Job
+	for i := range jobs {
+		job := &jobs[i].Job
+		if job.ExternalID != externalID {
+			continue
+		}
+		if found == nil || job.CreatedAt.After(found.CreatedAt) {
+			found = job
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: external ID %s", ports.ErrJobNotFound, externalID)
+	}
+	return found, nil
+}
@@ -0,0 +1,192 @@
+package localstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// jobIDFile records the full job ID inside a readable job directory.
+const jobIDFile = ".job_id"
+
+// shortIDLen is how much of the job ID a readable directory name keeps.
+const shortIDLen = 8
+
+// Option configures a LocalStorage.
+type Option func(*LocalStorage)
+
+// WithReadableDirs names new job directories after the platform and start
+// time, e.g. "youtube-20240612-153000-1a2b3c4d", instead of the bare job ID.
+// Jobs are still looked up by their full ID; existing bare-ID directories
+// keep working.
+func WithReadableDirs() Option {
+	return func(s *LocalStorage) {
+		s.readableDirs = true
+	}
+}
+
+// WithExternalIDDirs names the directories of jobs that carry an external ID
+// after that ID, with characters unsafe in file names replaced by "_". If
+// the directory already belongs to another job (e.g. an earlier attempt),
+// the short job ID is appended as with WithReadableDirs.
+func WithExternalIDDirs() Option {
+	return func(s *LocalStorage) {
+		s.externalIDDirs = true
+	}
+}
+
+// namedDirs reports whether job directories may be named other than by job ID.
+func (s *LocalStorage) namedDirs() bool {
+	return s.readableDirs || s.externalIDDirs
+}
+
+// externalDirName turns an external ID into a safe directory name.
+func externalDirName(externalID string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, externalID)
+	// Rules out ".", ".." and hidden directories
+	if strings.HasPrefix(name, ".") {
+		name = "_" + name[1:]
+	}
+	return name
+}
+
+// readableDirName builds the directory name for a new job.
+func readableDirName(jobID string, info ports.JobInfo) string {
+	platform := info.Platform
+	if platform == "" {
+		platform = "job"
+	}
+	return fmt.Sprintf("%s-%s-%s", platform, info.CreatedAt.UTC().Format("20060102-150405"), shortJobID(jobID))
+}
+
+func shortJobID(jobID string) string {
+	if len(jobID) > shortIDLen {
+		return jobID[:shortIDLen]
+	}
+	return jobID
+}
+
+// newJobDir returns the directory for a job about to be initialised,
+// creating the readable name (and its job ID file) on first use. The
+// directory is claimed with os.Mkdir, so of two jobs racing for a name one
+// gets a suffixed name instead.
+func (s *LocalStorage) newJobDir(ctx context.Context, jobID string) (string, error) {
+	if !s.namedDirs() {
+		return s.GetJobPath(jobID), nil
+	}
+	if path, ok := s.findJobDir(jobID); ok {
+		return path, nil
+	}
+	info, _ := ports.JobInfoFrom(ctx)
+	var names []string
+	switch {
+	case s.externalIDDirs && info.ExternalID != "":
+		name := externalDirName(info.ExternalID)
+		names = []string{name, name + "-" + shortJobID(jobID)}
+	case s.readableDirs:
+		names = []string{readableDirName(jobID, info)}
+	default:
+		return s.GetJobPath(jobID), nil
+	}
+	base := names[len(names)-1]
+	for n := 2; n <= maxDirSuffix; n++ {
+		names = append(names, fmt.Sprintf("%s-%d", base, n))
+	}
+
+	jobsDir := filepath.Join(s.BaseDir, "jobs")
+	if err := os.MkdirAll(jobsDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create job directory %s: %w", jobsDir, err)
+	}
+	for _, name := range names {
+		path := filepath.Join(jobsDir, name)
+		err := os.Mkdir(path, 0755)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create job directory %s: %w", path, err)
+		}
+		if err := os.WriteFile(filepath.Join(path, jobIDFile), []byte(jobID), 0644); err != nil {
+			return "", fmt.Errorf("failed to save %s: %w", jobIDFile, err)
+		}
+		s.rememberDir(jobID, path)
+		return path, nil
+	}
+	return "", fmt.Errorf("failed to create job directory for %s: %s and its %d suffixed names are taken", jobID, names[0], maxDirSuffix-1)
+}
+
+// maxDirSuffix bounds the numbered names newJobDir tries for a taken name.
+const maxDirSuffix = 100
+
+// findJobDir locates an existing named directory for jobID, confirmed
+// against its job ID file. Each directory's job ID file is read once and
+// remembered, so a miss only lists the jobs directory. Without
+// WithExternalIDDirs only directories with the job's short-ID suffix can be
+// its own.
+func (s *LocalStorage) findJobDir(jobID string) (string, bool) {
+	s.mu.Lock()
+	path, ok := s.dirs[jobID]
+	s.mu.Unlock()
+	if ok {
+		return path, true
+	}
+
+	suffix := "-" + shortJobID(jobID)
+	entries, err := os.ReadDir(filepath.Join(s.BaseDir, "jobs"))
+	if err != nil {
+		return "", false
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || (!s.externalIDDirs && !strings.Contains(entry.Name(), suffix)) {
+			continue
+		}
+		s.mu.Lock()
+		known := s.scanned[entry.Name()]
+		s.mu.Unlock()
+		if known {
+			continue
+		}
+		candidate := filepath.Join(s.BaseDir, "jobs", entry.Name())
+		data, err := os.ReadFile(filepath.Join(candidate, jobIDFile))
+		if err != nil {
+			// Not a named directory, or one whose job ID file isn't written yet
+			continue
+		}
+		id := strings.TrimSpace(string(data))
+		s.rememberDir(id, candidate)
+		if id == jobID {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// forgetDir drops a removed job directory from the lookups of findJobDir.
+func (s *LocalStorage) forgetDir(jobID, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.dirs, jobID)
+	delete(s.scanned, filepath.Base(path))
+}
+
+func (s *LocalStorage) rememberDir(jobID, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dirs == nil {
+		s.dirs = make(map[string]string)
+		s.scanned = make(map[string]bool)
+	}
+	s.dirs[jobID] = path
+	s.scanned[filepath.Base(path)] = true
+}
@@ -0,0 +1,133 @@
+package localstorage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestExternalIDDirsConcurrentJobs(t *testing.T) {
+	s := NewLocalStorage(t.TempDir(), WithExternalIDDirs())
+	ctx := ports.WithJobInfo(context.Background(), ports.JobInfo{ExternalID: "order/42"})
+	ids := []string{"11111111-aaaa", "22222222-bbbb", "33333333-cccc"}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(ids))
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.InitJob(ctx, id)
+		}()
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for i, id := range ids {
+		if errs[i] != nil {
+			t.Fatalf("InitJob(%s): %v", id, errs[i])
+		}
+		path := s.GetJobPath(id)
+		if seen[path] {
+			t.Errorf("jobs share directory %s", path)
+		}
+		seen[path] = true
+		data, err := os.ReadFile(filepath.Join(path, jobIDFile))
+		if err != nil || string(data) != id {
+			t.Errorf("%s/%s = %q, %v; want %q", path, jobIDFile, data, err, id)
+		}
+	}
+	if !seen[filepath.Join(s.BaseDir, "jobs", "order_42")] {
+		t.Errorf("no job got the plain external ID directory: %v", seen)
+	}
+}
+
+func TestReadableDirName(t *testing.T) {
+	created := time.Date(2024, 6, 12, 15, 30, 5, 0, time.UTC)
+	tests := []struct {
+		jobID string
+		info  ports.JobInfo
+		want  string
+	}{
+		{"1a2b3c4d-5e6f-7a8b", ports.JobInfo{Platform: "youtube", CreatedAt: created}, "youtube-20240612-153005-1a2b3c4d"},
+		{"1a2b3c4d-5e6f-7a8b", ports.JobInfo{Platform: "tiktok", CreatedAt: created.In(time.FixedZone("UTC+7", 7*3600))}, "tiktok-20240612-153005-1a2b3c4d"},
+		{"1a2b3c4d-5e6f-7a8b", ports.JobInfo{CreatedAt: created}, "job-20240612-153005-1a2b3c4d"},
+		{"abc", ports.JobInfo{Platform: "youtube", CreatedAt: created}, "youtube-20240612-153005-abc"},
+	}
+	for _, tt := range tests {
+		if got := readableDirName(tt.jobID, tt.info); got != tt.want {
+			t.Errorf("readableDirName(%s, %+v) = %s, want %s", tt.jobID, tt.info, got, tt.want)
+		}
+	}
+}
+
+// Jobs whose readable names collide get numbered directories, and each is
+// still found by its full ID.
+func TestReadableDirsLookupByJobID(t *testing.T) {
+	base := t.TempDir()
+	ctx := ports.WithJobInfo(context.Background(), ports.JobInfo{
+		Platform:  "tiktok",
+		CreatedAt: time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC),
+	})
+	s := NewLocalStorage(base, WithReadableDirs())
+	ids := []string{"1a2b3c4d-first", "1a2b3c4d-second"}
+	for _, id := range ids {
+		if err := s.InitJob(ctx, id); err != nil {
+			t.Fatalf("InitJob(%s): %v", id, err)
+		}
+		if err := s.SaveMetadata(ctx, id, []byte(id)); err != nil {
+			t.Fatalf("SaveMetadata(%s): %v", id, err)
+		}
+	}
+
+	fresh := NewLocalStorage(base, WithReadableDirs())
+	for i, name := range []string{"tiktok-20240612-153000-1a2b3c4d", "tiktok-20240612-153000-1a2b3c4d-2"} {
+		want := filepath.Join(base, "jobs", name)
+		if got := fresh.GetJobPath(ids[i]); got != want {
+			t.Errorf("GetJobPath(%s) = %s, want %s", ids[i], got, want)
+		}
+		if data, err := os.ReadFile(filepath.Join(want, "metadata_raw.json")); err != nil || string(data) != ids[i] {
+			t.Errorf("%s metadata = %q, %v; want %q", name, data, err, ids[i])
+		}
+	}
+
+	// Without the option, jobs keep their bare-ID directories
+	plain := NewLocalStorage(t.TempDir())
+	if err := plain.InitJob(ctx, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := plain.GetJobPath(ids[0]), filepath.Join(plain.BaseDir, "jobs", ids[0]); got != want {
+		t.Errorf("GetJobPath without WithReadableDirs = %s, want %s", got, want)
+	}
+}
+
+func TestReadableDirsFoundByOtherInstance(t *testing.T) {
+	base := t.TempDir()
+	ctx := ports.WithJobInfo(context.Background(), ports.JobInfo{
+		Platform:  "youtube",
+		CreatedAt: time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC),
+	})
+	first := NewLocalStorage(base, WithReadableDirs())
+	if err := first.InitJob(ctx, "1a2b3c4d-rest"); err != nil {
+		t.Fatalf("InitJob: %v", err)
+	}
+	want := filepath.Join(base, "jobs", "youtube-20240612-153000-1a2b3c4d")
+	if got := first.GetJobPath("1a2b3c4d-rest"); got != want {
+		t.Errorf("GetJobPath = %s, want %s", got, want)
+	}
+
+	// A fresh instance finds it on disk, and falls back to the bare ID for
+	// unknown jobs
+	second := NewLocalStorage(base, WithReadableDirs())
+	if got := second.GetJobPath("1a2b3c4d-rest"); got != want {
+		t.Errorf("GetJobPath from a new instance = %s, want %s", got, want)
+	}
+	if got := second.GetJobPath("ffffffff-none"); got != filepath.Join(base, "jobs", "ffffffff-none") {
+		t.Errorf("GetJobPath for an unknown job = %s", got)
+	}
+}
@@ -0,0 +1,89 @@
+package localstorage
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// WritePolicy decides what SaveVideo and the metadata saves do when their
+// file already exists, e.g. from an earlier run in the same job directory.
+type WritePolicy int
+
+const (
+	// Overwrite replaces the existing file (the default).
+	Overwrite WritePolicy = iota
+	// SkipExisting keeps the existing file; the save reports success
+	// without reading or writing anything.
+	SkipExisting
+	// ErrorIfExists fails the save with an error wrapping os.ErrExist.
+	ErrorIfExists
+)
+
+// writePolicyNames are the WritePolicy spellings ParseWritePolicy accepts.
+var writePolicyNames = map[string]WritePolicy{
+	"overwrite":       Overwrite,
+	"skip-existing":   SkipExisting,
+	"error-if-exists": ErrorIfExists,
+}
+
+// ParseWritePolicy parses "overwrite", "skip-existing" or "error-if-exists".
+func ParseWritePolicy(name string) (WritePolicy, error) {
+	policy, ok := writePolicyNames[name]
+	if !ok {
+		return Overwrite, fmt.Errorf("unknown write policy %q: expected overwrite, skip-existing or error-if-exists", name)
+	}
+	return policy, nil
+}
+
+// WithWritePolicy sets what saving over an existing video or metadata file
+// does.
+func WithWritePolicy(policy WritePolicy) Option {
+	return func(s *LocalStorage) {
+		s.writePolicy = policy
+	}
+}
+
+// ShouldWrite applies the write policy to the job's file, reporting whether
+// the save should go ahead; it implements ports.WriteChecker. Under
+// ErrorIfExists an existing file is os.ErrExist. The policy is about files
+// from earlier runs: files saved since the job was initialized can always be
+// rewritten, so a retried attempt replaces the failed attempt's.
+func (s *LocalStorage) ShouldWrite(ctx context.Context, jobID string, filename string) (bool, error) {
+	if s.writePolicy == Overwrite || s.savedInJob(jobID, filename) {
+		return true, nil
+	}
+	exists, err := s.Exists(ctx, jobID, filename)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case !exists:
+		return true, nil
+	case s.writePolicy == SkipExisting:
+		return false, nil
+	}
+	return false, os.ErrExist
+}
+
+// markSaved records that the held job's file was written since InitJob.
+func (s *LocalStorage) markSaved(jobID, filename string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, held := s.locks[jobID]; !held {
+		return
+	}
+	if s.saved == nil {
+		s.saved = make(map[string]map[string]bool)
+	}
+	if s.saved[jobID] == nil {
+		s.saved[jobID] = make(map[string]bool)
+	}
+	s.saved[jobID][filename] = true
+}
+
+func (s *LocalStorage) savedInJob(jobID, filename string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saved[jobID][filename]
+}
@@ -0,0 +1,71 @@
+package localstorage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShouldWrite(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		policy  WritePolicy
+		want    bool
+		wantErr error
+	}{
+		{Overwrite, true, nil},
+		{SkipExisting, false, nil},
+		{ErrorIfExists, false, os.ErrExist},
+	}
+	for _, tt := range tests {
+		s := NewLocalStorage(t.TempDir(), WithWritePolicy(tt.policy))
+		if err := s.InitJob(ctx, "job1"); err != nil {
+			t.Fatal(err)
+		}
+		if write, err := s.ShouldWrite(ctx, "job1", "video.mp4"); !write || err != nil {
+			t.Errorf("policy %d: ShouldWrite without a file = %v, %v", tt.policy, write, err)
+		}
+		if err := os.WriteFile(filepath.Join(s.GetJobPath("job1"), "video.mp4"), []byte("earlier run"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		write, err := s.ShouldWrite(ctx, "job1", "video.mp4")
+		if write != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("policy %d: ShouldWrite over a file = %v, %v, want %v, %v", tt.policy, write, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// Files saved while the job is held can be saved again, as a retry does;
+// after the job is released they count as an earlier run's.
+func TestShouldWriteLetsTheJobRewriteItsFiles(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocalStorage(t.TempDir(), WithWritePolicy(ErrorIfExists))
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveMetadata(ctx, "job1", []byte(`[{}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveVideo(ctx, "job1", strings.NewReader("first attempt"), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveMetadata(ctx, "job1", []byte(`[{"retry":true}]`)); err != nil {
+		t.Errorf("retried SaveMetadata: %v", err)
+	}
+	if err := s.SaveVideo(ctx, "job1", strings.NewReader("second attempt"), "video.mp4"); err != nil {
+		t.Errorf("retried SaveVideo: %v", err)
+	}
+
+	if err := s.ReleaseJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveMetadata(ctx, "job1", []byte(`[{}]`)); !errors.Is(err, os.ErrExist) {
+		t.Errorf("SaveMetadata in a later run err = %v, want os.ErrExist", err)
+	}
+}
@@ -0,0 +1,159 @@
+package localstorage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// markerFiles are the markers a finished job's directory gets (see
+// service.writeMarker); the newest one dates its completion.
+var markerFiles = []string{"_SUCCESS", "_FAILED", "_SKIPPED"}
+
+// retainedJob is a job directory considered by EnforceMaxJobs.
+type retainedJob struct {
+	id          string
+	dir         string
+	time        time.Time // Completion, or last modification if unfinished
+	duplicateOf string    // Job holding this job's video, from duplicate_of.json
+}
+
+// EnforceMaxJobs removes the oldest job directories until at most max
+// remain, returning the IDs of the removed jobs. Jobs are dated by their
+// completion marker, or by their directory's modification time if they
+// have none. Jobs locked by this or another process are in progress and
+// never removed, and neither are jobs holding the video of a -dedup-content
+// duplicate, so more than max may remain.
+func (s *LocalStorage) EnforceMaxJobs(ctx context.Context, max int) ([]string, error) {
+	if max < 1 {
+		return nil, fmt.Errorf("invalid max jobs %d: must be at least 1", max)
+	}
+	jobs, err := s.retainedJobs()
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := make(map[string]bool)
+	for _, job := range jobs {
+		if job.duplicateOf != "" {
+			referenced[job.duplicateOf] = true
+		}
+	}
+
+	var removed []string
+	excess := len(jobs) - max
+	for _, job := range jobs {
+		if excess <= 0 {
+			break
+		}
+		if referenced[job.id] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		ok, err := s.removeIdleJob(job)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed = append(removed, job.id)
+			excess--
+		}
+	}
+	return removed, nil
+}
+
+// retainedJobs lists the job directories, oldest first.
+func (s *LocalStorage) retainedJobs() ([]retainedJob, error) {
+	entries, err := os.ReadDir(filepath.Join(s.BaseDir, "jobs"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	var jobs []retainedJob
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join(s.BaseDir, "jobs", entry.Name())
+		job := retainedJob{id: entry.Name(), dir: dir, time: info.ModTime()}
+		if data, err := os.ReadFile(filepath.Join(dir, jobIDFile)); err == nil {
+			job.id = strings.TrimSpace(string(data))
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, "duplicate_of.json")); err == nil {
+			var ref ports.ContentRef
+			if json.Unmarshal(data, &ref) == nil {
+				job.duplicateOf = ref.JobID
+			}
+		}
+		var completed time.Time
+		for _, name := range markerFiles {
+			if marker, err := os.Stat(filepath.Join(dir, name)); err == nil && marker.ModTime().After(completed) {
+				completed = marker.ModTime()
+			}
+		}
+		if !completed.IsZero() {
+			job.time = completed
+		}
+		jobs = append(jobs, job)
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].time.Before(jobs[j].time)
+	})
+	return jobs, nil
+}
+
+// removeIdleJob removes the job's directory unless the job is in progress,
+// reporting whether it did. The job's lock is held while its artifacts are
+// removed, so no run can start on it meanwhile.
+func (s *LocalStorage) removeIdleJob(job retainedJob) (bool, error) {
+	s.mu.Lock()
+	_, held := s.locks[job.id]
+	s.mu.Unlock()
+	if held {
+		return false, nil
+	}
+	lock, err := acquireLock(job.dir)
+	if errors.Is(err, ports.ErrJobLocked) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// The lock file goes last: Windows can't delete it while it is open
+	entries, err := os.ReadDir(job.dir)
+	if err != nil {
+		lock.Close()
+		return false, fmt.Errorf("failed to read job directory %s: %w", job.dir, err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == lockFileName {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(job.dir, entry.Name())); err != nil {
+			lock.Close()
+			return false, fmt.Errorf("failed to remove job directory %s: %w", job.dir, err)
+		}
+	}
+	lock.Close()
+	if err := os.RemoveAll(job.dir); err != nil {
+		return false, fmt.Errorf("failed to remove job directory %s: %w", job.dir, err)
+	}
+
+	s.forgetDir(job.id, job.dir)
+	return true, nil
+}
@@ -0,0 +1,49 @@
+package localstorage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A job holding the video of a -dedup-content duplicate stays while the
+// duplicate does, however old it is.
+func TestEnforceMaxJobsKeepsReferencedJobs(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocalStorage(t.TempDir())
+	start := time.Now().Add(-time.Hour)
+	for i, id := range []string{"original", "plain", "duplicate"} {
+		if err := s.InitJob(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if id == "duplicate" {
+			if err := s.SaveReference(ctx, id, []byte(`{"job_id": "original", "filename": "video.mp4"}`)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.WriteMarker(ctx, id, "_SUCCESS"); err != nil {
+			t.Fatal(err)
+		}
+		completed := start.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(s.GetJobPath(id), "_SUCCESS"), completed, completed); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.ReleaseJob(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := s.EnforceMaxJobs(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"plain"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %q, want %q", removed, want)
+	}
+	if _, err := os.Stat(s.GetJobPath("original")); err != nil {
+		t.Errorf("referenced job removed: %v", err)
+	}
+}
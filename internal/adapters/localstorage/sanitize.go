@@ -0,0 +1,94 @@
+package localstorage
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxFilenameBytes keeps sanitized names well under the common 255-byte
+// limit, leaving room for suffixes such as ".tmp".
+const maxFilenameBytes = 200
+
+// maxExtBytes is the longest suffix SanitizeFilename treats as an extension.
+const maxExtBytes = 16
+
+// windowsReserved are device names Windows refuses as file names, with or
+// without an extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFilename turns untrusted text, such as a video title, into a file
+// name that is safe on Linux, macOS and Windows. It normalizes to NFC, so
+// a title spelled with combining marks (as macOS stores names) maps to the
+// same name as its precomposed form, drops control and
+// invisible formatting characters (zero-width spaces, bidi overrides),
+// replaces path separators and characters Windows rejects with "_",
+// collapses whitespace, escapes reserved device names, and truncates to
+// maxFilenameBytes at a character boundary, keeping the extension.
+// Emoji and other printable Unicode are kept.
+func SanitizeFilename(name string) string {
+	name = norm.NFC.String(strings.ToValidUTF8(name, "_"))
+
+	var b strings.Builder
+	space := false
+	for _, r := range name {
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		if strings.ContainsRune(`/\<>:"|?*`, r) {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+
+	// Windows strips trailing dots and spaces, and leading dots hide files
+	clean := strings.TrimRight(b.String(), ". ")
+	if strings.HasPrefix(clean, ".") {
+		clean = "_" + clean[1:]
+	}
+	if clean == "" {
+		return "_"
+	}
+
+	base := clean
+	if i := strings.IndexByte(clean, '.'); i >= 0 {
+		base = clean[:i]
+	}
+	if windowsReserved[strings.ToUpper(strings.TrimRight(base, " "))] {
+		clean = "_" + clean
+	}
+	return truncateFilename(clean, maxFilenameBytes)
+}
+
+// truncateFilename shortens name to at most max bytes without splitting a
+// character, keeping a short extension intact.
+func truncateFilename(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) > maxExtBytes || strings.ContainsRune(ext, ' ') {
+		ext = ""
+	}
+	base := name[:len(name)-len(ext)]
+	limit := max - len(ext)
+	for limit > 0 && !utf8.RuneStart(base[limit]) {
+		limit--
+	}
+	return strings.TrimRight(base[:limit], ". ") + ext
+}
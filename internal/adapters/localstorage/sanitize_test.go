@@ -0,0 +1,100 @@
+package localstorage
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "My Video.mp4", "My Video.mp4"},
+		{"emoji kept", "Party 🎉🔥.mp4", "Party 🎉🔥.mp4"},
+		{"non-latin kept", "日本語のタイトル", "日本語のタイトル"},
+		{"rtl override dropped", "evil\u202Egpj.exe", "evilgpj.exe"},
+		{"rtl mark dropped", "\u200Fשלום\u200F", "שלום"},
+		{"zero-width dropped", "zero\u200Bwidth\uFEFF", "zerowidth"},
+		{"control characters dropped", "a\x00b\x07c\x1b[0m", "abc[0m"},
+		{"newlines and tabs collapse", "line one\n\tline  two", "line one line two"},
+		{"leading and trailing space trimmed", "   padded   ", "padded"},
+		{"path separators replaced", "../etc/passwd", "_._etc_passwd"},
+		{"windows separators replaced", `C:\Windows\system32`, "C__Windows_system32"},
+		{"windows reserved characters replaced", `a<b>c:d"e|f?g*h`, "a_b_c_d_e_f_g_h"},
+		{"trailing dots trimmed", "name...", "name"},
+		{"leading dot escaped", ".hidden", "_hidden"},
+		{"reserved name", "CON", "_CON"},
+		{"reserved name lowercase", "nul", "_nul"},
+		{"reserved name with extension", "aux.txt", "_aux.txt"},
+		{"reserved com port", "COM1.mp4", "_COM1.mp4"},
+		{"reserved prefix is fine", "CONSOLE.mp4", "CONSOLE.mp4"},
+		{"empty", "", "_"},
+		{"only invisible", "\u200B\u202E", "_"},
+		{"only dots", "...", "_"},
+		{"invalid utf-8", "bad\xffbyte", "bad_byte"},
+		{"nfd composed", "Cafe\u0301.mp4", "Caf\u00e9.mp4"},
+		{"nfc kept", "Caf\u00e9.mp4", "Caf\u00e9.mp4"},
+		{"nfd hangul composed", "\u1112\u1161\u11ab", "\ud55c"},
+		{"nfd with stacked marks", "a\u0323\u0302", "\u1ead"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFilename(tt.in); got != tt.want {
+				t.Errorf("SanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilenameTruncates(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantExt string
+	}{
+		{"ascii keeps extension", strings.Repeat("a", 300) + ".mp4", ".mp4"},
+		{"multibyte at the boundary", strings.Repeat("é", 150) + ".webm", ".webm"},
+		{"emoji", strings.Repeat("🎉", 80) + ".mp4", ".mp4"},
+		{"long suffix isn't an extension", strings.Repeat("a", 150) + "." + strings.Repeat("b", 100), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeFilename(tt.in)
+			if len(got) > maxFilenameBytes {
+				t.Errorf("len = %d, want at most %d", len(got), maxFilenameBytes)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncation split a character: %q", got)
+			}
+			if tt.wantExt != "" && !strings.HasSuffix(got, tt.wantExt) {
+				t.Errorf("%q lost its extension %s", got, tt.wantExt)
+			}
+		})
+	}
+}
+
+// A title spelled with combining marks (NFD, as macOS file names are) and
+// its precomposed spelling (NFC) name the same file, even when truncated.
+func TestSanitizeFilenameNFDMatchesNFC(t *testing.T) {
+	tests := []struct {
+		name     string
+		nfd, nfc string
+	}{
+		{"accent", "Re\u0301sume\u0301 final.mp4", "R\u00e9sum\u00e9 final.mp4"},
+		{"vietnamese", "Tie\u0302\u0301ng Vie\u0323\u0302t", "Ti\u1ebfng Vi\u1ec7t"},
+		{"truncated", strings.Repeat("e\u0301", 150) + ".mp4", strings.Repeat("\u00e9", 150) + ".mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.nfd == tt.nfc {
+				t.Fatal("test spellings are identical")
+			}
+			if got, want := SanitizeFilename(tt.nfd), SanitizeFilename(tt.nfc); got != want {
+				t.Errorf("NFD spelling = %q, NFC spelling = %q; want the same name", got, want)
+			}
+		})
+	}
+}
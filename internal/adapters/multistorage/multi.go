@@ -0,0 +1,500 @@
+package multistorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// MultiStorage implements ports.Storage by writing every artifact to
+// several stores, e.g. local disk for fast access plus a mirror for
+// durability. The first store is the primary: reads and GetJobPath use it.
+type MultiStorage struct {
+	stores        []ports.Storage
+	bestEffort    bool
+	onError       func(error)
+	mirrorTimeout time.Duration
+}
+
+// DefaultMirrorTimeout is how long a mirror may take to accept a chunk of a
+// video stream before NewMultiStorage drops it from the stream.
+const DefaultMirrorTimeout = 30 * time.Second
+
+// mirrorBuffer is the number of stream chunks queued for each mirror, so one
+// that is briefly slow doesn't hold up the primary.
+const mirrorBuffer = 64
+
+// Option configures a MultiStorage.
+type Option func(*MultiStorage)
+
+// WithMirrorTimeout sets how long a mirror may take to accept a chunk of a
+// video stream once its buffer is full. A mirror that takes longer fails
+// its write, so it can't stall the primary (0 waits forever).
+func WithMirrorTimeout(timeout time.Duration) Option {
+	return func(m *MultiStorage) {
+		m.mirrorTimeout = timeout
+	}
+}
+
+// WithBestEffort makes writes succeed as long as the primary's does.
+// Failures of the other stores are passed to onError (if set) instead of
+// failing the write.
+func WithBestEffort(onError func(error)) Option {
+	return func(m *MultiStorage) {
+		m.bestEffort = true
+		m.onError = onError
+	}
+}
+
+// NewMultiStorage creates a MultiStorage writing to primary and mirrors.
+// Without WithBestEffort a write fails if any store fails.
+func NewMultiStorage(primary ports.Storage, mirrors []ports.Storage, opts ...Option) *MultiStorage {
+	m := &MultiStorage{stores: append([]ports.Storage{primary}, mirrors...), mirrorTimeout: DefaultMirrorTimeout}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// InitJob initializes the job in every store. If that fails, the stores
+// already initialized are released again.
+func (m *MultiStorage) InitJob(ctx context.Context, jobID string) error {
+	errs := make([]error, len(m.stores))
+	for i, s := range m.stores {
+		errs[i] = s.InitJob(ctx, jobID)
+	}
+	err := m.result(errs)
+	if err != nil {
+		for i, s := range m.stores {
+			if errs[i] == nil {
+				s.ReleaseJob(ctx, jobID)
+			}
+		}
+	}
+	return err
+}
+
+// ReleaseJob releases the job in every store.
+func (m *MultiStorage) ReleaseJob(ctx context.Context, jobID string) error {
+	return m.each(func(s ports.Storage) error { return s.ReleaseJob(ctx, jobID) })
+}
+
+// SaveInput saves the job input to every store.
+func (m *MultiStorage) SaveInput(ctx context.Context, jobID string, data []byte) error {
+	return m.each(func(s ports.Storage) error { return s.SaveInput(ctx, jobID, data) })
+}
+
+// SaveMetadata saves the raw metadata to every store.
+func (m *MultiStorage) SaveMetadata(ctx context.Context, jobID string, data []byte) error {
+	return m.each(func(s ports.Storage) error { return s.SaveMetadata(ctx, jobID, data) })
+}
+
+// SaveProjectedMetadata saves the selected metadata fields to every store.
+func (m *MultiStorage) SaveProjectedMetadata(ctx context.Context, jobID string, data []byte) error {
+	return m.each(func(s ports.Storage) error { return s.SaveProjectedMetadata(ctx, jobID, data) })
+}
+
+// SavePage saves the page HTML to every store.
+func (m *MultiStorage) SavePage(ctx context.Context, jobID string, data []byte) error {
+	return m.each(func(s ports.Storage) error { return s.SavePage(ctx, jobID, data) })
+}
+
+// SaveNormalizedMetadata saves the normalized metadata to every store.
+func (m *MultiStorage) SaveNormalizedMetadata(ctx context.Context, jobID string, data []byte) error {
+	return m.each(func(s ports.Storage) error { return s.SaveNormalizedMetadata(ctx, jobID, data) })
+}
+
+// SaveComments saves the comments to every store.
+func (m *MultiStorage) SaveComments(ctx context.Context, jobID string, data []byte) error {
+	return m.each(func(s ports.Storage) error { return s.SaveComments(ctx, jobID, data) })
+}
+
+// SaveScrapeMeta saves the scrape run's IDs to every store.
+func (m *MultiStorage) SaveScrapeMeta(ctx context.Context, jobID string, data []byte) error {
+	return m.each(func(s ports.Storage) error { return s.SaveScrapeMeta(ctx, jobID, data) })
+}
+
+// SaveVideo streams reader to every store at once; it is read only once.
+// Each store's write policy (see ports.WriteChecker) is applied up front:
+// stores keeping their copy are left out of the stream, and if the primary
+// keeps its copy, reader is left unread and the mirrors that want the
+// video get the primary's copy instead.
+func (m *MultiStorage) SaveVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error {
+	errs := make([]error, len(m.stores))
+	write := make([]bool, len(m.stores))
+	for i, s := range m.stores {
+		write[i], errs[i] = shouldWrite(ctx, s, jobID, filename)
+	}
+	if errs[0] != nil {
+		return m.result(errs)
+	}
+
+	var mirrors []int
+	for i := 1; i < len(m.stores); i++ {
+		if write[i] && errs[i] == nil {
+			mirrors = append(mirrors, i)
+		}
+	}
+	if !write[0] {
+		for _, i := range mirrors {
+			errs[i] = m.copyVideo(ctx, i, jobID, filename)
+		}
+		return m.result(errs)
+	}
+	streamed := m.stream(ctx, mirrors, reader, func(ctx context.Context, s ports.Storage, r io.Reader) error {
+		return s.SaveVideo(ctx, jobID, r, filename)
+	})
+	for i, err := range streamed {
+		if errs[i] == nil {
+			errs[i] = err
+		}
+	}
+	return m.result(errs)
+}
+
+// ShouldWrite reports whether SaveVideo would write the file to any store,
+// failing if a store's policy fails the save; it implements
+// ports.WriteChecker.
+func (m *MultiStorage) ShouldWrite(ctx context.Context, jobID string, filename string) (bool, error) {
+	errs := make([]error, len(m.stores))
+	anyWrite := false
+	for i, s := range m.stores {
+		var write bool
+		write, errs[i] = shouldWrite(ctx, s, jobID, filename)
+		anyWrite = anyWrite || (write && errs[i] == nil)
+	}
+	if m.bestEffort {
+		// SaveVideo reports the mirrors' failures
+		errs = errs[:1]
+	}
+	return anyWrite, m.result(errs)
+}
+
+// shouldWrite asks s's write policy, if it has one, whether to save the file.
+func shouldWrite(ctx context.Context, s ports.Storage, jobID, filename string) (bool, error) {
+	checker, ok := s.(ports.WriteChecker)
+	if !ok {
+		return true, nil
+	}
+	return checker.ShouldWrite(ctx, jobID, filename)
+}
+
+// AppendVideo streams reader to every store at once, like SaveVideo. A
+// mirror whose copy differs in size from the primary's missed part of an
+// earlier write (with WithBestEffort, or in an earlier run), so appending
+// to it would corrupt it; it is rewritten in full from the primary instead.
+func (m *MultiStorage) AppendVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error {
+	primarySize := m.videoSize(ctx, 0, jobID, filename)
+	var inSync, stale []int
+	for i := 1; i < len(m.stores); i++ {
+		if m.videoSize(ctx, i, jobID, filename) == primarySize {
+			inSync = append(inSync, i)
+		} else {
+			stale = append(stale, i)
+		}
+	}
+
+	errs := m.stream(ctx, inSync, reader, func(ctx context.Context, s ports.Storage, r io.Reader) error {
+		return s.AppendVideo(ctx, jobID, r, filename)
+	})
+	if errs[0] == nil {
+		for _, i := range stale {
+			errs[i] = m.copyVideo(ctx, i, jobID, filename)
+		}
+	}
+	return m.result(errs)
+}
+
+// videoSize returns the size of store i's copy of the video, or -1 if it
+// has none.
+func (m *MultiStorage) videoSize(ctx context.Context, i int, jobID, filename string) int64 {
+	info, err := m.stores[i].StatArtifact(ctx, jobID, filename)
+	if err != nil {
+		return -1
+	}
+	return info.Size
+}
+
+// copyVideo replaces store i's copy of the video with the primary's. The
+// old copy is removed first, so the store's write policy can't keep it.
+func (m *MultiStorage) copyVideo(ctx context.Context, i int, jobID, filename string) error {
+	video, _, err := m.stores[0].OpenVideo(ctx, jobID, filename)
+	if err != nil {
+		return fmt.Errorf("rewriting out-of-sync %s: %w", filename, err)
+	}
+	defer video.Close()
+	if err := m.stores[i].RemoveArtifact(ctx, jobID, filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("rewriting out-of-sync %s: %w", filename, err)
+	}
+	if err := m.stores[i].SaveVideo(ctx, jobID, video, filename); err != nil {
+		return fmt.Errorf("rewriting out-of-sync %s: %w", filename, err)
+	}
+	return nil
+}
+
+// OpenVideo reads the video from the primary.
+func (m *MultiStorage) OpenVideo(ctx context.Context, jobID string, filename string) (io.ReadCloser, int64, error) {
+	return m.stores[0].OpenVideo(ctx, jobID, filename)
+}
+
+// SaveDownloadState saves the download state to every store.
+func (m *MultiStorage) SaveDownloadState(ctx context.Context, jobID string, data []byte) error {
+	return m.each(func(s ports.Storage) error { return s.SaveDownloadState(ctx, jobID, data) })
+}
+
+// LoadDownloadState reads the primary's download state.
+func (m *MultiStorage) LoadDownloadState(ctx context.Context, jobID string) ([]byte, error) {
+	return m.stores[0].LoadDownloadState(ctx, jobID)
+}
+
+// RemoveDownloadState deletes the download state from every store.
+func (m *MultiStorage) RemoveDownloadState(ctx context.Context, jobID string) error {
+	return m.each(func(s ports.Storage) error { return s.RemoveDownloadState(ctx, jobID) })
+}
+
+// SaveStoryboard saves a storyboard sprite to every store.
+func (m *MultiStorage) SaveStoryboard(ctx context.Context, jobID string, name string, data []byte) error {
+	return m.each(func(s ports.Storage) error { return s.SaveStoryboard(ctx, jobID, name, data) })
+}
+
+// SaveReference saves the duplicate reference to every store.
+func (m *MultiStorage) SaveReference(ctx context.Context, jobID string, data []byte) error {
+	return m.each(func(s ports.Storage) error { return s.SaveReference(ctx, jobID, data) })
+}
+
+// RemoveArtifact deletes the artifact from every store.
+func (m *MultiStorage) RemoveArtifact(ctx context.Context, jobID string, filename string) error {
+	return m.each(func(s ports.Storage) error { return s.RemoveArtifact(ctx, jobID, filename) })
+}
+
+// SaveManifest saves the manifest to every store.
+func (m *MultiStorage) SaveManifest(ctx context.Context, jobID string, data []byte) error {
+	return m.each(func(s ports.Storage) error { return s.SaveManifest(ctx, jobID, data) })
+}
+
+// WriteMarker creates the marker in every store.
+func (m *MultiStorage) WriteMarker(ctx context.Context, jobID string, name string) error {
+	return m.each(func(s ports.Storage) error { return s.WriteMarker(ctx, jobID, name) })
+}
+
+// Exists checks the primary.
+func (m *MultiStorage) Exists(ctx context.Context, jobID string, filename string) (bool, error) {
+	return m.stores[0].Exists(ctx, jobID, filename)
+}
+
+// StatArtifact checks the primary.
+func (m *MultiStorage) StatArtifact(ctx context.Context, jobID string, filename string) (*ports.ArtifactInfo, error) {
+	return m.stores[0].StatArtifact(ctx, jobID, filename)
+}
+
+// GetJobPath returns the primary's path.
+func (m *MultiStorage) GetJobPath(jobID string) string {
+	return m.stores[0].GetJobPath(jobID)
+}
+
+// FreeSpace returns the least free space among the stores a write must
+// succeed on: all of them, or just the primary with WithBestEffort. Stores
+// that can't report it are ignored.
+func (m *MultiStorage) FreeSpace(ctx context.Context) (int64, error) {
+	stores := m.stores
+	if m.bestEffort {
+		stores = stores[:1]
+	}
+	least := int64(-1)
+	for i, s := range stores {
+		reporter, ok := s.(ports.SpaceReporter)
+		if !ok {
+			continue
+		}
+		free, err := reporter.FreeSpace(ctx)
+		if errors.Is(err, errors.ErrUnsupported) {
+			continue
+		}
+		if err != nil {
+			if i > 0 {
+				err = fmt.Errorf("mirror storage %d: %w", i, err)
+			}
+			return 0, err
+		}
+		if least < 0 || free < least {
+			least = free
+		}
+	}
+	if least < 0 {
+		return 0, fmt.Errorf("no store reports free space: %w", errors.ErrUnsupported)
+	}
+	return least, nil
+}
+
+// each runs write against every store.
+func (m *MultiStorage) each(write func(ports.Storage) error) error {
+	errs := make([]error, len(m.stores))
+	for i, s := range m.stores {
+		errs[i] = write(s)
+	}
+	return m.result(errs)
+}
+
+// stream runs save against the primary and the given mirrors concurrently,
+// returning each store's error (indexed like m.stores). The mirrors are fed
+// from a tee of the primary's reader, each through a buffered feed, so a
+// mirror only holds up the primary once its buffer is full, and then for at
+// most the mirror timeout before it is dropped with ErrMirrorStalled.
+func (m *MultiStorage) stream(ctx context.Context, mirrors []int, reader io.Reader, save func(context.Context, ports.Storage, io.Reader) error) []error {
+	errs := make([]error, len(m.stores))
+	tee := &fanOut{}
+	var wg sync.WaitGroup
+	for _, i := range mirrors {
+		mirrorCtx, cancel := context.WithCancel(ctx)
+		feed := newMirrorFeed(m.mirrorTimeout, cancel)
+		tee.feeds = append(tee.feeds, feed)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cancel()
+			errs[i] = save(mirrorCtx, m.stores[i], feed.pr)
+			feed.pr.CloseWithError(errMirrorDone)
+			if feed.stalled.Load() {
+				errs[i] = fmt.Errorf("%w: no data accepted for %s", ErrMirrorStalled, m.mirrorTimeout)
+			}
+		}()
+	}
+
+	errs[0] = save(ctx, m.stores[0], io.TeeReader(reader, tee))
+	for _, feed := range tee.feeds {
+		if errs[0] != nil {
+			feed.close(fmt.Errorf("primary storage failed: %w", errs[0]))
+		} else {
+			feed.close(nil)
+		}
+	}
+	wg.Wait()
+	return errs
+}
+
+// ErrMirrorStalled fails a mirror's video write when the mirror stops
+// accepting data for longer than the mirror timeout.
+var ErrMirrorStalled = errors.New("mirror stalled")
+
+// errMirrorDone unblocks writes to a mirror that returned before reading
+// the whole stream.
+var errMirrorDone = errors.New("mirror stopped reading")
+
+// mirrorFeed queues stream chunks for one mirror and writes them into the
+// pipe the mirror reads from.
+type mirrorFeed struct {
+	pr       *io.PipeReader
+	pw       *io.PipeWriter
+	chunks   chan []byte
+	exited   chan struct{} // closed once pump stops writing
+	closeErr error         // set before chunks is closed
+	timeout  time.Duration
+	cancel   context.CancelFunc
+	stalled  atomic.Bool
+}
+
+func newMirrorFeed(timeout time.Duration, cancel context.CancelFunc) *mirrorFeed {
+	pr, pw := io.Pipe()
+	f := &mirrorFeed{
+		pr:      pr,
+		pw:      pw,
+		chunks:  make(chan []byte, mirrorBuffer),
+		exited:  make(chan struct{}),
+		timeout: timeout,
+		cancel:  cancel,
+	}
+	go f.pump()
+	return f
+}
+
+// pump writes the queued chunks to the pipe, giving up on the mirror when
+// one write waits longer than the timeout.
+func (f *mirrorFeed) pump() {
+	defer close(f.exited)
+	for chunk := range f.chunks {
+		var timer *time.Timer
+		if f.timeout > 0 {
+			timer = time.AfterFunc(f.timeout, func() {
+				f.stalled.Store(true)
+				f.pw.CloseWithError(ErrMirrorStalled)
+				f.cancel()
+			})
+		}
+		_, err := f.pw.Write(chunk)
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return
+		}
+	}
+	f.pw.CloseWithError(f.closeErr)
+}
+
+// send queues a copy of p, reporting false once the mirror is gone.
+func (f *mirrorFeed) send(p []byte) bool {
+	select {
+	case f.chunks <- append([]byte(nil), p...):
+		return true
+	case <-f.exited:
+		return false
+	}
+}
+
+// close ends the stream: with EOF once the queue is written for a nil err,
+// or with err at once.
+func (f *mirrorFeed) close(err error) {
+	f.closeErr = err
+	if err != nil {
+		f.pw.CloseWithError(err)
+	}
+	close(f.chunks)
+}
+
+// fanOut queues writes for every mirror that hasn't failed yet. It never
+// fails itself, so a broken mirror can't break the primary's stream.
+type fanOut struct {
+	feeds []*mirrorFeed
+	gone  []bool
+}
+
+func (f *fanOut) Write(p []byte) (int, error) {
+	if f.gone == nil {
+		f.gone = make([]bool, len(f.feeds))
+	}
+	for i, feed := range f.feeds {
+		if !f.gone[i] && !feed.send(p) {
+			f.gone[i] = true
+		}
+	}
+	return len(p), nil
+}
+
+// result combines the per-store errors: all of them, or with best effort
+// only the primary's (reporting the others to onError).
+func (m *MultiStorage) result(errs []error) error {
+	var failed []error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if i > 0 {
+			err = fmt.Errorf("mirror storage %d: %w", i, err)
+			if m.bestEffort {
+				if m.onError != nil {
+					m.onError(err)
+				}
+				continue
+			}
+		}
+		failed = append(failed, err)
+	}
+	return errors.Join(failed...)
+}
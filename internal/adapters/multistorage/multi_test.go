@@ -0,0 +1,212 @@
+package multistorage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/adapters/localstorage"
+	"scrapeanddown/internal/core/ports"
+)
+
+// stalledStorage never reads the video it is asked to save, until its
+// context is cancelled.
+type stalledStorage struct {
+	ports.Storage
+}
+
+func (s stalledStorage) SaveVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func newStore(t *testing.T, jobID string) *localstorage.LocalStorage {
+	t.Helper()
+	s := localstorage.NewLocalStorage(t.TempDir())
+	if err := s.InitJob(context.Background(), jobID); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func readVideo(t *testing.T, s ports.Storage, jobID, filename string) string {
+	t.Helper()
+	video, _, err := s.OpenVideo(context.Background(), jobID, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer video.Close()
+	data, err := io.ReadAll(video)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSaveVideoWritesEveryStore(t *testing.T) {
+	ctx := context.Background()
+	primary, mirror := newStore(t, "job"), newStore(t, "job")
+	m := NewMultiStorage(primary, []ports.Storage{mirror})
+
+	if err := m.SaveVideo(ctx, "job", strings.NewReader("video bytes"), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]ports.Storage{"primary": primary, "mirror": mirror} {
+		if got := readVideo(t, s, "job", "video.mp4"); got != "video bytes" {
+			t.Errorf("%s video = %q", name, got)
+		}
+	}
+}
+
+func TestStalledMirrorDoesNotStallPrimary(t *testing.T) {
+	ctx := context.Background()
+	primary := newStore(t, "job")
+	var mu sync.Mutex
+	var reported []error
+	m := NewMultiStorage(primary, []ports.Storage{stalledStorage{newStore(t, "job")}},
+		WithBestEffort(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, err)
+		}),
+		WithMirrorTimeout(20*time.Millisecond))
+
+	// Far more than the mirror's buffer holds
+	data := bytes.Repeat([]byte("x"), 8<<20)
+	done := make(chan error, 1)
+	go func() { done <- m.SaveVideo(ctx, "job", bytes.NewReader(data), "video.mp4") }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("SaveVideo: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("a stalled mirror stalled the primary")
+	}
+
+	if got := readVideo(t, primary, "job", "video.mp4"); len(got) != len(data) {
+		t.Errorf("primary saved %d bytes, want %d", len(got), len(data))
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], ErrMirrorStalled) {
+		t.Errorf("reported %v, want one ErrMirrorStalled", reported)
+	}
+}
+
+func TestAppendVideoRewritesOutOfSyncMirror(t *testing.T) {
+	ctx := context.Background()
+	primary, inSync, stale := newStore(t, "job"), newStore(t, "job"), newStore(t, "job")
+	for _, s := range []ports.Storage{primary, inSync} {
+		if err := s.SaveVideo(ctx, "job", strings.NewReader("abc"), "video.mp4"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The stale mirror failed partway through the first write
+	if err := stale.SaveVideo(ctx, "job", strings.NewReader("a"), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewMultiStorage(primary, []ports.Storage{inSync, stale})
+	if err := m.AppendVideo(ctx, "job", strings.NewReader("def"), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]ports.Storage{"primary": primary, "in-sync mirror": inSync, "stale mirror": stale} {
+		if got := readVideo(t, s, "job", "video.mp4"); got != "abcdef" {
+			t.Errorf("%s video = %q, want %q", name, got, "abcdef")
+		}
+	}
+}
+
+// newPolicyStore is newStore under policy, with video.mp4 left by an earlier
+// run if existing isn't empty.
+func newPolicyStore(t *testing.T, jobID string, policy localstorage.WritePolicy, existing string) *localstorage.LocalStorage {
+	t.Helper()
+	s := localstorage.NewLocalStorage(t.TempDir(), localstorage.WithWritePolicy(policy))
+	if err := s.InitJob(context.Background(), jobID); err != nil {
+		t.Fatal(err)
+	}
+	if existing != "" {
+		if err := os.WriteFile(filepath.Join(s.GetJobPath(jobID), "video.mp4"), []byte(existing), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestSaveVideoAppliesEachStoresPolicy(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name            string
+		primary, mirror string // Existing copies
+		wantWrite       bool
+		wantPrimary     string
+		wantMirror      string
+		wantUnread      bool
+	}{
+		{name: "neither kept", wantWrite: true, wantPrimary: "new", wantMirror: "new"},
+		{name: "primary kept", primary: "old", wantWrite: true, wantPrimary: "old", wantMirror: "old", wantUnread: true},
+		{name: "mirror kept", mirror: "old", wantWrite: true, wantPrimary: "new", wantMirror: "old"},
+		{name: "both kept", primary: "old", mirror: "older", wantPrimary: "old", wantMirror: "older", wantUnread: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := newPolicyStore(t, "job", localstorage.SkipExisting, tt.primary)
+			mirror := newPolicyStore(t, "job", localstorage.SkipExisting, tt.mirror)
+			m := NewMultiStorage(primary, []ports.Storage{mirror})
+
+			write, err := m.ShouldWrite(ctx, "job", "video.mp4")
+			if err != nil || write != tt.wantWrite {
+				t.Errorf("ShouldWrite = %v, %v, want %v", write, err, tt.wantWrite)
+			}
+			reader := strings.NewReader("new")
+			if err := m.SaveVideo(ctx, "job", reader, "video.mp4"); err != nil {
+				t.Fatal(err)
+			}
+			if got := readVideo(t, primary, "job", "video.mp4"); got != tt.wantPrimary {
+				t.Errorf("primary video = %q, want %q", got, tt.wantPrimary)
+			}
+			if got := readVideo(t, mirror, "job", "video.mp4"); got != tt.wantMirror {
+				t.Errorf("mirror video = %q, want %q", got, tt.wantMirror)
+			}
+			if unread := reader.Len() > 0; unread != tt.wantUnread {
+				t.Errorf("stream unread = %v, want %v", unread, tt.wantUnread)
+			}
+		})
+	}
+}
+
+func TestSaveVideoFailsOnMirrorErrorIfExists(t *testing.T) {
+	ctx := context.Background()
+	primary := newPolicyStore(t, "job", localstorage.ErrorIfExists, "")
+	mirror := newPolicyStore(t, "job", localstorage.ErrorIfExists, "old")
+	m := NewMultiStorage(primary, []ports.Storage{mirror})
+
+	if _, err := m.ShouldWrite(ctx, "job", "video.mp4"); !errors.Is(err, os.ErrExist) {
+		t.Errorf("ShouldWrite err = %v, want os.ErrExist", err)
+	}
+	if err := m.SaveVideo(ctx, "job", strings.NewReader("new"), "video.mp4"); !errors.Is(err, os.ErrExist) {
+		t.Errorf("SaveVideo err = %v, want os.ErrExist", err)
+	}
+}
+
+// An out-of-sync mirror is rewritten even under a policy that keeps
+// existing files: its copy is a broken part of this very video.
+func TestAppendVideoRewritesOutOfSyncMirrorUnderSkipExisting(t *testing.T) {
+	ctx := context.Background()
+	primary := newPolicyStore(t, "job", localstorage.SkipExisting, "first half")
+	mirror := newPolicyStore(t, "job", localstorage.SkipExisting, "first")
+	m := NewMultiStorage(primary, []ports.Storage{mirror})
+
+	if err := m.AppendVideo(ctx, "job", strings.NewReader(", second half"), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readVideo(t, mirror, "job", "video.mp4"); got != "first half, second half" {
+		t.Errorf("mirror video = %q, want the primary's", got)
+	}
+}
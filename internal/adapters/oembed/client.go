@@ -0,0 +1,144 @@
+package oembed
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+	"scrapeanddown/internal/retry"
+)
+
+// Public oEmbed endpoints per platform.
+var defaultEndpoints = map[string]string{
+	"youtube":   "https://www.youtube.com/oembed",
+	"tiktok":    "https://www.tiktok.com/oembed",
+	"pinterest": "https://www.pinterest.com/oembed.json",
+}
+
+// OEmbedScraper implements ports.Scraper using the platforms' free oEmbed endpoints.
+// It only provides lightweight metadata (title, author, thumbnail) and never a
+// video URL, so it pairs with the yt-dlp download path.
+type OEmbedScraper struct {
+	client    *http.Client
+	endpoints map[string]string
+}
+
+// NewOEmbedScraper creates a new OEmbedScraper.
+func NewOEmbedScraper() *OEmbedScraper {
+	return &OEmbedScraper{
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		endpoints: defaultEndpoints,
+	}
+}
+
+// Scrape fetches oEmbed metadata for the given video URL.
+func (s *OEmbedScraper) Scrape(ctx context.Context, videoPageURL string) (*ports.ScrapeResult, error) {
+	platform := detectPlatform(videoPageURL)
+	endpoint, ok := s.endpoints[platform]
+	if !ok {
+		return nil, fmt.Errorf("%w for URL: %s", ports.ErrUnsupportedPlatform, videoPageURL)
+	}
+
+	reqURL := fmt.Sprintf("%s?url=%s&format=json", endpoint, url.QueryEscape(videoPageURL))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var body []byte
+	err = retry.Do(ctx, retry.DefaultPolicy, func() error {
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to fetch oembed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		// oEmbed answers 404 (or 401 for embedding-disabled/private) for missing videos
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnauthorized {
+			return ports.ErrVideoUnavailable
+		}
+		if resp.StatusCode != http.StatusOK {
+			return retry.NewStatusError(resp)
+		}
+
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read oembed response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ports.ScrapeResult{RawMetadata: body}, nil
+}
+
+// CheckAvailable asks the platform's oEmbed endpoint whether the video
+// exists, reading its answer per platform (see unavailableStatus). Rate
+// limits and server errors are retried; other answers can't tell.
+func (s *OEmbedScraper) CheckAvailable(ctx context.Context, videoPageURL string) error {
+	platform := detectPlatform(videoPageURL)
+	endpoint, ok := s.endpoints[platform]
+	if !ok {
+		return fmt.Errorf("%w for URL: %s", ports.ErrUnsupportedPlatform, videoPageURL)
+	}
+
+	reqURL := fmt.Sprintf("%s?url=%s&format=json", endpoint, url.QueryEscape(videoPageURL))
+	return retry.Do(ctx, retry.DefaultPolicy, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to fetch oembed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+		if unavailableStatus(platform, resp.StatusCode) {
+			return ports.ErrVideoUnavailable
+		}
+		return retry.NewStatusError(resp)
+	})
+}
+
+// unavailableStatus reports whether a platform's oEmbed endpoint answers
+// code for a video that doesn't exist or can't be fetched. Each answers 404
+// for an unknown video; TikTok answers 400 for one that was removed or made
+// private. YouTube's 401 and 403 are left out: it answers them for videos
+// that exist but can't be embedded.
+func unavailableStatus(platform string, code int) bool {
+	switch code {
+	case http.StatusNotFound:
+		return true
+	case http.StatusBadRequest:
+		return platform == "tiktok"
+	}
+	return false
+}
+
+func detectPlatform(url string) string {
+	lowerURL := strings.ToLower(url)
+	if strings.Contains(lowerURL, "youtube.com") || strings.Contains(lowerURL, "youtu.be") ||
+		strings.Contains(lowerURL, "youtube-nocookie.com") {
+		return "youtube"
+	}
+	if strings.Contains(lowerURL, "tiktok.com") {
+		return "tiktok"
+	}
+	if strings.Contains(lowerURL, "pinterest.") || strings.Contains(lowerURL, "pin.it") {
+		return "pinterest"
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package oembed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+	"scrapeanddown/internal/retry"
+)
+
+func TestCheckAvailable(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		status   int
+		wantGone bool
+		wantErr  bool
+	}{
+		{"youtube ok", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", http.StatusOK, false, false},
+		{"youtube missing", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", http.StatusNotFound, true, false},
+		{"youtube not embeddable", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", http.StatusUnauthorized, false, true},
+		{"youtube forbidden", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", http.StatusForbidden, false, true},
+		{"youtube bad request", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", http.StatusBadRequest, false, true},
+		{"tiktok ok", "https://www.tiktok.com/@user/video/1", http.StatusOK, false, false},
+		{"tiktok removed or private", "https://www.tiktok.com/@user/video/1", http.StatusBadRequest, true, false},
+		{"tiktok missing", "https://www.tiktok.com/@user/video/1", http.StatusNotFound, true, false},
+		{"pinterest missing", "https://www.pinterest.com/pin/1/", http.StatusNotFound, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("url") != tt.url {
+					t.Errorf("url = %q, want %q", r.URL.Query().Get("url"), tt.url)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			s := NewOEmbedScraper()
+			s.endpoints = map[string]string{"youtube": server.URL, "tiktok": server.URL, "pinterest": server.URL}
+
+			err := s.CheckAvailable(context.Background(), tt.url)
+			if gone := errors.Is(err, ports.ErrVideoUnavailable); gone != tt.wantGone {
+				t.Fatalf("err = %v, want unavailable %v", err, tt.wantGone)
+			}
+			var statusErr *retry.StatusError
+			if got := errors.As(err, &statusErr); got != tt.wantErr {
+				t.Errorf("err = %v, want a status error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckAvailableUnsupportedPlatform(t *testing.T) {
+	err := NewOEmbedScraper().CheckAvailable(context.Background(), "https://example.com/video")
+	if !errors.Is(err, ports.ErrUnsupportedPlatform) {
+		t.Fatalf("err = %v, want ErrUnsupportedPlatform", err)
+	}
+}
+
+func TestScrape(t *testing.T) {
+	const metadata = `{"title":"Never Gonna Give You Up","author_name":"Rick Astley","thumbnail_url":"https://i.ytimg.com/vi/dQw4w9WgXcQ/hqdefault.jpg"}`
+	tests := []struct {
+		name     string
+		url      string
+		status   int
+		wantGone bool
+		wantErr  bool
+	}{
+		{"youtube", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", http.StatusOK, false, false},
+		{"youtu.be", "https://youtu.be/dQw4w9WgXcQ", http.StatusOK, false, false},
+		{"tiktok", "https://www.tiktok.com/@user/video/1", http.StatusOK, false, false},
+		{"pinterest", "https://www.pinterest.com/pin/1/", http.StatusOK, false, false},
+		{"missing", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", http.StatusNotFound, true, false},
+		{"private", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", http.StatusUnauthorized, true, false},
+		{"bad request", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", http.StatusBadRequest, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.URL.Query().Get("url"); got != tt.url {
+					t.Errorf("url = %q, want %q", got, tt.url)
+				}
+				if got := r.URL.Query().Get("format"); got != "json" {
+					t.Errorf("format = %q, want json", got)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(metadata))
+			}))
+			defer server.Close()
+			s := NewOEmbedScraper()
+			s.endpoints = map[string]string{"youtube": server.URL, "tiktok": server.URL, "pinterest": server.URL}
+
+			result, err := s.Scrape(context.Background(), tt.url)
+			if gone := errors.Is(err, ports.ErrVideoUnavailable); gone != tt.wantGone {
+				t.Fatalf("err = %v, want unavailable %v", err, tt.wantGone)
+			}
+			var statusErr *retry.StatusError
+			if got := errors.As(err, &statusErr); got != tt.wantErr {
+				t.Fatalf("err = %v, want a status error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if string(result.RawMetadata) != metadata {
+				t.Errorf("RawMetadata = %s, want %s", result.RawMetadata, metadata)
+			}
+			if result.VideoURL != "" {
+				t.Errorf("VideoURL = %q, want none", result.VideoURL)
+			}
+		})
+	}
+}
+
+func TestScrapeUnsupportedPlatform(t *testing.T) {
+	_, err := NewOEmbedScraper().Scrape(context.Background(), "https://example.com/video")
+	if !errors.Is(err, ports.ErrUnsupportedPlatform) {
+		t.Fatalf("err = %v, want ErrUnsupportedPlatform", err)
+	}
+}
@@ -0,0 +1,187 @@
+package rapidapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+	"scrapeanddown/internal/retry"
+)
+
+// defaultHost is the RapidAPI YouTube download API used unless RAPIDAPI_HOST
+// overrides it. Its /dl?id=<video id> endpoint lists direct format URLs.
+const defaultHost = "ytstream-download-youtube-videos.p.rapidapi.com"
+
+// RapidAPIResolver implements ports.URLResolver using a RapidAPI YouTube
+// download endpoint, for environments where the yt-dlp binary isn't
+// available. It only handles YouTube.
+type RapidAPIResolver struct {
+	apiKey  string
+	host    string
+	baseURL string
+	client  *http.Client
+}
+
+// NewRapidAPIResolver creates a new RapidAPIResolver.
+// Reads the API key from RAPIDAPI_KEY and, optionally, the API host from
+// RAPIDAPI_HOST.
+func NewRapidAPIResolver() (*RapidAPIResolver, error) {
+	key := os.Getenv("RAPIDAPI_KEY")
+	if key == "" {
+		return nil, fmt.Errorf("RAPIDAPI_KEY environment variable not set")
+	}
+	host := os.Getenv("RAPIDAPI_HOST")
+	if host == "" {
+		host = defaultHost
+	}
+	return NewRapidAPIResolverWithClient(key, host, "https://"+host, &http.Client{
+		Timeout: 30 * time.Second,
+	}), nil
+}
+
+// NewRapidAPIResolverWithClient creates a new RapidAPIResolver calling
+// baseURL (with host as the X-RapidAPI-Host) through the given client.
+func NewRapidAPIResolverWithClient(key, host, baseURL string, client *http.Client) *RapidAPIResolver {
+	return &RapidAPIResolver{
+		apiKey:  key,
+		host:    host,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  client,
+	}
+}
+
+// ResolveVideoURL returns the direct URL of the highest-resolution
+// single-file (video+audio) format.
+func (r *RapidAPIResolver) ResolveVideoURL(ctx context.Context, videoPageURL string) (string, error) {
+	formats, err := r.fetchFormats(ctx, videoPageURL)
+	if err != nil {
+		return "", err
+	}
+	var best *format
+	for i := range formats {
+		f := &formats[i]
+		if f.URL != "" && (best == nil || f.Height > best.Height) {
+			best = f
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("rapidapi returned no downloadable formats")
+	}
+	return best.URL, nil
+}
+
+// ResolveVideoURLForHeight returns the direct URL of the single-file format
+// of exactly the given height.
+func (r *RapidAPIResolver) ResolveVideoURLForHeight(ctx context.Context, videoPageURL string, height int) (string, error) {
+	formats, err := r.fetchFormats(ctx, videoPageURL)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range formats {
+		if f.URL != "" && f.Height == height {
+			return f.URL, nil
+		}
+	}
+	return "", fmt.Errorf("no %dp format available", height)
+}
+
+// format is the subset of a RapidAPI format entry we use.
+type format struct {
+	URL           string `json:"url"`
+	MimeType      string `json:"mimeType"`
+	Height        int    `json:"height"`
+	ContentLength string `json:"contentLength"`
+}
+
+// fetchFormats calls the download endpoint and returns its muxed
+// (video+audio) formats.
+func (r *RapidAPIResolver) fetchFormats(ctx context.Context, videoPageURL string) ([]format, error) {
+	id := ports.YouTubeVideoID(videoPageURL)
+	if id == "" {
+		return nil, fmt.Errorf("unsupported URL for rapidapi: %s", videoPageURL)
+	}
+
+	reqURL := fmt.Sprintf("%s/dl?id=%s", r.baseURL, neturl.QueryEscape(id))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-RapidAPI-Key", r.apiKey)
+	req.Header.Set("X-RapidAPI-Host", r.host)
+
+	var body []byte
+	err = retry.Do(ctx, retry.DefaultPolicy, func() error {
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to call rapidapi: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("rapidapi error: %w", retry.NewStatusError(resp))
+		}
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read rapidapi response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseFormats(body)
+}
+
+// parseFormats extracts the muxed formats from a /dl response. The API
+// reports status "fail" (with a reason) for removed or private videos.
+func parseFormats(body []byte) ([]format, error) {
+	var info struct {
+		Status  string   `json:"status"`
+		Reason  string   `json:"reason"`
+		Formats []format `json:"formats"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse rapidapi response: %w", err)
+	}
+	if !strings.EqualFold(info.Status, "OK") {
+		return nil, fmt.Errorf("%w: %s", ports.ErrVideoUnavailable, info.Reason)
+	}
+
+	formats := make([]format, 0, len(info.Formats))
+	for _, f := range info.Formats {
+		// Prefer formats a standard player can open
+		if f.MimeType == "" || strings.HasPrefix(f.MimeType, "video/mp4") {
+			formats = append(formats, f)
+		}
+	}
+	if len(formats) == 0 {
+		formats = info.Formats
+	}
+	return formats, nil
+}
+
+// Probe reports the size of the format ResolveVideoURL would pick. The API
+// doesn't report duration, so DurationSeconds is 0 (unknown).
+func (r *RapidAPIResolver) Probe(ctx context.Context, videoPageURL string) (*ports.VideoInfo, error) {
+	formats, err := r.fetchFormats(ctx, videoPageURL)
+	if err != nil {
+		return nil, err
+	}
+	info := &ports.VideoInfo{}
+	bestHeight := -1
+	for _, f := range formats {
+		if f.URL != "" && f.Height > bestHeight {
+			bestHeight = f.Height
+			info.EstimatedBytes, _ = strconv.ParseInt(f.ContentLength, 10, 64)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,188 @@
+package rapidapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// sampleResponse is a trimmed /dl response: muxed formats, one without a
+// URL (a signature cipher), and a WebM one.
+const sampleResponse = `{
+	"status": "OK",
+	"id": "dQw4w9WgXcQ",
+	"title": "Sample",
+	"formats": [
+		{"itag": 18, "url": "https://rr1.googlevideo.com/360.mp4", "mimeType": "video/mp4; codecs=\"avc1.42001E, mp4a.40.2\"", "height": 360, "contentLength": "1000"},
+		{"itag": 22, "url": "https://rr1.googlevideo.com/720.mp4", "mimeType": "video/mp4; codecs=\"avc1.64001F, mp4a.40.2\"", "height": 720, "contentLength": "5000"},
+		{"itag": 37, "signatureCipher": "s=...", "mimeType": "video/mp4; codecs=\"avc1.640028, mp4a.40.2\"", "height": 1080},
+		{"itag": 43, "url": "https://rr1.googlevideo.com/1440.webm", "mimeType": "video/webm; codecs=\"vp8.0, vorbis\"", "height": 1440, "contentLength": "9000"}
+	]
+}`
+
+// fakeAPI serves body for /dl, checking the RapidAPI headers, and records
+// the video IDs requested.
+type fakeAPI struct {
+	t      *testing.T
+	status int
+	body   string
+
+	mu  sync.Mutex
+	ids []string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/dl" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("X-RapidAPI-Key") != "key" || r.Header.Get("X-RapidAPI-Host") != "api.example.com" {
+		f.t.Errorf("headers = %v, want the key and host", r.Header)
+	}
+	f.mu.Lock()
+	f.ids = append(f.ids, r.URL.Query().Get("id"))
+	f.mu.Unlock()
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+	}
+	w.Write([]byte(f.body))
+}
+
+func newTestResolver(t *testing.T, api *fakeAPI) *RapidAPIResolver {
+	api.t = t
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	return NewRapidAPIResolverWithClient("key", "api.example.com", srv.URL+"/", srv.Client())
+}
+
+func TestResolveVideoURL(t *testing.T) {
+	api := &fakeAPI{body: sampleResponse}
+	r := newTestResolver(t, api)
+	ctx := context.Background()
+
+	got, err := r.ResolveVideoURL(ctx, "https://youtu.be/dQw4w9WgXcQ?t=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The WebM and cipher-only formats are passed over
+	if want := "https://rr1.googlevideo.com/720.mp4"; got != want {
+		t.Errorf("ResolveVideoURL = %s, want %s", got, want)
+	}
+	if len(api.ids) != 1 || api.ids[0] != "dQw4w9WgXcQ" {
+		t.Errorf("requested ids %v, want the video's", api.ids)
+	}
+
+	tests := []struct {
+		height int
+		want   string
+		err    bool
+	}{
+		{360, "https://rr1.googlevideo.com/360.mp4", false},
+		{720, "https://rr1.googlevideo.com/720.mp4", false},
+		{1080, "", true}, // No direct URL
+		{1440, "", true}, // WebM
+	}
+	for _, tt := range tests {
+		got, err := r.ResolveVideoURLForHeight(ctx, "https://www.youtube.com/watch?v=dQw4w9WgXcQ", tt.height)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ResolveVideoURLForHeight(%d) = %q, %v; want %q", tt.height, got, err, tt.want)
+		}
+	}
+
+	info, err := r.Probe(ctx, "https://www.youtube.com/shorts/dQw4w9WgXcQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.EstimatedBytes != 5000 || info.DurationSeconds != 0 {
+		t.Errorf("Probe = %+v, want the 720p format's size", info)
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string // URLs
+		err  error
+	}{
+		{"mp4 preferred", sampleResponse, []string{"https://rr1.googlevideo.com/360.mp4", "https://rr1.googlevideo.com/720.mp4", ""}, nil},
+		{"only webm", `{"status": "ok", "formats": [{"url": "a.webm", "mimeType": "video/webm"}]}`, []string{"a.webm"}, nil},
+		{"no mime type", `{"status": "OK", "formats": [{"url": "a"}]}`, []string{"a"}, nil},
+		{"unavailable", `{"status": "fail", "reason": "Video unavailable"}`, nil, ports.ErrVideoUnavailable},
+		{"invalid", `<html>`, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formats, err := parseFormats([]byte(tt.body))
+			if tt.want == nil {
+				if err == nil || tt.err != nil && !errors.Is(err, tt.err) {
+					t.Fatalf("parseFormats err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, f := range formats {
+				got = append(got, f.URL)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("formats = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveVideoURLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		api  *fakeAPI
+		url  string
+		err  string
+	}{
+		{"not youtube", &fakeAPI{body: sampleResponse}, "https://vimeo.com/1", "unsupported URL"},
+		{"unavailable", &fakeAPI{body: `{"status": "fail", "reason": "This video is private"}`}, "https://youtu.be/dQw4w9WgXcQ", "This video is private"},
+		{"no formats", &fakeAPI{body: `{"status": "OK", "formats": []}`}, "https://youtu.be/dQw4w9WgXcQ", "no downloadable formats"},
+		{"bad key", &fakeAPI{status: http.StatusForbidden, body: `{"message": "You are not subscribed to this API."}`}, "https://youtu.be/dQw4w9WgXcQ", "rapidapi error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestResolver(t, tt.api).ResolveVideoURL(context.Background(), tt.url)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ResolveVideoURL err = %v, want %q", err, tt.err)
+			}
+			if tt.name == "not youtube" && len(tt.api.ids) != 0 {
+				t.Error("called the API for a URL it can't resolve")
+			}
+		})
+	}
+}
+
+func TestNewRapidAPIResolver(t *testing.T) {
+	t.Setenv("RAPIDAPI_KEY", "")
+	if _, err := NewRapidAPIResolver(); err == nil {
+		t.Error("NewRapidAPIResolver without RAPIDAPI_KEY succeeded")
+	}
+
+	t.Setenv("RAPIDAPI_KEY", "key")
+	t.Setenv("RAPIDAPI_HOST", "")
+	r, err := NewRapidAPIResolver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.host != defaultHost || r.baseURL != "https://"+defaultHost {
+		t.Errorf("host %s, base URL %s; want the default API", r.host, r.baseURL)
+	}
+
+	t.Setenv("RAPIDAPI_HOST", "api.example.com")
+	if r, _ = NewRapidAPIResolver(); r.host != "api.example.com" || r.baseURL != "https://api.example.com" {
+		t.Errorf("host %s, base URL %s; want RAPIDAPI_HOST", r.host, r.baseURL)
+	}
+}
@@ -0,0 +1,316 @@
+package s3storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/retry"
+)
+
+// request is one S3 API call. body, if set, returns a fresh reader of size
+// bytes for each attempt, so failed calls can be retried.
+type request struct {
+	method string
+	key    string
+	query  url.Values
+	header http.Header
+	body   func() io.Reader
+	size   int64
+	// payloadHash is the body's hex SHA-256, or unsignedPayload; empty
+	// means there is no body.
+	payloadHash string
+}
+
+// objectInfo is what a HEAD request tells about an object.
+type objectInfo struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+// errPreconditionFailed is a conditional request whose condition didn't
+// hold, such as creating an object that already exists.
+var errPreconditionFailed = errors.New("precondition failed")
+
+// objectURL returns the URL of the object at key: virtual-hosted style on
+// AWS, path style on a custom endpoint.
+func (s *S3Storage) objectURL(key string, query url.Values) *url.URL {
+	u := &url.URL{Scheme: "https", Host: s.bucket + ".s3." + s.region + ".amazonaws.com", Path: "/" + key}
+	if s.endpoint != nil {
+		u = &url.URL{
+			Scheme: s.endpoint.Scheme,
+			Host:   s.endpoint.Host,
+			Path:   strings.TrimSuffix(s.endpoint.Path, "/") + "/" + s.bucket + "/" + key,
+		}
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	if len(query) > 0 {
+		u.RawQuery = canonicalQuery(query)
+	}
+	return u
+}
+
+// do sends r, retrying transient failures, and returns the successful
+// response for the caller to read and close. A missing object wraps
+// os.ErrNotExist and a failed condition errPreconditionFailed.
+func (s *S3Storage) do(ctx context.Context, r request) (*http.Response, error) {
+	var resp *http.Response
+	err := retry.Do(ctx, s.retryPolicy, func() error {
+		var body io.Reader
+		if r.body != nil {
+			body = r.body()
+		}
+		req, err := http.NewRequestWithContext(ctx, r.method, s.objectURL(r.key, r.query).String(), body)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		if r.body != nil {
+			req.ContentLength = r.size
+			if r.size == 0 {
+				req.Body = http.NoBody
+			}
+		}
+		for name, values := range r.header {
+			req.Header[name] = values
+		}
+		payloadHash := r.payloadHash
+		if payloadHash == "" {
+			payloadHash = emptyPayloadHash
+		}
+		sign(req, s.creds, s.region, payloadHash, s.now())
+
+		res, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		if res.StatusCode >= 300 {
+			defer res.Body.Close()
+			return retry.NewStatusError(res)
+		}
+		resp = res
+		return nil
+	})
+	if err != nil {
+		var statusErr *retry.StatusError
+		if errors.As(err, &statusErr) {
+			switch statusErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, fmt.Errorf("s3://%s/%s: %w", s.bucket, r.key, os.ErrNotExist)
+			case http.StatusPreconditionFailed:
+				return nil, fmt.Errorf("s3://%s/%s: %w", s.bucket, r.key, errPreconditionFailed)
+			}
+		}
+		return nil, fmt.Errorf("s3 %s %s: %w", r.method, r.key, err)
+	}
+	return resp, nil
+}
+
+// putBytes uploads data to key. header may carry conditions such as
+// If-None-Match.
+func (s *S3Storage) putBytes(ctx context.Context, key string, data []byte, header http.Header) error {
+	if header == nil {
+		header = http.Header{}
+	}
+	s.setUploadHeaders(header, key)
+	resp, err := s.do(ctx, request{
+		method:      http.MethodPut,
+		key:         key,
+		header:      header,
+		body:        func() io.Reader { return bytes.NewReader(data) },
+		size:        int64(len(data)),
+		payloadHash: hashHex(data),
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// putFile uploads size bytes of file to key, in parts once it is larger
+// than the part size.
+func (s *S3Storage) putFile(ctx context.Context, key string, file io.ReaderAt, size int64) error {
+	if size > s.partSize {
+		return s.putMultipart(ctx, key, file, size)
+	}
+	header := http.Header{}
+	s.setUploadHeaders(header, key)
+	resp, err := s.do(ctx, request{
+		method:      http.MethodPut,
+		key:         key,
+		header:      header,
+		body:        func() io.Reader { return io.NewSectionReader(file, 0, size) },
+		size:        size,
+		payloadHash: unsignedPayload,
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// setUploadHeaders sets the headers every new object gets: its content
+// type and, with WithSSEKMS, server-side encryption under the KMS key.
+func (s *S3Storage) setUploadHeaders(header http.Header, key string) {
+	header.Set("Content-Type", contentType(key))
+	if s.kmsKeyID != "" {
+		header.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+		header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", s.kmsKeyID)
+	}
+}
+
+// completedPart is one uploaded part in a CompleteMultipartUpload request.
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// putMultipart uploads file to key in parts of the part size. The
+// encryption settings go on the CreateMultipartUpload call, which S3
+// applies to the assembled object. A failed upload is aborted so its parts
+// aren't kept (and billed).
+func (s *S3Storage) putMultipart(ctx context.Context, key string, file io.ReaderAt, size int64) (err error) {
+	header := http.Header{}
+	s.setUploadHeaders(header, key)
+	resp, err := s.do(ctx, request{method: http.MethodPost, key: key, query: url.Values{"uploads": {""}}, header: header})
+	if err != nil {
+		return err
+	}
+	var created struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	if err != nil || created.UploadID == "" {
+		return fmt.Errorf("s3 POST %s: no upload ID in response: %v", key, err)
+	}
+	defer func() {
+		if err != nil {
+			abort, abortErr := s.do(context.WithoutCancel(ctx), request{
+				method: http.MethodDelete,
+				key:    key,
+				query:  url.Values{"uploadId": {created.UploadID}},
+			})
+			if abortErr == nil {
+				abort.Body.Close()
+			}
+		}
+	}()
+
+	var parts []completedPart
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+s.partSize, number+1 {
+		length := min(s.partSize, size-offset)
+		resp, err := s.do(ctx, request{
+			method:      http.MethodPut,
+			key:         key,
+			query:       url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {created.UploadID}},
+			body:        func() io.Reader { return io.NewSectionReader(file, offset, length) },
+			size:        length,
+			payloadHash: unsignedPayload,
+		})
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return fmt.Errorf("failed to encode part list: %w", err)
+	}
+	resp, err = s.do(ctx, request{
+		method:      http.MethodPost,
+		key:         key,
+		query:       url.Values{"uploadId": {created.UploadID}},
+		header:      http.Header{"Content-Type": {"application/xml"}},
+		body:        func() io.Reader { return bytes.NewReader(body) },
+		size:        int64(len(body)),
+		payloadHash: hashHex(body),
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// S3 reports some failures to assemble the object in a 200 response
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("s3 POST %s: %s: %s", key, result.Code, result.Message)
+	}
+	return nil
+}
+
+// get returns the object's body and size.
+func (s *S3Storage) get(ctx context.Context, key string) (io.ReadCloser, int64, error) {
+	resp, err := s.do(ctx, request{method: http.MethodGet, key: key})
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// getBytes reads the whole object.
+func (s *S3Storage) getBytes(ctx context.Context, key string) ([]byte, error) {
+	body, _, err := s.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("s3 GET %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// head describes the object at key.
+func (s *S3Storage) head(ctx context.Context, key string) (*objectInfo, error) {
+	resp, err := s.do(ctx, request{method: http.MethodHead, key: key})
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	info := &objectInfo{size: resp.ContentLength, etag: resp.Header.Get("ETag")}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.modTime = modTime.UTC()
+	}
+	return info, nil
+}
+
+// exists reports whether there is an object at key.
+func (s *S3Storage) exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.head(ctx, key)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// remove deletes the object at key. S3 deletes succeed for missing
+// objects too.
+func (s *S3Storage) remove(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, request{method: http.MethodDelete, key: key})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,245 @@
+package s3storage
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/retry"
+)
+
+const testBucket = "videos"
+
+var testCreds = Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}
+
+// fakeObject is an object stored in fakeS3, with the headers it was
+// uploaded with.
+type fakeObject struct {
+	data    []byte
+	header  http.Header
+	modTime time.Time
+}
+
+func (o *fakeObject) etag() string {
+	sum := md5.Sum(o.data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// fakeUpload is a multipart upload in progress.
+type fakeUpload struct {
+	key    string
+	header http.Header
+	parts  map[int][]byte
+}
+
+// fakeRequest is a request fakeS3 received.
+type fakeRequest struct {
+	method string
+	key    string
+	query  url.Values
+	header http.Header
+}
+
+// fakeS3 serves a path-style S3 API for one bucket from memory, checking
+// every request's signature.
+type fakeS3 struct {
+	t   *testing.T
+	now func() time.Time
+	// fail, if set, may answer a request with an error status instead.
+	fail func(r fakeRequest) int
+	// lose, if set, may answer a PUT it carried out with 503, as if the
+	// response had been lost.
+	lose func(r fakeRequest) bool
+
+	mu       sync.Mutex
+	objects  map[string]*fakeObject
+	uploads  map[string]*fakeUpload
+	requests []fakeRequest
+	nextID   int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{t: t, now: time.Now, objects: map[string]*fakeObject{}, uploads: map[string]*fakeUpload{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+// newTestStorage returns an S3Storage on a fake S3 whose clock is now.
+func newTestStorage(t *testing.T, now time.Time, opts ...Option) (*S3Storage, *fakeS3) {
+	t.Helper()
+	f, srv := newFakeS3(t)
+	endpoint, _ := url.Parse(srv.URL)
+	s, err := NewS3Storage(testBucket, "eu-west-1", testCreds, append([]Option{WithEndpoint(endpoint)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.now = func() time.Time { return now }
+	f.now = s.now
+	s.retryPolicy = retry.Policy{MaxAttempts: 3, Initial: time.Millisecond}
+	return s, f
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := checkSignature(r, body); err != nil {
+		f.t.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	bucketPrefix := "/" + testBucket + "/"
+	if !strings.HasPrefix(r.URL.Path, bucketPrefix) {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	req := fakeRequest{method: r.Method, key: strings.TrimPrefix(r.URL.Path, bucketPrefix), query: r.URL.Query(), header: r.Header.Clone()}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	if f.fail != nil {
+		if code := f.fail(req); code != 0 {
+			http.Error(w, "injected failure", code)
+			return
+		}
+	}
+
+	obj := f.objects[req.key]
+	switch {
+	case r.Method == http.MethodPost && req.query.Has("uploads"):
+		f.nextID++
+		id := "upload-" + strconv.Itoa(f.nextID)
+		f.uploads[id] = &fakeUpload{key: req.key, header: req.header, parts: map[int][]byte{}}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && req.query.Has("uploadId"):
+		upload, ok := f.uploads[req.query.Get("uploadId")]
+		if !ok {
+			http.Error(w, "NoSuchUpload", http.StatusNotFound)
+			return
+		}
+		number, _ := strconv.Atoi(req.query.Get("partNumber"))
+		upload.parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"part-%d"`, number))
+	case r.Method == http.MethodPost && req.query.Has("uploadId"):
+		upload, ok := f.uploads[req.query.Get("uploadId")]
+		if !ok {
+			http.Error(w, "NoSuchUpload", http.StatusNotFound)
+			return
+		}
+		var complete struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			http.Error(w, "MalformedXML", http.StatusBadRequest)
+			return
+		}
+		var data []byte
+		for i, part := range complete.Parts {
+			if part.PartNumber != i+1 || part.ETag != fmt.Sprintf(`"part-%d"`, part.PartNumber) {
+				fmt.Fprint(w, "<Error><Code>InvalidPart</Code><Message>bad part list</Message></Error>")
+				return
+			}
+			data = append(data, upload.parts[part.PartNumber]...)
+		}
+		delete(f.uploads, req.query.Get("uploadId"))
+		f.objects[upload.key] = &fakeObject{data: data, header: upload.header, modTime: f.now()}
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case r.Method == http.MethodDelete && req.query.Has("uploadId"):
+		delete(f.uploads, req.query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		if req.header.Get("If-None-Match") == "*" && obj != nil ||
+			req.header.Get("If-Match") != "" && (obj == nil || obj.etag() != req.header.Get("If-Match")) {
+			http.Error(w, "PreconditionFailed", http.StatusPreconditionFailed)
+			return
+		}
+		f.objects[req.key] = &fakeObject{data: body, header: req.header, modTime: f.now()}
+		if f.lose != nil && f.lose(req) {
+			http.Error(w, "lost response", http.StatusServiceUnavailable)
+		}
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		if obj == nil {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", obj.etag())
+		w.Header().Set("Last-Modified", obj.modTime.UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Write(obj.data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, req.key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "NotImplemented", http.StatusNotImplemented)
+	}
+}
+
+// object returns the object at key, or nil.
+func (f *fakeS3) object(key string) *fakeObject {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.objects[key]
+}
+
+// keys returns the keys of the stored objects, sorted.
+func (f *fakeS3) keys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var keys []string
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// received returns the requests received so far.
+func (f *fakeS3) received() []fakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeRequest(nil), f.requests...)
+}
+
+// checkSignature signs a copy of r again with the headers it claims to have
+// signed, and compares the result with its Authorization header.
+func checkSignature(r *http.Request, body []byte) error {
+	auth := r.Header.Get("Authorization")
+	_, signed, ok := strings.Cut(auth, "SignedHeaders=")
+	if !ok {
+		return fmt.Errorf("no signature in %q", auth)
+	}
+	signed, _, _ = strings.Cut(signed, ",")
+	date, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return fmt.Errorf("bad X-Amz-Date: %v", err)
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != unsignedPayload && payloadHash != hashHex(body) {
+		return fmt.Errorf("X-Amz-Content-Sha256 %s doesn't match the body", payloadHash)
+	}
+
+	u := *r.URL
+	u.Scheme, u.Host = "http", r.Host
+	check, _ := http.NewRequest(r.Method, u.String(), nil)
+	for _, name := range strings.Split(signed, ";") {
+		if name != "host" && name != "x-amz-date" && name != "x-amz-content-sha256" {
+			check.Header[http.CanonicalHeaderKey(name)] = r.Header.Values(name)
+		}
+	}
+	sign(check, testCreds, "eu-west-1", payloadHash, date)
+	if got := check.Header.Get("Authorization"); got != auth {
+		return fmt.Errorf("signature mismatch:\n got  %s\n want %s", auth, got)
+	}
+	return nil
+}
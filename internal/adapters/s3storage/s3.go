@@ -0,0 +1,514 @@
+// Package s3storage stores job artifacts in an Amazon S3 (or S3-compatible)
+// bucket, laid out like the local backend's data directory: objects under
+// <prefix>jobs/<job ID>/, optionally partitioned by the job's start date.
+package s3storage
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"scrapeanddown/internal/adapters/tempdir"
+	"scrapeanddown/internal/core/ports"
+	"scrapeanddown/internal/retry"
+)
+
+// downloadStateFile records in-progress download state for resuming.
+const downloadStateFile = "download.state.json"
+
+// lockFileName is the per-job lock object inside the job prefix.
+const lockFileName = ".lock"
+
+// DefaultPartSize is the part size of multipart uploads; videos up to this
+// size are uploaded in a single PUT. With S3's limit of 10,000 parts it
+// allows videos of up to 640 GiB.
+const DefaultPartSize = 64 << 20
+
+// staleLockAge is how old a job's lock object must be before another
+// process may take the job over. Unlike a file lock, a lock object outlives
+// a crashed process, so without this a crash would lock the job for good.
+const staleLockAge = 24 * time.Hour
+
+// Date partition layouts for WithDatePartitions.
+const (
+	DailyPartitions   = "2006/01/02"
+	MonthlyPartitions = "2006/01"
+	HivePartitions    = "year=2006/month=01/day=02"
+)
+
+// S3Storage implements ports.Storage on an S3 bucket.
+type S3Storage struct {
+	client      *http.Client
+	bucket      string
+	region      string
+	creds       Credentials
+	endpoint    *url.URL
+	prefix      string
+	kmsKeyID    string
+	partitions  string
+	partSize    int64
+	retryPolicy retry.Policy
+	now         func() time.Time
+
+	mu    sync.Mutex
+	jobs  map[string]string // job ID -> key prefix of the job's objects
+	locks map[string]string // Held job ID -> token in its lock object
+}
+
+// Option configures an S3Storage.
+type Option func(*S3Storage)
+
+// WithPrefix stores every object under prefix, e.g. "scrapes/", so the
+// bucket can be shared.
+func WithPrefix(prefix string) Option {
+	return func(s *S3Storage) {
+		s.prefix = strings.Trim(prefix, "/")
+		if s.prefix != "" {
+			s.prefix += "/"
+		}
+	}
+}
+
+// WithSSEKMS encrypts every uploaded object with SSE-KMS under the given
+// KMS key ID or ARN.
+func WithSSEKMS(keyID string) Option {
+	return func(s *S3Storage) {
+		s.kmsKeyID = keyID
+	}
+}
+
+// WithDatePartitions puts new jobs under a prefix formatted from their
+// start date (UTC) with layout, e.g. DailyPartitions for
+// jobs/2024/06/12/<job ID>/, so lifecycle rules can expire old jobs by
+// prefix. The prefix is fixed when the job is first initialized; jobs are
+// still looked up by ID alone.
+func WithDatePartitions(layout string) Option {
+	return func(s *S3Storage) {
+		s.partitions = layout
+	}
+}
+
+// WithEndpoint sends requests to an S3-compatible service (e.g. MinIO) at
+// endpoint instead of AWS, addressing the bucket in the path.
+func WithEndpoint(endpoint *url.URL) Option {
+	return func(s *S3Storage) {
+		s.endpoint = endpoint
+	}
+}
+
+// WithHTTPClient sets the client requests are sent with.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *S3Storage) {
+		s.client = client
+	}
+}
+
+// NewS3Storage creates an S3Storage for bucket in region, signing requests
+// with creds.
+func NewS3Storage(bucket, region string, creds Credentials, opts ...Option) (*S3Storage, error) {
+	if bucket == "" {
+		return nil, errors.New("no S3 bucket given")
+	}
+	if region == "" {
+		return nil, errors.New("no S3 region given")
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, errors.New("no S3 credentials given")
+	}
+	s := &S3Storage{
+		client:      &http.Client{},
+		bucket:      bucket,
+		region:      region,
+		creds:       creds,
+		partSize:    DefaultPartSize,
+		retryPolicy: retry.DefaultPolicy,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.endpoint != nil && (s.endpoint.Scheme != "http" && s.endpoint.Scheme != "https" || s.endpoint.Host == "") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q: expected an http or https URL", s.endpoint)
+	}
+	return s, nil
+}
+
+// InitJob locks the job by creating its lock object, which fails if one
+// exists. The job's key prefix is recorded under index/ so the job can be
+// found by ID. Returns ports.ErrJobLocked if another process is working the
+// same job.
+func (s *S3Storage) InitJob(ctx context.Context, jobID string) error {
+	s.mu.Lock()
+	_, held := s.locks[jobID]
+	s.mu.Unlock()
+	if held {
+		return fmt.Errorf("%w: %s", ports.ErrJobLocked, jobID)
+	}
+
+	prefix, found, err := s.lookupJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		prefix = s.newJobPrefix(jobID)
+		if err := s.putBytes(ctx, s.indexKey(jobID), []byte(prefix), nil); err != nil {
+			return fmt.Errorf("failed to record job %s: %w", jobID, err)
+		}
+	}
+
+	token := rand.Text()
+	if err := s.acquireLock(ctx, prefix+lockFileName, token); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.jobs == nil {
+		s.jobs = make(map[string]string)
+		s.locks = make(map[string]string)
+	}
+	s.jobs[jobID] = prefix
+	s.locks[jobID] = token
+	return nil
+}
+
+// acquireLock creates the lock object at key holding token. A lock older
+// than staleLockAge is taken over, on the condition that it is unchanged.
+func (s *S3Storage) acquireLock(ctx context.Context, key, token string) error {
+	err := s.putBytes(ctx, key, []byte(token), http.Header{"If-None-Match": {"*"}})
+	if !errors.Is(err, errPreconditionFailed) {
+		if err != nil {
+			return fmt.Errorf("failed to lock job: %w", err)
+		}
+		return nil
+	}
+	// A retried PUT may have created the lock the first time round
+	if current, err := s.getBytes(ctx, key); err == nil && string(current) == token {
+		return nil
+	}
+	info, err := s.head(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to check job lock: %w", err)
+	}
+	if info.modTime.IsZero() || s.now().Sub(info.modTime) < staleLockAge {
+		return fmt.Errorf("%w: %s", ports.ErrJobLocked, key)
+	}
+	err = s.putBytes(ctx, key, []byte(token), http.Header{"If-Match": {info.etag}})
+	if errors.Is(err, errPreconditionFailed) {
+		return fmt.Errorf("%w: %s", ports.ErrJobLocked, key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock job: %w", err)
+	}
+	return nil
+}
+
+// ReleaseJob deletes the lock object taken by InitJob.
+func (s *S3Storage) ReleaseJob(ctx context.Context, jobID string) error {
+	s.mu.Lock()
+	_, held := s.locks[jobID]
+	delete(s.locks, jobID)
+	prefix := s.jobs[jobID]
+	s.mu.Unlock()
+	if !held {
+		return nil
+	}
+	if err := s.remove(ctx, prefix+lockFileName); err != nil {
+		return fmt.Errorf("failed to release job lock: %w", err)
+	}
+	return nil
+}
+
+// SaveInput saves the job input metadata.
+func (s *S3Storage) SaveInput(ctx context.Context, jobID string, data []byte) error {
+	return s.saveFile(ctx, jobID, "input.json", data)
+}
+
+// SaveMetadata saves the raw API response.
+func (s *S3Storage) SaveMetadata(ctx context.Context, jobID string, data []byte) error {
+	return s.saveFile(ctx, jobID, "metadata_raw.json", data)
+}
+
+// SaveProjectedMetadata saves the selected metadata fields.
+func (s *S3Storage) SaveProjectedMetadata(ctx context.Context, jobID string, data []byte) error {
+	return s.saveFile(ctx, jobID, "metadata.json", data)
+}
+
+// SavePage saves the video page HTML.
+func (s *S3Storage) SavePage(ctx context.Context, jobID string, data []byte) error {
+	return s.saveFile(ctx, jobID, "page.html", data)
+}
+
+// SaveNormalizedMetadata saves the normalized metadata.
+func (s *S3Storage) SaveNormalizedMetadata(ctx context.Context, jobID string, data []byte) error {
+	return s.saveFile(ctx, jobID, "metadata_normalized.json", data)
+}
+
+// SaveComments saves the scraped comments.
+func (s *S3Storage) SaveComments(ctx context.Context, jobID string, data []byte) error {
+	return s.saveFile(ctx, jobID, "comments.json", data)
+}
+
+// SaveScrapeMeta saves the IDs of the scrape run.
+func (s *S3Storage) SaveScrapeMeta(ctx context.Context, jobID string, data []byte) error {
+	return s.saveFile(ctx, jobID, "scrape_meta.json", data)
+}
+
+// SaveVideo uploads the video. An upload needs its length up front, so the
+// stream is spooled to a temporary file first, in the job's scratch
+// directory if the context carries one.
+func (s *S3Storage) SaveVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error {
+	if filename == "" {
+		filename = "video.mp4"
+	}
+	spool, size, err := spoolFile(ctx, nil, reader)
+	if err != nil {
+		return fmt.Errorf("failed to write video file: %w", err)
+	}
+	defer removeSpool(spool)
+	if err := s.putFile(ctx, s.key(ctx, jobID, filename), spool, size); err != nil {
+		return fmt.Errorf("failed to save %s: %w", filename, err)
+	}
+	return nil
+}
+
+// AppendVideo appends to an existing video object. Objects can't be
+// appended to in place, so the existing object is downloaded and uploaded
+// again with reader's data after it.
+func (s *S3Storage) AppendVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error {
+	key := s.key(ctx, jobID, filename)
+	existing, _, err := s.get(ctx, key)
+	if errors.Is(err, os.ErrNotExist) {
+		existing, err = io.NopCloser(strings.NewReader("")), nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open video file %s: %w", filename, err)
+	}
+	defer existing.Close()
+	spool, size, err := spoolFile(ctx, existing, reader)
+	if err != nil {
+		return fmt.Errorf("failed to write video file: %w", err)
+	}
+	defer removeSpool(spool)
+	if err := s.putFile(ctx, key, spool, size); err != nil {
+		return fmt.Errorf("failed to save %s: %w", filename, err)
+	}
+	return nil
+}
+
+// OpenVideo opens a saved video object for reading. The reader is not
+// seekable.
+func (s *S3Storage) OpenVideo(ctx context.Context, jobID string, filename string) (io.ReadCloser, int64, error) {
+	body, size, err := s.get(ctx, s.key(ctx, jobID, filename))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open video file %s: %w", filename, err)
+	}
+	return body, size, nil
+}
+
+// SaveDownloadState saves the in-progress download state.
+func (s *S3Storage) SaveDownloadState(ctx context.Context, jobID string, data []byte) error {
+	return s.saveFile(ctx, jobID, downloadStateFile, data)
+}
+
+// LoadDownloadState reads the in-progress download state.
+func (s *S3Storage) LoadDownloadState(ctx context.Context, jobID string) ([]byte, error) {
+	data, err := s.getBytes(ctx, s.key(ctx, jobID, downloadStateFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", downloadStateFile, err)
+	}
+	return data, nil
+}
+
+// RemoveDownloadState deletes the download state object, if any.
+func (s *S3Storage) RemoveDownloadState(ctx context.Context, jobID string) error {
+	if err := s.remove(ctx, s.key(ctx, jobID, downloadStateFile)); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", downloadStateFile, err)
+	}
+	return nil
+}
+
+// SaveStoryboard saves a storyboard image under the job's storyboards/ prefix.
+func (s *S3Storage) SaveStoryboard(ctx context.Context, jobID string, name string, data []byte) error {
+	if err := s.putBytes(ctx, s.key(ctx, jobID, path.Join("storyboards", name)), data, nil); err != nil {
+		return fmt.Errorf("failed to save storyboard %s: %w", name, err)
+	}
+	return nil
+}
+
+// SaveReference saves the duplicate-content reference.
+func (s *S3Storage) SaveReference(ctx context.Context, jobID string, data []byte) error {
+	return s.saveFile(ctx, jobID, "duplicate_of.json", data)
+}
+
+// RemoveArtifact deletes a job artifact. S3 deletes of missing objects
+// succeed, so the object is looked up first to report os.ErrNotExist as the
+// local backend does.
+func (s *S3Storage) RemoveArtifact(ctx context.Context, jobID string, filename string) error {
+	key := s.key(ctx, jobID, filename)
+	if _, err := s.head(ctx, key); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", filename, err)
+	}
+	if err := s.remove(ctx, key); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", filename, err)
+	}
+	return nil
+}
+
+// SaveManifest saves the job manifest.
+func (s *S3Storage) SaveManifest(ctx context.Context, jobID string, data []byte) error {
+	return s.saveFile(ctx, jobID, "manifest.json", data)
+}
+
+// WriteMarker creates an empty marker object. Uploads appear all at once,
+// so no rename is needed.
+func (s *S3Storage) WriteMarker(ctx context.Context, jobID string, name string) error {
+	if err := s.putBytes(ctx, s.key(ctx, jobID, name), nil, nil); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Exists reports whether a job artifact is present; an empty filename asks
+// whether the job itself exists.
+func (s *S3Storage) Exists(ctx context.Context, jobID string, filename string) (bool, error) {
+	key := s.indexKey(jobID)
+	if filename != "" {
+		key = s.key(ctx, jobID, filename)
+	}
+	exists, err := s.exists(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to check artifact %s: %w", filename, err)
+	}
+	return exists, nil
+}
+
+// StatArtifact returns size and modification time of a job artifact.
+func (s *S3Storage) StatArtifact(ctx context.Context, jobID string, filename string) (*ports.ArtifactInfo, error) {
+	info, err := s.head(ctx, s.key(ctx, jobID, filename))
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat artifact %s: %w", filename, err)
+	}
+	return &ports.ArtifactInfo{
+		Name:    filename,
+		Size:    info.size,
+		ModTime: info.modTime,
+	}, nil
+}
+
+// GetJobPath returns the job's location as an s3:// URL, with the date
+// partition it was initialized under.
+func (s *S3Storage) GetJobPath(jobID string) string {
+	return "s3://" + s.bucket + "/" + strings.TrimSuffix(s.jobPrefix(context.Background(), jobID), "/")
+}
+
+// saveFile uploads one of the job's small artifacts.
+func (s *S3Storage) saveFile(ctx context.Context, jobID, name string, data []byte) error {
+	if err := s.putBytes(ctx, s.key(ctx, jobID, name), data, nil); err != nil {
+		return fmt.Errorf("failed to save %s: %w", name, err)
+	}
+	return nil
+}
+
+// key returns the object key of the job's file.
+func (s *S3Storage) key(ctx context.Context, jobID, filename string) string {
+	return s.jobPrefix(ctx, jobID) + filename
+}
+
+// indexKey returns the key of the object recording the job's key prefix.
+func (s *S3Storage) indexKey(jobID string) string {
+	return s.prefix + "index/" + jobID
+}
+
+// jobPrefix returns the key prefix of the job's objects. A job that hasn't
+// been recorded gets the prefix InitJob would give it now.
+func (s *S3Storage) jobPrefix(ctx context.Context, jobID string) string {
+	if prefix, found, err := s.lookupJob(ctx, jobID); err == nil && found {
+		return prefix
+	}
+	return s.newJobPrefix(jobID)
+}
+
+// lookupJob returns the key prefix recorded for the job under index/, and
+// caches it. The index is read even without date partitions, so jobs
+// started with them are found after the option is turned off.
+func (s *S3Storage) lookupJob(ctx context.Context, jobID string) (string, bool, error) {
+	s.mu.Lock()
+	prefix, ok := s.jobs[jobID]
+	s.mu.Unlock()
+	if ok {
+		return prefix, true, nil
+	}
+	data, err := s.getBytes(ctx, s.indexKey(jobID))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to look up job %s: %w", jobID, err)
+	}
+	prefix = string(data)
+	if !strings.HasPrefix(prefix, s.prefix+"jobs/") || !strings.HasSuffix(prefix, "/"+jobID+"/") {
+		return "", false, fmt.Errorf("failed to look up job %s: invalid index entry %q", jobID, prefix)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.jobs == nil {
+		s.jobs = make(map[string]string)
+		s.locks = make(map[string]string)
+	}
+	s.jobs[jobID] = prefix
+	return prefix, true, nil
+}
+
+// newJobPrefix returns the key prefix for a job started now.
+func (s *S3Storage) newJobPrefix(jobID string) string {
+	if s.partitions == "" {
+		return s.prefix + "jobs/" + jobID + "/"
+	}
+	return s.prefix + "jobs/" + s.now().UTC().Format(s.partitions) + "/" + jobID + "/"
+}
+
+// spoolFile copies the readers, one after the other, into a new temporary
+// file, returning it and its size. A nil reader is skipped.
+func spoolFile(ctx context.Context, readers ...io.Reader) (*os.File, int64, error) {
+	dir, _ := tempdir.DirFromContext(ctx)
+	spool, err := os.CreateTemp(dir, "s3-upload-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	var size int64
+	for _, r := range readers {
+		if r == nil {
+			continue
+		}
+		n, err := io.Copy(spool, r)
+		size += n
+		if err != nil {
+			removeSpool(spool)
+			return nil, 0, err
+		}
+	}
+	return spool, size, nil
+}
+
+func removeSpool(spool *os.File) {
+	spool.Close()
+	os.Remove(spool.Name())
+}
+
+// contentType guesses an object's Content-Type from its extension.
+func contentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,381 @@
+package s3storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+var testNow = time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC)
+
+const testKMSKey = "arn:aws:kms:eu-west-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+
+// saveJob runs a job's saves like the orchestrator does: metadata, a video,
+// the manifest and the success marker.
+func saveJob(t *testing.T, s *S3Storage, jobID string, video []byte) {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.InitJob(ctx, jobID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveInput(ctx, jobID, []byte(`{"url":"https://youtu.be/x"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveMetadata(ctx, jobID, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveVideo(ctx, jobID, bytes.NewReader(video), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveStoryboard(ctx, jobID, "sb0.jpg", []byte("jpeg")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveManifest(ctx, jobID, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteMarker(ctx, jobID, "_SUCCESS"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReleaseJob(ctx, jobID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadsUseSSEKMS(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		video int // video size, over the test part size for a multipart upload
+		want  string
+	}{
+		{"SSE-KMS", []Option{WithSSEKMS(testKMSKey)}, 100, testKMSKey},
+		{"SSE-KMS multipart", []Option{WithSSEKMS(testKMSKey)}, 2500, testKMSKey},
+		{"no encryption", nil, 100, ""},
+		{"no encryption multipart", nil, 2500, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, f := newTestStorage(t, testNow, tt.opts...)
+			s.partSize = 1024
+			saveJob(t, s, "job1", bytes.Repeat([]byte("v"), tt.video))
+
+			var uploads, parts int
+			for _, r := range f.received() {
+				sse := r.header.Get("X-Amz-Server-Side-Encryption")
+				keyID := r.header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id")
+				switch {
+				case r.method == http.MethodPut && r.query.Has("uploadId"):
+					// S3 rejects SSE-KMS headers on parts; the upload carries them
+					parts++
+					if sse != "" || keyID != "" {
+						t.Errorf("part upload of %s has SSE headers %q, %q", r.key, sse, keyID)
+					}
+				case r.method == http.MethodPut, r.method == http.MethodPost && r.query.Has("uploads"):
+					uploads++
+					if tt.want == "" && (sse != "" || keyID != "") {
+						t.Errorf("%s %s has SSE headers %q, %q", r.method, r.key, sse, keyID)
+					}
+					if tt.want != "" && (sse != "aws:kms" || keyID != tt.want) {
+						t.Errorf("%s %s: SSE headers = %q, %q, want aws:kms, %s", r.method, r.key, sse, keyID, tt.want)
+					}
+				}
+			}
+			if uploads == 0 {
+				t.Fatal("no uploads")
+			}
+			if wantParts := (tt.video + 1023) / 1024; tt.video > 1024 && parts != wantParts {
+				t.Errorf("uploaded %d parts, want %d", parts, wantParts)
+			}
+			if video := f.object("jobs/job1/video.mp4"); video == nil || len(video.data) != tt.video {
+				t.Errorf("video object = %v, want %d bytes", video, tt.video)
+			}
+		})
+	}
+}
+
+func TestDatePartitions(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		prefix string
+	}{
+		{"flat", nil, "jobs/job1/"},
+		{"daily", []Option{WithDatePartitions(DailyPartitions)}, "jobs/2024/06/12/job1/"},
+		{"monthly", []Option{WithDatePartitions(MonthlyPartitions)}, "jobs/2024/06/job1/"},
+		{"hive", []Option{WithDatePartitions(HivePartitions)}, "jobs/year=2024/month=06/day=12/job1/"},
+		{"prefixed", []Option{WithPrefix("/scrapes/"), WithDatePartitions(DailyPartitions)}, "scrapes/jobs/2024/06/12/job1/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, f := newTestStorage(t, testNow, tt.opts...)
+			saveJob(t, s, "job1", []byte("video"))
+
+			for _, name := range []string{"input.json", "metadata_raw.json", "video.mp4", "storyboards/sb0.jpg", "manifest.json", "_SUCCESS"} {
+				if f.object(tt.prefix+name) == nil {
+					t.Errorf("no object %s%s; have %v", tt.prefix, name, f.keys())
+				}
+			}
+			if got, want := s.GetJobPath("job1"), "s3://videos/"+strings.TrimSuffix(tt.prefix, "/"); got != want {
+				t.Errorf("GetJobPath = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestDatePartitionsFixedAtInit(t *testing.T) {
+	ctx := context.Background()
+	s, f := newTestStorage(t, testNow, WithDatePartitions(DailyPartitions))
+	saveJob(t, s, "job1", []byte("video"))
+
+	// Another process, days later, still finds the job where it started
+	later, err := NewS3Storage(testBucket, "eu-west-1", testCreds, WithEndpoint(s.endpoint), WithDatePartitions(DailyPartitions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	later.now = func() time.Time { return testNow.Add(72 * time.Hour) }
+	if got, want := later.GetJobPath("job1"), "s3://videos/jobs/2024/06/12/job1"; got != want {
+		t.Errorf("GetJobPath = %s, want %s", got, want)
+	}
+	if err := later.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := later.SaveComments(ctx, "job1", []byte(`[]`)); err != nil {
+		t.Fatal(err)
+	}
+	if f.object("jobs/2024/06/12/job1/comments.json") == nil {
+		t.Errorf("comments not saved in the job's partition; have %v", f.keys())
+	}
+	if got, want := later.GetJobPath("job2"), "s3://videos/jobs/2024/06/15/job2"; got != want {
+		t.Errorf("GetJobPath of a new job = %s, want %s", got, want)
+	}
+}
+
+func TestInitJobLocked(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStorage(t, testNow)
+	other, err := NewS3Storage(testBucket, "eu-west-1", testCreds, WithEndpoint(s.endpoint))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := testNow
+	other.now = func() time.Time { return now }
+
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InitJob(ctx, "job1"); !errors.Is(err, ports.ErrJobLocked) {
+		t.Errorf("InitJob while held here: err = %v, want ports.ErrJobLocked", err)
+	}
+	if err := other.InitJob(ctx, "job1"); !errors.Is(err, ports.ErrJobLocked) {
+		t.Errorf("InitJob while held elsewhere: err = %v, want ports.ErrJobLocked", err)
+	}
+
+	// A lock left by a process that died is taken over once stale
+	now = testNow.Add(staleLockAge + time.Minute)
+	if err := other.InitJob(ctx, "job1"); err != nil {
+		t.Errorf("InitJob on a stale lock: %v", err)
+	}
+	if err := other.ReleaseJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	s.ReleaseJob(ctx, "job1")
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Errorf("InitJob after release: %v", err)
+	}
+}
+
+func TestInitJobRetriedLock(t *testing.T) {
+	s, f := newTestStorage(t, testNow)
+	// The lock is created but its response is lost, so the PUT is retried
+	// and finds the lock it created itself
+	lost := false
+	f.lose = func(r fakeRequest) bool {
+		if lost || !strings.HasSuffix(r.key, lockFileName) {
+			return false
+		}
+		lost = true
+		return true
+	}
+	if err := s.InitJob(context.Background(), "job1"); err != nil {
+		t.Errorf("InitJob: %v", err)
+	}
+	if !lost {
+		t.Error("the lock's response wasn't lost")
+	}
+}
+
+func TestMissingArtifacts(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStorage(t, testNow)
+	if exists, err := s.Exists(ctx, "job1", ""); err != nil || exists {
+		t.Errorf("Exists(job) before InitJob = %v, %v, want false, nil", exists, err)
+	}
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := s.Exists(ctx, "job1", ""); err != nil || !exists {
+		t.Errorf("Exists(job) after InitJob = %v, %v, want true, nil", exists, err)
+	}
+	if exists, err := s.Exists(ctx, "job1", "video.mp4"); err != nil || exists {
+		t.Errorf("Exists(video.mp4) = %v, %v, want false, nil", exists, err)
+	}
+
+	if _, _, err := s.OpenVideo(ctx, "job1", "video.mp4"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("OpenVideo err = %v, want os.ErrNotExist", err)
+	}
+	if _, err := s.LoadDownloadState(ctx, "job1"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadDownloadState err = %v, want os.ErrNotExist", err)
+	}
+	if _, err := s.StatArtifact(ctx, "job1", "video.mp4"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("StatArtifact err = %v, want os.ErrNotExist", err)
+	}
+	if err := s.RemoveArtifact(ctx, "job1", "video.mp4"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("RemoveArtifact err = %v, want os.ErrNotExist", err)
+	}
+	if err := s.RemoveDownloadState(ctx, "job1"); err != nil {
+		t.Errorf("RemoveDownloadState without state: %v", err)
+	}
+}
+
+func TestVideoRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStorage(t, testNow)
+	s.partSize = 1024
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	first := bytes.Repeat([]byte("a"), 1500)
+	rest := bytes.Repeat([]byte("b"), 700)
+	if err := s.SaveVideo(ctx, "job1", bytes.NewReader(first), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AppendVideo(ctx, "job1", bytes.NewReader(rest), "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+
+	body, size, err := s.OpenVideo(ctx, "job1", "video.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append(first, rest...)
+	if !bytes.Equal(got, want) || size != int64(len(want)) {
+		t.Errorf("OpenVideo = %d bytes (size %d), want %d bytes of the saved and appended data", len(got), size, len(want))
+	}
+	info, err := s.StatArtifact(ctx, "job1", "video.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(want)) || !info.ModTime.Equal(testNow) {
+		t.Errorf("StatArtifact = %+v, want size %d, modified %v", info, len(want), testNow)
+	}
+
+	if err := s.RemoveArtifact(ctx, "job1", "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := s.Exists(ctx, "job1", "video.mp4"); exists {
+		t.Error("video still exists after RemoveArtifact")
+	}
+}
+
+func TestDownloadState(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStorage(t, testNow)
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveDownloadState(ctx, "job1", []byte(`{"bytes_written":10}`)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := s.LoadDownloadState(ctx, "job1")
+	if err != nil || string(data) != `{"bytes_written":10}` {
+		t.Errorf("LoadDownloadState = %q, %v", data, err)
+	}
+	if err := s.RemoveDownloadState(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.LoadDownloadState(ctx, "job1"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadDownloadState after removal: err = %v, want os.ErrNotExist", err)
+	}
+}
+
+func TestTransientErrorsRetried(t *testing.T) {
+	s, f := newTestStorage(t, testNow)
+	failures := 0
+	f.fail = func(r fakeRequest) int {
+		if r.method == http.MethodPut && strings.HasSuffix(r.key, "input.json") && failures < 2 {
+			failures++
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	}
+	ctx := context.Background()
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveInput(ctx, "job1", []byte(`{}`)); err != nil {
+		t.Fatalf("SaveInput: %v", err)
+	}
+	if failures != 2 || f.object("jobs/job1/input.json") == nil {
+		t.Errorf("after %d failures, input.json = %v", failures, f.object("jobs/job1/input.json"))
+	}
+}
+
+func TestFailedMultipartUploadAborted(t *testing.T) {
+	s, f := newTestStorage(t, testNow)
+	s.partSize = 1024
+	f.fail = func(r fakeRequest) int {
+		if r.method == http.MethodPut && r.query.Get("partNumber") == "2" {
+			return http.StatusBadRequest
+		}
+		return 0
+	}
+	ctx := context.Background()
+	if err := s.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveVideo(ctx, "job1", bytes.NewReader(make([]byte, 3000)), "video.mp4"); err == nil {
+		t.Fatal("SaveVideo succeeded with a failing part")
+	}
+	if len(f.uploads) != 0 {
+		t.Errorf("%d multipart uploads left unaborted", len(f.uploads))
+	}
+	if f.object("jobs/job1/video.mp4") != nil {
+		t.Error("video object created from a failed upload")
+	}
+}
+
+func TestNewS3StorageValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		bucket string
+		region string
+		creds  Credentials
+		opts   []Option
+	}{
+		{"no bucket", "", "eu-west-1", testCreds, nil},
+		{"no region", testBucket, "", testCreds, nil},
+		{"no secret key", testBucket, "eu-west-1", Credentials{AccessKeyID: "AKID"}, nil},
+		{"bad endpoint", testBucket, "eu-west-1", testCreds, []Option{WithEndpoint(&url.URL{Scheme: "ftp", Host: "minio"})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewS3Storage(tt.bucket, tt.region, tt.creds, tt.opts...); err == nil {
+				t.Error("NewS3Storage succeeded")
+			}
+		})
+	}
+}
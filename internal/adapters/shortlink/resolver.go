@@ -0,0 +1,64 @@
+package shortlink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"scrapeanddown/internal/retry"
+)
+
+// maxRedirects bounds how many hops a short link may take.
+const maxRedirects = 10
+
+// Resolver implements ports.LinkExpander by following HTTP redirects.
+type Resolver struct {
+	client *http.Client
+}
+
+// NewResolver creates a new Resolver.
+func NewResolver() *Resolver {
+	return NewResolverWithClient(&http.Client{
+		Timeout: 15 * time.Second,
+	})
+}
+
+// NewResolverWithClient creates a new Resolver using the given client. The
+// client's redirect policy is replaced to bound the number of hops.
+func NewResolverWithClient(client *http.Client) *Resolver {
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return nil
+	}
+	return &Resolver{client: &c}
+}
+
+// Expand follows shortURL's redirects and returns the final URL.
+func (r *Resolver) Expand(ctx context.Context, shortURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, shortURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	var finalURL string
+	err = retry.Do(ctx, retry.DefaultPolicy, func() error {
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to expand %s: %w", shortURL, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("failed to expand %s: %w", shortURL, retry.NewStatusError(resp))
+		}
+		// Only the final URL matters; don't download the page
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		finalURL = resp.Request.URL.String()
+		return nil
+	})
+	return finalURL, err
+}
@@ -0,0 +1,62 @@
+package shortlink
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/abc123/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/hop", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/hop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/pin/123/sent/?invite_code=x", http.StatusFound)
+	})
+	mux.HandleFunc("/pin/123/sent/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>pin</html>"))
+	})
+	mux.HandleFunc("/loop/", func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/loop/"))
+		http.Redirect(w, r, "/loop/"+strconv.Itoa(n+1), http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	r := NewResolverWithClient(srv.Client())
+
+	tests := []struct {
+		name, url, want, err string
+	}{
+		{"redirects", srv.URL + "/abc123/", srv.URL + "/pin/123/sent/?invite_code=x", ""},
+		{"no redirect", srv.URL + "/pin/123/sent/", srv.URL + "/pin/123/sent/", ""},
+		{"too many redirects", srv.URL + "/loop/0", "", "stopped after 10 redirects"},
+		{"not found", srv.URL + "/missing", "", "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Expand(context.Background(), tt.url)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Expand = %q, %v; want an error with %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Expand = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// The resolver's redirect limit doesn't change the client it was given.
+func TestNewResolverWithClientCopiesClient(t *testing.T) {
+	client := &http.Client{}
+	NewResolverWithClient(client)
+	if client.CheckRedirect != nil {
+		t.Error("NewResolverWithClient changed the caller's client")
+	}
+}
@@ -0,0 +1,64 @@
+package tempdir
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Manager hands out per-job scratch directories under a common root and
+// removes them when the job finishes.
+type Manager struct {
+	root string
+}
+
+// NewManager creates a new Manager rooted at root.
+// An empty root defaults to os.TempDir().
+func NewManager(root string) *Manager {
+	if root == "" {
+		root = os.TempDir()
+	}
+	return &Manager{root: filepath.Join(root, "scrapeanddown")}
+}
+
+// Root returns the directory under which job scratch dirs are created.
+func (m *Manager) Root() string {
+	return m.root
+}
+
+// JobDir creates (if needed) and returns the scratch directory for a job.
+func (m *Manager) JobDir(jobID string) (string, error) {
+	path := m.path(jobID)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", fmt.Errorf("failed to create temp directory %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// Cleanup removes the job's scratch directory and everything in it.
+func (m *Manager) Cleanup(jobID string) error {
+	path := m.path(jobID)
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("failed to remove temp directory %s: %w", path, err)
+	}
+	return nil
+}
+
+func (m *Manager) path(jobID string) string {
+	return filepath.Join(m.root, jobID)
+}
+
+type ctxKey struct{}
+
+// WithDir returns a context carrying the job's scratch directory so adapters
+// can place intermediate files there.
+func WithDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, dir)
+}
+
+// DirFromContext returns the job's scratch directory, if one was attached.
+func DirFromContext(ctx context.Context) (string, bool) {
+	dir, ok := ctx.Value(ctxKey{}).(string)
+	return dir, ok && dir != ""
+}
@@ -0,0 +1,74 @@
+package tempdir
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManager(t *testing.T) {
+	root := t.TempDir()
+	m := NewManager(root)
+	if want := filepath.Join(root, "scrapeanddown"); m.Root() != want {
+		t.Errorf("Root = %s, want %s", m.Root(), want)
+	}
+
+	dir, err := m.JobDir("job1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(m.Root(), "job1"); dir != want {
+		t.Errorf("JobDir = %s, want %s", dir, want)
+	}
+	if again, err := m.JobDir("job1"); err != nil || again != dir {
+		t.Errorf("second JobDir = %s, %v; want the same directory", again, err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "hls-1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hls-1", "segment_000000"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	other, err := m.JobDir("job2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Cleanup("job1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("job1's directory left after Cleanup: %v", err)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("Cleanup removed another job's directory: %v", err)
+	}
+	if err := m.Cleanup("never-started"); err != nil {
+		t.Errorf("Cleanup of a job without a directory: %v", err)
+	}
+}
+
+func TestNewManagerDefaultsToTempDir(t *testing.T) {
+	if got, want := NewManager("").Root(), filepath.Join(os.TempDir(), "scrapeanddown"); got != want {
+		t.Errorf("Root = %s, want %s", got, want)
+	}
+}
+
+func TestDirFromContext(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		dir  string
+		ok   bool
+	}{
+		{"none", context.Background(), "", false},
+		{"empty", WithDir(context.Background(), ""), "", false},
+		{"set", WithDir(context.Background(), "/tmp/scrapeanddown/job1"), "/tmp/scrapeanddown/job1", true},
+	}
+	for _, tt := range tests {
+		if dir, ok := DirFromContext(tt.ctx); dir != tt.dir || ok != tt.ok {
+			t.Errorf("%s: DirFromContext = %q, %v; want %q, %v", tt.name, dir, ok, tt.dir, tt.ok)
+		}
+	}
+}
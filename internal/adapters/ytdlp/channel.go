@@ -0,0 +1,73 @@
+package ytdlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// ListChannel lists a channel's (or playlist's) videos with
+// --flat-playlist, which reads only the listing pages.
+func (d *YtDlpDownloader) ListChannel(ctx context.Context, channelURL string) (*ports.ChannelListing, error) {
+	out, err := d.run(ctx, "-J", "--flat-playlist", "--no-warnings", channelURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseChannelListing([]byte(out))
+}
+
+// playlistEntry is the subset of a flat-playlist entry we use. Channel URLs
+// without a tab list their tabs (Videos, Shorts, ...) as nested playlists.
+type playlistEntry struct {
+	Type    string          `json:"_type"`
+	ID      string          `json:"id"`
+	URL     string          `json:"url"`
+	IEKey   string          `json:"ie_key"`
+	Entries []playlistEntry `json:"entries"`
+}
+
+// parseChannelListing extracts the channel ID, title and video entries from
+// a --flat-playlist -J dump.
+func parseChannelListing(dump []byte) (*ports.ChannelListing, error) {
+	var info struct {
+		playlistEntry
+		ChannelID string `json:"channel_id"`
+		Title     string `json:"title"`
+	}
+	if err := json.Unmarshal(dump, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp output: %w", err)
+	}
+
+	listing := &ports.ChannelListing{ChannelID: info.ChannelID, Title: info.Title}
+	if listing.ChannelID == "" {
+		listing.ChannelID = info.ID
+	}
+	if listing.ChannelID == "" {
+		return nil, fmt.Errorf("yt-dlp returned no channel id")
+	}
+
+	seen := make(map[string]bool)
+	var collect func(entries []playlistEntry)
+	collect = func(entries []playlistEntry) {
+		for _, e := range entries {
+			if len(e.Entries) > 0 {
+				collect(e.Entries)
+				continue
+			}
+			// Skip unexpanded tabs and other non-video entries
+			if e.ID == "" || (e.IEKey != "" && e.IEKey != "Youtube") || seen[e.ID] {
+				continue
+			}
+			seen[e.ID] = true
+			url := e.URL
+			if url == "" {
+				url = "https://www.youtube.com/watch?v=" + e.ID
+			}
+			listing.Videos = append(listing.Videos, ports.ChannelVideo{ID: e.ID, URL: url})
+		}
+	}
+	collect(info.Entries)
+	return listing, nil
+}
@@ -0,0 +1,83 @@
+package ytdlp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestParseChannelListing(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+		want *ports.ChannelListing
+		err  bool
+	}{
+		{
+			name: "videos tab",
+			dump: `{"id": "UC1", "channel_id": "UC1", "title": "Channel - Videos", "entries": [
+				{"id": "aaaaaaaaaaa", "url": "https://www.youtube.com/watch?v=aaaaaaaaaaa", "ie_key": "Youtube"},
+				{"id": "bbbbbbbbbbb", "ie_key": "Youtube"}
+			]}`,
+			want: &ports.ChannelListing{ChannelID: "UC1", Title: "Channel - Videos", Videos: []ports.ChannelVideo{
+				{ID: "aaaaaaaaaaa", URL: "https://www.youtube.com/watch?v=aaaaaaaaaaa"},
+				{ID: "bbbbbbbbbbb", URL: "https://www.youtube.com/watch?v=bbbbbbbbbbb"},
+			}},
+		},
+		{
+			name: "nested tabs",
+			dump: `{"id": "@channel", "channel_id": "UC1", "title": "Channel", "entries": [
+				{"_type": "playlist", "id": "UC1-videos", "entries": [{"id": "aaaaaaaaaaa", "ie_key": "Youtube"}]},
+				{"_type": "playlist", "id": "UC1-shorts", "entries": [
+					{"id": "ccccccccccc", "url": "https://www.youtube.com/shorts/ccccccccccc", "ie_key": "Youtube"},
+					{"id": "aaaaaaaaaaa", "ie_key": "Youtube"}
+				]},
+				{"_type": "url", "id": "UC1-live", "url": "https://www.youtube.com/@channel/live", "ie_key": "YoutubeTab"}
+			]}`,
+			want: &ports.ChannelListing{ChannelID: "UC1", Title: "Channel", Videos: []ports.ChannelVideo{
+				{ID: "aaaaaaaaaaa", URL: "https://www.youtube.com/watch?v=aaaaaaaaaaa"},
+				{ID: "ccccccccccc", URL: "https://www.youtube.com/shorts/ccccccccccc"},
+			}},
+		},
+		{
+			name: "playlist",
+			dump: `{"id": "PL1", "title": "Playlist", "entries": [{"id": "aaaaaaaaaaa"}]}`,
+			want: &ports.ChannelListing{ChannelID: "PL1", Title: "Playlist", Videos: []ports.ChannelVideo{
+				{ID: "aaaaaaaaaaa", URL: "https://www.youtube.com/watch?v=aaaaaaaaaaa"},
+			}},
+		},
+		{name: "no id", dump: `{"title": "Channel", "entries": []}`, err: true},
+		{name: "invalid json", dump: `not json`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseChannelListing([]byte(tt.dump))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("parseChannelListing = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseChannelListing =\n %+v\nwant\n %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListChannelArguments(t *testing.T) {
+	runner := &fakeRunner{results: []fakeRunResult{{stdout: `{"id": "UC1", "entries": []}`}}}
+	if _, err := newFakeDownloader(runner).ListChannel(context.Background(), "https://www.youtube.com/@channel"); err != nil {
+		t.Fatal(err)
+	}
+	args := runner.calls[0]
+	want := []string{"-J", "--flat-playlist", "--no-warnings", "https://www.youtube.com/@channel"}
+	if !reflect.DeepEqual(args[len(args)-len(want):], want) {
+		t.Errorf("yt-dlp args = %v, want them to end with %v", args, want)
+	}
+}
@@ -0,0 +1,68 @@
+package ytdlp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// supportedBrowsers are the browsers yt-dlp's --cookies-from-browser reads.
+var supportedBrowsers = map[string]bool{
+	"brave": true, "chrome": true, "chromium": true, "edge": true, "firefox": true,
+	"opera": true, "safari": true, "vivaldi": true, "whale": true,
+}
+
+// errConflictingCookies is returned when both cookie sources are configured.
+var errConflictingCookies = errors.New("cookies file and cookies from browser are mutually exclusive")
+
+// WithCookiesFile passes a Netscape-format cookies file to yt-dlp (--cookies),
+// e.g. for age-restricted or members-only videos.
+func WithCookiesFile(path string) Option {
+	return func(d *YtDlpDownloader) {
+		d.cookiesFile = path
+	}
+}
+
+// WithCookiesFromBrowser makes yt-dlp read cookies from an installed browser
+// (--cookies-from-browser). spec is BROWSER[+KEYRING][:PROFILE][::CONTAINER],
+// e.g. "chrome" or "firefox:default-release"; check it with ValidateBrowser.
+func WithCookiesFromBrowser(spec string) Option {
+	return func(d *YtDlpDownloader) {
+		d.cookiesFromBrowser = spec
+	}
+}
+
+// ValidateBrowser checks that a --cookies-from-browser spec names a browser
+// yt-dlp supports.
+func ValidateBrowser(spec string) error {
+	browser := strings.ToLower(spec)
+	if i := strings.IndexAny(browser, "+:"); i >= 0 {
+		browser = browser[:i]
+	}
+	if !supportedBrowsers[browser] {
+		names := make([]string, 0, len(supportedBrowsers))
+		for name := range supportedBrowsers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unsupported browser %q: expected one of %s", browser, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// cookieArgs returns the yt-dlp arguments for the configured cookie source.
+func (d *YtDlpDownloader) cookieArgs() ([]string, error) {
+	switch {
+	case d.cookiesFile != "" && d.cookiesFromBrowser != "":
+		return nil, errConflictingCookies
+	case d.cookiesFile != "":
+		return []string{"--cookies", d.cookiesFile}, nil
+	case d.cookiesFromBrowser != "":
+		if err := ValidateBrowser(d.cookiesFromBrowser); err != nil {
+			return nil, err
+		}
+		return []string{"--cookies-from-browser", d.cookiesFromBrowser}, nil
+	}
+	return nil, nil
+}
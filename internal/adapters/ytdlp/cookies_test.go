@@ -0,0 +1,66 @@
+package ytdlp
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateBrowser(t *testing.T) {
+	tests := []struct {
+		spec string
+		ok   bool
+	}{
+		{"chrome", true},
+		{"Firefox", true},
+		{"firefox:default-release", true},
+		{"chromium+gnomekeyring:Profile 1", true},
+		{"safari::container", true},
+		{"netscape", false},
+		{"", false},
+		{":chrome", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateBrowser(tt.spec); (err == nil) != tt.ok {
+			t.Errorf("ValidateBrowser(%q) = %v, want ok %v", tt.spec, err, tt.ok)
+		}
+	}
+}
+
+func TestCookieArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string // nil if the call should fail without running yt-dlp
+	}{
+		{"none", nil, []string{"yt-dlp", "-J"}},
+		{"file", []Option{WithCookiesFile("cookies.txt")}, []string{"yt-dlp", "--cookies", "cookies.txt", "-J"}},
+		{"browser", []Option{WithCookiesFromBrowser("firefox:default")}, []string{"yt-dlp", "--cookies-from-browser", "firefox:default", "-J"}},
+		{"both", []Option{WithCookiesFile("cookies.txt"), WithCookiesFromBrowser("chrome")}, nil},
+		{"unsupported browser", []Option{WithCookiesFromBrowser("netscape")}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: []fakeRunResult{{stdout: formatsDump}}}
+			_, err := newFakeDownloader(runner, tt.opts...).ListFormats(context.Background(), "https://youtu.be/x")
+			if tt.want == nil {
+				if err == nil || len(runner.calls) != 0 {
+					t.Errorf("ListFormats = %v after %d runs, want an error without running yt-dlp", err, len(runner.calls))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListFormats: %v", err)
+			}
+			if got := runner.calls[0][:len(tt.want)]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("args start %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	d := newFakeDownloader(&fakeRunner{}, WithCookiesFile("cookies.txt"), WithCookiesFromBrowser("chrome"))
+	if _, err := d.ResolveVideoURL(context.Background(), "https://youtu.be/x"); !errors.Is(err, errConflictingCookies) {
+		t.Errorf("ResolveVideoURL with both cookie sources err = %v, want errConflictingCookies", err)
+	}
+}
@@ -3,58 +3,506 @@ package ytdlp
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"time"
+
+	"scrapeanddown/internal/core/ports"
+	"scrapeanddown/internal/retry"
 )
 
+// defaultFormat selects the best single-file (video+audio) format.
+const defaultFormat = "b"
+
+// defaultAttempts is how many times a failing yt-dlp invocation is tried.
+const defaultAttempts = 3
+
 // YtDlpDownloader uses the local yt-dlp binary to fetch video URLs.
 type YtDlpDownloader struct {
 	binaryPath string
+	format     string // Selector for the default download
+	maxHeight  int    // 0 = no cap
+	maxFPS     int    // 0 = no cap
+	attempts   int
+	backoff    time.Duration
+	runner     commandRunner
+
+	cookiesFile        string
+	cookiesFromBrowser string
+
+	debugf func(format string, args ...interface{})
+}
+
+// commandRunner executes external commands. The real implementation shells
+// out; tests substitute a fake to check arguments and simulate output.
+type commandRunner interface {
+	Run(ctx context.Context, name string, args ...string) (stdout, stderr []byte, err error)
 }
 
-// NewYtDlpDownloader creates a new downloader.
-func NewYtDlpDownloader() *YtDlpDownloader {
-	// Check if yt-dlp.exe exists in current directory
-	if _, err := os.Stat("yt-dlp.exe"); err == nil {
-		return &YtDlpDownloader{binaryPath: ".\\yt-dlp.exe"}
+// Option configures a YtDlpDownloader.
+type Option func(*YtDlpDownloader)
+
+// WithRetries sets how many times a retryable yt-dlp failure is retried
+// (0 disables retries).
+func WithRetries(retries int) Option {
+	return func(d *YtDlpDownloader) {
+		d.attempts = retries + 1
 	}
-	return &YtDlpDownloader{
-		binaryPath: "yt-dlp", // Assumes yt-dlp is in PATH
+}
+
+// WithDebugLog logs every yt-dlp invocation and failure through logf.
+func WithDebugLog(logf func(format string, args ...interface{})) Option {
+	return func(d *YtDlpDownloader) {
+		d.debugf = logf
 	}
 }
 
-// GetVideoURL fetches the direct download link using yt-dlp --get-url.
-func (d *YtDlpDownloader) GetVideoURL(ctx context.Context, videoURL string) (string, error) {
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
-	defer cancel()
+// Bounds for WithMaxHeight, from 144p to 8K.
+const (
+	MinMaxHeight = 144
+	MaxMaxHeight = 4320
+)
 
-	// -f best: Select best quality
-	// --get-url: Only output the URL
-	// --no-warnings: Suppress warnings
-	cmd := exec.CommandContext(ctx, d.binaryPath, "-f", "b", "--get-url", "--no-warnings", videoURL)
+// WithMaxHeight makes the default download the best video and audio
+// streams no taller than maxHeight, falling back to the best single file
+// (see FormatForMaxHeight). Merged streams have no single URL, so
+// ResolveVideoFormat marks them ResolverOnly and DownloadFormat fetches
+// them. Check maxHeight with ValidateMaxHeight.
+func WithMaxHeight(maxHeight int) Option {
+	return func(d *YtDlpDownloader) {
+		d.maxHeight = maxHeight
+		d.format = cappedFormat(d.maxHeight, d.maxFPS)
+	}
+}
+
+// WithMaxFPS makes the default download the best video and audio streams
+// at no more than maxFPS frames per second, combined with WithMaxHeight if
+// set. Formats that don't report a frame rate qualify.
+func WithMaxFPS(maxFPS int) Option {
+	return func(d *YtDlpDownloader) {
+		d.maxFPS = maxFPS
+		d.format = cappedFormat(d.maxHeight, d.maxFPS)
+	}
+}
+
+// FormatForMaxHeight returns the format selector for WithMaxHeight, e.g.
+// "bestvideo[height<=1080]+bestaudio/best[height<=1080]".
+func FormatForMaxHeight(maxHeight int) string {
+	return cappedFormat(maxHeight, 0)
+}
+
+// cappedFormat returns the default selector under the caps (0 = no cap): the
+// best merged streams, else the best single file.
+func cappedFormat(maxHeight, maxFPS int) string {
+	if maxHeight <= 0 && maxFPS <= 0 {
+		return defaultFormat
+	}
+	return formatWithCaps("bestvideo", maxHeight, maxFPS) + "+bestaudio/" + formatWithCaps("best", maxHeight, maxFPS)
+}
+
+// formatWithCaps adds height and fps filters (0 = no cap) to a selector.
+func formatWithCaps(selector string, maxHeight, maxFPS int) string {
+	if maxHeight > 0 {
+		selector += fmt.Sprintf("[height<=%d]", maxHeight)
+	}
+	if maxFPS > 0 {
+		selector += fmt.Sprintf("[fps<=?%d]", maxFPS)
+	}
+	return selector
+}
+
+// ValidateMaxHeight checks that maxHeight is a plausible video height.
+func ValidateMaxHeight(maxHeight int) error {
+	if maxHeight < MinMaxHeight || maxHeight > MaxMaxHeight {
+		return fmt.Errorf("max height %d out of range %d-%d", maxHeight, MinMaxHeight, MaxMaxHeight)
+	}
+	return nil
+}
+
+// NewYtDlpDownloader creates a new downloader running the yt-dlp binary in
+// the working directory if there is one, or else the one on PATH.
+func NewYtDlpDownloader(opts ...Option) *YtDlpDownloader {
+	return NewYtDlpDownloaderWithPath(defaultBinaryPath(), opts...)
+}
+
+// NewYtDlpDownloaderWithPath creates a new downloader running the yt-dlp
+// binary at path. A bare name is looked up on PATH.
+func NewYtDlpDownloaderWithPath(path string, opts ...Option) *YtDlpDownloader {
+	d := &YtDlpDownloader{
+		binaryPath: path,
+		format:     defaultFormat,
+		attempts:   defaultAttempts,
+		backoff:    2 * time.Second,
+		runner:     execRunner{},
+		debugf:     func(string, ...interface{}) {},
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// defaultBinaryPath returns the yt-dlp binary NewYtDlpDownloader runs: one
+// in the working directory wins over PATH.
+func defaultBinaryPath() string {
+	name := "yt-dlp"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if info, err := os.Stat(name); err == nil && !info.IsDir() {
+		return "." + string(filepath.Separator) + name
+	}
+	return "yt-dlp"
+}
+
+// CheckBinary reports whether the yt-dlp binary exists, failing with a
+// *BinaryNotFoundError if it doesn't, so a missing install can be reported
+// before the first job needs it.
+func (d *YtDlpDownloader) CheckBinary() error {
+	if _, err := exec.LookPath(d.binaryPath); err != nil && isNotFound(err, d.binaryPath) {
+		return &BinaryNotFoundError{Path: d.binaryPath, Err: err}
+	}
+	return nil
+}
+
+// ResolveVideoURL fetches the direct download link using yt-dlp --get-url.
+// One URL must carry both video and audio, so under WithMaxHeight or
+// WithMaxFPS it resolves the best single file within the caps.
+func (d *YtDlpDownloader) ResolveVideoURL(ctx context.Context, videoURL string) (string, error) {
+	return d.GetVideoURLForFormat(ctx, videoURL, formatWithCaps(defaultFormat, d.maxHeight, d.maxFPS))
+}
+
+// ResolveVideoURLForHeight fetches the direct download link of the
+// single-file format of exactly the given height.
+func (d *YtDlpDownloader) ResolveVideoURLForHeight(ctx context.Context, videoURL string, height int) (string, error) {
+	return d.GetVideoURLForFormat(ctx, videoURL, fmt.Sprintf("b[height=%d]", height))
+}
+
+// GetVideoURLForFormat fetches the direct download link for the given yt-dlp
+// format selector (e.g. "b[height=720]"). For merged selections, which
+// resolve to several URLs, it returns the first (video) one.
+func (d *YtDlpDownloader) GetVideoURLForFormat(ctx context.Context, videoURL, format string) (string, error) {
+	urls, err := d.GetVideoURLsForFormat(ctx, videoURL, format)
+	if err != nil {
+		return "", err
+	}
+	return urls[0], nil
+}
+
+// GetVideoURLsForFormat fetches the direct download links for the given
+// yt-dlp format selector: one per part, in order, so "bv+ba" yields the
+// video URL, then the audio URL. Streams that are live or upcoming fail
+// with ports.ErrLiveStream, and recordings that need liveFromStart with
+// ports.ErrNoDirectURL: in full they are only a DASH segment generator, so
+// they must go through ResolveVideoFormat and DownloadFormat.
+func (d *YtDlpDownloader) GetVideoURLsForFormat(ctx context.Context, videoURL, format string) ([]string, error) {
+	out, err := d.run(ctx, d.getURLArgs(videoURL, format)...)
+	if err != nil {
+		return nil, err
+	}
+	status, urls := parseURLOutput(out)
+	if err := liveStreamError(status); err != nil {
+		return nil, err
+	}
+	if liveFromStart(status) {
+		return nil, fmt.Errorf("%w: %s is a %s recording, which only yt-dlp can download in full", ports.ErrNoDirectURL, videoURL, status)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("yt-dlp returned empty URL")
+	}
+	return urls, nil
+}
+
+// parseURLOutput parses the output of getURLArgs: the live status, then one
+// URL per line.
+func parseURLOutput(out string) (ports.LiveStatus, []string) {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return ports.LiveStatusUnknown, nil
+	}
+	return ports.ParseLiveStatus(lines[0], false, false), lines[1:]
+}
+
+// ResolveSeparateStreams fetches the direct links of the best video-only
+// and audio-only streams, preferring MP4 video and M4A audio. The video
+// stream respects WithMaxHeight and WithMaxFPS.
+func (d *YtDlpDownloader) ResolveSeparateStreams(ctx context.Context, videoURL string) (string, string, error) {
+	urls, err := d.GetVideoURLsForFormat(ctx, videoURL, separateStreamsFormat(d.maxHeight, d.maxFPS))
+	if err != nil {
+		return "", "", err
+	}
+	if len(urls) != 2 {
+		return "", "", fmt.Errorf("yt-dlp returned %d URLs for separate streams, expected video and audio", len(urls))
+	}
+	return urls[0], urls[1], nil
+}
+
+// separateStreamsFormat returns the selector for ResolveSeparateStreams.
+func separateStreamsFormat(maxHeight, maxFPS int) string {
+	video := formatWithCaps("bv", maxHeight, maxFPS)
+	return fmt.Sprintf("%s[ext=mp4]+ba[ext=m4a]/%s+ba", video, video)
+}
+
+// ResolveVideoURLWithHeaders resolves the default format's direct URL from
+// yt-dlp's JSON dump, along with the HTTP headers yt-dlp would send when
+// downloading it (User-Agent, Referer, cookies, ...).
+func (d *YtDlpDownloader) ResolveVideoURLWithHeaders(ctx context.Context, videoURL string) (string, map[string]string, error) {
+	format, err := d.ResolveVideoFormat(ctx, videoURL)
+	if err != nil {
+		return "", nil, err
+	}
+	return format.URL, format.Headers, nil
+}
+
+// ResolveVideoFormat is ResolveVideoURLWithHeaders that also reports the
+// format's container extension (e.g. "webm") and the video's live status.
+// A merged selection is ResolverOnly, for DownloadFormat.
+// Streams that are live or upcoming fail with ports.ErrLiveStream; see
+// liveFromStart for ended ones.
+func (d *YtDlpDownloader) ResolveVideoFormat(ctx context.Context, videoURL string) (*ports.ResolvedFormat, error) {
+	args := []string{"-f", d.format, "-J", "--no-playlist", "--no-warnings", videoURL}
+	out, err := d.run(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	format, err := parseResolvedFormat([]byte(out))
+	if err != nil {
+		return nil, err
+	}
+	if err := liveStreamError(format.LiveStatus); err != nil {
+		return nil, err
+	}
+	if liveFromStart(format.LiveStatus) {
+		// With --live-from-start the recording's URL is a DASH segment
+		// generator, not a file; DownloadFormat fetches it in full
+		d.debugf("yt-dlp: %s is a %s recording, leaving its download to yt-dlp", videoURL, format.LiveStatus)
+		format.ResolverOnly = true
+	}
+	return format, nil
+}
+
+// dumpFormat is the subset of a yt-dlp -J format entry we use.
+type dumpFormat struct {
+	URL         string            `json:"url"`
+	Ext         string            `json:"ext"`
+	HTTPHeaders map[string]string `json:"http_headers"`
+}
+
+// parseResolvedFormat extracts the selected format's URL, extension and
+// headers from a -J dump. Merged selections list their parts in
+// requested_formats; the URL and headers are the first (video) part's, the
+// extension the merged file's, and the format is ResolverOnly.
+func parseResolvedFormat(dump []byte) (*ports.ResolvedFormat, error) {
+	var info struct {
+		dumpFormat
+		RequestedFormats []dumpFormat `json:"requested_formats"`
+		LiveStatus       string       `json:"live_status"`
+		IsLive           bool         `json:"is_live"`
+		WasLive          bool         `json:"was_live"`
+	}
+	if err := json.Unmarshal(dump, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp output: %w", err)
+	}
+
+	selected := info.dumpFormat
+	merged := false
+	if selected.URL == "" && len(info.RequestedFormats) > 0 {
+		selected = info.RequestedFormats[0]
+		merged = len(info.RequestedFormats) > 1
+		if info.Ext != "" {
+			selected.Ext = info.Ext
+		}
+	}
+	if selected.URL == "" {
+		return nil, fmt.Errorf("yt-dlp returned empty URL")
+	}
+	return &ports.ResolvedFormat{
+		URL:          selected.URL,
+		Headers:      selected.HTTPHeaders,
+		Ext:          selected.Ext,
+		LiveStatus:   ports.ParseLiveStatus(info.LiveStatus, info.IsLive, info.WasLive),
+		ResolverOnly: merged,
+	}, nil
+}
+
+// DownloadFormat has yt-dlp download the video with the default format into
+// dir, merging separate streams (which needs ffmpeg), and returns the path
+// of the file it wrote. Only ctx bounds how long it may take.
+func (d *YtDlpDownloader) DownloadFormat(ctx context.Context, videoURL string, format *ports.ResolvedFormat, dir string) (string, error) {
+	// -o: Name the file after the video's ID, in dir
+	// --no-simulate --print after_move:filepath: Download, then print the final path
+	args := []string{"-f", d.format, "--no-playlist", "--no-warnings", "--no-progress",
+		"-o", filepath.Join(dir, "%(id)s.%(ext)s"), "--no-simulate", "--print", "after_move:filepath", videoURL}
+	if liveFromStart(format.LiveStatus) {
+		args = append([]string{"--live-from-start"}, args...)
+	}
+	out, err := d.runFor(ctx, 0, args...)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	path := strings.TrimSpace(lines[len(lines)-1])
+	if path == "" {
+		return "", fmt.Errorf("yt-dlp didn't report the downloaded file")
+	}
+	return path, nil
+}
+
+// Probe asks yt-dlp for the video's duration, (approximate) file size of
+// the default format and live status, without downloading anything.
+func (d *YtDlpDownloader) Probe(ctx context.Context, videoURL string) (*ports.VideoInfo, error) {
+	out, err := d.run(ctx, "-f", d.format, "--no-playlist", "--no-warnings",
+		"--print", "%(duration)s %(filesize,filesize_approx)s %(live_status)s", videoURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseProbeOutput(out), nil
+}
+
+// CheckAvailable asks yt-dlp to extract the video without downloading
+// anything, failing with a ports.UnavailableError if the platform won't
+// serve it (removed, private, ...).
+func (d *YtDlpDownloader) CheckAvailable(ctx context.Context, videoURL string) error {
+	_, err := d.run(ctx, "--simulate", "--skip-download", "--no-playlist", "--no-warnings", "--quiet", videoURL)
+	return err
+}
+
+// parseProbeOutput parses "<duration> <filesize> <live status>" where any
+// may be "NA".
+func parseProbeOutput(out string) *ports.VideoInfo {
+	info := &ports.VideoInfo{}
+	fields := strings.Fields(strings.TrimSpace(out))
+	if len(fields) > 0 {
+		info.DurationSeconds, _ = strconv.ParseFloat(fields[0], 64)
+	}
+	if len(fields) > 1 {
+		if size, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			info.EstimatedBytes = int64(size)
+		}
+	}
+	if len(fields) > 2 {
+		info.LiveStatus = ports.ParseLiveStatus(fields[2], false, false)
+	}
+	return info
+}
+
+// invocationTimeout bounds a yt-dlp invocation that downloads nothing.
+const invocationTimeout = 2 * time.Minute
+
+// run executes yt-dlp and returns its stdout. Failures that look transient
+// (player token rotation, extraction glitches, upstream 5xx) are retried
+// with exponential backoff; permanent ones fail immediately.
+func (d *YtDlpDownloader) run(ctx context.Context, args ...string) (string, error) {
+	return d.runFor(ctx, invocationTimeout, args...)
+}
+
+// runFor is run with each invocation bounded by timeout (0 = only by ctx).
+func (d *YtDlpDownloader) runFor(ctx context.Context, timeout time.Duration, args ...string) (string, error) {
+	cookies, err := d.cookieArgs()
+	if err != nil {
+		return "", err
+	}
+	args = append(cookies, args...)
+
+	policy := retry.Policy{
+		MaxAttempts: d.attempts,
+		Initial:     d.backoff,
+		Jitter:      retry.DefaultPolicy.Jitter,
+		Retryable:   isRetryable,
+		OnRetry: func(attempt int, err error, wait time.Duration) {
+			d.debugf("yt-dlp: attempt %d failed, retrying in %s", attempt, wait)
+		},
+	}
+	var out string
+	err = retry.Do(ctx, policy, func() error {
+		var err error
+		out, err = d.runOnce(ctx, timeout, args...)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// runOnce executes a single yt-dlp invocation, with a timeout unless it is 0.
+func (d *YtDlpDownloader) runOnce(ctx context.Context, timeout time.Duration, args ...string) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	d.debugf("yt-dlp: running %s %s", d.binaryPath, strings.Join(args, " "))
+	stdout, stderr, err := d.runner.Run(ctx, d.binaryPath, args...)
+	if err != nil && isNotFound(err, d.binaryPath) {
+		return "", &BinaryNotFoundError{Path: d.binaryPath, Err: err}
+	}
+	if err != nil {
+		d.debugf("yt-dlp: %v: %s", err, strings.TrimSpace(string(stderr)))
+		return "", classifyFailure(err, string(stderr))
+	}
+	return string(stdout), nil
+}
+
+// processWaitDelay bounds how long Run waits for output after yt-dlp has
+// been killed.
+const processWaitDelay = 5 * time.Second
+
+// execRunner runs commands via os/exec. Cancelling the context kills the
+// command's whole process tree.
+type execRunner struct{}
+
+// Run executes the command and captures its output.
+func (execRunner) Run(ctx context.Context, name string, args ...string) ([]byte, []byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	killProcessTree(cmd)
+	// Don't let a straggler holding stdout/stderr open block Run after a cancel
+	cmd.WaitDelay = processWaitDelay
 
 	var out bytes.Buffer
 	var stderr bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("yt-dlp failed: %w, stderr: %s", err, stderr.String())
-	}
+	err := cmd.Run()
+	return out.Bytes(), stderr.Bytes(), err
+}
 
-	urlStr := strings.TrimSpace(out.String())
-	if urlStr == "" {
-		return "", fmt.Errorf("yt-dlp returned empty URL")
-	}
+// getURLArgs builds the yt-dlp arguments for resolving a single video's
+// URL, printing its live status first.
+func (d *YtDlpDownloader) getURLArgs(videoURL, format string) []string {
+	// -f: Format selector, "b" is the best single-file format
+	// --print: Only output the live status and the URLs (implies --simulate, nothing is downloaded)
+	// --no-playlist: A watch URL with a list= param must resolve just the video
+	// --no-warnings: Suppress warnings
+	return []string{"-f", format, "--print", "live_status", "--print", "urls", "--no-playlist", "--no-warnings", videoURL}
+}
 
-	// yt-dlp might return multiple URLs (video + audio), just take the first one
-	urls := strings.Split(urlStr, "\n")
-	if len(urls) > 0 {
-		return urls[0], nil
+// liveStreamError returns ports.ErrLiveStream for a stream with no complete
+// recording yet, nil for anything else.
+func liveStreamError(status ports.LiveStatus) error {
+	if status.Ongoing() {
+		return fmt.Errorf("%w (%s)", ports.ErrLiveStream, status)
 	}
+	return nil
+}
 
-	return urlStr, nil
+// liveFromStart reports whether a video must be downloaded with
+// --live-from-start: until YouTube finishes processing a stream's recording
+// (post_live), its default formats only cover the last hours of it. A
+// processed recording (was_live) is a regular video.
+func liveFromStart(status ports.LiveStatus) bool {
+	return status == ports.PostLive
 }
@@ -0,0 +1,427 @@
+package ytdlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// fakeRunResult is one scripted yt-dlp invocation.
+type fakeRunResult struct {
+	stdout, stderr string
+	err            error
+}
+
+// fakeRunner records every invocation and replays scripted results in order,
+// repeating the last one when it runs out.
+type fakeRunner struct {
+	results []fakeRunResult
+	calls   [][]string
+}
+
+func (f *fakeRunner) Run(ctx context.Context, name string, args ...string) ([]byte, []byte, error) {
+	f.calls = append(f.calls, append([]string{name}, args...))
+	r := f.results[min(len(f.calls), len(f.results))-1]
+	return []byte(r.stdout), []byte(r.stderr), r.err
+}
+
+func newFakeDownloader(runner *fakeRunner, opts ...Option) *YtDlpDownloader {
+	d := NewYtDlpDownloaderWithPath("yt-dlp", opts...)
+	d.runner = runner
+	d.backoff = 0
+	return d
+}
+
+var errExit = errors.New("exit status 1")
+
+func TestResolveVideoURLArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{
+			name: "default format",
+			want: []string{"yt-dlp", "-f", "b", "--print", "live_status", "--print", "urls", "--no-playlist", "--no-warnings", "https://youtu.be/x"},
+		},
+		{
+			name: "max height and fps",
+			opts: []Option{WithMaxHeight(720), WithMaxFPS(30)},
+			want: []string{"yt-dlp", "-f", "b[height<=720][fps<=?30]", "--print", "live_status", "--print", "urls", "--no-playlist", "--no-warnings", "https://youtu.be/x"},
+		},
+		{
+			name: "cookies file",
+			opts: []Option{WithCookiesFile("cookies.txt")},
+			want: []string{"yt-dlp", "--cookies", "cookies.txt", "-f", "b", "--print", "live_status", "--print", "urls", "--no-playlist", "--no-warnings", "https://youtu.be/x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: []fakeRunResult{{stdout: "not_live\nhttps://cdn.example.com/v.mp4\n"}}}
+			d := newFakeDownloader(runner, tt.opts...)
+			got, err := d.ResolveVideoURL(context.Background(), "https://youtu.be/x")
+			if err != nil {
+				t.Fatalf("ResolveVideoURL: %v", err)
+			}
+			if got != "https://cdn.example.com/v.mp4" {
+				t.Errorf("URL = %q", got)
+			}
+			if !reflect.DeepEqual(runner.calls, [][]string{tt.want}) {
+				t.Errorf("args = %q\nwant %q", runner.calls, [][]string{tt.want})
+			}
+		})
+	}
+}
+
+func TestResolveVideoURLForHeight(t *testing.T) {
+	runner := &fakeRunner{results: []fakeRunResult{{stdout: "not_live\nhttps://cdn.example.com/360.mp4\n"}}}
+	d := newFakeDownloader(runner, WithMaxHeight(720))
+	got, err := d.ResolveVideoURLForHeight(context.Background(), "https://youtu.be/x", 360)
+	if err != nil {
+		t.Fatalf("ResolveVideoURLForHeight: %v", err)
+	}
+	if got != "https://cdn.example.com/360.mp4" {
+		t.Errorf("URL = %q", got)
+	}
+	if format := runner.calls[0][2]; format != "b[height=360]" {
+		t.Errorf("format = %q, want b[height=360]", format)
+	}
+}
+
+func TestResolveSeparateStreams(t *testing.T) {
+	runner := &fakeRunner{results: []fakeRunResult{{stdout: "not_live\nhttps://v\nhttps://a\n"}}}
+	d := newFakeDownloader(runner)
+	video, audio, err := d.ResolveSeparateStreams(context.Background(), "https://youtu.be/x")
+	if err != nil {
+		t.Fatalf("ResolveSeparateStreams: %v", err)
+	}
+	if video != "https://v" || audio != "https://a" {
+		t.Errorf("streams = %q, %q", video, audio)
+	}
+	if got := runner.calls[0][2]; got != "bv[ext=mp4]+ba[ext=m4a]/bv+ba" {
+		t.Errorf("format = %q", got)
+	}
+}
+
+func TestResolveLiveStreams(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "live", status: "is_live", wantErr: ports.ErrLiveStream, wantCalls: 1},
+		{name: "upcoming", status: "is_upcoming", wantErr: ports.ErrLiveStream, wantCalls: 1},
+		{name: "recording", status: "was_live", wantCalls: 1},
+		{name: "post live", status: "post_live", wantErr: ports.ErrNoDirectURL, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: []fakeRunResult{{stdout: tt.status + "\nhttps://cdn.example.com/v.mp4\n"}}}
+			d := newFakeDownloader(runner)
+			_, err := d.ResolveVideoURL(context.Background(), "https://youtu.be/x")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if len(runner.calls) != tt.wantCalls {
+				t.Errorf("ran yt-dlp %d times, want %d", len(runner.calls), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestResolveVideoFormatParsesDump(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+		want ports.ResolvedFormat
+	}{
+		{
+			name: "single file",
+			dump: `{"url":"https://v","ext":"webm","http_headers":{"User-Agent":"ua"},"live_status":"not_live"}`,
+			want: ports.ResolvedFormat{URL: "https://v", Ext: "webm", Headers: map[string]string{"User-Agent": "ua"}, LiveStatus: ports.NotLive},
+		},
+		{
+			name: "merged selection is resolver only",
+			dump: `{"ext":"mkv","requested_formats":[{"url":"https://v","ext":"webm"},{"url":"https://a","ext":"m4a"}],"was_live":true}`,
+			want: ports.ResolvedFormat{URL: "https://v", Ext: "mkv", LiveStatus: ports.WasLive, ResolverOnly: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: []fakeRunResult{{stdout: tt.dump}}}
+			got, err := newFakeDownloader(runner).ResolveVideoFormat(context.Background(), "https://youtu.be/x")
+			if err != nil {
+				t.Fatalf("ResolveVideoFormat: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("format = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+// A post_live recording in full is only a DASH segment generator, so it is
+// left to DownloadFormat instead of being resolved again from the start.
+func TestResolveVideoFormatPostLiveIsResolverOnly(t *testing.T) {
+	runner := &fakeRunner{results: []fakeRunResult{{stdout: `{"url":"https://v","ext":"mp4","live_status":"post_live"}`}}}
+	got, err := newFakeDownloader(runner).ResolveVideoFormat(context.Background(), "https://youtu.be/x")
+	if err != nil {
+		t.Fatalf("ResolveVideoFormat: %v", err)
+	}
+	if !got.ResolverOnly || got.LiveStatus != ports.PostLive {
+		t.Errorf("format = %+v, want a resolver-only post_live format", *got)
+	}
+	if len(runner.calls) != 1 {
+		t.Errorf("ran yt-dlp %d times, want 1", len(runner.calls))
+	}
+}
+
+func TestResolveVideoFormatInvalidJSON(t *testing.T) {
+	runner := &fakeRunner{results: []fakeRunResult{{stdout: "not json"}}}
+	if _, err := newFakeDownloader(runner).ResolveVideoFormat(context.Background(), "https://youtu.be/x"); err == nil {
+		t.Error("ResolveVideoFormat succeeded on invalid output")
+	}
+}
+
+func TestProbeParsesOutput(t *testing.T) {
+	runner := &fakeRunner{results: []fakeRunResult{{stdout: "12.5 1048576 post_live\n"}}}
+	info, err := newFakeDownloader(runner).Probe(context.Background(), "https://youtu.be/x")
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	want := ports.VideoInfo{DurationSeconds: 12.5, EstimatedBytes: 1048576, LiveStatus: ports.PostLive}
+	if !reflect.DeepEqual(*info, want) {
+		t.Errorf("info = %+v, want %+v", *info, want)
+	}
+}
+
+func TestParseProbeOutput(t *testing.T) {
+	tests := []struct {
+		out  string
+		want ports.VideoInfo
+	}{
+		{"212 53687091\n", ports.VideoInfo{DurationSeconds: 212, EstimatedBytes: 53687091}},
+		{"212 NA\n", ports.VideoInfo{DurationSeconds: 212}},
+		{"NA NA\n", ports.VideoInfo{}},
+		{"10800.5 1.5e9 not_live\n", ports.VideoInfo{DurationSeconds: 10800.5, EstimatedBytes: 1500000000, LiveStatus: ports.NotLive}},
+		{"", ports.VideoInfo{}},
+	}
+	for _, tt := range tests {
+		if got := *parseProbeOutput(tt.out); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseProbeOutput(%q) = %+v, want %+v", tt.out, got, tt.want)
+		}
+	}
+}
+
+func TestRunStderrHandling(t *testing.T) {
+	tests := []struct {
+		name       string
+		stderr     string
+		wantReason ports.UnavailableReason
+		wantCalls  int
+	}{
+		{name: "private", stderr: "ERROR: [youtube] x: Private video. Sign in if you've been granted access", wantReason: ports.ReasonPrivate, wantCalls: 1},
+		{name: "removed", stderr: "ERROR: [youtube] x: Video unavailable. This video has been removed by the uploader", wantReason: ports.ReasonRemoved, wantCalls: 1},
+		{name: "age restricted", stderr: "ERROR: [youtube] x: Sign in to confirm your age", wantReason: ports.ReasonAgeRestricted, wantCalls: 1},
+		{name: "image-only pin", stderr: "ERROR: [Pinterest] 123: No video formats found!", wantReason: ports.ReasonRemoved, wantCalls: 1},
+		{name: "unsupported url", stderr: "ERROR: Unsupported URL: https://example.com", wantCalls: 1},
+		{name: "transient", stderr: "ERROR: [youtube] x: nsig extraction failed", wantCalls: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: []fakeRunResult{{stderr: tt.stderr, err: errExit}}}
+			_, err := newFakeDownloader(runner).ResolveVideoURL(context.Background(), "https://youtu.be/x")
+			var ytErr *Error
+			if !errors.As(err, &ytErr) {
+				t.Fatalf("err = %v, want a *Error", err)
+			}
+			if ytErr.Stderr != tt.stderr {
+				t.Errorf("Stderr = %q", ytErr.Stderr)
+			}
+			var unavailable *ports.UnavailableError
+			switch {
+			case tt.wantReason == "" && errors.As(err, &unavailable):
+				t.Errorf("err = %v, want no UnavailableError", err)
+			case tt.wantReason != "" && (!errors.As(err, &unavailable) || unavailable.Reason != tt.wantReason):
+				t.Errorf("err = %v, want reason %s", err, tt.wantReason)
+			}
+			if len(runner.calls) != tt.wantCalls {
+				t.Errorf("ran yt-dlp %d times, want %d", len(runner.calls), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRunRetriesUntilSuccess(t *testing.T) {
+	runner := &fakeRunner{results: []fakeRunResult{
+		{stderr: "ERROR: HTTP Error 503", err: errExit},
+		{stdout: "not_live\nhttps://v\n"},
+	}}
+	got, err := newFakeDownloader(runner).ResolveVideoURL(context.Background(), "https://youtu.be/x")
+	if err != nil || got != "https://v" {
+		t.Fatalf("ResolveVideoURL = %q, %v", got, err)
+	}
+	if len(runner.calls) != 2 {
+		t.Errorf("ran yt-dlp %d times, want 2", len(runner.calls))
+	}
+}
+
+// WithRetries bounds the fresh invocations after a transient failure.
+func TestWithRetries(t *testing.T) {
+	transient := fakeRunResult{stderr: "ERROR: HTTP Error 503", err: errExit}
+	success := fakeRunResult{stdout: "not_live\nhttps://v\n"}
+	tests := []struct {
+		name      string
+		retries   int
+		results   []fakeRunResult
+		wantCalls int
+		wantOK    bool
+	}{
+		{"disabled", 0, []fakeRunResult{transient, success}, 1, false},
+		{"one retry succeeds", 1, []fakeRunResult{transient, success}, 2, true},
+		{"retries run out", 2, []fakeRunResult{transient, transient, transient, success}, 3, false},
+		{"succeeds on the last", 3, []fakeRunResult{transient, transient, transient, success}, 4, true},
+		{"no retry after success", 3, []fakeRunResult{success}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: tt.results}
+			var retried []string
+			logf := func(format string, args ...interface{}) { retried = append(retried, fmt.Sprintf(format, args...)) }
+			_, err := newFakeDownloader(runner, WithRetries(tt.retries), WithDebugLog(logf)).ResolveVideoURL(context.Background(), "https://youtu.be/x")
+			if (err == nil) != tt.wantOK {
+				t.Errorf("ResolveVideoURL err = %v, want success %v", err, tt.wantOK)
+			}
+			if len(runner.calls) != tt.wantCalls {
+				t.Fatalf("ran yt-dlp %d times, want %d", len(runner.calls), tt.wantCalls)
+			}
+			for _, call := range runner.calls[1:] {
+				if !slices.Equal(call, runner.calls[0]) {
+					t.Errorf("retry ran %q, want the first invocation %q", call, runner.calls[0])
+				}
+			}
+			if n := countContaining(retried, "retrying in"); n != tt.wantCalls-1 {
+				t.Errorf("logged %d retries, want %d: %q", n, tt.wantCalls-1, retried)
+			}
+		})
+	}
+}
+
+// countContaining counts the lines containing substr.
+func countContaining(lines []string, substr string) int {
+	n := 0
+	for _, line := range lines {
+		if strings.Contains(line, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRunBinaryNotFound(t *testing.T) {
+	runner := &fakeRunner{results: []fakeRunResult{{err: &exec.Error{Name: "yt-dlp", Err: exec.ErrNotFound}}}}
+	_, err := newFakeDownloader(runner).ResolveVideoURL(context.Background(), "https://youtu.be/x")
+	if !errors.Is(err, ports.ErrToolNotFound) {
+		t.Errorf("err = %v, want ports.ErrToolNotFound", err)
+	}
+	if len(runner.calls) != 1 {
+		t.Errorf("ran yt-dlp %d times, want 1", len(runner.calls))
+	}
+}
+
+func TestFormatForMaxHeight(t *testing.T) {
+	if got, want := FormatForMaxHeight(1080), "bestvideo[height<=1080]+bestaudio/best[height<=1080]"; got != want {
+		t.Errorf("FormatForMaxHeight(1080) = %q, want %q", got, want)
+	}
+	if got := FormatForMaxHeight(0); got != defaultFormat {
+		t.Errorf("FormatForMaxHeight(0) = %q, want %q", got, defaultFormat)
+	}
+	d := NewYtDlpDownloaderWithPath("yt-dlp", WithMaxHeight(720), WithMaxFPS(30))
+	if want := "bestvideo[height<=720][fps<=?30]+bestaudio/best[height<=720][fps<=?30]"; d.format != want {
+		t.Errorf("format = %q, want %q", d.format, want)
+	}
+}
+
+func TestDownloadFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    ports.LiveStatus
+		fromStart bool
+	}{
+		{name: "video", status: ports.NotLive},
+		{name: "post live recording", status: ports.PostLive, fromStart: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: []fakeRunResult{{stdout: "/tmp/job/x.mkv\n"}}}
+			d := newFakeDownloader(runner, WithMaxHeight(1080))
+			path, err := d.DownloadFormat(context.Background(), "https://youtu.be/x", &ports.ResolvedFormat{LiveStatus: tt.status}, "/tmp/job")
+			if err != nil {
+				t.Fatalf("DownloadFormat: %v", err)
+			}
+			if path != "/tmp/job/x.mkv" {
+				t.Errorf("path = %q", path)
+			}
+			want := []string{"yt-dlp", "-f", FormatForMaxHeight(1080), "--no-playlist", "--no-warnings", "--no-progress",
+				"-o", "/tmp/job/%(id)s.%(ext)s", "--no-simulate", "--print", "after_move:filepath", "https://youtu.be/x"}
+			if tt.fromStart {
+				want = append([]string{"yt-dlp", "--live-from-start"}, want[1:]...)
+			}
+			if !reflect.DeepEqual(runner.calls[0], want) {
+				t.Errorf("args = %q\nwant %q", runner.calls[0], want)
+			}
+		})
+	}
+}
+
+// A watch URL inside a playlist resolves just its video, whichever call
+// resolves it.
+func TestSingleVideoCallsSkipPlaylists(t *testing.T) {
+	const inPlaylist = "https://www.youtube.com/watch?v=x&list=PL123"
+	calls := map[string]func(d *YtDlpDownloader) error{
+		"ResolveVideoURL": func(d *YtDlpDownloader) error {
+			_, err := d.ResolveVideoURL(context.Background(), inPlaylist)
+			return err
+		},
+		"GetVideoURLsForFormat": func(d *YtDlpDownloader) error {
+			_, err := d.GetVideoURLsForFormat(context.Background(), inPlaylist, "b")
+			return err
+		},
+		"ResolveSeparateStreams": func(d *YtDlpDownloader) error {
+			_, _, err := d.ResolveSeparateStreams(context.Background(), inPlaylist)
+			return err
+		},
+		"ResolveVideoFormat": func(d *YtDlpDownloader) error {
+			_, err := d.ResolveVideoFormat(context.Background(), inPlaylist)
+			return err
+		},
+		"Probe": func(d *YtDlpDownloader) error {
+			_, err := d.Probe(context.Background(), inPlaylist)
+			return err
+		},
+		"ListFormats": func(d *YtDlpDownloader) error {
+			_, err := d.ListFormats(context.Background(), inPlaylist)
+			return err
+		},
+	}
+	for name, call := range calls {
+		runner := &fakeRunner{results: []fakeRunResult{{stdout: "https://cdn/v.mp4\n"}}}
+		call(newFakeDownloader(runner)) // Only the arguments matter
+		if len(runner.calls) == 0 {
+			t.Errorf("%s didn't run yt-dlp", name)
+		}
+		for _, args := range runner.calls {
+			if !slices.Contains(args, "--no-playlist") {
+				t.Errorf("%s ran %q without --no-playlist", name, args)
+			}
+		}
+	}
+}
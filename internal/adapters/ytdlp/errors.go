@@ -0,0 +1,133 @@
+package ytdlp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// Stderr fragments saying why the platform won't serve the video, checked in
+// order: the specific reasons come first because YouTube prefixes several of
+// them with the generic "Video unavailable".
+var unavailableMarkers = []struct {
+	reason  ports.UnavailableReason
+	markers []string
+}{
+	{ports.ReasonPrivate, []string{"private video", "this video is private"}},
+	{ports.ReasonMembersOnly, []string{"members-only", "join this channel"}},
+	{ports.ReasonAgeRestricted, []string{"sign in to confirm your age", "age-restricted", "inappropriate for some users"}},
+	{ports.ReasonGeoBlocked, []string{"available in your country", "geo restriction", "blocked it in your country"}},
+	{ports.ReasonRemoved, []string{
+		"video unavailable",
+		"has been removed",
+		"account associated with this video has been terminated",
+		"no video formats found", // e.g. image-only Pinterest pins
+	}},
+}
+
+// Stderr fragments of other failures that won't go away on retry.
+var fatalMarkers = []string{
+	"unsupported url",
+}
+
+// Stderr fragments of known transient failures.
+var retryableMarkers = []string{
+	"nsig extraction failed",
+	"unable to extract",
+	"http error 5",
+	"timed out",
+	"connection reset",
+	"temporary failure",
+	"remote end closed connection",
+}
+
+// Error is a failed yt-dlp invocation.
+type Error struct {
+	Err       error
+	Stderr    string
+	Retryable bool
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("yt-dlp failed: %v, stderr: %s", e.Err, e.Stderr)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// BinaryNotFoundError is returned when the yt-dlp binary can't be run
+// because it doesn't exist. It matches ports.ErrToolNotFound and is never
+// retried. Callers word the remedy (e.g. which flag sets the path).
+type BinaryNotFoundError struct {
+	Path string // The binary that was looked for
+	Err  error
+}
+
+func (e *BinaryNotFoundError) Error() string {
+	return fmt.Sprintf("yt-dlp not found (looked for %s)", e.Path)
+}
+
+func (e *BinaryNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BinaryNotFoundError) Is(target error) bool {
+	return target == ports.ErrToolNotFound
+}
+
+// isNotFound reports whether running binary failed because it doesn't
+// exist: not on PATH, or no file at its path. Other missing files (e.g. a
+// script's interpreter or the working directory) don't count.
+func isNotFound(err error, binary string) bool {
+	if errors.Is(err, exec.ErrNotFound) {
+		return true
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != binary || !errors.Is(pathErr, fs.ErrNotExist) {
+		return false
+	}
+	_, statErr := os.Stat(binary)
+	return errors.Is(statErr, fs.ErrNotExist)
+}
+
+// classifyFailure wraps a failed invocation, deciding retryability from stderr.
+// Videos the platform won't serve are reported as a ports.UnavailableError
+// with the reason, and never retried here. Failures matching no known pattern
+// are treated as transient, since most generic extractor errors clear up on a
+// fresh invocation.
+func classifyFailure(err error, stderr string) error {
+	lower := strings.ToLower(stderr)
+	for _, u := range unavailableMarkers {
+		if containsAny(lower, u.markers) {
+			return &Error{Err: fmt.Errorf("%w: %v", &ports.UnavailableError{Reason: u.reason}, err), Stderr: stderr}
+		}
+	}
+	if containsAny(lower, fatalMarkers) {
+		return &Error{Err: err, Stderr: stderr}
+	}
+	return &Error{Err: err, Stderr: stderr, Retryable: true}
+}
+
+func containsAny(s string, substrs []string) bool {
+	for _, sub := range substrs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRetryable reports whether a run error is worth retrying.
+func isRetryable(err error) bool {
+	var ytErr *Error
+	if errors.As(err, &ytErr) {
+		return ytErr.Retryable
+	}
+	return false
+}
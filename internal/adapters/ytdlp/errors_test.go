@@ -0,0 +1,52 @@
+package ytdlp
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsNotFound(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "yt-dlp")
+	present := filepath.Join(dir, "present")
+	if err := os.WriteFile(present, []byte("#!/nonexistent/interpreter\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		binary string
+		want   bool
+	}{
+		{"not on PATH", &exec.Error{Name: "yt-dlp", Err: exec.ErrNotFound}, "yt-dlp", true},
+		{"no file at path", &fs.PathError{Op: "fork/exec", Path: missing, Err: fs.ErrNotExist}, missing, true},
+		{"interpreter missing", &fs.PathError{Op: "fork/exec", Path: present, Err: fs.ErrNotExist}, present, false},
+		{"another file", &fs.PathError{Op: "open", Path: filepath.Join(dir, "cookies.txt"), Err: fs.ErrNotExist}, missing, false},
+		{"other failure", errors.New("exit status 1"), missing, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNotFound(tt.err, tt.binary); got != tt.want {
+				t.Errorf("isNotFound(%v, %s) = %v, want %v", tt.err, tt.binary, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckBinaryMissingPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "yt-dlp")
+	err := NewYtDlpDownloaderWithPath(path).CheckBinary()
+	var notFound *BinaryNotFoundError
+	if !errors.As(err, &notFound) || notFound.Path != path {
+		t.Fatalf("CheckBinary err = %v, want a *BinaryNotFoundError for %s", err, path)
+	}
+	if strings.Contains(err.Error(), "-ytdlp-path") {
+		t.Errorf("error %q names a CLI flag", err)
+	}
+}
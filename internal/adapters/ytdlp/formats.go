@@ -0,0 +1,83 @@
+package ytdlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// FormatInfo describes one format yt-dlp can download for a video.
+type FormatInfo struct {
+	FormatID   string
+	Ext        string
+	Resolution string  // e.g. "1920x1080" or "audio only"
+	FPS        float64 // 0 if unknown or audio only
+	VideoCodec string  // "" if the format has no video
+	AudioCodec string  // "" if the format has no audio
+	Filesize   int64   // Exact or approximate; 0 if unknown
+	Note       string
+	HasVideo   bool
+	HasAudio   bool
+}
+
+// ListFormats lists the formats available for the video, in yt-dlp's order
+// (worst to best), without downloading anything.
+func (d *YtDlpDownloader) ListFormats(ctx context.Context, videoURL string) ([]FormatInfo, error) {
+	out, err := d.run(ctx, "-J", "--no-playlist", "--no-warnings", videoURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseFormats([]byte(out))
+}
+
+// parseFormats extracts the formats array from a -J dump.
+func parseFormats(dump []byte) ([]FormatInfo, error) {
+	var info struct {
+		Formats []struct {
+			FormatID       string  `json:"format_id"`
+			Ext            string  `json:"ext"`
+			Resolution     string  `json:"resolution"`
+			Width          int     `json:"width"`
+			Height         int     `json:"height"`
+			FPS            float64 `json:"fps"`
+			VCodec         string  `json:"vcodec"`
+			ACodec         string  `json:"acodec"`
+			Filesize       float64 `json:"filesize"`
+			FilesizeApprox float64 `json:"filesize_approx"`
+			FormatNote     string  `json:"format_note"`
+		} `json:"formats"`
+	}
+	if err := json.Unmarshal(dump, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp output: %w", err)
+	}
+
+	formats := make([]FormatInfo, 0, len(info.Formats))
+	for _, f := range info.Formats {
+		fi := FormatInfo{
+			FormatID:   f.FormatID,
+			Ext:        f.Ext,
+			Resolution: f.Resolution,
+			FPS:        f.FPS,
+			Note:       f.FormatNote,
+			// yt-dlp reports a missing stream as codec "none"
+			HasVideo: f.VCodec != "none" && (f.VCodec != "" || f.Height > 0),
+			HasAudio: f.ACodec != "none" && f.ACodec != "",
+		}
+		if fi.HasVideo {
+			fi.VideoCodec = f.VCodec
+		}
+		if fi.HasAudio {
+			fi.AudioCodec = f.ACodec
+		}
+		if fi.Resolution == "" && f.Width > 0 && f.Height > 0 {
+			fi.Resolution = fmt.Sprintf("%dx%d", f.Width, f.Height)
+		}
+		if f.Filesize > 0 {
+			fi.Filesize = int64(f.Filesize)
+		} else {
+			fi.Filesize = int64(f.FilesizeApprox)
+		}
+		formats = append(formats, fi)
+	}
+	return formats, nil
+}
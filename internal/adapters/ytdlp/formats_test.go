@@ -0,0 +1,66 @@
+package ytdlp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// formatsDump is a -J dump trimmed to the formats array, with a combined, a
+// video-only, an audio-only and a storyboard format.
+const formatsDump = `{"id": "x", "formats": [
+	{"format_id": "sb0", "ext": "mhtml", "resolution": "48x27", "vcodec": "none", "acodec": "none", "format_note": "storyboard"},
+	{"format_id": "140", "ext": "m4a", "resolution": "audio only", "vcodec": "none", "acodec": "mp4a.40.2", "filesize": 3455488, "format_note": "medium"},
+	{"format_id": "18", "ext": "mp4", "width": 640, "height": 360, "fps": 25, "vcodec": "avc1.42001E", "acodec": "mp4a.40.2", "filesize_approx": 9876543.2, "format_note": "360p"},
+	{"format_id": "137", "ext": "mp4", "resolution": "1920x1080", "fps": 29.97, "vcodec": "avc1.640028", "acodec": "none", "filesize": 104857600, "filesize_approx": 1, "format_note": "1080p"}
+]}`
+
+func TestParseFormats(t *testing.T) {
+	got, err := parseFormats([]byte(formatsDump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FormatInfo{
+		{FormatID: "sb0", Ext: "mhtml", Resolution: "48x27", Note: "storyboard"},
+		{FormatID: "140", Ext: "m4a", Resolution: "audio only", AudioCodec: "mp4a.40.2", Filesize: 3455488, Note: "medium", HasAudio: true},
+		{FormatID: "18", Ext: "mp4", Resolution: "640x360", FPS: 25, VideoCodec: "avc1.42001E", AudioCodec: "mp4a.40.2", Filesize: 9876543, Note: "360p", HasVideo: true, HasAudio: true},
+		{FormatID: "137", Ext: "mp4", Resolution: "1920x1080", FPS: 29.97, VideoCodec: "avc1.640028", Filesize: 104857600, Note: "1080p", HasVideo: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d formats, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("format %d = %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+
+	for _, dump := range []string{`{"id": "x"}`, `{"formats": []}`} {
+		if got, err := parseFormats([]byte(dump)); err != nil || len(got) != 0 {
+			t.Errorf("parseFormats(%s) = %v, %v; want no formats", dump, got, err)
+		}
+	}
+	if _, err := parseFormats([]byte("not json")); err == nil {
+		t.Error("parseFormats succeeded on invalid output")
+	}
+}
+
+func TestListFormats(t *testing.T) {
+	runner := &fakeRunner{results: []fakeRunResult{{stdout: formatsDump}}}
+	formats, err := newFakeDownloader(runner).ListFormats(context.Background(), "https://youtu.be/x")
+	if err != nil {
+		t.Fatalf("ListFormats: %v", err)
+	}
+	if len(formats) != 4 || formats[3].FormatID != "137" {
+		t.Errorf("ListFormats = %+v, want the four formats in order", formats)
+	}
+	want := []string{"yt-dlp", "-J", "--no-playlist", "--no-warnings", "https://youtu.be/x"}
+	if !reflect.DeepEqual(runner.calls, [][]string{want}) {
+		t.Errorf("args = %q, want %q", runner.calls, want)
+	}
+
+	runner = &fakeRunner{results: []fakeRunResult{{stderr: "ERROR: Video unavailable", err: errExit}}}
+	if _, err := newFakeDownloader(runner).ListFormats(context.Background(), "https://youtu.be/x"); err == nil {
+		t.Error("ListFormats succeeded when yt-dlp failed")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package ytdlp
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessTree makes cancelling cmd's context kill yt-dlp's whole
+// process group, including the ffmpeg processes it spawns, instead of only
+// yt-dlp itself.
+func killProcessTree(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		// A negative PID signals the group, whose ID is yt-dlp's PID
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
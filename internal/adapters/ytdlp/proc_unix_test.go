@@ -0,0 +1,71 @@
+//go:build !windows
+
+package ytdlp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// exited reports whether pid is gone or a zombie waiting to be reaped.
+func exited(pid int) bool {
+	if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+		return true
+	}
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	// The state follows the parenthesized command name
+	if i := bytes.LastIndexByte(stat, ')'); i >= 0 && i+2 < len(stat) {
+		return stat[i+2] == 'Z'
+	}
+	return false
+}
+
+// Cancelling a run kills the processes the command started, not only the
+// command itself.
+func TestExecRunnerKillsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	script := `sleep 60 & echo $! > "$1"; wait`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := execRunner{}.Run(ctx, "sh", "-c", script, "sh", pidFile)
+		done <- err
+	}()
+
+	var child int
+	for deadline := time.Now().Add(5 * time.Second); child == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatal("the command never started its child")
+		}
+		data, _ := os.ReadFile(pidFile)
+		child, _ = strconv.Atoi(string(bytes.TrimSpace(data)))
+	}
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("Run of a cancelled command succeeded")
+		}
+	case <-time.After(processWaitDelay):
+		t.Error("Run didn't return after cancellation")
+	}
+	for deadline := time.Now().Add(2 * time.Second); !exited(child); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			syscall.Kill(child, syscall.SIGKILL)
+			t.Fatalf("child process %d outlived the cancelled command", child)
+		}
+	}
+}
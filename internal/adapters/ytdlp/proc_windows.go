@@ -0,0 +1,24 @@
+//go:build windows
+
+package ytdlp
+
+import (
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// killProcessTree makes cancelling cmd's context kill yt-dlp and every
+// process it spawned (e.g. ffmpeg), via taskkill /T, instead of only
+// yt-dlp itself.
+func killProcessTree(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+		if err := kill.Run(); err != nil {
+			// Fall back to killing yt-dlp alone
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
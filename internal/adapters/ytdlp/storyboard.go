@@ -0,0 +1,80 @@
+package ytdlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// GetStoryboards lists the sprite sheets of the highest-resolution storyboard
+// yt-dlp reports for the video. Returns an empty slice if there is none.
+func (d *YtDlpDownloader) GetStoryboards(ctx context.Context, videoURL string) ([]ports.StoryboardImage, error) {
+	out, err := d.run(ctx, "-J", "--no-playlist", "--no-warnings", videoURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseStoryboards([]byte(out))
+}
+
+// storyboardFormat is the subset of a storyboard format entry we use.
+// yt-dlp lists storyboards among the formats as mhtml "sb*" entries whose
+// fragments are the individual sprite images.
+type storyboardFormat struct {
+	FormatID    string            `json:"format_id"`
+	FormatNote  string            `json:"format_note"`
+	HTTPHeaders map[string]string `json:"http_headers"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	Columns     int               `json:"columns"`
+	Rows        int               `json:"rows"`
+	Fragments   []struct {
+		URL      string  `json:"url"`
+		Duration float64 `json:"duration"`
+	} `json:"fragments"`
+}
+
+// parseStoryboards extracts the sprite sheets of the widest storyboard
+// format from a -J dump.
+func parseStoryboards(dump []byte) ([]ports.StoryboardImage, error) {
+	var info struct {
+		Formats []storyboardFormat `json:"formats"`
+	}
+	if err := json.Unmarshal(dump, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp output: %w", err)
+	}
+
+	var best *storyboardFormat
+	for i := range info.Formats {
+		f := &info.Formats[i]
+		if f.FormatNote != "storyboard" || len(f.Fragments) == 0 {
+			continue
+		}
+		if best == nil || f.Width > best.Width {
+			best = f
+		}
+	}
+	if best == nil {
+		return []ports.StoryboardImage{}, nil
+	}
+
+	images := make([]ports.StoryboardImage, 0, len(best.Fragments))
+	for i, frag := range best.Fragments {
+		if frag.URL == "" {
+			continue
+		}
+		images = append(images, ports.StoryboardImage{
+			FormatID:        best.FormatID,
+			Index:           i,
+			URL:             frag.URL,
+			Headers:         best.HTTPHeaders,
+			Width:           best.Width,
+			Height:          best.Height,
+			Columns:         best.Columns,
+			Rows:            best.Rows,
+			DurationSeconds: frag.Duration,
+		})
+	}
+	return images, nil
+}
@@ -0,0 +1,87 @@
+package ytdlp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// storyboardDump is a trimmed -J dump with two storyboard formats.
+const storyboardDump = `{
+  "id": "dQw4w9WgXcQ",
+  "formats": [
+    {"format_id": "sb2", "format_note": "storyboard", "ext": "mhtml", "width": 80, "height": 45, "columns": 10, "rows": 10,
+     "fragments": [{"url": "https://i.ytimg.com/sb/2/M0.jpg", "duration": 200}]},
+    {"format_id": "sb0", "format_note": "storyboard", "ext": "mhtml", "width": 160, "height": 90, "columns": 5, "rows": 5,
+     "http_headers": {"User-Agent": "ua"},
+     "fragments": [
+       {"url": "https://i.ytimg.com/sb/0/M0.jpg", "duration": 50},
+       {"url": "", "duration": 50},
+       {"url": "https://i.ytimg.com/sb/0/M2.jpg", "duration": 12.5}
+     ]},
+    {"format_id": "sb1", "format_note": "storyboard", "width": 320, "height": 180, "fragments": []},
+    {"format_id": "18", "format_note": "360p", "width": 640, "height": 360, "url": "https://cdn/v.mp4"}
+  ]
+}`
+
+func TestParseStoryboards(t *testing.T) {
+	tests := []struct {
+		name string
+		dump string
+		want []ports.StoryboardImage
+	}{
+		{
+			name: "widest storyboard with fragments",
+			dump: storyboardDump,
+			want: []ports.StoryboardImage{
+				{FormatID: "sb0", Index: 0, URL: "https://i.ytimg.com/sb/0/M0.jpg", Headers: map[string]string{"User-Agent": "ua"},
+					Width: 160, Height: 90, Columns: 5, Rows: 5, DurationSeconds: 50},
+				{FormatID: "sb0", Index: 2, URL: "https://i.ytimg.com/sb/0/M2.jpg", Headers: map[string]string{"User-Agent": "ua"},
+					Width: 160, Height: 90, Columns: 5, Rows: 5, DurationSeconds: 12.5},
+			},
+		},
+		{
+			name: "no storyboards",
+			dump: `{"formats": [{"format_id": "18", "format_note": "360p"}]}`,
+			want: []ports.StoryboardImage{},
+		},
+		{
+			name: "no formats",
+			dump: `{"id": "x"}`,
+			want: []ports.StoryboardImage{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStoryboards([]byte(tt.dump))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStoryboards = %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetStoryboards(t *testing.T) {
+	runner := &fakeRunner{results: []fakeRunResult{{stdout: storyboardDump}}}
+	images, err := newFakeDownloader(runner).GetStoryboards(context.Background(), "https://youtu.be/x")
+	if err != nil {
+		t.Fatalf("GetStoryboards: %v", err)
+	}
+	if len(images) != 2 {
+		t.Errorf("got %d images, want 2", len(images))
+	}
+	want := []string{"yt-dlp", "-J", "--no-playlist", "--no-warnings", "https://youtu.be/x"}
+	if !reflect.DeepEqual(runner.calls, [][]string{want}) {
+		t.Errorf("args = %q, want %q", runner.calls, want)
+	}
+
+	runner = &fakeRunner{results: []fakeRunResult{{stdout: "not json"}}}
+	if _, err := newFakeDownloader(runner).GetStoryboards(context.Background(), "https://youtu.be/x"); err == nil {
+		t.Error("GetStoryboards succeeded on invalid output")
+	}
+}
@@ -0,0 +1,93 @@
+package ytdlp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// GetSubtitles lists the WebVTT subtitle tracks yt-dlp reports for the
+// video, best first (see ports.SubtitleLister). Automatic captions are only
+// listed in the video's own language: YouTube offers machine translations
+// of them into every other language.
+func (d *YtDlpDownloader) GetSubtitles(ctx context.Context, videoURL string) ([]ports.SubtitleTrack, error) {
+	out, err := d.run(ctx, "-J", "--no-playlist", "--no-warnings", videoURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseSubtitles([]byte(out))
+}
+
+// subtitleFile is one format of a subtitle track in a -J dump.
+type subtitleFile struct {
+	Ext string `json:"ext"`
+	URL string `json:"url"`
+}
+
+// parseSubtitles extracts the VTT tracks from a -J dump, ranked by
+// subtitleRank.
+func parseSubtitles(dump []byte) ([]ports.SubtitleTrack, error) {
+	var info struct {
+		Language          string                    `json:"language"`
+		Subtitles         map[string][]subtitleFile `json:"subtitles"`
+		AutomaticCaptions map[string][]subtitleFile `json:"automatic_captions"`
+	}
+	if err := json.Unmarshal(dump, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp output: %w", err)
+	}
+
+	tracks := []ports.SubtitleTrack{}
+	add := func(files map[string][]subtitleFile, automatic bool) {
+		for lang, formats := range files {
+			if automatic && !strings.HasSuffix(lang, "-orig") && !sameLanguage(lang, info.Language) {
+				continue
+			}
+			for _, f := range formats {
+				if f.Ext == "vtt" && f.URL != "" {
+					tracks = append(tracks, ports.SubtitleTrack{Language: lang, URL: f.URL, Automatic: automatic})
+					break
+				}
+			}
+		}
+	}
+	add(info.Subtitles, false)
+	add(info.AutomaticCaptions, true)
+
+	sort.Slice(tracks, func(i, j int) bool {
+		ri, rj := subtitleRank(tracks[i], info.Language), subtitleRank(tracks[j], info.Language)
+		if ri != rj {
+			return ri < rj
+		}
+		return tracks[i].Language < tracks[j].Language
+	})
+	return tracks, nil
+}
+
+// subtitleRank orders tracks: uploaded subtitles in the video's language,
+// other uploaded subtitles, then captions of the original audio ("-orig"),
+// then other captions in the video's language.
+func subtitleRank(t ports.SubtitleTrack, videoLang string) int {
+	switch {
+	case !t.Automatic && sameLanguage(t.Language, videoLang):
+		return 0
+	case !t.Automatic:
+		return 1
+	case strings.HasSuffix(t.Language, "-orig"):
+		return 2
+	}
+	return 3
+}
+
+// sameLanguage reports whether lang is in the base language of videoLang,
+// e.g. "en-US" and "en". An unknown videoLang matches nothing.
+func sameLanguage(lang, videoLang string) bool {
+	base := func(l string) string {
+		l, _, _ = strings.Cut(strings.ToLower(l), "-")
+		return l
+	}
+	return videoLang != "" && base(lang) == base(videoLang)
+}
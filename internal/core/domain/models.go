@@ -1,21 +1,168 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Job represents a single scraping job.
 type Job struct {
-	ID        string    `json:"job_id"`
-	URL       string    `json:"url"`
-	Platform  string    `json:"platform"` // "youtube" or "tiktok"
-	CreatedAt time.Time `json:"created_at"`
+	ID         string    `json:"job_id"`
+	ExternalID string    `json:"external_id,omitempty"` // Caller's own ID for the job
+	URL        string    `json:"url"`
+	VideoURL   string    `json:"video_url,omitempty"` // Pre-resolved media URL downloaded as is
+	Platform   string    `json:"platform"`            // "youtube" or "tiktok"
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 // JobResult holds the outcome of a completed job.
 type JobResult struct {
-	Job          Job
-	MetadataPath string
-	VideoPath    string
-	Success      bool
-	ErrorMessage string
-	CompletedAt  time.Time
+	Job          Job         `json:"job"`
+	MetadataPath string      `json:"metadata_path,omitempty"`
+	VideoPath    string      `json:"video_path,omitempty"`
+	AudioPath    string      `json:"audio_path,omitempty"`   // Audio-only stream, with separate streams
+	DuplicateOf  string      `json:"duplicate_of,omitempty"` // Job ID holding identical video content, if de-duplicated
+	Renditions   []Rendition `json:"renditions,omitempty"`
+	Music        *MusicTrack `json:"music,omitempty"` // Background music track, with TikTok music extraction
+	Success      bool        `json:"success"`
+	ErrorMessage string      `json:"error,omitempty"`
+	// FailureReason categorizes an unavailable video, e.g. "private"
+	FailureReason string `json:"failure_reason,omitempty"`
+	// PanicStack is the stack of a job that panicked
+	PanicStack string `json:"panic_stack,omitempty"`
+	// MetadataFallback is set when the metadata came from a fallback
+	// scraper and holds only basic fields
+	MetadataFallback bool `json:"metadata_fallback,omitempty"`
+	// Skipped jobs matched a skip rule and weren't downloaded; they aren't
+	// failures
+	Skipped     bool      `json:"skipped,omitempty"`
+	SkipReason  string    `json:"skip_reason,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+
+	// Download statistics
+	DownloadBytes            int64         `json:"download_bytes"`
+	DownloadDuration         time.Duration `json:"download_duration_ns"`
+	AvgThroughputBytesPerSec float64       `json:"avg_throughput_bytes_per_sec"`
+	// VideoDigests holds the video's requested hashes by algorithm, e.g.
+	// {"md5": ..., "sha1": ...}; unset for resumed downloads
+	VideoDigests map[string]string `json:"video_digests,omitempty"`
+
+	// Apify usage of the job's scrapes (including re-scrapes); cached
+	// scrapes cost nothing
+	ApifyComputeUnits float64 `json:"apify_compute_units,omitempty"`
+	ApifyCostUSD      float64 `json:"apify_cost_usd,omitempty"`
+
+	Timings Timings `json:"timings"`
+}
+
+// Timings records when each job step ran; zero times mean the step didn't
+// run. With several renditions, resolve and download span from the first
+// start to the last end.
+type Timings struct {
+	ScrapeStartedAt   time.Time `json:"scrape_started_at"`
+	ScrapeEndedAt     time.Time `json:"scrape_ended_at"`
+	ResolveStartedAt  time.Time `json:"resolve_started_at"`
+	ResolveEndedAt    time.Time `json:"resolve_ended_at"`
+	DownloadStartedAt time.Time `json:"download_started_at"`
+	DownloadEndedAt   time.Time `json:"download_ended_at"`
+}
+
+// VideoMetadata is the platform-independent subset of a video's metadata,
+// normalized from the scraper's raw response. Zero values mean unknown.
+type VideoMetadata struct {
+	Title           string   `json:"title,omitempty"`
+	Author          string   `json:"author,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	DurationSeconds float64  `json:"duration_seconds,omitempty"`
+	ViewCount       int64    `json:"view_count,omitempty"`
+	LikeCount       int64    `json:"like_count,omitempty"`
+	PublishedAt     string   `json:"published_at,omitempty"` // As reported by the platform
+	ThumbnailURL    string   `json:"thumbnail_url,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	// LiveStatus is set for streams, e.g. "was_live" (see ports.LiveStatus)
+	LiveStatus string `json:"live_status,omitempty"`
+
+	// Extra holds fields added by metadata processors.
+	Extra map[string]interface{} `json:"extra,omitempty"`
+}
+
+// Rendition is one saved quality of the video.
+type Rendition struct {
+	Quality string `json:"quality"`
+	Path    string `json:"path"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// MusicTrack is a saved background music track.
+type MusicTrack struct {
+	Title  string `json:"title,omitempty"`
+	Author string `json:"author,omitempty"`
+	Album  string `json:"album,omitempty"`
+	// Original is set for the creator's own sound rather than a licensed track
+	Original bool   `json:"original"`
+	Path     string `json:"path"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// DownloadState is the persisted progress of an in-flight download, used to
+// resume after a restart.
+type DownloadState struct {
+	JobID        string            `json:"job_id"`
+	PageURL      string            `json:"page_url"`
+	Platform     string            `json:"platform"`
+	VideoURL     string            `json:"video_url"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	TargetFile   string            `json:"target_file"`
+	BytesWritten int64             `json:"bytes_written"`
+	ETag         string            `json:"etag,omitempty"`
+	LastModified string            `json:"last_modified,omitempty"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// Manifest summarizes every artifact produced by a job.
+type Manifest struct {
+	JobID       string          `json:"job_id"`
+	URL         string          `json:"url"`
+	Platform    string          `json:"platform"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Artifacts   []ManifestEntry `json:"artifacts"`
+}
+
+// ManifestEntry describes a single artifact in the job directory.
+type ManifestEntry struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"` // "video", "metadata", "thumbnail", "subtitles", ...
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Digests holds the extra hashes requested, e.g. {"md5": ...}
+	Digests     map[string]string `json:"digests,omitempty"`
+	ContentType string            `json:"content_type"`
+	ModifiedAt  time.Time         `json:"modified_at"`
+}
+
+// JobStep identifies the stage of a job at which a failure occurred.
+type JobStep string
+
+const (
+	StepScrape    JobStep = "scrape"
+	StepPreflight JobStep = "preflight"
+	StepResolve   JobStep = "resolve"
+	StepDownload  JobStep = "download"
+	StepSave      JobStep = "save"
+)
+
+// JobError is a job failure annotated with the failed step and whether
+// retrying the job makes sense.
+type JobError struct {
+	Step      JobStep
+	Err       error
+	Retryable bool
+}
+
+func (e *JobError) Error() string {
+	return fmt.Sprintf("%s failed: %v", e.Step, e.Err)
+}
+
+func (e *JobError) Unwrap() error {
+	return e.Err
 }
@@ -0,0 +1,67 @@
+package ports
+
+import "errors"
+
+// ErrVideoUnavailable is returned when the platform reports no video for the URL
+// (deleted, removed, or never existed).
+var ErrVideoUnavailable = errors.New("video not found or removed")
+
+// ErrUnsupportedPlatform is returned by scrapers that have no source for the
+// URL's platform.
+var ErrUnsupportedPlatform = errors.New("unsupported platform")
+
+// ErrToolNotFound is returned when an external program an adapter runs
+// (e.g. yt-dlp) isn't installed.
+var ErrToolNotFound = errors.New("required tool not found")
+
+// ErrInvalidURL is returned when a URL given to a job is malformed or not an
+// http(s) URL.
+var ErrInvalidURL = errors.New("invalid url")
+
+// ErrURLExpired is returned by downloaders when a resolved URL is rejected as
+// expired or forbidden (HTTP 403/410); re-resolving usually yields a fresh one.
+var ErrURLExpired = errors.New("download url expired")
+
+// ErrNotVideo is returned by downloaders when the server answers with a
+// document (an HTML page, a JSON or XML error) instead of media, as CDNs do
+// for expired signed URLs.
+var ErrNotVideo = errors.New("response is not a video")
+
+// ErrDownloadStalled is returned by downloaders when no video data arrives
+// for their idle timeout while the connection stays open.
+var ErrDownloadStalled = errors.New("download stalled")
+
+// ErrJobNotFound is returned when no stored job matches a lookup.
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobLocked is returned when another process is already working the job.
+var ErrJobLocked = errors.New("job is locked by another process")
+
+// ErrLimitExceeded is returned when a video exceeds a configured duration or
+// size limit and is not downloaded.
+var ErrLimitExceeded = errors.New("video exceeds configured limit")
+
+// ErrCircuitOpen is returned without contacting an upstream service that has
+// failed repeatedly, until its circuit breaker's cooldown ends.
+var ErrCircuitOpen = errors.New("circuit breaker open after repeated failures")
+
+// ErrRunStalled is returned when a scrape run never starts (e.g. an actor
+// stuck building or cold-starting) within its no-progress timeout.
+var ErrRunStalled = errors.New("scrape run made no progress")
+
+// ErrInsufficientSpace is returned when storage has less free space than a
+// download is estimated to need, plus the configured safety margin.
+var ErrInsufficientSpace = errors.New("insufficient free space")
+
+// ErrLiveStream is returned for a stream that is live or scheduled: only
+// the recording of a finished stream is downloaded.
+var ErrLiveStream = errors.New("video is a live or upcoming stream")
+
+// ErrNoDirectURL is returned when a direct URL is asked for a video only
+// the resolver itself can download (e.g. a YouTube recording still being
+// processed, which yt-dlp fetches as DASH segments).
+var ErrNoDirectURL = errors.New("video has no direct download URL")
+
+// ErrCertMismatch is returned when a server certificate matches none of the
+// pinned fingerprints.
+var ErrCertMismatch = errors.New("server certificate does not match pinned fingerprints")
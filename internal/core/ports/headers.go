@@ -0,0 +1,34 @@
+package ports
+
+import "context"
+
+type requestHeadersKey struct{}
+
+// WithRequestHeaders attaches extra HTTP headers (e.g. the User-Agent and
+// Referer a platform's CDN expects) for downloaders to send.
+func WithRequestHeaders(ctx context.Context, headers map[string]string) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, requestHeadersKey{}, headers)
+}
+
+// RequestHeadersFrom returns the headers attached with WithRequestHeaders.
+func RequestHeadersFrom(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(requestHeadersKey{}).(map[string]string)
+	return headers
+}
+
+type anyContentTypeKey struct{}
+
+// WithAnyContentType makes downloaders accept a response of any content
+// type, for requests that want a page rather than media (see ErrNotVideo).
+func WithAnyContentType(ctx context.Context) context.Context {
+	return context.WithValue(ctx, anyContentTypeKey{}, true)
+}
+
+// AnyContentTypeFrom reports whether WithAnyContentType was applied.
+func AnyContentTypeFrom(ctx context.Context) bool {
+	ok, _ := ctx.Value(anyContentTypeKey{}).(bool)
+	return ok
+}
@@ -3,6 +3,9 @@ package ports
 import (
 	"context"
 	"io"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
 )
 
 // ScrapeResult holds the raw metadata from a scraping operation.
@@ -10,6 +13,47 @@ import (
 type ScrapeResult struct {
 	RawMetadata []byte // Full JSON response, untouched
 	VideoURL    string // Extracted video download URL
+	Comments    []byte // Raw comments JSON array, nil unless comments were requested
+
+	// Pre-flight hints parsed from the metadata; 0 means unknown.
+	DurationSeconds float64
+	EstimatedBytes  int64
+
+	// What the scrape cost, for scrapers that report it (Apify compute
+	// units and USD); 0 when unknown or served from a cache.
+	ComputeUnits float64
+	CostUSD      float64
+
+	// The Apify run and dataset that produced the metadata, for scrapers
+	// that report them; "" otherwise. A cached result keeps its run's.
+	RunID     string
+	DatasetID string
+
+	// FromFallback marks metadata from a fallback scraper (e.g. oEmbed for
+	// a platform the primary can't scrape), usually only basic fields.
+	FromFallback bool
+
+	// Music is the post's background music track, for platforms that
+	// report one (TikTok); nil if none was found.
+	Music *MusicTrack
+}
+
+// MusicTrack is the sound a post uses, as reported by the scraper.
+type MusicTrack struct {
+	URL    string // Direct audio download URL; empty if not provided
+	Title  string
+	Author string
+	Album  string
+	// Original marks the creator's own "original sound", as opposed to a
+	// licensed track from the platform's music library.
+	Original bool
+}
+
+// ArtifactInfo describes a file persisted for a job.
+type ArtifactInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
 }
 
 // Scraper defines the contract for fetching video metadata from an API.
@@ -19,6 +63,16 @@ type Scraper interface {
 	Scrape(ctx context.Context, videoPageURL string) (*ScrapeResult, error)
 }
 
+// BatchScraper is implemented by scrapers that can scrape several URLs for
+// the price of one request, such as an actor run taking a list of URLs.
+type BatchScraper interface {
+	// ScrapeBatch scrapes urls, returning one result per URL in the same
+	// order. A nil entry means the batch didn't cover that URL (e.g. the
+	// run returned no item for it, or its platform can't be batched), and
+	// it should be scraped on its own with Scrape.
+	ScrapeBatch(ctx context.Context, urls []string) ([]*ScrapeResult, error)
+}
+
 // Downloader defines the contract for downloading video files.
 type Downloader interface {
 	// Download fetches the video from the given URL.
@@ -26,20 +80,162 @@ type Downloader interface {
 	Download(ctx context.Context, videoURL string) (io.ReadCloser, error)
 }
 
+// DownloadResponse is an open download, possibly resumed part-way through.
+type DownloadResponse struct {
+	Body         io.ReadCloser
+	Offset       int64 // Byte offset the body starts at; 0 means the full file
+	ETag         string
+	LastModified string
+	ContentType  string // As sent by the server; "" if unknown
+}
+
+// ResumableDownloader is implemented by downloaders that can continue a
+// partial download with a Range request.
+type ResumableDownloader interface {
+	// DownloadFrom fetches videoURL starting at offset. ifRange (an ETag or
+	// Last-Modified value) guards the resume: if the remote file changed, the
+	// full file is returned with Offset 0.
+	DownloadFrom(ctx context.Context, videoURL string, offset int64, ifRange string) (*DownloadResponse, error)
+}
+
+// ContentRef points at a stored artifact.
+type ContentRef struct {
+	JobID    string `json:"job_id"`
+	Filename string `json:"filename"`
+}
+
+// ContentIndex maps content hashes to the job that first stored them, for
+// de-duplicating identical downloads across jobs.
+type ContentIndex interface {
+	// LookupOrAdd returns the existing reference for hash if one exists;
+	// otherwise it records ref for hash and returns nil.
+	LookupOrAdd(ctx context.Context, hash string, ref ContentRef) (*ContentRef, error)
+
+	// Set records ref for hash, replacing any existing reference (e.g. one
+	// whose file is gone).
+	Set(ctx context.Context, hash string, ref ContentRef) error
+}
+
+// MetadataProcessor enriches normalized metadata (e.g. language detection,
+// classification, keyword extraction). raw is the scraper's untouched response.
+type MetadataProcessor interface {
+	Process(ctx context.Context, meta *domain.VideoMetadata, raw []byte) (*domain.VideoMetadata, error)
+}
+
+// ChannelState remembers which videos of each channel have been downloaded,
+// for incremental channel syncs.
+type ChannelState interface {
+	// SeenVideos returns the IDs already downloaded for the channel.
+	SeenVideos(ctx context.Context, channelID string) (map[string]bool, error)
+
+	// MarkSeen records videoIDs as downloaded, along with the channel's
+	// current URL and title.
+	MarkSeen(ctx context.Context, listing ChannelListing, channelURL string, videoIDs []string) error
+}
+
+// BatchCheckpoint remembers which entries of a named batch have completed,
+// so a re-run of an interrupted batch skips them.
+type BatchCheckpoint interface {
+	// Completed returns the job IDs of the batch's completed entries, keyed
+	// by entry.
+	Completed(ctx context.Context, batchID string) (map[string]string, error)
+
+	// MarkCompleted records that the batch entry key finished as jobID.
+	MarkCompleted(ctx context.Context, batchID, key, jobID string) error
+
+	// Clear forgets the batch's completed entries.
+	Clear(ctx context.Context, batchID string) error
+}
+
 // Storage defines the contract for persisting job artifacts.
 type Storage interface {
-	// InitJob creates the job directory structure.
+	// InitJob creates the job directory structure and locks the job.
 	InitJob(ctx context.Context, jobID string) error
 
+	// ReleaseJob releases the lock taken by InitJob.
+	ReleaseJob(ctx context.Context, jobID string) error
+
 	// SaveInput saves the job input metadata (URL, timestamp, etc.).
 	SaveInput(ctx context.Context, jobID string, data []byte) error
 
 	// SaveMetadata saves the raw API response without modification.
 	SaveMetadata(ctx context.Context, jobID string, data []byte) error
 
+	// SaveProjectedMetadata saves the selected subset of metadata fields.
+	SaveProjectedMetadata(ctx context.Context, jobID string, data []byte) error
+
+	// SavePage saves the video page's raw HTML.
+	SavePage(ctx context.Context, jobID string, data []byte) error
+
+	// SaveNormalizedMetadata saves the normalized (and processed) metadata.
+	SaveNormalizedMetadata(ctx context.Context, jobID string, data []byte) error
+
+	// SaveComments saves the raw comments JSON array.
+	SaveComments(ctx context.Context, jobID string, data []byte) error
+
+	// SaveScrapeMeta saves where the metadata came from (the scraper's run
+	// and dataset IDs).
+	SaveScrapeMeta(ctx context.Context, jobID string, data []byte) error
+
 	// SaveVideo saves the video file from the provided reader.
 	SaveVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error
 
+	// AppendVideo appends to a partially written video file (for resumes).
+	AppendVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error
+
+	// OpenVideo opens a saved video file for reading and returns its size.
+	// The reader may also implement io.Seeker, for serving ranges. Returns
+	// an error wrapping os.ErrNotExist if there is no such file.
+	OpenVideo(ctx context.Context, jobID string, filename string) (io.ReadCloser, int64, error)
+
+	// SaveDownloadState persists in-progress download state for resuming.
+	SaveDownloadState(ctx context.Context, jobID string, data []byte) error
+
+	// LoadDownloadState returns the persisted download state.
+	// Returns an error wrapping os.ErrNotExist if there is none.
+	LoadDownloadState(ctx context.Context, jobID string) ([]byte, error)
+
+	// RemoveDownloadState deletes the download state once the download completes.
+	RemoveDownloadState(ctx context.Context, jobID string) error
+
+	// SaveStoryboard saves a storyboard sprite image under storyboards/.
+	SaveStoryboard(ctx context.Context, jobID string, name string, data []byte) error
+
+	// SaveReference records that the job's video duplicates another job's.
+	SaveReference(ctx context.Context, jobID string, data []byte) error
+
+	// RemoveArtifact deletes a stored artifact.
+	RemoveArtifact(ctx context.Context, jobID string, filename string) error
+
+	// SaveManifest saves the job manifest listing all artifacts.
+	SaveManifest(ctx context.Context, jobID string, data []byte) error
+
+	// WriteMarker atomically creates an empty marker file (e.g. "_SUCCESS").
+	WriteMarker(ctx context.Context, jobID string, name string) error
+
+	// Exists reports whether a stored artifact is present. A missing artifact
+	// is (false, nil); errors mean existence couldn't be determined.
+	Exists(ctx context.Context, jobID string, filename string) (bool, error)
+
+	// StatArtifact returns size and modification time of a stored artifact.
+	StatArtifact(ctx context.Context, jobID string, filename string) (*ArtifactInfo, error)
+
 	// GetJobPath returns the filesystem path for a given job ID.
 	GetJobPath(jobID string) string
 }
+
+// WriteChecker is implemented by storage backends with a write policy for
+// existing files, so a video can be kept before it is downloaded.
+type WriteChecker interface {
+	// ShouldWrite reports whether saving the job's file would write it, or
+	// fails as the save would under the policy.
+	ShouldWrite(ctx context.Context, jobID string, filename string) (bool, error)
+}
+
+// SpaceReporter is implemented by storage backends that can report how much
+// space is left for new artifacts.
+type SpaceReporter interface {
+	// FreeSpace returns the bytes available to this process. Errors wrap
+	// errors.ErrUnsupported when the backend can't tell.
+	FreeSpace(ctx context.Context) (int64, error)
+}
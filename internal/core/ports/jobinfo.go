@@ -0,0 +1,27 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// JobInfo describes the job being stored, for storage backends that derive
+// names from more than the job ID.
+type JobInfo struct {
+	Platform   string
+	CreatedAt  time.Time
+	ExternalID string
+}
+
+type jobInfoKey struct{}
+
+// WithJobInfo attaches the job's details for Storage.InitJob.
+func WithJobInfo(ctx context.Context, info JobInfo) context.Context {
+	return context.WithValue(ctx, jobInfoKey{}, info)
+}
+
+// JobInfoFrom returns the details attached with WithJobInfo.
+func JobInfoFrom(ctx context.Context) (JobInfo, bool) {
+	info, ok := ctx.Value(jobInfoKey{}).(JobInfo)
+	return info, ok
+}
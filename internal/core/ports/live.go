@@ -0,0 +1,44 @@
+package ports
+
+import "strings"
+
+// LiveStatus is whether a video is, or was, a live stream, as yt-dlp's
+// live_status field reports it.
+type LiveStatus string
+
+const (
+	LiveStatusUnknown LiveStatus = ""
+	NotLive           LiveStatus = "not_live"
+	IsLive            LiveStatus = "is_live"
+	IsUpcoming        LiveStatus = "is_upcoming" // Scheduled, not started yet
+	WasLive           LiveStatus = "was_live"    // Ended, the recording is a regular VOD
+	PostLive          LiveStatus = "post_live"   // Ended, the recording is still being processed
+)
+
+// ParseLiveStatus reads a live status given as yt-dlp's live_status, or,
+// failing that, as its older is_live and was_live flags. Unrecognized
+// statuses are LiveStatusUnknown.
+func ParseLiveStatus(status string, isLive, wasLive bool) LiveStatus {
+	switch s := LiveStatus(strings.ToLower(strings.TrimSpace(status))); s {
+	case NotLive, IsLive, IsUpcoming, WasLive, PostLive:
+		return s
+	}
+	switch {
+	case isLive:
+		return IsLive
+	case wasLive:
+		return WasLive
+	}
+	return LiveStatusUnknown
+}
+
+// Ongoing reports whether the stream is live or yet to start: there is no
+// complete recording to download.
+func (s LiveStatus) Ongoing() bool {
+	return s == IsLive || s == IsUpcoming
+}
+
+// Ended reports whether the video is the recording of a finished stream.
+func (s LiveStatus) Ended() bool {
+	return s == WasLive || s == PostLive
+}
@@ -0,0 +1,140 @@
+package ports
+
+import "context"
+
+// URLResolver turns a video page URL into a direct download URL, for
+// platforms whose scrape doesn't provide one (e.g. YouTube via yt-dlp).
+type URLResolver interface {
+	ResolveVideoURL(ctx context.Context, videoPageURL string) (string, error)
+}
+
+// LinkExpander turns a short link (e.g. pin.it/...) into the URL it
+// redirects to.
+type LinkExpander interface {
+	Expand(ctx context.Context, shortURL string) (string, error)
+}
+
+// HeaderResolver is implemented by resolvers that also report the HTTP
+// headers the CDN expects for the URL (User-Agent, Referer, cookies, ...).
+type HeaderResolver interface {
+	ResolveVideoURLWithHeaders(ctx context.Context, videoPageURL string) (string, map[string]string, error)
+}
+
+// FormatResolver is implemented by resolvers that also report the
+// container extension of the format they resolve, so the video can be saved
+// under a matching name (e.g. video.webm).
+type FormatResolver interface {
+	ResolveVideoFormat(ctx context.Context, videoPageURL string) (*ResolvedFormat, error)
+}
+
+// ResolvedFormat is a direct download URL and what is known about it.
+type ResolvedFormat struct {
+	URL        string
+	Headers    map[string]string // HTTP headers the CDN expects, if any
+	Ext        string            // Container extension without the dot; "" if unknown
+	LiveStatus LiveStatus
+
+	// ResolverOnly marks a format no single direct URL serves, e.g. a
+	// merged video+audio selection: only a FileDownloader resolver can
+	// fetch it. URL is then its first (video) part.
+	ResolverOnly bool
+}
+
+// FileDownloader is implemented by resolvers that can download a resolved
+// format themselves, for formats marked ResolverOnly.
+type FileDownloader interface {
+	// DownloadFormat downloads the video into dir as format was resolved
+	// and returns the path of the file written.
+	DownloadFormat(ctx context.Context, videoPageURL string, format *ResolvedFormat, dir string) (string, error)
+}
+
+// QualityResolver is implemented by resolvers that can pick a single-file
+// rendition of exactly the given height.
+type QualityResolver interface {
+	ResolveVideoURLForHeight(ctx context.Context, videoPageURL string, height int) (string, error)
+}
+
+// StreamsResolver is implemented by resolvers that can resolve a video's
+// best video-only and audio-only streams separately, for callers that mux
+// them themselves.
+type StreamsResolver interface {
+	ResolveSeparateStreams(ctx context.Context, videoPageURL string) (videoURL, audioURL string, err error)
+}
+
+// VideoInfo holds pre-flight facts about a video.
+type VideoInfo struct {
+	DurationSeconds float64
+	EstimatedBytes  int64 // 0 if unknown
+	LiveStatus      LiveStatus
+}
+
+// AvailabilityChecker confirms that a video exists, more cheaply than
+// scraping or resolving it.
+type AvailabilityChecker interface {
+	// CheckAvailable returns ErrVideoUnavailable (or an UnavailableError)
+	// for a video the platform doesn't serve, nil for one it does, and any
+	// other error if it can't tell.
+	CheckAvailable(ctx context.Context, videoPageURL string) error
+}
+
+// Prober is implemented by resolvers that can report a video's duration and
+// size without downloading it.
+type Prober interface {
+	Probe(ctx context.Context, videoPageURL string) (*VideoInfo, error)
+}
+
+// StoryboardImage is one sprite sheet of a storyboard: a Columns x Rows grid
+// of Width x Height preview frames.
+type StoryboardImage struct {
+	FormatID        string
+	Index           int
+	URL             string
+	Headers         map[string]string
+	Width           int
+	Height          int
+	Columns         int
+	Rows            int
+	DurationSeconds float64 // Span of the video covered by this sheet
+}
+
+// StoryboardLister is implemented by resolvers that can list a video's
+// storyboard sprite sheets.
+type StoryboardLister interface {
+	GetStoryboards(ctx context.Context, videoPageURL string) ([]StoryboardImage, error)
+}
+
+// SubtitleTrack is a video's subtitles in one language, as WebVTT.
+type SubtitleTrack struct {
+	Language  string // e.g. "en", "pt-BR", or "en-orig" for captions in the original language
+	URL       string
+	Automatic bool // Auto-generated captions rather than uploaded subtitles
+}
+
+// SubtitleLister is implemented by resolvers that can list a video's
+// subtitle tracks.
+type SubtitleLister interface {
+	// GetSubtitles returns the video's WebVTT tracks, best first: uploaded
+	// subtitles before automatic captions, the video's own language first.
+	// Returns an empty slice if there are none.
+	GetSubtitles(ctx context.Context, videoPageURL string) ([]SubtitleTrack, error)
+}
+
+// ChannelVideo is one entry of a channel listing.
+type ChannelVideo struct {
+	ID  string
+	URL string
+}
+
+// ChannelListing is the videos of a channel (or playlist). ChannelID is
+// stable across channel renames and handle changes.
+type ChannelListing struct {
+	ChannelID string
+	Title     string
+	Videos    []ChannelVideo
+}
+
+// ChannelLister is implemented by resolvers that can list a channel's videos
+// without resolving each one.
+type ChannelLister interface {
+	ListChannel(ctx context.Context, channelURL string) (*ChannelListing, error)
+}
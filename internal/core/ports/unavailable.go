@@ -0,0 +1,57 @@
+package ports
+
+import (
+	"errors"
+	"fmt"
+)
+
+// UnavailableReason categorizes why a platform won't serve a video.
+type UnavailableReason string
+
+const (
+	ReasonRemoved       UnavailableReason = "removed" // Deleted, taken down, or never existed
+	ReasonPrivate       UnavailableReason = "private"
+	ReasonMembersOnly   UnavailableReason = "members_only"
+	ReasonAgeRestricted UnavailableReason = "age_restricted"
+	ReasonGeoBlocked    UnavailableReason = "geo_blocked"
+)
+
+// UnavailableError reports a video the platform refuses to serve, and why.
+// It matches ErrVideoUnavailable with errors.Is.
+type UnavailableError struct {
+	Reason UnavailableReason
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("%s (%s)", ErrVideoUnavailable, e.Reason)
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrVideoUnavailable
+}
+
+// Retryable is always false: repeating the request with the same cookies
+// and network gets the same answer. See NeedsCookies for the reasons a
+// later run can overcome.
+func (e *UnavailableError) Retryable() bool {
+	return false
+}
+
+// NeedsCookies reports whether the video can be fetched with suitable
+// account cookies: age-restricted and members-only videos.
+func (e *UnavailableError) NeedsCookies() bool {
+	return e.Reason == ReasonAgeRestricted || e.Reason == ReasonMembersOnly
+}
+
+// UnavailableReasonOf returns the reason carried by err, if it wraps an
+// UnavailableError. Plain ErrVideoUnavailable errors report ReasonRemoved.
+func UnavailableReasonOf(err error) (UnavailableReason, bool) {
+	var unavailable *UnavailableError
+	if errors.As(err, &unavailable) {
+		return unavailable.Reason, true
+	}
+	if errors.Is(err, ErrVideoUnavailable) {
+		return ReasonRemoved, true
+	}
+	return "", false
+}
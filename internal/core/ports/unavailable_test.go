@@ -0,0 +1,61 @@
+package ports
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestUnavailableError(t *testing.T) {
+	tests := []struct {
+		reason       UnavailableReason
+		needsCookies bool
+	}{
+		{ReasonRemoved, false},
+		{ReasonPrivate, false},
+		{ReasonMembersOnly, true},
+		{ReasonAgeRestricted, true},
+		{ReasonGeoBlocked, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.reason), func(t *testing.T) {
+			err := fmt.Errorf("scrape: %w", &UnavailableError{Reason: tt.reason})
+			if !errors.Is(err, ErrVideoUnavailable) {
+				t.Errorf("%v doesn't match ErrVideoUnavailable", err)
+			}
+			var unavailable *UnavailableError
+			if !errors.As(err, &unavailable) {
+				t.Fatalf("%v isn't an UnavailableError", err)
+			}
+			// The same request gets the same answer
+			if unavailable.Retryable() {
+				t.Errorf("%s is retryable", tt.reason)
+			}
+			if got := unavailable.NeedsCookies(); got != tt.needsCookies {
+				t.Errorf("NeedsCookies() = %v, want %v", got, tt.needsCookies)
+			}
+			if reason, ok := UnavailableReasonOf(err); !ok || reason != tt.reason {
+				t.Errorf("UnavailableReasonOf = %q, %v; want %q", reason, ok, tt.reason)
+			}
+		})
+	}
+}
+
+func TestUnavailableReasonOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   UnavailableReason
+		wantOK bool
+	}{
+		{"plain unavailable", fmt.Errorf("oembed: %w", ErrVideoUnavailable), ReasonRemoved, true},
+		{"private", &UnavailableError{Reason: ReasonPrivate}, ReasonPrivate, true},
+		{"other error", errors.New("connection reset"), "", false},
+		{"nil", nil, "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := UnavailableReasonOf(tt.err); got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: UnavailableReasonOf = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package ports
+
+import (
+	"net/url"
+	"strings"
+)
+
+// YouTubeVideoID returns the YouTube video ID from watch, youtu.be, embed,
+// live and shorts URLs (including music.youtube.com and
+// youtube-nocookie.com), or "" for anything else.
+func YouTubeVideoID(videoURL string) string {
+	u, err := url.Parse(videoURL)
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(u.Hostname())
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if host == "youtu.be" {
+		return segments[0]
+	}
+	if !strings.Contains(host, "youtube.com") && !strings.Contains(host, "youtube-nocookie.com") {
+		return ""
+	}
+	if len(segments) >= 2 {
+		switch segments[0] {
+		case "embed", "live", "shorts", "v":
+			return segments[1]
+		}
+	}
+	return u.Query().Get("v")
+}
@@ -0,0 +1,28 @@
+package ports
+
+import "testing"
+
+func TestYouTubeVideoID(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PL1", "dQw4w9WgXcQ"},
+		{"https://m.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://music.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ?t=10", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/shorts/abc123", "abc123"},
+		{"https://www.youtube.com/embed/abc123", "abc123"},
+		{"https://www.youtube.com/live/abc123", "abc123"},
+		{"https://www.youtube-nocookie.com/embed/abc123", "abc123"},
+		{"https://www.youtube.com/@channel", ""},
+		{"https://www.tiktok.com/@user/video/123?v=1", ""},
+		{"::not a url", ""},
+	}
+	for _, tt := range tests {
+		if got := YouTubeVideoID(tt.url); got != tt.want {
+			t.Errorf("YouTubeVideoID(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
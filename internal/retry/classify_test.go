@@ -0,0 +1,140 @@
+package retry
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestShouldRetryStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusForbidden, false},
+		{http.StatusNotFound, false},
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooEarly, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusNotImplemented, false},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusGatewayTimeout, true},
+	}
+	for _, tt := range tests {
+		if got := ShouldRetryStatus(tt.code); got != tt.want {
+			t.Errorf("ShouldRetryStatus(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+// classified has its own say on retrying.
+type classified bool
+
+func (c classified) Error() string   { return "classified" }
+func (c classified) Retryable() bool { return bool(c) }
+
+// timeoutError is a net.Error that timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"unrecognized", errors.New("boom"), false},
+		{"canceled", context.Canceled, false},
+		{"wrapped canceled", fmt.Errorf("fetch: %w", context.Canceled), false},
+		{"deadline", context.DeadlineExceeded, true},
+		{"retryable status", &StatusError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"permanent status", &StatusError{StatusCode: http.StatusNotFound}, false},
+		{"wrapped status", fmt.Errorf("apify: %w", &StatusError{StatusCode: http.StatusTooManyRequests}), true},
+		{"classified retryable", fmt.Errorf("x: %w", classified(true)), true},
+		{"classified permanent", classified(false), false},
+		{"unexpected EOF", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"EOF", io.EOF, true},
+		{"connection reset", &net.OpError{Op: "read", Err: syscall.ECONNRESET}, true},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{"broken pipe", syscall.EPIPE, true},
+		{"dial failure", &net.OpError{Op: "dial", Err: errors.New("no route to host")}, true},
+		{"timeout", &url.Error{Op: "Get", URL: "https://x", Err: timeoutError{}}, true},
+		{"unknown host", &net.DNSError{Err: "no such host", Name: "x.invalid", IsNotFound: true}, false},
+		{"unknown host in a dial", &net.OpError{Op: "dial", Err: &net.DNSError{Name: "x.invalid", IsNotFound: true}}, false},
+		{"dns timeout", &net.DNSError{Err: "timeout", Name: "x", IsTimeout: true}, true},
+		{"unknown authority", &url.Error{Op: "Get", URL: "https://x", Err: x509.UnknownAuthorityError{}}, false},
+		{"hostname mismatch", x509.HostnameError{Host: "x"}, false},
+		{"pinned cert mismatch", fmt.Errorf("tls: %w", ports.ErrCertMismatch), false},
+		{"age restricted", fmt.Errorf("yt-dlp: %w", &ports.UnavailableError{Reason: ports.ReasonAgeRestricted}), false},
+		{"members only", &ports.UnavailableError{Reason: ports.ReasonMembersOnly}, false},
+		{"too many redirects", &url.Error{Op: "Get", URL: "https://x", Err: errors.New("stopped after 10 redirects")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		header   string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"0", 0, 0},
+		{"5", 5 * time.Second, 5 * time.Second},
+		{"-1", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), 58 * time.Second, time.Minute},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		if got := ParseRetryAfter(tt.header); got < tt.min || got > tt.max {
+			t.Errorf("ParseRetryAfter(%q) = %v, want within [%v, %v]", tt.header, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestNewStatusError(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": {"7"}},
+		Body:       io.NopCloser(strings.NewReader("  slow down\n" + strings.Repeat("x", 2*maxErrorBody))),
+	}
+	err := NewStatusError(resp)
+	if err.StatusCode != http.StatusTooManyRequests || err.RetryAfter != 7*time.Second {
+		t.Errorf("got %d after %v, want 429 after 7s", err.StatusCode, err.RetryAfter)
+	}
+	if !strings.HasPrefix(err.Body, "slow down") || len(err.Body) > maxErrorBody {
+		t.Errorf("body = %.20q (%d bytes), want it trimmed and cut at %d", err.Body, len(err.Body), maxErrorBody)
+	}
+	if RetryAfterOf(fmt.Errorf("scrape: %w", err)) != 7*time.Second {
+		t.Errorf("RetryAfterOf a wrapped StatusError = %v, want 7s", RetryAfterOf(err))
+	}
+	if got := (&StatusError{StatusCode: 502}).Error(); got != "unexpected status code: 502" {
+		t.Errorf("Error() = %q", got)
+	}
+}
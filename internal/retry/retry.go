@@ -0,0 +1,139 @@
+// Package retry runs operations against flaky network services with
+// exponential backoff, jitter and Retry-After support, and classifies which
+// failures are worth retrying, so every adapter retries the same way.
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy controls how Do retries. Zero fields fall back as documented, so a
+// zero Policy tries once.
+type Policy struct {
+	// MaxAttempts is the total number of tries, including the first; values
+	// below 1 mean 1.
+	MaxAttempts int
+
+	// Initial is the delay before the first retry. Later delays grow by
+	// Multiplier (2 if unset; 1 keeps the delay constant), up to Max when
+	// Max is set.
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+
+	// Jitter randomizes each delay within ±Jitter of it (e.g. 0.2 for
+	// ±20%), so clients that failed together don't retry together. It is
+	// clamped to [0, 1].
+	Jitter float64
+
+	// MaxRetryAfter caps the wait a server asks for with Retry-After; 0
+	// means no cap beyond the context's.
+	MaxRetryAfter time.Duration
+
+	// Retryable decides whether a failure is retried; IsRetryable if nil.
+	Retryable func(error) bool
+
+	// OnRetry, if set, is called before each wait with the attempt that
+	// failed (1-based), its error and the wait.
+	OnRetry func(attempt int, err error, wait time.Duration)
+}
+
+// DefaultPolicy suits idempotent requests to an API: three tries, 1s then
+// 2s apart (±20%).
+var DefaultPolicy = Policy{
+	MaxAttempts:   3,
+	Initial:       time.Second,
+	Max:           30 * time.Second,
+	Multiplier:    2,
+	Jitter:        0.2,
+	MaxRetryAfter: time.Minute,
+}
+
+// randFloat returns a number in [0, 1); tests may replace it.
+var randFloat = rand.Float64
+
+// Backoff returns the delay before retrying after the given failed attempt
+// (1-based), before jitter: Initial * Multiplier^(attempt-1), capped at Max.
+func (p Policy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	} else if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.Initial)
+	for i := 1; i < attempt; i++ {
+		delay *= multiplier
+		if p.Max > 0 && delay >= float64(p.Max) {
+			return p.Max
+		}
+	}
+	if p.Max > 0 && delay > float64(p.Max) {
+		return p.Max
+	}
+	return time.Duration(delay)
+}
+
+// wait returns how long to wait after the given failed attempt: the
+// server's Retry-After if err carries one, else the jittered Backoff.
+func (p Policy) wait(attempt int, err error) time.Duration {
+	if after := RetryAfterOf(err); after > 0 {
+		if p.MaxRetryAfter > 0 && after > p.MaxRetryAfter {
+			return p.MaxRetryAfter
+		}
+		return after
+	}
+
+	delay := p.Backoff(attempt)
+	jitter := min(max(p.Jitter, 0), 1)
+	if jitter == 0 || delay <= 0 {
+		return delay
+	}
+	factor := 1 - jitter + 2*jitter*randFloat()
+	return time.Duration(float64(delay) * factor)
+}
+
+// Do calls fn until it succeeds, fails with an error the policy doesn't
+// retry, or MaxAttempts is reached, and returns fn's last error. If ctx ends
+// while waiting to retry, ctx.Err() is returned instead.
+func Do(ctx context.Context, policy Policy, fn func() error) error {
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || attempt >= policy.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		wait := policy.wait(attempt, err)
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, wait)
+		}
+		if err := Sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Sleep waits for d or until ctx is done, returning ctx.Err() in that case.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,229 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// pinRand makes randFloat return v for the rest of the test.
+func pinRand(t *testing.T, v float64) {
+	t.Helper()
+	saved := randFloat
+	randFloat = func() float64 { return v }
+	t.Cleanup(func() { randFloat = saved })
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		attempt int
+		want    time.Duration
+	}{
+		{"first retry", Policy{Initial: time.Second, Multiplier: 2}, 1, time.Second},
+		{"doubles", Policy{Initial: time.Second, Multiplier: 2}, 3, 4 * time.Second},
+		{"capped", Policy{Initial: time.Second, Multiplier: 2, Max: 5 * time.Second}, 4, 5 * time.Second},
+		{"stays capped", Policy{Initial: time.Second, Multiplier: 2, Max: 5 * time.Second}, 60, 5 * time.Second},
+		{"initial above max", Policy{Initial: time.Minute, Max: 5 * time.Second}, 1, 5 * time.Second},
+		{"unset multiplier doubles", Policy{Initial: time.Second}, 2, 2 * time.Second},
+		{"multiplier below 1 is constant", Policy{Initial: time.Second, Multiplier: 0.5}, 5, time.Second},
+		{"multiplier 1 is constant", Policy{Initial: time.Second, Multiplier: 1}, 5, time.Second},
+		{"triples", Policy{Initial: 100 * time.Millisecond, Multiplier: 3}, 3, 900 * time.Millisecond},
+		{"no initial", Policy{Multiplier: 2}, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.attempt); got != tt.want {
+				t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitJitter(t *testing.T) {
+	policy := Policy{Initial: time.Second, Jitter: 0.2}
+	tests := []struct {
+		name   string
+		policy Policy
+		rand   float64
+		want   time.Duration
+	}{
+		{"low end", policy, 0, 800 * time.Millisecond},
+		{"middle", policy, 0.5, time.Second},
+		{"high end", policy, 0.999999, 1199999600 * time.Nanosecond},
+		{"no jitter", Policy{Initial: time.Second}, 0, time.Second},
+		{"negative jitter is none", Policy{Initial: time.Second, Jitter: -1}, 0, time.Second},
+		{"jitter clamped to 1", Policy{Initial: time.Second, Jitter: 3}, 0.75, 1500 * time.Millisecond},
+		{"no delay", Policy{Jitter: 0.2}, 0.999999, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pinRand(t, tt.rand)
+			if got := tt.policy.wait(1, errors.New("boom")); got != tt.want {
+				t.Errorf("wait = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitJitterBounds(t *testing.T) {
+	policy := Policy{Initial: time.Second, Multiplier: 2, Jitter: 0.2}
+	for attempt := 1; attempt <= 4; attempt++ {
+		base := policy.Backoff(attempt)
+		low, high := time.Duration(float64(base)*0.8), time.Duration(float64(base)*1.2)
+		for i := 0; i < 1000; i++ {
+			if got := policy.wait(attempt, errors.New("boom")); got < low || got >= high {
+				t.Fatalf("attempt %d: wait %v outside [%v, %v)", attempt, got, low, high)
+			}
+		}
+	}
+}
+
+func TestWaitRetryAfter(t *testing.T) {
+	pinRand(t, 0)
+	policy := Policy{Initial: time.Second, Jitter: 0.2, MaxRetryAfter: 10 * time.Second}
+	tests := []struct {
+		name string
+		err  error
+		want time.Duration
+	}{
+		{"server's wait", &StatusError{StatusCode: 429, RetryAfter: 3 * time.Second}, 3 * time.Second},
+		{"wrapped", errors.Join(errors.New("scrape"), &StatusError{StatusCode: 503, RetryAfter: 2 * time.Second}), 2 * time.Second},
+		{"capped", &StatusError{StatusCode: 429, RetryAfter: time.Hour}, 10 * time.Second},
+		{"none asked", &StatusError{StatusCode: 503}, 800 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.wait(1, tt.err); got != tt.want {
+				t.Errorf("wait = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	uncapped := Policy{Initial: time.Second}
+	if got := uncapped.wait(1, &StatusError{StatusCode: 429, RetryAfter: time.Hour}); got != time.Hour {
+		t.Errorf("uncapped wait = %v, want 1h", got)
+	}
+}
+
+var errTransient = &StatusError{StatusCode: 503}
+
+func TestDo(t *testing.T) {
+	errPermanent := errors.New("permanent")
+	tests := []struct {
+		name        string
+		maxAttempts int
+		errs        []error // fn's result per call; nil once exhausted
+		wantCalls   int
+		wantErr     error
+	}{
+		{"first try", 3, nil, 1, nil},
+		{"succeeds on retry", 3, []error{errTransient, errTransient}, 3, nil},
+		{"gives up", 3, []error{errTransient, errTransient, errTransient, errTransient}, 3, errTransient},
+		{"permanent", 3, []error{errPermanent}, 1, errPermanent},
+		{"transient then permanent", 3, []error{errTransient, errPermanent}, 2, errPermanent},
+		{"zero attempts tries once", 0, []error{errTransient}, 1, errTransient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var waits []int
+			policy := Policy{
+				MaxAttempts: tt.maxAttempts,
+				Initial:     time.Microsecond,
+				OnRetry:     func(attempt int, err error, wait time.Duration) { waits = append(waits, attempt) },
+			}
+			calls := 0
+			err := Do(context.Background(), policy, func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if err != tt.wantErr {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if len(waits) != tt.wantCalls-1 {
+				t.Errorf("OnRetry calls = %v, want %d", waits, tt.wantCalls-1)
+			}
+			for i, attempt := range waits {
+				if attempt != i+1 {
+					t.Errorf("OnRetry attempts = %v, want 1, 2, ...", waits)
+				}
+			}
+		})
+	}
+}
+
+func TestDoCustomRetryable(t *testing.T) {
+	errRetry := errors.New("retry me")
+	calls := 0
+	policy := Policy{MaxAttempts: 3, Retryable: func(err error) bool { return err == errRetry }}
+	err := Do(context.Background(), policy, func() error {
+		calls++
+		if calls == 1 {
+			return errRetry
+		}
+		return errTransient
+	})
+	if err != errTransient || calls != 2 {
+		t.Errorf("err = %v after %d calls, want the unclassified error after 2", err, calls)
+	}
+}
+
+func TestDoCancelledWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	policy := Policy{
+		MaxAttempts: 3,
+		Initial:     time.Hour,
+		OnRetry:     func(int, error, time.Duration) { cancel() },
+	}
+	calls := 0
+	start := time.Now()
+	err := Do(ctx, policy, func() error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do waited %v after cancellation", elapsed)
+	}
+}
+
+func TestDoStopsOnceContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := Do(ctx, Policy{MaxAttempts: 3}, func() error {
+		calls++
+		cancel()
+		return errTransient
+	})
+	// fn's own error, not a retry
+	if err != errTransient || calls != 1 {
+		t.Errorf("err = %v after %d calls, want fn's error after 1", err, calls)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	if err := Sleep(context.Background(), 0); err != nil {
+		t.Errorf("Sleep(0) = %v", err)
+	}
+	if err := Sleep(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Sleep(1ms) = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Sleep on a done context = %v, want context.Canceled", err)
+	}
+}
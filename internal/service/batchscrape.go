@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// prescrapedKey carries a job's result from a batched scrape.
+type prescrapedKey struct{}
+
+// prescraped is a batched scrape result waiting for its job. It is taken
+// once, so a retried attempt scrapes afresh.
+type prescraped struct {
+	mu     sync.Mutex
+	result *ports.ScrapeResult
+}
+
+// withPrescraped makes the job's scrape return result instead of calling
+// the scraper.
+func withPrescraped(ctx context.Context, result *ports.ScrapeResult) context.Context {
+	return context.WithValue(ctx, prescrapedKey{}, &prescraped{result: result})
+}
+
+// takePrescraped returns the job's batched scrape result, or nil if it has
+// none or it was already taken.
+func takePrescraped(ctx context.Context) *ports.ScrapeResult {
+	p, _ := ctx.Value(prescrapedKey{}).(*prescraped)
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result := p.result
+	p.result = nil
+	return result
+}
+
+// hasPrescraped reports whether the job's batched scrape result is still
+// waiting to be taken.
+func hasPrescraped(ctx context.Context) bool {
+	p, _ := ctx.Value(prescrapedKey{}).(*prescraped)
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.result != nil
+}
+
+// prescrape scrapes a window of the batch's URLs just before their jobs
+// start, when the scraper can cover several in one request (see
+// ports.BatchScraper), returning a result per item or nil. Windows are
+// sized to the worker pool, so short-lived video URLs (TikTok's) don't
+// expire while earlier jobs download, and take a scrape slot like any
+// scrape. Items it didn't cover, or all of them if it fails, are scraped by
+// their jobs as usual.
+func (o *Orchestrator) prescrape(ctx context.Context, items []BatchItem) []*ports.ScrapeResult {
+	batcher, ok := o.scraper.(ports.BatchScraper)
+	if !ok || len(items) < 2 {
+		return nil
+	}
+
+	var urls []string
+	var indexes []int
+	for i, item := range items {
+		// Jobs that skip the scrape mustn't pay for one
+		if item.VideoURL != "" || o.opts.SkipMetadata && usesYtDlp(detectPlatform(item.URL)) || o.ignoreReason(item.URL, "") != "" {
+			continue
+		}
+		urls = append(urls, item.URL)
+		indexes = append(indexes, i)
+	}
+	if len(urls) < 2 {
+		return nil
+	}
+
+	if err := o.scrapeSlots.acquire(ctx); err != nil {
+		return nil
+	}
+	scraped, err := batcher.ScrapeBatch(ctx, urls)
+	o.scrapeSlots.release()
+	if err != nil {
+		o.logger.Printf("WARNING: batched scrape failed, affected jobs will scrape on their own: %v", err)
+	}
+
+	results := make([]*ports.ScrapeResult, len(items))
+	covered := 0
+	for j, result := range scraped {
+		if result != nil {
+			results[indexes[j]] = result
+			covered++
+		}
+	}
+	if covered > 0 {
+		o.logger.Printf("Scraped %d of %d batch URLs in a batched run", covered, len(items))
+	}
+	return results
+}
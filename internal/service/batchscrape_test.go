@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// fakeBatchScraper is a fakeScraper that also scrapes batches, recording
+// each batch's URLs.
+type fakeBatchScraper struct {
+	fakeScraper
+	batchMu sync.Mutex
+	batches [][]string
+}
+
+func (f *fakeBatchScraper) ScrapeBatch(ctx context.Context, urls []string) ([]*ports.ScrapeResult, error) {
+	f.batchMu.Lock()
+	f.batches = append(f.batches, urls)
+	f.batchMu.Unlock()
+	results := make([]*ports.ScrapeResult, len(urls))
+	for i, url := range urls {
+		id := url[strings.LastIndex(url, "/")+1:]
+		results[i] = &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/" + id + ".mp4"}
+	}
+	return results, nil
+}
+
+func TestPrescrapeInWorkerWindows(t *testing.T) {
+	scraper := &fakeBatchScraper{}
+	files := map[string]string{}
+	var urls []string
+	for _, id := range []string{"1", "2", "3", "4", "5"} {
+		urls = append(urls, "https://www.tiktok.com/@user/video/"+id)
+		files["https://cdn/"+id+".mp4"] = "video " + id
+	}
+	scraper.byURL = map[string]*ports.ScrapeResult{urls[4]: {RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/5.mp4"}}
+	o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: files}, nil, Options{})
+
+	for _, r := range o.RunJobs(context.Background(), urls, 2, 1) {
+		if r.Err != nil {
+			t.Fatalf("%s: %v", r.URL, r.Err)
+		}
+	}
+
+	// The last window's lone URL is scraped by its job
+	want := [][]string{urls[0:2], urls[2:4]}
+	if len(scraper.batches) != len(want) {
+		t.Fatalf("batches = %q, want %q", scraper.batches, want)
+	}
+	for i := range want {
+		if strings.Join(scraper.batches[i], " ") != strings.Join(want[i], " ") {
+			t.Errorf("batch %d = %q, want %q", i, scraper.batches[i], want[i])
+		}
+	}
+	if len(scraper.calls) != 1 || scraper.calls[0] != urls[4] {
+		t.Errorf("single scrapes = %q, want just %s", scraper.calls, urls[4])
+	}
+}
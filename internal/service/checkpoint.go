@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+
+	"scrapeanddown/internal/core/domain"
+)
+
+// batchIDKey is the context key for the ResumeBatch batch a job belongs to.
+type batchIDKey struct{}
+
+// ResumeBatch is RunBatch for a named batch (e.g. an input file) that may
+// have been interrupted before: entries Options.Checkpoint recorded as
+// completed under batchID are skipped, and every entry that succeeds is
+// recorded as it completes. Call ClearBatch once the batch is done with, or
+// before it to re-run every entry.
+func (o *Orchestrator) ResumeBatch(ctx context.Context, batchID string, items []BatchItem, workers, maxAttempts int) []BatchResult {
+	if o.opts.Checkpoint == nil {
+		return o.RunBatch(ctx, items, workers, maxAttempts)
+	}
+
+	completed, err := o.opts.Checkpoint.Completed(ctx, batchID)
+	if err != nil {
+		o.logger.Printf("WARNING: failed to read checkpoint for %s, running every URL: %v", batchID, err)
+	}
+
+	results := make([]BatchResult, len(items))
+	var pending []BatchItem
+	var indexes []int
+	for i, item := range items {
+		if _, ok := completed[checkpointKey(item)]; ok {
+			results[i] = BatchResult{URL: item.URL, Skipped: true}
+			continue
+		}
+		pending = append(pending, item)
+		indexes = append(indexes, i)
+	}
+	if skipped := len(items) - len(pending); skipped > 0 {
+		o.logger.Printf("Skipping %d URLs of %s completed by an earlier run", skipped, batchID)
+	}
+	if len(pending) == 0 {
+		return results
+	}
+
+	ctx = context.WithValue(ctx, batchIDKey{}, batchID)
+	for j, r := range o.RunBatch(ctx, pending, workers, maxAttempts) {
+		results[indexes[j]] = r
+	}
+	return results
+}
+
+// ClearBatch forgets which entries of batchID have completed.
+func (o *Orchestrator) ClearBatch(ctx context.Context, batchID string) error {
+	if o.opts.Checkpoint == nil {
+		return nil
+	}
+	return o.opts.Checkpoint.Clear(ctx, batchID)
+}
+
+// checkpoint records a completed job of a ResumeBatch batch.
+func (o *Orchestrator) checkpoint(ctx context.Context, item BatchItem, result *domain.JobResult) {
+	batchID, _ := ctx.Value(batchIDKey{}).(string)
+	if batchID == "" || o.opts.Checkpoint == nil {
+		return
+	}
+	// Record the job even if the batch is being cancelled, or it reruns
+	if err := o.opts.Checkpoint.MarkCompleted(context.WithoutCancel(ctx), batchID, checkpointKey(item), result.Job.ID); err != nil {
+		o.logger.Printf("[JOB %s] WARNING: failed to checkpoint: %v", result.Job.ID, err)
+	}
+}
+
+// checkpointKey identifies a batch entry by the video it names, so the
+// key survives the URL being written differently in a re-run.
+func checkpointKey(item BatchItem) string {
+	key := canonicalURL(item.URL)
+	if item.ExternalID != "" {
+		key += " " + item.ExternalID
+	}
+	if item.AudioOnly {
+		key += " audio"
+	} else if item.Quality != "" {
+		key += " " + item.Quality
+	}
+	return key
+}
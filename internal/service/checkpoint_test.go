@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrapeanddown/internal/adapters/checkpoint"
+	"scrapeanddown/internal/core/ports"
+)
+
+// tiktokItems returns batch items for TikTok videos with the given IDs,
+// and a downloader serving each as https://cdn/<id>.mp4.
+func tiktokItems(ids ...string) ([]BatchItem, *fakeScraper, *fakeDownloader) {
+	scraper := &fakeScraper{byURL: map[string]*ports.ScrapeResult{}}
+	downloader := &fakeDownloader{files: map[string]string{}}
+	var items []BatchItem
+	for _, id := range ids {
+		u := "https://www.tiktok.com/@user/video/" + id
+		items = append(items, BatchItem{URL: u})
+		scraper.byURL[u] = &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/" + id + ".mp4"}
+		downloader.files["https://cdn/"+id+".mp4"] = "video " + id
+	}
+	return items, scraper, downloader
+}
+
+// An interrupted batch resumed from its checkpoint only runs the entries
+// that didn't complete.
+func TestResumeBatchAfterInterruption(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "batch_checkpoint.json")
+	items, scraper, downloader := tiktokItems("1", "2", "3", "4")
+
+	// The first run is stopped while scraping the second video
+	drain := make(chan struct{})
+	var once sync.Once
+	interrupting := scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+		if strings.HasSuffix(url, "/2") {
+			once.Do(func() { close(drain) })
+		}
+		return scraper.Scrape(ctx, url)
+	})
+	o, _ := newTestOrchestrator(t, interrupting, downloader, nil, Options{Checkpoint: checkpoint.NewJSONCheckpoint(path)})
+	results := o.ResumeBatch(WithDrain(context.Background(), drain), "urls.txt", items, 1, 1)
+	for i, r := range results {
+		if wantErr := i >= 2; (r.Err != nil) != wantErr {
+			t.Fatalf("first run result %d = %v, want an error: %v", i, r.Err, wantErr)
+		}
+	}
+
+	items, scraper, downloader = tiktokItems("1", "2", "3", "4")
+	o, _ = newTestOrchestrator(t, scraper, downloader, nil, Options{Checkpoint: checkpoint.NewJSONCheckpoint(path)})
+	results = o.ResumeBatch(context.Background(), "urls.txt", items, 2, 1)
+	for i, r := range results {
+		if wantSkipped := i < 2; r.Skipped != wantSkipped || r.Err != nil {
+			t.Errorf("resumed result %d = skipped %v, %v; want skipped %v", i, r.Skipped, r.Err, wantSkipped)
+		}
+	}
+	slices.Sort(scraper.calls)
+	if want := []string{items[2].URL, items[3].URL}; !slices.Equal(scraper.calls, want) {
+		t.Errorf("resumed run scraped %v, want %v", scraper.calls, want)
+	}
+
+	// Once cleared, the batch runs in full again
+	if err := o.ClearBatch(context.Background(), "urls.txt"); err != nil {
+		t.Fatal(err)
+	}
+	scraper.calls = nil
+	for i, r := range o.ResumeBatch(context.Background(), "urls.txt", items, 2, 1) {
+		if r.Skipped || r.Err != nil {
+			t.Errorf("result %d after ClearBatch = skipped %v, %v", i, r.Skipped, r.Err)
+		}
+	}
+	if len(scraper.calls) != 4 {
+		t.Errorf("run after ClearBatch scraped %v, want every URL", scraper.calls)
+	}
+}
+
+// A corrupt checkpoint runs every entry, and is replaced by one recording
+// this run.
+func TestResumeBatchCorruptCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "batch_checkpoint.json")
+	if err := os.WriteFile(path, []byte(`{"urls.txt": {"https://www.tiktok.com/@user/video/1": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	items, scraper, downloader := tiktokItems("1", "2")
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{Checkpoint: checkpoint.NewJSONCheckpoint(path)})
+
+	for i, r := range o.ResumeBatch(context.Background(), "urls.txt", items, 1, 1) {
+		if r.Skipped || r.Err != nil {
+			t.Errorf("result %d with a corrupt checkpoint = skipped %v, %v", i, r.Skipped, r.Err)
+		}
+	}
+	if len(scraper.calls) != 2 {
+		t.Errorf("scraped %v, want every URL", scraper.calls)
+	}
+
+	scraper.calls = nil
+	for i, r := range o.ResumeBatch(context.Background(), "urls.txt", items, 1, 1) {
+		if !r.Skipped {
+			t.Errorf("result %d of the re-run = %+v, want skipped", i, r)
+		}
+	}
+	if len(scraper.calls) != 0 {
+		t.Errorf("re-run scraped %v, want nothing", scraper.calls)
+	}
+}
+
+// A resumed job stream reports completed specs as skipped and runs the
+// rest, including those that failed before.
+func TestResumeJobStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "batch_checkpoint.json")
+	input := strings.Join([]string{
+		`{"url": "https://www.tiktok.com/@user/video/1"}`,
+		`{"url": "https://www.tiktok.com/@user/video/fail"}`,
+		`{"url": "https://www.tiktok.com/@user/video/2", "quality": "720p"}`,
+	}, "\n")
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn/1.mp4": "video 1", "https://cdn/2.mp4": "video 2"}}
+	o, _ := newTestOrchestrator(t, streamScraper(), downloader, nil, Options{Checkpoint: checkpoint.NewJSONCheckpoint(path)})
+
+	run := func() map[int]JobStreamLine {
+		t.Helper()
+		var out strings.Builder
+		failed, err := o.ResumeJobStream(context.Background(), "stdin", strings.NewReader(input), &out, 2, 1)
+		if err != nil || failed != 1 {
+			t.Fatalf("ResumeJobStream = %d, %v; want 1 failed line", failed, err)
+		}
+		return decodeStreamLines(t, out.String())
+	}
+
+	for n, line := range run() {
+		if line.Skipped || (line.Error != "") != (n == 2) {
+			t.Errorf("first run line %d = %+v", n, line)
+		}
+	}
+	downloader.calls = nil
+	lines := run()
+	for n, line := range lines {
+		if wantSkipped := n != 2; line.Skipped != wantSkipped || line.Skipped && line.Result != nil {
+			t.Errorf("resumed line %d = %+v, want skipped %v", n, line, wantSkipped)
+		}
+	}
+	if lines[1].URL != "https://www.tiktok.com/@user/video/1" {
+		t.Errorf("skipped line URL = %q", lines[1].URL)
+	}
+	if len(downloader.calls) != 0 {
+		t.Errorf("resumed run downloaded %v, want nothing", downloader.calls)
+	}
+}
+
+// A sync killed partway through doesn't run the jobs its checkpoint
+// recorded, still counts them as seen, and clears the checkpoint once the
+// channel state is saved.
+func TestSyncChannelResumesFromCheckpoint(t *testing.T) {
+	resolver := &fakeChannelResolver{
+		fakeResolver: fakeResolver{url: "https://cdn/v.mp4"},
+		listing: ports.ChannelListing{ChannelID: "UC1", Title: "Channel", Videos: []ports.ChannelVideo{
+			{ID: "aaaaaaaaaaa", URL: "https://www.youtube.com/watch?v=aaaaaaaaaaa"},
+			{ID: "bbbbbbbbbbb", URL: "https://www.youtube.com/watch?v=bbbbbbbbbbb"},
+		}},
+	}
+	state := &fakeChannelState{}
+	cp := checkpoint.NewJSONCheckpoint(filepath.Join(t.TempDir(), "batch_checkpoint.json"))
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, resolver,
+		Options{ChannelState: state, Checkpoint: cp})
+
+	// The earlier sync completed the first video before it was killed
+	first := []BatchItem{{URL: resolver.listing.Videos[0].URL}}
+	if r := o.ResumeBatch(context.Background(), "sync UC1", first, 1, 1); r[0].Err != nil {
+		t.Fatal(r[0].Err)
+	}
+	resolver.calls = nil
+
+	results, err := o.SyncChannel(context.Background(), "https://www.youtube.com/@channel", 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Skipped || results[1].Skipped || results[1].Err != nil {
+		t.Errorf("results = %+v, want the first skipped and the second run", results)
+	}
+	if !slices.Equal(resolver.calls, []string{resolver.listing.Videos[1].URL}) {
+		t.Errorf("resolved %v, want only the second video", resolver.calls)
+	}
+	if !state.seen["aaaaaaaaaaa"] || !state.seen["bbbbbbbbbbb"] {
+		t.Errorf("seen = %v, want both videos", state.seen)
+	}
+	if completed, err := cp.Completed(context.Background(), "sync UC1"); err != nil || len(completed) != 0 {
+		t.Errorf("checkpoint after the sync = %v, %v; want it cleared", completed, err)
+	}
+}
+
+// Without a checkpoint, ResumeBatch is RunBatch.
+func TestResumeBatchWithoutCheckpoint(t *testing.T) {
+	items, scraper, downloader := tiktokItems("1")
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{})
+	for range 2 {
+		if r := o.ResumeBatch(context.Background(), "urls.txt", items, 1, 1); r[0].Skipped || r[0].Err != nil {
+			t.Errorf("result = %+v", r[0])
+		}
+	}
+	if len(scraper.calls) != 2 {
+		t.Errorf("scraped %v, want the URL every run", scraper.calls)
+	}
+	if err := o.ClearBatch(context.Background(), "urls.txt"); err != nil {
+		t.Errorf("ClearBatch without a checkpoint: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// defaultVideoFile is the name of a job's video before withContainerExt
+// adjusts its extension.
+const defaultVideoFile = "video.mp4"
+
+// videoFileExts are the extensions a job's main video may be saved with.
+var videoFileExts = []string{"mp4", "webm", "mkv", "mov", "flv", "3gp"}
+
+// contentTypeExts maps download Content-Types to container extensions.
+var contentTypeExts = map[string]string{
+	"video/mp4":        "mp4",
+	"video/webm":       "webm",
+	"video/x-matroska": "mkv",
+	"video/quicktime":  "mov",
+	"video/x-flv":      "flv",
+	"video/3gpp":       "3gp",
+	"audio/mp4":        "m4a",
+	"audio/webm":       "webm",
+}
+
+// withContainerExt swaps filename's extension for the container's: the
+// resolver's ext if it reported a plausible one, else the one contentType
+// implies. Unknown containers (e.g. application/octet-stream) keep filename.
+func withContainerExt(filename, ext, contentType string) string {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	if !plausibleExt(ext) {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		ext = contentTypeExts[mediaType]
+	}
+	if ext == "" {
+		return filename
+	}
+	return strings.TrimSuffix(filename, path.Ext(filename)) + "." + ext
+}
+
+// plausibleExt reports whether ext looks like a file extension.
+func plausibleExt(ext string) bool {
+	if ext == "" || len(ext) > 5 {
+		return false
+	}
+	for _, r := range ext {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// isMainVideoFile reports whether filename is a job's main video (named
+// video.<ext> or by the OutputTemplate), as opposed to a rendition or
+// separate stream.
+func isMainVideoFile(filename string) bool {
+	base := strings.TrimSuffix(filename, path.Ext(filename))
+	if base == strings.TrimSuffix(videoOnlyFile, path.Ext(videoOnlyFile)) || base == strings.TrimSuffix(audioOnlyFile, path.Ext(audioOnlyFile)) {
+		return false
+	}
+	// Renditions are video_<height>p
+	height, ok := strings.CutPrefix(base, "video_")
+	if ok && strings.HasSuffix(height, "p") {
+		if _, err := strconv.Atoi(strings.TrimSuffix(height, "p")); err == nil {
+			return false
+		}
+	}
+	return true
+}
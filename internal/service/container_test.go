@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"scrapeanddown/internal/adapters/downloader"
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestWithContainerExt(t *testing.T) {
+	tests := []struct {
+		filename, ext, contentType, want string
+	}{
+		{"video.mp4", "webm", "video/mp4", "video.webm"},
+		{"video.mp4", ".MKV", "", "video.mkv"},
+		{"video.mp4", "", "video/webm", "video.webm"},
+		{"video.mp4", "", "video/quicktime; charset=binary", "video.mov"},
+		{"video.mp4", "not an ext!", "video/webm", "video.webm"},
+		{"video.mp4", "", "application/octet-stream", "video.mp4"},
+		{"video.mp4", "", "", "video.mp4"},
+		{"video_720p.mp4", "webm", "", "video_720p.webm"},
+		{"audio.m4a", "", "audio/webm", "audio.webm"},
+	}
+	for _, tt := range tests {
+		if got := withContainerExt(tt.filename, tt.ext, tt.contentType); got != tt.want {
+			t.Errorf("withContainerExt(%q, %q, %q) = %q, want %q", tt.filename, tt.ext, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestIsMainVideoFile(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     bool
+	}{
+		{"video.mp4", true},
+		{"video.webm", true},
+		{"My Video [abc].mkv", true},
+		{"video_720p.mp4", false},
+		{"video_720p.webm", false},
+		{"video_best.mp4", true},
+		{videoOnlyFile, false},
+		{audioOnlyFile, false},
+	}
+	for _, tt := range tests {
+		if got := isMainVideoFile(tt.filename); got != tt.want {
+			t.Errorf("isMainVideoFile(%q) = %v, want %v", tt.filename, got, tt.want)
+		}
+	}
+}
+
+// Without an extension from the resolver, the download's Content-Type names
+// the container.
+func TestRunJobSavesContentTypeExt(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/webm")
+		w.Write([]byte("webm video"))
+	}))
+	defer srv.Close()
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: srv.URL + "/v"}}
+	o, _ := newTestOrchestrator(t, scraper, downloader.NewHTTPDownloader(), nil, Options{})
+
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readJobFile(t, o, result.Job.ID, "video.webm"); got != "webm video" {
+		t.Errorf("video.webm = %q", got)
+	}
+	if filepath.Base(result.VideoPath) != "video.webm" {
+		t.Errorf("VideoPath = %s, want video.webm", result.VideoPath)
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Phases with their own time budget (see Options.JobTimeout).
+const (
+	PhaseJob      = "job"
+	PhaseScrape   = "scrape"
+	PhaseDownload = "download"
+)
+
+// DeadlineError reports which phase's time budget ran out. It is the cause
+// of a phase's context, and matches context.DeadlineExceeded.
+type DeadlineError struct {
+	Phase  string
+	Budget time.Duration
+}
+
+func (e *DeadlineError) Error() string {
+	return fmt.Sprintf("%s deadline exceeded after %s", e.Phase, e.Budget)
+}
+
+func (e *DeadlineError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// phaseContext bounds ctx by the phase's budget, if it has one.
+func phaseContext(ctx context.Context, phase string, budget time.Duration) (context.Context, context.CancelFunc) {
+	if budget <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, budget, &DeadlineError{Phase: phase, Budget: budget})
+}
+
+// attributeDeadline prefixes a bare "context deadline exceeded" error with
+// the budget of ctx, or of an enclosing phase, that ran out.
+func attributeDeadline(ctx context.Context, err error) error {
+	var deadline *DeadlineError
+	if err == nil || !errors.Is(err, context.DeadlineExceeded) || errors.As(err, &deadline) {
+		return err
+	}
+	if errors.As(context.Cause(ctx), &deadline) {
+		return fmt.Errorf("%w: %w", deadline, err)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestAttributeDeadline(t *testing.T) {
+	expired := func(cause error) context.Context {
+		ctx, cancel := context.WithDeadlineCause(context.Background(), time.Now().Add(-time.Second), cause)
+		t.Cleanup(cancel)
+		return ctx
+	}
+	scrapeExpired := expired(&DeadlineError{Phase: PhaseScrape, Budget: time.Minute})
+	bare := fmt.Errorf("fetching dataset: %w", context.DeadlineExceeded)
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want string
+	}{
+		{"nil", scrapeExpired, nil, ""},
+		{"other errors", scrapeExpired, errFake, errFake.Error()},
+		{"bare deadline", scrapeExpired, bare, "scrape deadline exceeded after 1m0s: fetching dataset: context deadline exceeded"},
+		{"already attributed", scrapeExpired, attributeDeadline(expired(&DeadlineError{Phase: PhaseDownload, Budget: time.Second}), bare),
+			"download deadline exceeded after 1s: fetching dataset: context deadline exceeded"},
+		{"no budget ran out", expired(nil), bare, bare.Error()},
+		{"live context", context.Background(), bare, bare.Error()},
+	}
+	for _, tt := range tests {
+		err := attributeDeadline(tt.ctx, tt.err)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("%s: attributeDeadline = %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.err != nil && errors.Is(tt.err, context.DeadlineExceeded) && !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: %v no longer matches context.DeadlineExceeded", tt.name, err)
+		}
+	}
+}
+
+// A job stopped by a budget says which one in its error message.
+func TestRunJobPhaseDeadlines(t *testing.T) {
+	const budget = 20 * time.Millisecond
+	hang := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return errors.New("the deadline never fired")
+		}
+	}
+	tests := []struct {
+		name        string
+		opts        Options
+		hangScrape  bool
+		wantMessage string
+	}{
+		{"scrape", Options{ScrapeTimeout: budget, DownloadTimeout: time.Hour, JobTimeout: time.Hour}, true, "scrape deadline exceeded after 20ms"},
+		{"download", Options{ScrapeTimeout: time.Hour, DownloadTimeout: budget, JobTimeout: time.Hour}, false, "download deadline exceeded after 20ms"},
+		{"job", Options{JobTimeout: budget}, true, "job deadline exceeded after 20ms"},
+		{"job, during the download", Options{ScrapeTimeout: time.Hour, JobTimeout: budget}, false, "job deadline exceeded after 20ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraper := scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+				if tt.hangScrape {
+					return nil, fmt.Errorf("waiting for run: %w", hang(ctx))
+				}
+				return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}, nil
+			})
+			downloader := downloadFunc(func(ctx context.Context, url string) (io.ReadCloser, error) {
+				return nil, hang(ctx)
+			})
+			o, _ := newTestOrchestrator(t, scraper, downloader, nil, tt.opts)
+
+			result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("RunJob err = %v, want context.DeadlineExceeded", err)
+			}
+			if !strings.Contains(result.ErrorMessage, tt.wantMessage) {
+				t.Errorf("ErrorMessage = %q, want %q", result.ErrorMessage, tt.wantMessage)
+			}
+		})
+	}
+}
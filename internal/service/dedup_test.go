@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"scrapeanddown/internal/adapters/contentindex"
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// Of two jobs downloading identical bytes, the second keeps a reference to
+// the first one's video instead of a copy.
+func TestDedupMarksDuplicate(t *testing.T) {
+	ctx := context.Background()
+	index := contentindex.NewJSONIndex(filepath.Join(t.TempDir(), "content_index.json"))
+	scraper := &fakeScraper{byURL: map[string]*ports.ScrapeResult{}}
+	downloader := &fakeDownloader{files: map[string]string{}}
+	for id, content := range map[string]string{"1": "same bytes", "2": "same bytes", "3": "other bytes"} {
+		scraper.byURL["https://www.tiktok.com/@user/video/"+id] = &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/" + id + ".mp4"}
+		downloader.files["https://cdn/"+id+".mp4"] = content
+	}
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{ContentIndex: index})
+
+	run := func(id string) *domain.JobResult {
+		t.Helper()
+		result, err := o.RunJob(ctx, "https://www.tiktok.com/@user/video/"+id)
+		if err != nil {
+			t.Fatalf("RunJob(%s): %v", id, err)
+		}
+		return result
+	}
+	first, second, other := run("1"), run("2"), run("3")
+
+	if first.DuplicateOf != "" || other.DuplicateOf != "" {
+		t.Errorf("DuplicateOf = %q, %q for unique videos, want none", first.DuplicateOf, other.DuplicateOf)
+	}
+	if second.DuplicateOf != first.Job.ID {
+		t.Fatalf("second job DuplicateOf = %q, want %s", second.DuplicateOf, first.Job.ID)
+	}
+	if second.VideoPath != first.VideoPath {
+		t.Errorf("second job VideoPath = %s, want the first job's %s", second.VideoPath, first.VideoPath)
+	}
+	if _, err := os.Stat(filepath.Join(o.storage.GetJobPath(second.Job.ID), "video.mp4")); !os.IsNotExist(err) {
+		t.Errorf("second job kept its own copy: %v", err)
+	}
+	if got := readJobFile(t, o, other.Job.ID, "video.mp4"); got != "other bytes" {
+		t.Errorf("third job's video = %q", got)
+	}
+}
+
+// A content index entry whose file is gone must not turn the next identical
+// video into a reference to nothing; that copy takes the entry over.
+func TestDedupSkipsMissingReference(t *testing.T) {
+	ctx := context.Background()
+	index := contentindex.NewJSONIndex(filepath.Join(t.TempDir(), "content_index.json"))
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "same bytes"}}
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{ContentIndex: index})
+
+	run := func(url string) (string, string) {
+		t.Helper()
+		result, err := o.RunJob(ctx, url)
+		if err != nil {
+			t.Fatalf("RunJob(%s): %v", url, err)
+		}
+		return result.Job.ID, result.DuplicateOf
+	}
+
+	first, _ := run("https://www.tiktok.com/@user/video/1")
+	if err := os.Remove(filepath.Join(o.storage.GetJobPath(first), "video.mp4")); err != nil {
+		t.Fatal(err)
+	}
+
+	second, duplicateOf := run("https://www.tiktok.com/@user/video/2")
+	if duplicateOf != "" {
+		t.Fatalf("second job is a duplicate of %s, whose video is gone", duplicateOf)
+	}
+	if _, err := os.Stat(filepath.Join(o.storage.GetJobPath(second), "video.mp4")); err != nil {
+		t.Errorf("second job's copy not kept: %v", err)
+	}
+
+	if _, duplicateOf := run("https://www.tiktok.com/@user/video/3"); duplicateOf != second {
+		t.Errorf("third job is a duplicate of %q, want the second job %s", duplicateOf, second)
+	}
+}
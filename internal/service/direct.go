@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+type directVideoURLKey struct{}
+
+// WithDirectVideoURL makes jobs started with the returned context download
+// videoURL, an already resolved media URL (e.g. a CDN link), as is: the
+// metadata scrape, preflight checks and URL resolution are skipped, and so
+// are the steps needing them (formats, storyboards, transcripts). The job's
+// own URL is still recorded as its page; RunJob may be given "" to use
+// videoURL for it.
+func WithDirectVideoURL(ctx context.Context, videoURL string) context.Context {
+	return context.WithValue(ctx, directVideoURLKey{}, videoURL)
+}
+
+// directVideoURLFrom returns the URL attached with WithDirectVideoURL, if any.
+func directVideoURLFrom(ctx context.Context) string {
+	videoURL, _ := ctx.Value(directVideoURLKey{}).(string)
+	return videoURL
+}
+
+// ValidateVideoURL checks that a direct video URL is an absolute http(s)
+// URL, failing with ports.ErrInvalidURL otherwise.
+func ValidateVideoURL(videoURL string) error {
+	u, err := url.Parse(videoURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ports.ErrInvalidURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %q is not an absolute http(s) url", ports.ErrInvalidURL, videoURL)
+	}
+	return nil
+}
+
+// errDirectURLExpired is the re-resolve result of a direct video URL that
+// expired: there is nothing to resolve it from.
+var errDirectURLExpired = fmt.Errorf("%w: a direct video url can't be re-resolved", ports.ErrURLExpired)
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrDraining is reported for work skipped because shutdown began.
+var ErrDraining = errors.New("skipped: shutting down")
+
+type drainKey struct{}
+
+// WithDrain returns a context carrying a drain signal. Once drain is closed
+// no new jobs or retries are started, but in-flight jobs run to completion;
+// cancelling ctx still aborts them.
+func WithDrain(ctx context.Context, drain <-chan struct{}) context.Context {
+	return context.WithValue(ctx, drainKey{}, drain)
+}
+
+// drainFrom returns the drain channel from ctx, or nil (never closed).
+func drainFrom(ctx context.Context) <-chan struct{} {
+	drain, _ := ctx.Value(drainKey{}).(<-chan struct{})
+	return drain
+}
+
+// draining reports whether shutdown has begun or ctx is done.
+func draining(ctx context.Context) bool {
+	select {
+	case <-drainFrom(ctx):
+		return true
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
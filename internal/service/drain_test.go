@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// drainingReader starts draining on its first read, then serves the rest
+// of the video a few bytes at a time.
+type drainingReader struct {
+	r     io.Reader
+	start func()
+}
+
+func (d *drainingReader) Read(p []byte) (int, error) {
+	d.start()
+	return d.r.Read(p[:min(len(p), 4)])
+}
+
+func (d *drainingReader) Close() error { return nil }
+
+// Draining lets the download in flight finish saving the whole video, and
+// starts no new jobs.
+func TestDrainFinishesInFlightWork(t *testing.T) {
+	const video = "a video that takes several reads"
+	items, scraper, _ := tiktokItems("1", "2", "3")
+	drain := make(chan struct{})
+	var once sync.Once
+	downloader := downloadFunc(func(ctx context.Context, videoURL string) (io.ReadCloser, error) {
+		return &drainingReader{r: strings.NewReader(video), start: func() { once.Do(func() { close(drain) }) }}, nil
+	})
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{})
+
+	results := o.RunBatch(WithDrain(context.Background(), drain), items, 1, 1)
+	if results[0].Err != nil {
+		t.Fatalf("in-flight job failed: %v", results[0].Err)
+	}
+	if got := readJobFile(t, o, results[0].Result.Job.ID, "video.mp4"); got != video {
+		t.Errorf("in-flight job saved %q, want the whole video", got)
+	}
+	for _, r := range results[1:] {
+		if !errors.Is(r.Err, ErrDraining) {
+			t.Errorf("%s after the drain = %v, want ErrDraining", r.URL, r.Err)
+		}
+	}
+	if len(scraper.calls) != 1 {
+		t.Errorf("scraped %v, want only the job in flight", scraper.calls)
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+)
+
+// Job event types.
+const (
+	EventJobStarted    = "job_started"    // Data: url, platform
+	EventStepCompleted = "step_completed" // Data: step
+	EventArtifactSaved = "artifact_saved" // Data: name, kind
+	EventJobFinished   = "job_finished"   // Data: success, and error or skipped and skip_reason
+)
+
+// eventBuffer is how many events a subscriber may fall behind by before it
+// is dropped.
+const eventBuffer = 64
+
+// JobEvent is a step in a job's progress, published on an EventBus.
+type JobEvent struct {
+	JobID string                 `json:"job_id"`
+	Type  string                 `json:"type"`
+	Data  map[string]interface{} `json:"data,omitempty"`
+	Time  time.Time              `json:"time"`
+}
+
+// EventBus fans job events out to subscribers, e.g. a live UI. Publishing
+// never blocks a job: a subscriber whose buffer is full is dropped and its
+// channel closed.
+type EventBus struct {
+	mu   sync.Mutex
+	subs map[chan JobEvent]string // Channel to job ID, "" for all jobs
+}
+
+// NewEventBus creates an EventBus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan JobEvent]string)}
+}
+
+// Subscribe returns a channel receiving the events of the job with jobID,
+// in order, or of every job if jobID is "". Only events published after
+// the call are received. The channel is closed by unsubscribe, or when the
+// subscriber falls behind and is dropped.
+func (b *EventBus) Subscribe(jobID string) (events <-chan JobEvent, unsubscribe func()) {
+	ch := make(chan JobEvent, eventBuffer)
+	b.mu.Lock()
+	b.subs[ch] = jobID
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish delivers e to its subscribers, dropping those that are full.
+func (b *EventBus) publish(e JobEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch, jobID := range b.subs {
+		if jobID != "" && jobID != e.JobID {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// emit publishes a job event on Options.Events, if set.
+func (o *Orchestrator) emit(jobID, eventType string, data map[string]interface{}) {
+	if o.opts.Events == nil {
+		return
+	}
+	o.opts.Events.publish(JobEvent{JobID: jobID, Type: eventType, Data: data, Time: o.now().UTC()})
+}
+
+// emitStep publishes that the job completed step.
+func (o *Orchestrator) emitStep(jobID string, step domain.JobStep) {
+	o.emit(jobID, EventStepCompleted, map[string]interface{}{"step": string(step)})
+}
+
+// emitFinished publishes the job's outcome.
+func (o *Orchestrator) emitFinished(result *domain.JobResult) {
+	data := map[string]interface{}{"success": result.Success}
+	switch {
+	case result.Skipped:
+		data["skipped"] = true
+		data["skip_reason"] = result.SkipReason
+	case result.ErrorMessage != "":
+		data["error"] = result.ErrorMessage
+	}
+	o.emit(result.Job.ID, EventJobFinished, data)
+}
+
+// addArtifact records a saved artifact for the manifest and publishes it.
+func (o *Orchestrator) addArtifact(jobID string, artifacts *[]artifactRecord, record artifactRecord) {
+	*artifacts = append(*artifacts, record)
+	o.emit(jobID, EventArtifactSaved, map[string]interface{}{"name": record.name, "kind": record.kind})
+}
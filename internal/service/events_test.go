@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// collect drains events until the bus closes the channel or no event
+// arrives for a while.
+func collect(events <-chan JobEvent) []JobEvent {
+	var got []JobEvent
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return got
+			}
+			got = append(got, e)
+		case <-time.After(100 * time.Millisecond):
+			return got
+		}
+	}
+}
+
+func eventTypes(events []JobEvent) string {
+	types := make([]string, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+		if step, ok := e.Data["step"]; ok {
+			types[i] += ":" + fmt.Sprint(step)
+		}
+		if name, ok := e.Data["name"]; ok {
+			types[i] += ":" + fmt.Sprint(name)
+		}
+	}
+	return strings.Join(types, " ")
+}
+
+func TestJobEventsArriveInOrder(t *testing.T) {
+	bus := NewEventBus()
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+	o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, nil, Options{Events: bus})
+	events, unsubscribe := bus.Subscribe("")
+	defer unsubscribe()
+
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collect(events)
+	for _, e := range got {
+		if e.JobID != result.Job.ID {
+			t.Errorf("event %s for job %s, want %s", e.Type, e.JobID, result.Job.ID)
+		}
+	}
+
+	types := eventTypes(got)
+	// Each artifact is announced after it is saved, each step once it is done
+	for _, want := range []string{
+		"job_started",
+		"artifact_saved:input.json",
+		"artifact_saved:metadata_raw.json",
+		"step_completed:scrape",
+		"artifact_saved:video.mp4",
+		"step_completed:download",
+		"job_finished",
+	} {
+		i := strings.Index(types, want)
+		if i < 0 {
+			t.Fatalf("events %q lack %s, or have it out of order", types, want)
+		}
+		types = types[i+len(want):]
+	}
+	if last := got[len(got)-1]; last.Type != EventJobFinished || last.Data["success"] != true {
+		t.Errorf("last event = %s %v, want a successful job_finished", last.Type, last.Data)
+	}
+}
+
+func TestRetriedJobFinishesOnce(t *testing.T) {
+	saved := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = saved })
+
+	bus := NewEventBus()
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+	downloads := 0
+	downloader := downloadFunc(func(ctx context.Context, url string) (io.ReadCloser, error) {
+		downloads++
+		if downloads == 1 {
+			return nil, fmt.Errorf("connection reset")
+		}
+		return io.NopCloser(strings.NewReader("video")), nil
+	})
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{Events: bus})
+	events, unsubscribe := bus.Subscribe("")
+	defer unsubscribe()
+
+	if _, err := o.RunJobWithRetry(context.Background(), "https://www.tiktok.com/@user/video/1", 3); err != nil {
+		t.Fatal(err)
+	}
+	got := collect(events)
+	if downloads != 2 {
+		t.Fatalf("%d downloads, want a failed one and a retry", downloads)
+	}
+	finished := 0
+	for _, e := range got {
+		if e.Type == EventJobFinished {
+			finished++
+		}
+	}
+	if finished != 1 {
+		t.Errorf("%d job_finished events, want 1: %s", finished, eventTypes(got))
+	}
+	if last := got[len(got)-1]; last.Type != EventJobFinished || last.Data["success"] != true {
+		t.Errorf("last event = %s %v, want a successful job_finished", last.Type, last.Data)
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// eventKeepAlive is how often an idle event stream sends a comment, so
+// proxies don't close it.
+const eventKeepAlive = 15 * time.Second
+
+// EventStream is an http.Handler streaming a job's events from an EventBus
+// as server-sent events. Mount it in server mode with a pattern naming the
+// job ID, e.g. mux.Handle("GET /jobs/{id}/events", service.NewEventStream(bus)).
+//
+// Each event is sent as "event: <type>" with the JobEvent as JSON data. The
+// stream ends after the job's job_finished event, when the client goes
+// away, or when it falls too far behind and is dropped by the bus. Events
+// published before the client connected aren't replayed.
+type EventStream struct {
+	bus *EventBus
+}
+
+// NewEventStream creates an EventStream for bus.
+func NewEventStream(bus *EventBus) *EventStream {
+	return &EventStream{bus: bus}
+}
+
+func (s *EventStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	if jobID == "" {
+		http.Error(w, "missing job id", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := s.bus.Subscribe(jobID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			flusher.Flush()
+			if e.Type == EventJobFinished {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package service
+
+import "context"
+
+type externalIDKey struct{}
+
+// WithExternalID makes jobs started with the returned context carry the
+// caller's own ID for them. It is recorded in input.json and may name the
+// job directory, depending on the storage backend.
+func WithExternalID(ctx context.Context, externalID string) context.Context {
+	return context.WithValue(ctx, externalIDKey{}, externalID)
+}
+
+// externalIDFrom returns the ID attached with WithExternalID, if any.
+func externalIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(externalIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrapeanddown/internal/adapters/localstorage"
+	"scrapeanddown/internal/core/ports"
+)
+
+// fakeScraper returns a scripted result for every URL, or the one set for
+// that URL in byURL.
+type fakeScraper struct {
+	mu     sync.Mutex
+	result *ports.ScrapeResult
+	byURL  map[string]*ports.ScrapeResult
+	err    error
+	calls  []string
+}
+
+func (f *fakeScraper) Scrape(ctx context.Context, videoPageURL string) (*ports.ScrapeResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, videoPageURL)
+	if f.err != nil {
+		return nil, f.err
+	}
+	result := f.result
+	if r, ok := f.byURL[videoPageURL]; ok {
+		result = r
+	}
+	if result == nil {
+		result = &ports.ScrapeResult{RawMetadata: []byte(`[{}]`)}
+	}
+	copied := *result
+	return &copied, nil
+}
+
+// scrapeFunc lets a function serve as a ports.Scraper.
+type scrapeFunc func(ctx context.Context, videoPageURL string) (*ports.ScrapeResult, error)
+
+func (f scrapeFunc) Scrape(ctx context.Context, videoPageURL string) (*ports.ScrapeResult, error) {
+	return f(ctx, videoPageURL)
+}
+
+// downloadFunc lets a function serve as a ports.Downloader.
+type downloadFunc func(ctx context.Context, videoURL string) (io.ReadCloser, error)
+
+func (f downloadFunc) Download(ctx context.Context, videoURL string) (io.ReadCloser, error) {
+	return f(ctx, videoURL)
+}
+
+// fakeDownloader serves files from an in-memory map of URL to content.
+type fakeDownloader struct {
+	mu    sync.Mutex
+	files map[string]string
+	calls []string
+}
+
+func (f *fakeDownloader) Download(ctx context.Context, videoURL string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, videoURL)
+	data, ok := f.files[videoURL]
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected status code: 404", ports.ErrURLExpired)
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+// fakeResolver resolves every page URL to url, or fails with err.
+type fakeResolver struct {
+	mu    sync.Mutex
+	url   string
+	err   error
+	calls []string
+}
+
+func (f *fakeResolver) ResolveVideoURL(ctx context.Context, videoPageURL string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, videoPageURL)
+	return f.url, f.err
+}
+
+// fakeFormatResolver is a fakeResolver that also reports the format.
+type fakeFormatResolver struct {
+	fakeResolver
+	format ports.ResolvedFormat
+}
+
+func (f *fakeFormatResolver) ResolveVideoFormat(ctx context.Context, videoPageURL string) (*ports.ResolvedFormat, error) {
+	if _, err := f.ResolveVideoURL(ctx, videoPageURL); err != nil {
+		return nil, err
+	}
+	format := f.format
+	return &format, nil
+}
+
+var errFake = errors.New("fake failure")
+
+// newTestOrchestrator builds an orchestrator over local storage in a temp
+// directory, logging to the test log. It returns the storage root.
+func newTestOrchestrator(t *testing.T, scraper ports.Scraper, downloader ports.Downloader, resolver ports.URLResolver, opts Options) (*Orchestrator, string) {
+	t.Helper()
+	root := t.TempDir()
+	if opts.TempDir == "" {
+		opts.TempDir = t.TempDir()
+	}
+	logger := log.New(testWriter{t}, "", 0)
+	return NewOrchestrator(scraper, downloader, localstorage.NewLocalStorage(root), resolver, logger, opts), root
+}
+
+// testWriter sends log output to t.Log.
+type testWriter struct{ t *testing.T }
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// readJobFile reads a job's artifact from local storage.
+func readJobFile(t *testing.T, o *Orchestrator, jobID, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(o.storage.GetJobPath(jobID), name))
+	if err != nil {
+		t.Fatalf("reading %s: %v", name, err)
+	}
+	return string(data)
+}
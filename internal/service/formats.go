@@ -0,0 +1,33 @@
+package service
+
+import "context"
+
+type qualityKey struct{}
+
+type audioOnlyKey struct{}
+
+// WithQuality makes jobs started with the returned context download the
+// given rendition (e.g. "720p") instead of Options.Qualities or
+// Options.SeparateStreams.
+func WithQuality(ctx context.Context, quality string) context.Context {
+	return context.WithValue(ctx, qualityKey{}, quality)
+}
+
+// WithAudioOnly makes jobs started with the returned context download only
+// the best audio stream, as audio_only.m4a. It takes precedence over
+// WithQuality and Options.
+func WithAudioOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, audioOnlyKey{}, true)
+}
+
+// jobFormats returns what a job downloads: its WithAudioOnly or WithQuality
+// setting, if any, else what Options ask for.
+func (o *Orchestrator) jobFormats(ctx context.Context) (audioOnly, separateStreams bool, qualities []string) {
+	if only, _ := ctx.Value(audioOnlyKey{}).(bool); only {
+		return true, false, nil
+	}
+	if quality, _ := ctx.Value(qualityKey{}).(string); quality != "" {
+		return false, false, []string{quality}
+	}
+	return false, o.opts.SeparateStreams, o.opts.Qualities
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+)
+
+// hashFuncs are the digests Options.Hashes may request.
+var hashFuncs = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ParseHashAlgorithms validates digest names for Options.Hashes, e.g.
+// ["md5", "SHA1"], returning them lower-cased and without duplicates.
+func ParseHashAlgorithms(names []string) ([]string, error) {
+	var algorithms []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := hashFuncs[name]; !ok {
+			known := make([]string, 0, len(hashFuncs))
+			for k := range hashFuncs {
+				known = append(known, k)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown hash algorithm %q (supported: %s)", name, strings.Join(known, ", "))
+		}
+		if !seen[name] {
+			seen[name] = true
+			algorithms = append(algorithms, name)
+		}
+	}
+	return algorithms, nil
+}
+
+// hashSink digests a download as it streams past, every configured
+// algorithm in the same pass. SHA-256 is always computed: the manifest and
+// the content index use it.
+type hashSink struct {
+	hashes    map[string]hash.Hash
+	requested []string
+	w         io.Writer
+}
+
+// newHashSink creates a hashSink for SHA-256 plus algorithms, as returned
+// by ParseHashAlgorithms.
+func newHashSink(algorithms []string) *hashSink {
+	sink := &hashSink{hashes: map[string]hash.Hash{"sha256": sha256.New()}, requested: algorithms}
+	for _, name := range algorithms {
+		if _, ok := sink.hashes[name]; !ok {
+			sink.hashes[name] = hashFuncs[name]()
+		}
+	}
+	writers := make([]io.Writer, 0, len(sink.hashes))
+	for _, h := range sink.hashes {
+		writers = append(writers, h)
+	}
+	sink.w = io.MultiWriter(writers...)
+	return sink
+}
+
+func (s *hashSink) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+// sha256 returns the SHA-256 digest, hex-encoded.
+func (s *hashSink) sha256() string {
+	return hex.EncodeToString(s.hashes["sha256"].Sum(nil))
+}
+
+// digests returns the requested digests, hex-encoded by algorithm; nil if
+// none were.
+func (s *hashSink) digests() map[string]string {
+	if len(s.requested) == 0 {
+		return nil
+	}
+	digests := make(map[string]string, len(s.requested))
+	for _, name := range s.requested {
+		digests[name] = hex.EncodeToString(s.hashes[name].Sum(nil))
+	}
+	return digests
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"scrapeanddown/internal/core/domain"
+)
+
+// maxJobSpecLine bounds one line of a RunJobStream input.
+const maxJobSpecLine = 1 << 20
+
+// JobStreamLine is a line RunJobStream writes: the outcome of the job spec
+// on input line Line, or why that line couldn't be run.
+type JobStreamLine struct {
+	Line   int               `json:"line"`
+	URL    string            `json:"url,omitempty"`
+	Error  string            `json:"error,omitempty"`
+	Result *domain.JobResult `json:"result,omitempty"`
+	// Skipped is set, with no Result, for specs ResumeJobStream skipped
+	// because an earlier run completed them.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// RunJobStream reads newline-delimited JSON job specs ({"url": ...,
+// "external_id": ..., "quality": ..., "audio_only": ...}) from r and runs
+// them on a pool of workers as they arrive, each with up to maxAttempts
+// tries. A JobStreamLine is written to w for every spec as its job finishes,
+// so lines come out in completion order; malformed specs, and lines over
+// maxJobSpecLine, get one carrying the error and don't stop the stream.
+// A spec with a video_url may leave out its url. Unlike RunBatch, specs aren't
+// de-duplicated, since later lines aren't known yet.
+//
+// It returns the number of lines that failed once r is exhausted, or once
+// the context is draining or cancelled, and every started job is done. An
+// error is only returned if r can't be read.
+func (o *Orchestrator) RunJobStream(ctx context.Context, r io.Reader, w io.Writer, workers, maxAttempts int) (int, error) {
+	return o.runJobStream(ctx, r, w, workers, maxAttempts, nil)
+}
+
+// ResumeJobStream is RunJobStream for a named stream (e.g. an input file
+// piped in again) that may have been interrupted before: specs
+// Options.Checkpoint recorded as completed under batchID get a Skipped line
+// instead of a job, and every job that succeeds is recorded as it
+// completes. Call ClearBatch to run every spec again.
+func (o *Orchestrator) ResumeJobStream(ctx context.Context, batchID string, r io.Reader, w io.Writer, workers, maxAttempts int) (int, error) {
+	if o.opts.Checkpoint == nil {
+		return o.RunJobStream(ctx, r, w, workers, maxAttempts)
+	}
+	completed, err := o.opts.Checkpoint.Completed(ctx, batchID)
+	if err != nil {
+		o.logger.Printf("WARNING: failed to read checkpoint for %s, running every job spec: %v", batchID, err)
+	}
+	if completed == nil {
+		completed = map[string]string{}
+	}
+	return o.runJobStream(context.WithValue(ctx, batchIDKey{}, batchID), r, w, workers, maxAttempts, completed)
+}
+
+// runJobStream is RunJobStream, skipping the specs whose checkpoint keys
+// are in completed.
+func (o *Orchestrator) runJobStream(ctx context.Context, r io.Reader, w io.Writer, workers, maxAttempts int, completed map[string]string) (int, error) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var mu sync.Mutex
+	failed := 0
+	var computeUnits, costUSD float64
+	emit := func(out JobStreamLine) {
+		line, err := json.Marshal(out)
+		if err != nil {
+			o.logger.Printf("WARNING: failed to encode result for line %d: %v", out.Line, err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if out.Error != "" {
+			failed++
+		}
+		if out.Result != nil {
+			computeUnits += out.Result.ApifyComputeUnits
+			costUSD += out.Result.ApifyCostUSD
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			o.logger.Printf("WARNING: failed to write result for line %d: %v", out.Line, err)
+		}
+	}
+
+	type spec struct {
+		line int
+		item BatchItem
+	}
+	specs := make(chan spec)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o.rampUp(ctx)
+			for s := range specs {
+				r := o.runItem(ctx, s.item, nil, maxAttempts)
+				out := JobStreamLine{Line: s.line, URL: s.item.URL, Result: r.Result}
+				if r.Err != nil {
+					out.Error = r.Err.Error()
+				}
+				emit(out)
+			}
+		}()
+	}
+
+	// Read in the background so a drain isn't stuck behind a blocked read
+	type input struct {
+		data    []byte
+		tooLong bool
+		err     error
+	}
+	lines := make(chan input)
+	stop := make(chan struct{})
+	go func() {
+		defer close(lines)
+		br := bufio.NewReaderSize(r, 64*1024)
+		for {
+			data, tooLong, err := readSpecLine(br)
+			if err == io.EOF {
+				return
+			}
+			select {
+			case lines <- input{data: data, tooLong: tooLong, err: err}:
+			case <-stop:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var readErr error
+	n, skipped := 0, 0
+read:
+	for {
+		var in input
+		var ok bool
+		select {
+		case in, ok = <-lines:
+		case <-drainFrom(ctx):
+			break read
+		case <-ctx.Done():
+			break read
+		}
+		if !ok {
+			break
+		}
+		if in.err != nil {
+			readErr = fmt.Errorf("failed to read job specs: %w", in.err)
+			break
+		}
+
+		n++
+		if in.tooLong {
+			err := fmt.Errorf("invalid job spec: line longer than %d bytes", maxJobSpecLine)
+			o.logger.Printf("ERROR: line %d: %v", n, err)
+			emit(JobStreamLine{Line: n, Error: err.Error()})
+			continue
+		}
+		data := bytes.TrimSpace(in.data)
+		if len(data) == 0 {
+			continue
+		}
+		item, err := parseJobSpec(data)
+		if err != nil {
+			o.logger.Printf("ERROR: line %d: %v", n, err)
+			emit(JobStreamLine{Line: n, Error: err.Error()})
+			continue
+		}
+		if _, ok := completed[checkpointKey(item)]; ok {
+			emit(JobStreamLine{Line: n, URL: item.URL, Skipped: true})
+			skipped++
+			continue
+		}
+		select {
+		case specs <- spec{line: n, item: item}:
+		case <-drainFrom(ctx):
+			break read
+		case <-ctx.Done():
+			break read
+		}
+	}
+	close(stop)
+	close(specs)
+	wg.Wait()
+
+	if skipped > 0 {
+		batchID, _ := ctx.Value(batchIDKey{}).(string)
+		o.logger.Printf("Skipped %d job specs of %s completed by an earlier run", skipped, batchID)
+	}
+	if computeUnits > 0 || costUSD > 0 {
+		o.logger.Printf("Apify usage: %s", FormatApifyUsage(computeUnits, costUSD))
+	}
+
+	return failed, readErr
+}
+
+// readSpecLine reads the next line of br, without its line ending. A line
+// over maxJobSpecLine bytes is read to its end and dropped, reported as
+// tooLong. It returns io.EOF once br has no more lines.
+func readSpecLine(br *bufio.Reader) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if !tooLong && len(line)+len(bytes.TrimSuffix(chunk, []byte("\n"))) > maxJobSpecLine {
+			tooLong, line = true, nil
+		}
+		if !tooLong {
+			line = append(line, chunk...)
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && (len(line) > 0 || tooLong):
+			// The last line, without a newline
+		case err != nil:
+			return nil, false, err
+		}
+		return bytes.TrimSuffix(line, []byte("\n")), tooLong, nil
+	}
+}
+
+// parseJobSpec parses one RunJobStream line.
+func parseJobSpec(data []byte) (BatchItem, error) {
+	var entry urlEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return BatchItem{}, fmt.Errorf("invalid job spec: %w", err)
+	}
+	// A direct video URL is job enough; RunJob takes it as the page URL too
+	if entry.URL == "" && entry.VideoURL == "" {
+		return BatchItem{}, fmt.Errorf("invalid job spec: missing url or video_url")
+	}
+	if entry.URL == "" {
+		entry.URL = entry.VideoURL
+	}
+	if entry.VideoURL != "" {
+		if err := ValidateVideoURL(entry.VideoURL); err != nil {
+			return BatchItem{}, fmt.Errorf("invalid job spec: %w", err)
+		}
+	}
+	if entry.Quality != "" {
+		if _, err := qualityHeight(entry.Quality); err != nil {
+			return BatchItem{}, fmt.Errorf("invalid job spec: %w", err)
+		}
+	}
+	return BatchItem(entry), nil
+}
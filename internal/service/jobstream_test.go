@@ -0,0 +1,226 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// streamScraper scrapes TikTok URLs to https://cdn/<id>.mp4, failing those
+// ending in "/fail".
+func streamScraper() ports.Scraper {
+	return scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+		id := url[strings.LastIndex(url, "/")+1:]
+		if id == "fail" {
+			return nil, errFake
+		}
+		return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/" + id + ".mp4"}, nil
+	})
+}
+
+func decodeStreamLines(t *testing.T, out string) map[int]JobStreamLine {
+	t.Helper()
+	lines := map[int]JobStreamLine{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		var line JobStreamLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("output line %q: %v", scanner.Text(), err)
+		}
+		if _, dup := lines[line.Line]; dup {
+			t.Errorf("line %d reported twice", line.Line)
+		}
+		lines[line.Line] = line
+	}
+	return lines
+}
+
+func TestRunJobStreamMixedLines(t *testing.T) {
+	input := strings.Join([]string{
+		`{"url": "https://www.tiktok.com/@user/video/1"}`,
+		`{"url": "https://www.tiktok.com/@user/video/2"`,
+		``,
+		`   `,
+		`{"url": "https://www.tiktok.com/@user/video/` + strings.Repeat("9", maxJobSpecLine) + `"}`,
+		`{"quality": "720p"}`,
+		`{"url": "https://www.tiktok.com/@user/video/3", "quality": "huge"}`,
+		`{"video_url": "https://cdn.example.com/direct.mp4"}`,
+		`{"video_url": "ftp://cdn.example.com/direct.mp4"}`,
+		`{"url": "https://www.tiktok.com/@user/video/fail"}`,
+		`"https://www.tiktok.com/@user/video/4"`,
+		`{"url": "https://www.tiktok.com/@user/video/5"}`,
+	}, "\n")
+	downloader := &fakeDownloader{files: map[string]string{
+		"https://cdn/1.mp4":                  "video 1",
+		"https://cdn/4.mp4":                  "video 4",
+		"https://cdn/5.mp4":                  "video 5",
+		"https://cdn.example.com/direct.mp4": "direct video",
+	}}
+	o, _ := newTestOrchestrator(t, streamScraper(), downloader, nil, Options{})
+
+	var out strings.Builder
+	failed, err := o.RunJobStream(context.Background(), strings.NewReader(input), &out, 3, 1)
+	if err != nil {
+		t.Fatalf("RunJobStream: %v", err)
+	}
+
+	lines := decodeStreamLines(t, out.String())
+	tests := []struct {
+		line    int
+		ok      bool
+		errPart string
+	}{
+		{1, true, ""},
+		{2, false, "invalid job spec"},
+		{5, false, "line longer than"},
+		{6, false, "missing url or video_url"},
+		{7, false, "invalid job spec"},
+		{8, true, ""},
+		{9, false, "not an absolute http(s) url"},
+		{10, false, "fake"},
+		{11, true, ""},
+		{12, true, ""},
+	}
+	if len(lines) != len(tests) {
+		t.Errorf("got %d output lines, want %d (blank lines get none): %s", len(lines), len(tests), out.String())
+	}
+	wantFailed := 0
+	for _, tt := range tests {
+		line, ok := lines[tt.line]
+		if !ok {
+			t.Errorf("no output for line %d", tt.line)
+			continue
+		}
+		if tt.ok {
+			if line.Error != "" || line.Result == nil || !line.Result.Success {
+				t.Errorf("line %d: error %q, want success", tt.line, line.Error)
+			}
+			continue
+		}
+		wantFailed++
+		if !strings.Contains(line.Error, tt.errPart) {
+			t.Errorf("line %d: error %q, want it to mention %q", tt.line, line.Error, tt.errPart)
+		}
+	}
+	if failed != wantFailed {
+		t.Errorf("failed = %d, want %d", failed, wantFailed)
+	}
+	if got := lines[8].URL; got != "https://cdn.example.com/direct.mp4" {
+		t.Errorf("direct line url = %q, want the video url", got)
+	}
+}
+
+// notifyWriter calls onWrite after each write.
+type notifyWriter struct {
+	mu      sync.Mutex
+	buf     strings.Builder
+	onWrite func()
+}
+
+func (w *notifyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n, err := w.buf.Write(p)
+	w.onWrite()
+	return n, err
+}
+
+// Results come out as jobs finish, not in input order.
+func TestRunJobStreamCompletionOrder(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	scraper := scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+		id := url[strings.LastIndex(url, "/")+1:]
+		if id == "1" {
+			// Held until line 2's result is written
+			select {
+			case <-release:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/" + id + ".mp4"}, nil
+	})
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn/1.mp4": "video 1", "https://cdn/2.mp4": "video 2"}}
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{})
+
+	w := &notifyWriter{onWrite: func() { once.Do(func() { close(release) }) }}
+	input := `{"url": "https://www.tiktok.com/@user/video/1"}` + "\n" + `{"url": "https://www.tiktok.com/@user/video/2"}` + "\n"
+	failed, err := o.RunJobStream(context.Background(), strings.NewReader(input), w, 2, 1)
+	if err != nil || failed != 0 {
+		t.Fatalf("RunJobStream = %d failed, %v", failed, err)
+	}
+
+	var order []int
+	for _, text := range strings.Split(strings.TrimSpace(w.buf.String()), "\n") {
+		var line JobStreamLine
+		if err := json.Unmarshal([]byte(text), &line); err != nil {
+			t.Fatal(err)
+		}
+		order = append(order, line.Line)
+	}
+	if len(order) != 2 || order[0] != 2 || order[1] != 1 {
+		t.Errorf("output order = %v, want [2 1]", order)
+	}
+}
+
+// brokenReader fails instead of ending once its data is read.
+type brokenReader struct{ data io.Reader }
+
+func (r *brokenReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, io.ErrClosedPipe
+	}
+	return n, err
+}
+
+func TestRunJobStreamReadError(t *testing.T) {
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn/1.mp4": "video 1"}}
+	o, _ := newTestOrchestrator(t, streamScraper(), downloader, nil, Options{})
+
+	var out strings.Builder
+	r := &brokenReader{data: strings.NewReader(`{"url": "https://www.tiktok.com/@user/video/1"}` + "\n")}
+	failed, err := o.RunJobStream(context.Background(), r, &out, 1, 1)
+	if err == nil || !strings.Contains(err.Error(), "failed to read job specs") {
+		t.Errorf("err = %v, want a read error", err)
+	}
+	// The line read before the failure still ran
+	if lines := decodeStreamLines(t, out.String()); failed != 0 || len(lines) != 1 || lines[1].Error != "" {
+		t.Errorf("got %d failed, output %q; want line 1 done", failed, out.String())
+	}
+}
+
+func TestReadSpecLine(t *testing.T) {
+	long := strings.Repeat("x", maxJobSpecLine+1)
+	exact := strings.Repeat("y", maxJobSpecLine)
+	br := bufio.NewReaderSize(strings.NewReader("a\r\n\n"+long+"\n"+exact+"\nlast"), 16)
+	want := []struct {
+		line    string
+		tooLong bool
+	}{
+		{"a\r", false},
+		{"", false},
+		{"", true},
+		{exact, false},
+		{"last", false},
+	}
+	for i, w := range want {
+		line, tooLong, err := readSpecLine(br)
+		if err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+		if string(line) != w.line || tooLong != w.tooLong {
+			t.Errorf("line %d = %.10q (%d bytes), tooLong %v; want %.10q, %v", i+1, line, len(line), tooLong, w.line, w.tooLong)
+		}
+	}
+	if _, _, err := readSpecLine(br); err != io.EOF {
+		t.Errorf("after the last line: err = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogLevel controls how much the orchestrator logs.
+type LogLevel int
+
+const (
+	LogQuiet   LogLevel = -1 // Errors only
+	LogNormal  LogLevel = 0  // Job progress (the default)
+	LogVerbose LogLevel = 1  // Plus step timings and URL resolution details
+	LogDebug   LogLevel = 2  // Plus request details from the adapters (wired by the caller)
+)
+
+// leveledLogger filters the orchestrator's log lines by Options.LogLevel.
+// Printf and Println log at LogNormal, except error lines (see isErrorLine),
+// which LogQuiet keeps.
+type leveledLogger struct {
+	*log.Logger
+	level LogLevel
+
+	// now, when set (Options.LogElapsed), prefixes the lines of running
+	// jobs with the time since their start
+	now    func() time.Time
+	mu     sync.Mutex
+	starts map[string]time.Time
+}
+
+// newLeveledLogger wraps logger as Options.LogLevel and LogElapsed ask,
+// timing jobs by now.
+func newLeveledLogger(logger *log.Logger, opts Options, now func() time.Time) *leveledLogger {
+	l := &leveledLogger{Logger: logger, level: opts.LogLevel}
+	if opts.LogElapsed {
+		l.now = now
+	}
+	return l
+}
+
+func (l *leveledLogger) Printf(format string, args ...interface{}) {
+	if l.level >= LogNormal || isErrorLine(fmt.Sprintf(format, args...)) {
+		l.Logger.Printf(l.withElapsed(format, args), args...)
+	}
+}
+
+// Summaryf logs at every level, LogQuiet included, for a batch's final
+// summary.
+func (l *leveledLogger) Summaryf(format string, args ...interface{}) {
+	l.Logger.Printf(format, args...)
+}
+
+func (l *leveledLogger) Println(args ...interface{}) {
+	if l.level >= LogNormal {
+		l.Logger.Println(args...)
+	}
+}
+
+// Verbosef logs at LogVerbose.
+func (l *leveledLogger) Verbosef(format string, args ...interface{}) {
+	if l.level >= LogVerbose {
+		l.Logger.Printf(l.withElapsed(format, args), args...)
+	}
+}
+
+// startJob starts the elapsed time of a job's lines; the returned func ends
+// it. Each attempt of a retried job starts again from zero.
+func (l *leveledLogger) startJob(jobID string) func() {
+	if l.now == nil {
+		return func() {}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.starts == nil {
+		l.starts = make(map[string]time.Time)
+	}
+	l.starts[jobID] = l.now()
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.starts, jobID)
+	}
+}
+
+// withElapsed prefixes a job line ("[JOB %s] ...") of a started job with
+// its elapsed time, e.g. "[+3.2s] [JOB ...] ...".
+func (l *leveledLogger) withElapsed(format string, args []interface{}) string {
+	if l.now == nil || len(args) == 0 || !strings.HasPrefix(format, "[JOB %s]") {
+		return format
+	}
+	jobID, _ := args[0].(string)
+	l.mu.Lock()
+	start, ok := l.starts[jobID]
+	l.mu.Unlock()
+	if !ok {
+		return format
+	}
+	return "[+" + l.now().Sub(start).Round(100*time.Millisecond).String() + "] " + format
+}
+
+// RedactURL drops the query of a direct download URL, which usually holds
+// signatures and tokens, for logging.
+func RedactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid url>"
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "..."
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// errorLine matches a line reporting an error: "ERROR: ..." after any
+// bracketed prefixes, e.g. "[JOB ...] ERROR: ..." or yt-dlp's
+// "[youtube] ERROR: ...".
+var errorLine = regexp.MustCompile(`^(\[[^\]]*\] )*ERROR:`)
+
+// ytdlpFailure matches yt-dlp output quoted in a line, e.g. the error of a
+// warning, that means the download failed: its "ERROR: [extractor] ..."
+// lines and the warnings it gives up with.
+var ytdlpFailure = regexp.MustCompile(`ERROR: \[[^\]]+\]|WARNING: .*(?i:giving up after|unable to download|unable to extract)`)
+
+// isErrorLine reports whether a formatted log line reports an error.
+func isErrorLine(line string) bool {
+	return errorLine.MatchString(line) || ytdlpFailure.MatchString(line)
+}
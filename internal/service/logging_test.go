@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeveledLoggerLevels(t *testing.T) {
+	lines := map[string]func(l *leveledLogger){
+		"progress": func(l *leveledLogger) { l.Printf("[JOB %s] Saved metadata.json", "j1") },
+		"verbose":  func(l *leveledLogger) { l.Verbosef("[JOB %s] Resolve took %s", "j1", time.Second) },
+		"error":    func(l *leveledLogger) { l.Printf("[JOB %s] ERROR: %s", "j1", "download failed") },
+		"summary":  func(l *leveledLogger) { l.Summaryf("Batch summary: %s", "3 jobs") },
+	}
+	tests := []struct {
+		level LogLevel
+		want  []string
+	}{
+		{LogQuiet, []string{"error", "summary"}},
+		{LogNormal, []string{"progress", "error", "summary"}},
+		{LogVerbose, []string{"progress", "verbose", "error", "summary"}},
+		{LogDebug, []string{"progress", "verbose", "error", "summary"}},
+	}
+	for _, tt := range tests {
+		for name, logLine := range lines {
+			var buf bytes.Buffer
+			logLine(newLeveledLogger(log.New(&buf, "", 0), Options{LogLevel: tt.level}, time.Now))
+			want := false
+			for _, w := range tt.want {
+				want = want || w == name
+			}
+			if got := buf.Len() > 0; got != want {
+				t.Errorf("level %d: %s line logged = %v, want %v (%q)", tt.level, name, got, want, buf.String())
+			}
+		}
+	}
+}
+
+func TestIsErrorLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"ERROR: line 3: invalid URL", true},
+		{"[JOB abc] ERROR: download failed", true},
+		{"[+1.2s] [JOB abc] ERROR: download failed", true},
+		{"[youtube] ERROR: Video unavailable", true},
+		{"[JOB abc] WARNING: quality 720p failed: ERROR: [youtube] dQw4w9WgXcQ: Private video", true},
+		{"[JOB abc] WARNING: yt-dlp: WARNING: [download] Got error: giving up after 10 retries", true},
+		{"[JOB abc] WARNING: yt-dlp: WARNING: Unable to download webpage", true},
+		{"[JOB abc] Saved metadata.json", false},
+		{"[JOB abc] WARNING: view count unknown, minimum views not checked", false},
+		{"[JOB abc] Saved ERRORS.txt", false},
+	}
+	for _, tt := range tests {
+		if got := isErrorLine(tt.line); got != tt.want {
+			t.Errorf("isErrorLine(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestQuietJobLogsOnlyErrorsAndSummary(t *testing.T) {
+	o, _ := newTestOrchestrator(t, &fakeScraper{err: errFake}, &fakeDownloader{}, nil, Options{LogLevel: LogQuiet})
+	var buf bytes.Buffer
+	o.logger = newLeveledLogger(log.New(&buf, "", 0), o.opts, time.Now)
+
+	o.RunJobs(context.Background(), []string{"https://www.youtube.com/watch?v=dQw4w9WgXcQ"}, 1, 1)
+
+	out := buf.String()
+	if strings.Contains(out, "Starting job") {
+		t.Errorf("quiet logged job progress:\n%s", out)
+	}
+	for _, want := range []string{"ERROR:", "Batch summary: 1 jobs: 0 succeeded, 1 failed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("quiet log lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"https://cdn.example.com/v.mp4?sig=secret&expires=1", "https://cdn.example.com/v.mp4?..."},
+		{"https://cdn.example.com/v.mp4#t=10", "https://cdn.example.com/v.mp4"},
+		{"https://www.youtube.com/watch", "https://www.youtube.com/watch"},
+		{"://bad", "<invalid url>"},
+	}
+	for _, tt := range tests {
+		if got := RedactURL(tt.in); got != tt.want {
+			t.Errorf("RedactURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Lines of a started job are prefixed with its elapsed time by the clock.
+func TestLeveledLoggerElapsed(t *testing.T) {
+	clock := time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+	var buf bytes.Buffer
+	l := newLeveledLogger(log.New(&buf, "", 0), Options{LogLevel: LogVerbose, LogElapsed: true}, now)
+
+	l.Printf("[JOB %s] before the start", "j1")
+	end := l.startJob("j1")
+	l.Printf("[JOB %s] started", "j1")
+	clock = clock.Add(3240 * time.Millisecond)
+	l.Printf("[JOB %s] scraped", "j1")
+	l.Printf("[JOB %s] another job", "j2")
+	l.Printf("Batch progress: %d", 1)
+	clock = clock.Add(1500 * time.Millisecond)
+	l.Verbosef("[JOB %s] downloaded", "j1")
+	end()
+	l.Printf("[JOB %s] after the end", "j1")
+	l.startJob("j1")
+	clock = clock.Add(time.Second)
+	l.Printf("[JOB %s] next attempt", "j1")
+
+	want := []string{
+		"[JOB j1] before the start",
+		"[+0s] [JOB j1] started",
+		"[+3.2s] [JOB j1] scraped",
+		"[JOB j2] another job",
+		"Batch progress: 1",
+		"[+4.7s] [JOB j1] downloaded",
+		"[JOB j1] after the end",
+		"[+1s] [JOB j1] next attempt",
+	}
+	if got := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("logged:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	buf.Reset()
+	l = newLeveledLogger(log.New(&buf, "", 0), Options{}, now)
+	l.startJob("j1")
+	l.Printf("[JOB %s] started", "j1")
+	if got := buf.String(); got != "[JOB j1] started\n" {
+		t.Errorf("without LogElapsed logged %q", got)
+	}
+}
+
+// Each line of a job counts up from its start.
+func TestRunJobLogElapsed(t *testing.T) {
+	clock := time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC)
+	now := func() time.Time {
+		clock = clock.Add(100 * time.Millisecond)
+		return clock
+	}
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}},
+		&fakeResolver{url: "https://cdn/v.mp4"}, Options{Now: now, LogElapsed: true})
+	var buf bytes.Buffer
+	o.logger = newLeveledLogger(log.New(&buf, "", 0), o.opts, now)
+
+	if _, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=dQw4w9WgXcQ"); err != nil {
+		t.Fatal(err)
+	}
+	last := time.Duration(-1)
+	jobLines := 0
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if !strings.Contains(line, "[JOB ") {
+			continue
+		}
+		jobLines++
+		var elapsed string
+		if _, err := fmt.Sscanf(line, "[+%s", &elapsed); err != nil {
+			t.Errorf("line without an elapsed prefix: %q", line)
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSuffix(elapsed, "]"))
+		if err != nil || d < last {
+			t.Errorf("elapsed %q follows %s, want it to count up: %q", elapsed, last, line)
+		}
+		last = d
+	}
+	if jobLines < 3 || last <= 0 {
+		t.Errorf("%d job lines, last at %s:\n%s", jobLines, last, buf.String())
+	}
+	// The first line reads the clock once after the start
+	if !strings.HasPrefix(buf.String(), "[+100ms] [JOB ") {
+		t.Errorf("first line isn't one tick after the start:\n%s", buf.String())
+	}
+}
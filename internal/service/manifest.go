@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"scrapeanddown/internal/core/domain"
+)
+
+// artifactRecord tracks an artifact saved during a job, along with its checksum
+// computed while the data passed through the orchestrator.
+type artifactRecord struct {
+	name    string
+	kind    string
+	sha256  string
+	digests map[string]string // Options.Hashes, by algorithm
+}
+
+func newArtifactRecord(name, kind string, data []byte) artifactRecord {
+	sum := sha256.Sum256(data)
+	return artifactRecord{name: name, kind: kind, sha256: hex.EncodeToString(sum[:])}
+}
+
+// writeManifest stats every recorded artifact and saves manifest.json.
+func (o *Orchestrator) writeManifest(ctx context.Context, job domain.Job, artifacts []artifactRecord) error {
+	manifest := domain.Manifest{
+		JobID:       job.ID,
+		URL:         job.URL,
+		Platform:    job.Platform,
+		GeneratedAt: o.now().UTC(),
+		Artifacts:   make([]domain.ManifestEntry, 0, len(artifacts)),
+	}
+
+	for _, a := range artifacts {
+		info, err := o.storage.StatArtifact(ctx, job.ID, a.name)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", a.name, err)
+		}
+		manifest.Artifacts = append(manifest.Artifacts, domain.ManifestEntry{
+			Name:        a.name,
+			Kind:        a.kind,
+			Size:        info.Size,
+			SHA256:      a.sha256,
+			Digests:     a.digests,
+			ContentType: contentTypeFor(a.name),
+			ModifiedAt:  info.ModTime,
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return o.storage.SaveManifest(ctx, job.ID, data)
+}
+
+// contentTypeFor guesses the MIME type from the artifact's extension.
+func contentTypeFor(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	switch ext {
+	case ".mp4":
+		return "video/mp4"
+	case ".webm":
+		return "video/webm"
+	case ".json":
+		return "application/json"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".vtt":
+		return "text/vtt"
+	case ".srt":
+		return "application/x-subrip"
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// The manifest lists each artifact the job saved, with its size, checksum
+// and content type.
+func TestManifestListsArtifacts(t *testing.T) {
+	const video, metadata = "not really a video", `[{"desc": "a video"}]`
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(metadata), VideoURL: "https://cdn/v.mp4"}}
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": video}}
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{WriteManifest: true})
+
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest domain.Manifest
+	if err := json.Unmarshal([]byte(readJobFile(t, o, result.Job.ID, "manifest.json")), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.JobID != result.Job.ID || manifest.URL != result.Job.URL || manifest.Platform != "tiktok" {
+		t.Errorf("manifest job = %s %s %s, want the job's", manifest.JobID, manifest.URL, manifest.Platform)
+	}
+
+	tests := []struct {
+		name, kind, contentType, data string
+	}{
+		{"video.mp4", "video", "video/mp4", video},
+		{"metadata_raw.json", "metadata", "application/json", metadata},
+	}
+	entries := map[string]domain.ManifestEntry{}
+	for _, a := range manifest.Artifacts {
+		entries[a.Name] = a
+		info, err := os.Stat(filepath.Join(o.storage.GetJobPath(result.Job.ID), a.Name))
+		if err != nil {
+			t.Errorf("%s: %v", a.Name, err)
+			continue
+		}
+		if a.Size != info.Size() {
+			t.Errorf("%s: size %d, want %d", a.Name, a.Size, info.Size())
+		}
+	}
+	for _, tt := range tests {
+		a, ok := entries[tt.name]
+		if !ok {
+			t.Errorf("%s not in the manifest %+v", tt.name, manifest.Artifacts)
+			continue
+		}
+		sum := sha256.Sum256([]byte(tt.data))
+		if a.Kind != tt.kind || a.ContentType != tt.contentType || a.Size != int64(len(tt.data)) || a.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("%s = %+v, want kind %s, %s, %d bytes and its SHA-256", tt.name, a, tt.kind, tt.contentType, len(tt.data))
+		}
+	}
+	if _, ok := entries["manifest.json"]; ok {
+		t.Error("the manifest lists itself")
+	}
+}
+
+func TestContentTypeFor(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"video.mp4", "video/mp4"},
+		{"VIDEO.MP4", "video/mp4"},
+		{"video.webm", "video/webm"},
+		{"metadata.json", "application/json"},
+		{"thumbnail.jpeg", "image/jpeg"},
+		{"subtitles.en.vtt", "text/vtt"},
+		{"subtitles.en.srt", "application/x-subrip"},
+		{"video", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if got := contentTypeFor(tt.name); got != tt.want {
+			t.Errorf("contentTypeFor(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Scraped comments are saved to comments.json and listed in the manifest;
+// a job without any saves no file.
+func TestCommentsSaved(t *testing.T) {
+	const comments = `[{"text":"first"},{"text":"second"}]`
+	for _, withComments := range []bool{true, false} {
+		result := &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}
+		if withComments {
+			result.Comments = []byte(comments)
+		}
+		downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}
+		o, _ := newTestOrchestrator(t, &fakeScraper{result: result}, downloader, nil, Options{WriteManifest: true})
+
+		job, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(o.storage.GetJobPath(job.Job.ID), "comments.json")
+		if !withComments {
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("comments.json saved without comments: %v", err)
+			}
+			continue
+		}
+		if got := readJobFile(t, o, job.Job.ID, "comments.json"); got != comments {
+			t.Errorf("comments.json = %s, want %s", got, comments)
+		}
+		var manifest domain.Manifest
+		if err := json.Unmarshal([]byte(readJobFile(t, o, job.Job.ID, "manifest.json")), &manifest); err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, a := range manifest.Artifacts {
+			found = found || a.Name == "comments.json" && a.Kind == "comments" && a.Size == int64(len(comments))
+		}
+		if !found {
+			t.Errorf("comments.json not in the manifest %+v", manifest.Artifacts)
+		}
+	}
+}
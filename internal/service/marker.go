@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+
+	"scrapeanddown/internal/core/domain"
+)
+
+// Hadoop-style markers telling consumers polling job directories that a job
+// is done, and how it ended.
+const (
+	successMarker = "_SUCCESS"
+	failedMarker  = "_FAILED"
+	skippedMarker = "_SKIPPED"
+)
+
+// writeMarker records how the job ended. It runs as the job's very last
+// storage write (after the last attempt of a retried job), and replaces
+// any other marker left by an earlier run (e.g. a failed job that was
+// later resumed).
+func (o *Orchestrator) writeMarker(ctx context.Context, result *domain.JobResult) {
+	ctx = context.WithoutCancel(ctx)
+	jobID := result.Job.ID
+	name := failedMarker
+	switch {
+	case result.Success:
+		name = successMarker
+	case result.Skipped:
+		name = skippedMarker
+	}
+	for _, stale := range []string{successMarker, failedMarker, skippedMarker} {
+		if stale == name {
+			continue
+		}
+		if exists, _ := o.storage.Exists(ctx, jobID, stale); exists {
+			if err := o.storage.RemoveArtifact(ctx, jobID, stale); err != nil {
+				o.logger.Printf("[JOB %s] WARNING: %v", jobID, err)
+			}
+		}
+	}
+	if err := o.storage.WriteMarker(ctx, jobID, name); err != nil {
+		o.logger.Printf("[JOB %s] WARNING: %v", jobID, err)
+	}
+}
+
+// releaseJob releases the job taken by Storage.InitJob.
+func (o *Orchestrator) releaseJob(ctx context.Context, jobID string) {
+	if err := o.storage.ReleaseJob(context.WithoutCancel(ctx), jobID); err != nil {
+		o.logger.Printf("[JOB %s] WARNING: %v", jobID, err)
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// A finished job's directory holds exactly one marker, naming its outcome.
+func TestRunJobMarkers(t *testing.T) {
+	tests := []struct {
+		name       string
+		downloader *fakeDownloader
+		wantMarker string
+	}{
+		{"succeeded", &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, successMarker},
+		{"failed", &fakeDownloader{}, failedMarker},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+			o, _ := newTestOrchestrator(t, scraper, tt.downloader, nil, Options{})
+
+			result, _ := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+			markers, _ := filepath.Glob(filepath.Join(o.storage.GetJobPath(result.Job.ID), "_*"))
+			if len(markers) != 1 || filepath.Base(markers[0]) != tt.wantMarker {
+				t.Errorf("markers = %q, want only %s", markers, tt.wantMarker)
+			}
+		})
+	}
+}
+
+// A job finishing again, as a resumed download does, replaces its old marker.
+func TestWriteMarkerReplacesStale(t *testing.T) {
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, &fakeDownloader{}, nil, Options{})
+	ctx := context.Background()
+	if err := o.storage.InitJob(ctx, "job1"); err != nil {
+		t.Fatal(err)
+	}
+	result := &domain.JobResult{Job: domain.Job{ID: "job1"}}
+	o.writeMarker(ctx, result)
+	result.Success = true
+	o.writeMarker(ctx, result)
+
+	markers, _ := filepath.Glob(filepath.Join(o.storage.GetJobPath("job1"), "_*"))
+	if len(markers) != 1 || filepath.Base(markers[0]) != successMarker {
+		t.Errorf("markers = %q, want only %s", markers, successMarker)
+	}
+}
+
+// A retried job's attempts share one job: no marker may appear, and the job
+// must stay held, until the last attempt is done.
+func TestRetriedJobMarkedAfterLastAttempt(t *testing.T) {
+	saved := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = saved })
+
+	var o *Orchestrator
+	var root string
+	var betweenAttempts []string
+	downloads := 0
+	downloader := downloadFunc(func(ctx context.Context, url string) (io.ReadCloser, error) {
+		downloads++
+		if downloads == 1 {
+			return nil, fmt.Errorf("connection reset")
+		}
+		markers, _ := filepath.Glob(filepath.Join(root, "jobs", "*", "_*"))
+		betweenAttempts = append(betweenAttempts, markers...)
+		dirs, _ := filepath.Glob(filepath.Join(root, "jobs", "*"))
+		for _, dir := range dirs {
+			if err := o.storage.InitJob(ctx, filepath.Base(dir)); !errors.Is(err, ports.ErrJobLocked) {
+				t.Errorf("job not held during the retry: InitJob err = %v", err)
+			}
+		}
+		return io.NopCloser(strings.NewReader("video")), nil
+	})
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+	o, root = newTestOrchestrator(t, scraper, downloader, nil, Options{})
+
+	result, err := o.RunJobWithRetry(context.Background(), "https://www.tiktok.com/@user/video/1", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if downloads != 2 {
+		t.Fatalf("%d downloads, want a failed one and a retry", downloads)
+	}
+	if len(betweenAttempts) > 0 {
+		t.Errorf("markers written before the last attempt: %q", betweenAttempts)
+	}
+	dir := o.storage.GetJobPath(result.Job.ID)
+	if _, err := os.Stat(filepath.Join(dir, successMarker)); err != nil {
+		t.Errorf("no %s after the successful retry: %v", successMarker, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, failedMarker)); err == nil {
+		t.Errorf("%s left by the failed attempt", failedMarker)
+	}
+	// Released once done
+	if err := o.storage.InitJob(context.Background(), result.Job.ID); err != nil {
+		t.Errorf("job still held after the last attempt: %v", err)
+	}
+}
+
+// A video the platform won't serve fails on its first attempt, whatever the
+// reason: retrying with the same cookies gets the same answer.
+func TestUnavailableVideoNotRetried(t *testing.T) {
+	saved := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = saved })
+
+	for _, reason := range []ports.UnavailableReason{ports.ReasonAgeRestricted, ports.ReasonMembersOnly, ports.ReasonPrivate} {
+		t.Run(string(reason), func(t *testing.T) {
+			calls := 0
+			scraper := scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+				calls++
+				return nil, &ports.UnavailableError{Reason: reason}
+			})
+			o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{}, nil, Options{})
+
+			result, err := o.RunJobWithRetry(context.Background(), "https://www.tiktok.com/@user/video/1", 3)
+			if !errors.Is(err, ports.ErrVideoUnavailable) {
+				t.Fatalf("err = %v, want ErrVideoUnavailable", err)
+			}
+			if calls != 1 {
+				t.Errorf("scraped %d times, want 1", calls)
+			}
+			if result.FailureReason != string(reason) {
+				t.Errorf("failure reason = %q, want %q", result.FailureReason, reason)
+			}
+		})
+	}
+}
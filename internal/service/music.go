@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// musicFile is the name a post's background music track is saved as.
+const musicFile = "music.mp3"
+
+// saveMusic downloads the post's music track, as reported by the scrape, to
+// music.mp3 and records it on the result. Original sounds (the creator's
+// own audio) and licensed tracks are both saved; the result says which it
+// is. A missing track or URL, or a failed download, is logged and skipped.
+func (o *Orchestrator) saveMusic(ctx context.Context, job domain.Job, result *domain.JobResult, scrapeResult *ports.ScrapeResult, artifacts *[]artifactRecord) {
+	if scrapeResult == nil || scrapeResult.Music == nil {
+		o.logger.Printf("[JOB %s] No music track in the metadata, skipping %s", job.ID, musicFile)
+		return
+	}
+	track := scrapeResult.Music
+	if track.URL == "" {
+		o.logger.Printf("[JOB %s] No download URL for music track %s, skipping %s", job.ID, describeTrack(track), musicFile)
+		return
+	}
+
+	if err := o.downloadSlots.acquire(ctx); err != nil {
+		o.logger.Printf("[JOB %s] WARNING: failed to download music track: %v", job.ID, err)
+		return
+	}
+	defer o.downloadSlots.release()
+
+	body, err := o.downloader.Download(ctx, track.URL)
+	if err != nil {
+		o.logger.Printf("[JOB %s] WARNING: failed to download music track: %v", job.ID, err)
+		return
+	}
+	defer body.Close()
+
+	hashes := newHashSink(o.opts.Hashes)
+	counter := &countingReader{r: io.TeeReader(body, hashes)}
+	if err := o.storage.SaveVideo(ctx, job.ID, counter, musicFile); err != nil {
+		o.logger.Printf("[JOB %s] WARNING: failed to save music track: %v", job.ID, err)
+		return
+	}
+
+	result.Music = &domain.MusicTrack{
+		Title:    track.Title,
+		Author:   track.Author,
+		Album:    track.Album,
+		Original: track.Original,
+		Path:     o.storage.GetJobPath(job.ID) + "/" + musicFile,
+		Bytes:    counter.n,
+	}
+	artifact := artifactRecord{name: musicFile, kind: "music"}
+	// A track kept by the write policy didn't pass through us
+	if counter.n > 0 {
+		artifact.sha256 = hashes.sha256()
+		artifact.digests = hashes.digests()
+	}
+	o.addArtifact(job.ID, artifacts, artifact)
+	o.logger.Printf("[JOB %s] Saved %s (%s)", job.ID, musicFile, describeTrack(track))
+}
+
+// describeTrack names a track for the log, e.g. `licensed track "Song" by
+// Artist` or `original sound by creator`.
+func describeTrack(track *ports.MusicTrack) string {
+	kind := "licensed track"
+	if track.Original {
+		kind = "original sound"
+	}
+	desc := kind
+	if track.Title != "" {
+		desc += fmt.Sprintf(" %q", track.Title)
+	}
+	if track.Author != "" {
+		desc += " by " + track.Author
+	}
+	return desc
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// The music track is a download like the video, so it must hold a download
+// slot too.
+func TestMusicDownloadTakesDownloadSlot(t *testing.T) {
+	scraper := scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+		id := url[strings.LastIndex(url, "/")+1:]
+		return &ports.ScrapeResult{
+			RawMetadata: []byte(`[{}]`),
+			VideoURL:    "https://cdn/video-" + id + ".mp4",
+			Music:       &ports.MusicTrack{Title: "Song", URL: "https://cdn/music-" + id + ".mp3"},
+		}, nil
+	})
+	var mu sync.Mutex
+	active, most := 0, 0
+	downloader := downloadFunc(func(ctx context.Context, url string) (io.ReadCloser, error) {
+		mu.Lock()
+		active++
+		most = max(most, active)
+		mu.Unlock()
+		// Long enough for the other job's downloads to overlap without a slot
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return io.NopCloser(strings.NewReader("data")), nil
+	})
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{
+		TikTokMusic:            true,
+		MaxConcurrentDownloads: 1,
+	})
+
+	results := o.RunJobs(context.Background(), []string{
+		"https://www.tiktok.com/@user/video/1",
+		"https://www.tiktok.com/@user/video/2",
+		"https://www.tiktok.com/@user/video/3",
+	}, 3, 1)
+	for _, r := range results {
+		if r.Err != nil {
+			t.Fatalf("%s: %v", r.URL, r.Err)
+		}
+		if r.Result.Music == nil {
+			t.Errorf("%s: music not saved", r.URL)
+		}
+	}
+	if most > 1 {
+		t.Errorf("%d downloads ran at once, want at most 1", most)
+	}
+}
+
+func TestMusicTrackGetsRequestedDigests(t *testing.T) {
+	scraper := &fakeScraper{result: &ports.ScrapeResult{
+		RawMetadata: []byte(`[{}]`),
+		VideoURL:    "https://cdn/v.mp4",
+		Music:       &ports.MusicTrack{Title: "Song", URL: "https://cdn/m.mp3"},
+	}}
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video", "https://cdn/m.mp3": "music"}}
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{TikTokMusic: true, WriteManifest: true, Hashes: []string{"md5"}})
+
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest domain.Manifest
+	if err := json.Unmarshal([]byte(readJobFile(t, o, result.Job.ID, "manifest.json")), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	sha := sha256.Sum256([]byte("music"))
+	md := md5.Sum([]byte("music"))
+	for _, a := range manifest.Artifacts {
+		if a.Name != musicFile {
+			continue
+		}
+		if a.SHA256 != hex.EncodeToString(sha[:]) || a.Digests["md5"] != hex.EncodeToString(md[:]) {
+			t.Errorf("music checksums = %s, %v, want the track's SHA-256 and MD5", a.SHA256, a.Digests)
+		}
+		return
+	}
+	t.Errorf("%s not in the manifest", musicFile)
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// Candidate JSON paths per normalized field, covering the Apify YouTube and
+// TikTok actors and oEmbed responses. The first present path wins; TikTok has
+// no title, so its caption fills both title and description.
+var (
+	titlePaths       = []string{"title", "text"}
+	authorPaths      = []string{"channelName", "authorMeta.name", "author_name"}
+	descriptionPaths = []string{"text", "description"}
+	durationPaths    = []string{"duration", "videoMeta.duration"}
+	viewPaths        = []string{"viewCount", "playCount"}
+	likePaths        = []string{"likes", "diggCount"}
+	publishedPaths   = []string{"date", "createTimeISO"}
+	thumbnailPaths   = []string{"thumbnailUrl", "videoMeta.coverUrl", "thumbnail_url"}
+	liveStatusPaths  = []string{"live_status", "liveStatus"}
+	isLivePaths      = []string{"is_live", "isLive"}
+	wasLivePaths     = []string{"was_live", "wasLive"}
+)
+
+// NormalizeMetadata maps the first dataset item of raw onto VideoMetadata.
+func NormalizeMetadata(raw []byte) (*domain.VideoMetadata, error) {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	if items, ok := doc.([]interface{}); ok {
+		if len(items) == 0 {
+			return &domain.VideoMetadata{}, nil
+		}
+		doc = items[0]
+	}
+
+	meta := &domain.VideoMetadata{
+		Title:        firstString(doc, titlePaths),
+		Author:       firstString(doc, authorPaths),
+		Description:  firstString(doc, descriptionPaths),
+		PublishedAt:  firstString(doc, publishedPaths),
+		ThumbnailURL: firstString(doc, thumbnailPaths),
+		ViewCount:    int64(firstNumber(doc, viewPaths)),
+		LikeCount:    int64(firstNumber(doc, likePaths)),
+	}
+	for _, path := range durationPaths {
+		if v, ok := lookupPath(doc, path); ok {
+			meta.DurationSeconds = durationValue(v)
+			break
+		}
+	}
+	if tags, ok := lookupPath(doc, "hashtags"); ok {
+		meta.Tags = tagValues(tags)
+	}
+	meta.LiveStatus = string(ports.ParseLiveStatus(firstString(doc, liveStatusPaths), firstBool(doc, isLivePaths), firstBool(doc, wasLivePaths)))
+	return meta, nil
+}
+
+// processMetadata normalizes raw, fills its gaps from the page's OpenGraph
+// tags if Options.OpenGraph is set, runs the configured processors and
+// returns the result as JSON.
+func (o *Orchestrator) processMetadata(ctx context.Context, job domain.Job, raw []byte, page *jobPage) ([]byte, error) {
+	meta, err := NormalizeMetadata(raw)
+	if err != nil {
+		return nil, err
+	}
+	if o.opts.OpenGraph {
+		if data, err := page.load(ctx, o); err != nil {
+			o.logger.Printf("[JOB %s] WARNING: failed to fetch page for OpenGraph tags: %v", job.ID, err)
+		} else if filled := fillFromOpenGraph(meta, ExtractOpenGraph(data)); filled > 0 {
+			o.logger.Printf("[JOB %s] Filled %d metadata fields from OpenGraph tags", job.ID, filled)
+		}
+	}
+	for i, p := range o.opts.MetadataProcessors {
+		processed, err := p.Process(ctx, meta, raw)
+		if err != nil {
+			o.logger.Printf("[JOB %s] WARNING: metadata processor %d failed: %v", job.ID, i+1, err)
+			continue
+		}
+		if processed != nil {
+			meta = processed
+		}
+	}
+	return json.MarshalIndent(meta, "", "  ")
+}
+
+func firstString(doc interface{}, paths []string) string {
+	for _, path := range paths {
+		if v, ok := lookupPath(doc, path); ok {
+			if s, ok := v.(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func firstNumber(doc interface{}, paths []string) float64 {
+	for _, path := range paths {
+		v, ok := lookupPath(doc, path)
+		if !ok {
+			continue
+		}
+		switch n := v.(type) {
+		case float64:
+			return n
+		case string:
+			if f, err := strconv.ParseFloat(strings.ReplaceAll(n, ",", ""), 64); err == nil {
+				return f
+			}
+		}
+	}
+	return 0
+}
+
+func firstBool(doc interface{}, paths []string) bool {
+	for _, path := range paths {
+		if v, ok := lookupPath(doc, path); ok {
+			if b, ok := v.(bool); ok {
+				return b
+			}
+		}
+	}
+	return false
+}
+
+// durationValue accepts seconds as a number or numeric string, or "[HH:]MM:SS".
+func durationValue(v interface{}) float64 {
+	switch d := v.(type) {
+	case float64:
+		return d
+	case string:
+		if secs, err := strconv.ParseFloat(d, 64); err == nil {
+			return secs
+		}
+		var total float64
+		for _, part := range strings.Split(d, ":") {
+			n, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return 0
+			}
+			total = total*60 + n
+		}
+		return total
+	}
+	return 0
+}
+
+// tagValues reads hashtags given as strings or as {"name": ...} objects.
+func tagValues(v interface{}) []string {
+	items, _ := v.([]interface{})
+	var tags []string
+	for _, item := range items {
+		switch t := item.(type) {
+		case string:
+			tags = append(tags, strings.TrimPrefix(t, "#"))
+		case map[string]interface{}:
+			if name, ok := t["name"].(string); ok && name != "" {
+				tags = append(tags, name)
+			}
+		}
+	}
+	return tags
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// processFunc adapts a function to ports.MetadataProcessor.
+type processFunc func(ctx context.Context, meta *domain.VideoMetadata, raw []byte) (*domain.VideoMetadata, error)
+
+func (f processFunc) Process(ctx context.Context, meta *domain.VideoMetadata, raw []byte) (*domain.VideoMetadata, error) {
+	return f(ctx, meta, raw)
+}
+
+// withExtra returns a processor setting meta.Extra[key] to value, on a copy.
+func withExtra(key string, value interface{}) processFunc {
+	return func(ctx context.Context, meta *domain.VideoMetadata, raw []byte) (*domain.VideoMetadata, error) {
+		processed := *meta
+		processed.Extra = map[string]interface{}{}
+		for k, v := range meta.Extra {
+			processed.Extra[k] = v
+		}
+		processed.Extra[key] = value
+		return &processed, nil
+	}
+}
+
+func TestMetadataProcessors(t *testing.T) {
+	const raw = `[{"text": "a caption", "authorMeta": {"name": "user"}, "playCount": 42}]`
+	failing := processFunc(func(ctx context.Context, meta *domain.VideoMetadata, raw []byte) (*domain.VideoMetadata, error) {
+		return nil, errFake
+	})
+	var sawRaw string
+	recording := processFunc(func(ctx context.Context, meta *domain.VideoMetadata, data []byte) (*domain.VideoMetadata, error) {
+		sawRaw = string(data)
+		return nil, nil
+	})
+
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(raw), VideoURL: "https://cdn/v.mp4"}}
+	o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, nil,
+		Options{MetadataProcessors: []ports.MetadataProcessor{withExtra("language", "en"), failing, recording, withExtra("nsfw", false)}})
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var meta domain.VideoMetadata
+	if err := json.Unmarshal([]byte(readJobFile(t, o, result.Job.ID, "metadata_normalized.json")), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "a caption" || meta.Author != "user" || meta.ViewCount != 42 {
+		t.Errorf("normalized metadata = %+v, want the scraped fields", meta)
+	}
+	if meta.Extra["language"] != "en" || meta.Extra["nsfw"] != false || len(meta.Extra) != 2 {
+		t.Errorf("Extra = %v, want both processors' fields", meta.Extra)
+	}
+	if sawRaw != raw {
+		t.Errorf("processor got raw %q, want the scraped response", sawRaw)
+	}
+}
+
+func TestNoMetadataProcessors(t *testing.T) {
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+	o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, nil, Options{})
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(o.storage.GetJobPath(result.Job.ID), "metadata_normalized.json")); !os.IsNotExist(err) {
+		t.Errorf("metadata_normalized.json without processors: %v, want none", err)
+	}
+}
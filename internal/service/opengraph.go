@@ -0,0 +1,77 @@
+package service
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"scrapeanddown/internal/core/domain"
+)
+
+var (
+	metaTagPattern = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrPattern    = regexp.MustCompile(`(?is)([a-z:_-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+)
+
+// ExtractOpenGraph returns the OpenGraph tags of a page (og:title,
+// og:image, og:video:duration, ...) and the video:* tags that accompany
+// them, keyed by property. The first non-empty tag of each property wins,
+// as OpenGraph puts the preferred value first. Values are HTML-unescaped.
+func ExtractOpenGraph(page []byte) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range metaTagPattern.FindAll(page, -1) {
+		attrs := make(map[string]string)
+		for _, m := range attrPattern.FindAllSubmatch(tag, -1) {
+			attrs[strings.ToLower(string(m[1]))] = strings.Trim(string(m[2]), `"'`)
+		}
+		// Some sites use name= instead of property=
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		key = strings.ToLower(key)
+		content, ok := attrs["content"]
+		if !ok || !(strings.HasPrefix(key, "og:") || strings.HasPrefix(key, "video:")) {
+			continue
+		}
+		value := strings.TrimSpace(html.UnescapeString(content))
+		if _, seen := tags[key]; !seen && value != "" {
+			tags[key] = value
+		}
+	}
+	return tags
+}
+
+// fillFromOpenGraph fills meta's empty fields from OpenGraph tags, leaving
+// what the scrape found alone. It returns the number of fields filled.
+func fillFromOpenGraph(meta *domain.VideoMetadata, tags map[string]string) int {
+	filled := 0
+	fill := func(field *string, keys ...string) {
+		if *field != "" {
+			return
+		}
+		for _, key := range keys {
+			if v := tags[key]; v != "" {
+				*field = v
+				filled++
+				return
+			}
+		}
+	}
+	fill(&meta.Title, "og:title")
+	fill(&meta.Description, "og:description")
+	fill(&meta.ThumbnailURL, "og:image:secure_url", "og:image", "og:image:url")
+	fill(&meta.PublishedAt, "video:release_date")
+
+	if meta.DurationSeconds == 0 {
+		for _, key := range []string{"og:video:duration", "video:duration"} {
+			if secs, err := strconv.ParseFloat(tags[key], 64); err == nil && secs > 0 {
+				meta.DurationSeconds = secs
+				filled++
+				break
+			}
+		}
+	}
+	return filled
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"scrapeanddown/internal/adapters/downloader"
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+const openGraphPage = `<!DOCTYPE html>
+<html><head>
+<meta charset="utf-8">
+<META PROPERTY="og:title" CONTENT="Tom &amp; Jerry">
+<meta content='A short clip' property='og:description'/>
+<meta property="og:image" content="https://cdn/thumb-large.jpg">
+<meta property="og:image" content="https://cdn/thumb-small.jpg">
+<meta property="og:video:duration" content=" 42 ">
+<meta name="video:release_date" content="2024-06-12">
+<meta property="og:site_name" content="">
+<meta name="description" content="not OpenGraph">
+<meta property="twitter:title" content="not OpenGraph either">
+</head><body></body></html>`
+
+func TestExtractOpenGraph(t *testing.T) {
+	want := map[string]string{
+		"og:title":           "Tom & Jerry",
+		"og:description":     "A short clip",
+		"og:image":           "https://cdn/thumb-large.jpg",
+		"og:video:duration":  "42",
+		"video:release_date": "2024-06-12",
+	}
+	if got := ExtractOpenGraph([]byte(openGraphPage)); !maps.Equal(got, want) {
+		t.Errorf("ExtractOpenGraph = %v, want %v", got, want)
+	}
+	if got := ExtractOpenGraph([]byte("<html><title>No tags</title></html>")); len(got) != 0 {
+		t.Errorf("ExtractOpenGraph of a page without tags = %v", got)
+	}
+}
+
+func TestFillFromOpenGraph(t *testing.T) {
+	tags := ExtractOpenGraph([]byte(openGraphPage))
+	tests := []struct {
+		name       string
+		meta       domain.VideoMetadata
+		want       domain.VideoMetadata
+		wantFilled int
+	}{
+		{
+			"fills empty fields",
+			domain.VideoMetadata{Author: "user"},
+			domain.VideoMetadata{Title: "Tom & Jerry", Author: "user", Description: "A short clip", DurationSeconds: 42,
+				PublishedAt: "2024-06-12", ThumbnailURL: "https://cdn/thumb-large.jpg"},
+			5,
+		},
+		{
+			"keeps scraped fields",
+			domain.VideoMetadata{Title: "Scraped", DurationSeconds: 10, ThumbnailURL: "https://cdn/scraped.jpg"},
+			domain.VideoMetadata{Title: "Scraped", Description: "A short clip", DurationSeconds: 10,
+				PublishedAt: "2024-06-12", ThumbnailURL: "https://cdn/scraped.jpg"},
+			2,
+		},
+	}
+	for _, tt := range tests {
+		meta := tt.meta
+		if filled := fillFromOpenGraph(&meta, tags); filled != tt.wantFilled {
+			t.Errorf("%s: filled %d fields, want %d", tt.name, filled, tt.wantFilled)
+		}
+		if meta.Title != tt.want.Title || meta.Author != tt.want.Author || meta.Description != tt.want.Description ||
+			meta.DurationSeconds != tt.want.DurationSeconds || meta.PublishedAt != tt.want.PublishedAt || meta.ThumbnailURL != tt.want.ThumbnailURL {
+			t.Errorf("%s: got %+v, want %+v", tt.name, meta, tt.want)
+		}
+	}
+}
+
+// The tags fill the normalized metadata, from the same fetch that saves
+// page.html.
+func TestRunJobOpenGraph(t *testing.T) {
+	pages := &pageServer{pageStatus: http.StatusOK, page: openGraphPage}
+	srv := httptest.NewServer(pages)
+	defer srv.Close()
+	raw := `[{"title": "Scraped title", "channelName": "C"}]`
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(raw), VideoURL: srv.URL + "/v.mp4"}}
+	o, _ := newTestOrchestrator(t, scraper, downloader.NewHTTPDownloader(), nil, Options{OpenGraph: true, SavePageHTML: true})
+
+	result, err := o.RunJob(context.Background(), srv.URL+"/video/1")
+	if err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+	var meta domain.VideoMetadata
+	if err := json.Unmarshal([]byte(readJobFile(t, o, result.Job.ID, "metadata_normalized.json")), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Title != "Scraped title" || meta.Author != "C" || meta.Description != "A short clip" || meta.DurationSeconds != 42 {
+		t.Errorf("normalized metadata = %+v", meta)
+	}
+	if readJobFile(t, o, result.Job.ID, "page.html") != openGraphPage {
+		t.Error("page.html isn't the fetched page")
+	}
+	if pages.fetches != 1 {
+		t.Errorf("fetched the page %d times, want 1", pages.fetches)
+	}
+}
@@ -3,26 +3,212 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
-	"scrapeanddown/internal/adapters/ytdlp"
+	"scrapeanddown/internal/adapters/tempdir"
 	"scrapeanddown/internal/core/domain"
 	"scrapeanddown/internal/core/ports"
 )
 
+// retryBackoff is the base delay between RunJobWithRetry attempts; a
+// variable so tests can shorten it.
+var retryBackoff = 5 * time.Second
+
+// Options controls optional steps of a job.
+type Options struct {
+	// WriteManifest writes manifest.json listing every artifact as the final step.
+	WriteManifest bool
+
+	// MaxResolveRetries bounds how many times an expired download URL is
+	// re-resolved and the download retried.
+	MaxResolveRetries int
+
+	// TempDir is the root for per-job scratch directories (default os.TempDir()).
+	TempDir string
+
+	// SkipMetadata bypasses the metadata scrape for platforms downloaded via
+	// yt-dlp. Ignored (with a warning) where the scrape provides the video URL.
+	SkipMetadata bool
+
+	// Qualities requests specific renditions (e.g. "1080p", "360p") for
+	// yt-dlp platforms, saved as video_<quality>.mp4. A job's own
+	// WithQuality or WithAudioOnly setting overrides this and SeparateStreams.
+	Qualities []string
+
+	// SeparateStreams downloads the best video-only and audio-only streams of
+	// yt-dlp platforms, unmerged, as video_only.mp4 and audio_only.m4a.
+	// Takes precedence over Qualities.
+	SeparateStreams bool
+
+	// MaxSizeBytes rejects videos over the limit before downloading. Zero
+	// disables the check.
+	MaxSizeBytes int64
+
+	// CheckFreeSpace makes a download start only if storage has room for
+	// the video's estimated size plus FreeSpaceMargin; with an unknown size,
+	// the margin alone is required. Only storage implementing
+	// ports.SpaceReporter is checked, and never for StreamJob, which doesn't
+	// write the video to storage.
+	CheckFreeSpace  bool
+	FreeSpaceMargin int64
+
+	// MinViews, MinDuration and MaxDuration are skip rules checked against
+	// the scraped metadata: a video outside them isn't downloaded, and its
+	// job ends as skipped rather than failed. Zero disables a rule.
+	MinViews    int64
+	MinDuration time.Duration
+	MaxDuration time.Duration
+
+	// Hashes names digests to compute of downloaded videos (and music
+	// tracks) besides SHA-256
+	// (see ParseHashAlgorithms), e.g. "md5" and "sha1" for archives that
+	// need them. They're computed while the video is saved, without reading
+	// it again, and recorded in the manifest and the job result.
+	Hashes []string
+
+	// SkipList names videos never to download: their jobs end as skipped
+	// before anything is fetched or stored, and channel syncs leave them
+	// out without recording them as seen.
+	SkipList *SkipList
+
+	// CheckAvailability confirms each video exists before it is scraped, so
+	// a dead URL fails fast with ErrVideoUnavailable without starting an
+	// Apify run. Videos downloaded via the resolver are checked by it if it
+	// implements ports.AvailabilityChecker, others by AvailabilityChecker.
+	// A check that can't tell lets the job go on.
+	CheckAvailability   bool
+	AvailabilityChecker ports.AvailabilityChecker
+
+	// ContentIndex, when set, de-duplicates identical videos across jobs by
+	// SHA-256: later copies are replaced with a reference to the first.
+	ContentIndex ports.ContentIndex
+
+	// StartRamp, when set, delays each batch worker's first job by a random
+	// time below it, spreading a batch's initial burst of Apify and CDN
+	// requests over that window.
+	StartRamp time.Duration
+
+	// MaxConcurrentScrapes and MaxConcurrentDownloads cap how many of the
+	// orchestrator's jobs are scraping or downloading at once (0 = no cap),
+	// so e.g. a batch can scrape with many workers while fewer download.
+	// CheckAvailability's checks count as scrapes.
+	MaxConcurrentScrapes   int
+	MaxConcurrentDownloads int
+
+	// Results, when set, receives a JSON line (a JobResult) for every job a
+	// RunJobs batch finishes, written as each job completes.
+	Results io.Writer
+
+	// Summary, when set, receives a JSON line (a BatchSummary) for every
+	// RunJobs batch and watched file once it finishes.
+	Summary io.Writer
+
+	// LinkExpander resolves short links (pin.it) to their canonical URL
+	// before the job starts. Without it the short link is used as given.
+	LinkExpander ports.LinkExpander
+
+	// ChannelState records the videos downloaded per channel for SyncChannel.
+	ChannelState ports.ChannelState
+
+	// Checkpoint records the completed entries of ResumeBatch batches.
+	// Without it ResumeBatch runs every entry.
+	Checkpoint ports.BatchCheckpoint
+
+	// MetadataFields, when set, saves only these JSON paths of the dataset
+	// item (e.g. "title", "channel.name") as metadata.json.
+	MetadataFields []string
+
+	// MetadataProcessors, when set, run in order over the normalized metadata,
+	// which is then saved as metadata_normalized.json. A processor error is
+	// logged and its changes are dropped.
+	MetadataProcessors []ports.MetadataProcessor
+
+	// SkipRawMetadata skips saving the full metadata_raw.json.
+	SkipRawMetadata bool
+
+	// Storyboards downloads the storyboard sprite sheets (scrubbing previews)
+	// under storyboards/ for yt-dlp platforms. Missing storyboards are skipped.
+	Storyboards bool
+
+	// Transcript downloads the video's subtitles, or automatic captions, as
+	// subtitles.<lang>.vtt and saves their deduplicated text as
+	// transcript.txt, for resolvers implementing ports.SubtitleLister.
+	// Missing subtitles are skipped.
+	Transcript bool
+
+	// TikTokMusic downloads a TikTok post's background music track as
+	// music.mp3. Posts without a track URL, and failures, are logged and
+	// don't fail the job.
+	TikTokMusic bool
+
+	// Events, when set, receives each job's progress: start, completed
+	// steps, saved artifacts and the outcome (see JobEvent).
+	Events *EventBus
+
+	// SavePageHTML fetches the video page itself and saves it as page.html.
+	// Failures are logged and don't fail the job.
+	SavePageHTML bool
+
+	// OpenGraph fills the normalized metadata's empty fields (title,
+	// description, thumbnail, duration, release date) from the video page's
+	// OpenGraph tags, and saves it as metadata_normalized.json. The page is
+	// fetched once, shared with SavePageHTML. Failures are logged and don't
+	// fail the job.
+	OpenGraph bool
+
+	// AllowDuplicateURLs makes RunJobs run every entry, even when several
+	// name the same video.
+	AllowDuplicateURLs bool
+
+	// OutputTemplate, when set, names each job's main video after its
+	// metadata instead of video.<ext>. Renditions and separate streams keep
+	// their fixed names.
+	OutputTemplate *OutputTemplate
+
+	// JobTimeout, ScrapeTimeout and DownloadTimeout bound a job attempt, each
+	// scrape, and each video download (from when it gets a download slot).
+	// A failure they cause names the budget in its error, e.g. "download
+	// deadline exceeded after 10m0s: ...". Zero means no limit.
+	JobTimeout      time.Duration
+	ScrapeTimeout   time.Duration
+	DownloadTimeout time.Duration
+
+	// LogLevel controls how much is logged; the zero value is LogNormal.
+	LogLevel LogLevel
+
+	// LogElapsed prefixes each job's log lines with the time since the job
+	// (attempt) started, by Now, e.g. "[+3.2s]".
+	LogElapsed bool
+
+	// Now returns the current time; defaults to time.Now. Tests inject a fake clock.
+	Now func() time.Time
+}
+
 // Orchestrator coordinates the scraping workflow.
 type Orchestrator struct {
 	scraper    ports.Scraper
 	downloader ports.Downloader
 	storage    ports.Storage
-	ytDlp      *ytdlp.YtDlpDownloader
-	logger     *log.Logger
+	resolver   ports.URLResolver
+	logger     *leveledLogger
+	temp       *tempdir.Manager
+	now        func() time.Time
+	opts       Options
+
+	resultsMu sync.Mutex // Serializes writes to Options.Results
+
+	scrapeSlots   stageLimit
+	downloadSlots stageLimit
 }
 
 // NewOrchestrator creates a new Orchestrator.
@@ -30,127 +216,1047 @@ func NewOrchestrator(
 	scraper ports.Scraper,
 	downloader ports.Downloader,
 	storage ports.Storage,
-	ytDlp *ytdlp.YtDlpDownloader,
+	resolver ports.URLResolver,
 	logger *log.Logger,
+	opts Options,
 ) *Orchestrator {
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
 	return &Orchestrator{
 		scraper:    scraper,
 		downloader: downloader,
 		storage:    storage,
-		ytDlp:      ytDlp,
-		logger:     logger,
+		resolver:   resolver,
+		logger:     newLeveledLogger(logger, opts, now),
+		temp:       tempdir.NewManager(opts.TempDir),
+		now:        now,
+		opts:       opts,
+
+		scrapeSlots:   newStageLimit(opts.MaxConcurrentScrapes),
+		downloadSlots: newStageLimit(opts.MaxConcurrentDownloads),
 	}
 }
 
 // RunJob executes a complete scraping job for the given URL.
-func (o *Orchestrator) RunJob(ctx context.Context, url string) (*domain.JobResult, error) {
-	// Generate job ID and create job
+func (o *Orchestrator) RunJob(ctx context.Context, url string) (jobResult *domain.JobResult, err error) {
+	direct := directVideoURLFrom(ctx)
+	if url == "" {
+		url = direct
+	}
+	url = o.expandShortLink(ctx, url)
+	ctx, cancel := phaseContext(ctx, PhaseJob, o.opts.JobTimeout)
+	defer cancel()
+
+	// Generate job ID and create job; retries keep the first attempt's ID
+	attempt := retryAttemptFrom(ctx)
 	jobID := uuid.New().String()
+	if attempt != nil {
+		jobID = attempt.jobID
+	}
 	job := domain.Job{
-		ID:        jobID,
-		URL:       url,
-		Platform:  detectPlatform(url),
-		CreatedAt: time.Now().UTC(),
+		ID:         jobID,
+		ExternalID: externalIDFrom(ctx),
+		URL:        url,
+		VideoURL:   direct,
+		Platform:   detectPlatform(url),
+		CreatedAt:  o.now().UTC(),
 	}
 
 	result := &domain.JobResult{Job: job, Success: false}
-	o.logger.Printf("[JOB %s] Starting job for URL: %s", jobID, url)
+	defer o.logger.startJob(jobID)()
+	logURL := url
+	if url == direct {
+		// A direct video URL's query is usually a signature
+		logURL = RedactURL(url)
+	}
+	o.logger.Printf("[JOB %s] Starting job for URL: %s", jobID, logURL)
+	if job.ExternalID != "" {
+		o.logger.Printf("[JOB %s] External ID: %s", jobID, job.ExternalID)
+	}
+	o.emit(jobID, EventJobStarted, map[string]interface{}{"url": url, "platform": job.Platform})
+	// Deferred first, so it runs after the cleanup below. A retried job's
+	// attempts leave it to RunJobWithRetry, which reports only the last one.
+	if attempt == nil {
+		defer o.emitFinished(result)
+	}
+	defer o.logTimings(result)
+	// Deferred before the cleanup below, which still runs as a panic unwinds:
+	// the job's lock is released and it is marked failed
+	defer func() {
+		if p := recover(); p != nil {
+			jobResult, err = result, o.panicked(result, p)
+		}
+	}()
+	if direct != "" {
+		if err := ValidateVideoURL(direct); err != nil {
+			return result, o.fail(result, domain.StepResolve, err, err.Error())
+		}
+	}
+	// Before the job directory, so an ignored video leaves nothing behind
+	if reason := o.ignoreReason(url, ""); reason != "" {
+		o.skip(result, reason)
+		return result, nil
+	}
 
-	if err := o.storage.InitJob(ctx, jobID); err != nil {
-		result.ErrorMessage = fmt.Sprintf("failed to init job: %v", err)
-		o.logger.Printf("[JOB %s] ERROR: %s", jobID, result.ErrorMessage)
-		return result, err
+	// Scratch space for intermediate files, removed whether the job succeeds or fails
+	scratchDir, err := o.temp.JobDir(jobID)
+	if err != nil {
+		return result, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to create temp dir: %v", err))
 	}
+	defer func() {
+		if err := o.temp.Cleanup(jobID); err != nil {
+			o.logger.Printf("[JOB %s] WARNING: %v", jobID, err)
+		}
+	}()
+	ctx = tempdir.WithDir(ctx, scratchDir)
+	ctx = ports.WithJobInfo(ctx, ports.JobInfo{Platform: job.Platform, CreatedAt: job.CreatedAt, ExternalID: job.ExternalID})
+
+	if attempt == nil || !attempt.initialized {
+		if err := o.storage.InitJob(ctx, jobID); err != nil {
+			return result, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to init job: %v", err))
+		}
+	}
+	if attempt != nil {
+		// Held across attempts: RunJobWithRetry writes the marker and
+		// releases the job once the last one is done
+		attempt.initialized = true
+	} else {
+		defer o.releaseJob(ctx, jobID)
+		// Runs before the lock is released, after everything else
+		defer o.writeMarker(ctx, result)
+	}
+
+	var artifacts []artifactRecord
+	if attempt != nil {
+		o.clearStaleArtifacts(ctx, jobID, attempt)
+		defer func() { attempt.saved = artifacts }()
+	}
+	page := &jobPage{url: job.URL}
 
 	inputData, _ := json.MarshalIndent(job, "", "  ")
-	_ = o.storage.SaveInput(ctx, jobID, inputData)
+	if err := o.storage.SaveInput(ctx, jobID, inputData); err == nil {
+		o.addArtifact(jobID, &artifacts, newArtifactRecord("input.json", "input", inputData))
+	}
 
-	// Step 3: Scrape Metadata (Apify)
-	o.logger.Printf("[JOB %s] Scraping metadata via Apify...", jobID)
-	scrapeResult, err := o.scraper.Scrape(ctx, url)
-	if err != nil {
-		result.ErrorMessage = fmt.Sprintf("failed to scrape metadata: %v", err)
-		o.logger.Printf("[JOB %s] ERROR: %s", jobID, result.ErrorMessage)
-		return result, err
-	}
-	o.logger.Printf("[JOB %s] Apify scrape completed, saved metadata", jobID)
-
-	if err := o.storage.SaveMetadata(ctx, jobID, scrapeResult.RawMetadata); err != nil {
-		result.ErrorMessage = fmt.Sprintf("failed to save metadata: %v", err)
-		return result, err
-	}
-	result.MetadataPath = o.storage.GetJobPath(jobID) + "/metadata_raw.json"
-
-	// Step 4: Get Video URL via yt-dlp
-	var videoDownloadURL string
-	
-	if job.Platform == "youtube" {
-		o.logger.Printf("[JOB %s] Fetching download link via yt-dlp...", jobID)
-		ytUrl, ytErr := o.ytDlp.GetVideoURL(ctx, url)
-		if ytErr == nil && ytUrl != "" {
-			videoDownloadURL = ytUrl
-			o.logger.Printf("[JOB %s] Success: Got video URL from yt-dlp", jobID)
-		} else {
-			result.ErrorMessage = fmt.Sprintf("yt-dlp failed: %v", ytErr)
-			o.logger.Printf("[JOB %s] ERROR: %s", jobID, result.ErrorMessage)
-			return result, ytErr
+	// A batched scrape already found the video
+	if o.opts.CheckAvailability && direct == "" && !hasPrescraped(ctx) {
+		if err := o.checkAvailable(ctx, job); err != nil {
+			return result, o.fail(result, domain.StepPreflight, err, err.Error())
 		}
+	}
+
+	// Step 3: Scrape Metadata (Apify)
+	var scrapeResult *ports.ScrapeResult
+	skipMetadata := o.opts.SkipMetadata && usesYtDlp(job.Platform)
+	if o.opts.SkipMetadata && !skipMetadata && direct == "" {
+		o.logger.Printf("[JOB %s] WARNING: -no-metadata ignored, %s needs the scrape for its video URL", jobID, job.Platform)
+	}
+	if direct != "" {
+		o.logger.Printf("[JOB %s] Direct video URL given, skipping metadata scrape and URL resolution", jobID)
+	} else if skipMetadata {
+		o.logger.Printf("[JOB %s] Skipping metadata scrape", jobID)
 	} else {
-		// TikTok fallback logic (Apify)
-		videoDownloadURL = scrapeResult.VideoURL
+		scrapeResult, err = o.scrapeMetadata(ctx, job, result, page, &artifacts)
+		if err != nil {
+			return result, err
+		}
+		o.emitStep(jobID, domain.StepScrape)
 	}
 
-	if videoDownloadURL == "" {
-		return result, fmt.Errorf("no video url resolved")
+	// A job given only the direct URL has no page to save
+	if o.opts.SavePageHTML && job.URL != direct {
+		o.savePage(ctx, job, page, &artifacts)
 	}
 
-	// Step 5: Download
-	o.logger.Printf("[JOB %s] Downloading video stream...", jobID)
-	videoReader, err := o.downloader.Download(ctx, videoDownloadURL)
-	if err != nil {
-		result.ErrorMessage = fmt.Sprintf("failed to download video: %v", err)
-		o.logger.Printf("[JOB %s] ERROR: %s", jobID, result.ErrorMessage)
-		return result, err
+	// Pre-flight: skip unwanted videos and reject oversized ones before downloading.
+	// Without metadata there is nothing to check a direct URL against.
+	if direct == "" {
+		reason, err := o.preflight(ctx, job, scrapeResult, true)
+		if err != nil {
+			return result, o.fail(result, domain.StepPreflight, err, err.Error())
+		}
+		if reason != "" {
+			o.skip(result, reason)
+			return result, nil
+		}
+		o.emitStep(jobID, domain.StepPreflight)
+	}
+
+	audioOnly, separateStreams, qualities := o.jobFormats(ctx)
+	_, canSelectQuality := o.resolver.(ports.QualityResolver)
+	_, canSeparateStreams := o.resolver.(ports.StreamsResolver)
+	if direct == "" && (audioOnly || separateStreams) && usesYtDlp(job.Platform) && canSeparateStreams {
+		// Steps 4+5 per stream
+		if err := o.downloadSeparateStreams(ctx, job, result, &artifacts, audioOnly); err != nil {
+			return result, err
+		}
+		o.emitStep(jobID, domain.StepDownload)
+	} else if direct == "" && len(qualities) > 0 && usesYtDlp(job.Platform) && canSelectQuality {
+		// Steps 4+5 per rendition
+		if err := o.downloadRenditions(ctx, job, result, &artifacts, qualities); err != nil {
+			return result, err
+		}
+		o.emitStep(jobID, domain.StepDownload)
+	} else {
+		if direct != "" && (audioOnly || separateStreams || len(qualities) > 0) {
+			o.logger.Printf("[JOB %s] WARNING: format options ignored, downloading the direct video URL as is", jobID)
+		} else if audioOnly {
+			o.logger.Printf("[JOB %s] WARNING: audio-only not supported, downloading default for %s", jobID, job.Platform)
+		} else if separateStreams {
+			o.logger.Printf("[JOB %s] WARNING: separate streams not supported, downloading default for %s", jobID, job.Platform)
+		} else if len(qualities) > 0 {
+			o.logger.Printf("[JOB %s] WARNING: quality renditions not supported, downloading default for %s", jobID, job.Platform)
+		}
+
+		// Step 4: Get Video URL via yt-dlp, unless it was given
+		video := &resolvedVideo{URL: direct}
+		resolve := func() (*resolvedVideo, error) { return nil, errDirectURLExpired }
+		if direct == "" {
+			markStart(&result.Timings.ResolveStartedAt, o.now())
+			video, err = o.resolveVideoURL(ctx, job, result, scrapeResult)
+			result.Timings.ResolveEndedAt = o.now()
+			if err != nil {
+				err = attributeDeadline(ctx, err)
+				return result, o.fail(result, domain.StepResolve, err, err.Error())
+			}
+			o.emitStep(jobID, domain.StepResolve)
+			resolve = func() (*resolvedVideo, error) {
+				return o.resolveVideoURL(ctx, job, result, nil)
+			}
+		}
+
+		// Step 5: Download
+		filename := defaultVideoFile
+		if o.opts.OutputTemplate != nil {
+			filename = o.opts.OutputTemplate.render(videoTemplateFields(job, scrapeResult))
+			o.logger.Verbosef("[JOB %s] Output template rendered %s", jobID, filename)
+		}
+		markStart(&result.Timings.DownloadStartedAt, o.now())
+		saved, step, err := o.downloadVideo(ctx, job, video, filename, resolve, nil)
+		result.Timings.DownloadEndedAt = o.now()
+		if err != nil {
+			return result, o.fail(result, step, err, err.Error())
+		}
+		result.DownloadBytes = saved.bytes
+		result.DownloadDuration = saved.duration
+		result.AvgThroughputBytesPerSec = throughput(result.DownloadBytes, result.DownloadDuration)
+		result.VideoDigests = saved.artifact.digests
+		result.VideoPath = o.storage.GetJobPath(jobID) + "/" + saved.artifact.name
+		o.logger.Printf("[JOB %s] Saved %s", jobID, saved.artifact.name)
+
+		duplicate, err := o.dedupVideo(ctx, job, result, saved.artifact)
+		if err != nil {
+			return result, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to de-duplicate video: %v", err))
+		}
+		if !duplicate {
+			o.addArtifact(jobID, &artifacts, saved.artifact)
+		}
+		o.emitStep(jobID, domain.StepDownload)
 	}
-	defer videoReader.Close()
 
-	if err := o.storage.SaveVideo(ctx, jobID, videoReader, "video.mp4"); err != nil {
-		result.ErrorMessage = fmt.Sprintf("failed to save video: %v", err)
-		return result, err
+	// Both look the video up by its page
+	if o.opts.Storyboards && direct == "" {
+		o.saveStoryboards(ctx, job, &artifacts)
+	}
+	if o.opts.Transcript && direct == "" {
+		o.saveTranscript(ctx, job, &artifacts)
+	}
+	if o.opts.TikTokMusic && job.Platform == "tiktok" {
+		o.saveMusic(ctx, job, result, scrapeResult, &artifacts)
+	}
+
+	// Step 6: Manifest (final step)
+	if o.opts.WriteManifest {
+		if err := o.writeManifest(ctx, job, artifacts); err != nil {
+			return result, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to write manifest: %v", err))
+		}
+		o.logger.Printf("[JOB %s] Saved manifest.json", jobID)
+		o.emit(jobID, EventArtifactSaved, map[string]interface{}{"name": "manifest.json", "kind": "manifest"})
 	}
-	result.VideoPath = o.storage.GetJobPath(jobID) + "/video.mp4"
-	o.logger.Printf("[JOB %s] Saved video.mp4", jobID)
 
 	// Success
 	result.Success = true
-	result.CompletedAt = time.Now().UTC()
-	
+	result.CompletedAt = o.now().UTC()
+
 	o.logger.Printf("[JOB %s] Job completed successfully!", jobID)
 	o.logger.Printf("[JOB %s] Artifacts saved to: %s", jobID, o.storage.GetJobPath(jobID))
 
-	// Print summary
-	fmt.Println("\n=== Job Summary ===")
-	fmt.Printf("Job ID:       %s\n", result.Job.ID)
-	fmt.Printf("Platform:     %s\n", result.Job.Platform)
-	fmt.Printf("Success:      %v\n", result.Success)
-	if !result.Success {
-		fmt.Printf("Error:        %s\n", result.ErrorMessage)
-	} else {
-		fmt.Printf("Metadata:     %s\n", result.MetadataPath)
-		fmt.Printf("Video:        %s\n", result.VideoPath)
+	// Print summary alongside the log, so stdout can carry other output
+	if o.logger.level >= LogNormal {
+		out := o.logger.Writer()
+		fmt.Fprintln(out, "\n=== Job Summary ===")
+		fmt.Fprintf(out, "Job ID:       %s\n", result.Job.ID)
+		fmt.Fprintf(out, "Platform:     %s\n", result.Job.Platform)
+		fmt.Fprintf(out, "Success:      %v\n", result.Success)
+		if !result.Success {
+			fmt.Fprintf(out, "Error:        %s\n", result.ErrorMessage)
+		} else {
+			fmt.Fprintf(out, "Metadata:     %s\n", result.MetadataPath)
+			fmt.Fprintf(out, "Video:        %s\n", result.VideoPath)
+			if result.Music != nil {
+				fmt.Fprintf(out, "Music:        %s\n", result.Music.Path)
+			}
+		}
+		fmt.Fprintf(out, "Completed At: %s\n", result.CompletedAt.Format(time.RFC3339))
 	}
-	fmt.Printf("Completed At: %s\n", result.CompletedAt.Format(time.RFC3339))
 
 	return result, nil
 }
 
+// RunJobWithRetry runs the job up to maxAttempts times, retrying only failures
+// marked retryable, with a linear backoff between attempts. No retry is
+// started once the context is draining (see WithDrain). All attempts run as
+// one job, in one directory; each retry first removes the artifacts of the
+// failed attempt it can't reuse, such as a partial video. The job_finished
+// event is published once, for the last attempt.
+func (o *Orchestrator) RunJobWithRetry(ctx context.Context, url string, maxAttempts int) (result *domain.JobResult, err error) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	if maxAttempts > 1 {
+		attempt := &retryAttempt{jobID: uuid.New().String()}
+		ctx = withRetryAttempt(ctx, attempt)
+		defer func() {
+			if attempt.initialized {
+				o.writeMarker(ctx, result)
+				o.releaseJob(ctx, attempt.jobID)
+			}
+			if result != nil {
+				o.emitFinished(result)
+			}
+		}()
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		result, err = o.RunJob(ctx, url)
+		if err == nil {
+			return result, nil
+		}
+
+		var jobErr *domain.JobError
+		if !errors.As(err, &jobErr) || !jobErr.Retryable || attempt == maxAttempts {
+			return result, err
+		}
+
+		if draining(ctx) {
+			return result, err
+		}
+
+		backoff := time.Duration(attempt) * retryBackoff
+		o.logger.Printf("Attempt %d/%d failed at %s step, retrying in %s...", attempt, maxAttempts, jobErr.Step, backoff)
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-drainFrom(ctx):
+			return result, err
+		case <-time.After(backoff):
+		}
+	}
+	return result, err
+}
+
+// fail records a failure on the result, logs it, and wraps err in a
+// domain.JobError carrying the step and whether a retry makes sense.
+func (o *Orchestrator) fail(result *domain.JobResult, step domain.JobStep, err error, message string) error {
+	result.ErrorMessage = message
+	if reason, ok := ports.UnavailableReasonOf(err); ok {
+		result.FailureReason = string(reason)
+	}
+	o.logger.Printf("[JOB %s] ERROR: %s", result.Job.ID, message)
+	return &domain.JobError{Step: step, Err: err, Retryable: isRetryable(step, err)}
+}
+
+// needsFreshURL reports whether a download failed because its resolved URL
+// went stale: rejected as expired, or serving an error page instead of the
+// video.
+func needsFreshURL(err error) bool {
+	return errors.Is(err, ports.ErrURLExpired) || errors.Is(err, ports.ErrNotVideo)
+}
+
+// isRetryable decides whether a failure at the given step may succeed on retry.
+// Storage failures and permanent conditions (missing video, cancellation,
+// a job held by another process, a pinned certificate mismatch) are not retried,
+// nor are videos the platform won't serve, whatever the reason: the cookies
+// that could help an age-restricted video don't change between attempts.
+func isRetryable(step domain.JobStep, err error) bool {
+	switch {
+	case errors.Is(err, context.Canceled),
+		errors.Is(err, ports.ErrVideoUnavailable),
+		errors.Is(err, ports.ErrJobLocked),
+		errors.Is(err, ports.ErrInvalidURL),
+		errors.Is(err, ports.ErrToolNotFound),
+		errors.Is(err, ports.ErrLimitExceeded),
+		errors.Is(err, ports.ErrInsufficientSpace),
+		errors.Is(err, ports.ErrCertMismatch),
+		errors.Is(err, ports.ErrLiveStream),
+		errors.Is(err, ports.ErrNoDirectURL),
+		errors.Is(err, ports.ErrCircuitOpen):
+		return false
+	case step == domain.StepSave:
+		return false
+	}
+	return true
+}
+
+// preflight checks the skip rules, MaxSizeBytes and the free space in
+// storage using the scrape hints and normalized metadata, probing via the
+// resolver when they don't provide the duration or size for a rule or limit.
+// Unknown values pass. It returns why the video should be skipped, if it
+// should, ErrLiveStream if it is a live or upcoming stream, ErrLimitExceeded
+// if it is too large, or ErrInsufficientSpace if it won't fit.
+// toStorage says whether the video will be saved to storage, and so needs
+// its free space.
+func (o *Orchestrator) preflight(ctx context.Context, job domain.Job, scrapeResult *ports.ScrapeResult, toStorage bool) (string, error) {
+	var durationSeconds float64
+	var estimatedBytes int64
+	var views int64
+	var live ports.LiveStatus
+	if scrapeResult != nil {
+		durationSeconds, estimatedBytes = scrapeResult.DurationSeconds, scrapeResult.EstimatedBytes
+		if meta, err := NormalizeMetadata(scrapeResult.RawMetadata); err == nil {
+			if durationSeconds == 0 {
+				durationSeconds = meta.DurationSeconds
+			}
+			views = meta.ViewCount
+			live = ports.LiveStatus(meta.LiveStatus)
+		}
+	}
+	if live.Ongoing() {
+		return "", fmt.Errorf("%w (%s)", ports.ErrLiveStream, live)
+	}
+	if o.opts.MinViews > 0 && views == 0 {
+		o.logger.Printf("[JOB %s] WARNING: view count unknown, minimum views not checked", job.ID)
+	}
+	needDuration := (o.opts.MinDuration > 0 || o.opts.MaxDuration > 0) && durationSeconds == 0
+	needSize := o.opts.MaxSizeBytes > 0 && estimatedBytes == 0
+	prober, canProbe := o.resolver.(ports.Prober)
+	if usesYtDlp(job.Platform) && canProbe && (needDuration || needSize) {
+		info, err := prober.Probe(ctx, job.URL)
+		if err != nil {
+			o.logger.Printf("[JOB %s] WARNING: probe failed, rules and limits not fully checked: %v", job.ID, err)
+		} else {
+			if durationSeconds == 0 {
+				durationSeconds = info.DurationSeconds
+			}
+			if estimatedBytes == 0 {
+				estimatedBytes = info.EstimatedBytes
+			}
+			if live == ports.LiveStatusUnknown {
+				live = info.LiveStatus
+			}
+		}
+	}
+	if live.Ongoing() {
+		return "", fmt.Errorf("%w (%s)", ports.ErrLiveStream, live)
+	}
+	if live.Ended() {
+		o.logger.Printf("[JOB %s] Video is the recording of an ended live stream (%s), downloading all of it", job.ID, live)
+	}
+
+	if o.opts.MaxSizeBytes > 0 && estimatedBytes > o.opts.MaxSizeBytes {
+		return "", fmt.Errorf("%w: estimated size %d bytes exceeds %d", ports.ErrLimitExceeded, estimatedBytes, o.opts.MaxSizeBytes)
+	}
+	if reason := o.skipReason(time.Duration(durationSeconds*float64(time.Second)), views); reason != "" {
+		return reason, nil
+	}
+	if !toStorage || !o.opts.CheckFreeSpace {
+		return "", nil
+	}
+	return "", o.checkFreeSpace(ctx, job, estimatedBytes)
+}
+
+// checkAvailable runs Options.CheckAvailability's check, returning the
+// error of a video found unavailable. A check that fails otherwise, or
+// that has no checker for the platform, is logged and passes.
+func (o *Orchestrator) checkAvailable(ctx context.Context, job domain.Job) error {
+	checker := o.opts.AvailabilityChecker
+	if c, ok := o.resolver.(ports.AvailabilityChecker); ok && usesYtDlp(job.Platform) {
+		checker = c
+	}
+	if checker == nil {
+		o.logger.Verbosef("[JOB %s] No availability check for %s", job.ID, job.Platform)
+		return nil
+	}
+	// It hits the same platforms as a scrape, so it takes a scrape slot
+	if err := o.scrapeSlots.acquire(ctx); err != nil {
+		return err
+	}
+	defer o.scrapeSlots.release()
+	o.logger.Printf("[JOB %s] Checking the video is available...", job.ID)
+	err := checker.CheckAvailable(ctx, job.URL)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ports.ErrVideoUnavailable):
+		return err
+	case errors.Is(err, ports.ErrUnsupportedPlatform):
+		o.logger.Verbosef("[JOB %s] No availability check for %s", job.ID, job.Platform)
+	case ctx.Err() != nil:
+		return ctx.Err()
+	default:
+		o.logger.Printf("[JOB %s] WARNING: availability not checked: %v", job.ID, err)
+	}
+	return nil
+}
+
+// checkFreeSpace returns ErrInsufficientSpace if the storage reports less
+// free space than estimatedBytes (0 if unknown) plus FreeSpaceMargin.
+// Storage that can't report its free space passes.
+func (o *Orchestrator) checkFreeSpace(ctx context.Context, job domain.Job, estimatedBytes int64) error {
+	reporter, ok := o.storage.(ports.SpaceReporter)
+	need := estimatedBytes + o.opts.FreeSpaceMargin
+	if !ok || need <= 0 {
+		return nil
+	}
+	free, err := reporter.FreeSpace(ctx)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		o.logger.Printf("[JOB %s] WARNING: free space not checked: %v", job.ID, err)
+		return nil
+	}
+	if free < need {
+		return fmt.Errorf("%w: %d bytes free, need %d (estimated size %d + margin %d)", ports.ErrInsufficientSpace, free, need, estimatedBytes, o.opts.FreeSpaceMargin)
+	}
+	return nil
+}
+
+// dedupVideo looks the saved video's hash up in the content index. If another
+// job already stored identical bytes, the copy is removed and replaced by a
+// duplicate_of.json reference; it reports whether that happened. A
+// reference to a file that is gone (e.g. its job was removed) is replaced by
+// this job's copy.
+func (o *Orchestrator) dedupVideo(ctx context.Context, job domain.Job, result *domain.JobResult, artifact artifactRecord) (bool, error) {
+	if o.opts.ContentIndex == nil || artifact.sha256 == "" {
+		return false, nil
+	}
+
+	existing, err := o.opts.ContentIndex.LookupOrAdd(ctx, artifact.sha256, ports.ContentRef{JobID: job.ID, Filename: artifact.name})
+	if err != nil || existing == nil || existing.JobID == job.ID {
+		return false, err
+	}
+	ok, err := o.storage.Exists(ctx, existing.JobID, existing.Filename)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		o.logger.Printf("[JOB %s] Identical video of job %s is gone, keeping this copy", job.ID, existing.JobID)
+		return false, o.opts.ContentIndex.Set(ctx, artifact.sha256, ports.ContentRef{JobID: job.ID, Filename: artifact.name})
+	}
+
+	ref, _ := json.MarshalIndent(struct {
+		ports.ContentRef
+		SHA256 string `json:"sha256"`
+	}{*existing, artifact.sha256}, "", "  ")
+	if err := o.storage.SaveReference(ctx, job.ID, ref); err != nil {
+		return false, err
+	}
+	if err := o.storage.RemoveArtifact(ctx, job.ID, artifact.name); err != nil {
+		return false, err
+	}
+
+	result.DuplicateOf = existing.JobID
+	result.VideoPath = o.storage.GetJobPath(existing.JobID) + "/" + existing.Filename
+	o.logger.Printf("[JOB %s] Video is identical to job %s, kept a reference instead of a copy", job.ID, existing.JobID)
+	return true, nil
+}
+
+// savedVideo describes a video stream written to storage.
+type savedVideo struct {
+	artifact artifactRecord
+	bytes    int64
+	duration time.Duration
+}
+
+// downloadVideo downloads videoURL and saves it as filename, with the
+// extension replaced by the container's when the resolver or the response's
+// Content-Type tells it (see withContainerExt); the artifact has the name
+// used. When the URL has expired it asks resolve for a fresh one, up to
+// MaxResolveRetries times.
+// Progress is persisted as download state so the download can be resumed
+// after a restart; resume, if set, is the state to continue from.
+// On failure it also returns the step that failed.
+func (o *Orchestrator) downloadVideo(ctx context.Context, job domain.Job, video *resolvedVideo, filename string, resolve func() (*resolvedVideo, error), resume *domain.DownloadState) (_ *savedVideo, _ domain.JobStep, err error) {
+	var offset int64
+	var validator string
+	if resume != nil {
+		offset, validator = o.resumePoint(ctx, job.ID, resume)
+	} else {
+		kept, err := o.keptVideo(ctx, job, video, filename)
+		if err != nil {
+			return nil, domain.StepSave, err
+		}
+		if kept != nil {
+			return kept, "", nil
+		}
+	}
+
+	if err := o.downloadSlots.acquire(ctx); err != nil {
+		return nil, domain.StepDownload, err
+	}
+	holding := true
+	defer func() {
+		if holding {
+			o.downloadSlots.release()
+		}
+	}()
+	ctx, cancel := phaseContext(ctx, PhaseDownload, o.opts.DownloadTimeout)
+	defer cancel()
+	defer func() { err = attributeDeadline(ctx, err) }()
+	if video.resolverFormat != nil {
+		return o.downloadWithResolver(ctx, job, video, filename)
+	}
+
+	o.logger.Printf("[JOB %s] Downloading video stream...", job.ID)
+	resp, err := o.openDownload(ctx, video, offset, validator)
+	// Resolved CDN URLs expire quickly; re-resolve and retry a bounded number of times
+	for attempt := 1; needsFreshURL(err) && attempt <= o.opts.MaxResolveRetries; attempt++ {
+		o.logger.Printf("[JOB %s] Download URL expired, re-resolving (attempt %d/%d)...", job.ID, attempt, o.opts.MaxResolveRetries)
+		// A re-scrape takes a scrape slot; waiting for it while holding a
+		// download slot could deadlock with jobs queued the other way round
+		o.downloadSlots.release()
+		holding = false
+		video, err = resolve()
+		if err != nil {
+			return nil, domain.StepResolve, err
+		}
+		if err := o.downloadSlots.acquire(ctx); err != nil {
+			return nil, domain.StepDownload, err
+		}
+		holding = true
+		if video.resolverFormat != nil {
+			if resume != nil {
+				return nil, domain.StepResolve, fmt.Errorf("cannot resume %s: the fresh format can only be downloaded whole, by the resolver", filename)
+			}
+			return o.downloadWithResolver(ctx, job, video, filename)
+		}
+		resp, err = o.openDownload(ctx, video, offset, validator)
+	}
+	if err != nil {
+		return nil, domain.StepDownload, fmt.Errorf("failed to download video: %w", err)
+	}
+	defer resp.Body.Close()
+	o.logger.Verbosef("[JOB %s] Download response: offset %d, content type %q", job.ID, resp.Offset, resp.ContentType)
+	// A resumed download keeps the name its partial file already has
+	if resume == nil {
+		filename = withContainerExt(filename, video.Ext, resp.ContentType)
+	}
+
+	state := &domain.DownloadState{
+		JobID:        job.ID,
+		PageURL:      job.URL,
+		Platform:     job.Platform,
+		VideoURL:     video.URL,
+		Headers:      video.Headers,
+		TargetFile:   filename,
+		BytesWritten: resp.Offset,
+		ETag:         resp.ETag,
+		LastModified: resp.LastModified,
+	}
+	o.saveDownloadState(ctx, state)
+
+	// Every digest is computed as the stream is saved, in one pass
+	hashes := newHashSink(o.opts.Hashes)
+	counter := &countingReader{r: io.TeeReader(resp.Body, hashes)}
+	progress := &progressReader{r: counter, every: downloadStateInterval, onProgress: func(n int64) {
+		state.BytesWritten = resp.Offset + n
+		o.saveDownloadState(ctx, state)
+	}}
+
+	save := o.storage.SaveVideo
+	if resp.Offset > 0 {
+		o.logger.Printf("[JOB %s] Resuming %s at byte %d", job.ID, filename, resp.Offset)
+		save = o.storage.AppendVideo
+	} else if offset > 0 {
+		o.logger.Printf("[JOB %s] Remote file changed, restarting %s from scratch", job.ID, filename)
+	}
+
+	start := o.now()
+	if err := save(ctx, job.ID, progress, filename); err != nil {
+		// A broken stream is a download failure; anything else is on the storage side
+		if counter.err != nil {
+			return nil, domain.StepDownload, fmt.Errorf("failed to download video: %w", err)
+		}
+		return nil, domain.StepSave, fmt.Errorf("failed to save video: %w", err)
+	}
+	if err := o.storage.RemoveDownloadState(ctx, job.ID); err != nil {
+		o.logger.Printf("[JOB %s] WARNING: %v", job.ID, err)
+	}
+
+	saved := &savedVideo{
+		artifact: artifactRecord{name: filename, kind: "video"},
+		bytes:    counter.n,
+		duration: o.now().Sub(start),
+	}
+	// keptVideo checked the name before the response could change its
+	// extension; the write policy may still keep the file without reading
+	kept := false
+	if counter.n == 0 && resp.Offset == 0 {
+		if info, err := o.storage.StatArtifact(ctx, job.ID, filename); err == nil && info.Size > 0 {
+			o.logger.Printf("[JOB %s] Kept existing %s", job.ID, filename)
+			kept = true
+		}
+	}
+	// The checksum only covers what passed through us, so skip it for resumes
+	if resp.Offset == 0 && !kept {
+		saved.artifact.sha256 = hashes.sha256()
+		saved.artifact.digests = hashes.digests()
+	}
+	return saved, "", nil
+}
+
+// keptVideo applies the storage's write policy (see ports.WriteChecker)
+// before anything is downloaded: if the video, named with the container
+// extension the resolver reported, is to be kept, it returns the kept
+// video, without a checksum as its bytes didn't pass through us.
+func (o *Orchestrator) keptVideo(ctx context.Context, job domain.Job, video *resolvedVideo, filename string) (*savedVideo, error) {
+	checker, ok := o.storage.(ports.WriteChecker)
+	if !ok {
+		return nil, nil
+	}
+	filename = withContainerExt(filename, video.Ext, "")
+	write, err := checker.ShouldWrite(ctx, job.ID, filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save video: %w", err)
+	}
+	if write {
+		return nil, nil
+	}
+	o.logger.Printf("[JOB %s] Kept existing %s", job.ID, filename)
+	return &savedVideo{artifact: artifactRecord{name: filename, kind: "video"}}, nil
+}
+
+// downloadRenditions resolves and downloads each requested quality via the
+// resolver's QualityResolver, saving them as video_<quality>.mp4. Unavailable
+// qualities are skipped with a warning; the job fails only if none succeed.
+func (o *Orchestrator) downloadRenditions(ctx context.Context, job domain.Job, result *domain.JobResult, artifacts *[]artifactRecord, qualities []string) error {
+	qualityResolver := o.resolver.(ports.QualityResolver)
+	var lastErr error
+	var lastStep domain.JobStep
+	for _, quality := range qualities {
+		height, err := qualityHeight(quality)
+		if err != nil {
+			o.logger.Printf("[JOB %s] WARNING: skipping quality %q: %v", job.ID, quality, err)
+			continue
+		}
+
+		resolve := func() (*resolvedVideo, error) {
+			o.logger.Printf("[JOB %s] Fetching %s download link...", job.ID, quality)
+			u, err := qualityResolver.ResolveVideoURLForHeight(ctx, job.URL, height)
+			if err != nil {
+				return nil, fmt.Errorf("url resolver failed for %s: %w", quality, err)
+			}
+			return &resolvedVideo{URL: u}, nil
+		}
+		markStart(&result.Timings.ResolveStartedAt, o.now())
+		video, err := resolve()
+		result.Timings.ResolveEndedAt = o.now()
+		if err != nil {
+			o.logger.Printf("[JOB %s] WARNING: quality %s not available: %v", job.ID, quality, err)
+			lastErr, lastStep = err, domain.StepResolve
+			continue
+		}
+
+		filename := fmt.Sprintf("video_%s.mp4", quality)
+		markStart(&result.Timings.DownloadStartedAt, o.now())
+		saved, step, err := o.downloadVideo(ctx, job, video, filename, resolve, nil)
+		result.Timings.DownloadEndedAt = o.now()
+		if err != nil {
+			o.logger.Printf("[JOB %s] WARNING: quality %s failed: %v", job.ID, quality, err)
+			lastErr, lastStep = err, step
+			continue
+		}
+
+		filename = saved.artifact.name
+		path := o.storage.GetJobPath(job.ID) + "/" + filename
+		result.Renditions = append(result.Renditions, domain.Rendition{
+			Quality: quality,
+			Path:    path,
+			Bytes:   saved.bytes,
+		})
+		result.DownloadBytes += saved.bytes
+		result.DownloadDuration += saved.duration
+		o.addArtifact(job.ID, artifacts, saved.artifact)
+		o.logger.Printf("[JOB %s] Saved %s", job.ID, filename)
+	}
+
+	if len(result.Renditions) == 0 {
+		if lastErr == nil {
+			lastErr, lastStep = fmt.Errorf("no valid qualities requested"), domain.StepResolve
+		}
+		return o.fail(result, lastStep, lastErr, fmt.Sprintf("no renditions downloaded: %v", lastErr))
+	}
+	result.VideoPath = result.Renditions[0].Path
+	result.AvgThroughputBytesPerSec = throughput(result.DownloadBytes, result.DownloadDuration)
+	return nil
+}
+
+// File names of the streams saved with Options.SeparateStreams (and, for
+// the audio, WithAudioOnly).
+const (
+	videoOnlyFile = "video_only.mp4"
+	audioOnlyFile = "audio_only.m4a"
+)
+
+// downloadSeparateStreams downloads the video-only and audio-only streams,
+// or with audioOnly just the audio. Every stream is required: the job fails
+// if any does.
+func (o *Orchestrator) downloadSeparateStreams(ctx context.Context, job domain.Job, result *domain.JobResult, artifacts *[]artifactRecord, audioOnly bool) error {
+	streamsResolver := o.resolver.(ports.StreamsResolver)
+	resolveStream := func(audio bool) func() (*resolvedVideo, error) {
+		return func() (*resolvedVideo, error) {
+			videoURL, audioURL, err := streamsResolver.ResolveSeparateStreams(ctx, job.URL)
+			if err != nil {
+				return nil, fmt.Errorf("url resolver failed for separate streams: %w", err)
+			}
+			if audio {
+				return &resolvedVideo{URL: audioURL}, nil
+			}
+			return &resolvedVideo{URL: videoURL}, nil
+		}
+	}
+
+	if audioOnly {
+		o.logger.Printf("[JOB %s] Fetching audio stream link...", job.ID)
+	} else {
+		o.logger.Printf("[JOB %s] Fetching separate video and audio stream links...", job.ID)
+	}
+	markStart(&result.Timings.ResolveStartedAt, o.now())
+	videoURL, audioURL, err := streamsResolver.ResolveSeparateStreams(ctx, job.URL)
+	result.Timings.ResolveEndedAt = o.now()
+	if err != nil {
+		err = fmt.Errorf("url resolver failed for separate streams: %w", err)
+		return o.fail(result, domain.StepResolve, err, err.Error())
+	}
+
+	type stream struct {
+		filename string
+		url      string
+		audio    bool
+		path     *string
+	}
+	streams := []stream{
+		{videoOnlyFile, videoURL, false, &result.VideoPath},
+		{audioOnlyFile, audioURL, true, &result.AudioPath},
+	}
+	if audioOnly {
+		streams = streams[1:]
+	}
+	for _, stream := range streams {
+		markStart(&result.Timings.DownloadStartedAt, o.now())
+		saved, step, err := o.downloadVideo(ctx, job, &resolvedVideo{URL: stream.url}, stream.filename, resolveStream(stream.audio), nil)
+		result.Timings.DownloadEndedAt = o.now()
+		if err != nil {
+			return o.fail(result, step, err, fmt.Sprintf("failed to download %s: %v", stream.filename, err))
+		}
+		*stream.path = o.storage.GetJobPath(job.ID) + "/" + saved.artifact.name
+		result.DownloadBytes += saved.bytes
+		result.DownloadDuration += saved.duration
+		o.addArtifact(job.ID, artifacts, saved.artifact)
+		o.logger.Printf("[JOB %s] Saved %s", job.ID, saved.artifact.name)
+	}
+	result.AvgThroughputBytesPerSec = throughput(result.DownloadBytes, result.DownloadDuration)
+	return nil
+}
+
+// qualityHeight parses a quality like "1080p" into its frame height.
+func qualityHeight(quality string) (int, error) {
+	height, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(quality), "p"))
+	if err != nil || height <= 0 {
+		return 0, fmt.Errorf("invalid quality %q, expected e.g. 720p", quality)
+	}
+	return height, nil
+}
+
+// scrapeMetadata scrapes the video's metadata and saves it (plus comments, if any).
+func (o *Orchestrator) scrapeMetadata(ctx context.Context, job domain.Job, result *domain.JobResult, page *jobPage, artifacts *[]artifactRecord) (*ports.ScrapeResult, error) {
+	o.logger.Printf("[JOB %s] Scraping metadata via Apify...", job.ID)
+	result.Timings.ScrapeStartedAt = o.now()
+	scrapeResult, err := o.scrape(ctx, job.URL)
+	result.Timings.ScrapeEndedAt = o.now()
+	addScrapeUsage(result, scrapeResult)
+	// Platforms downloaded via the resolver don't need the scrape to succeed
+	if errors.Is(err, ports.ErrUnsupportedPlatform) && usesYtDlp(job.Platform) {
+		o.logger.Printf("[JOB %s] WARNING: no metadata source for %s, continuing without metadata", job.ID, job.Platform)
+		return nil, nil
+	}
+	if errors.Is(err, ports.ErrVideoUnavailable) {
+		return nil, o.fail(result, domain.StepScrape, err, err.Error())
+	}
+	if err != nil {
+		return nil, o.fail(result, domain.StepScrape, err, fmt.Sprintf("failed to scrape metadata: %v", err))
+	}
+	if scrapeResult.FromFallback {
+		result.MetadataFallback = true
+		o.logger.Printf("[JOB %s] WARNING: metadata came from the fallback scraper, basic fields only", job.ID)
+	}
+	o.logger.Printf("[JOB %s] Apify scrape completed, saved metadata", job.ID)
+
+	if len(o.opts.MetadataFields) > 0 {
+		projected, err := projectFields(scrapeResult.RawMetadata, o.opts.MetadataFields)
+		if err != nil {
+			return nil, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to project metadata: %v", err))
+		}
+		if err := o.storage.SaveProjectedMetadata(ctx, job.ID, projected); err != nil {
+			return nil, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to save metadata: %v", err))
+		}
+		result.MetadataPath = o.storage.GetJobPath(job.ID) + "/metadata.json"
+		o.addArtifact(job.ID, artifacts, newArtifactRecord("metadata.json", "metadata", projected))
+	}
+
+	if !o.opts.SkipRawMetadata {
+		if err := o.storage.SaveMetadata(ctx, job.ID, scrapeResult.RawMetadata); err != nil {
+			return nil, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to save metadata: %v", err))
+		}
+		result.MetadataPath = o.storage.GetJobPath(job.ID) + "/metadata_raw.json"
+		o.addArtifact(job.ID, artifacts, newArtifactRecord("metadata_raw.json", "metadata", scrapeResult.RawMetadata))
+	}
+
+	if len(o.opts.MetadataProcessors) > 0 || o.opts.OpenGraph {
+		data, err := o.processMetadata(ctx, job, scrapeResult.RawMetadata, page)
+		if err != nil {
+			return nil, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to normalize metadata: %v", err))
+		}
+		if err := o.storage.SaveNormalizedMetadata(ctx, job.ID, data); err != nil {
+			return nil, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to save metadata: %v", err))
+		}
+		o.addArtifact(job.ID, artifacts, newArtifactRecord("metadata_normalized.json", "metadata", data))
+	}
+
+	if len(scrapeResult.Comments) > 0 {
+		if err := o.storage.SaveComments(ctx, job.ID, scrapeResult.Comments); err != nil {
+			return nil, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to save comments: %v", err))
+		}
+		o.addArtifact(job.ID, artifacts, newArtifactRecord("comments.json", "comments", scrapeResult.Comments))
+		o.logger.Printf("[JOB %s] Saved comments.json", job.ID)
+	}
+
+	if scrapeResult.RunID != "" || scrapeResult.DatasetID != "" {
+		o.saveScrapeMeta(ctx, job, scrapeResult, artifacts)
+	}
+
+	return scrapeResult, nil
+}
+
+// saveScrapeMeta saves the IDs of the run that produced the metadata as
+// scrape_meta.json, to look the run up in the Apify console. Failures are
+// logged: the metadata itself is saved.
+func (o *Orchestrator) saveScrapeMeta(ctx context.Context, job domain.Job, scrapeResult *ports.ScrapeResult, artifacts *[]artifactRecord) {
+	data, _ := json.MarshalIndent(struct {
+		RunID     string `json:"run_id,omitempty"`
+		DatasetID string `json:"dataset_id,omitempty"`
+	}{scrapeResult.RunID, scrapeResult.DatasetID}, "", "  ")
+	if err := o.storage.SaveScrapeMeta(ctx, job.ID, data); err != nil {
+		o.logger.Printf("[JOB %s] WARNING: %v", job.ID, err)
+		return
+	}
+	o.addArtifact(job.ID, artifacts, newArtifactRecord("scrape_meta.json", "metadata", data))
+	o.logger.Printf("[JOB %s] Saved scrape_meta.json (run %s, dataset %s)", job.ID, scrapeResult.RunID, scrapeResult.DatasetID)
+}
+
+// resolvedVideo is a direct download URL plus the HTTP headers the
+// platform's CDN expects for it.
+type resolvedVideo struct {
+	URL     string
+	Headers map[string]string
+	Ext     string // Container extension, if the resolver reported it
+
+	// resolverFormat is set for a ResolverOnly format, which only the
+	// resolver itself can download
+	resolverFormat *ports.ResolvedFormat
+}
+
+// resolveVideoURL returns a direct download URL for the job's video.
+// For YouTube it asks the URL resolver (including the request headers, if
+// the resolver reports them), falling back to a URL found in the scraped
+// metadata if the resolver fails; for other platforms it uses the URL from
+// the scrape result, re-scraping when scrapeResult is nil (e.g. after expiry).
+func (o *Orchestrator) resolveVideoURL(ctx context.Context, job domain.Job, result *domain.JobResult, scrapeResult *ports.ScrapeResult) (*resolvedVideo, error) {
+	if usesYtDlp(job.Platform) {
+		video, err := o.resolveWithResolver(ctx, job)
+		if err == nil {
+			o.logger.Printf("[JOB %s] Success: Got video URL from resolver", job.ID)
+			return video, nil
+		}
+		// A live stream's scraped URL is no more a complete recording
+		if scrapeResult == nil || scrapeResult.VideoURL == "" || ctx.Err() != nil || errors.Is(err, ports.ErrLiveStream) || errors.Is(err, ports.ErrNoDirectURL) {
+			return nil, err
+		}
+		o.logger.Printf("[JOB %s] WARNING: %v; using the video URL from the scraped metadata", job.ID, err)
+		return &resolvedVideo{URL: scrapeResult.VideoURL}, nil
+	}
+
+	// TikTok fallback logic (Apify)
+	if scrapeResult == nil {
+		o.logger.Printf("[JOB %s] Re-scraping via Apify for a fresh video URL...", job.ID)
+		fresh, err := o.scrape(context.WithValue(ctx, freshScrapeKey{}, true), job.URL)
+		addScrapeUsage(result, fresh)
+		if err != nil {
+			return nil, fmt.Errorf("failed to re-scrape metadata: %w", err)
+		}
+		scrapeResult = fresh
+	}
+	if scrapeResult.VideoURL == "" {
+		return nil, fmt.Errorf("no video url resolved")
+	}
+	o.logger.Verbosef("[JOB %s] Using video URL from the scraped metadata: %s", job.ID, RedactURL(scrapeResult.VideoURL))
+	return &resolvedVideo{URL: scrapeResult.VideoURL}, nil
+}
+
+// resolveWithResolver asks the URL resolver for the job's video URL.
+func (o *Orchestrator) resolveWithResolver(ctx context.Context, job domain.Job) (*resolvedVideo, error) {
+	if o.resolver == nil {
+		return nil, fmt.Errorf("no url resolver configured for %s", job.Platform)
+	}
+	o.logger.Printf("[JOB %s] Fetching download link...", job.ID)
+	video := &resolvedVideo{}
+	var err error
+	switch r := o.resolver.(type) {
+	case ports.FormatResolver:
+		var format *ports.ResolvedFormat
+		if format, err = r.ResolveVideoFormat(ctx, job.URL); err == nil {
+			video = &resolvedVideo{URL: format.URL, Headers: format.Headers, Ext: format.Ext}
+			if format.ResolverOnly {
+				video.resolverFormat = format
+			}
+		}
+	case ports.HeaderResolver:
+		video.URL, video.Headers, err = r.ResolveVideoURLWithHeaders(ctx, job.URL)
+	default:
+		video.URL, err = o.resolver.ResolveVideoURL(ctx, job.URL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("url resolver failed: %w", err)
+	}
+	if video.URL == "" {
+		return nil, fmt.Errorf("url resolver failed: empty video url")
+	}
+	o.logger.Verbosef("[JOB %s] Resolved %s (ext %q, %d headers)", job.ID, RedactURL(video.URL), video.Ext, len(video.Headers))
+	return video, nil
+}
+
+// usesYtDlp reports whether the platform's video URL is resolved by the URL
+// resolver (yt-dlp by default) rather than taken from the scraped metadata.
+func usesYtDlp(platform string) bool {
+	return platform == "youtube" || platform == "pinterest"
+}
+
 func detectPlatform(url string) string {
-	if containsAny(url, "youtube.com", "youtu.be") {
+	url = strings.ToLower(url)
+	// youtube.com also covers music.youtube.com and m.youtube.com
+	if containsAny(url, "youtube.com", "youtu.be", "youtube-nocookie.com") {
 		return "youtube"
 	}
 	if containsAny(url, "tiktok.com") {
 		return "tiktok"
 	}
+	// pinterest.com and its country domains (pinterest.co.uk, ...), plus pin.it short links
+	if containsAny(url, "pinterest.", "pin.it") {
+		return "pinterest"
+	}
 	return "unknown"
 }
 
@@ -167,14 +1273,33 @@ func containsAny(s string, substrs ...string) bool {
 	return false
 }
 
-func extractVideoID(videoURL string) string {
-	u, err := url.Parse(videoURL)
-	if err != nil {
-		return ""
+// canonicalURL returns a key identifying the video a URL points at, so that
+// different forms of the same URL compare equal: YouTube URLs reduce to
+// their video ID, others drop the scheme, "www." or "m.", query and fragment.
+func canonicalURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	platform := detectPlatform(rawURL)
+	if platform == "youtube" {
+		if id := ports.YouTubeVideoID(rawURL); id != "" {
+			return "youtube:" + id
+		}
 	}
-	if u.Host == "youtu.be" {
-		return strings.TrimPrefix(u.Path, "/")
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	host := trimHostPrefix(strings.ToLower(u.Hostname()))
+	return platform + ":" + host + strings.TrimSuffix(u.EscapedPath(), "/")
+}
+
+// trimHostPrefix drops a host's "www." or mobile "m." (m.youtube.com,
+// m.tiktok.com), which serve the same videos as the bare host.
+func trimHostPrefix(host string) string {
+	for _, prefix := range []string{"www.", "m."} {
+		if trimmed, ok := strings.CutPrefix(host, prefix); ok {
+			return trimmed
+		}
 	}
-	qty := u.Query()
-	return qty.Get("v")
+	return host
 }
@@ -0,0 +1,390 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/adapters/localstorage"
+	"scrapeanddown/internal/adapters/tempdir"
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// Every job gets its own scratch directory under Options.TempDir, which is
+// removed when the job ends, however it ends.
+func TestJobScratchDirRemoved(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    func() (io.ReadCloser, error)
+		wantErr bool
+	}{
+		{"success", func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("video")), nil }, false},
+		{"download error", func() (io.ReadCloser, error) { return nil, errFake }, true},
+		{"panic", func() (io.ReadCloser, error) { panic("quirky payload") }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempRoot := t.TempDir()
+			var scratch string
+			downloader := downloadFunc(func(ctx context.Context, videoURL string) (io.ReadCloser, error) {
+				dir, ok := tempdir.DirFromContext(ctx)
+				if !ok {
+					t.Error("no scratch directory in the download's context")
+					return nil, errFake
+				}
+				scratch = dir
+				if err := os.WriteFile(filepath.Join(dir, "partial.ts"), []byte("partial"), 0644); err != nil {
+					t.Errorf("scratch directory not usable: %v", err)
+				}
+				return tt.fail()
+			})
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+			o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{TempDir: tempRoot})
+
+			_, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RunJob err = %v, want an error: %v", err, tt.wantErr)
+			}
+			if scratch == "" {
+				t.Fatal("the download never ran")
+			}
+			if rel, err := filepath.Rel(tempRoot, scratch); err != nil || strings.HasPrefix(rel, "..") {
+				t.Errorf("scratch directory %s isn't under %s", scratch, tempRoot)
+			}
+			if _, err := os.Stat(scratch); !os.IsNotExist(err) {
+				t.Errorf("scratch directory left behind: %v", err)
+			}
+		})
+	}
+}
+
+func TestDetectPlatform(t *testing.T) {
+	tests := []struct{ url, want string }{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "youtube"},
+		{"https://music.youtube.com/watch?v=dQw4w9WgXcQ", "youtube"},
+		{"https://youtu.be/dQw4w9WgXcQ", "youtube"},
+		{"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", "youtube"},
+		{"https://www.tiktok.com/@user/video/1", "tiktok"},
+		{"https://WWW.TIKTOK.COM/@user/video/1", "tiktok"},
+		{"https://www.pinterest.com/pin/123/", "pinterest"},
+		{"https://pinterest.co.uk/pin/123/", "pinterest"},
+		{"https://pin.it/abc123", "pinterest"},
+		{"https://vimeo.com/1", "unknown"},
+	}
+	for _, tt := range tests {
+		if got := detectPlatform(tt.url); got != tt.want {
+			t.Errorf("detectPlatform(%s) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
+
+// Every form of YouTube URL is detected as YouTube and yields its video ID.
+func TestYouTubeURLVariants(t *testing.T) {
+	tests := []struct{ url, wantID string }{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"http://youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/watch?feature=share&v=dQw4w9WgXcQ&t=42s", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PL123&index=2", "dQw4w9WgXcQ"},
+		{"https://m.youtube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://WWW.YouTube.com/watch?v=dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://music.youtube.com/watch?v=dQw4w9WgXcQ&feature=share", "dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ?si=abc&t=10", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/shorts/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://youtube.com/shorts/dQw4w9WgXcQ?feature=share", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/embed/dQw4w9WgXcQ?start=30", "dQw4w9WgXcQ"},
+		{"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/live/dQw4w9WgXcQ?si=abc", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/v/dQw4w9WgXcQ", "dQw4w9WgXcQ"},
+		// YouTube, but not a video
+		{"https://www.youtube.com/@channel", ""},
+		{"https://www.youtube.com/playlist?list=PL123", ""},
+	}
+	for _, tt := range tests {
+		if got := detectPlatform(tt.url); got != "youtube" {
+			t.Errorf("detectPlatform(%s) = %s, want youtube", tt.url, got)
+		}
+		if got := ports.YouTubeVideoID(tt.url); got != tt.wantID {
+			t.Errorf("YouTubeVideoID(%s) = %q, want %q", tt.url, got, tt.wantID)
+		}
+	}
+}
+
+// RunJob releases the job's lock when it finishes, whatever the outcome.
+func TestRunJobReleasesLock(t *testing.T) {
+	for _, files := range []map[string]string{{"https://cdn/v.mp4": "video"}, {}} {
+		scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+		o, root := newTestOrchestrator(t, scraper, &fakeDownloader{files: files}, nil, Options{})
+		result, _ := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+		if err := localstorage.NewLocalStorage(root).InitJob(context.Background(), result.Job.ID); err != nil {
+			t.Errorf("InitJob after RunJob (success %v): %v", result.Success, err)
+		}
+	}
+}
+
+// SkipMetadata never scrapes a yt-dlp platform, and is ignored for TikTok,
+// which needs the scrape for its video URL.
+func TestSkipMetadata(t *testing.T) {
+	tests := []struct {
+		url         string
+		wantScraped bool
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", false},
+		{"https://youtu.be/dQw4w9WgXcQ", false},
+		{"https://www.tiktok.com/@user/video/1", true},
+	}
+	for _, tt := range tests {
+		scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+		resolver := &fakeResolver{url: "https://cdn/v.mp4"}
+		downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}
+		o, _ := newTestOrchestrator(t, scraper, downloader, resolver, Options{SkipMetadata: true})
+
+		result, err := o.RunJob(context.Background(), tt.url)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.url, err)
+		}
+		if scraped := len(scraper.calls) > 0; scraped != tt.wantScraped {
+			t.Errorf("%s: scraped %v, want %v", tt.url, scraper.calls, tt.wantScraped)
+		}
+		_, err = os.Stat(filepath.Join(o.storage.GetJobPath(result.Job.ID), "metadata_raw.json"))
+		if saved := err == nil; saved != tt.wantScraped {
+			t.Errorf("%s: metadata_raw.json saved %v, want %v", tt.url, saved, tt.wantScraped)
+		}
+		if got := readJobFile(t, o, result.Job.ID, "video.mp4"); got != "video" {
+			t.Errorf("%s: video = %q", tt.url, got)
+		}
+	}
+}
+
+// failingSaveStorage is local storage that can't save videos.
+type failingSaveStorage struct{ *localstorage.LocalStorage }
+
+func (failingSaveStorage) SaveVideo(ctx context.Context, jobID string, reader io.Reader, filename string) error {
+	return errFake
+}
+
+// Each failure point wraps its error in a JobError naming the step, and
+// RunJobWithRetry retries only those that are retryable.
+func TestJobErrorStep(t *testing.T) {
+	saved := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = saved })
+
+	const tiktok, youtube = "https://www.tiktok.com/@user/video/1", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	scraped := &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}
+	video := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}
+	tests := []struct {
+		name          string
+		url           string
+		scraper       *fakeScraper
+		resolver      *fakeResolver
+		downloader    ports.Downloader
+		failSave      bool
+		wantStep      domain.JobStep
+		wantRetryable bool
+	}{
+		{"scrape", tiktok, &fakeScraper{err: errFake}, nil, video, false, domain.StepScrape, true},
+		{"scrape unavailable", tiktok, &fakeScraper{err: ports.ErrVideoUnavailable}, nil, video, false, domain.StepScrape, false},
+		{"resolve", youtube, &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`)}}, &fakeResolver{err: errFake}, video, false, domain.StepResolve, true},
+		{"download", tiktok, &fakeScraper{result: scraped}, nil, downloadFunc(func(ctx context.Context, videoURL string) (io.ReadCloser, error) {
+			return nil, errFake
+		}), false, domain.StepDownload, true},
+		{"save", tiktok, &fakeScraper{result: scraped}, nil, video, true, domain.StepSave, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var storage ports.Storage = localstorage.NewLocalStorage(t.TempDir())
+			if tt.failSave {
+				storage = failingSaveStorage{storage.(*localstorage.LocalStorage)}
+			}
+			var resolver ports.URLResolver
+			if tt.resolver != nil {
+				resolver = tt.resolver
+			}
+			o := NewOrchestrator(tt.scraper, tt.downloader, storage, resolver, log.New(testWriter{t}, "", 0), Options{TempDir: t.TempDir()})
+
+			result, err := o.RunJobWithRetry(context.Background(), tt.url, 3)
+			var jobErr *domain.JobError
+			if !errors.As(err, &jobErr) {
+				t.Fatalf("err = %v, want a JobError", err)
+			}
+			if jobErr.Step != tt.wantStep || jobErr.Retryable != tt.wantRetryable {
+				t.Errorf("JobError = step %s, retryable %v; want %s, %v", jobErr.Step, jobErr.Retryable, tt.wantStep, tt.wantRetryable)
+			}
+			if !errors.Is(err, jobErr.Err) || result.ErrorMessage == "" {
+				t.Errorf("err = %v, ErrorMessage %q; want the cause unwrapped and a message", err, result.ErrorMessage)
+			}
+			wantCalls := 1
+			if tt.wantRetryable {
+				wantCalls = 3
+			}
+			if got := len(tt.scraper.calls); got != wantCalls {
+				t.Errorf("ran %d attempts, want %d", got, wantCalls)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		step domain.JobStep
+		err  error
+		want bool
+	}{
+		{domain.StepScrape, errFake, true},
+		{domain.StepDownload, errFake, true},
+		{domain.StepSave, errFake, false},
+		{domain.StepDownload, context.Canceled, false},
+		{domain.StepScrape, fmt.Errorf("scrape: %w", ports.ErrVideoUnavailable), false},
+		{domain.StepScrape, ports.ErrJobLocked, false},
+		{domain.StepPreflight, ports.ErrLimitExceeded, false},
+		{domain.StepResolve, ports.ErrToolNotFound, false},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.step, tt.err); got != tt.want {
+			t.Errorf("isRetryable(%s, %v) = %v, want %v", tt.step, tt.err, got, tt.want)
+		}
+	}
+}
+
+// Metadata from a fallback scraper marks the result, and the job still
+// downloads the video.
+func TestRunJobMetadataFallback(t *testing.T) {
+	for _, fromFallback := range []bool{false, true} {
+		scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`{"title":"A pin"}`), VideoURL: "https://cdn/v.mp4", FromFallback: fromFallback}}
+		o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, nil, Options{})
+
+		result, err := o.RunJob(context.Background(), "https://www.pinterest.com/pin/1/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.MetadataFallback != fromFallback || !result.Success {
+			t.Errorf("FromFallback %v: MetadataFallback = %v, Success = %v", fromFallback, result.MetadataFallback, result.Success)
+		}
+		if got := readJobFile(t, o, result.Job.ID, "metadata_raw.json"); got != `{"title":"A pin"}` {
+			t.Errorf("FromFallback %v: metadata_raw.json = %s", fromFallback, got)
+		}
+	}
+}
+
+// A video the scrape reports gone fails the job with that reason, before a
+// download is tried.
+func TestRunJobVideoUnavailable(t *testing.T) {
+	downloader := &fakeDownloader{}
+	o, _ := newTestOrchestrator(t, &fakeScraper{err: ports.ErrVideoUnavailable}, downloader, nil, Options{})
+
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if !errors.Is(err, ports.ErrVideoUnavailable) {
+		t.Fatalf("RunJob err = %v, want ErrVideoUnavailable", err)
+	}
+	if result.ErrorMessage != ports.ErrVideoUnavailable.Error() {
+		t.Errorf("ErrorMessage = %q, want %q", result.ErrorMessage, ports.ErrVideoUnavailable.Error())
+	}
+	if len(downloader.calls) > 0 {
+		t.Errorf("downloaded %v", downloader.calls)
+	}
+}
+
+// streamsResolver is a fakeResolver that also resolves separate streams.
+type streamsResolver struct {
+	fakeResolver
+	video, audio string
+}
+
+func (r *streamsResolver) ResolveSeparateStreams(ctx context.Context, videoPageURL string) (string, string, error) {
+	return r.video, r.audio, nil
+}
+
+// With SeparateStreams, both streams are saved unmerged under their own
+// names; platforms without yt-dlp get the usual video.
+func TestRunJobSeparateStreams(t *testing.T) {
+	files := map[string]string{
+		"https://cdn/video-only": "video stream",
+		"https://cdn/audio-only": "audio stream",
+		"https://cdn/v.mp4":      "merged video",
+	}
+	tests := []struct {
+		name      string
+		url       string
+		wantFiles map[string]string
+	}{
+		{"youtube", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", map[string]string{videoOnlyFile: "video stream", audioOnlyFile: "audio stream"}},
+		{"tiktok", "https://www.tiktok.com/@user/video/1", map[string]string{defaultVideoFile: "merged video"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+			resolver := &streamsResolver{fakeResolver: fakeResolver{url: "https://cdn/v.mp4"}, video: "https://cdn/video-only", audio: "https://cdn/audio-only"}
+			o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: files}, resolver, Options{SeparateStreams: true})
+
+			result, err := o.RunJob(context.Background(), tt.url)
+			if err != nil {
+				t.Fatalf("RunJob: %v", err)
+			}
+			for name, want := range tt.wantFiles {
+				if got := readJobFile(t, o, result.Job.ID, name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if _, ok := tt.wantFiles[audioOnlyFile]; ok {
+				dir := o.storage.GetJobPath(result.Job.ID)
+				if result.VideoPath != dir+"/"+videoOnlyFile || result.AudioPath != dir+"/"+audioOnlyFile {
+					t.Errorf("VideoPath = %s, AudioPath = %s", result.VideoPath, result.AudioPath)
+				}
+				if _, err := os.Stat(filepath.Join(dir, defaultVideoFile)); err == nil {
+					t.Errorf("%s saved alongside the separate streams", defaultVideoFile)
+				}
+			}
+		})
+	}
+}
+
+// The run and dataset behind the metadata are saved with it, when the
+// scraper reports them.
+func TestRunJobSavesScrapeMeta(t *testing.T) {
+	tests := []struct {
+		name        string
+		runID, dsID string
+		wantSaved   bool
+	}{
+		{"apify run", "run1", "ds1", true},
+		{"no run", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4", RunID: tt.runID, DatasetID: tt.dsID}}
+			o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, nil, Options{})
+
+			result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+			if err != nil {
+				t.Fatalf("RunJob: %v", err)
+			}
+			path := filepath.Join(o.storage.GetJobPath(result.Job.ID), "scrape_meta.json")
+			if !tt.wantSaved {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("scrape_meta.json without a run: %v", err)
+				}
+				return
+			}
+			var meta struct {
+				RunID     string `json:"run_id"`
+				DatasetID string `json:"dataset_id"`
+			}
+			if err := json.Unmarshal([]byte(readJobFile(t, o, result.Job.ID, "scrape_meta.json")), &meta); err != nil {
+				t.Fatal(err)
+			}
+			if meta.RunID != tt.runID || meta.DatasetID != tt.dsID {
+				t.Errorf("scrape_meta.json = %+v, want run %s, dataset %s", meta, tt.runID, tt.dsID)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// templateFields are the placeholders an OutputTemplate may use.
+var templateFields = []string{"title", "uploader", "id", "ext", "upload_date"}
+
+// missingField is what a placeholder without a default renders to when its
+// field is unknown, as in yt-dlp.
+const missingField = "NA"
+
+// OutputTemplate names a job's main video after its metadata, with a subset
+// of yt-dlp's output template syntax: "%(field)s" placeholders for title,
+// uploader, id, ext and upload_date (YYYYMMDD), optionally with a default
+// ("%(uploader|unknown)s"), flags, width and precision ("%(title).50s",
+// "%(id)-12s", "%(upload_date)08d"), and "%%" for a literal "%". A "/"
+// nests the video in directories under the job directory; each rendered
+// component is sanitized.
+//
+// %(ext)s may only end the template, as ".%(ext)s"; it is appended when
+// missing, since the extension is only known once the download starts.
+type OutputTemplate struct {
+	components [][]templatePart
+	sanitize   func(string) string
+}
+
+// templatePart is literal text or a placeholder.
+type templatePart struct {
+	literal    string
+	field      string
+	verb       string // fmt verb built from the flags, width, precision and conversion
+	def        string
+	hasDefault bool
+}
+
+// ParseOutputTemplate parses tmpl (see OutputTemplate). sanitize makes one
+// rendered path component safe as a file name, e.g.
+// localstorage.SanitizeFilename.
+func ParseOutputTemplate(tmpl string, sanitize func(string) string) (*OutputTemplate, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return nil, fmt.Errorf("empty output template")
+	}
+	if strings.HasPrefix(tmpl, "/") {
+		return nil, fmt.Errorf("output template %q must be relative to the job directory", tmpl)
+	}
+	parts, err := parseTemplateParts(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid output template %q: %w", tmpl, err)
+	}
+
+	// Split into path components at the literal slashes
+	components := [][]templatePart{nil}
+	for _, part := range parts {
+		if part.field != "" {
+			last := len(components) - 1
+			components[last] = append(components[last], part)
+			continue
+		}
+		for i, text := range strings.Split(part.literal, "/") {
+			if i > 0 {
+				components = append(components, nil)
+			}
+			if text != "" {
+				last := len(components) - 1
+				components[last] = append(components[last], templatePart{literal: text})
+			}
+		}
+	}
+	for _, component := range components {
+		if len(component) == 0 {
+			return nil, fmt.Errorf("invalid output template %q: empty path component", tmpl)
+		}
+		if len(component) == 1 && (component[0].literal == "." || component[0].literal == "..") {
+			return nil, fmt.Errorf("invalid output template %q: %q path component", tmpl, component[0].literal)
+		}
+	}
+
+	// Drop the trailing ".%(ext)s"; rendering adds the extension back
+	last := components[len(components)-1]
+	if n := len(last); n >= 2 && last[n-1].field == "ext" && strings.HasSuffix(last[n-2].literal, ".") {
+		last[n-2].literal = strings.TrimSuffix(last[n-2].literal, ".")
+		if last[n-2].literal == "" {
+			last = last[:n-2]
+		} else {
+			last = last[:n-1]
+		}
+		if len(last) == 0 {
+			return nil, fmt.Errorf("invalid output template %q: no file name before the extension", tmpl)
+		}
+		components[len(components)-1] = last
+	}
+	for _, component := range components {
+		for _, part := range component {
+			if part.field == "ext" {
+				return nil, fmt.Errorf("invalid output template %q: %%(ext)s is only supported as the final \".%%(ext)s\"", tmpl)
+			}
+		}
+	}
+
+	return &OutputTemplate{components: components, sanitize: sanitize}, nil
+}
+
+// parseTemplateParts splits tmpl into literal text and placeholders.
+func parseTemplateParts(tmpl string) ([]templatePart, error) {
+	var parts []templatePart
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			parts = append(parts, templatePart{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '%' {
+			literal.WriteByte(tmpl[i])
+			continue
+		}
+		if strings.HasPrefix(tmpl[i:], "%%") {
+			literal.WriteByte('%')
+			i++
+			continue
+		}
+		if !strings.HasPrefix(tmpl[i:], "%(") {
+			return nil, fmt.Errorf("stray %% at offset %d (use %%%% for a literal %%)", i)
+		}
+		end := strings.IndexByte(tmpl[i:], ')')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated placeholder at offset %d", i)
+		}
+		name, def, hasDefault := strings.Cut(tmpl[i+2:i+end], "|")
+		if !isTemplateField(name) {
+			return nil, fmt.Errorf("unknown field %q (supported: %s)", name, strings.Join(templateFields, ", "))
+		}
+
+		// Flags, width and precision, then the conversion
+		spec := i + end + 1
+		j := spec
+		for j < len(tmpl) && strings.IndexByte("-0+ #", tmpl[j]) >= 0 {
+			j++
+		}
+		for j < len(tmpl) && tmpl[j] >= '0' && tmpl[j] <= '9' {
+			j++
+		}
+		if j < len(tmpl) && tmpl[j] == '.' {
+			j++
+			for j < len(tmpl) && tmpl[j] >= '0' && tmpl[j] <= '9' {
+				j++
+			}
+		}
+		if j >= len(tmpl) || (tmpl[j] != 's' && tmpl[j] != 'd') {
+			return nil, fmt.Errorf("placeholder %%(%s) needs an s or d conversion", name)
+		}
+
+		flush()
+		parts = append(parts, templatePart{
+			field:      name,
+			verb:       "%" + tmpl[spec:j+1],
+			def:        def,
+			hasDefault: hasDefault,
+		})
+		i = j
+	}
+	flush()
+	return parts, nil
+}
+
+func isTemplateField(name string) bool {
+	for _, field := range templateFields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// render returns the video's path relative to the job directory, with
+// defaultVideoFile's extension for withContainerExt to replace.
+func (t *OutputTemplate) render(fields map[string]string) string {
+	names := make([]string, len(t.components))
+	for i, component := range t.components {
+		var b strings.Builder
+		for _, part := range component {
+			b.WriteString(part.render(fields))
+		}
+		name := b.String()
+		if i == len(t.components)-1 {
+			name += path.Ext(defaultVideoFile)
+		}
+		if t.sanitize != nil {
+			name = t.sanitize(name)
+		}
+		// A field may render to nothing, or to "." or ".."
+		if strings.Trim(name, ".") == "" {
+			name = "_" + name
+		}
+		names[i] = name
+	}
+	return strings.Join(names, "/")
+}
+
+func (p templatePart) render(fields map[string]string) string {
+	if p.field == "" {
+		return p.literal
+	}
+	value := fields[p.field]
+	if value == "" {
+		value = missingField
+		if p.hasDefault {
+			value = p.def
+		}
+	}
+	verb := p.verb
+	if strings.HasSuffix(verb, "d") {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return fmt.Sprintf(verb, n)
+		}
+		// Not a number (e.g. "NA"): format it as a string instead
+		verb = strings.TrimSuffix(verb, "d") + "s"
+	}
+	return fmt.Sprintf(verb, value)
+}
+
+// videoTemplateFields returns the OutputTemplate fields for job, from the
+// normalized metadata of scrapeResult when there is one.
+func videoTemplateFields(job domain.Job, scrapeResult *ports.ScrapeResult) map[string]string {
+	fields := map[string]string{}
+	if job.Platform == "youtube" {
+		fields["id"] = ports.YouTubeVideoID(job.URL)
+	}
+	if scrapeResult == nil {
+		return fields
+	}
+	if meta, err := NormalizeMetadata(scrapeResult.RawMetadata); err == nil {
+		fields["title"] = meta.Title
+		fields["uploader"] = meta.Author
+		fields["upload_date"] = uploadDate(meta.PublishedAt)
+	}
+	if fields["id"] == "" {
+		fields["id"] = datasetItemID(scrapeResult.RawMetadata)
+	}
+	return fields
+}
+
+// uploadDate formats a published timestamp as yt-dlp's YYYYMMDD, or returns
+// "" if it can't be parsed.
+func uploadDate(publishedAt string) string {
+	if t, err := time.Parse(time.RFC3339, publishedAt); err == nil {
+		return t.UTC().Format("20060102")
+	}
+	if len(publishedAt) >= len("2006-01-02") {
+		if t, err := time.Parse("2006-01-02", publishedAt[:len("2006-01-02")]); err == nil {
+			return t.Format("20060102")
+		}
+	}
+	return ""
+}
+
+// datasetItemID returns the "id" of the first dataset item in raw, if any.
+func datasetItemID(raw []byte) string {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return ""
+	}
+	if items, ok := doc.([]interface{}); ok {
+		if len(items) == 0 {
+			return ""
+		}
+		doc = items[0]
+	}
+	v, _ := lookupPath(doc, "id")
+	switch id := v.(type) {
+	case string:
+		return id
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	}
+	return ""
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"scrapeanddown/internal/adapters/localstorage"
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestOutputTemplateRender(t *testing.T) {
+	fields := map[string]string{
+		"title":       "My Video: Part 1",
+		"uploader":    "Some Channel",
+		"id":          "dQw4w9WgXcQ",
+		"upload_date": "20240612",
+	}
+	tests := []struct {
+		tmpl   string
+		fields map[string]string
+		want   string
+	}{
+		{"%(title)s.%(ext)s", fields, "My Video_ Part 1.mp4"},
+		{"%(title)s", fields, "My Video_ Part 1.mp4"},
+		{"%(uploader)s/%(upload_date)s - %(title)s [%(id)s].%(ext)s", fields, "Some Channel/20240612 - My Video_ Part 1 [dQw4w9WgXcQ].mp4"},
+		{"%(title).8s.%(ext)s", fields, "My Video.mp4"},
+		{"%(id).3s.%(ext)s", fields, "dQw.mp4"},
+		{"%(upload_date)010d.%(ext)s", fields, "0020240612.mp4"},
+		{"100%% %(id)s.%(ext)s", fields, "100% dQw4w9WgXcQ.mp4"},
+		// Missing fields use the default, or NA without one
+		{"%(uploader|unknown)s/%(title)s.%(ext)s", map[string]string{"title": "T"}, "unknown/T.mp4"},
+		{"%(uploader)s/%(title)s.%(ext)s", map[string]string{"title": "T"}, "NA/T.mp4"},
+		// A component rendering to nothing usable is replaced
+		{"%(title)s/video.%(ext)s", map[string]string{"title": ".."}, "_/video.mp4"},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseOutputTemplate(tt.tmpl, localstorage.SanitizeFilename)
+		if err != nil {
+			t.Errorf("ParseOutputTemplate(%q): %v", tt.tmpl, err)
+			continue
+		}
+		if got := tmpl.render(tt.fields); got != tt.want {
+			t.Errorf("%q rendered %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestParseOutputTemplateErrors(t *testing.T) {
+	tests := []struct {
+		tmpl, err string
+	}{
+		{"", "empty output template"},
+		{"/abs/%(title)s.%(ext)s", "must be relative"},
+		{"%(title)s/../%(id)s.%(ext)s", `".." path component`},
+		{"%(title)s//%(id)s.%(ext)s", "empty path component"},
+		{"%(views)s.%(ext)s", `unknown field "views"`},
+		{"%(title.%(ext)s", "unknown field"},
+		{"%(title", "unterminated placeholder"},
+		{"%(title)x", "needs an s or d conversion"},
+		{"50% %(title)s", "stray %"},
+		{"%(ext)s/%(title)s", "only supported as the final"},
+		{".%(ext)s", "no file name before the extension"},
+	}
+	for _, tt := range tests {
+		_, err := ParseOutputTemplate(tt.tmpl, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ParseOutputTemplate(%q) err = %v, want %q", tt.tmpl, err, tt.err)
+		}
+	}
+}
+
+func TestVideoTemplateFields(t *testing.T) {
+	tests := []struct {
+		name string
+		job  domain.Job
+		raw  string
+		want map[string]string
+	}{
+		{
+			"youtube",
+			domain.Job{Platform: "youtube", URL: "https://youtu.be/dQw4w9WgXcQ"},
+			`[{"title": "T", "channelName": "C", "date": "2024-06-12T15:30:00.000Z"}]`,
+			map[string]string{"id": "dQw4w9WgXcQ", "title": "T", "uploader": "C", "upload_date": "20240612"},
+		},
+		{
+			"tiktok",
+			domain.Job{Platform: "tiktok", URL: "https://www.tiktok.com/@user/video/1"},
+			`[{"id": "7301", "text": "caption", "authorMeta": {"name": "user"}, "createTimeISO": "2024-06-12"}]`,
+			map[string]string{"id": "7301", "title": "caption", "uploader": "user", "upload_date": "20240612"},
+		},
+		{
+			"numeric id, bad date",
+			domain.Job{Platform: "tiktok"},
+			`[{"id": 7301, "createTimeISO": "yesterday"}]`,
+			map[string]string{"id": "7301", "title": "", "uploader": "", "upload_date": ""},
+		},
+	}
+	for _, tt := range tests {
+		got := videoTemplateFields(tt.job, &ports.ScrapeResult{RawMetadata: []byte(tt.raw)})
+		for field, want := range tt.want {
+			if got[field] != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, field, got[field], want)
+			}
+		}
+	}
+}
+
+// A templated name nests the video in directories under the job directory.
+func TestRunJobOutputTemplate(t *testing.T) {
+	tmpl, err := ParseOutputTemplate("%(uploader)s/%(upload_date|undated)s/%(title)s.%(ext)s", localstorage.SanitizeFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := `[{"text": "a caption", "authorMeta": {"name": "user"}}]`
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(raw), VideoURL: "https://cdn/v.mp4"}}
+	o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, nil, Options{OutputTemplate: tmpl})
+
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readJobFile(t, o, result.Job.ID, filepath.Join("user", "undated", "a caption.mp4")); got != "video" {
+		t.Errorf("templated video = %q", got)
+	}
+	if want := filepath.Join(o.storage.GetJobPath(result.Job.ID), "user", "undated", "a caption.mp4"); result.VideoPath != want {
+		t.Errorf("VideoPath = %s, want %s", result.VideoPath, want)
+	}
+}
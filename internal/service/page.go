@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// maxPageBytes caps how much of the video page is saved.
+const maxPageBytes = 20 << 20
+
+// browserHeaders make the page request look like a desktop browser, so the
+// platform serves the same HTML a visitor would see.
+var browserHeaders = map[string]string{
+	"User-Agent":      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+	"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+	"Accept-Language": "en-US,en;q=0.9",
+}
+
+// jobPage is a job's page HTML, fetched at most once for the features that
+// read it (page.html, OpenGraph enrichment).
+type jobPage struct {
+	url     string
+	fetched bool
+	data    []byte
+	err     error
+}
+
+// load returns the page, fetching it on first use.
+func (p *jobPage) load(ctx context.Context, o *Orchestrator) ([]byte, error) {
+	if !p.fetched {
+		p.data, p.err = o.fetchPage(ports.WithAnyContentType(ports.WithRequestHeaders(ctx, browserHeaders)), p.url)
+		p.fetched = true
+	}
+	return p.data, p.err
+}
+
+// savePage saves the job's page HTML as page.html. It never fails the job:
+// errors are logged and skipped.
+func (o *Orchestrator) savePage(ctx context.Context, job domain.Job, page *jobPage, artifacts *[]artifactRecord) {
+	data, err := page.load(ctx, o)
+	if err == nil {
+		err = o.storage.SavePage(ctx, job.ID, data)
+	}
+	if err != nil {
+		o.logger.Printf("[JOB %s] WARNING: failed to save page HTML: %v", job.ID, err)
+		return
+	}
+	o.addArtifact(job.ID, artifacts, newArtifactRecord("page.html", "page", data))
+	o.logger.Printf("[JOB %s] Saved page.html", job.ID)
+}
+
+// fetchPage downloads up to maxPageBytes of the page.
+func (o *Orchestrator) fetchPage(ctx context.Context, pageURL string) ([]byte, error) {
+	body, err := o.downloader.Download(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, maxPageBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrapeanddown/internal/adapters/downloader"
+	"scrapeanddown/internal/core/ports"
+)
+
+// pageServer serves a video page at /video/1 and its video at /v.mp4,
+// recording the User-Agent the page was requested with.
+type pageServer struct {
+	pageStatus int
+	page       string // Defaults to a bare page
+
+	mu        sync.Mutex
+	userAgent string
+	fetches   int
+}
+
+func (s *pageServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/video/1":
+		s.mu.Lock()
+		s.userAgent = r.UserAgent()
+		s.fetches++
+		s.mu.Unlock()
+		if s.pageStatus != http.StatusOK {
+			http.Error(w, "gone", s.pageStatus)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page := s.page
+		if page == "" {
+			page = "<html><title>Video</title></html>"
+		}
+		w.Write([]byte(page))
+	case "/v.mp4":
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Write([]byte("video"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestSavePageHTML(t *testing.T) {
+	tests := []struct {
+		name       string
+		pageStatus int
+		wantPage   bool
+	}{
+		{"saved", http.StatusOK, true},
+		{"fetch failure continues", http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := &pageServer{pageStatus: tt.pageStatus}
+			srv := httptest.NewServer(pages)
+			defer srv.Close()
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: srv.URL + "/v.mp4"}}
+			o, _ := newTestOrchestrator(t, scraper, downloader.NewHTTPDownloader(), nil, Options{SavePageHTML: true})
+
+			result, err := o.RunJob(context.Background(), srv.URL+"/video/1")
+			if err != nil {
+				t.Fatalf("RunJob: %v", err)
+			}
+			if !strings.HasPrefix(pages.userAgent, "Mozilla/5.0") {
+				t.Errorf("page requested with User-Agent %q, want a browser's", pages.userAgent)
+			}
+			_, statErr := os.Stat(filepath.Join(o.storage.GetJobPath(result.Job.ID), "page.html"))
+			if tt.wantPage != (statErr == nil) {
+				t.Fatalf("page.html saved = %v, want %v", statErr == nil, tt.wantPage)
+			}
+			if tt.wantPage {
+				if got := readJobFile(t, o, result.Job.ID, "page.html"); got != "<html><title>Video</title></html>" {
+					t.Errorf("page.html = %q", got)
+				}
+			}
+		})
+	}
+}
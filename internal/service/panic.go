@@ -0,0 +1,41 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"scrapeanddown/internal/core/domain"
+)
+
+// ErrJobPanicked is reported for a job whose run panicked. The panic is
+// contained to that job: a batch's other jobs carry on.
+var ErrJobPanicked = errors.New("job panicked")
+
+// panicked fails result for a panic p recovered in RunJob (e.g. on a quirky
+// metadata payload), logging it with its stack. The returned error is not a
+// JobError, so the job is not retried.
+func (o *Orchestrator) panicked(result *domain.JobResult, p interface{}) error {
+	stack := string(debug.Stack())
+	if result.Job.ID != "" {
+		o.logger.Printf("[JOB %s] ERROR: job panicked: %v\n%s", result.Job.ID, p, stack)
+	} else {
+		o.logger.Printf("ERROR: job for %s panicked: %v\n%s", result.Job.URL, p, stack)
+	}
+	result.Success = false
+	result.ErrorMessage = fmt.Sprintf("panic: %v", p)
+	result.PanicStack = stack
+	result.CompletedAt = o.now().UTC()
+	return fmt.Errorf("%w: %v", ErrJobPanicked, p)
+}
+
+// itemPanicked fails a batch entry whose worker panicked outside RunJob,
+// e.g. writing the job's result. An entry that got no result from its job
+// gets a minimal one, as writeResult gives it.
+func (o *Orchestrator) itemPanicked(br BatchResult, p interface{}) BatchResult {
+	if br.Result == nil {
+		br.Result = &domain.JobResult{Job: domain.Job{URL: br.URL}}
+	}
+	br.Err = o.panicked(br.Result, p)
+	return br
+}
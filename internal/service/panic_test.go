@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// A job that panics fails on its own, with its ID and marker, while the
+// batch's other jobs complete.
+func TestPanickingJobFailsAlone(t *testing.T) {
+	urls := []string{
+		"https://www.tiktok.com/@user/video/1",
+		"https://www.tiktok.com/@user/video/2",
+		"https://www.tiktok.com/@user/video/3",
+	}
+	scraper := scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+		if url == urls[1] {
+			var m map[string]int
+			m["boom"]++
+		}
+		id := url[strings.LastIndex(url, "/")+1:]
+		return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/" + id + ".mp4"}, nil
+	})
+	downloader := &fakeDownloader{files: map[string]string{
+		"https://cdn/1.mp4": "video 1",
+		"https://cdn/3.mp4": "video 3",
+	}}
+	o, root := newTestOrchestrator(t, scraper, downloader, nil, Options{})
+
+	results := o.RunJobs(context.Background(), urls, 2, 3)
+	if len(results) != len(urls) {
+		t.Fatalf("got %d results, want %d", len(results), len(urls))
+	}
+	for i, r := range results {
+		if i == 1 {
+			continue
+		}
+		if r.Err != nil || r.Result == nil || !r.Result.Success {
+			t.Errorf("%s: err = %v, want success", r.URL, r.Err)
+		}
+	}
+
+	r := results[1]
+	if !errors.Is(r.Err, ErrJobPanicked) {
+		t.Fatalf("err = %v, want ErrJobPanicked", r.Err)
+	}
+	if r.Result == nil || r.Result.Job.ID == "" {
+		t.Fatalf("result = %+v, want the job's own result", r.Result)
+	}
+	if r.Result.Success || !strings.HasPrefix(r.Result.ErrorMessage, "panic: ") || r.Result.PanicStack == "" {
+		t.Errorf("result = success %v, error %q, stack %d bytes", r.Result.Success, r.Result.ErrorMessage, len(r.Result.PanicStack))
+	}
+	if _, err := os.Stat(filepath.Join(root, "jobs", r.Result.Job.ID, "_FAILED")); err != nil {
+		t.Errorf("panicked job not marked failed: %v", err)
+	}
+	if got := errorCategory(r.Err); got != "panic" {
+		t.Errorf("category = %q, want panic", got)
+	}
+}
+
+// panicWriter panics writing a line that contains needle.
+type panicWriter struct {
+	needle string
+	mu     sync.Mutex
+	lines  []string
+}
+
+func (w *panicWriter) Write(p []byte) (int, error) {
+	if strings.Contains(string(p), w.needle) {
+		panic("results writer broke")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lines = append(w.lines, string(p))
+	return len(p), nil
+}
+
+// A panic after a job has run, writing its result, fails that entry alone
+// in both a batch and a job stream.
+func TestPanickingResultWriterFailsAlone(t *testing.T) {
+	tests := []struct {
+		name string
+		// run returns the error reported for each URL, "" for none
+		run func(o *Orchestrator, urls []string) map[string]string
+	}{
+		{"batch", func(o *Orchestrator, urls []string) map[string]string {
+			errs := map[string]string{}
+			for _, r := range o.RunJobs(context.Background(), urls, 2, 1) {
+				errs[r.URL] = ""
+				if r.Err != nil {
+					errs[r.URL] = r.Err.Error()
+				}
+			}
+			return errs
+		}},
+		{"stream", func(o *Orchestrator, urls []string) map[string]string {
+			var in, out strings.Builder
+			for _, u := range urls {
+				fmt.Fprintf(&in, "%q\n", u)
+			}
+			if _, err := o.RunJobStream(context.Background(), strings.NewReader(in.String()), &out, 2, 1); err != nil {
+				t.Fatal(err)
+			}
+			errs := map[string]string{}
+			for _, line := range decodeStreamLines(t, out.String()) {
+				errs[line.URL] = line.Error
+			}
+			return errs
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls := []string{
+				"https://www.tiktok.com/@user/video/1",
+				"https://www.tiktok.com/@user/video/2",
+				"https://www.tiktok.com/@user/video/3",
+			}
+			downloader := &fakeDownloader{files: map[string]string{
+				"https://cdn/1.mp4": "video 1",
+				"https://cdn/2.mp4": "video 2",
+				"https://cdn/3.mp4": "video 3",
+			}}
+			results := &panicWriter{needle: "video/2"}
+			o, _ := newTestOrchestrator(t, streamScraper(), downloader, nil, Options{Results: results})
+
+			errs := tt.run(o, urls)
+			if len(errs) != len(urls) {
+				t.Fatalf("got results for %v, want every URL", errs)
+			}
+			for _, u := range urls {
+				err := errs[u]
+				if u == urls[1] {
+					if !strings.Contains(err, ErrJobPanicked.Error()) {
+						t.Errorf("%s: err = %q, want the panic", u, err)
+					}
+				} else if err != "" {
+					t.Errorf("%s: err = %q, want success", u, err)
+				}
+			}
+			if len(results.lines) != 2 {
+				t.Errorf("wrote %d results, want the other two", len(results.lines))
+			}
+		})
+	}
+}
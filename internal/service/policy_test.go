@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/adapters/localstorage"
+	"scrapeanddown/internal/core/ports"
+)
+
+// newPolicyOrchestrator is newTestOrchestrator with storage under the given
+// write policy.
+func newPolicyOrchestrator(t *testing.T, policy localstorage.WritePolicy, scraper ports.Scraper, downloader ports.Downloader, resolver ports.URLResolver) (*Orchestrator, string) {
+	t.Helper()
+	root := t.TempDir()
+	storage := localstorage.NewLocalStorage(root, localstorage.WithWritePolicy(policy))
+	logger := log.New(testWriter{t}, "", 0)
+	return NewOrchestrator(scraper, downloader, storage, resolver, logger, Options{TempDir: t.TempDir()}), root
+}
+
+// scrapeOverExisting scrapes like fakeScraper, first leaving name with
+// content in the job directory as an earlier run would have.
+func scrapeOverExisting(t *testing.T, root *string, name, content string, result *ports.ScrapeResult) ports.Scraper {
+	return scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+		dirs, _ := filepath.Glob(filepath.Join(*root, "jobs", "*"))
+		for _, dir := range dirs {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Error(err)
+			}
+		}
+		copied := *result
+		return &copied, nil
+	})
+}
+
+func TestSkipExistingKeepsVideoWithoutDownloading(t *testing.T) {
+	var root string
+	scraper := scrapeOverExisting(t, &root, "video.mp4", "earlier run", &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"})
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "new bytes"}}
+	o, r := newPolicyOrchestrator(t, localstorage.SkipExisting, scraper, downloader, nil)
+	root = r
+
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(downloader.calls) != 0 {
+		t.Errorf("downloaded %q for a kept video", downloader.calls)
+	}
+	if got := readJobFile(t, o, result.Job.ID, "video.mp4"); got != "earlier run" {
+		t.Errorf("video.mp4 = %q, want the kept copy", got)
+	}
+}
+
+// countingFileResolver is a fakeFileResolver counting its downloads.
+type countingFileResolver struct {
+	fakeFileResolver
+	downloads int
+}
+
+func (f *countingFileResolver) DownloadFormat(ctx context.Context, videoPageURL string, format *ports.ResolvedFormat, dir string) (string, error) {
+	f.downloads++
+	return f.fakeFileResolver.DownloadFormat(ctx, videoPageURL, format, dir)
+}
+
+func TestSkipExistingKeepsResolverVideoWithoutDownloading(t *testing.T) {
+	var root string
+	scraper := scrapeOverExisting(t, &root, "video.mkv", "earlier run", &ports.ScrapeResult{RawMetadata: []byte(`[{}]`)})
+	resolver := &countingFileResolver{fakeFileResolver: fakeFileResolver{
+		fakeFormatResolver: fakeFormatResolver{format: ports.ResolvedFormat{URL: "https://cdn/video-only", Ext: "mkv", ResolverOnly: true}},
+		content:            "new bytes",
+	}}
+	o, r := newPolicyOrchestrator(t, localstorage.SkipExisting, scraper, &fakeDownloader{}, resolver)
+	root = r
+
+	result, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolver.downloads != 0 {
+		t.Errorf("resolver downloaded %d times for a kept video", resolver.downloads)
+	}
+	if got := readJobFile(t, o, result.Job.ID, "video.mkv"); got != "earlier run" {
+		t.Errorf("video.mkv = %q, want the kept copy", got)
+	}
+}
+
+func TestErrorIfExistsFailsBeforeDownloading(t *testing.T) {
+	var root string
+	scraper := scrapeOverExisting(t, &root, "video.mp4", "earlier run", &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"})
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "new bytes"}}
+	o, r := newPolicyOrchestrator(t, localstorage.ErrorIfExists, scraper, downloader, nil)
+	root = r
+
+	_, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err == nil || !strings.Contains(err.Error(), os.ErrExist.Error()) {
+		t.Errorf("RunJob err = %v, want the existing file", err)
+	}
+	if len(downloader.calls) != 0 {
+		t.Errorf("downloaded %q before failing", downloader.calls)
+	}
+}
+
+// A retry rewrites what its failed attempt saved; the policy is about files
+// from earlier runs.
+func TestErrorIfExistsLetsRetriesThrough(t *testing.T) {
+	saved := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = saved })
+
+	downloads := 0
+	downloader := downloadFunc(func(ctx context.Context, url string) (io.ReadCloser, error) {
+		downloads++
+		if downloads == 1 {
+			return io.NopCloser(&failingReader{data: "partial"}), nil
+		}
+		return io.NopCloser(strings.NewReader("whole video")), nil
+	})
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+	o, _ := newPolicyOrchestrator(t, localstorage.ErrorIfExists, scraper, downloader, nil)
+
+	result, err := o.RunJobWithRetry(context.Background(), "https://www.tiktok.com/@user/video/1", 2)
+	if err != nil {
+		t.Fatalf("RunJobWithRetry: %v", err)
+	}
+	if downloads != 2 {
+		t.Fatalf("%d downloads, want a failed one and a retry", downloads)
+	}
+	if got := readJobFile(t, o, result.Job.ID, "video.mp4"); got != "whole video" {
+		t.Errorf("video.mp4 = %q, want the retry's", got)
+	}
+}
+
+// failingReader returns data, then a connection error.
+type failingReader struct {
+	data string
+	read bool
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.read {
+		return 0, fmt.Errorf("connection reset")
+	}
+	r.read = true
+	return copy(p, r.data), nil
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// BatchItem is one job of a RunBatch batch.
+type BatchItem struct {
+	URL        string
+	ExternalID string // Optional, see WithExternalID
+	Quality    string // Optional, see WithQuality
+	AudioOnly  bool   // Optional, see WithAudioOnly
+	VideoURL   string // Optional, see WithDirectVideoURL
+}
+
+// BatchResult is the outcome of one URL in a RunJobs batch.
+type BatchResult struct {
+	URL    string
+	Result *domain.JobResult
+	Err    error
+
+	// Skipped is set, with no Result, for entries ResumeBatch skipped
+	// because an earlier run completed them.
+	Skipped bool
+}
+
+// RunJobs runs a job per URL on a pool of workers, each with up to
+// maxAttempts tries. Results are returned in the order of urls, and their
+// summary is logged (see SummarizeBatch). Once the context is draining,
+// URLs not yet started are skipped with ErrDraining.
+//
+// Unless Options.AllowDuplicateURLs is set, URLs naming the same video (see
+// canonicalURL) run once and every duplicate entry gets that result.
+func (o *Orchestrator) RunJobs(ctx context.Context, urls []string, workers, maxAttempts int) []BatchResult {
+	items := make([]BatchItem, len(urls))
+	for i, u := range urls {
+		items[i] = BatchItem{URL: u}
+	}
+	results := o.RunBatch(ctx, items, workers, maxAttempts)
+	o.logSummary("", results)
+	return results
+}
+
+// RunBatch is RunJobs for items that may carry external IDs and per-job
+// formats. Entries are only duplicates if those match too.
+func (o *Orchestrator) RunBatch(ctx context.Context, items []BatchItem, workers, maxAttempts int) []BatchResult {
+	if o.opts.AllowDuplicateURLs {
+		return o.runJobs(ctx, items, workers, maxAttempts)
+	}
+
+	var unique []BatchItem
+	indexOf := make([]int, len(items))
+	seen := make(map[BatchItem]int)
+	for i, item := range items {
+		key := item
+		key.URL = canonicalURL(item.URL)
+		j, ok := seen[key]
+		if !ok {
+			j = len(unique)
+			seen[key] = j
+			unique = append(unique, item)
+		}
+		indexOf[i] = j
+	}
+	if skipped := len(items) - len(unique); skipped > 0 {
+		o.logger.Printf("Skipping %d duplicate URLs in batch", skipped)
+	}
+
+	uniqueResults := o.runJobs(ctx, unique, workers, maxAttempts)
+	results := make([]BatchResult, len(items))
+	for i, item := range items {
+		results[i] = uniqueResults[indexOf[i]]
+		results[i].URL = item.URL
+	}
+	return results
+}
+
+// runJobs is RunBatch without de-duplication.
+func (o *Orchestrator) runJobs(ctx context.Context, items []BatchItem, workers, maxAttempts int) []BatchResult {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	// Filled a window at a time by the loop handing out the jobs
+	prescraped := make([]*ports.ScrapeResult, len(items))
+	results := make([]BatchResult, len(items))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o.rampUp(ctx)
+			for i := range indexes {
+				results[i] = o.runItem(ctx, items[i], prescraped[i], maxAttempts)
+			}
+		}()
+	}
+
+	for i := range items {
+		if draining(ctx) {
+			results[i] = BatchResult{URL: items[i].URL, Err: ErrDraining}
+			continue
+		}
+		if i%workers == 0 {
+			end := min(i+workers, len(items))
+			copy(prescraped[i:end], o.prescrape(ctx, items[i:end]))
+		}
+		select {
+		case indexes <- i:
+		case <-drainFrom(ctx):
+			results[i] = BatchResult{URL: items[i].URL, Err: ErrDraining}
+		case <-ctx.Done():
+			results[i] = BatchResult{URL: items[i].URL, Err: ErrDraining}
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// runItem runs a batch worker's job for item, then writes its result and
+// checkpoints it. A panic anywhere in that, not just in RunJob (e.g. in an
+// Options.Results writer), fails the entry rather than the whole batch.
+func (o *Orchestrator) runItem(ctx context.Context, item BatchItem, prescraped *ports.ScrapeResult, maxAttempts int) (br BatchResult) {
+	br.URL = item.URL
+	defer func() {
+		if p := recover(); p != nil {
+			br = o.itemPanicked(br, p)
+		}
+	}()
+
+	jobCtx := itemContext(ctx, item)
+	if prescraped != nil {
+		jobCtx = withPrescraped(jobCtx, prescraped)
+	}
+	br.Result, br.Err = o.RunJobWithRetry(jobCtx, item.URL, maxAttempts)
+	o.writeResult(br)
+	if br.Err == nil {
+		o.checkpoint(ctx, item, br.Result)
+	}
+	return br
+}
+
+// rampUp waits a random time below Options.StartRamp, so a batch's workers
+// don't all start their first job at once. It returns early once the
+// context is draining or done.
+func (o *Orchestrator) rampUp(ctx context.Context) {
+	if o.opts.StartRamp <= 0 {
+		return
+	}
+	timer := time.NewTimer(rand.N(o.opts.StartRamp))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-drainFrom(ctx):
+	case <-ctx.Done():
+	}
+}
+
+// ApifyUsage totals the Apify usage of a batch's jobs. Duplicate entries
+// sharing a job count once.
+func ApifyUsage(results []BatchResult) (computeUnits, costUSD float64) {
+	seen := make(map[*domain.JobResult]bool)
+	for _, r := range results {
+		if r.Result == nil || seen[r.Result] {
+			continue
+		}
+		seen[r.Result] = true
+		computeUnits += r.Result.ApifyComputeUnits
+		costUSD += r.Result.ApifyCostUSD
+	}
+	return computeUnits, costUSD
+}
+
+// FormatApifyUsage formats Apify usage for summaries.
+func FormatApifyUsage(computeUnits, costUSD float64) string {
+	return fmt.Sprintf("%.4f compute units ($%.4f)", computeUnits, costUSD)
+}
+
+// itemContext returns ctx carrying item's external ID and formats.
+func itemContext(ctx context.Context, item BatchItem) context.Context {
+	if item.ExternalID != "" {
+		ctx = WithExternalID(ctx, item.ExternalID)
+	}
+	if item.Quality != "" {
+		ctx = WithQuality(ctx, item.Quality)
+	}
+	if item.AudioOnly {
+		ctx = WithAudioOnly(ctx)
+	}
+	if item.VideoURL != "" {
+		ctx = WithDirectVideoURL(ctx, item.VideoURL)
+	}
+	return ctx
+}
+
+// writeResult appends r to Options.Results as one JSON line. Jobs that
+// failed before producing a result get a minimal one carrying the error.
+func (o *Orchestrator) writeResult(r BatchResult) {
+	if o.opts.Results == nil {
+		return
+	}
+	result := r.Result
+	if result == nil {
+		result = &domain.JobResult{Job: domain.Job{URL: r.URL}}
+	}
+	if r.Err != nil && result.ErrorMessage == "" {
+		result.ErrorMessage = r.Err.Error()
+	}
+	line, err := json.Marshal(result)
+	if err != nil {
+		o.logger.Printf("WARNING: failed to encode result for %s: %v", r.URL, err)
+		return
+	}
+
+	o.resultsMu.Lock()
+	defer o.resultsMu.Unlock()
+	if _, err := o.opts.Results.Write(append(line, '\n')); err != nil {
+		o.logger.Printf("WARNING: failed to write result for %s: %v", r.URL, err)
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct{ a, b string }{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "https://youtu.be/dQw4w9WgXcQ?t=10"},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "https://m.youtube.com/shorts/dQw4w9WgXcQ"},
+		{"https://www.tiktok.com/@user/video/1", "http://tiktok.com/@user/video/1/?is_from_webapp=1"},
+		{"https://www.tiktok.com/@user/video/1", " https://WWW.TikTok.com/@user/video/1#comments "},
+	}
+	for _, tt := range tests {
+		if a, b := canonicalURL(tt.a), canonicalURL(tt.b); a != b {
+			t.Errorf("canonicalURL(%q) = %q, canonicalURL(%q) = %q; want them equal", tt.a, a, tt.b, b)
+		}
+	}
+
+	distinct := []string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://www.youtube.com/watch?v=aaaaaaaaaaa",
+		"https://www.tiktok.com/@user/video/1",
+		"https://www.tiktok.com/@user/video/2",
+	}
+	seen := map[string]string{}
+	for _, u := range distinct {
+		key := canonicalURL(u)
+		if other, ok := seen[key]; ok {
+			t.Errorf("canonicalURL(%q) = canonicalURL(%q) = %q", u, other, key)
+		}
+		seen[key] = u
+	}
+}
+
+// Entries naming the same video in different forms run once, and each gets
+// the result in its place.
+func TestRunJobsDeduplicatesURLs(t *testing.T) {
+	urls := []string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://www.tiktok.com/@user/video/1",
+		"https://youtu.be/dQw4w9WgXcQ",
+		"http://tiktok.com/@user/video/1/?lang=en",
+		"https://www.youtube.com/shorts/dQw4w9WgXcQ",
+	}
+	newOrchestrator := func(allowDuplicates bool) (*Orchestrator, *fakeScraper, *fakeResolver) {
+		scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+		resolver := &fakeResolver{url: "https://cdn/v.mp4"}
+		o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, resolver,
+			Options{AllowDuplicateURLs: allowDuplicates, SkipMetadata: true})
+		return o, scraper, resolver
+	}
+
+	o, scraper, resolver := newOrchestrator(false)
+	results := o.RunJobs(context.Background(), urls, 1, 1)
+	if len(results) != len(urls) {
+		t.Fatalf("got %d results, want %d", len(results), len(urls))
+	}
+	for i, r := range results {
+		if r.URL != urls[i] || r.Err != nil || r.Result == nil {
+			t.Errorf("result %d = %+v, want a success for %s", i, r, urls[i])
+		}
+	}
+	for _, dup := range [][2]int{{0, 2}, {0, 4}, {1, 3}} {
+		if a, b := results[dup[0]].Result, results[dup[1]].Result; a != nil && b != nil && a.Job.ID != b.Job.ID {
+			t.Errorf("results %d and %d ran as jobs %s and %s, want one job", dup[0], dup[1], a.Job.ID, b.Job.ID)
+		}
+	}
+	if len(resolver.calls) != 1 || len(scraper.calls) != 1 {
+		t.Errorf("resolved %v and scraped %v, want each video once", resolver.calls, scraper.calls)
+	}
+
+	o, scraper, resolver = newOrchestrator(true)
+	o.RunJobs(context.Background(), urls, 1, 1)
+	if len(resolver.calls) != 3 || len(scraper.calls) != 2 {
+		t.Errorf("with AllowDuplicateURLs resolved %v and scraped %v, want every entry", resolver.calls, scraper.calls)
+	}
+}
+
+// Each finished job is written to Options.Results as one JSON line, before
+// the next one starts.
+func TestRunJobsWritesResults(t *testing.T) {
+	items, scraper, downloader := tiktokItems("1", "2")
+	urls := []string{items[0].URL, "https://www.tiktok.com/@user/video/fail", items[1].URL}
+	var out strings.Builder
+	lines := func() []string { return strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") }
+	checking := scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+		switch url {
+		case urls[1]:
+			return nil, errFake
+		case urls[2]:
+			if n := len(lines()); n != 2 {
+				t.Errorf("%d result lines written before the last job, want 2", n)
+			}
+		}
+		return scraper.Scrape(ctx, url)
+	})
+	o, _ := newTestOrchestrator(t, checking, downloader, nil, Options{Results: &out})
+
+	o.RunJobs(context.Background(), urls, 1, 1)
+
+	got := lines()
+	if len(got) != len(urls) {
+		t.Fatalf("got %d result lines, want %d:\n%s", len(got), len(urls), out.String())
+	}
+	for i, line := range got {
+		var result domain.JobResult
+		if err := json.Unmarshal([]byte(line), &result); err != nil {
+			t.Fatalf("line %d isn't a JobResult: %v\n%s", i, err, line)
+		}
+		wantOK := i != 1
+		if result.Job.URL != urls[i] || result.Job.ID == "" || result.Job.Platform != "tiktok" || result.Success != wantOK {
+			t.Errorf("line %d = %s, want job %s with success %v", i, line, urls[i], wantOK)
+		}
+		if wantOK != (result.ErrorMessage == "") {
+			t.Errorf("line %d has error %q", i, result.ErrorMessage)
+		}
+	}
+}
+
+// A job's Apify usage sums its scrapes, and a batch's counts each job once
+// however many entries share it.
+func TestApifyUsage(t *testing.T) {
+	scrapes := 0
+	scraper := scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+		if strings.HasSuffix(url, "/2") {
+			return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/2.mp4", ComputeUnits: 1, CostUSD: 0.5}, nil
+		}
+		// Video 1's first URL has expired, so it is scraped again
+		scrapes++
+		videoURL := "https://cdn/expired.mp4"
+		if scrapes > 1 {
+			videoURL = "https://cdn/1.mp4"
+		}
+		return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: videoURL, ComputeUnits: 0.25, CostUSD: 0.125}, nil
+	})
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn/1.mp4": "video 1", "https://cdn/2.mp4": "video 2"}}
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{MaxResolveRetries: 1})
+
+	urls := []string{"https://www.tiktok.com/@user/video/1", "https://www.tiktok.com/@user/video/2", "https://tiktok.com/@user/video/2"}
+	results := o.RunJobs(context.Background(), urls, 1, 1)
+	for i, want := range []struct{ units, cost float64 }{{0.5, 0.25}, {1, 0.5}, {1, 0.5}} {
+		r := results[i].Result
+		if r == nil || r.ApifyComputeUnits != want.units || r.ApifyCostUSD != want.cost {
+			t.Fatalf("result %d = %+v, want %v compute units, $%v", i, r, want.units, want.cost)
+		}
+	}
+
+	units, cost := ApifyUsage(results)
+	if units != 1.5 || cost != 0.75 {
+		t.Errorf("ApifyUsage = %v, %v; want 1.5, 0.75", units, cost)
+	}
+	if got := FormatApifyUsage(units, cost); got != "1.5000 compute units ($0.7500)" {
+		t.Errorf("FormatApifyUsage = %q", got)
+	}
+	if units, cost := ApifyUsage([]BatchResult{{URL: urls[0], Err: errFake}}); units != 0 || cost != 0 {
+		t.Errorf("ApifyUsage of a failed entry = %v, %v; want 0", units, cost)
+	}
+}
+
+// Each worker's first job starts at a random time within the ramp. Jobs
+// outlast the ramp, so every worker runs one.
+func TestRunJobsStartRamp(t *testing.T) {
+	const ramp, workers = 200 * time.Millisecond, 8
+	var (
+		mu     sync.Mutex
+		starts []time.Duration
+	)
+	begin := time.Now()
+	scraper := scrapeFunc(func(ctx context.Context, videoPageURL string) (*ports.ScrapeResult, error) {
+		mu.Lock()
+		starts = append(starts, time.Since(begin))
+		mu.Unlock()
+		time.Sleep(ramp)
+		return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}, nil
+	})
+	o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, nil,
+		Options{StartRamp: ramp})
+
+	var urls []string
+	for i := range workers {
+		urls = append(urls, fmt.Sprintf("https://www.tiktok.com/@user/video/%d", i+1))
+	}
+	for i, r := range o.RunJobs(context.Background(), urls, workers, 1) {
+		if r.Err != nil {
+			t.Fatalf("job %d: %v", i, r.Err)
+		}
+	}
+	if len(starts) != workers {
+		t.Fatalf("started %d jobs, want %d", len(starts), workers)
+	}
+	slices.Sort(starts)
+	// Scheduling can run late, but a job can't start after its delay ends
+	if last := starts[len(starts)-1]; last > ramp+200*time.Millisecond {
+		t.Errorf("last job started after %s, want within the %s ramp", last, ramp)
+	}
+	if spread := starts[len(starts)-1] - starts[0]; spread < ramp/10 {
+		t.Errorf("jobs started within %s of each other, want them spread over %s: %v", spread, ramp, starts)
+	}
+}
+
+func TestRampUpEndsEarly(t *testing.T) {
+	o := &Orchestrator{opts: Options{StartRamp: time.Hour}}
+	drain := make(chan struct{})
+	close(drain)
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, ctx := range map[string]context.Context{
+		"drain":  WithDrain(context.Background(), drain),
+		"cancel": cancelled,
+	} {
+		done := make(chan struct{})
+		go func() {
+			o.rampUp(ctx)
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("rampUp didn't return on %s", name)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestFreeSpaceCheck(t *testing.T) {
+	const url = "https://www.tiktok.com/@user/video/1"
+	// More than any disk has free
+	const margin = 1 << 60
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+	newOrchestrator := func(check bool) *Orchestrator {
+		o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, nil,
+			Options{CheckFreeSpace: check, FreeSpaceMargin: margin})
+		return o
+	}
+
+	t.Run("off by default", func(t *testing.T) {
+		if _, err := newOrchestrator(false).RunJob(context.Background(), url); err != nil {
+			t.Fatalf("RunJob: %v", err)
+		}
+	})
+	t.Run("opted in", func(t *testing.T) {
+		_, err := newOrchestrator(true).RunJob(context.Background(), url)
+		if !errors.Is(err, ports.ErrInsufficientSpace) {
+			t.Fatalf("RunJob err = %v, want ErrInsufficientSpace", err)
+		}
+	})
+	t.Run("stream skips it", func(t *testing.T) {
+		var out bytes.Buffer
+		if _, err := newOrchestrator(true).StreamJob(context.Background(), url, &out); err != nil {
+			t.Fatalf("StreamJob: %v", err)
+		}
+		if out.String() != "video" {
+			t.Errorf("streamed %q, want %q", out.String(), "video")
+		}
+	})
+}
+
+// checkFunc lets a function serve as a ports.AvailabilityChecker.
+type checkFunc func(ctx context.Context, videoPageURL string) error
+
+func (f checkFunc) CheckAvailable(ctx context.Context, videoPageURL string) error {
+	return f(ctx, videoPageURL)
+}
+
+// Availability checks take scrape slots, so checks and scrapes together stay
+// within MaxConcurrentScrapes.
+func TestAvailabilityCheckTakesScrapeSlot(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak, checks := 0, 0, 0
+	busy := func() {
+		mu.Lock()
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}
+	checker := checkFunc(func(ctx context.Context, url string) error {
+		mu.Lock()
+		checks++
+		mu.Unlock()
+		busy()
+		return nil
+	})
+	scraper := scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+		busy()
+		return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}, nil
+	})
+	o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, nil,
+		Options{CheckAvailability: true, AvailabilityChecker: checker, MaxConcurrentScrapes: 1})
+
+	var urls []string
+	for _, id := range []string{"1", "2", "3", "4"} {
+		urls = append(urls, "https://www.pinterest.com/pin/"+id+"/")
+	}
+	for _, r := range o.RunJobs(context.Background(), urls, 4, 1) {
+		if r.Err != nil {
+			t.Fatalf("%s: %v", r.URL, r.Err)
+		}
+	}
+	if checks != len(urls) {
+		t.Errorf("checks = %d, want %d", checks, len(urls))
+	}
+	if peak != 1 {
+		t.Errorf("peak checks and scrapes in flight = %d, want 1", peak)
+	}
+}
+
+func TestAvailabilityCheckFailsDeadURL(t *testing.T) {
+	checker := checkFunc(func(ctx context.Context, url string) error { return ports.ErrVideoUnavailable })
+	scraper := &fakeScraper{}
+	o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{}, nil, Options{CheckAvailability: true, AvailabilityChecker: checker})
+
+	_, err := o.RunJob(context.Background(), "https://www.pinterest.com/pin/1/")
+	if !errors.Is(err, ports.ErrVideoUnavailable) {
+		t.Fatalf("RunJob err = %v, want ErrVideoUnavailable", err)
+	}
+	if len(scraper.calls) != 0 {
+		t.Errorf("scraped %q after a failed check", scraper.calls)
+	}
+}
+
+// probeResolver is a fakeResolver that also probes videos.
+type probeResolver struct {
+	fakeResolver
+	info   ports.VideoInfo
+	probes int
+}
+
+func (r *probeResolver) Probe(ctx context.Context, videoPageURL string) (*ports.VideoInfo, error) {
+	r.probes++
+	return &r.info, nil
+}
+
+// MaxSizeBytes rejects a video before downloading it, using the scrape's size
+// hint or, for yt-dlp platforms without one, a probe.
+func TestMaxSizeBytes(t *testing.T) {
+	const tiktok, youtube = "https://www.tiktok.com/@user/video/1", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	tests := []struct {
+		name       string
+		url        string
+		hint       int64
+		probed     int64
+		wantErr    bool
+		wantProbes int
+	}{
+		{"hint over", tiktok, 2000, 0, true, 0},
+		{"hint under", tiktok, 1000, 0, false, 0},
+		{"no hint", tiktok, 0, 0, false, 0},
+		{"hint preferred to probe", youtube, 1000, 2000, false, 0},
+		{"probed over", youtube, 0, 2000, true, 1},
+		{"probed under", youtube, 0, 1000, false, 1},
+		{"probe unknown", youtube, 0, 0, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4", EstimatedBytes: tt.hint}}
+			resolver := &probeResolver{fakeResolver: fakeResolver{url: "https://cdn/v.mp4"}, info: ports.VideoInfo{EstimatedBytes: tt.probed}}
+			downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}
+			o, _ := newTestOrchestrator(t, scraper, downloader, resolver, Options{MaxSizeBytes: 1500})
+
+			_, err := o.RunJob(context.Background(), tt.url)
+			if gotErr := errors.Is(err, ports.ErrLimitExceeded); gotErr != tt.wantErr {
+				t.Fatalf("RunJob err = %v, want ErrLimitExceeded %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatal(err)
+			}
+			if downloaded := len(downloader.calls) > 0; downloaded == tt.wantErr {
+				t.Errorf("downloaded %v, want %v", downloaded, !tt.wantErr)
+			}
+			if resolver.probes != tt.wantProbes {
+				t.Errorf("probed %d times, want %d", resolver.probes, tt.wantProbes)
+			}
+		})
+	}
+}
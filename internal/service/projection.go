@@ -0,0 +1,58 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// projectFields extracts the given dot-separated JSON paths (e.g. "title",
+// "channel.name", "formats.0.url") from the dataset item in raw and returns
+// them as a flat JSON object keyed by path. When raw is an array (an Apify
+// dataset), the first item is used. Missing paths are left out.
+func projectFields(raw []byte, paths []string) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	if items, ok := doc.([]interface{}); ok {
+		if len(items) == 0 {
+			doc = nil
+		} else {
+			doc = items[0]
+		}
+	}
+
+	projected := make(map[string]interface{}, len(paths))
+	for _, path := range paths {
+		if value, ok := lookupPath(doc, path); ok {
+			projected[path] = value
+		}
+	}
+	return json.MarshalIndent(projected, "", "  ")
+}
+
+// lookupPath walks doc along a dot-separated path; numeric segments index arrays.
+func lookupPath(doc interface{}, path string) (interface{}, bool) {
+	current := doc
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+const projectionItem = `[{"title":"Clip","author":{"name":"user","id":7},"viewCount":1200,"formats":[{"url":"https://cdn/a"},{"url":"https://cdn/b"}],"empty":null}]`
+
+// compactJSON returns data without insignificant whitespace.
+func compactJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	return buf.String()
+}
+
+func TestProjectFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		raw   string
+		paths []string
+		want  string
+	}{
+		{"top-level", projectionItem, []string{"title", "viewCount"}, `{"title":"Clip","viewCount":1200}`},
+		{"nested", projectionItem, []string{"author.name"}, `{"author.name":"user"}`},
+		{"object", projectionItem, []string{"author"}, `{"author":{"id":7,"name":"user"}}`},
+		{"array index", projectionItem, []string{"formats.1.url"}, `{"formats.1.url":"https://cdn/b"}`},
+		{"null kept", projectionItem, []string{"empty"}, `{"empty":null}`},
+		{"missing left out", projectionItem, []string{"title", "likes", "author.email", "formats.5.url", "formats.x", "title.length"}, `{"title":"Clip"}`},
+		{"single object", `{"title":"Clip"}`, []string{"title"}, `{"title":"Clip"}`},
+		{"empty dataset", `[]`, []string{"title"}, `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := projectFields([]byte(tt.raw), tt.paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compactJSON(t, got) != tt.want {
+				t.Errorf("projectFields = %s, want %s", compactJSON(t, got), tt.want)
+			}
+		})
+	}
+}
+
+func TestProjectFieldsInvalidJSON(t *testing.T) {
+	if _, err := projectFields([]byte("not json"), []string{"title"}); err == nil {
+		t.Error("projectFields of invalid JSON succeeded")
+	}
+}
+
+// MetadataFields saves the projection as metadata.json, next to the raw
+// metadata unless SkipRawMetadata drops it.
+func TestRunJobProjectsMetadata(t *testing.T) {
+	for _, skipRaw := range []bool{false, true} {
+		scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(projectionItem), VideoURL: "https://cdn/v.mp4"}}
+		downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}
+		o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{MetadataFields: []string{"title", "author.name"}, SkipRawMetadata: skipRaw})
+
+		result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := compactJSON(t, []byte(readJobFile(t, o, result.Job.ID, "metadata.json"))); got != `{"author.name":"user","title":"Clip"}` {
+			t.Errorf("metadata.json = %s", got)
+		}
+		_, err = os.Stat(filepath.Join(o.storage.GetJobPath(result.Job.ID), "metadata_raw.json"))
+		if saved := err == nil; saved == skipRaw {
+			t.Errorf("SkipRawMetadata %v: metadata_raw.json saved %v", skipRaw, saved)
+		}
+		if skipRaw && result.MetadataPath != o.storage.GetJobPath(result.Job.ID)+"/metadata.json" {
+			t.Errorf("MetadataPath = %s, want metadata.json", result.MetadataPath)
+		}
+	}
+}
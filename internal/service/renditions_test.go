@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// heightResolver resolves each available height to its own URL.
+type heightResolver struct {
+	fakeResolver
+	heights map[int]string
+}
+
+func (r *heightResolver) ResolveVideoURLForHeight(ctx context.Context, videoPageURL string, height int) (string, error) {
+	u, ok := r.heights[height]
+	if !ok {
+		return "", fmt.Errorf("requested format not available: %w", errFake)
+	}
+	return u, nil
+}
+
+func TestDownloadRenditions(t *testing.T) {
+	const youtube = "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	tests := []struct {
+		name      string
+		qualities []string
+		want      []string // saved qualities
+		wantStep  domain.JobStep
+	}{
+		{"all available", []string{"1080p", "360p"}, []string{"1080p", "360p"}, ""},
+		{"one unavailable", []string{"1080p", "720p", "360p"}, []string{"1080p", "360p"}, ""},
+		{"invalid skipped", []string{"best", "360p"}, []string{"360p"}, ""},
+		{"none available", []string{"720p", "144p"}, nil, domain.StepResolve},
+		{"none valid", []string{"best"}, nil, domain.StepResolve},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &heightResolver{heights: map[int]string{1080: "https://cdn/1080.mp4", 360: "https://cdn/360.mp4"}}
+			downloader := &fakeDownloader{files: map[string]string{"https://cdn/1080.mp4": "1080p video", "https://cdn/360.mp4": "360p video"}}
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`)}}
+			o, _ := newTestOrchestrator(t, scraper, downloader, resolver, Options{Qualities: tt.qualities})
+
+			result, err := o.RunJob(context.Background(), youtube)
+			if tt.wantStep != "" {
+				var jobErr *domain.JobError
+				if !errors.As(err, &jobErr) || jobErr.Step != tt.wantStep {
+					t.Fatalf("err = %v, want a %s failure", err, tt.wantStep)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var saved []string
+			for _, r := range result.Renditions {
+				saved = append(saved, r.Quality)
+				name := "video_" + r.Quality + ".mp4"
+				if r.Path != o.storage.GetJobPath(result.Job.ID)+"/"+name {
+					t.Errorf("%s path = %s", r.Quality, r.Path)
+				}
+				if got := readJobFile(t, o, result.Job.ID, name); got != r.Quality+" video" || r.Bytes != int64(len(got)) {
+					t.Errorf("%s = %q, %d bytes; want its own rendition", name, got, r.Bytes)
+				}
+			}
+			if !slices.Equal(saved, tt.want) {
+				t.Errorf("renditions = %v, want %v", saved, tt.want)
+			}
+			if result.VideoPath != result.Renditions[0].Path {
+				t.Errorf("VideoPath = %s, want the first rendition", result.VideoPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestRunJobUsesResolver(t *testing.T) {
+	resolver := &fakeResolver{url: "https://cdn.example.com/v.mp4"}
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn.example.com/v.mp4": "video"}}
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, downloader, resolver, Options{})
+
+	result, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=abc")
+	if err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+	if len(resolver.calls) != 1 || resolver.calls[0] != "https://www.youtube.com/watch?v=abc" {
+		t.Errorf("resolver calls = %q", resolver.calls)
+	}
+	if got := readJobFile(t, o, result.Job.ID, "video.mp4"); got != "video" {
+		t.Errorf("video.mp4 = %q", got)
+	}
+}
+
+func TestRunJobUsesResolvedFormat(t *testing.T) {
+	resolver := &fakeFormatResolver{format: ports.ResolvedFormat{URL: "https://cdn.example.com/v.webm", Ext: "webm"}}
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn.example.com/v.webm": "webm video"}}
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, downloader, resolver, Options{})
+
+	result, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=abc")
+	if err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+	if got := readJobFile(t, o, result.Job.ID, "video.webm"); got != "webm video" {
+		t.Errorf("video.webm = %q", got)
+	}
+	if filepath.Base(result.VideoPath) != "video.webm" {
+		t.Errorf("VideoPath = %s, want video.webm", result.VideoPath)
+	}
+	if _, err := os.Stat(filepath.Join(o.storage.GetJobPath(result.Job.ID), "video.mp4")); !os.IsNotExist(err) {
+		t.Errorf("video.mp4 saved as well: %v", err)
+	}
+}
+
+// The headers yt-dlp resolved the format with are sent with its download.
+func TestRunJobSendsResolvedHeaders(t *testing.T) {
+	headers := map[string]string{"User-Agent": "Mozilla/5.0 (yt-dlp)", "Referer": "https://www.youtube.com/"}
+	resolver := &fakeFormatResolver{format: ports.ResolvedFormat{URL: "https://cdn.example.com/v.mp4", Ext: "mp4", Headers: headers}}
+	var sent map[string]string
+	downloader := downloadFunc(func(ctx context.Context, videoURL string) (io.ReadCloser, error) {
+		sent = ports.RequestHeadersFrom(ctx)
+		return io.NopCloser(strings.NewReader("video")), nil
+	})
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, downloader, resolver, Options{})
+
+	if _, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=abc"); err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+	if !maps.Equal(sent, headers) {
+		t.Errorf("download sent headers %v, want %v", sent, headers)
+	}
+}
+
+func TestRunJobResolverFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		resolveErr error
+		scraped    string
+		wantErr    error
+		wantOK     bool
+	}{
+		{name: "no fallback", resolveErr: errFake, wantErr: errFake},
+		{name: "falls back to the scraped URL", resolveErr: errFake, scraped: "https://cdn.example.com/scraped.mp4", wantOK: true},
+		{name: "empty URL falls back to the scraped URL", scraped: "https://cdn.example.com/scraped.mp4", wantOK: true},
+		{name: "empty URL with no fallback"},
+		{name: "live stream doesn't fall back", resolveErr: ports.ErrLiveStream, scraped: "https://cdn.example.com/scraped.mp4", wantErr: ports.ErrLiveStream},
+		{name: "resolver-only video doesn't fall back", resolveErr: ports.ErrNoDirectURL, scraped: "https://cdn.example.com/scraped.mp4", wantErr: ports.ErrNoDirectURL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &fakeResolver{err: tt.resolveErr}
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: tt.scraped}}
+			downloader := &fakeDownloader{files: map[string]string{"https://cdn.example.com/scraped.mp4": "video"}}
+			o, _ := newTestOrchestrator(t, scraper, downloader, resolver, Options{})
+
+			result, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=abc")
+			if result.Success != tt.wantOK {
+				t.Errorf("Success = %v, want %v (err %v)", result.Success, tt.wantOK, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantOK && !slices.Equal(downloader.calls, []string{tt.scraped}) {
+				t.Errorf("downloaded %q, want the scraped URL", downloader.calls)
+			}
+		})
+	}
+}
+
+func TestRunJobTikTokSkipsResolver(t *testing.T) {
+	resolver := &fakeResolver{url: "https://cdn.example.com/resolved.mp4"}
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn.example.com/tiktok.mp4"}}
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn.example.com/tiktok.mp4": "video"}}
+	o, _ := newTestOrchestrator(t, scraper, downloader, resolver, Options{})
+
+	if _, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/123"); err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+	if len(resolver.calls) != 0 {
+		t.Errorf("resolver called for a TikTok job: %q", resolver.calls)
+	}
+}
+
+// fakeFileResolver resolves a ResolverOnly format and downloads it itself.
+type fakeFileResolver struct {
+	fakeFormatResolver
+	content string
+}
+
+func (f *fakeFileResolver) DownloadFormat(ctx context.Context, videoPageURL string, format *ports.ResolvedFormat, dir string) (string, error) {
+	path := filepath.Join(dir, "abc."+format.Ext)
+	return path, os.WriteFile(path, []byte(f.content), 0644)
+}
+
+func TestRunJobResolverOnlyFormat(t *testing.T) {
+	resolver := &fakeFileResolver{
+		fakeFormatResolver: fakeFormatResolver{format: ports.ResolvedFormat{URL: "https://cdn.example.com/video-only", Ext: "mkv", ResolverOnly: true}},
+		content:            "merged video",
+	}
+	downloader := &fakeDownloader{}
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, downloader, resolver, Options{})
+
+	result, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=abc")
+	if err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+	if got := readJobFile(t, o, result.Job.ID, "video.mkv"); got != "merged video" {
+		t.Errorf("video.mkv = %q", got)
+	}
+	if len(downloader.calls) != 0 {
+		t.Errorf("the video-only part was downloaded directly: %q", downloader.calls)
+	}
+}
+
+func TestRunJobResolverOnlyFormatUnsupported(t *testing.T) {
+	resolver := &fakeFormatResolver{format: ports.ResolvedFormat{URL: "https://cdn.example.com/video-only", ResolverOnly: true}}
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, &fakeDownloader{}, resolver, Options{})
+
+	result, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=abc")
+	if err == nil || result.Success {
+		t.Fatalf("RunJob succeeded with a format nothing can download")
+	}
+}
+
+// resolverSeq resolves to the next of urls on each call, repeating the last.
+type resolverSeq struct {
+	mu    sync.Mutex
+	urls  []string
+	calls int
+}
+
+func (r *resolverSeq) ResolveVideoURL(ctx context.Context, videoPageURL string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	return r.urls[min(r.calls, len(r.urls))-1], nil
+}
+
+// A download whose resolved URL has expired is retried with a freshly
+// resolved one, up to Options.MaxResolveRetries times.
+func TestRunJobReresolvesExpiredURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		urls         []string
+		retries      int
+		wantResolves int
+		wantOK       bool
+	}{
+		{"fresh URL works", []string{"https://cdn/expired.mp4", "https://cdn/fresh.mp4"}, 2, 2, true},
+		{"after several", []string{"https://cdn/e1.mp4", "https://cdn/e2.mp4", "https://cdn/fresh.mp4"}, 2, 3, true},
+		{"retries run out", []string{"https://cdn/e1.mp4", "https://cdn/e2.mp4", "https://cdn/e3.mp4", "https://cdn/fresh.mp4"}, 2, 3, false},
+		{"disabled", []string{"https://cdn/expired.mp4", "https://cdn/fresh.mp4"}, 0, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &resolverSeq{urls: tt.urls}
+			// Any other URL fails with ErrURLExpired
+			downloader := &fakeDownloader{files: map[string]string{"https://cdn/fresh.mp4": "video"}}
+			o, _ := newTestOrchestrator(t, &fakeScraper{}, downloader, resolver, Options{MaxResolveRetries: tt.retries})
+
+			result, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=abc")
+			if resolver.calls != tt.wantResolves {
+				t.Errorf("resolved %d times, want %d", resolver.calls, tt.wantResolves)
+			}
+			if !tt.wantOK {
+				if !errors.Is(err, ports.ErrURLExpired) {
+					t.Errorf("RunJob err = %v, want ErrURLExpired", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RunJob: %v", err)
+			}
+			if got := readJobFile(t, o, result.Job.ID, "video.mp4"); got != "video" {
+				t.Errorf("video.mp4 = %q", got)
+			}
+			if want := tt.urls[:tt.wantResolves]; !slices.Equal(downloader.calls, want) {
+				t.Errorf("downloaded %v, want %v", downloader.calls, want)
+			}
+		})
+	}
+}
+
+// Without a resolver, the video page is scraped again for a fresh URL.
+func TestRunJobRescrapesExpiredURL(t *testing.T) {
+	scrapes := 0
+	scraper := scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+		scrapes++
+		videoURL := "https://cdn/expired.mp4"
+		if scrapes > 1 {
+			videoURL = "https://cdn/fresh.mp4"
+		}
+		return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: videoURL}, nil
+	})
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn/fresh.mp4": "video"}}
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{MaxResolveRetries: 1})
+
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+	if scrapes != 2 {
+		t.Errorf("scraped %d times, want 2", scrapes)
+	}
+	if got := readJobFile(t, o, result.Job.ID, "video.mp4"); got != "video" {
+		t.Errorf("video.mp4 = %q", got)
+	}
+}
+
+// An error page served in place of the video is treated like an expired URL.
+func TestRunJobReresolvesOnErrorPage(t *testing.T) {
+	resolver := &resolverSeq{urls: []string{"https://cdn/error-page.mp4", "https://cdn/fresh.mp4"}}
+	downloader := downloadFunc(func(ctx context.Context, url string) (io.ReadCloser, error) {
+		if url != "https://cdn/fresh.mp4" {
+			return nil, fmt.Errorf("%w: server sent text/html", ports.ErrNotVideo)
+		}
+		return io.NopCloser(strings.NewReader("video")), nil
+	})
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, downloader, resolver, Options{MaxResolveRetries: 1})
+
+	result, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=abc")
+	if err != nil {
+		t.Fatalf("RunJob: %v", err)
+	}
+	if resolver.calls != 2 {
+		t.Errorf("resolved %d times, want 2", resolver.calls)
+	}
+	if got := readJobFile(t, o, result.Job.ID, "video.mp4"); got != "video" {
+		t.Errorf("video.mp4 = %q", got)
+	}
+}
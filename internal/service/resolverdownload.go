@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"scrapeanddown/internal/adapters/tempdir"
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// fetchWithResolver has the resolver download a ResolverOnly format into
+// dir, and opens the file written. The caller closes and removes it.
+func (o *Orchestrator) fetchWithResolver(ctx context.Context, job domain.Job, video *resolvedVideo, dir string) (*os.File, error) {
+	fd, ok := o.resolver.(ports.FileDownloader)
+	if !ok {
+		return nil, fmt.Errorf("the resolved format has no single download URL and the resolver can't download it itself")
+	}
+	o.logger.Printf("[JOB %s] Downloading video via the resolver (the format has no single URL)...", job.ID)
+	path, err := fd.DownloadFormat(ctx, job.URL, video.resolverFormat, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download video: %w", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the downloaded video: %w", err)
+	}
+	return f, nil
+}
+
+// downloadWithResolver is downloadVideo for a ResolverOnly format: the
+// resolver downloads it into the job's scratch directory, and the file is
+// then saved as filename with the extension of the file it wrote. Such
+// downloads can't be resumed.
+func (o *Orchestrator) downloadWithResolver(ctx context.Context, job domain.Job, video *resolvedVideo, filename string) (*savedVideo, domain.JobStep, error) {
+	dir, ok := tempdir.DirFromContext(ctx)
+	if !ok {
+		var err error
+		if dir, err = o.temp.JobDir(job.ID); err != nil {
+			return nil, domain.StepSave, err
+		}
+	}
+	start := o.now()
+	f, err := o.fetchWithResolver(ctx, job, video, dir)
+	if err != nil {
+		return nil, domain.StepDownload, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	filename = withContainerExt(filename, filepath.Ext(f.Name()), "")
+
+	hashes := newHashSink(o.opts.Hashes)
+	counter := &countingReader{r: io.TeeReader(f, hashes)}
+	if err := o.storage.SaveVideo(ctx, job.ID, counter, filename); err != nil {
+		return nil, domain.StepSave, fmt.Errorf("failed to save video: %w", err)
+	}
+	saved := &savedVideo{
+		artifact: artifactRecord{name: filename, kind: "video"},
+		bytes:    counter.n,
+		duration: o.now().Sub(start),
+	}
+	if counter.n > 0 {
+		saved.artifact.sha256 = hashes.sha256()
+		saved.artifact.digests = hashes.digests()
+	}
+	return saved, "", nil
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// downloadStateInterval is how many bytes are written between download state saves.
+const downloadStateInterval = 16 << 20
+
+// ResumeJob continues an interrupted job from its persisted download state.
+// The partial file is resumed with a Range request if the remote file is
+// unchanged (per ETag/Last-Modified), otherwise it is downloaded again.
+func (o *Orchestrator) ResumeJob(ctx context.Context, jobID string) (*domain.JobResult, error) {
+	data, err := o.storage.LoadDownloadState(ctx, jobID)
+	if err != nil {
+		for _, ext := range videoFileExts {
+			name := withContainerExt(defaultVideoFile, ext, "")
+			if done, _ := o.storage.Exists(ctx, jobID, name); done {
+				return nil, fmt.Errorf("nothing to resume for job %s: %s is already complete", jobID, name)
+			}
+		}
+		return nil, fmt.Errorf("nothing to resume for job %s: %w", jobID, err)
+	}
+	var state domain.DownloadState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid download state for job %s: %w", jobID, err)
+	}
+
+	job := domain.Job{
+		ID:        jobID,
+		URL:       state.PageURL,
+		Platform:  state.Platform,
+		CreatedAt: o.now().UTC(),
+	}
+	result := &domain.JobResult{Job: job, Success: false}
+	defer o.logger.startJob(jobID)()
+	o.logger.Printf("[JOB %s] Resuming download of %s", jobID, state.TargetFile)
+	defer o.logTimings(result)
+
+	if err := o.storage.InitJob(ctx, jobID); err != nil {
+		return result, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to init job: %v", err))
+	}
+	defer o.releaseJob(ctx, jobID)
+	// Runs before the lock is released, after everything else
+	defer o.writeMarker(ctx, result)
+
+	resolve := func() (*resolvedVideo, error) {
+		// Renditions were resolved with a quality-specific format we don't persist
+		if !isMainVideoFile(state.TargetFile) {
+			return nil, fmt.Errorf("cannot re-resolve expired URL for %s", state.TargetFile)
+		}
+		return o.resolveVideoURL(ctx, job, result, nil)
+	}
+	video := &resolvedVideo{URL: state.VideoURL, Headers: state.Headers}
+	result.Timings.DownloadStartedAt = o.now()
+	saved, step, err := o.downloadVideo(ctx, job, video, state.TargetFile, resolve, &state)
+	result.Timings.DownloadEndedAt = o.now()
+	if err != nil {
+		return result, o.fail(result, step, err, err.Error())
+	}
+
+	result.DownloadBytes = saved.bytes
+	result.DownloadDuration = saved.duration
+	result.AvgThroughputBytesPerSec = throughput(result.DownloadBytes, result.DownloadDuration)
+	result.VideoPath = o.storage.GetJobPath(jobID) + "/" + state.TargetFile
+
+	if o.opts.WriteManifest {
+		artifacts := append(o.existingArtifacts(ctx, jobID), saved.artifact)
+		if err := o.writeManifest(ctx, job, artifacts); err != nil {
+			return result, o.fail(result, domain.StepSave, err, fmt.Sprintf("failed to write manifest: %v", err))
+		}
+		o.logger.Printf("[JOB %s] Saved manifest.json", jobID)
+		o.emit(jobID, EventArtifactSaved, map[string]interface{}{"name": "manifest.json", "kind": "manifest"})
+	}
+
+	result.Success = true
+	result.CompletedAt = o.now().UTC()
+	o.logger.Printf("[JOB %s] Resumed download completed", jobID)
+	return result, nil
+}
+
+// resumedArtifacts are the files the interrupted run may have saved before
+// the download, in manifest order.
+var resumedArtifacts = []struct{ name, kind string }{
+	{"input.json", "input"},
+	{"metadata.json", "metadata"},
+	{"metadata_raw.json", "metadata"},
+	{"metadata_normalized.json", "metadata"},
+	{"comments.json", "comments"},
+	{"scrape_meta.json", "metadata"},
+	{"page.html", "page"},
+}
+
+// existingArtifacts lists the interrupted run's artifacts for the manifest.
+// Their checksums were never recorded, so the entries carry none.
+func (o *Orchestrator) existingArtifacts(ctx context.Context, jobID string) []artifactRecord {
+	var artifacts []artifactRecord
+	for _, a := range resumedArtifacts {
+		if ok, err := o.storage.Exists(ctx, jobID, a.name); err == nil && ok {
+			artifacts = append(artifacts, artifactRecord{name: a.name, kind: a.kind})
+		}
+	}
+	return artifacts
+}
+
+// resumePoint returns the offset and If-Range validator to resume from.
+// Without a usable validator the remote file can't be proven unchanged, so
+// the download restarts from zero.
+func (o *Orchestrator) resumePoint(ctx context.Context, jobID string, state *domain.DownloadState) (int64, string) {
+	exists, err := o.storage.Exists(ctx, jobID, state.TargetFile)
+	if err != nil {
+		o.logger.Printf("[JOB %s] WARNING: %v, restarting download", jobID, err)
+		return 0, ""
+	}
+	if !exists {
+		return 0, ""
+	}
+	info, err := o.storage.StatArtifact(ctx, jobID, state.TargetFile)
+	if err != nil || info.Size == 0 {
+		return 0, ""
+	}
+
+	// If-Range only accepts strong ETags
+	validator := state.ETag
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = state.LastModified
+	}
+	if validator == "" {
+		return 0, ""
+	}
+	return info.Size, validator
+}
+
+// openDownload starts a (possibly ranged) download with the video's
+// headers. Downloaders that can't resume always fetch the full file.
+func (o *Orchestrator) openDownload(ctx context.Context, video *resolvedVideo, offset int64, ifRange string) (*ports.DownloadResponse, error) {
+	ctx = ports.WithRequestHeaders(ctx, video.Headers)
+	if rd, ok := o.downloader.(ports.ResumableDownloader); ok {
+		return rd.DownloadFrom(ctx, video.URL, offset, ifRange)
+	}
+	body, err := o.downloader.Download(ctx, video.URL)
+	if err != nil {
+		return nil, err
+	}
+	return &ports.DownloadResponse{Body: body}, nil
+}
+
+// saveDownloadState persists the state; failures only cost resumability.
+func (o *Orchestrator) saveDownloadState(ctx context.Context, state *domain.DownloadState) {
+	state.UpdatedAt = o.now().UTC()
+	data, _ := json.MarshalIndent(state, "", "  ")
+	if err := o.storage.SaveDownloadState(ctx, state.JobID, data); err != nil {
+		o.logger.Printf("[JOB %s] WARNING: %v", state.JobID, err)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/adapters/downloader"
+	"scrapeanddown/internal/adapters/localstorage"
+	"scrapeanddown/internal/core/ports"
+)
+
+// videoServer serves a video with Range and If-Range support. Its first
+// response is cut off halfway through, as if the process had been killed.
+type videoServer struct {
+	mu       sync.Mutex
+	content  string
+	etag     string
+	requests []http.Header
+}
+
+func (s *videoServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	content, etag := s.content, s.etag
+	s.requests = append(s.requests, r.Header.Clone())
+	first := len(s.requests) == 1
+	s.mu.Unlock()
+
+	w.Header().Set("ETag", etag)
+	if first {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte(content[:5]))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}
+	http.ServeContent(w, r, "v.mp4", time.Time{}, strings.NewReader(content))
+}
+
+// A download cut off by a restart resumes with a Range request while the
+// remote file is unchanged, and starts over once it has changed.
+func TestResumeJobAfterRestart(t *testing.T) {
+	tests := []struct {
+		name    string
+		changed bool
+		want    string
+	}{
+		{"unchanged", false, "0123456789"},
+		{"changed", true, "abcdefghij"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &videoServer{content: "0123456789", etag: `"v1"`}
+			srv := httptest.NewServer(server)
+			t.Cleanup(srv.Close)
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: srv.URL + "/v.mp4"}}
+			o, root := newTestOrchestrator(t, scraper, downloader.NewHTTPDownloader(), nil, Options{})
+
+			result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+			if err == nil {
+				t.Fatal("RunJob of a cut-off download succeeded")
+			}
+			jobID := result.Job.ID
+			if got := readJobFile(t, o, jobID, "video.mp4"); got != "01234" {
+				t.Fatalf("partial video = %q, want the 5 bytes received", got)
+			}
+
+			if tt.changed {
+				server.mu.Lock()
+				server.content, server.etag = "abcdefghij", `"v2"`
+				server.mu.Unlock()
+			}
+			// The next process only has the data directory
+			o = NewOrchestrator(scraper, downloader.NewHTTPDownloader(), localstorage.NewLocalStorage(root), nil,
+				log.New(testWriter{t}, "", 0), Options{TempDir: t.TempDir()})
+			if _, err := o.ResumeJob(context.Background(), jobID); err != nil {
+				t.Fatalf("ResumeJob: %v", err)
+			}
+			if got := readJobFile(t, o, jobID, "video.mp4"); got != tt.want {
+				t.Errorf("resumed video = %q, want %q", got, tt.want)
+			}
+			resumed := server.requests[1]
+			if resumed.Get("Range") != "bytes=5-" || resumed.Get("If-Range") != `"v1"` {
+				t.Errorf("resumed with Range %q, If-Range %q; want bytes=5- if \"v1\"", resumed.Get("Range"), resumed.Get("If-Range"))
+			}
+			if _, err := o.storage.LoadDownloadState(context.Background(), jobID); err == nil {
+				t.Error("download state left after the download completed")
+			}
+			if _, err := o.ResumeJob(context.Background(), jobID); err == nil || !strings.Contains(err.Error(), "already complete") {
+				t.Errorf("second ResumeJob err = %v, want nothing to resume", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"scrapeanddown/internal/core/domain"
+)
+
+// retryAttemptKey carries the job shared by RunJobWithRetry's attempts.
+type retryAttemptKey struct{}
+
+// retryAttempt is the job RunJobWithRetry's attempts run as: every attempt
+// keeps its job ID, and so its directory, rather than leaving one failed
+// directory per attempt behind.
+type retryAttempt struct {
+	jobID       string
+	saved       []artifactRecord // What the previous attempt saved
+	initialized bool             // The job was initialized in storage, and is held until the last attempt ends
+}
+
+// withRetryAttempt makes RunJob run as attempt's job.
+func withRetryAttempt(ctx context.Context, attempt *retryAttempt) context.Context {
+	return context.WithValue(ctx, retryAttemptKey{}, attempt)
+}
+
+// retryAttemptFrom returns the job set by withRetryAttempt, if any.
+func retryAttemptFrom(ctx context.Context) *retryAttempt {
+	attempt, _ := ctx.Value(retryAttemptKey{}).(*retryAttempt)
+	return attempt
+}
+
+// staleFiles are artifacts a failed attempt may leave without recording
+// them: the duplicate reference is saved in place of a video.
+var staleFiles = []string{"duplicate_of.json"}
+
+// reusableArtifact reports whether an artifact of this kind, left by a
+// failed attempt, may stay for the next one. The input and the metadata
+// stage's artifacts (metadata, comments, page) are complete once saved, and
+// the next attempt saves them again. Everything from the download stage on
+// is removed, so a video, rendition or transcript the next attempt doesn't
+// produce can't linger beside its results.
+func reusableArtifact(kind string) bool {
+	switch kind {
+	case "input", "metadata", "comments", "page":
+		return true
+	}
+	return false
+}
+
+// clearStaleArtifacts removes what the previous attempt of a retried job
+// left that the next attempt can't reuse (see reusableArtifact), including
+// the partial file of an interrupted download and its download state.
+// Failures are logged: the attempt overwrites what it saves anyway.
+func (o *Orchestrator) clearStaleArtifacts(ctx context.Context, jobID string, attempt *retryAttempt) {
+	stale := append([]string(nil), staleFiles...)
+	for _, artifact := range attempt.saved {
+		if !reusableArtifact(artifact.kind) {
+			stale = append(stale, artifact.name)
+		}
+	}
+	attempt.saved = nil
+	if data, err := o.storage.LoadDownloadState(ctx, jobID); err == nil {
+		var state domain.DownloadState
+		if json.Unmarshal(data, &state) == nil && state.TargetFile != "" {
+			stale = append(stale, state.TargetFile)
+		}
+		if err := o.storage.RemoveDownloadState(ctx, jobID); err != nil {
+			o.logger.Printf("[JOB %s] WARNING: %v", jobID, err)
+		}
+	}
+
+	removed := 0
+	for _, name := range stale {
+		if exists, _ := o.storage.Exists(ctx, jobID, name); !exists {
+			continue
+		}
+		if err := o.storage.RemoveArtifact(ctx, jobID, name); err != nil {
+			o.logger.Printf("[JOB %s] WARNING: failed to remove stale artifact: %v", jobID, err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		o.logger.Printf("[JOB %s] Removed %d stale artifacts of the previous attempt", jobID, removed)
+	}
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+)
+
+// hasSkipRules reports whether any skip rule is configured.
+func (o *Orchestrator) hasSkipRules() bool {
+	return o.opts.MinViews > 0 || o.opts.MinDuration > 0 || o.opts.MaxDuration > 0
+}
+
+// skipReason returns why a video with the given duration and view count
+// fails the skip rules, or "" if it passes. Zero means unknown, which
+// passes: metadata sources without view counts (e.g. oEmbed) report none.
+func (o *Orchestrator) skipReason(duration time.Duration, views int64) string {
+	switch {
+	case views > 0 && o.opts.MinViews > 0 && views < o.opts.MinViews:
+		return fmt.Sprintf("%d views is below the minimum of %d", views, o.opts.MinViews)
+	case duration > 0 && o.opts.MinDuration > 0 && duration < o.opts.MinDuration:
+		return fmt.Sprintf("duration %s is shorter than %s", duration, o.opts.MinDuration)
+	case duration > 0 && o.opts.MaxDuration > 0 && duration > o.opts.MaxDuration:
+		return fmt.Sprintf("duration %s exceeds %s", duration, o.opts.MaxDuration)
+	}
+	return ""
+}
+
+// skip ends a job as skipped.
+func (o *Orchestrator) skip(result *domain.JobResult, reason string) {
+	result.Skipped = true
+	result.SkipReason = reason
+	result.CompletedAt = o.now().UTC()
+	o.logger.Printf("[JOB %s] Skipping download: %s", result.Job.ID, reason)
+}
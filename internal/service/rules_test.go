@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestSkipReason(t *testing.T) {
+	rules := Options{MinViews: 10_000, MinDuration: 30 * time.Second, MaxDuration: 10 * time.Minute}
+	tests := []struct {
+		name     string
+		opts     Options
+		duration time.Duration
+		views    int64
+		want     string // Substring of the reason; "" to pass
+	}{
+		{"passes every rule", rules, time.Minute, 50_000, ""},
+		{"at the bounds", rules, 30 * time.Second, 10_000, ""},
+		{"at the maximum", rules, 10 * time.Minute, 10_000, ""},
+		{"too few views", rules, time.Minute, 9_999, "9999 views is below the minimum of 10000"},
+		{"too short", rules, 29 * time.Second, 50_000, "duration 29s is shorter than 30s"},
+		{"too long", rules, 11 * time.Minute, 50_000, "duration 11m0s exceeds 10m0s"},
+		{"unknown views pass", rules, time.Minute, 0, ""},
+		{"unknown duration passes", rules, 0, 50_000, ""},
+		{"no rules", Options{}, time.Second, 1, ""},
+	}
+	for _, tt := range tests {
+		o := &Orchestrator{opts: tt.opts}
+		got := o.skipReason(tt.duration, tt.views)
+		if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+			t.Errorf("%s: skipReason(%s, %d) = %q, want %q", tt.name, tt.duration, tt.views, got, tt.want)
+		}
+	}
+}
+
+// A video failing a rule is skipped before the download, rather than failed.
+func TestRunJobSkipRules(t *testing.T) {
+	const tiktok, youtube = "https://www.tiktok.com/@user/video/1", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	tests := []struct {
+		name        string
+		url         string
+		views       int64
+		duration    float64 // In the scraped metadata
+		probed      float64 // Duration reported by the resolver's probe
+		wantSkipped bool
+	}{
+		{"passes", tiktok, 50_000, 60, 0, false},
+		{"below min views", tiktok, 500, 60, 0, true},
+		{"below min duration", tiktok, 50_000, 10, 0, true},
+		{"above max duration", tiktok, 50_000, 900, 0, true},
+		{"probed duration passes", youtube, 50_000, 0, 60, false},
+		{"probed duration too long", youtube, 50_000, 0, 900, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := fmt.Sprintf(`[{"playCount": %d, "videoMeta": {"duration": %g}}]`, tt.views, tt.duration)
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(raw), VideoURL: "https://cdn/v.mp4"}}
+			resolver := &probeResolver{fakeResolver: fakeResolver{url: "https://cdn/v.mp4"}, info: ports.VideoInfo{DurationSeconds: tt.probed}}
+			downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}
+			o, _ := newTestOrchestrator(t, scraper, downloader, resolver,
+				Options{MinViews: 10_000, MinDuration: 30 * time.Second, MaxDuration: 10 * time.Minute})
+
+			result, err := o.RunJob(context.Background(), tt.url)
+			if err != nil {
+				t.Fatalf("RunJob: %v", err)
+			}
+			if result.Skipped != tt.wantSkipped || (result.SkipReason != "") != tt.wantSkipped {
+				t.Errorf("Skipped = %v (%q), want %v", result.Skipped, result.SkipReason, tt.wantSkipped)
+			}
+			if downloaded := len(downloader.calls) > 0; downloaded == tt.wantSkipped {
+				t.Errorf("downloaded %v, want %v", downloaded, !tt.wantSkipped)
+			}
+		})
+	}
+}
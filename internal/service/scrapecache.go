@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// freshScrapeKey marks scrapes that must bypass CachingScraper, e.g. the
+// re-scrape for a fresh video URL after the cached one expired.
+type freshScrapeKey struct{}
+
+// scrapeCacheEntry is a cached ScrapeResult. The result's byte fields
+// encode as base64, so they come back byte for byte.
+type scrapeCacheEntry struct {
+	URL       string              `json:"url"`
+	ScrapedAt time.Time           `json:"scraped_at"`
+	Result    *ports.ScrapeResult `json:"result"`
+}
+
+// CachingScraper wraps a Scraper, caching its results on disk by canonical
+// URL and scraper settings so that scraping a URL again within the TTL
+// doesn't call the wrapped scraper (and spend Apify credits).
+type CachingScraper struct {
+	scraper  ports.Scraper
+	dir      string
+	ttl      time.Duration
+	settings string
+	now      func() time.Time
+}
+
+// NewCachingScraper creates a CachingScraper storing results under dir.
+// Entries older than ttl are scraped again; a ttl of 0 never expires them.
+// settings describe the wrapped scraper's configuration where it changes
+// the result (e.g. "max-height=1080"), so that a result scraped under other
+// settings isn't served from the cache.
+func NewCachingScraper(scraper ports.Scraper, dir string, ttl time.Duration, settings ...string) *CachingScraper {
+	return &CachingScraper{scraper: scraper, dir: dir, ttl: ttl, settings: strings.Join(settings, "\n"), now: time.Now}
+}
+
+// Scrape returns the cached result for url if there is a fresh one, and
+// otherwise scrapes it and caches the result. Failed scrapes aren't cached.
+func (c *CachingScraper) Scrape(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+	path := c.path(url)
+	if fresh, _ := ctx.Value(freshScrapeKey{}).(bool); !fresh {
+		if result, ok := c.load(path); ok {
+			// The run was paid for when it was cached
+			result.ComputeUnits, result.CostUSD = 0, 0
+			return result, nil
+		}
+	}
+
+	result, err := c.scraper.Scrape(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	// A cache that can't be written just misses next time
+	_ = c.save(path, url, result)
+	return result, nil
+}
+
+// ScrapeBatch is Scrape for several URLs: cached ones are served from the
+// cache, and the rest go to the wrapped scraper's ScrapeBatch if it has one
+// (see ports.BatchScraper). Otherwise they're left nil for their jobs to
+// scrape.
+func (c *CachingScraper) ScrapeBatch(ctx context.Context, urls []string) ([]*ports.ScrapeResult, error) {
+	results := make([]*ports.ScrapeResult, len(urls))
+	var missing []string
+	var indexes []int
+	for i, url := range urls {
+		if result, ok := c.load(c.path(url)); ok {
+			result.ComputeUnits, result.CostUSD = 0, 0
+			results[i] = result
+			continue
+		}
+		missing = append(missing, url)
+		indexes = append(indexes, i)
+	}
+
+	batcher, ok := c.scraper.(ports.BatchScraper)
+	if !ok || len(missing) == 0 {
+		return results, nil
+	}
+	scraped, err := batcher.ScrapeBatch(ctx, missing)
+	for j, result := range scraped {
+		if result == nil {
+			continue
+		}
+		results[indexes[j]] = result
+		_ = c.save(c.path(missing[j]), missing[j], result)
+	}
+	return results, err
+}
+
+// path returns the cache file for url under c's settings.
+func (c *CachingScraper) path(url string) string {
+	key := canonicalURL(url)
+	if c.settings != "" {
+		key += "\n" + c.settings
+	}
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *CachingScraper) load(path string) (*ports.ScrapeResult, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry scrapeCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	if entry.Result == nil || (c.ttl > 0 && c.now().Sub(entry.ScrapedAt) > c.ttl) {
+		return nil, false
+	}
+	return entry.Result, true
+}
+
+func (c *CachingScraper) save(path, url string, result *ports.ScrapeResult) error {
+	data, err := json.MarshalIndent(scrapeCacheEntry{URL: url, ScrapedAt: c.now().UTC(), Result: result}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	// Write-then-rename so concurrent jobs never read a torn entry
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
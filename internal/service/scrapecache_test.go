@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCachingScraperKeysOnSettings(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	inner := &fakeScraper{}
+	const url = "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+
+	if _, err := NewCachingScraper(inner, dir, 0, "max-height=1080").Scrape(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCachingScraper(inner, dir, 0, "max-height=1080").Scrape(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.calls) != 1 {
+		t.Fatalf("same settings scraped %d times, want 1 (cache hit)", len(inner.calls))
+	}
+
+	if _, err := NewCachingScraper(inner, dir, 0, "max-height=720").Scrape(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCachingScraper(inner, dir, 0, "max-height=1080", "comments=100").Scrape(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.calls) != 3 {
+		t.Fatalf("scraped %d times, want 3: other settings must miss the cache", len(inner.calls))
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// shortLinkHosts are hosts whose links only redirect to the real page.
+var shortLinkHosts = map[string]bool{
+	"pin.it": true,
+}
+
+// expandShortLink resolves known short links via Options.LinkExpander and
+// canonicalizes the result. On failure the link is returned unchanged, since
+// yt-dlp can usually follow it itself.
+func (o *Orchestrator) expandShortLink(ctx context.Context, rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !shortLinkHosts[strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")] || o.opts.LinkExpander == nil {
+		return rawURL
+	}
+	expanded, err := o.opts.LinkExpander.Expand(ctx, rawURL)
+	if err != nil {
+		o.logger.Printf("WARNING: failed to expand %s, using it as is: %v", rawURL, err)
+		return rawURL
+	}
+	expanded = canonicalPinterestURL(expanded)
+	o.logger.Printf("Expanded %s to %s", rawURL, expanded)
+	return expanded
+}
+
+// canonicalPinterestURL reduces a pin URL like
+// https://www.pinterest.com/pin/123/sent/?invite_code=... to
+// https://www.pinterest.com/pin/123/. Other URLs are returned unchanged.
+func canonicalPinterestURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.Contains(strings.ToLower(u.Hostname()), "pinterest.") {
+		return rawURL
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(segments) < 2 || segments[0] != "pin" || segments[1] == "" {
+		return rawURL
+	}
+	return "https://www.pinterest.com/pin/" + segments[1] + "/"
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// fakeExpander expands links from a map, failing for others.
+type fakeExpander struct {
+	mu    sync.Mutex
+	links map[string]string
+	calls []string
+}
+
+func (f *fakeExpander) Expand(ctx context.Context, shortURL string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, shortURL)
+	if long, ok := f.links[shortURL]; ok {
+		return long, nil
+	}
+	return "", errFake
+}
+
+func TestExpandShortLink(t *testing.T) {
+	expander := &fakeExpander{links: map[string]string{
+		"https://pin.it/abc123":  "https://www.pinterest.co.uk/pin/987654321/sent/?invite_code=x&sender=y",
+		"https://pin.it/board42": "https://www.pinterest.com/user/board/",
+	}}
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, &fakeDownloader{}, nil, Options{LinkExpander: expander})
+
+	tests := []struct {
+		url, want string
+		expands   bool
+	}{
+		{"https://pin.it/abc123", "https://www.pinterest.com/pin/987654321/", true},
+		{"https://pin.it/board42", "https://www.pinterest.com/user/board/", true},
+		{"https://pin.it/gone", "https://pin.it/gone", true},
+		{"https://www.pinterest.com/pin/123/", "https://www.pinterest.com/pin/123/", false},
+		{"https://www.tiktok.com/@user/video/1", "https://www.tiktok.com/@user/video/1", false},
+	}
+	for _, tt := range tests {
+		expander.calls = nil
+		if got := o.expandShortLink(context.Background(), tt.url); got != tt.want {
+			t.Errorf("expandShortLink(%s) = %s, want %s", tt.url, got, tt.want)
+		}
+		if expanded := len(expander.calls) > 0; expanded != tt.expands {
+			t.Errorf("expandShortLink(%s) expanded %v, want %v", tt.url, expanded, tt.expands)
+		}
+	}
+
+	// Without an expander, yt-dlp follows the link itself
+	o, _ = newTestOrchestrator(t, &fakeScraper{}, &fakeDownloader{}, nil, Options{})
+	if got := o.expandShortLink(context.Background(), "https://pin.it/abc123"); got != "https://pin.it/abc123" {
+		t.Errorf("expandShortLink without an expander = %s", got)
+	}
+}
+
+func TestCanonicalPinterestURL(t *testing.T) {
+	tests := []struct{ url, want string }{
+		{"https://www.pinterest.com/pin/123/sent/?invite_code=x", "https://www.pinterest.com/pin/123/"},
+		{"https://pinterest.de/pin/123", "https://www.pinterest.com/pin/123/"},
+		{"https://www.pinterest.com/user/board/", "https://www.pinterest.com/user/board/"},
+		{"https://www.pinterest.com/pin/", "https://www.pinterest.com/pin/"},
+		{"https://www.tiktok.com/pin/1", "https://www.tiktok.com/pin/1"},
+	}
+	for _, tt := range tests {
+		if got := canonicalPinterestURL(tt.url); got != tt.want {
+			t.Errorf("canonicalPinterestURL(%s) = %s, want %s", tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// SkipList is a .scraperignore-style list of videos never to download.
+// Each line is a video ID (e.g. dQw4w9WgXcQ) or a video URL; a trailing
+// "*" matches by prefix instead (e.g. "youtube.com/shorts/*"). Blank lines
+// and "#" comments, whole-line or after a space, are ignored.
+type SkipList struct {
+	entries []skipEntry
+}
+
+type skipEntry struct {
+	line    string // As written, for the skip reason
+	pattern string // Trimmed of the "*", and of the scheme for URLs
+	prefix  bool
+	url     bool
+}
+
+// LoadSkipList reads a SkipList file.
+func LoadSkipList(path string) (*SkipList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open skip list: %w", err)
+	}
+	defer f.Close()
+	list, err := ParseSkipList(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return list, nil
+}
+
+// ParseSkipList parses a SkipList.
+func ParseSkipList(r io.Reader) (*SkipList, error) {
+	list := &SkipList{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry := skipEntry{line: line, pattern: line}
+		if strings.HasSuffix(line, "*") {
+			entry.prefix = true
+			entry.pattern = strings.TrimSuffix(line, "*")
+		}
+		if strings.Contains(entry.pattern, "*") {
+			return nil, fmt.Errorf("line %d: only a trailing * is supported", n)
+		}
+		if entry.pattern == "" {
+			return nil, fmt.Errorf("line %d: empty pattern", n)
+		}
+		if strings.Contains(entry.pattern, "/") {
+			entry.url = true
+			entry.pattern = skipListURL(entry.pattern)
+		}
+		list.entries = append(list.entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read skip list: %w", err)
+	}
+	return list, nil
+}
+
+// Match returns the entry matching the video at videoURL, whose ID is
+// taken from the URL unless given (e.g. by a channel listing), or "" if
+// none does.
+func (l *SkipList) Match(videoURL, id string) string {
+	if l == nil {
+		return ""
+	}
+	if id == "" {
+		id = skipListID(videoURL)
+	}
+	trimmed := skipListURL(videoURL)
+	for _, e := range l.entries {
+		switch {
+		case e.url && e.prefix && strings.HasPrefix(trimmed, e.pattern),
+			e.url && !e.prefix && canonicalURL(videoURL) == canonicalURL("https://"+e.pattern),
+			!e.url && e.prefix && id != "" && strings.HasPrefix(id, e.pattern),
+			!e.url && !e.prefix && id == e.pattern:
+			return e.line
+		}
+	}
+	return ""
+}
+
+// skipListURL drops a URL's scheme and its host's "www." or "m." (see
+// trimHostPrefix), and lower-cases the host, so that prefixes compare
+// regardless of them.
+func skipListURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		rawURL = rawURL[i+3:]
+	}
+	host, path, _ := strings.Cut(rawURL, "/")
+	return trimHostPrefix(strings.ToLower(host)) + "/" + path
+}
+
+// skipListID returns the ID of the video at videoURL: YouTube's video ID,
+// else the last segment of the path (e.g. a TikTok video's number).
+func skipListID(videoURL string) string {
+	if detectPlatform(videoURL) == "youtube" {
+		return ports.YouTubeVideoID(videoURL)
+	}
+	_, path, _ := strings.Cut(skipListURL(videoURL), "/")
+	path, _, _ = strings.Cut(path, "?")
+	path, _, _ = strings.Cut(path, "#")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	return segments[len(segments)-1]
+}
+
+// ignoreReason returns why Options.SkipList ignores the video, or "".
+func (o *Orchestrator) ignoreReason(videoURL, id string) string {
+	if entry := o.opts.SkipList.Match(videoURL, id); entry != "" {
+		return fmt.Sprintf("ignored (skip list entry %q)", entry)
+	}
+	return ""
+}
+
+// ignoredResult is the result of a video the skip list ignores, which
+// runs no job.
+func ignoredResult(videoURL, reason string, now time.Time) *domain.JobResult {
+	return &domain.JobResult{
+		Job:         domain.Job{URL: videoURL, Platform: detectPlatform(videoURL), CreatedAt: now},
+		Skipped:     true,
+		SkipReason:  reason,
+		CompletedAt: now,
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSkipListMatch(t *testing.T) {
+	list, err := ParseSkipList(strings.NewReader(strings.Join([]string{
+		"# promos",
+		"PROMO_*",
+		"dQw4w9WgXcQ # rickroll",
+		"https://m.tiktok.com/@user/video/7",
+		"www.youtube.com/shorts/*",
+		"m.pinterest.com/pin/9*",
+	}, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url, id string
+		want    string
+	}{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "", "dQw4w9WgXcQ"},
+		{"https://m.youtube.com/watch?v=dQw4w9WgXcQ", "", "dQw4w9WgXcQ"},
+		{"https://www.youtube.com/watch?v=other123456", "PROMO_1", "PROMO_*"},
+		{"https://www.tiktok.com/@user/video/7", "", "https://m.tiktok.com/@user/video/7"},
+		{"https://m.tiktok.com/@user/video/7", "", "https://m.tiktok.com/@user/video/7"},
+		{"https://www.tiktok.com/@user/video/8", "", ""},
+		{"https://m.youtube.com/shorts/abcdefghijk", "", "www.youtube.com/shorts/*"},
+		{"https://youtube.com/shorts/abcdefghijk", "", "www.youtube.com/shorts/*"},
+		{"https://www.pinterest.com/pin/91/", "", "m.pinterest.com/pin/9*"},
+		{"https://www.pinterest.com/pin/81/", "", ""},
+	}
+	for _, tt := range tests {
+		if got := list.Match(tt.url, tt.id); got != tt.want {
+			t.Errorf("Match(%q, %q) = %q, want %q", tt.url, tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestParseSkipListRejectsInnerStar(t *testing.T) {
+	if _, err := ParseSkipList(strings.NewReader("youtube.com/*/shorts\n")); err == nil {
+		t.Fatal("want an error for a * before the end")
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// stageLimit caps how many jobs are in one pipeline stage at once, so that
+// stages with different bottlenecks (scrape API quota, download bandwidth)
+// can be throttled independently of the worker count. A nil stageLimit
+// doesn't limit.
+type stageLimit chan struct{}
+
+func newStageLimit(n int) stageLimit {
+	if n <= 0 {
+		return nil
+	}
+	return make(stageLimit, n)
+}
+
+// acquire blocks until the stage has room or ctx is done.
+func (l stageLimit) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees the room taken by acquire.
+func (l stageLimit) release() {
+	if l != nil {
+		<-l
+	}
+}
+
+// scrape calls the scraper within the scrape stage limit, unless the job
+// already has a result from a batched scrape.
+func (o *Orchestrator) scrape(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+	if fresh, _ := ctx.Value(freshScrapeKey{}).(bool); !fresh {
+		if result := takePrescraped(ctx); result != nil {
+			return result, nil
+		}
+	}
+	if err := o.scrapeSlots.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer o.scrapeSlots.release()
+	ctx, cancel := phaseContext(ctx, PhaseScrape, o.opts.ScrapeTimeout)
+	defer cancel()
+	result, err := o.scraper.Scrape(ctx, url)
+	return result, attributeDeadline(ctx, err)
+}
+
+// addScrapeUsage adds what a scrape cost to the job's usage.
+func addScrapeUsage(result *domain.JobResult, scrapeResult *ports.ScrapeResult) {
+	if scrapeResult == nil {
+		return
+	}
+	result.ApifyComputeUnits += scrapeResult.ComputeUnits
+	result.ApifyCostUSD += scrapeResult.CostUSD
+}
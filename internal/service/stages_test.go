@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// A job re-scraping an expired URL must not hold its download slot, or a
+// job needing that slot could never finish.
+func TestReresolveReleasesDownloadSlot(t *testing.T) {
+	const (
+		jobA = "https://www.tiktok.com/@user/video/1"
+		jobB = "https://www.tiktok.com/@user/video/2"
+	)
+	aExpired := make(chan struct{})
+	bDownloading := make(chan struct{})
+	var once sync.Once
+
+	var mu sync.Mutex
+	scrapes := map[string]int{}
+	scraper := scrapeFunc(func(ctx context.Context, url string) (*ports.ScrapeResult, error) {
+		mu.Lock()
+		scrapes[url]++
+		n := scrapes[url]
+		mu.Unlock()
+		if url == jobB {
+			return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/b.mp4"}, nil
+		}
+		if n == 1 {
+			return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/a-expired.mp4"}, nil
+		}
+		// The re-scrape only finishes once job B got the download slot
+		select {
+		case <-bDownloading:
+		case <-time.After(5 * time.Second):
+			return nil, fmt.Errorf("job B never got the download slot")
+		}
+		return &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/a.mp4"}, nil
+	})
+	downloader := downloadFunc(func(ctx context.Context, url string) (io.ReadCloser, error) {
+		switch url {
+		case "https://cdn/a-expired.mp4":
+			once.Do(func() { close(aExpired) })
+			return nil, fmt.Errorf("%w: unexpected status code: 403", ports.ErrURLExpired)
+		case "https://cdn/b.mp4":
+			close(bDownloading)
+		}
+		return io.NopCloser(strings.NewReader("video")), nil
+	})
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{
+		MaxResolveRetries:      1,
+		MaxConcurrentDownloads: 1,
+	})
+
+	errA := make(chan error, 1)
+	go func() {
+		_, err := o.RunJob(context.Background(), jobA)
+		errA <- err
+	}()
+	<-aExpired
+	if _, err := o.RunJob(context.Background(), jobB); err != nil {
+		t.Errorf("job B: %v", err)
+	}
+	if err := <-errA; err != nil {
+		t.Errorf("job A: %v", err)
+	}
+}
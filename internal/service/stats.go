@@ -0,0 +1,55 @@
+package service
+
+import (
+	"io"
+	"time"
+)
+
+// countingReader counts the bytes read through it and remembers the first
+// read error, so a failed save can be attributed to the source or the sink.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if err != nil && err != io.EOF && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
+
+// progressReader calls onProgress with the running byte count every time
+// another `every` bytes have been read.
+type progressReader struct {
+	r          io.Reader
+	every      int64
+	onProgress func(n int64)
+
+	n    int64
+	next int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	if p.next == 0 {
+		p.next = p.every
+	}
+	if p.n >= p.next {
+		p.onProgress(p.n)
+		p.next = p.n + p.every
+	}
+	return n, err
+}
+
+// throughput returns the average transfer rate in bytes per second.
+func throughput(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) / d.Seconds()
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestThroughput(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		d     time.Duration
+		want  float64
+	}{
+		{124_000_000, 8 * time.Second, 15_500_000},
+		{1500, 500 * time.Millisecond, 3000},
+		{0, time.Second, 0},
+		{1000, 0, 0},
+		{1000, -time.Second, 0},
+	}
+	for _, tt := range tests {
+		if got := throughput(tt.bytes, tt.d); got != tt.want {
+			t.Errorf("throughput(%d, %v) = %v, want %v", tt.bytes, tt.d, got, tt.want)
+		}
+	}
+}
+
+// clockReader moves a fake clock on by step once the body has been read.
+type clockReader struct {
+	io.Reader
+	clock *time.Time
+	step  time.Duration
+}
+
+func (r *clockReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		*r.clock = r.clock.Add(r.step)
+		r.step = 0
+	}
+	return n, err
+}
+
+func (r *clockReader) Close() error { return nil }
+
+func TestRunJobDownloadStats(t *testing.T) {
+	clock := time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC)
+	body := strings.Repeat("x", 2_000_000)
+	downloader := downloadFunc(func(ctx context.Context, videoURL string) (io.ReadCloser, error) {
+		return &clockReader{Reader: strings.NewReader(body), clock: &clock, step: 4 * time.Second}, nil
+	})
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{Now: func() time.Time { return clock }})
+
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.DownloadBytes != int64(len(body)) {
+		t.Errorf("DownloadBytes = %d, want %d", result.DownloadBytes, len(body))
+	}
+	if result.DownloadDuration != 4*time.Second {
+		t.Errorf("DownloadDuration = %v, want 4s", result.DownloadDuration)
+	}
+	if result.AvgThroughputBytesPerSec != 500_000 {
+		t.Errorf("AvgThroughputBytesPerSec = %v, want 500000", result.AvgThroughputBytesPerSec)
+	}
+}
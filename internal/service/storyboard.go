@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// saveStoryboards downloads the video's storyboard sprite sheets. It never
+// fails the job: unavailable storyboards or failed images are logged and
+// skipped.
+func (o *Orchestrator) saveStoryboards(ctx context.Context, job domain.Job, artifacts *[]artifactRecord) {
+	lister, ok := o.resolver.(ports.StoryboardLister)
+	if !usesYtDlp(job.Platform) || !ok {
+		o.logger.Printf("[JOB %s] WARNING: storyboards not supported, skipping for %s", job.ID, job.Platform)
+		return
+	}
+
+	images, err := lister.GetStoryboards(ctx, job.URL)
+	if err != nil {
+		o.logger.Printf("[JOB %s] WARNING: failed to list storyboards: %v", job.ID, err)
+		return
+	}
+	if len(images) == 0 {
+		o.logger.Printf("[JOB %s] No storyboards available", job.ID)
+		return
+	}
+
+	saved := 0
+	for _, img := range images {
+		name := fmt.Sprintf("%s_%03d.jpg", img.FormatID, img.Index)
+		data, err := o.fetchFile(ports.WithRequestHeaders(ctx, img.Headers), img.URL)
+		if err == nil {
+			err = o.storage.SaveStoryboard(ctx, job.ID, name, data)
+		}
+		if err != nil {
+			o.logger.Printf("[JOB %s] WARNING: skipping storyboard %s: %v", job.ID, name, err)
+			continue
+		}
+		o.addArtifact(job.ID, artifacts, newArtifactRecord("storyboards/"+name, "storyboard", data))
+		saved++
+	}
+	o.logger.Printf("[JOB %s] Saved %d/%d storyboard images (%dx%d frames, %dx%d grid)",
+		job.ID, saved, len(images), images[0].Width, images[0].Height, images[0].Columns, images[0].Rows)
+}
+
+// fetchFile downloads a small file into memory.
+func (o *Orchestrator) fetchFile(ctx context.Context, url string) ([]byte, error) {
+	body, err := o.downloader.Download(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// storyboardResolver is a fakeResolver that also lists storyboards.
+type storyboardResolver struct {
+	fakeResolver
+	images []ports.StoryboardImage
+	err    error
+}
+
+func (r *storyboardResolver) GetStoryboards(ctx context.Context, videoPageURL string) ([]ports.StoryboardImage, error) {
+	return r.images, r.err
+}
+
+// Storyboard images are saved under storyboards/; missing ones are skipped
+// and never fail the job.
+func TestSaveStoryboards(t *testing.T) {
+	const youtube = "https://www.youtube.com/watch?v=dQw4w9WgXcQ"
+	images := []ports.StoryboardImage{
+		{FormatID: "sb0", Index: 0, URL: "https://i.ytimg.com/sb/0/M0.jpg"},
+		{FormatID: "sb0", Index: 1, URL: "https://i.ytimg.com/sb/0/gone.jpg"},
+		{FormatID: "sb0", Index: 2, URL: "https://i.ytimg.com/sb/0/M2.jpg"},
+	}
+	tests := []struct {
+		name   string
+		url    string
+		images []ports.StoryboardImage
+		err    error
+		want   map[string]string
+	}{
+		{"saved", youtube, images, nil, map[string]string{"sb0_000.jpg": "sprite 0", "sb0_002.jpg": "sprite 2"}},
+		{"none available", youtube, []ports.StoryboardImage{}, nil, nil},
+		{"listing fails", youtube, nil, errFake, nil},
+		{"unsupported platform", "https://www.tiktok.com/@user/video/1", images, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &storyboardResolver{fakeResolver: fakeResolver{url: "https://cdn/v.mp4"}, images: tt.images, err: tt.err}
+			downloader := &fakeDownloader{files: map[string]string{
+				"https://cdn/v.mp4":               "video",
+				"https://i.ytimg.com/sb/0/M0.jpg": "sprite 0",
+				"https://i.ytimg.com/sb/0/M2.jpg": "sprite 2",
+			}}
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+			o, _ := newTestOrchestrator(t, scraper, downloader, resolver, Options{Storyboards: true})
+
+			result, err := o.RunJob(context.Background(), tt.url)
+			if err != nil {
+				t.Fatalf("RunJob: %v", err)
+			}
+			dir := filepath.Join(o.storage.GetJobPath(result.Job.ID), "storyboards")
+			entries, _ := os.ReadDir(dir)
+			saved := map[string]string{}
+			for _, e := range entries {
+				data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				saved[e.Name()] = string(data)
+			}
+			if len(tt.want) == 0 {
+				tt.want = map[string]string{}
+			}
+			if !maps.Equal(saved, tt.want) {
+				t.Errorf("saved storyboards %v, want %v", saved, tt.want)
+			}
+		})
+	}
+}
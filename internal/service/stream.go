@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// StreamJob resolves the video for url and writes it to w instead of
+// storage, e.g. to pipe it into a player. Nothing is saved: metadata is only
+// scraped when the platform needs it for the video URL, and the download
+// can't be resumed. Expired URLs are re-resolved as in RunJob, as long as
+// nothing has been written yet.
+func (o *Orchestrator) StreamJob(ctx context.Context, url string, w io.Writer) (*domain.JobResult, error) {
+	url = o.expandShortLink(ctx, url)
+
+	job := domain.Job{
+		ID:         uuid.New().String(),
+		ExternalID: externalIDFrom(ctx),
+		URL:        url,
+		Platform:   detectPlatform(url),
+		CreatedAt:  o.now().UTC(),
+	}
+	result := &domain.JobResult{Job: job, Success: false}
+	defer o.logger.startJob(job.ID)()
+	o.logger.Printf("[JOB %s] Streaming video for URL: %s", job.ID, url)
+	defer o.logTimings(result)
+
+	var scrapeResult *ports.ScrapeResult
+	if !usesYtDlp(job.Platform) {
+		markStart(&result.Timings.ScrapeStartedAt, o.now())
+		var err error
+		scrapeResult, err = o.scrape(ctx, job.URL)
+		result.Timings.ScrapeEndedAt = o.now()
+		addScrapeUsage(result, scrapeResult)
+		if err != nil {
+			return result, o.fail(result, domain.StepScrape, err, fmt.Sprintf("failed to scrape metadata: %v", err))
+		}
+	}
+	reason, err := o.preflight(ctx, job, scrapeResult, false)
+	if err != nil {
+		return result, o.fail(result, domain.StepPreflight, err, err.Error())
+	}
+	if reason != "" {
+		o.skip(result, reason)
+		return result, nil
+	}
+
+	markStart(&result.Timings.ResolveStartedAt, o.now())
+	video, err := o.resolveVideoURL(ctx, job, result, scrapeResult)
+	result.Timings.ResolveEndedAt = o.now()
+	if err != nil {
+		return result, o.fail(result, domain.StepResolve, err, err.Error())
+	}
+
+	markStart(&result.Timings.DownloadStartedAt, o.now())
+	defer func() { result.Timings.DownloadEndedAt = o.now() }()
+	if err := o.downloadSlots.acquire(ctx); err != nil {
+		return result, o.fail(result, domain.StepDownload, err, err.Error())
+	}
+	holding := true
+	defer func() {
+		if holding {
+			o.downloadSlots.release()
+		}
+	}()
+	var body io.ReadCloser
+	if video.resolverFormat == nil {
+		resp, err := o.openDownload(ctx, video, 0, "")
+		for attempt := 1; needsFreshURL(err) && attempt <= o.opts.MaxResolveRetries; attempt++ {
+			o.logger.Printf("[JOB %s] Download URL expired, re-resolving (attempt %d/%d)...", job.ID, attempt, o.opts.MaxResolveRetries)
+			// As in downloadVideo, the re-scrape mustn't wait holding a download slot
+			o.downloadSlots.release()
+			holding = false
+			video, err = o.resolveVideoURL(ctx, job, result, nil)
+			if err != nil {
+				return result, o.fail(result, domain.StepResolve, err, err.Error())
+			}
+			if err := o.downloadSlots.acquire(ctx); err != nil {
+				return result, o.fail(result, domain.StepDownload, err, err.Error())
+			}
+			holding = true
+			if video.resolverFormat != nil {
+				break
+			}
+			resp, err = o.openDownload(ctx, video, 0, "")
+		}
+		if video.resolverFormat == nil {
+			if err != nil {
+				return result, o.fail(result, domain.StepDownload, err, fmt.Sprintf("failed to download video: %v", err))
+			}
+			body = resp.Body
+		}
+	}
+	if video.resolverFormat != nil {
+		// The resolver downloads into scratch space, streamed from there
+		dir, err := o.temp.JobDir(job.ID)
+		if err != nil {
+			return result, o.fail(result, domain.StepSave, err, err.Error())
+		}
+		defer func() {
+			if err := o.temp.Cleanup(job.ID); err != nil {
+				o.logger.Printf("[JOB %s] WARNING: %v", job.ID, err)
+			}
+		}()
+		f, err := o.fetchWithResolver(ctx, job, video, dir)
+		if err != nil {
+			return result, o.fail(result, domain.StepDownload, err, err.Error())
+		}
+		body = f
+	}
+	defer body.Close()
+
+	o.logger.Printf("[JOB %s] Streaming video...", job.ID)
+	start := o.now()
+	counter := &countingReader{r: body}
+	if _, err := io.Copy(w, counter); err != nil {
+		// A broken stream is a download failure; anything else is the writer going away
+		step := domain.StepSave
+		if counter.err != nil {
+			step = domain.StepDownload
+		}
+		return result, o.fail(result, step, err, fmt.Sprintf("failed to stream video: %v", err))
+	}
+
+	result.DownloadBytes = counter.n
+	result.DownloadDuration = o.now().Sub(start)
+	result.AvgThroughputBytesPerSec = throughput(result.DownloadBytes, result.DownloadDuration)
+	result.Success = true
+	result.CompletedAt = o.now().UTC()
+	o.logger.Printf("[JOB %s] Streamed %d bytes", job.ID, result.DownloadBytes)
+	return result, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestStreamJob(t *testing.T) {
+	const video = "streamed video bytes"
+	tests := []struct {
+		name       string
+		url        string
+		wantScrape bool
+	}{
+		{"tiktok scrapes for the URL", "https://www.tiktok.com/@user/video/1", true},
+		{"youtube skips the scrape", "https://www.youtube.com/watch?v=dQw4w9WgXcQ", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+			downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": video}}
+			o, root := newTestOrchestrator(t, scraper, downloader, &fakeResolver{url: "https://cdn/v.mp4"}, Options{})
+
+			var out bytes.Buffer
+			result, err := o.StreamJob(context.Background(), tt.url, &out)
+			if err != nil {
+				t.Fatalf("StreamJob: %v", err)
+			}
+			if out.String() != video {
+				t.Errorf("streamed %q, want %q", out.String(), video)
+			}
+			if !result.Success || result.DownloadBytes != int64(len(video)) {
+				t.Errorf("result = success %v, %d bytes; want success, %d bytes", result.Success, result.DownloadBytes, len(video))
+			}
+			if scraped := len(scraper.calls) > 0; scraped != tt.wantScrape {
+				t.Errorf("scraped %v, want a scrape: %v", scraper.calls, tt.wantScrape)
+			}
+			if entries, _ := os.ReadDir(root); len(entries) != 0 {
+				t.Errorf("StreamJob saved %v to storage, want nothing", entries)
+			}
+		})
+	}
+}
+
+// errWriter fails every write.
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) { return 0, errors.New("broken pipe") }
+
+func TestStreamJobWriterFailure(t *testing.T) {
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`), VideoURL: "https://cdn/v.mp4"}}
+	o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, nil, Options{})
+
+	result, err := o.StreamJob(context.Background(), "https://www.tiktok.com/@user/video/1", errWriter{})
+	var jobErr *domain.JobError
+	if !errors.As(err, &jobErr) || jobErr.Step != domain.StepSave {
+		t.Fatalf("err = %v, want a JobError at the save step", err)
+	}
+	if result.Success {
+		t.Error("result succeeded after the writer failed")
+	}
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// BatchCounts counts a batch's entries by outcome. Every entry is counted
+// once: Total is the sum of the others.
+type BatchCounts struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`  // Including entries never started because of a shutdown
+	Skipped   int `json:"skipped"` // Matched a skip rule
+	// Cached entries ran no job of their own: an earlier run completed
+	// them (see ResumeBatch), or they duplicate another entry of the batch
+	Cached int `json:"cached"`
+
+	Bytes int64 `json:"bytes"` // Downloaded by the batch's jobs
+}
+
+// BatchSummary aggregates the results of a batch.
+type BatchSummary struct {
+	Batch string `json:"batch,omitempty"` // The watched file, if any
+	BatchCounts
+
+	// TotalTime spans from the first job's start to the last one's end
+	TotalTime  time.Duration           `json:"total_time_ns"`
+	ByPlatform map[string]*BatchCounts `json:"by_platform"`
+	// ByError counts failures by category: the reason an unavailable video
+	// gave (e.g. "private"), else the failed step (e.g. "download"), or
+	// "not_started", "panic" and "other"
+	ByError map[string]int `json:"by_error"`
+}
+
+// SummarizeBatch aggregates a batch's results, as returned by RunBatch or
+// ResumeBatch.
+func SummarizeBatch(results []BatchResult) BatchSummary {
+	summary := BatchSummary{
+		ByPlatform: make(map[string]*BatchCounts),
+		ByError:    make(map[string]int),
+	}
+	seen := make(map[*domain.JobResult]bool)
+	var first, last time.Time
+	for _, r := range results {
+		platform := detectPlatform(r.URL)
+		if r.Result != nil && r.Result.Job.Platform != "" {
+			platform = r.Result.Job.Platform
+		}
+		counts := summary.ByPlatform[platform]
+		if counts == nil {
+			counts = &BatchCounts{}
+			summary.ByPlatform[platform] = counts
+		}
+
+		unique := r.Result != nil && !seen[r.Result]
+		var bytes int64
+		if unique {
+			seen[r.Result] = true
+			bytes = r.Result.DownloadBytes
+		}
+		outcome := entryOutcome(r, unique)
+		if outcome == outcomeFailed {
+			summary.ByError[errorCategory(r.Err)]++
+		}
+		summary.add(outcome, bytes)
+		counts.add(outcome, bytes)
+
+		if !unique {
+			continue
+		}
+		if start := r.Result.Job.CreatedAt; !start.IsZero() && (first.IsZero() || start.Before(first)) {
+			first = start
+		}
+		if end := jobEnd(r.Result); end.After(last) {
+			last = end
+		}
+	}
+	if !first.IsZero() && last.After(first) {
+		summary.TotalTime = last.Sub(first)
+	}
+	return summary
+}
+
+// Outcomes of a batch entry, see BatchCounts.
+const (
+	outcomeSucceeded = iota
+	outcomeFailed
+	outcomeSkipped
+	outcomeCached
+)
+
+// entryOutcome classifies a batch entry; unique is false for entries
+// sharing the job of an earlier one.
+func entryOutcome(r BatchResult, unique bool) int {
+	switch {
+	case r.Skipped || (r.Result != nil && !unique):
+		return outcomeCached
+	case r.Err != nil:
+		return outcomeFailed
+	case r.Result != nil && r.Result.Skipped:
+		return outcomeSkipped
+	}
+	return outcomeSucceeded
+}
+
+func (c *BatchCounts) add(outcome int, bytes int64) {
+	c.Total++
+	c.Bytes += bytes
+	switch outcome {
+	case outcomeSucceeded:
+		c.Succeeded++
+	case outcomeFailed:
+		c.Failed++
+	case outcomeSkipped:
+		c.Skipped++
+	case outcomeCached:
+		c.Cached++
+	}
+}
+
+// errorCategory names the kind of a batch entry's failure for ByError.
+func errorCategory(err error) string {
+	if errors.Is(err, ErrDraining) {
+		return "not_started"
+	}
+	if errors.Is(err, ErrJobPanicked) {
+		return "panic"
+	}
+	if reason, ok := ports.UnavailableReasonOf(err); ok {
+		return string(reason)
+	}
+	var jobErr *domain.JobError
+	if errors.As(err, &jobErr) {
+		return string(jobErr.Step)
+	}
+	return "other"
+}
+
+// jobEnd returns when a job last did something: its completion, or for a
+// failed job the end of its last step.
+func jobEnd(result *domain.JobResult) time.Time {
+	end := result.CompletedAt
+	for _, t := range []time.Time{result.Timings.ScrapeEndedAt, result.Timings.ResolveEndedAt, result.Timings.DownloadEndedAt} {
+		if t.After(end) {
+			end = t
+		}
+	}
+	return end
+}
+
+// String formats the summary's totals on one line, e.g.
+// "12 jobs: 9 succeeded, 1 failed, 1 skipped, 1 cached; 845.2 MB in 3m20s".
+func (s BatchSummary) String() string {
+	return fmt.Sprintf("%d jobs: %d succeeded, %d failed, %d skipped, %d cached; %.1f MB in %s",
+		s.Total, s.Succeeded, s.Failed, s.Skipped, s.Cached, float64(s.Bytes)/1e6, s.TotalTime.Round(time.Second))
+}
+
+// logSummary logs a batch's summary with its breakdowns, and writes it to
+// Options.Summary as a JSON line.
+func (o *Orchestrator) logSummary(batch string, results []BatchResult) {
+	if len(results) == 0 {
+		return
+	}
+	summary := SummarizeBatch(results)
+	summary.Batch = batch
+	prefix := "Batch summary"
+	if batch != "" {
+		prefix = batch + ": summary"
+	}
+	o.logger.Summaryf("%s: %s", prefix, summary)
+
+	platforms := make([]string, 0, len(summary.ByPlatform))
+	for platform, counts := range summary.ByPlatform {
+		platforms = append(platforms, fmt.Sprintf("%s %d/%d", platform, counts.Succeeded, counts.Total))
+	}
+	sort.Strings(platforms)
+	o.logger.Summaryf("%s: succeeded by platform: %s", prefix, strings.Join(platforms, ", "))
+	if len(summary.ByError) > 0 {
+		categories := make([]string, 0, len(summary.ByError))
+		for category, n := range summary.ByError {
+			categories = append(categories, fmt.Sprintf("%s %d", category, n))
+		}
+		sort.Strings(categories)
+		o.logger.Summaryf("%s: failures: %s", prefix, strings.Join(categories, ", "))
+	}
+
+	if o.opts.Summary == nil {
+		return
+	}
+	line, err := json.Marshal(summary)
+	if err != nil {
+		o.logger.Printf("WARNING: failed to encode batch summary: %v", err)
+		return
+	}
+	o.resultsMu.Lock()
+	defer o.resultsMu.Unlock()
+	if _, err := o.opts.Summary.Write(append(line, '\n')); err != nil {
+		o.logger.Printf("WARNING: failed to write batch summary: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+func TestErrorCategory(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"draining", fmt.Errorf("job not started: %w", ErrDraining), "not_started"},
+		{"panic", fmt.Errorf("%w: nil map", ErrJobPanicked), "panic"},
+		{"unavailable", &domain.JobError{Step: domain.StepScrape, Err: &ports.UnavailableError{Reason: ports.ReasonPrivate}}, "private"},
+		{"step", &domain.JobError{Step: domain.StepDownload, Err: errors.New("connection reset")}, "download"},
+		{"other", errors.New("boom"), "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorCategory(tt.err); got != tt.want {
+				t.Errorf("errorCategory(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntryOutcome(t *testing.T) {
+	tests := []struct {
+		name   string
+		entry  BatchResult
+		unique bool
+		want   int
+	}{
+		{"succeeded", BatchResult{Result: &domain.JobResult{}}, true, outcomeSucceeded},
+		{"failed", BatchResult{Result: &domain.JobResult{}, Err: errors.New("boom")}, true, outcomeFailed},
+		{"failed without a result", BatchResult{Err: ErrDraining}, false, outcomeFailed},
+		{"skip rule", BatchResult{Result: &domain.JobResult{Skipped: true}}, true, outcomeSkipped},
+		{"completed earlier", BatchResult{Skipped: true}, false, outcomeCached},
+		{"duplicate entry", BatchResult{Result: &domain.JobResult{}}, false, outcomeCached},
+		{"failed duplicate entry", BatchResult{Result: &domain.JobResult{}, Err: errors.New("boom")}, false, outcomeCached},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := entryOutcome(tt.entry, tt.unique); got != tt.want {
+				t.Errorf("entryOutcome = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeBatch(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	shared := &domain.JobResult{
+		Job:           domain.Job{Platform: "youtube", CreatedAt: start},
+		DownloadBytes: 1000,
+		CompletedAt:   start.Add(time.Minute),
+	}
+	failed := &domain.JobResult{
+		Job:     domain.Job{Platform: "tiktok", CreatedAt: start.Add(10 * time.Second)},
+		Timings: domain.Timings{DownloadEndedAt: start.Add(3 * time.Minute)},
+	}
+	results := []BatchResult{
+		{URL: "https://youtu.be/a", Result: shared},
+		{URL: "https://www.youtube.com/watch?v=a", Result: shared},
+		{URL: "https://www.tiktok.com/@u/video/1", Result: failed, Err: &domain.JobError{Step: domain.StepDownload, Err: errors.New("reset")}},
+		{URL: "https://pin.it/x", Result: &domain.JobResult{Skipped: true, DownloadBytes: 5}},
+		{URL: "https://www.tiktok.com/@u/video/2", Skipped: true},
+		{URL: "https://example.com/v", Err: ErrDraining},
+	}
+
+	got := SummarizeBatch(results)
+	want := BatchSummary{
+		BatchCounts: BatchCounts{Total: 6, Succeeded: 1, Failed: 2, Skipped: 1, Cached: 2, Bytes: 1005},
+		TotalTime:   3 * time.Minute,
+		ByPlatform: map[string]*BatchCounts{
+			"youtube":   {Total: 2, Succeeded: 1, Cached: 1, Bytes: 1000},
+			"tiktok":    {Total: 2, Failed: 1, Cached: 1},
+			"pinterest": {Total: 1, Skipped: 1, Bytes: 5},
+			"unknown":   {Total: 1, Failed: 1},
+		},
+		ByError: map[string]int{"download": 1, "not_started": 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SummarizeBatch =\n%+v\nwant\n%+v", got, want)
+	}
+	if got, want := got.String(), "6 jobs: 1 succeeded, 2 failed, 1 skipped, 2 cached; 0.0 MB in 3m0s"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+}
+
+func TestSummarizeEmptyBatch(t *testing.T) {
+	got := SummarizeBatch(nil)
+	if got.Total != 0 || got.TotalTime != 0 || len(got.ByPlatform) != 0 || len(got.ByError) != 0 {
+		t.Errorf("SummarizeBatch(nil) = %+v, want an empty summary", got)
+	}
+}
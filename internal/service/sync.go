@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// SyncChannel lists the channel's videos and runs jobs only for those not
+// downloaded by an earlier sync, recorded in Options.ChannelState by channel
+// ID. Only successful jobs are recorded, so failures are retried next sync.
+// The channel state is saved once the batch is done; until then each job
+// that succeeds is recorded in Options.Checkpoint, so a sync that was killed
+// doesn't run them again.
+// Videos matching Options.SkipList run no job; they're returned, after the
+// others, as skipped (and counted so in the logged summary) and left
+// unrecorded, so removing them from the list downloads them next sync.
+func (o *Orchestrator) SyncChannel(ctx context.Context, channelURL string, workers, maxAttempts int) ([]BatchResult, error) {
+	if o.opts.ChannelState == nil {
+		return nil, fmt.Errorf("channel sync needs a channel state store")
+	}
+	lister, ok := o.resolver.(ports.ChannelLister)
+	if !ok {
+		return nil, fmt.Errorf("url resolver cannot list channels")
+	}
+
+	listing, err := lister.ListChannel(ctx, channelURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list channel: %w", err)
+	}
+	seen, err := o.opts.ChannelState.SeenVideos(ctx, listing.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids, urls []string
+	var ignored []BatchResult
+	for _, v := range listing.Videos {
+		if seen[v.ID] {
+			continue
+		}
+		if reason := o.ignoreReason(v.URL, v.ID); reason != "" {
+			ignored = append(ignored, BatchResult{URL: v.URL, Result: ignoredResult(v.URL, reason, o.now().UTC())})
+			continue
+		}
+		ids = append(ids, v.ID)
+		urls = append(urls, v.URL)
+	}
+	o.logger.Printf("Channel %s (%s): %d videos, %d new", listing.Title, listing.ChannelID, len(listing.Videos), len(urls))
+	if len(ignored) > 0 {
+		o.logger.Printf("Channel %s (%s): %d new videos ignored by the skip list", listing.Title, listing.ChannelID, len(ignored))
+	}
+	if len(urls) == 0 {
+		o.logSummary("", ignored)
+		return ignored, nil
+	}
+
+	items := make([]BatchItem, len(urls))
+	for i, u := range urls {
+		items[i] = BatchItem{URL: u}
+	}
+	batchID := "sync " + listing.ChannelID
+	results := o.ResumeBatch(ctx, batchID, items, workers, maxAttempts)
+	var done []string
+	for i, r := range results {
+		if r.Err == nil {
+			done = append(done, ids[i])
+		}
+	}
+	// Summarized with the ignored videos, as RunJobs would leave them out
+	results = append(results, ignored...)
+	o.logSummary("", results)
+	if err := o.opts.ChannelState.MarkSeen(context.WithoutCancel(ctx), *listing, channelURL, done); err != nil {
+		return results, fmt.Errorf("failed to save channel state: %w", err)
+	}
+	// The channel state now has every job the checkpoint recorded
+	if err := o.ClearBatch(context.WithoutCancel(ctx), batchID); err != nil {
+		o.logger.Printf("WARNING: failed to clear checkpoint for %s: %v", batchID, err)
+	}
+	return results, nil
+}
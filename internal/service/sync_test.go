@@ -0,0 +1,148 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"scrapeanddown/internal/adapters/channelstate"
+	"scrapeanddown/internal/core/ports"
+)
+
+// fakeChannelResolver is a fakeResolver that also lists a channel.
+type fakeChannelResolver struct {
+	fakeResolver
+	listing ports.ChannelListing
+}
+
+func (f *fakeChannelResolver) ListChannel(ctx context.Context, channelURL string) (*ports.ChannelListing, error) {
+	listing := f.listing
+	return &listing, nil
+}
+
+// fakeChannelState remembers seen videos in memory.
+type fakeChannelState struct {
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func (f *fakeChannelState) SeenVideos(ctx context.Context, channelID string) (map[string]bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	seen := make(map[string]bool, len(f.seen))
+	for id := range f.seen {
+		seen[id] = true
+	}
+	return seen, nil
+}
+
+func (f *fakeChannelState) MarkSeen(ctx context.Context, listing ports.ChannelListing, channelURL string, videoIDs []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.seen == nil {
+		f.seen = map[string]bool{}
+	}
+	for _, id := range videoIDs {
+		f.seen[id] = true
+	}
+	return nil
+}
+
+// The sync's summary covers the videos the skip list ignored, and is
+// logged once.
+func TestSyncChannelSummaryCountsIgnored(t *testing.T) {
+	resolver := &fakeChannelResolver{
+		fakeResolver: fakeResolver{url: "https://cdn/v.mp4"},
+		listing: ports.ChannelListing{ChannelID: "UC1", Title: "Channel", Videos: []ports.ChannelVideo{
+			{ID: "aaaaaaaaaaa", URL: "https://www.youtube.com/watch?v=aaaaaaaaaaa"},
+			{ID: "bbbbbbbbbbb", URL: "https://www.youtube.com/shorts/bbbbbbbbbbb"},
+			{ID: "ccccccccccc", URL: "https://www.youtube.com/watch?v=ccccccccccc"},
+		}},
+	}
+	skip, err := ParseSkipList(strings.NewReader("m.youtube.com/shorts/*\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &fakeChannelState{}
+	var summary bytes.Buffer
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, resolver,
+		Options{ChannelState: state, SkipList: skip, Summary: &summary})
+
+	results, err := o.SyncChannel(context.Background(), "https://www.youtube.com/@channel", 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	if !results[2].Result.Skipped || results[2].URL != resolver.listing.Videos[1].URL {
+		t.Errorf("last result = %+v, want the ignored short", results[2])
+	}
+	if state.seen["bbbbbbbbbbb"] || !state.seen["aaaaaaaaaaa"] || !state.seen["ccccccccccc"] {
+		t.Errorf("seen = %v, want the two downloaded videos", state.seen)
+	}
+
+	lines := strings.Split(strings.TrimSpace(summary.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("summary lines = %q, want one", lines)
+	}
+	var got BatchSummary
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Total != 3 || got.Succeeded != 2 || got.Skipped != 1 {
+		t.Errorf("summary = %d total, %d succeeded, %d skipped; want 3, 2, 1", got.Total, got.Succeeded, got.Skipped)
+	}
+}
+
+// A second sync only runs jobs for the videos added since the first, also
+// after the channel was renamed, since state is kept by channel ID.
+func TestSyncChannelOnlyNewVideos(t *testing.T) {
+	video := func(id string) ports.ChannelVideo {
+		return ports.ChannelVideo{ID: id, URL: "https://www.youtube.com/watch?v=" + id}
+	}
+	resolver := &fakeChannelResolver{
+		fakeResolver: fakeResolver{url: "https://cdn/v.mp4"},
+		listing:      ports.ChannelListing{ChannelID: "UC1", Title: "Channel", Videos: []ports.ChannelVideo{video("aaaaaaaaaaa"), video("bbbbbbbbbbb")}},
+	}
+	state := channelstate.NewJSONState(filepath.Join(t.TempDir(), "channel_state.json"))
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}, resolver,
+		Options{ChannelState: state})
+
+	results, err := o.SyncChannel(context.Background(), "https://www.youtube.com/@channel", 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || len(resolver.calls) != 2 {
+		t.Fatalf("first sync ran %d jobs (%d results), want 2", len(resolver.calls), len(results))
+	}
+
+	resolver.calls = nil
+	resolver.listing.Title = "Renamed Channel"
+	resolver.listing.Videos = []ports.ChannelVideo{video("ccccccccccc"), video("aaaaaaaaaaa"), video("bbbbbbbbbbb"), video("ddddddddddd")}
+	results, err = o.SyncChannel(context.Background(), "https://www.youtube.com/@renamed", 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.URL)
+	}
+	slices.Sort(got)
+	slices.Sort(resolver.calls)
+	want := []string{video("ccccccccccc").URL, video("ddddddddddd").URL}
+	if !slices.Equal(got, want) || !slices.Equal(resolver.calls, want) {
+		t.Errorf("second sync ran %v (results for %v), want only the new videos %v", resolver.calls, got, want)
+	}
+
+	// Nothing new: no jobs at all
+	resolver.calls = nil
+	if results, err := o.SyncChannel(context.Background(), "https://www.youtube.com/@renamed", 2, 1); err != nil || len(results) != 0 || len(resolver.calls) != 0 {
+		t.Errorf("third sync = %d results, %v, %d jobs; want nothing to do", len(results), err, len(resolver.calls))
+	}
+}
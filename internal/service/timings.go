@@ -0,0 +1,39 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+)
+
+// markStart records now as a step's start unless it already has one, so a
+// step repeated per rendition spans from its first run.
+func markStart(start *time.Time, now time.Time) {
+	if start.IsZero() {
+		*start = now
+	}
+}
+
+// logTimings logs how long each step that ran took.
+func (o *Orchestrator) logTimings(result *domain.JobResult) {
+	t := result.Timings
+	var parts []string
+	for _, step := range []struct {
+		name       string
+		start, end time.Time
+	}{
+		{"scrape", t.ScrapeStartedAt, t.ScrapeEndedAt},
+		{"resolve", t.ResolveStartedAt, t.ResolveEndedAt},
+		{"download", t.DownloadStartedAt, t.DownloadEndedAt},
+	} {
+		if step.start.IsZero() || step.end.IsZero() {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", step.name, step.end.Sub(step.start).Round(time.Millisecond)))
+	}
+	if len(parts) > 0 {
+		o.logger.Verbosef("[JOB %s] Step timings: %s", result.Job.ID, strings.Join(parts, ", "))
+	}
+}
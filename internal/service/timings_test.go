@@ -0,0 +1,100 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// A job records when each step started and ended, in the order they ran,
+// and logs their durations at verbose level.
+func TestRunJobTimings(t *testing.T) {
+	var mu sync.Mutex
+	clock := time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC)
+	now := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{}]`)}}
+	downloader := &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "video"}}
+	o, _ := newTestOrchestrator(t, scraper, downloader, &fakeResolver{url: "https://cdn/v.mp4"}, Options{Now: now, LogLevel: LogVerbose})
+	var buf bytes.Buffer
+	o.logger = newLeveledLogger(log.New(&buf, "", 0), o.opts, now)
+
+	result, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tm := result.Timings
+	steps := []struct {
+		name string
+		at   time.Time
+	}{
+		{"ScrapeStartedAt", tm.ScrapeStartedAt},
+		{"ScrapeEndedAt", tm.ScrapeEndedAt},
+		{"ResolveStartedAt", tm.ResolveStartedAt},
+		{"ResolveEndedAt", tm.ResolveEndedAt},
+		{"DownloadStartedAt", tm.DownloadStartedAt},
+		{"DownloadEndedAt", tm.DownloadEndedAt},
+	}
+	for i, step := range steps {
+		if step.at.IsZero() {
+			t.Errorf("%s not set", step.name)
+			continue
+		}
+		if i > 0 && step.at.Before(steps[i-1].at) {
+			t.Errorf("%s %v is before %s %v", step.name, step.at, steps[i-1].name, steps[i-1].at)
+		}
+	}
+	for _, span := range [][2]time.Time{
+		{tm.ScrapeStartedAt, tm.ScrapeEndedAt},
+		{tm.ResolveStartedAt, tm.ResolveEndedAt},
+		{tm.DownloadStartedAt, tm.DownloadEndedAt},
+	} {
+		if !span[1].After(span[0]) {
+			t.Errorf("step ended at %v, not after it started at %v", span[1], span[0])
+		}
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "Step timings: scrape ") || !strings.Contains(out, ", resolve ") || !strings.Contains(out, ", download ") {
+		t.Errorf("log lacks the step timings:\n%s", out)
+	}
+}
+
+func TestLogTimingsSkipsStepsThatDidNotRun(t *testing.T) {
+	o, _ := newTestOrchestrator(t, &fakeScraper{}, &fakeDownloader{}, nil, Options{LogLevel: LogVerbose})
+	start := time.Date(2024, 6, 12, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		timings domain.Timings
+		want    string
+	}{
+		{"none", domain.Timings{}, ""},
+		{"scrape only", domain.Timings{ScrapeStartedAt: start, ScrapeEndedAt: start.Add(1500 * time.Millisecond)},
+			"[JOB job1] Step timings: scrape 1.5s\n"},
+		{"unfinished resolve", domain.Timings{
+			ScrapeStartedAt: start, ScrapeEndedAt: start.Add(time.Second),
+			ResolveStartedAt:  start.Add(time.Second),
+			DownloadStartedAt: start.Add(2 * time.Second), DownloadEndedAt: start.Add(5 * time.Second),
+		}, "[JOB job1] Step timings: scrape 1s, download 3s\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		o.logger = newLeveledLogger(log.New(&buf, "", 0), o.opts, time.Now)
+		o.logTimings(&domain.JobResult{Job: domain.Job{ID: "job1"}, Timings: tt.timings})
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%s: logged %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"scrapeanddown/internal/adapters/localstorage"
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// transcriptFile is the name the plain-text transcript is saved as.
+const transcriptFile = "transcript.txt"
+
+var (
+	vttBlockPattern = regexp.MustCompile(`\n[ \t]*\n`)
+	vttTagPattern   = regexp.MustCompile(`<[^>]*>`)
+	spacePattern    = regexp.MustCompile(`[\s\x{a0}]+`)
+)
+
+// saveTranscript downloads the video's best subtitle track (see
+// ports.SubtitleLister), saves it as subtitles.<lang>.vtt, and saves its
+// text, without timings or repeated caption lines, as transcript.txt. It
+// never fails the job: missing subtitles and failures are logged and
+// skipped.
+func (o *Orchestrator) saveTranscript(ctx context.Context, job domain.Job, artifacts *[]artifactRecord) {
+	lister, ok := o.resolver.(ports.SubtitleLister)
+	if !ok {
+		o.logger.Printf("[JOB %s] WARNING: subtitles not supported, skipping transcript for %s", job.ID, job.Platform)
+		return
+	}
+	tracks, err := lister.GetSubtitles(ctx, job.URL)
+	if err != nil {
+		o.logger.Printf("[JOB %s] WARNING: failed to list subtitles: %v", job.ID, err)
+		return
+	}
+	if len(tracks) == 0 {
+		o.logger.Printf("[JOB %s] No subtitles available, skipping %s", job.ID, transcriptFile)
+		return
+	}
+
+	track := tracks[0]
+	// A download like the video's, so it waits for a slot too
+	if err := o.downloadSlots.acquire(ctx); err != nil {
+		o.logger.Printf("[JOB %s] WARNING: failed to download %s subtitles: %v", job.ID, track.Language, err)
+		return
+	}
+	data, err := o.fetchFile(ctx, track.URL)
+	o.downloadSlots.release()
+	if err != nil {
+		o.logger.Printf("[JOB %s] WARNING: failed to download %s subtitles: %v", job.ID, track.Language, err)
+		return
+	}
+	// The language comes from the platform, so it mustn't shape the path
+	language := localstorage.SanitizeFilename(track.Language)
+	if language == "" {
+		language = "und"
+	}
+	name := fmt.Sprintf("subtitles.%s.vtt", language)
+	if err := o.storage.SaveVideo(ctx, job.ID, bytes.NewReader(data), name); err != nil {
+		o.logger.Printf("[JOB %s] WARNING: failed to save %s: %v", job.ID, name, err)
+	} else {
+		o.addArtifact(job.ID, artifacts, newArtifactRecord(name, "subtitles", data))
+	}
+
+	lines := dedupeCaptions(parseVTT(data))
+	if len(lines) == 0 {
+		o.logger.Printf("[JOB %s] WARNING: %s has no text, skipping %s", job.ID, name, transcriptFile)
+		return
+	}
+	text := []byte(strings.Join(lines, "\n") + "\n")
+	if err := o.storage.SaveVideo(ctx, job.ID, bytes.NewReader(text), transcriptFile); err != nil {
+		o.logger.Printf("[JOB %s] WARNING: failed to save %s: %v", job.ID, transcriptFile, err)
+		return
+	}
+	o.addArtifact(job.ID, artifacts, newArtifactRecord(transcriptFile, "transcript", text))
+
+	source := "subtitles"
+	if track.Automatic {
+		source = "automatic captions"
+	}
+	o.logger.Printf("[JOB %s] Saved %s (%d lines from %s %s)", job.ID, transcriptFile, len(lines), track.Language, source)
+}
+
+// parseVTT returns the text lines of a WebVTT file's cues, in order, with
+// cue identifiers, timings and markup (<c>, <i>, <00:00:01.200>) stripped
+// and entities decoded. Header, NOTE, STYLE and REGION blocks are skipped.
+func parseVTT(data []byte) []string {
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+
+	var lines []string
+	for _, block := range vttBlockPattern.Split(text, -1) {
+		blockLines := strings.Split(strings.Trim(block, "\n"), "\n")
+		// The payload follows the timing line; blocks without one aren't cues
+		timing := -1
+		for i, line := range blockLines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			continue
+		}
+		for _, line := range blockLines[timing+1:] {
+			line = html.UnescapeString(vttTagPattern.ReplaceAllString(line, ""))
+			line = strings.TrimSpace(spacePattern.ReplaceAllString(line, " "))
+			if line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// dedupeCaptions drops the repeats of rolling captions. YouTube's automatic
+// captions show each line twice, first as it is spoken and then above the
+// next one, and add 10ms cues repeating what is on screen; other sources
+// grow a line word by word. A line matching one of the last two kept is
+// dropped, and a line extending the last kept one replaces it.
+func dedupeCaptions(lines []string) []string {
+	var kept []string
+	for _, line := range lines {
+		n := len(kept)
+		switch {
+		case n > 0 && kept[n-1] == line, n > 1 && kept[n-2] == line:
+			continue
+		case n > 0 && strings.HasPrefix(line, kept[n-1]+" "):
+			kept[n-1] = line
+		default:
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"scrapeanddown/internal/core/ports"
+)
+
+// fakeSubtitleResolver is a fakeResolver that also lists subtitle tracks.
+type fakeSubtitleResolver struct {
+	fakeResolver
+	tracks []ports.SubtitleTrack
+}
+
+func (f *fakeSubtitleResolver) GetSubtitles(ctx context.Context, videoPageURL string) ([]ports.SubtitleTrack, error) {
+	return f.tracks, nil
+}
+
+func TestTranscriptLanguageCantEscapeJobDir(t *testing.T) {
+	resolver := &fakeSubtitleResolver{
+		fakeResolver: fakeResolver{url: "https://cdn/v.mp4"},
+		tracks:       []ports.SubtitleTrack{{Language: "../../evil", URL: "https://cdn/subs.vtt"}},
+	}
+	downloader := &fakeDownloader{files: map[string]string{
+		"https://cdn/v.mp4":    "video",
+		"https://cdn/subs.vtt": "WEBVTT\n\n00:00:00.000 --> 00:00:01.000\nHello\n",
+	}}
+	o, root := newTestOrchestrator(t, &fakeScraper{}, downloader, resolver, Options{Transcript: true, SkipMetadata: true})
+
+	result, err := o.RunJob(context.Background(), "https://www.youtube.com/watch?v=dQw4w9WgXcQ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readJobFile(t, o, result.Job.ID, "subtitles._._.._evil.vtt"); got == "" {
+		t.Error("subtitles saved empty")
+	}
+	if got := readJobFile(t, o, result.Job.ID, transcriptFile); got != "Hello\n" {
+		t.Errorf("transcript = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(root, "evil.vtt")); err == nil {
+		t.Error("subtitles written outside the job directory")
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"scrapeanddown/internal/core/domain"
+	"scrapeanddown/internal/core/ports"
+)
+
+// VideoFile is an http.Handler serving a job's saved video from storage.
+// Mount it in server mode with a pattern naming the job ID, e.g.
+// mux.Handle("GET /jobs/{id}/video", service.NewVideoFile(storage)).
+//
+// It serves the job's main video, whatever its container or templated name,
+// unless the "file" query parameter names another of its videos (e.g.
+// video_720p.mp4). The job's videos are the "video" artifacts of its
+// manifest, or without one, files named like a video; other artifacts
+// aren't served. Range requests are honored when the storage returns a
+// seekable reader; a missing job or file is a 404, and a job ID or file
+// name that isn't inside the job's directory (e.g. "..") a 400.
+type VideoFile struct {
+	storage ports.Storage
+}
+
+// NewVideoFile creates a VideoFile serving from storage.
+func NewVideoFile(storage ports.Storage) *VideoFile {
+	return &VideoFile{storage: storage}
+}
+
+func (v *VideoFile) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	filename := r.URL.Query().Get("file")
+	// Neither may step out of the job's directory
+	if !isJobIDSegment(jobID) || (filename != "" && !filepath.IsLocal(filename)) {
+		http.Error(w, "invalid job id or file", http.StatusBadRequest)
+		return
+	}
+	// Only an existing job's files are served
+	exists, err := v.storage.Exists(r.Context(), jobID, "")
+	if err != nil {
+		http.Error(w, "failed to look up job", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	filename, err = v.videoFile(r.Context(), jobID, filename)
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to read job manifest", http.StatusInternalServerError)
+		return
+	}
+
+	body, size, err := v.storage.OpenVideo(r.Context(), jobID, filename)
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to open video", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		var modTime time.Time
+		if info, err := v.storage.StatArtifact(r.Context(), jobID, filename); err == nil {
+			modTime = info.ModTime
+		}
+		w.Header().Set("Content-Type", contentTypeFor(filename))
+		http.ServeContent(w, r, filename, modTime, seeker)
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeFor(filename))
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		io.Copy(w, body)
+	}
+}
+
+// videoFile returns the job's video to serve for the requested file name,
+// the main video if it is "". A name that isn't one of the job's videos
+// is os.ErrNotExist.
+func (v *VideoFile) videoFile(ctx context.Context, jobID, requested string) (string, error) {
+	videos, err := v.manifestVideos(ctx, jobID)
+	if errors.Is(err, os.ErrNotExist) {
+		// Without a manifest, go by the name
+		if requested != "" {
+			if !isVideoFileName(requested) {
+				return "", os.ErrNotExist
+			}
+			return requested, nil
+		}
+		for _, ext := range videoFileExts {
+			name := withContainerExt(defaultVideoFile, ext, "")
+			if exists, _ := v.storage.Exists(ctx, jobID, name); exists {
+				return name, nil
+			}
+		}
+		return "", os.ErrNotExist
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if requested != "" {
+		if !slices.Contains(videos, requested) {
+			return "", os.ErrNotExist
+		}
+		return requested, nil
+	}
+	for _, name := range videos {
+		if isMainVideoFile(name) {
+			return name, nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// manifestVideos lists the "video" artifacts of the job's manifest.
+func (v *VideoFile) manifestVideos(ctx context.Context, jobID string) ([]string, error) {
+	body, _, err := v.storage.OpenVideo(ctx, jobID, "manifest.json")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var manifest domain.Manifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest.json: %w", err)
+	}
+	var videos []string
+	for _, entry := range manifest.Artifacts {
+		if entry.Kind == "video" {
+			videos = append(videos, entry.Name)
+		}
+	}
+	return videos, nil
+}
+
+// isJobIDSegment reports whether jobID names a directory in the jobs
+// directory: a single path segment other than "." and "..".
+func isJobIDSegment(jobID string) bool {
+	return jobID != "" && jobID != "." && jobID != ".." && filepath.Base(jobID) == jobID &&
+		!strings.ContainsAny(jobID, `/\`)
+}
+
+// isVideoFileName reports whether filename has a video or audio container's
+// extension.
+func isVideoFileName(filename string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	return slices.Contains(videoFileExts, ext) || ext == "m4a"
+}
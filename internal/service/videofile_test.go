@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"scrapeanddown/internal/adapters/localstorage"
+	"scrapeanddown/internal/core/ports"
+)
+
+func newVideoServer(t *testing.T, storage ports.Storage) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("GET /jobs/{id}/video", NewVideoFile(storage))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func getVideo(t *testing.T, server *httptest.Server, path string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func saveFile(t *testing.T, storage ports.Storage, jobID, name, data string) {
+	t.Helper()
+	if err := storage.SaveVideo(context.Background(), jobID, strings.NewReader(data), name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVideoFileWithoutManifest(t *testing.T) {
+	storage := localstorage.NewLocalStorage(t.TempDir())
+	if err := storage.InitJob(context.Background(), "job1"); err != nil {
+		t.Fatal(err)
+	}
+	saveFile(t, storage, "job1", "video.webm", "webm video")
+	saveFile(t, storage, "job1", "metadata_raw.json", "{}")
+	server := newVideoServer(t, storage)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/jobs/job1/video", http.StatusOK, "webm video"},
+		{"/jobs/job1/video?file=video.webm", http.StatusOK, "webm video"},
+		{"/jobs/job1/video?file=metadata_raw.json", http.StatusNotFound, ""},
+		{"/jobs/job1/video?file=../job2/video.mp4", http.StatusBadRequest, ""},
+		{"/jobs/missing/video", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		status, body := getVideo(t, server, tt.path)
+		if status != tt.status || (tt.body != "" && body != tt.body) {
+			t.Errorf("GET %s = %d %q, want %d %q", tt.path, status, body, tt.status, tt.body)
+		}
+	}
+}
+
+func TestVideoFileFromManifest(t *testing.T) {
+	tmpl, err := ParseOutputTemplate("%(title)s", localstorage.SanitizeFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scraper := &fakeScraper{result: &ports.ScrapeResult{RawMetadata: []byte(`[{"title":"My Clip"}]`), VideoURL: "https://cdn/v.mp4"}}
+	o, _ := newTestOrchestrator(t, scraper, &fakeDownloader{files: map[string]string{"https://cdn/v.mp4": "templated video"}}, nil,
+		Options{WriteManifest: true, OutputTemplate: tmpl})
+	result, err := o.RunJob(context.Background(), "https://www.tiktok.com/@user/video/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newVideoServer(t, o.storage)
+
+	id := result.Job.ID
+	if status, body := getVideo(t, server, "/jobs/"+id+"/video"); status != http.StatusOK || body != "templated video" {
+		t.Errorf("main video = %d %q, want the templated video", status, body)
+	}
+	// Saved, but not a video
+	if status, _ := getVideo(t, server, "/jobs/"+id+"/video?file=input.json"); status != http.StatusNotFound {
+		t.Errorf("input.json served with %d, want 404", status)
+	}
+}
+
+func TestVideoFileRejectsTraversal(t *testing.T) {
+	root := t.TempDir()
+	storage := localstorage.NewLocalStorage(root)
+	for _, job := range []string{"job1", "job2"} {
+		if err := storage.InitJob(context.Background(), job); err != nil {
+			t.Fatal(err)
+		}
+		saveFile(t, storage, job, "video.mp4", job+" video")
+	}
+	// Outside any job: a content-addressed object and a file in the data dir
+	for _, name := range []string{filepath.Join("objects", "ab", "hash", "video.mp4"), "video.mp4"} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("private"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := NewVideoFile(storage)
+
+	tests := []struct {
+		id, file string
+		status   int
+	}{
+		{"..", "", http.StatusBadRequest},
+		{"..", "objects/ab/hash/video.mp4", http.StatusBadRequest},
+		{"..", "video.mp4", http.StatusBadRequest},
+		{".", "", http.StatusBadRequest},
+		{".", "job2/video.mp4", http.StatusBadRequest},
+		{"job1/../job2", "", http.StatusBadRequest},
+		{`..\job2`, "", http.StatusBadRequest},
+		{"", "", http.StatusBadRequest},
+		{"job1", "../job2/video.mp4", http.StatusBadRequest},
+		{"job1", "../../video.mp4", http.StatusBadRequest},
+		{"job1", "sub/../../job2/video.mp4", http.StatusBadRequest},
+		{"job1", "/etc/passwd", http.StatusBadRequest},
+		{"job1", "", http.StatusOK},
+		{"job3", "", http.StatusNotFound},
+		{"job3", "video.mp4", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		target := "/jobs/x/video"
+		if tt.file != "" {
+			target += "?file=" + url.QueryEscape(tt.file)
+		}
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetPathValue("id", tt.id)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("id %q, file %q: status %d, want %d", tt.id, tt.file, rec.Code, tt.status)
+		}
+		if strings.Contains(rec.Body.String(), "private") || (tt.id != "job1" && strings.Contains(rec.Body.String(), "video")) {
+			t.Errorf("id %q, file %q: served %q", tt.id, tt.file, rec.Body.String())
+		}
+	}
+}
+
+func TestVideoFileJobWithoutVideo(t *testing.T) {
+	storage := localstorage.NewLocalStorage(t.TempDir())
+	if err := storage.InitJob(context.Background(), "job1"); err != nil {
+		t.Fatal(err)
+	}
+	saveFile(t, storage, "job1", "metadata_raw.json", "{}")
+	server := newVideoServer(t, storage)
+
+	if status, _ := getVideo(t, server, "/jobs/job1/video"); status != http.StatusNotFound {
+		t.Errorf("job without a video: status %d, want 404", status)
+	}
+}
+
+func TestVideoFileRange(t *testing.T) {
+	storage := localstorage.NewLocalStorage(t.TempDir())
+	if err := storage.InitJob(context.Background(), "job1"); err != nil {
+		t.Fatal(err)
+	}
+	saveFile(t, storage, "job1", "video.mp4", "0123456789")
+	server := newVideoServer(t, storage)
+
+	tests := []struct {
+		rangeHeader  string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"", http.StatusOK, "0123456789", ""},
+		{"bytes=2-5", http.StatusPartialContent, "2345", "bytes 2-5/10"},
+		{"bytes=7-", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"bytes=-3", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"bytes=20-", http.StatusRequestedRangeNotSatisfiable, "", "bytes */10"},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/jobs/job1/video", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.rangeHeader != "" {
+			req.Header.Set("Range", tt.rangeHeader)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || (tt.body != "" && string(body) != tt.body) {
+			t.Errorf("Range %q = %d %q, want %d %q", tt.rangeHeader, resp.StatusCode, body, tt.status, tt.body)
+		}
+		if got := resp.Header.Get("Content-Range"); got != tt.contentRange {
+			t.Errorf("Range %q: Content-Range %q, want %q", tt.rangeHeader, got, tt.contentRange)
+		}
+		if got := resp.Header.Get("Content-Type"); tt.status != http.StatusRequestedRangeNotSatisfiable && got != "video/mp4" {
+			t.Errorf("Range %q: Content-Type %q, want video/mp4", tt.rangeHeader, got)
+		}
+	}
+}
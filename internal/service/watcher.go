@@ -0,0 +1,304 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// readyMarkerSuffix marks an input file as fully written ("urls.txt.ready").
+const readyMarkerSuffix = ".ready"
+
+// WatchOptions controls a Watcher.
+type WatchOptions struct {
+	// Interval is how often the input directory is polled (default 2s).
+	Interval time.Duration
+
+	// Workers is the number of jobs run concurrently per file (default 1).
+	Workers int
+
+	// MaxAttempts is passed to RunJobWithRetry for each URL (default 1).
+	MaxAttempts int
+
+	// Force re-runs URLs an interrupted or failed earlier run of the same
+	// file already completed (see Orchestrator.ResumeBatch).
+	Force bool
+}
+
+// Watcher polls a directory for .txt/.json files of URLs, runs a job per
+// URL, and moves each file to processed/ or failed/ under the directory.
+// Files are run as ResumeBatch batches named after the file, so a file left
+// in place by a shutdown, or moved back from failed/, only runs the URLs
+// that haven't completed yet.
+//
+// A file is picked up once its "<name>.ready" marker exists, or once its
+// size and modification time are unchanged across two polls.
+type Watcher struct {
+	orchestrator *Orchestrator
+	inDir        string
+	opts         WatchOptions
+	seen         map[string]os.FileInfo
+}
+
+// NewWatcher creates a Watcher for inDir.
+func NewWatcher(orchestrator *Orchestrator, inDir string, opts WatchOptions) *Watcher {
+	if opts.Interval <= 0 {
+		opts.Interval = 2 * time.Second
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	return &Watcher{
+		orchestrator: orchestrator,
+		inDir:        inDir,
+		opts:         opts,
+		seen:         make(map[string]os.FileInfo),
+	}
+}
+
+// Run polls until ctx is cancelled or starts draining.
+func (w *Watcher) Run(ctx context.Context) error {
+	for _, dir := range []string{"processed", "failed"} {
+		if err := os.MkdirAll(filepath.Join(w.inDir, dir), 0755); err != nil {
+			return fmt.Errorf("failed to create %s directory: %w", dir, err)
+		}
+	}
+
+	w.orchestrator.logger.Printf("Watching %s for URL files (every %s)", w.inDir, w.opts.Interval)
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Poll(ctx); err != nil {
+			w.orchestrator.logger.Printf("WARNING: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-drainFrom(ctx):
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll scans the input directory once and processes every file that is
+// ready.
+func (w *Watcher) Poll(ctx context.Context) error {
+	entries, err := os.ReadDir(w.inDir)
+	if err != nil {
+		return fmt.Errorf("failed to read watch directory: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names[entry.Name()] = true
+		}
+	}
+
+	var ready []string
+	for name := range names {
+		ext := strings.ToLower(filepath.Ext(name))
+		if ext != ".txt" && ext != ".json" {
+			continue
+		}
+		if w.isReady(name, names[name+readyMarkerSuffix]) {
+			ready = append(ready, name)
+		}
+	}
+	sort.Strings(ready)
+
+	for name := range w.seen {
+		if !names[name] {
+			delete(w.seen, name)
+		}
+	}
+
+	for _, name := range ready {
+		if draining(ctx) {
+			return nil
+		}
+		w.process(ctx, name)
+	}
+	return nil
+}
+
+// isReady reports whether a file has finished being written.
+func (w *Watcher) isReady(name string, hasMarker bool) bool {
+	info, err := os.Stat(filepath.Join(w.inDir, name))
+	if err != nil {
+		return false
+	}
+	if hasMarker {
+		return true
+	}
+
+	prev, ok := w.seen[name]
+	w.seen[name] = info
+	return ok && prev.Size() == info.Size() && prev.ModTime().Equal(info.ModTime())
+}
+
+// process runs the jobs for one file and moves it out of the input directory.
+func (w *Watcher) process(ctx context.Context, name string) {
+	logger := w.orchestrator.logger
+	path := filepath.Join(w.inDir, name)
+	delete(w.seen, name)
+
+	items, err := readURLFile(path)
+	failed := err != nil
+	interrupted := false
+	if err != nil {
+		logger.Printf("ERROR: %s: %v", name, err)
+	} else {
+		logger.Printf("Processing %s (%d URLs)", name, len(items))
+		if w.opts.Force {
+			if err := w.orchestrator.ClearBatch(ctx, name); err != nil {
+				logger.Printf("WARNING: failed to clear checkpoint for %s: %v", name, err)
+			}
+		}
+		results := w.orchestrator.ResumeBatch(ctx, name, items, w.opts.Workers, w.opts.MaxAttempts)
+		for _, r := range results {
+			switch {
+			case errors.Is(r.Err, ErrDraining):
+				interrupted = true
+			case r.Err != nil:
+				logger.Printf("ERROR: %s: %s: %v", name, r.URL, r.Err)
+				failed = true
+			}
+		}
+		w.orchestrator.logSummary(name, results)
+		if units, cost := ApifyUsage(results); units > 0 || cost > 0 {
+			logger.Printf("%s: Apify usage %s", name, FormatApifyUsage(units, cost))
+		}
+	}
+
+	// Leave the file in place if we were interrupted so it's retried next run.
+	if interrupted || ctx.Err() != nil {
+		logger.Printf("Leaving %s in place: shutdown interrupted it", name)
+		return
+	}
+
+	dest := "processed"
+	if failed {
+		dest = "failed"
+	}
+	if err := os.Rename(path, filepath.Join(w.inDir, dest, name)); err != nil {
+		logger.Printf("WARNING: failed to move %s to %s: %v", name, dest, err)
+	}
+	if err := os.Remove(path + readyMarkerSuffix); err != nil && !os.IsNotExist(err) {
+		logger.Printf("WARNING: failed to remove ready marker for %s: %v", name, err)
+	}
+	logger.Printf("Moved %s to %s/", name, dest)
+
+	// Keep a failed file's checkpoint so a retry only re-runs its failures
+	if !failed {
+		if err := w.orchestrator.ClearBatch(ctx, name); err != nil {
+			logger.Printf("WARNING: failed to clear checkpoint for %s: %v", name, err)
+		}
+	}
+}
+
+// readURLFile reads URLs from a .txt file (one per line, "#" comments) or a
+// .json file ({"url": ...}, {"urls": [...]} or a plain array, where each
+// URL may also be {"url": ..., "external_id": ..., "quality": ...,
+// "audio_only": ...}).
+func readURLFile(path string) ([]BatchItem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var items []BatchItem
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		items, err = parseURLJSON(data)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				items = append(items, BatchItem{URL: line})
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no URLs found")
+	}
+	return items, nil
+}
+
+// parseURLJSON accepts {"url": ...}, {"urls": [...]} or [...].
+func parseURLJSON(data []byte) ([]BatchItem, error) {
+	var list []urlEntry
+	if err := json.Unmarshal(data, &list); err == nil {
+		return batchItems(list), nil
+	}
+
+	var doc struct {
+		URL        string     `json:"url"`
+		ExternalID string     `json:"external_id"`
+		URLs       []urlEntry `json:"urls"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	if doc.URL != "" {
+		doc.URLs = append([]urlEntry{{URL: doc.URL, ExternalID: doc.ExternalID}}, doc.URLs...)
+	}
+	return batchItems(doc.URLs), nil
+}
+
+// urlEntry is a URL in a .json file: a plain string or an object with an
+// optional external ID, formats and direct video URL. An object with only a
+// video_url uses it as its URL too.
+type urlEntry BatchItem
+
+func (e *urlEntry) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*e = urlEntry{URL: url}
+		return nil
+	}
+	var obj struct {
+		URL        string `json:"url"`
+		ExternalID string `json:"external_id"`
+		Quality    string `json:"quality"`
+		AudioOnly  bool   `json:"audio_only"`
+		VideoURL   string `json:"video_url"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	if obj.URL == "" {
+		obj.URL = obj.VideoURL
+	}
+	*e = urlEntry{URL: obj.URL, ExternalID: obj.ExternalID, Quality: obj.Quality, AudioOnly: obj.AudioOnly, VideoURL: obj.VideoURL}
+	return nil
+}
+
+func batchItems(entries []urlEntry) []BatchItem {
+	items := make([]BatchItem, 0, len(entries))
+	for _, e := range entries {
+		if e.URL != "" {
+			items = append(items, BatchItem(e))
+		}
+	}
+	return items
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes content to dir/name.
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// exists reports whether dir/name exists.
+func exists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+// A file dropped into the watched directory has its jobs run and is moved
+// to processed/, or to failed/ if any of them fails.
+func TestWatcherProcessesDroppedFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		wantDest string
+	}{
+		{"succeeded", "https://www.tiktok.com/@user/video/1\n# a comment\nhttps://www.tiktok.com/@user/video/2\n", "processed"},
+		{"failed", "https://www.tiktok.com/@user/video/1\nhttps://www.tiktok.com/@user/video/3\n", "failed"},
+		{"no URLs", "# nothing yet\n", "failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, scraper, downloader := tiktokItems("1", "2")
+			o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{})
+			in := t.TempDir()
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- NewWatcher(o, in, WatchOptions{Interval: time.Millisecond}).Run(ctx) }()
+
+			writeFile(t, in, "urls.txt", tt.content)
+			writeFile(t, in, "urls.txt.ready", "")
+			deadline := time.Now().Add(5 * time.Second)
+			for !exists(filepath.Join(in, tt.wantDest), "urls.txt") && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			cancel()
+			if err := <-done; err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			if !exists(filepath.Join(in, tt.wantDest), "urls.txt") {
+				t.Fatalf("urls.txt not moved to %s/", tt.wantDest)
+			}
+			if exists(in, "urls.txt") || exists(in, "urls.txt.ready") {
+				t.Error("urls.txt or its ready marker left in the input directory")
+			}
+			var want []string
+			for _, line := range strings.Split(tt.content, "\n") {
+				if strings.HasPrefix(line, "https://") {
+					want = append(want, line)
+				}
+			}
+			slices.Sort(scraper.calls)
+			if !slices.Equal(scraper.calls, want) {
+				t.Errorf("scraped %v, want %v", scraper.calls, want)
+			}
+		})
+	}
+}
+
+// Without a ready marker, a file is only picked up once it stops changing
+// between polls.
+func TestWatcherWaitsForStableFile(t *testing.T) {
+	_, scraper, downloader := tiktokItems("1", "2")
+	o, _ := newTestOrchestrator(t, scraper, downloader, nil, Options{})
+	in := t.TempDir()
+	for _, dir := range []string{"processed", "failed"} {
+		if err := os.Mkdir(filepath.Join(in, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := NewWatcher(o, in, WatchOptions{})
+	poll := func() {
+		t.Helper()
+		if err := w.Poll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(t, in, "urls.txt", "https://www.tiktok.com/@user/video/1\n")
+	writeFile(t, in, "notes.md", "https://www.tiktok.com/@user/video/2\n")
+	poll()
+	// Still being written
+	writeFile(t, in, "urls.txt", "https://www.tiktok.com/@user/video/1\nhttps://www.tiktok.com/@user/video/2\n")
+	poll()
+	if len(scraper.calls) != 0 || !exists(in, "urls.txt") {
+		t.Fatalf("a file still being written was processed: scraped %v", scraper.calls)
+	}
+
+	poll()
+	if !exists(filepath.Join(in, "processed"), "urls.txt") {
+		t.Fatal("stable file not processed")
+	}
+	if len(scraper.calls) != 2 {
+		t.Errorf("scraped %v, want both URLs of the complete file", scraper.calls)
+	}
+	if !exists(in, "notes.md") {
+		t.Error("a file that isn't .txt or .json was moved")
+	}
+}
+
+func TestReadURLFile(t *testing.T) {
+	tests := []struct {
+		name, file, content string
+		want                []BatchItem
+		wantErr             bool
+	}{
+		{"txt", "urls.txt", "  https://a  \n\n# skipped\nhttps://b\n", []BatchItem{{URL: "https://a"}, {URL: "https://b"}}, false},
+		{"json url", "job.json", `{"url": "https://a", "external_id": "ext1"}`, []BatchItem{{URL: "https://a", ExternalID: "ext1"}}, false},
+		{"json urls", "job.json", `{"urls": ["https://a", {"url": "https://b", "quality": "720p", "audio_only": true}]}`,
+			[]BatchItem{{URL: "https://a"}, {URL: "https://b", Quality: "720p", AudioOnly: true}}, false},
+		{"json array", "job.json", `["https://a", {"video_url": "https://cdn/v.mp4"}, {"url": ""}]`,
+			[]BatchItem{{URL: "https://a"}, {URL: "https://cdn/v.mp4", VideoURL: "https://cdn/v.mp4"}}, false},
+		{"empty", "urls.txt", "# nothing\n", nil, true},
+		{"bad json", "job.json", `{"url": `, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFile(t, dir, tt.file, tt.content)
+			got, err := readURLFile(filepath.Join(dir, tt.file))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readURLFile err = %v, want an error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readURLFile = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}